@@ -0,0 +1,433 @@
+package asm
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+/*
+	Two pass assembler for the 80C196 instruction set, reading the same mnemonic and
+	operand syntax that the disasm package prints:
+
+		label:	MNEMONIC op1, op2, op3	; comment
+
+	Operands:
+		R_40		register (direct)
+		#1234		immediate, hex like the disassembler prints it (#0x1234, #label also work)
+		[R_30]		indirect
+		[R_30]+		indirect with auto-increment ([R_30+] is accepted too)
+		0x12[R_30]	short-indexed (1-2 hex digits), 0x1234[R_30] long-indexed
+		0x123456[R_30]	extended-indexed (ELD/EST only)
+		0x172080	code address for jumps and calls, or a label
+
+	The " ~(...)" register annotations the disassembler appends are ignored.
+
+	Directives: ORG address, name EQU value, DCB/DCW/DCL value, ...
+*/
+
+// Segment is a run of assembled bytes starting at Address
+type Segment struct {
+	Address int
+	Bytes   []byte
+}
+
+// Program is the output of an assembly run
+type Program struct {
+	Segments []Segment
+	Labels   map[string]int
+	Promoted []Promotion // branches assembled in a longer form, see promote.go
+}
+
+type line struct {
+	number   int
+	address  int
+	label    string
+	mnemonic string
+	operands []string
+}
+
+type assembler struct {
+	labels   map[string]int
+	lines    []line
+	final    bool
+	promoted map[int]int // line number to how many forms longer its branch is
+	set      *disasm.InstructionSet
+}
+
+var annotation = regexp.MustCompile(`\s*~(\([^)]*\))?`)
+
+// Assemble runs both passes over the source, starting at origin until the first ORG
+func Assemble(src string, origin int) (*Program, error) {
+	return AssembleSet(disasm.InstructionSets[disasm.DefaultInstructionSet], src, origin)
+}
+
+// AssembleSet assembles for a part with the instructions of set, see Assemble. A branch
+// isn't promoted to a form the set doesn't have.
+func AssembleSet(set *disasm.InstructionSet, src string, origin int) (*Program, error) {
+	a := &assembler{labels: make(map[string]int), promoted: make(map[int]int), set: set}
+
+	if err := a.read(src); err != nil {
+		return nil, err
+	}
+
+	// Pass 1 - sizes and label addresses, again each time a branch has to be promoted
+	if err := a.pass(origin, nil); err != nil {
+		return nil, err
+	}
+	for a.promote() {
+		if err := a.pass(origin, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pass 2 - encode with every label known
+	a.final = true
+	prog := &Program{Labels: a.labels}
+	if err := a.pass(origin, prog); err != nil {
+		return nil, err
+	}
+	prog.Promoted = a.promotionList()
+
+	return prog, nil
+}
+
+// Read splits the source into labels, mnemonics and operands
+func (a *assembler) read(src string) error {
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := scanner.Text()
+		if i := strings.Index(text, ";"); i >= 0 {
+			text = text[:i]
+		}
+		text = annotation.ReplaceAllString(text, "")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		l := line{number: n}
+
+		// Label
+		if i := strings.Index(text, ":"); i > 0 && isSymbol(text[:i]) {
+			l.label = text[:i]
+			text = strings.TrimSpace(text[i+1:])
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) > 0 {
+			l.mnemonic = strings.ToUpper(fields[0])
+			text = strings.TrimSpace(text[len(fields[0]):])
+
+			// Signed multiply/divide print as "SGN MUL"
+			if l.mnemonic == "SGN" && len(fields) > 1 {
+				l.mnemonic = "SGN " + strings.ToUpper(fields[1])
+				text = strings.TrimSpace(text[len(fields[1]):])
+			}
+
+			// name EQU value
+			if len(fields) > 2 && strings.ToUpper(fields[1]) == "EQU" {
+				l.label = fields[0]
+				l.mnemonic = "EQU"
+				text = strings.TrimSpace(strings.Join(fields[2:], " "))
+			}
+		}
+
+		if text != "" {
+			for _, op := range strings.Split(text, ",") {
+				l.operands = append(l.operands, strings.TrimSpace(op))
+			}
+		}
+
+		a.lines = append(a.lines, l)
+	}
+	return scanner.Err()
+}
+
+// Pass walks every line once, emitting bytes into prog when it is given
+func (a *assembler) pass(origin int, prog *Program) error {
+	pc := origin
+	var seg *Segment
+	defined := make(map[string]bool)
+
+	for i := range a.lines {
+		l := &a.lines[i]
+
+		switch l.mnemonic {
+		case "EQU":
+			if len(l.operands) != 1 {
+				return lineErr(l, fmt.Errorf("EQU needs one value"))
+			}
+			val, err := a.value(l.operands[0])
+			if err != nil {
+				return lineErr(l, err)
+			}
+			a.labels[l.label] = val.value
+			continue
+
+		case "ORG":
+			if len(l.operands) != 1 {
+				return lineErr(l, fmt.Errorf("ORG needs one address"))
+			}
+			val, err := a.value(l.operands[0])
+			if err != nil {
+				return lineErr(l, err)
+			}
+			pc = val.value
+			seg = nil
+		}
+
+		l.address = pc
+		if l.label != "" {
+			if addr, ok := a.labels[l.label]; ok && defined[l.label] && addr != pc {
+				return lineErr(l, fmt.Errorf("Label %s defined twice", l.label))
+			}
+			a.labels[l.label] = pc
+			defined[l.label] = true
+		}
+
+		if l.mnemonic == "" || l.mnemonic == "ORG" {
+			continue
+		}
+
+		out, err := a.emit(l)
+		if err != nil {
+			return lineErr(l, err)
+		}
+
+		if prog != nil && len(out) > 0 {
+			if seg == nil {
+				prog.Segments = append(prog.Segments, Segment{Address: pc})
+				seg = &prog.Segments[len(prog.Segments)-1]
+			}
+			seg.Bytes = append(seg.Bytes, out...)
+		}
+		pc += len(out)
+	}
+
+	return nil
+}
+
+// Emit assembles one directive or instruction line
+func (a *assembler) emit(l *line) ([]byte, error) {
+	switch l.mnemonic {
+	case "DCB", "DB":
+		return a.data(l.operands, 1)
+	case "DCW", "DW":
+		return a.data(l.operands, 2)
+	case "DCL", "DL":
+		return a.data(l.operands, 4)
+	}
+
+	ops := make([]operand, len(l.operands))
+	for i, s := range l.operands {
+		op, err := a.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		ops[i] = op
+	}
+
+	if a.promoted[l.number] > 0 {
+		return a.encodePromoted(l, ops)
+	}
+	return a.encode(l.mnemonic, ops, l.address)
+}
+
+// Data directives, little endian like everything else on the 196
+func (a *assembler) data(operands []string, width int) ([]byte, error) {
+	var out []byte
+	for _, s := range operands {
+		if width == 1 && len(s) > 1 && s[0] == '"' && s[len(s)-1] == '"' {
+			out = append(out, []byte(s[1:len(s)-1])...)
+			continue
+		}
+		val, err := a.value(s)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < width; i++ {
+			out = append(out, byte(val.value>>uint(8*i)))
+		}
+	}
+	return out, nil
+}
+
+// Bytes flattens the program into one image starting at the lowest segment address,
+// filling any gaps with fill
+func (p *Program) Bytes(fill byte) (int, []byte) {
+	if len(p.Segments) == 0 {
+		return 0, nil
+	}
+
+	segs := make([]Segment, len(p.Segments))
+	copy(segs, p.Segments)
+	sort.Sort(segments(segs))
+
+	start := segs[0].Address
+	end := start
+	for _, s := range segs {
+		if s.Address+len(s.Bytes) > end {
+			end = s.Address + len(s.Bytes)
+		}
+	}
+
+	out := make([]byte, end-start)
+	for i := range out {
+		out[i] = fill
+	}
+	for _, s := range segs {
+		copy(out[s.Address-start:], s.Bytes)
+	}
+	return start, out
+}
+
+// Apply writes the program into an image that is addressed from zero, like the
+// blocks the disasm package works on
+func (p *Program) Apply(block []byte) error {
+	for _, s := range p.Segments {
+		if s.Address < 0 || s.Address+len(s.Bytes) > len(block) {
+			return fmt.Errorf("Segment 0x%X (%d bytes) is outside the image", s.Address, len(s.Bytes))
+		}
+		copy(block[s.Address:], s.Bytes)
+	}
+	return nil
+}
+
+type segments []Segment
+
+func (s segments) Len() int {
+	return len(s)
+}
+
+func (s segments) Less(i, j int) bool {
+	return s[i].Address < s[j].Address
+}
+
+func (s segments) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Numbers and symbols
+type value struct {
+	value    int
+	digits   int  // hex digits written, used to pick short vs long forms
+	symbolic bool // refers to a label, so its size can't depend on the value
+}
+
+// Value evaluates a number, a label, or a simple sum of them (label+2, 0x10-1)
+func (a *assembler) value(s string) (value, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return value{}, fmt.Errorf("Missing value")
+	}
+
+	var v value
+	sign := 1
+	start := 0
+	terms := 0
+	if s[0] == '-' {
+		sign = -1
+		start = 1
+	}
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && (s[i] != '+' && s[i] != '-' || i == start) {
+			continue
+		}
+
+		term := strings.TrimSpace(s[start:i])
+		if term != "" {
+			t, err := a.term(term)
+			if err != nil {
+				return value{}, err
+			}
+			v.value += sign * t.value
+			v.symbolic = v.symbolic || t.symbolic
+			if terms == 0 {
+				v.digits = t.digits
+			} else {
+				v.digits = 0
+			}
+			terms++
+		}
+
+		if i < len(s) {
+			sign = 1
+			if s[i] == '-' {
+				sign = -1
+			}
+		}
+		start = i + 1
+	}
+
+	return v, nil
+}
+
+func (a *assembler) term(s string) (value, error) {
+	upper := strings.ToUpper(s)
+
+	switch {
+	case strings.HasPrefix(upper, "0X"):
+		n, err := strconv.ParseInt(s[2:], 16, 64)
+		return value{value: int(n), digits: len(s) - 2}, err
+
+	case strings.HasPrefix(upper, "R_"):
+		n, err := strconv.ParseInt(s[2:], 16, 64)
+		return value{value: int(n), digits: len(s) - 2}, err
+
+	case strings.HasSuffix(upper, "H") && isHex(s[:len(s)-1]) && s[0] >= '0' && s[0] <= '9':
+		n, err := strconv.ParseInt(s[:len(s)-1], 16, 64)
+		return value{value: int(n), digits: len(s) - 1}, err
+
+	case s[0] >= '0' && s[0] <= '9':
+		n, err := strconv.ParseInt(s, 10, 64)
+		return value{value: int(n)}, err
+
+	case isSymbol(s):
+		addr, ok := a.labels[s]
+		if !ok && a.final {
+			return value{}, fmt.Errorf("Undefined label: %s", s)
+		}
+		return value{value: addr, symbolic: true}, nil
+	}
+
+	return value{}, fmt.Errorf("Unable to read value: %s", s)
+}
+
+func isSymbol(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case i > 0 && c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+func lineErr(l *line, err error) error {
+	return fmt.Errorf("Line %d: %s", l.number, err)
+}
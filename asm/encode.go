@@ -0,0 +1,480 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+type operand struct {
+	text    string
+	mode    string // direct, immediate, indirect or indexed
+	autoInc bool
+	reg     value // base register of indirect and indexed operands
+	val     value // register, immediate, displacement or code address
+}
+
+// Encode assembles a single instruction, picking the addressing mode variant from the
+// operands: Encode("ADD", "R_40", "R_42", "0x12[R_30]"). Branch targets are absolute
+// addresses, so jumps and calls should go through EncodeAt.
+func Encode(mnemonic string, operands ...string) ([]byte, error) {
+	return EncodeAt(0, mnemonic, operands...)
+}
+
+// EncodeAt assembles a single instruction that will sit at address
+func EncodeAt(address int, mnemonic string, operands ...string) ([]byte, error) {
+	a := &assembler{labels: make(map[string]int), final: true, set: disasm.InstructionSets[disasm.DefaultInstructionSet]}
+
+	mnemonic = strings.ToUpper(strings.Join(strings.Fields(mnemonic), " "))
+
+	ops := make([]operand, len(operands))
+	for i, s := range operands {
+		s = strings.TrimSpace(annotation.ReplaceAllString(s, ""))
+		op, err := a.operand(s)
+		if err != nil {
+			return nil, err
+		}
+		ops[i] = op
+	}
+
+	return a.encode(mnemonic, ops, address)
+}
+
+// Operand reads one operand in the disassembler's syntax
+func (a *assembler) operand(s string) (operand, error) {
+	op := operand{text: s}
+	var err error
+
+	switch {
+	case strings.HasPrefix(s, "#"):
+		op.mode = "immediate"
+		imm := strings.TrimSpace(s[1:])
+
+		// The disassembler prints immediates as bare hex (#00FF)
+		if isHex(imm) {
+			op.val, err = a.value("0x" + imm)
+		} else {
+			op.val, err = a.value(imm)
+		}
+
+	case strings.HasPrefix(s, "["):
+		op.mode = "indirect"
+		inner := s[1:]
+		if strings.HasSuffix(inner, "]+") {
+			op.autoInc = true
+			inner = strings.TrimSuffix(inner, "]+")
+		} else if strings.HasSuffix(inner, "+]") {
+			op.autoInc = true
+			inner = strings.TrimSuffix(inner, "+]")
+		} else if strings.HasSuffix(inner, "]") {
+			inner = strings.TrimSuffix(inner, "]")
+		} else {
+			return op, fmt.Errorf("Unbalanced brackets: %s", s)
+		}
+		op.reg, err = a.value(inner)
+
+	case strings.HasSuffix(s, "]") && strings.Contains(s, "["):
+		op.mode = "indexed"
+		i := strings.Index(s, "[")
+		op.val, err = a.value(s[:i])
+		if err == nil {
+			op.reg, err = a.value(s[i+1 : len(s)-1])
+		}
+
+	default:
+		op.mode = "direct"
+		op.val, err = a.value(s)
+	}
+
+	return op, err
+}
+
+// Encode builds the machine code for one instruction at address
+func (a *assembler) encode(mnemonic string, ops []operand, address int) ([]byte, error) {
+	signed := false
+	if strings.HasPrefix(mnemonic, "SGN ") {
+		signed = true
+		mnemonic = strings.TrimPrefix(mnemonic, "SGN ")
+	}
+
+	// BR shares its opcode with EBR, the low bit of the register picks which
+	if mnemonic == "BR" {
+		if len(ops) != 1 || ops[0].mode != "indirect" {
+			return nil, fmt.Errorf("BR takes one indirect operand: BR [R_xx]")
+		}
+		return []byte{0xE3, byte(ops[0].reg.value) & 0xFE}, nil
+	}
+
+	candidates := a.lookup(mnemonic, signed)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("Unknown mnemonic: %s", mnemonic)
+	}
+
+	first := candidates[0]
+	switch {
+	case first.Op&0xF0 == 0x20:
+		// SJMP, SCALL - 11 bit displacement, the top three bits live in the opcode
+		if err := count(mnemonic, ops, 1); err != nil {
+			return nil, err
+		}
+		disp := relative(ops[0].val.value, address+2)
+		if err := a.inRange(mnemonic, disp, -1024, 1023); err != nil {
+			return nil, err
+		}
+		xxx, lo := disasm.SplitOffset(disp)
+		return []byte{first.Op&0xF8 | xxx, lo}, nil
+
+	case first.Op&0xF0 == 0x30:
+		// JBC, JBS - the bit number lives in the opcode
+		if err := count(mnemonic, ops, 3); err != nil {
+			return nil, err
+		}
+		bit := ops[1].val.value
+		if bit < 0 || bit > 7 {
+			return nil, fmt.Errorf("Bit number out of range: %d", bit)
+		}
+		disp := relative(ops[2].val.value, address+3)
+		if err := a.inRange(mnemonic, disp, -128, 127); err != nil {
+			return nil, err
+		}
+		reg, err := a.register(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		return []byte{first.Op&0xF8 | byte(bit), reg, byte(disp)}, nil
+
+	case first.Op&0xF0 == 0xD0:
+		// Conditional jumps
+		if err := count(mnemonic, ops, 1); err != nil {
+			return nil, err
+		}
+		disp := relative(ops[0].val.value, address+2)
+		if err := a.inRange(mnemonic, disp, -128, 127); err != nil {
+			return nil, err
+		}
+		return []byte{first.Op, byte(disp)}, nil
+	}
+
+	switch first.Op {
+	case 0xE0, 0xE1:
+		// DJNZ, DJNZW
+		if err := count(mnemonic, ops, 2); err != nil {
+			return nil, err
+		}
+		reg, err := a.register(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		disp := relative(ops[1].val.value, address+3)
+		if err := a.inRange(mnemonic, disp, -128, 127); err != nil {
+			return nil, err
+		}
+		return []byte{first.Op, reg, byte(disp)}, nil
+
+	case 0xE7, 0xEF:
+		// LJMP, LCALL
+		if err := count(mnemonic, ops, 1); err != nil {
+			return nil, err
+		}
+		disp := relative(ops[0].val.value, address+3)
+		if err := a.inRange(mnemonic, disp, -32768, 32767); err != nil {
+			return nil, err
+		}
+		return []byte{first.Op, byte(disp), byte(disp >> 8)}, nil
+
+	case 0xE6, 0xF1:
+		// EJMP, ECALL
+		if err := count(mnemonic, ops, 1); err != nil {
+			return nil, err
+		}
+		disp := relative(ops[0].val.value, address+4)
+		return []byte{first.Op, byte(disp), byte(disp >> 8), byte(disp >> 16)}, nil
+
+	case 0xE3:
+		// EBR [treg]
+		if len(ops) != 1 || ops[0].mode != "indirect" {
+			return nil, fmt.Errorf("EBR takes one indirect operand: EBR [R_xx]")
+		}
+		return []byte{0xE3, byte(ops[0].reg.value) | 0x01}, nil
+
+	case 0xE2:
+		// TIJMP TBASE, [INDEX], #MASK
+		if err := count(mnemonic, ops, 3); err != nil {
+			return nil, err
+		}
+		if ops[1].mode != "indirect" || ops[2].mode != "immediate" {
+			return nil, fmt.Errorf("TIJMP takes TBASE, [INDEX], #MASK")
+		}
+		tbase, err := a.register(ops[0])
+		if err != nil {
+			return nil, err
+		}
+		return []byte{0xE2, byte(ops[1].reg.value), byte(ops[2].val.value), tbase}, nil
+
+	case 0x00:
+		// SKIP, the second byte is ignored
+		if len(ops) == 0 {
+			return []byte{0x00, 0x00}, nil
+		}
+		return []byte{0x00, byte(ops[0].val.value)}, nil
+
+	case 0xF6:
+		// IDLPD #key
+		if len(ops) != 1 || ops[0].mode != "immediate" {
+			return nil, fmt.Errorf("IDLPD takes the key: IDLPD #key")
+		}
+		return []byte{0xF6, byte(ops[0].val.value)}, nil
+
+	case 0xC1, 0xC5, 0xCD, 0xE4:
+		// BMOV, CMPL, BMOVI, EBMOVI - two registers
+		if err := count(mnemonic, ops, 2); err != nil {
+			return nil, err
+		}
+		return a.registers(first.Op, ops)
+	}
+
+	// ELD, ELDB, EST, ESTB
+	if strings.HasPrefix(first.AddressingMode, "extended") {
+		return a.extended(mnemonic, candidates, ops)
+	}
+
+	// No operands
+	if len(first.VarStrings) == 0 {
+		if err := count(mnemonic, ops, 0); err != nil {
+			return nil, err
+		}
+		return []byte{first.Op}, nil
+	}
+
+	return a.generic(mnemonic, candidates, ops)
+}
+
+// Generic handles the aa addressing mode instructions - every operand is a register
+// except the last, which can be direct, immediate, indirect or indexed
+func (a *assembler) generic(mnemonic string, candidates []disasm.Instruction, ops []operand) ([]byte, error) {
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("%s needs operands", mnemonic)
+	}
+	last := ops[len(ops)-1]
+
+	mode := last.mode
+	long := false
+	switch last.mode {
+	case "indexed":
+		long = a.isLong(last.val)
+
+	case "direct":
+		// Anything past the register file goes through the zero register
+		if last.val.value > 0xFF {
+			mode = "indexed"
+			long = true
+			last.reg = value{value: 0x00}
+		}
+	}
+
+	var instr *disasm.Instruction
+	for i := range candidates {
+		c := &candidates[i]
+		if len(c.VarStrings) != len(ops) {
+			continue
+		}
+		if c.AddressingMode == mode || (mode == "immediate" && c.AddressingMode == "direct" && isCount(c)) {
+			instr = c
+			break
+		}
+	}
+	if instr == nil {
+		return nil, fmt.Errorf("No %s form of %s with %d operands", mode, mnemonic, len(ops))
+	}
+
+	var out []byte
+	if instr.Signed {
+		out = append(out, 0xFE)
+	}
+	out = append(out, instr.Op)
+
+	// The last operand comes first in the instruction stream
+	switch mode {
+	case "direct":
+		reg, err := a.register(last)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, reg)
+
+	case "immediate":
+		imm := last.val.value
+		if isCount(instr) {
+			if a.final && (imm < 0 || imm > 0x0F) {
+				return nil, fmt.Errorf("Shift count out of range: %d", imm)
+			}
+			out = append(out, byte(imm))
+		} else if instr.VarStrings[len(instr.VarStrings)-1] == "waop" {
+			out = append(out, byte(imm), byte(imm>>8))
+		} else {
+			out = append(out, byte(imm))
+		}
+
+	case "indirect":
+		reg := byte(last.reg.value) & 0xFE
+		if last.autoInc {
+			reg |= 0x01
+		}
+		out = append(out, reg)
+
+	case "indexed":
+		reg := byte(last.reg.value) & 0xFE
+		disp := last.val.value
+		if long {
+			out = append(out, reg|0x01, byte(disp), byte(disp>>8))
+		} else {
+			if a.final && last.val.digits == 0 && (disp < -128 || disp > 127) {
+				return nil, fmt.Errorf("Short index out of range: %d", disp)
+			}
+			out = append(out, reg, byte(disp))
+		}
+	}
+
+	// Then the registers, last to first
+	for i := len(ops) - 2; i >= 0; i-- {
+		reg, err := a.register(ops[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, reg)
+	}
+
+	return out, nil
+}
+
+// Extended handles ELD/ELDB/EST/ESTB with their 24 bit pointers
+func (a *assembler) extended(mnemonic string, candidates []disasm.Instruction, ops []operand) ([]byte, error) {
+	if err := count(mnemonic, ops, 2); err != nil {
+		return nil, err
+	}
+
+	reg, err := a.register(ops[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// A bare register is taken as [treg]
+	ptr := ops[1]
+	if ptr.mode == "direct" {
+		ptr.mode = "indirect"
+		ptr.reg = ptr.val
+	}
+
+	mode := "extended-" + ptr.mode
+	for _, c := range candidates {
+		if c.AddressingMode != mode {
+			continue
+		}
+		treg := byte(ptr.reg.value)
+		if mode == "extended-indirect" {
+			return []byte{c.Op, treg, reg}, nil
+		}
+		disp := ptr.val.value
+		return []byte{c.Op, treg, byte(disp), byte(disp >> 8), byte(disp >> 16), reg}, nil
+	}
+
+	return nil, fmt.Errorf("%s takes a register and [treg] or disp[treg]", mnemonic)
+}
+
+// Registers encodes instructions whose operands are all registers, last to first. A
+// register in brackets is taken as the register, [R_30]+ as R_31, the way the block moves
+// were once printed.
+func (a *assembler) registers(op byte, ops []operand) ([]byte, error) {
+	out := []byte{op}
+	for i := len(ops) - 1; i >= 0; i-- {
+		o := ops[i]
+		if o.mode == "indirect" {
+			o.mode, o.val = "direct", o.reg
+			if o.autoInc {
+				o.val.value |= 0x01
+			}
+		}
+		reg, err := a.register(o)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, reg)
+	}
+	return out, nil
+}
+
+// Register checks that an operand is a plain register file address
+func (a *assembler) register(op operand) (byte, error) {
+	if op.mode != "direct" {
+		return 0, fmt.Errorf("Expected a register: %s", op.text)
+	}
+	if a.final && (op.val.value < 0 || op.val.value > 0xFF) {
+		return 0, fmt.Errorf("Register out of range: %s", op.text)
+	}
+	return byte(op.val.value), nil
+}
+
+// IsLong picks long-indexed for labels, 3-4 hex digit displacements, and anything
+// that doesn't fit a signed byte
+func (a *assembler) isLong(v value) bool {
+	if v.symbolic {
+		return true
+	}
+	if v.digits > 0 {
+		return v.digits > 2
+	}
+	return v.value < -128 || v.value > 127
+}
+
+// The displacement from next to target, taken round the address space the way the
+// disassembler wraps targets, so a jump it printed near either end reaches the same place
+func relative(target, next int) int {
+	span := disasm.DefaultAddressTranslation.Mask + 1
+	disp := (target - next) % span
+	if disp >= span/2 {
+		disp -= span
+	} else if disp < -span/2 {
+		disp += span
+	}
+	return disp
+}
+
+func (a *assembler) inRange(mnemonic string, disp, min, max int) error {
+	if a.final && (disp < min || disp > max) {
+		return fmt.Errorf("%s target out of range: displacement %d", mnemonic, disp)
+	}
+	return nil
+}
+
+func count(mnemonic string, ops []operand, n int) error {
+	if len(ops) != n {
+		return fmt.Errorf("%s takes %d operands, got %d", mnemonic, n, len(ops))
+	}
+	return nil
+}
+
+// IsCount is true for the shifts, which take an immediate count in the direct form
+func isCount(instr *disasm.Instruction) bool {
+	return instr.VarStrings[len(instr.VarStrings)-1] == "breg/#count" && instr.AddressingMode == "direct"
+}
+
+// Lookup finds every table entry for a mnemonic in the set
+func (a *assembler) lookup(mnemonic string, signed bool) []disasm.Instruction {
+	var found []disasm.Instruction
+	for _, s := range []bool{false, true} {
+		if signed && !s {
+			continue
+		}
+		for op := 0; op < 0x100; op++ {
+			instr, ok := a.set.Lookup(byte(op), s)
+			if !ok || instr.Reserved || instr.Mnemonic != mnemonic {
+				continue
+			}
+			instr.Op = byte(op)
+			instr.Signed = s
+			found = append(found, instr)
+		}
+	}
+	return found
+}
@@ -0,0 +1,77 @@
+package asm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+// Branches the disassembler printed near the bottom of the address space, reaching round
+// to the top, assemble back to their own bytes
+func TestWrappedBranches(t *testing.T) {
+	for _, in := range [][]byte{
+		{0xE7, 0x00, 0xF0},       // LJMP back past 0
+		{0xEF, 0x00, 0xF0},       // LCALL
+		{0x27, 0x00},             // SJMP
+		{0xDF, 0x80},             // JE
+		{0xE6, 0x00, 0xF0, 0xFF}, // EJMP
+	} {
+		instr, err := disasm.Parse(in, 0x100)
+		if err != nil {
+			t.Fatalf("%X: %s", in, err)
+		}
+		ops := make([]string, len(instr.Ops))
+		for i, op := range instr.Ops {
+			ops[i] = op.String()
+		}
+		out, err := EncodeAt(0x100, instr.Mnemonic, ops...)
+		if err != nil || !bytes.Equal(out, in) {
+			t.Errorf("%s at 0x100 assembled to %X (%v), want %X", instr, out, err, in)
+		}
+	}
+}
+
+// The block moves take their registers in brackets too, [R_30]+ as R_31
+func TestBlockMoveBrackets(t *testing.T) {
+	for _, c := range []struct {
+		ops  []string
+		want []byte
+	}{
+		{[]string{"R_32", "R_31"}, []byte{0xCD, 0x31, 0x32}},
+		{[]string{"R_32", "[R_30]+"}, []byte{0xCD, 0x31, 0x32}},
+		{[]string{"R_32", "[R_30]"}, []byte{0xCD, 0x30, 0x32}},
+	} {
+		out, err := Encode("BMOVI", c.ops...)
+		if err != nil || !bytes.Equal(out, c.want) {
+			t.Errorf("BMOVI %v assembled to %X (%v), want %X", c.ops, out, err, c.want)
+		}
+	}
+}
+
+// A branch too far for LJMP becomes EJMP on the 196EA, and is an error on a part without
+// the extended forms
+func TestFarBranchSet(t *testing.T) {
+	src := "\tORG 0x2000\nstart:\tSJMP far\n\tSCALL far\n\tORG 0x30000\nfar:\tRET\n"
+
+	prog, err := Assemble(src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var to []string
+	for _, p := range prog.Promoted {
+		to = append(to, p.To)
+	}
+	if len(to) != 2 || to[0] != "EJMP" || to[1] != "ECALL" {
+		t.Errorf("196EA promoted to %v, want EJMP and ECALL", to)
+	}
+
+	kr, err := disasm.FindInstructionSet("196kr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AssembleSet(kr, src, 0); err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("196KR assembled a branch past LJMP's reach: %v", err)
+	}
+}
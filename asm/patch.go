@@ -0,0 +1,129 @@
+package asm
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+// Patch is replacement code spliced over existing instructions at Address
+type Patch struct {
+	Address  int
+	Source   string
+	Bytes    []byte               // assembled code, padded out to the next instruction boundary
+	Replaced []disasm.Instruction // original instructions the patch covers
+	Warnings []string
+}
+
+// NewPatch starts a patch of source at address
+func NewPatch(address int, source string) *Patch {
+	return &Patch{Address: address, Source: source}
+}
+
+// Build assembles the patch against block and checks that it can be spliced in cleanly.
+// The patch always ends on an original instruction boundary, any leftover bytes are
+// filled with SKIP/NOP. When an analysis is given, jump, call and xref targets that land
+// inside the replaced range (other than Address itself) are reported in Warnings, as are
+// branches that had to be assembled in a longer form to reach their targets.
+func (p *Patch) Build(block []byte, an *disasm.Analysis) error {
+	p.Bytes = nil
+	p.Replaced = nil
+	p.Warnings = nil
+
+	prog, err := Assemble(p.Source, p.Address)
+	if err != nil {
+		return err
+	}
+	if len(prog.Segments) != 1 || prog.Segments[0].Address != p.Address {
+		return fmt.Errorf("Patch must be one block of code starting at 0x%X", p.Address)
+	}
+	code := prog.Segments[0].Bytes
+	for _, pr := range prog.Promoted {
+		p.Warnings = append(p.Warnings, pr.String())
+	}
+
+	// Walk the original instructions until the patch is covered
+	end := p.Address
+	for end < p.Address+len(code) {
+		if end >= len(block) {
+			return fmt.Errorf("Patch runs past the end of the image at 0x%X", end)
+		}
+		stop := end + 10
+		if stop > len(block) {
+			stop = len(block)
+		}
+		instr, err := disasm.Parse(block[end:stop], end)
+		if _, ok := err.(disasm.ErrTruncated); ok {
+			return fmt.Errorf("Patch runs past the end of the image at 0x%X", end)
+		}
+		if err != nil {
+			return fmt.Errorf("Unable to parse original instruction at 0x%X: %s", end, err)
+		}
+		p.Replaced = append(p.Replaced, instr)
+		end += instr.ByteLength
+	}
+
+	// Pad to the boundary, 2 byte SKIPs then a NOP for an odd byte
+	padding := end - (p.Address + len(code))
+	for ; padding >= 2; padding -= 2 {
+		skip, _ := Encode("SKIP")
+		code = append(code, skip...)
+	}
+	if padding == 1 {
+		nop, _ := Encode("NOP")
+		code = append(code, nop...)
+	}
+	p.Bytes = code
+
+	if an != nil {
+		p.Warnings = append(p.Warnings, p.targets(an, end)...)
+	}
+
+	return nil
+}
+
+// Anything that jumps, calls or points into the middle of the replaced range
+func (p *Patch) targets(an *disasm.Analysis, end int) []string {
+	var warnings []string
+	inside := func(adr int) bool {
+		return adr > p.Address && adr < end
+	}
+
+	for adr, jumps := range an.Jumps {
+		if inside(adr) {
+			for _, j := range jumps {
+				warnings = append(warnings, fmt.Sprintf("0x%X: %s from 0x%X lands inside the patch", adr, j.Mnemonic, j.JumpFrom))
+			}
+		}
+	}
+	for adr, calls := range an.Subroutines {
+		if inside(adr) {
+			for _, c := range calls {
+				warnings = append(warnings, fmt.Sprintf("0x%X: %s from 0x%X lands inside the patch", adr, c.Mnemonic, c.CallFrom))
+			}
+		}
+	}
+	for adr, xrefs := range an.XRefs {
+		if inside(adr) {
+			for _, x := range xrefs {
+				warnings = append(warnings, fmt.Sprintf("0x%X: XREF by %s at 0x%X points inside the patch", adr, x.Mnemonic, x.XRefFrom))
+			}
+		}
+	}
+
+	sort.Strings(warnings)
+	return warnings
+}
+
+// Apply writes a built patch into block
+func (p *Patch) Apply(block []byte) error {
+	if p.Bytes == nil {
+		return fmt.Errorf("Patch at 0x%X hasn't been built", p.Address)
+	}
+	if p.Address < 0 || p.Address+len(p.Bytes) > len(block) {
+		return fmt.Errorf("Patch at 0x%X (%d bytes) is outside the image", p.Address, len(p.Bytes))
+	}
+	copy(block[p.Address:], p.Bytes)
+	return nil
+}
@@ -0,0 +1,140 @@
+package asm
+
+import "fmt"
+
+/*
+	Branch promotion. SJMP and SCALL reach -1024..+1023 bytes and JBC and JBS -128..+127,
+	a target further away is assembled with the long form instead of failing: SJMP becomes
+	LJMP and SCALL LCALL (+-32K), then EJMP and ECALL past that on a part that has them,
+	a target out of reach of LJMP is an error on one that doesn't. JBC and JBS have no long
+	form, they become the opposite test jumping over an LJMP or EJMP to the target:
+
+		JBS R_30, 3, far	->	JBC R_30, 3, $+6
+					LJMP far
+
+	A longer form moves every label after it, so the first pass is run again until no
+	more branches need promoting. Each one is listed in Program.Promoted.
+*/
+
+// Promotion is a branch assembled in a longer form to reach its target
+type Promotion struct {
+	Line    int
+	Address int
+	From    string // the mnemonic written
+	To      string // what was assembled instead
+}
+
+func (p Promotion) String() string {
+	return fmt.Sprintf("Line %d: %s at 0x%X is out of range, assembled as %s", p.Line, p.From, p.Address, p.To)
+}
+
+// The longer forms of each branch that can be promoted, shortest first
+var promotions = map[string][]string{
+	"SJMP":  {"LJMP", "EJMP"},
+	"SCALL": {"LCALL", "ECALL"},
+	"JBC":   {"LJMP", "EJMP"},
+	"JBS":   {"LJMP", "EJMP"},
+}
+
+// The longer forms of a branch the instruction set has
+func (a *assembler) longer(mnemonic string) []string {
+	var forms []string
+	for _, form := range promotions[mnemonic] {
+		if len(a.lookup(form, false)) == 0 {
+			break
+		}
+		forms = append(forms, form)
+	}
+	return forms
+}
+
+var oppositeBitTest = map[string]string{"JBC": "JBS", "JBS": "JBC"}
+
+// The form a line is assembled in, its own mnemonic until it has been promoted
+func (a *assembler) form(l *line) string {
+	level := a.promoted[l.number]
+	if level == 0 {
+		return l.mnemonic
+	}
+	return promotions[l.mnemonic][level-1]
+}
+
+// Promote moves every branch whose target is out of range for its current form up to
+// the next one, after a first pass has placed the labels. It is true when any moved.
+func (a *assembler) promote() bool {
+	moved := false
+	for i := range a.lines {
+		l := &a.lines[i]
+		longer := a.longer(l.mnemonic)
+		level := a.promoted[l.number]
+		if longer == nil || level == len(longer) || len(l.operands) == 0 {
+			continue
+		}
+
+		target, err := a.value(l.operands[len(l.operands)-1])
+		if err != nil {
+			continue // reported by the final pass
+		}
+
+		var disp, min, max int
+		switch form := a.form(l); form {
+		case "SJMP", "SCALL":
+			disp, min, max = relative(target.value, l.address+2), -1024, 1023
+		case "JBC", "JBS":
+			disp, min, max = relative(target.value, l.address+3), -128, 127
+		case "LJMP", "LCALL":
+			start := l.address
+			if _, ok := oppositeBitTest[l.mnemonic]; ok {
+				start += 3
+			}
+			disp, min, max = relative(target.value, start+3), -32768, 32767
+		}
+
+		if disp < min || disp > max {
+			a.promoted[l.number] = level + 1
+			moved = true
+		}
+	}
+	return moved
+}
+
+// Assembles a promoted branch
+func (a *assembler) encodePromoted(l *line, ops []operand) ([]byte, error) {
+	form := a.form(l)
+	opposite, ok := oppositeBitTest[l.mnemonic]
+	if !ok {
+		return a.encode(form, ops, l.address)
+	}
+	if err := count(l.mnemonic, ops, 3); err != nil {
+		return nil, err
+	}
+
+	jump, err := a.encode(form, ops[2:], l.address+3)
+	if err != nil {
+		return nil, err
+	}
+	over := ops[2]
+	over.val = value{value: l.address + 3 + len(jump)}
+	test, err := a.encode(opposite, []operand{ops[0], ops[1], over}, l.address)
+	if err != nil {
+		return nil, err
+	}
+	return append(test, jump...), nil
+}
+
+// The promotions made, in line order
+func (a *assembler) promotionList() []Promotion {
+	var list []Promotion
+	for i := range a.lines {
+		l := &a.lines[i]
+		if a.promoted[l.number] == 0 {
+			continue
+		}
+		to := a.form(l)
+		if opposite, ok := oppositeBitTest[l.mnemonic]; ok {
+			to = opposite + " over " + to
+		}
+		list = append(list, Promotion{Line: l.number, Address: l.address, From: l.mnemonic, To: to})
+	}
+	return list
+}
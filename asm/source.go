@@ -0,0 +1,218 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+/*
+	Source export. WriteSource writes a whole analysed image out as one source file this
+	assembler builds back into the same bytes: the code as instructions, everything else
+	as DCB rows, an ORG wherever a run of erased (0xFF) bytes is left out, a label at each
+	address the analysis names that starts a line, and an EQU for the names that don't,
+	RAM, registers and the middle of data. Jumps and calls go to their labels and long
+	offsets that are a named address use the name, so the source can be edited and moved
+	around like any other.
+
+	An instruction that doesn't encode back to its own bytes (a redundant form the
+	assembler never picks, say) is written as DCB with the instruction in a comment.
+	Registers reached through the window are written as the register encoded, with the
+	register they reach in the comment. The source is assembled and compared with the
+	image before anything is written, so what comes out always rebuilds.
+*/
+
+// Erased runs at least this long are left out of the source and filled back in
+const sourceGap = 32
+
+// Bytes in a DCB row
+const sourceRow = 16
+
+// WriteSource writes an analysis of the disassembler's image as assembler source that
+// rebuilds it byte for byte, with the gaps filled with 0xFF
+func WriteSource(h *disasm.DisAsm, an *disasm.Analysis, w io.Writer) error {
+	block := h.Block()
+	src := source(h, an, block)
+	if err := checkSource(src, block); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, src)
+	return err
+}
+
+// Builds the source text
+func source(h *disasm.DisAsm, an *disasm.Analysis, block []byte) string {
+	code := make(disasm.Instructions, len(an.Opcodes))
+	copy(code, an.Opcodes)
+	sort.Sort(code)
+
+	// Where lines start, the instructions and the bytes around them
+	starts := make(map[int]bool)
+	end := 0
+	for _, instr := range code {
+		if instr.Address >= end && instr.Address+instr.ByteLength <= len(block) {
+			starts[instr.Address] = true
+			end = instr.Address + instr.ByteLength
+		}
+	}
+
+	// A label for every named address in ROM that a line can start on, an EQU for the rest
+	memory := h.MemoryMap()
+	names := make(map[int]string)
+	labels := make(map[int]string)
+	used := make(map[string]bool)
+	var equs []disasm.Label
+	for _, l := range h.Labels(an) {
+		if !isSymbol(l.Name) || strings.HasPrefix(strings.ToUpper(l.Name), "R_") || used[l.Name] {
+			continue
+		}
+		used[l.Name] = true
+		names[l.Address] = l.Name
+		kind := memory.Kind(l.Address)
+		if l.Address < len(block) && (kind == disasm.KindROM || kind == disasm.KindExternal) && !inside(code, l.Address) {
+			labels[l.Address] = l.Name
+			starts[l.Address] = true
+		} else {
+			equs = append(equs, l)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "; %d byte image, assemble and fill the gaps with 0xFF to rebuild it\n\n", len(block))
+	for _, l := range equs {
+		fmt.Fprintf(&b, "%s EQU 0x%X\n", l.Name, l.Address)
+	}
+
+	i := 0
+	org := true
+	for pc := 0; pc < len(block); {
+		for i < len(code) && code[i].Address < pc {
+			i++
+		}
+
+		// Erased bytes, left to the fill unless something is named in them
+		if n := erased(block, pc, starts); n >= sourceGap {
+			pc += n
+			org = true
+			continue
+		}
+		if org {
+			fmt.Fprintf(&b, "\n\tORG 0x%X\n", pc)
+			org = false
+		}
+		if name, ok := labels[pc]; ok {
+			fmt.Fprintf(&b, "%s:\n", name)
+		}
+
+		if i < len(code) && code[i].Address == pc && pc+code[i].ByteLength <= len(block) {
+			b.WriteString(sourceLine(code[i], names))
+			pc += code[i].ByteLength
+			continue
+		}
+
+		// Data up to the next line start or erased run
+		row := []string{fmt.Sprintf("0x%02X", block[pc])}
+		for pc++; pc < len(block) && len(row) < sourceRow && !starts[pc] && erased(block, pc, starts) < sourceGap; pc++ {
+			row = append(row, fmt.Sprintf("0x%02X", block[pc]))
+		}
+		fmt.Fprintf(&b, "\tDCB %s\n", strings.Join(row, ", "))
+	}
+	return b.String()
+}
+
+// One instruction as a source line, or as DCB when it doesn't encode back to the same bytes
+func sourceLine(instr disasm.Instruction, names map[int]string) string {
+	numeric := make([]string, len(instr.Ops))
+	named := make([]string, len(instr.Ops))
+	windowed := false
+	for i, op := range instr.Ops {
+		switch o := op.(type) {
+		case disasm.RegisterOperand:
+			windowed = windowed || o.Window != 0
+			o.Window = 0
+			op = o
+		case disasm.IndirectOperand:
+			windowed = windowed || o.Window != 0
+			o.Window = 0
+			op = o
+		case disasm.IndexedOperand:
+			windowed = windowed || o.Window != 0
+			o.Window = 0
+			op = o
+		}
+		numeric[i] = op.String()
+		named[i] = numeric[i]
+
+		switch o := op.(type) {
+		case disasm.AddressOperand:
+			if name, ok := names[o.Address]; ok {
+				named[i] = name
+			}
+		case disasm.IndexedOperand:
+			if name, ok := names[o.Offset]; ok && o.Size == 2 {
+				named[i] = fmt.Sprintf("%s[R_%02X]", name, o.Reg)
+			}
+		}
+	}
+
+	out, err := EncodeAt(instr.Address, instr.Mnemonic, numeric...)
+	if err != nil || !bytes.Equal(out, instr.Raw[:instr.ByteLength]) {
+		row := make([]string, instr.ByteLength)
+		for i := range row {
+			row[i] = fmt.Sprintf("0x%02X", instr.Raw[i])
+		}
+		return fmt.Sprintf("\tDCB %s\t; %s\n", strings.Join(row, ", "), instr)
+	}
+
+	text := "\t" + instr.Mnemonic
+	if len(named) > 0 {
+		text += " " + strings.Join(named, ", ")
+	}
+	if windowed {
+		text += "\t; " + instr.String()
+	}
+	return text + "\n"
+}
+
+// How many erased bytes start at pc, up to the next line start
+func erased(block []byte, pc int, starts map[int]bool) int {
+	n := 0
+	for pc+n < len(block) && block[pc+n] == 0xFF && (n == 0 || !starts[pc+n]) {
+		n++
+	}
+	if starts[pc] {
+		return 0
+	}
+	return n
+}
+
+// Whether adr is in the middle of an instruction
+func inside(code disasm.Instructions, adr int) bool {
+	i := sort.Search(len(code), func(i int) bool { return code[i].Address >= adr })
+	return i > 0 && code[i-1].Address+code[i-1].ByteLength > adr
+}
+
+// Assembles the source and checks it against the image
+func checkSource(src string, block []byte) error {
+	prog, err := Assemble(src, 0)
+	if err != nil {
+		return fmt.Errorf("Source doesn't assemble: %s", err)
+	}
+	out := make([]byte, len(block))
+	for i := range out {
+		out[i] = 0xFF
+	}
+	if err := prog.Apply(out); err != nil {
+		return err
+	}
+	for i := range out {
+		if out[i] != block[i] {
+			return fmt.Errorf("Source doesn't rebuild the image, the first difference is at 0x%X", i)
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"regexp"
 
 	"github.com/kataras/iris"
+	"github.com/murdinc/ELMFlash/crash"
 	"github.com/murdinc/ELMFlash/hexstuff"
 	"github.com/toqueteos/webbrowser"
 )
@@ -97,6 +98,9 @@ func Calibrate(devMode bool) {
 
 	api := iris.New()
 
+	// Crash reports for handler panics, the server keeps running
+	api.UseFunc(recoverPanic)
+
 	// Template Configuration
 	api.Config().Render.Template.Directory = guiLocation
 	api.Config().Render.Template.Layout = "templates/layout.html"
@@ -184,6 +188,16 @@ func GetMaps(block []byte) []int {
 	return addresses
 }
 
+func recoverPanic(ctx *iris.Context) {
+	defer func() {
+		if r := recover(); r != nil {
+			crash.Recovered(r)
+			ctx.SetStatusCode(500)
+		}
+	}()
+	ctx.Next()
+}
+
 func index(ctx *iris.Context) {
 	payload := make(map[string]interface{})
 
@@ -0,0 +1,117 @@
+package comm
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/crash"
+)
+
+/*
+	Bluetooth adapters. Most of the ELM327 clones only do Bluetooth, a serial port over
+	RFCOMM. On Linux DialBluetooth connects to one by its address, 00:1D:A5:68:98:8B say,
+	on RFCOMM channel 1 where the clones all listen (00:1D:A5:68:98:8B/2 for another), with
+	no /dev/rfcomm binding needed; elsewhere, and with one bound, the adapter is the serial
+	port the system made for it, /dev/rfcomm0 or /dev/tty.OBDII, and opened with Open.
+	BluetoothDevices lists the devices the system knows (from bluetoothctl), paired or not,
+	which Discover probes the adapters among.
+
+	An adapter has to be paired before it takes a connection, with a PIN of 1234 or 0000
+	on most. A connection that fails says why in those terms: not paired, off or out of
+	range, or taken by something else. A dropped connection is made again on the next Send,
+	the same as on the network.
+*/
+
+// The RFCOMM channel an adapter listens on unless given
+const defaultChannel = 1
+
+// What the adapters call themselves, for picking them out of the devices around
+var adapterNames = []string{"OBD", "ELM", "V-LINK", "VLINK", "VGATE", "KONNWEI", "VIECAR", "CAN OBD", "OBDLINK"}
+
+var bluetoothAddress = regexp.MustCompile(`^([0-9A-Fa-f]{2}:){5}[0-9A-Fa-f]{2}(/[0-9]+)?$`)
+
+// BluetoothDevice is a Bluetooth device the system knows of
+type BluetoothDevice struct {
+	Address string
+	Name    string
+	Paired  bool
+}
+
+func (b BluetoothDevice) String() string {
+	if b.Paired {
+		return fmt.Sprintf("%s (%s), paired", b.Address, b.Name)
+	}
+	return fmt.Sprintf("%s (%s), not paired", b.Address, b.Name)
+}
+
+// LooksLikeAdapter is whether the device's name is one an OBD adapter goes by
+func (b BluetoothDevice) LooksLikeAdapter() bool {
+	name := strings.ToUpper(b.Name)
+	for _, n := range adapterNames {
+		if strings.Contains(name, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBluetooth is whether location is a Bluetooth address, with or without its channel
+func IsBluetooth(location string) bool {
+	return bluetoothAddress.MatchString(location)
+}
+
+// Splits location into the address and the RFCOMM channel
+func parseBluetooth(location string) (string, int, error) {
+	if !IsBluetooth(location) {
+		return "", 0, fmt.Errorf("Bad Bluetooth address %s, 00:1D:A5:68:98:8B say", location)
+	}
+	address, channel := strings.ToUpper(location), defaultChannel
+	if i := strings.Index(address, "/"); i >= 0 {
+		channel, _ = strconv.Atoi(address[i+1:])
+		address = address[:i]
+	}
+	if channel < 1 || channel > 30 {
+		return "", 0, fmt.Errorf("Bad RFCOMM channel %d, 1 to 30", channel)
+	}
+	return address, channel, nil
+}
+
+// DialBluetooth connects to the adapter at location, a Bluetooth address, over RFCOMM. It
+// still has to be set up with Init.
+func DialBluetooth(location string) (*ELM327, error) {
+	address, channel, err := parseBluetooth(location)
+	if err != nil {
+		return nil, err
+	}
+	port, err := dialRFCOMM(address, channel)
+	if err != nil {
+		return nil, pairingError(address, err)
+	}
+	crash.SetConfig("adapter", location)
+	e := NewELM327(port)
+	e.address = location
+	e.redial = func() (io.ReadWriteCloser, error) { return dialRFCOMM(address, channel) }
+	return e, nil
+}
+
+// Says a connection failed because the device isn't paired, or isn't known at all, where
+// the system says so, err as it was otherwise
+func pairingError(address string, err error) error {
+	devices, lerr := BluetoothDevices()
+	if lerr != nil {
+		return err
+	}
+	for _, b := range devices {
+		if b.Address != address {
+			continue
+		}
+		if !b.Paired {
+			return fmt.Errorf("%s (%s) isn't paired, pair it first (bluetoothctl pair %s, PIN 1234 or 0000 on most adapters): %s", b.Address, b.Name, b.Address, err)
+		}
+		return err
+	}
+	return fmt.Errorf("%s isn't a device this system knows, scan for it and pair it first (bluetoothctl scan on): %s", address, err)
+}
@@ -0,0 +1,106 @@
+//go:build linux && !386
+// +build linux,!386
+
+package comm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Bluetooth's address family and RFCOMM's protocol, which syscall doesn't have
+const afBluetooth = 31
+const btprotoRFCOMM = 3
+
+// struct sockaddr_rc, the address with its bytes the other way round
+type sockaddrRC struct {
+	family  uint16
+	bdaddr  [6]byte
+	channel uint8
+	_       uint8
+}
+
+// Connects an RFCOMM socket to channel of the device at address
+func dialRFCOMM(address string, channel int) (io.ReadWriteCloser, error) {
+	sa := sockaddrRC{family: afBluetooth, channel: uint8(channel)}
+	for i, b := range strings.Split(address, ":") {
+		fmt.Sscanf(b, "%02X", &sa.bdaddr[5-i])
+	}
+
+	fd, err := syscall.Socket(afBluetooth, syscall.SOCK_STREAM, btprotoRFCOMM)
+	if err != nil {
+		if err == syscall.EAFNOSUPPORT || err == syscall.EPROTONOSUPPORT {
+			return nil, errors.New("No Bluetooth on this system, or its kernel has no RFCOMM")
+		}
+		return nil, err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_CONNECT, uintptr(fd), uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa))
+	if errno != 0 {
+		syscall.Close(fd)
+		return nil, connectError(address, channel, errno)
+	}
+
+	// Non-blocking so a Close gets the reads going in the background out
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "rfcomm:"+address), nil
+}
+
+// Why a connection failed, in terms of the adapter
+func connectError(address string, channel int, errno syscall.Errno) error {
+	switch errno {
+	case syscall.EHOSTDOWN, syscall.EHOSTUNREACH, syscall.ETIMEDOUT:
+		return fmt.Errorf("%s isn't answering, is the adapter in the OBD-II port with the ignition on, and in range? (%s)", address, errno)
+	case syscall.ECONNREFUSED:
+		return fmt.Errorf("%s has nothing on RFCOMM channel %d (%s)", address, channel, errno)
+	case syscall.EACCES, syscall.EPERM:
+		return fmt.Errorf("%s turned the connection down, it isn't paired or the pairing has gone stale, pair it again (%s)", address, errno)
+	case syscall.EBUSY, syscall.EALREADY:
+		return fmt.Errorf("%s is connected to something else, another app or a /dev/rfcomm binding (%s)", address, errno)
+	}
+	return errno
+}
+
+// BluetoothDevices lists the devices the system knows of and whether each is paired
+func BluetoothDevices() ([]BluetoothDevice, error) {
+	out, err := exec.Command("bluetoothctl", "devices").Output()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the Bluetooth devices with bluetoothctl: %s", err)
+	}
+	devices := parseDevices(string(out))
+
+	// Newer bluetoothctl takes a filter, older has a command of its own
+	out, err = exec.Command("bluetoothctl", "devices", "Paired").Output()
+	if err != nil || len(parseDevices(string(out))) == 0 {
+		out, _ = exec.Command("bluetoothctl", "paired-devices").Output()
+	}
+	for _, p := range parseDevices(string(out)) {
+		for i := range devices {
+			if devices[i].Address == p.Address {
+				devices[i].Paired = true
+			}
+		}
+	}
+	return devices, nil
+}
+
+// Reads bluetoothctl's list of devices, "Device 00:1D:A5:68:98:8B OBDII" a line
+func parseDevices(out string) []BluetoothDevice {
+	var devices []BluetoothDevice
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "Device" || !IsBluetooth(fields[1]) {
+			continue
+		}
+		devices = append(devices, BluetoothDevice{Address: strings.ToUpper(fields[1]), Name: strings.Join(fields[2:], " ")})
+	}
+	return devices
+}
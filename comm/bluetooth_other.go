@@ -0,0 +1,20 @@
+//go:build !linux || 386
+// +build !linux 386
+
+package comm
+
+import (
+	"errors"
+	"io"
+)
+
+// Connecting to an RFCOMM socket is only done on Linux, elsewhere the system makes a serial
+// port for a paired adapter
+func dialRFCOMM(address string, channel int) (io.ReadWriteCloser, error) {
+	return nil, errors.New("Bluetooth adapters are opened by their serial port on this system, /dev/tty.OBDII or a COM port say, once paired in the system's Bluetooth settings")
+}
+
+// BluetoothDevices lists the devices the system knows of and whether each is paired
+func BluetoothDevices() ([]BluetoothDevice, error) {
+	return nil, errors.New("Listing the Bluetooth devices is only done on Linux, pair the adapter in the system's Bluetooth settings and use its serial port")
+}
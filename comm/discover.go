@@ -0,0 +1,126 @@
+package comm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+	Adapter discovery. SerialPorts lists the ports an adapter can be on, the USB and
+	Bluetooth serial devices in /dev (COM ports on Windows), and Probe opens one at a baud
+	rate and asks it what it is (ATI). An ELM327 or a clone answers with its firmware,
+	"ELM327 v1.5" say, and an STN adapter gives its chip as well; anything else, or
+	nothing before the probe timeout, isn't one. Discover tries every port at each of
+	ProbeBauds, the rates adapters ship at, until one answers, so nobody has to know which
+	/dev/tty and baud their adapter is on. After the serial ports come the paired Bluetooth
+	devices with an adapter's name, connected to over RFCOMM.
+
+	Probing writes ATI to whatever is on the port, don't point it at ports with other
+	hardware on them that minds.
+*/
+
+// ProbeBauds are the baud rates Discover tries, the usual ELM327 rates first
+var ProbeBauds = []int{38400, 115200, 9600, 230400, 500000}
+
+// How long a probe waits for an answer
+const probeTimeout = time.Second
+
+// Names in /dev of the serial ports an adapter shows up as
+var serialPrefixes = []string{"ttyUSB", "ttyACM", "rfcomm", "tty.usbserial", "cu.usbserial", "tty.usbmodem", "cu.usbmodem", "tty.SLAB", "tty.wchusbserial", "tty.OBD", "tty.STY3M"}
+
+// Adapter is an ELM-compatible adapter found on a serial port or over Bluetooth
+type Adapter struct {
+	Port     string // the serial port or Bluetooth address
+	Baud     int    // 0 over Bluetooth
+	Firmware string // its answer to ATI, and STI on an STN
+}
+
+func (a Adapter) String() string {
+	if a.Baud == 0 {
+		return fmt.Sprintf("%s over Bluetooth, %s", a.Port, a.Firmware)
+	}
+	return fmt.Sprintf("%s at %d baud, %s", a.Port, a.Baud, a.Firmware)
+}
+
+// SerialPorts lists the serial ports an adapter could be on, by name
+func SerialPorts() []string {
+	var ports []string
+	if runtime.GOOS == "windows" {
+		for i := 1; i <= 32; i++ {
+			ports = append(ports, fmt.Sprintf("COM%d", i))
+		}
+		return ports
+	}
+
+	contents, _ := ioutil.ReadDir("/dev")
+	for _, f := range contents {
+		for _, prefix := range serialPrefixes {
+			if strings.HasPrefix(f.Name(), prefix) {
+				ports = append(ports, "/dev/"+f.Name())
+				break
+			}
+		}
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+// Probe opens port at baud, or connects to it if it's a Bluetooth address, and asks what's
+// there, an error if it isn't an ELM-compatible adapter
+func Probe(port string, baud int) (Adapter, error) {
+	e, err := Connect(port, baud)
+	if err != nil {
+		return Adapter{}, err
+	}
+	defer e.Close()
+	e.SetTimeout(probeTimeout)
+
+	// Anything half typed before is ended first, the answer to that doesn't matter
+	Command(e, "")
+	lines, err := Command(e, "ATI")
+	if err != nil {
+		return Adapter{}, err
+	}
+	for _, line := range lines {
+		if strings.Contains(strings.ToUpper(line), "ELM") {
+			a := Adapter{Port: port, Baud: baud, Firmware: line}
+			if e.detectSTN(); e.STN != nil {
+				a.Firmware += ", " + e.STN.String()
+			}
+			return a, nil
+		}
+	}
+	if IsBluetooth(port) {
+		return Adapter{}, fmt.Errorf("No ELM327 at %s, it answered %q", port, strings.Join(lines, " "))
+	}
+	return Adapter{}, fmt.Errorf("No ELM327 on %s at %d baud, it answered %q", port, baud, strings.Join(lines, " "))
+}
+
+// Discover probes every serial port at each of ProbeBauds, then the paired Bluetooth
+// devices named like adapters, and returns the adapters that answered, one for each port
+func Discover() []Adapter {
+	var found []Adapter
+	for _, port := range SerialPorts() {
+		for _, baud := range ProbeBauds {
+			if a, err := Probe(port, baud); err == nil {
+				found = append(found, a)
+				break
+			}
+		}
+	}
+
+	devices, _ := BluetoothDevices()
+	for _, b := range devices {
+		if !b.Paired || !b.LooksLikeAdapter() {
+			continue
+		}
+		if a, err := Probe(b.Address, 0); err == nil {
+			found = append(found, a)
+		}
+	}
+	return found
+}
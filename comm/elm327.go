@@ -0,0 +1,405 @@
+package comm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	serial "github.com/huin/goserial"
+	"github.com/murdinc/ELMFlash/crash"
+)
+
+/*
+	ELM327. Init resets the adapter (ATZ) and sets it up the way the rest of the app reads
+	it: no echo (ATE0), no line feeds (ATL0), headers on (ATH1) and no spaces (ATS0), so a
+	frame comes back as one run of hex digits a line. What the adapter sends is read in the
+	background, so Receive can give up on a response after the timeout whatever the port
+	is; a late one is thrown away when the next command is sent. Every line sent and
+	received goes into the crash transcript.
+
+	On a serial port SwitchBaud moves the link to a faster rate with the adapter's baud
+	rate divisor handshake: AT BRD with 4MHz over the rate, an OK, and the port reopened at
+	the new rate, where the adapter sends its ID and keeps the rate if a carriage return
+	comes back in time. If it doesn't the adapter drops back to the old rate by itself and
+	so does the port. SpeedUp tries FastBauds in turn, a 480KB image is minutes at 38400
+	and seconds at 500000.
+*/
+
+// FastBauds are the rates SpeedUp tries, fastest first
+var FastBauds = []int{500000, 115200}
+
+// The adapter's baud rate generator, AT BRD takes it over the rate
+const brdClock = 4000000
+
+// How long the adapter waits for the carriage return at a new rate, in its units of 5ms
+// (AT BRT), and how long we wait for its ID
+const brdWindow = 0x50
+const brdTimeout = 500 * time.Millisecond
+
+// ELM327 is an ELM327, or a clone, on a serial port, the network or Bluetooth
+type ELM327 struct {
+	Version      string // what the adapter says it is after a reset, "ELM327 v1.5" say
+	STN          *STN   // an STN11xx adapter's ID, nil on an ELM327
+	port         io.ReadWriteCloser
+	location     string // the serial port, "" on the network
+	baud         int
+	address      string                             // host and port, or Bluetooth address
+	redial       func() (io.ReadWriteCloser, error) // connects again after a drop
+	reconnecting bool
+	setup        []string // as given to Init, sent again after reconnecting
+	filter       []byte   // target and source given to ReceiveFrom, set again after reconnecting
+	chunks       chan chunk
+	buf          string // read and not handed back yet
+	last         string // the command the next response is to
+	timeout      time.Duration
+}
+
+// How many times a dropped connection is dialed again, and how long between
+const reconnectTries = 3
+const reconnectDelay = 2 * time.Second
+
+// What one read of the port got
+type chunk struct {
+	data string
+	err  error
+}
+
+// Open opens the adapter on the serial port at location. It still has to be set up with
+// Init.
+func Open(location string, baud int) (*ELM327, error) {
+	port, err := serial.OpenPort(&serial.Config{Name: location, Baud: baud})
+	if err != nil {
+		return nil, err
+	}
+	crash.SetConfig("adapter", location)
+	crash.SetConfig("baud", fmt.Sprintf("%d", baud))
+	e := NewELM327(port)
+	e.location, e.baud = location, baud
+	return e, nil
+}
+
+// NewELM327 talks to an adapter over port, a serial port or connection already open or
+// anything that answers like one
+func NewELM327(port io.ReadWriteCloser) *ELM327 {
+	e := &ELM327{timeout: DefaultTimeout}
+	e.attach(port)
+	return e
+}
+
+// Starts reading port in the background
+func (e *ELM327) attach(port io.ReadWriteCloser) {
+	e.port = port
+	e.chunks = make(chan chunk, 16)
+	e.buf = ""
+	go read(port, e.chunks)
+}
+
+// Reads port into chunks until it's closed
+func read(port io.Reader, chunks chan<- chunk) {
+	b := make([]byte, 256)
+	for {
+		n, err := port.Read(b)
+		if n > 0 {
+			chunks <- chunk{data: string(b[:n])}
+		}
+		if err != nil {
+			chunks <- chunk{err: err}
+			close(chunks)
+			return
+		}
+	}
+}
+
+// Reads until what's come in holds until, returning it up to there, or gives up after
+// timeout (0 never does)
+func (e *ELM327) readUntil(until string, timeout time.Duration) (string, error) {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+	for {
+		if i := strings.Index(e.buf, until); i >= 0 {
+			out := e.buf[:i+len(until)]
+			e.buf = e.buf[i+len(until):]
+			return out, nil
+		}
+		select {
+		case c, ok := <-e.chunks:
+			if !ok {
+				return "", errors.New("Adapter connection closed")
+			}
+			if c.err != nil {
+				return "", c.err
+			}
+			e.buf += c.data
+		case <-expired:
+			return "", ErrTimeout
+		}
+	}
+}
+
+// Init resets the adapter and sets it up to answer one frame a line, in hex without
+// spaces and with headers, finds out if it's an STN, then sends the commands in setup, the
+// protocol and timing for the bus the caller is on
+func (e *ELM327) Init(setup ...string) error {
+	e.setup = setup
+	lines, err := Command(e, "ATZ")
+	if err != nil {
+		return err
+	}
+	if len(lines) > 0 {
+		e.Version = lines[len(lines)-1]
+		crash.SetConfig("adapter version", e.Version)
+	}
+
+	for _, cmd := range []string{"ATE0", "ATL0", "ATH1", "ATS0"} {
+		if _, err := Command(e, cmd); err != nil {
+			return err
+		}
+	}
+	e.detectSTN()
+	for _, cmd := range setup {
+		if _, err := Command(e, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send sends cmd, dropping whatever came in since the last response, a response to an
+// earlier command that came too late. On the network a dropped connection is made again
+// first, and over Bluetooth.
+func (e *ELM327) Send(cmd string) error {
+	if e.port == nil {
+		return errors.New("Adapter isn't open")
+	}
+	err := e.send(cmd)
+	if err != nil && e.redial != nil && !e.reconnecting {
+		if rerr := e.reconnect(); rerr != nil {
+			return fmt.Errorf("%s, and reconnecting: %s", err, rerr)
+		}
+		err = e.send(cmd)
+	}
+	return err
+}
+
+func (e *ELM327) send(cmd string) error {
+	for drained := false; !drained; {
+		select {
+		case c, ok := <-e.chunks:
+			if !ok {
+				return errors.New("Adapter connection closed")
+			}
+			if c.err != nil {
+				return c.err
+			}
+			e.buf += c.data
+		default:
+			drained = true
+		}
+	}
+	if e.buf != "" {
+		crash.Record("<", strings.Trim(e.buf, "\r\n>"))
+		e.buf = ""
+	}
+
+	crash.Record(">", cmd)
+	e.last = cmd
+	_, err := io.WriteString(e.port, cmd+"\r")
+	return err
+}
+
+// Receive reads the response to the last command sent, up to the prompt
+func (e *ELM327) Receive() ([]string, error) {
+	raw, err := e.readUntil(string(prompt), e.timeout)
+	if err != nil {
+		return nil, err
+	}
+	crash.Record("<", strings.Trim(raw, "\r\n>"))
+	return frame(e.last, raw)
+}
+
+// SetTimeout sets how long Receive waits for a response, 0 for as long as it takes
+func (e *ELM327) SetTimeout(d time.Duration) error {
+	if d < 0 {
+		return fmt.Errorf("Bad timeout %s", d)
+	}
+	e.timeout = d
+	return nil
+}
+
+// Baud is the rate of the serial port, 0 on the network
+func (e *ELM327) Baud() int {
+	return e.baud
+}
+
+// SwitchBaud moves the adapter and the serial port to baud, leaving both at the rate they
+// were at if the adapter doesn't come up at the new one. An STN adapter switches with
+// STBR, any rate its UART makes, an ELM327 with AT BRD.
+func (e *ELM327) SwitchBaud(baud int) error {
+	if e.location == "" {
+		return errors.New("Only an adapter on a serial port has a baud rate")
+	}
+	if baud == e.baud {
+		return nil
+	}
+
+	var cmd string
+	if e.STN != nil {
+		cmd = fmt.Sprintf("STBR %d", baud)
+	} else {
+		divisor := (brdClock + baud/2) / baud
+		if divisor < 8 || divisor > 0xFF {
+			return fmt.Errorf("The adapter can't run at %d baud", baud)
+		}
+
+		// A longer wait for the carriage return than the adapter's default, reopening the
+		// port takes a while; an adapter without AT BRT waits its own time
+		Command(e, fmt.Sprintf("AT BRT %02X", brdWindow))
+		cmd = fmt.Sprintf("AT BRD %02X", divisor)
+	}
+	if err := e.Send(cmd); err != nil {
+		return err
+	}
+
+	// OK at the old rate and no prompt after it, it's switching; "?" from an adapter that
+	// can't
+	for {
+		line, err := e.readUntil("\r", e.timeout)
+		if err != nil {
+			return err
+		}
+		crash.Record("<", strings.Trim(line, "\r\n>"))
+		line = strings.Trim(line, "\r\n> ")
+		if line == "OK" {
+			break
+		}
+		if line != "" {
+			e.readUntil(string(prompt), brdTimeout)
+			kind := classify(line)
+			if kind == "" {
+				kind = UnknownCommand
+			}
+			return &Error{Kind: kind, Command: cmd, Response: line}
+		}
+	}
+
+	old := e.baud
+	if err := e.reopen(baud); err != nil {
+		if rerr := e.reopen(old); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	if e.confirmBaud() {
+		crash.SetConfig("baud", fmt.Sprintf("%d", baud))
+		return nil
+	}
+
+	// The adapter went back on its own, it says so with a prompt
+	if rerr := e.reopen(old); rerr != nil {
+		return rerr
+	}
+	e.readUntil(string(prompt), brdTimeout)
+	return fmt.Errorf("Adapter didn't come up at %d baud, back at %d", baud, old)
+}
+
+// Answers the adapter at the new rate so it stays there. An ELM327 sends its ID first and
+// waits for a carriage return, an STN waits for the carriage return and answers it with
+// its ID.
+func (e *ELM327) confirmBaud() bool {
+	if e.STN == nil {
+		id, err := e.readUntil("\r", brdTimeout)
+		if err != nil || !strings.Contains(strings.ToUpper(id), "ELM") {
+			return false
+		}
+		crash.Record("<", strings.TrimSpace(id))
+	}
+	if _, err := io.WriteString(e.port, "\r"); err != nil {
+		return false
+	}
+	reply, err := e.readUntil(string(prompt), brdTimeout)
+	if err != nil {
+		return false
+	}
+	crash.Record("<", strings.Trim(reply, "\r\n>"))
+	return e.STN == nil || strings.Contains(strings.ToUpper(reply), "STN")
+}
+
+// SpeedUp switches to the fastest of FastBauds (STNFastBauds on an STN adapter) the
+// adapter and port manage, returning the rate the link is at, with why the last one tried
+// didn't work if it's still at the rate it was
+func (e *ELM327) SpeedUp() (int, error) {
+	bauds := FastBauds
+	if e.STN != nil {
+		bauds = STNFastBauds
+	}
+	var err error
+	for _, baud := range bauds {
+		if baud <= e.baud {
+			break
+		}
+		if err = e.SwitchBaud(baud); err == nil {
+			break
+		}
+	}
+	return e.baud, err
+}
+
+// Closes the serial port and opens it again at baud
+func (e *ELM327) reopen(baud int) error {
+	if e.port != nil {
+		e.port.Close()
+	}
+	port, err := serial.OpenPort(&serial.Config{Name: e.location, Baud: baud})
+	if err != nil {
+		e.port = nil
+		return err
+	}
+	e.attach(port)
+	e.baud = baud
+	return nil
+}
+
+// Connects again and sets the adapter up the way it was
+func (e *ELM327) reconnect() error {
+	e.reconnecting = true
+	defer func() { e.reconnecting = false }()
+	e.port.Close()
+
+	var port io.ReadWriteCloser
+	var err error
+	for try := 0; try < reconnectTries; try++ {
+		if try > 0 {
+			time.Sleep(reconnectDelay)
+		}
+		if port, err = e.redial(); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	crash.Record("-", "reconnected to "+e.address)
+	e.attach(port)
+
+	if err := e.Init(e.setup...); err != nil {
+		return err
+	}
+	if e.filter != nil {
+		return e.ReceiveFrom(e.filter[0], e.filter[1])
+	}
+	return nil
+}
+
+// Close closes the port
+func (e *ELM327) Close() error {
+	if e.port == nil {
+		return nil
+	}
+	err := e.port.Close()
+	e.port = nil
+	return err
+}
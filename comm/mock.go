@@ -0,0 +1,102 @@
+package comm
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Mock is a transport in memory that answers commands from a script, for running the
+// flashing and diagnostic code without an adapter. A command that isn't scripted gets the
+// adapter's "?", and AT commands that aren't get "OK" (ATZ the version) the way an adapter
+// would.
+//
+//	m := comm.NewMock()
+//	m.Reply("10", "4510F5500001FF")
+//	m.Fail("0902", comm.NoData)
+//	d := iso9141.Attach(m)
+type Mock struct {
+	Sent    []string      // every command sent, in order
+	Timeout time.Duration // as last set
+	replies map[string][]mockReply
+	last    string
+	pending bool
+	closed  bool
+}
+
+// One response, lines of data or an error
+type mockReply struct {
+	lines []string
+	err   error
+}
+
+// NewMock returns a mock with nothing scripted
+func NewMock() *Mock {
+	return &Mock{replies: make(map[string][]mockReply), Timeout: DefaultTimeout}
+}
+
+// Reply scripts the next response to cmd, the lines of data given. Responses to the same
+// command are given back in the order they were scripted, the last one over and over.
+func (m *Mock) Reply(cmd string, lines ...string) {
+	m.replies[cmd] = append(m.replies[cmd], mockReply{lines: lines})
+}
+
+// Fail scripts the next response to cmd as the adapter's error response of kind
+func (m *Mock) Fail(cmd string, kind ErrorKind) {
+	m.replies[cmd] = append(m.replies[cmd], mockReply{err: &Error{Kind: kind, Command: cmd, Response: string(kind)}})
+}
+
+// Silent scripts the next response to cmd as none at all, Receive times out
+func (m *Mock) Silent(cmd string) {
+	m.replies[cmd] = append(m.replies[cmd], mockReply{err: ErrTimeout})
+}
+
+// Send records cmd
+func (m *Mock) Send(cmd string) error {
+	if m.closed {
+		return errors.New("Adapter isn't open")
+	}
+	m.Sent = append(m.Sent, cmd)
+	m.last = cmd
+	m.pending = true
+	return nil
+}
+
+// Receive gives back the scripted response to the last command sent
+func (m *Mock) Receive() ([]string, error) {
+	if m.closed {
+		return nil, errors.New("Adapter isn't open")
+	}
+	if !m.pending {
+		return nil, ErrTimeout
+	}
+	m.pending = false
+
+	replies := m.replies[m.last]
+	if len(replies) == 0 {
+		switch at := strings.ToUpper(strings.Replace(m.last, " ", "", -1)); {
+		case at == "ATZ":
+			return []string{"ELM327 v1.5"}, nil
+		case strings.HasPrefix(at, "AT"):
+			return []string{"OK"}, nil
+		}
+		return nil, &Error{Kind: UnknownCommand, Command: m.last, Response: "?"}
+	}
+	r := replies[0]
+	if len(replies) > 1 {
+		m.replies[m.last] = replies[1:]
+	}
+	return r.lines, r.err
+}
+
+// SetTimeout records d
+func (m *Mock) SetTimeout(d time.Duration) error {
+	m.Timeout = d
+	return nil
+}
+
+// Close closes the mock, nothing can be sent after
+func (m *Mock) Close() error {
+	m.closed = true
+	return nil
+}
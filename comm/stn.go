@@ -0,0 +1,113 @@
+package comm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/murdinc/ELMFlash/crash"
+)
+
+/*
+	STN adapters. The OBDLinks and the other adapters built on ScanTool's STN11xx chips
+	take the ELM327's AT commands and their own ST commands besides, which Init finds out
+	by asking for the chip's ID (STI, "?" from an ELM327). Where an adapter has them the
+	ST commands are used: STBR switches the baud rate to anything the UART makes, up to
+	2Mbaud, where AT BRD only manages the divisors of 4MHz; STFAP filters what the adapter
+	passes on by header bits, so a memory read only sees the ECU's answers to the tester;
+	STM monitors the bus with those filters. On an ELM327 the same calls fall back to AT
+	BRD, AT SR (only frames to the tester) and AT MA.
+*/
+
+// STNFastBauds are the rates SpeedUp tries on an STN adapter, fastest first
+var STNFastBauds = []int{2000000, 1000000, 500000, 115200}
+
+// How long Monitor waits between checks for being stopped
+const monitorPoll = 100 * time.Millisecond
+
+// STN is the ID of an STN11xx adapter
+type STN struct {
+	Chip   string // the chip and firmware, "STN1110 v4.2.0" say (STI)
+	Device string // the adapter, "OBDLink SX r4.2" say (STDI)
+}
+
+func (s STN) String() string {
+	if s.Device == "" {
+		return s.Chip
+	}
+	return s.Device + ", " + s.Chip
+}
+
+// Asks for an STN's ID, leaving STN nil on an ELM327
+func (e *ELM327) detectSTN() {
+	e.STN = nil
+	chip, err := Command(e, "STI")
+	if err != nil || len(chip) == 0 || !strings.HasPrefix(strings.ToUpper(chip[0]), "STN") {
+		return
+	}
+	e.STN = &STN{Chip: chip[0]}
+	if device, err := Command(e, "STDI"); err == nil && len(device) > 0 {
+		e.STN.Device = device[0]
+	}
+	crash.SetConfig("adapter chip", e.STN.String())
+}
+
+// ReceiveFrom passes on only the frames from source to target, by their header bytes (an
+// ECU's answers to the tester, 0x10 to 0xF5, for a memory read), clearing any filters set
+// before
+func (e *ELM327) ReceiveFrom(target, source byte) error {
+	e.filter = []byte{target, source}
+	if e.STN == nil {
+		_, err := Command(e, fmt.Sprintf("AT SR %02X", target))
+		return err
+	}
+
+	// The header's first byte is its priority and length, anything goes
+	if _, err := Command(e, "STFCP"); err != nil {
+		return err
+	}
+	_, err := Command(e, fmt.Sprintf("STFAP 00%02X%02X, 00FFFF", target, source))
+	return err
+}
+
+// Monitor passes the frames on the bus to fn, one a line in hex, until stop is closed.
+// An STN adapter monitors with STM, through the filters set with ReceiveFrom, an ELM327
+// with AT MA.
+func (e *ELM327) Monitor(stop <-chan struct{}, fn func(frame string)) error {
+	cmd := "AT MA"
+	if e.STN != nil {
+		cmd = "STM"
+	}
+	if err := e.Send(cmd); err != nil {
+		return err
+	}
+
+	for {
+		line, err := e.readUntil("\r", monitorPoll)
+		switch {
+		case err == ErrTimeout:
+		case err != nil:
+			return err
+		default:
+			if line = strings.Trim(line, "\r\n> "); line != "" {
+				if kind := classify(line); kind != "" {
+					return &Error{Kind: kind, Command: cmd, Response: line}
+				}
+				fn(line)
+			}
+		}
+
+		select {
+		case <-stop:
+			// Any character stops it, it answers with a prompt
+			if _, err := io.WriteString(e.port, "\r"); err != nil {
+				return err
+			}
+			_, err := e.readUntil(string(prompt), e.timeout)
+			e.buf = ""
+			return err
+		default:
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package comm
+
+import (
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/murdinc/ELMFlash/crash"
+)
+
+/*
+	WiFi adapters. The cheap WiFi ELM327 clones are an access point with the adapter
+	listening on a TCP port, 192.168.0.10:35000 on most of them, and talk to it the way a
+	serial one does, so Dial gives the same ELM327 with the connection for its port. TCP
+	keepalives are sent while it's quiet, the dongles drop a connection that has been idle a
+	while, and a connection that drops anyway (the car's ignition cycled, the laptop roamed
+	off the access point) is made again on the next Send: dialed a few times, the adapter
+	set up again with what was given to Init and ReceiveFrom, and the command sent. A
+	response that was coming when it dropped is lost, Receive returns the error.
+*/
+
+// How often keepalives go out on a quiet connection
+const keepAlivePeriod = 30 * time.Second
+
+// Dial connects to an adapter on the network at address, host and port. It still has to
+// be set up with Init.
+func Dial(address string) (*ELM327, error) {
+	conn, err := dialTCP(address)
+	if err != nil {
+		return nil, err
+	}
+	crash.SetConfig("adapter", address)
+	e := NewELM327(conn)
+	e.address = address
+	e.redial = func() (io.ReadWriteCloser, error) { return dialTCP(address) }
+	return e, nil
+}
+
+// Connect opens the adapter at location, dialing it if it's a host and port or a
+// Bluetooth address and opening the serial port at baud otherwise
+func Connect(location string, baud int) (*ELM327, error) {
+	switch {
+	case IsNetwork(location):
+		return Dial(location)
+	case IsBluetooth(location):
+		return DialBluetooth(location)
+	}
+	return Open(location, baud)
+}
+
+// IsNetwork is whether location is a host and port rather than a serial port
+func IsNetwork(location string) bool {
+	if strings.HasPrefix(location, "/") || strings.HasPrefix(strings.ToUpper(location), "COM") {
+		return false
+	}
+	_, port, err := net.SplitHostPort(location)
+	return err == nil && port != ""
+}
+
+func dialTCP(address string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", address, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetKeepAlive(true)
+		tcp.SetKeepAlivePeriod(keepAlivePeriod)
+	}
+	return conn, nil
+}
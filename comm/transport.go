@@ -0,0 +1,149 @@
+package comm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+	Transports. The flashing and diagnostic code speaks to the bus through Transport, one
+	command out and its response back, the way an ELM327 works: a line sent, and lines
+	read back up to the '>' prompt. ELM327 is the adapter on a serial port (Open) or on the
+	network (Dial, the WiFi clones listen on 192.168.0.10:35000, Connect takes either), Mock is one in memory
+	that answers from a script, so those layers run without hardware.
+
+	A response is the lines of data, with the echo, blank lines and the adapter's progress
+	notes (SEARCHING..., BUS INIT: ...OK) stripped. A response the adapter sends in place of
+	data is an *Error, with its Kind saying which: NO DATA, BUS ERROR, CAN ERROR and the
+	rest of the ELM327's messages, and "?" for a command it didn't take. A response that
+	doesn't come within the timeout is ErrTimeout.
+*/
+
+// Transport is a link to the bus through an adapter
+type Transport interface {
+	Send(cmd string) error            // sends one command
+	Receive() ([]string, error)       // reads the response to the last command sent
+	SetTimeout(d time.Duration) error // how long Receive waits, 0 for as long as it takes
+	Close() error
+}
+
+// DefaultTimeout is how long a transport waits for a response unless set otherwise, long
+// enough for an adapter reset or a bus search
+const DefaultTimeout = 10 * time.Second
+
+// ErrTimeout is returned by Receive when no response came in time
+var ErrTimeout = errors.New("Adapter didn't answer in time")
+
+// Command sends cmd over t and returns the response
+func Command(t Transport, cmd string) ([]string, error) {
+	if err := t.Send(cmd); err != nil {
+		return nil, err
+	}
+	return t.Receive()
+}
+
+// The adapter's prompt, it's ready for the next command
+const prompt = '>'
+
+// ErrorKind is which of the ELM327's error responses came back
+type ErrorKind string
+
+const (
+	NoData          ErrorKind = "NO DATA"
+	BusError        ErrorKind = "BUS ERROR"
+	CANError        ErrorKind = "CAN ERROR"
+	BusBusy         ErrorKind = "BUS BUSY"
+	BusInitError    ErrorKind = "BUS INIT ERROR"
+	BufferFull      ErrorKind = "BUFFER FULL"
+	DataError       ErrorKind = "DATA ERROR"
+	FeedbackError   ErrorKind = "FB ERROR"
+	ReceiveError    ErrorKind = "RX ERROR"
+	Stopped         ErrorKind = "STOPPED"
+	UnableToConnect ErrorKind = "UNABLE TO CONNECT"
+	UnknownCommand  ErrorKind = "?"
+	OtherError      ErrorKind = "ERROR"
+)
+
+// The error responses by what they start with, longest first so BUS INIT: ...ERROR isn't
+// taken for something shorter
+var errorResponses = []struct {
+	prefix string
+	kind   ErrorKind
+}{
+	{"UNABLE TO CONNECT", UnableToConnect},
+	{"BUS INIT", BusInitError},
+	{"BUFFER FULL", BufferFull},
+	{"<DATA ERROR", DataError},
+	{"DATA ERROR", DataError},
+	{"BUS ERROR", BusError},
+	{"CAN ERROR", CANError},
+	{"BUS BUSY", BusBusy},
+	{"<RX ERROR", ReceiveError},
+	{"FB ERROR", FeedbackError},
+	{"NO DATA", NoData},
+	{"STOPPED", Stopped},
+	{"?", UnknownCommand},
+}
+
+// Error is a response from the adapter that isn't data
+type Error struct {
+	Kind     ErrorKind
+	Command  string
+	Response string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s - command: [%s] response: [%s]", e.Kind, e.Command, e.Response)
+}
+
+// IsKind is whether err is an adapter error of kind
+func IsKind(err error, kind ErrorKind) bool {
+	e, ok := err.(*Error)
+	return ok && e.Kind == kind
+}
+
+// Classifies a line of a response, "" if it's data
+func classify(line string) ErrorKind {
+	for _, r := range errorResponses {
+		if strings.HasPrefix(line, r.prefix) {
+			if r.kind == BusInitError && !strings.HasSuffix(line, "ERROR") {
+				return "" // BUS INIT: ...OK, the bus came up
+			}
+			return r.kind
+		}
+	}
+	if strings.HasSuffix(line, "ERROR") {
+		return OtherError
+	}
+	return ""
+}
+
+// Splits a response into its lines of data, dropping the echo of cmd, blank lines and
+// the adapter's progress notes, and turns an error response into an *Error with any lines
+// of data that came before it
+func frame(cmd, raw string) ([]string, error) {
+	var lines []string
+	for _, line := range strings.FieldsFunc(raw, func(r rune) bool { return r == '\r' || r == '\n' || r == prompt }) {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", line == cmd, strings.HasPrefix(line, "SEARCHING"):
+			continue
+		}
+
+		// A frame the adapter got with a bad checksum comes with the note on its end
+		if i := strings.Index(line, "<"); i > 0 {
+			lines = append(lines, line[:i])
+			line = line[i:]
+		}
+		if kind := classify(line); kind != "" {
+			return lines, &Error{Kind: kind, Command: cmd, Response: line}
+		}
+		if strings.HasPrefix(line, "BUS INIT") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
@@ -0,0 +1,233 @@
+package compare
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+/*
+	Differential disassembly. Functions are lined up by fingerprint rather than by address,
+	since a change early in the image moves everything after it. Functions that didn't
+	match but sit between the same two matched neighbours in both images are taken to be
+	the same function changed, and their instructions are lined up with a longest common
+	subsequence. Jump and call targets are left out of the comparison, they move with the
+	code. Everything else that isn't code in either image is compared byte for byte.
+*/
+
+// Report is everything that differs between two images
+type Report struct {
+	Matched   int            // functions found unchanged in shape
+	Functions []FunctionDiff // functions whose instructions differ
+	Removed   []int          // functions only in the first image
+	Added     []int          // functions only in the second image
+	Data      []DataDiff
+}
+
+// FunctionDiff is one function in both images and how its instructions differ
+type FunctionDiff struct {
+	From    int
+	To      int
+	Changes []Change
+}
+
+// Change is an instruction changed, removed (To is -1) or inserted (From is -1)
+type Change struct {
+	From int
+	To   int
+	Old  string
+	New  string
+}
+
+func (c Change) String() string {
+	switch {
+	case c.From < 0:
+		return fmt.Sprintf("+ 0x%X %s", c.To, c.New)
+	case c.To < 0:
+		return fmt.Sprintf("- 0x%X %s", c.From, c.Old)
+	}
+	return fmt.Sprintf("~ 0x%X %s  =>  0x%X %s", c.From, c.Old, c.To, c.New)
+}
+
+// DataDiff is a run of changed bytes outside the code
+type DataDiff struct {
+	Start int
+	Old   []byte
+	New   []byte
+}
+
+// Diff compares two analyzed images, functions shorter than minInstrs aren't matched by
+// fingerprint alone
+func Diff(a *disasm.DisAsm, anA *disasm.Analysis, b *disasm.DisAsm, anB *disasm.Analysis, minInstrs int) *Report {
+	report := new(Report)
+
+	matches := disasm.MatchFunctions(a.Fingerprints(anA), b.Fingerprints(anB), minInstrs)
+	pairs := pairFunctions(entries(anA), entries(anB), matches, report)
+
+	for _, p := range pairs {
+		ia, errA := a.FunctionInstructions(anA, p[0])
+		ib, errB := b.FunctionInstructions(anB, p[1])
+		if errA != nil || errB != nil {
+			continue
+		}
+		if changes := diffInstructions(ia, ib); len(changes) > 0 {
+			report.Functions = append(report.Functions, FunctionDiff{From: p[0], To: p[1], Changes: changes})
+		} else {
+			report.Matched++
+		}
+	}
+
+	report.Data = diffData(a.Block(), anA, b.Block(), anB)
+	return report
+}
+
+func entries(an *disasm.Analysis) []int {
+	var adrs []int
+	for adr := range an.Subroutines {
+		adrs = append(adrs, adr)
+	}
+	sort.Ints(adrs)
+	return adrs
+}
+
+// Pairs every fingerprint match, then the unmatched functions between two matched neighbours
+// when both images have the same number of them there. The rest are added or removed.
+func pairFunctions(fa, fb []int, matches []disasm.FunctionMatch, report *Report) [][2]int {
+	var pairs [][2]int
+	var ga, gb []int // unmatched since the last anchor
+
+	flush := func() {
+		if len(ga) == len(gb) {
+			for i := range ga {
+				pairs = append(pairs, [2]int{ga[i], gb[i]})
+			}
+		} else {
+			report.Removed = append(report.Removed, ga...)
+			report.Added = append(report.Added, gb...)
+		}
+		ga, gb = nil, nil
+	}
+
+	ia, ib := 0, 0
+	for _, m := range anchors(matches) {
+		for ia < len(fa) && fa[ia] < m.From {
+			ga = append(ga, fa[ia])
+			ia++
+		}
+		for ib < len(fb) && fb[ib] < m.To {
+			gb = append(gb, fb[ib])
+			ib++
+		}
+		flush()
+		pairs = append(pairs, [2]int{m.From, m.To})
+		ia++
+		ib++
+	}
+	ga = append(ga, fa[ia:]...)
+	gb = append(gb, fb[ib:]...)
+	flush()
+
+	return pairs
+}
+
+// The longest run of matches in the same order in both images, the matches are in order of
+// the first. A function that moved past its neighbours still matches but can't anchor.
+func anchors(matches []disasm.FunctionMatch) []disasm.FunctionMatch {
+	best := make([]int, len(matches)) // length of the longest run ending at i
+	prev := make([]int, len(matches))
+	end := -1
+	for i := range matches {
+		best[i], prev[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if matches[j].To < matches[i].To && best[j]+1 > best[i] {
+				best[i], prev[i] = best[j]+1, j
+			}
+		}
+		if end < 0 || best[i] > best[end] {
+			end = i
+		}
+	}
+
+	var run []disasm.FunctionMatch
+	for i := end; i >= 0; i = prev[i] {
+		run = append([]disasm.FunctionMatch{matches[i]}, run...)
+	}
+	return run
+}
+
+func instrText(instr disasm.Instruction) string {
+	return strings.TrimSpace(disasm.NormalForm(instr) + " " + strings.Join(instr.Operands(), ", "))
+}
+
+// Lines up two instruction lists with a longest common subsequence, a removal next to an
+// insertion is a change
+func diffInstructions(a, b disasm.Instructions) []Change {
+	ta := make([]string, len(a))
+	for i := range a {
+		ta[i] = instrText(a[i])
+	}
+	tb := make([]string, len(b))
+	for i := range b {
+		tb[i] = instrText(b[i])
+	}
+
+	// lcs[i][j] is the longest common subsequence of ta[i:] and tb[j:]
+	lcs := make([][]int, len(ta)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(tb)+1)
+	}
+	for i := len(ta) - 1; i >= 0; i-- {
+		for j := len(tb) - 1; j >= 0; j-- {
+			if ta[i] == tb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var changes []Change
+	i, j := 0, 0
+	for i < len(ta) || j < len(tb) {
+		switch {
+		case i < len(ta) && j < len(tb) && ta[i] == tb[j]:
+			i++
+			j++
+		case i < len(ta) && j < len(tb) && lcs[i][j] == lcs[i+1][j+1]:
+			changes = append(changes, Change{From: a[i].Address, To: b[j].Address, Old: ta[i], New: tb[j]})
+			i++
+			j++
+		case j == len(tb) || i < len(ta) && lcs[i+1][j] >= lcs[i][j+1]:
+			changes = append(changes, Change{From: a[i].Address, To: -1, Old: ta[i]})
+			i++
+		default:
+			changes = append(changes, Change{From: -1, To: b[j].Address, New: tb[j]})
+			j++
+		}
+	}
+	return changes
+}
+
+// Runs of differing bytes at the same address where neither image has code
+func diffData(a []byte, anA *disasm.Analysis, b []byte, anB *disasm.Analysis) []DataDiff {
+	var diffs []DataDiff
+	var cur *DataDiff
+
+	for adr := 0; adr < len(a) && adr < len(b); adr++ {
+		if a[adr] == b[adr] || anA.Crawled[adr] == 1 || anB.Crawled[adr] == 1 {
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			diffs = append(diffs, DataDiff{Start: adr})
+			cur = &diffs[len(diffs)-1]
+		}
+		cur.Old = append(cur.Old, a[adr])
+		cur.New = append(cur.New, b[adr])
+	}
+	return diffs
+}
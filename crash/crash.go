@@ -0,0 +1,252 @@
+package crash
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	Local crash reports. Nothing is ever sent anywhere, the bundle is a zip file
+	written to the working directory, and only after the user says yes.
+*/
+
+// Version is the app version, set by main
+var Version = "unknown"
+
+// Consent asks the user before a bundle is written. Replace it for a UI that
+// can't prompt on the terminal.
+var Consent = func(summary string) bool {
+	fmt.Printf("\n%s\nWrite a crash report to the current directory? It stays on this computer. [y/N]: ", summary)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+const transcriptSize = 200
+
+var (
+	mu         sync.Mutex
+	transcript []string
+	config     = make(map[string]string)
+)
+
+// Record adds a line to the rolling transcript of adapter traffic, dir is ">" for
+// sent and "<" for received
+func Record(dir string, line string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	transcript = append(transcript, fmt.Sprintf("%s %s %s", time.Now().Format("15:04:05.000"), dir, redact(dir, line)))
+	if len(transcript) > transcriptSize {
+		transcript = transcript[len(transcript)-transcriptSize:]
+	}
+}
+
+// SetConfig notes a setting worth including in reports
+func SetConfig(key, value string) {
+	mu.Lock()
+	defer mu.Unlock()
+	config[key] = value
+}
+
+// Security access (service 27, reply 67) carries seeds and keys, keep the service
+// and sub function and hide the rest. A received line can hold several frames.
+func redact(dir, line string) string {
+	lines := strings.Split(line, "\r")
+	for i, l := range lines {
+		lines[i] = redactFrame(dir, l)
+	}
+	return strings.Join(lines, "\r")
+}
+
+// Bytes of a security access message still to come in consecutive frames, by direction,
+// after its ISO-TP first frame
+var hiding = make(map[string]int)
+
+// On the K-line the message is the whole line sent, or comes after the three header
+// bytes of the answer. On CAN it comes after the ISO-TP PCI, one byte for a single
+// frame and two for a first frame, and an answer starts with the ECU's 3 digit ID; the
+// consecutive frames of a message are hidden whole, apart from the PCI.
+func redactFrame(dir, line string) string {
+	lead := line[:len(line)-len(strings.TrimLeft(line, " \n"))]
+	c := strings.ToUpper(strings.Replace(strings.TrimSpace(line), " ", "", -1))
+	hide := func(keep int) string {
+		return lead + c[:keep] + strings.Repeat("*", len(c)-keep)
+	}
+
+	// The CAN ID of an answer
+	id := 0
+	if dir == "<" {
+		id = 3
+	}
+	if len(c) <= id+2 || !isHex(c) || (dir != ">" && dir != "<") {
+		return line
+	}
+
+	frame := c[id:]
+	if hiding[dir] > 0 && frame[0] == '2' {
+		hiding[dir] -= 7
+		return hide(id + 2)
+	}
+	hiding[dir] = 0
+
+	service := "27"
+	if dir == "<" {
+		service = "67"
+	}
+	switch {
+	case frame[0] == '0' && len(frame) > 5 && frame[2:4] == service:
+		return hide(id + 6)
+	case frame[0] == '1' && len(frame) > 7 && frame[4:6] == service:
+		length, _ := strconv.ParseInt(frame[1:4], 16, 0)
+		hiding[dir] = int(length) - 6
+		return hide(id + 8)
+	case dir == ">" && len(c) > 4 && c[:2] == service:
+		return hide(4)
+	case dir == "<" && len(c) > 10 && c[6:8] == service:
+		return hide(10)
+	}
+	return line
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789ABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Bundle is everything that goes into a crash report
+type Bundle struct {
+	Time       time.Time
+	Reason     string
+	Stack      []byte
+	Transcript []string
+	Config     map[string]string
+}
+
+// Capture snapshots the transcript and config
+func Capture(reason string, stack []byte) *Bundle {
+	mu.Lock()
+	defer mu.Unlock()
+
+	b := &Bundle{
+		Time:       time.Now(),
+		Reason:     reason,
+		Stack:      stack,
+		Transcript: make([]string, len(transcript)),
+		Config:     make(map[string]string),
+	}
+	copy(b.Transcript, transcript)
+	for k, v := range config {
+		b.Config[k] = v
+	}
+	return b
+}
+
+// Write saves the bundle as a zip file in dir and returns its name
+func (b *Bundle) Write(dir string) (string, error) {
+	name := fmt.Sprintf("%s/CRASH%s.zip", dir, b.Time.Format("20060102-150405"))
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	z := zip.NewWriter(f)
+
+	files := []struct {
+		name string
+		data string
+	}{
+		{"reason.txt", b.Reason + "\n"},
+		{"stack.txt", string(b.Stack)},
+		{"transcript.txt", strings.Join(b.Transcript, "\n") + "\n"},
+		{"versions.txt", versions()},
+		{"config.txt", b.config()},
+	}
+	for _, file := range files {
+		w, err := z.Create(file.name)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write([]byte(file.data)); err != nil {
+			return "", err
+		}
+	}
+
+	return name, z.Close()
+}
+
+func (b *Bundle) config() string {
+	var keys []string
+	for k := range b.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		lines = append(lines, k+" = "+b.Config[k])
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func versions() string {
+	return fmt.Sprintf("ELMFlash %s\n%s %s/%s\nargs: %s\n", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH, strings.Join(os.Args, " "))
+}
+
+// Report asks for consent and writes a bundle, for errors the program carries on from
+func Report(reason string, stack []byte) {
+	if !Consent("ELMFlash hit an error: " + reason) {
+		return
+	}
+
+	name, err := Capture(reason, stack).Write(".")
+	if err != nil {
+		log("Crash report - Unable to write bundle", err)
+		return
+	}
+	log(fmt.Sprintf("Crash report written to %s, attach it to your bug report", name), nil)
+}
+
+// Fatal reports a protocol error the program can't recover from and exits
+func Fatal(kind string, err error) {
+	log(kind, err)
+	Report(fmt.Sprintf("%s: %s", kind, err), debug.Stack())
+	os.Exit(1)
+}
+
+// Recover is deferred at the top of main, it turns a panic into a report and exits
+func Recover() {
+	if r := recover(); r != nil {
+		Recovered(r)
+		os.Exit(2)
+	}
+}
+
+// Recovered reports a panic that something else already caught with recover
+func Recovered(r interface{}) {
+	log("Panic", fmt.Errorf("%v", r))
+	Report(fmt.Sprintf("panic: %v", r), debug.Stack())
+}
+
+// Log Function
+func log(kind string, err error) {
+	if err == nil {
+		fmt.Printf("====> %s\n", kind)
+	} else {
+		fmt.Printf("[ERROR - %s]: %s\n", kind, err)
+	}
+}
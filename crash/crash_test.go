@@ -0,0 +1,77 @@
+package crash_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/murdinc/ELMFlash/comm"
+	"github.com/murdinc/ELMFlash/crash"
+	"github.com/murdinc/ELMFlash/isotp"
+)
+
+// Records the traffic the way the ELM327 transport does
+type recorder struct {
+	*comm.Mock
+}
+
+func (r recorder) Send(cmd string) error {
+	crash.Record(">", cmd)
+	return r.Mock.Send(cmd)
+}
+
+func (r recorder) Receive() ([]string, error) {
+	lines, err := r.Mock.Receive()
+	crash.Record("<", strings.Join(lines, "\r"))
+	return lines, err
+}
+
+// A security access over CAN, seeds and keys in single frames and split across first
+// and consecutive frames, leaves none of them in the transcript
+func TestRedactISOTP(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("0227010000000000", "7E8 06 67 01 12 34 56 78 00")
+	m.Reply("0627021357246800", "7E8 02 67 02 00 00 00 00 00")
+	m.Reply("0227030000000000", "7E8 10 0A 67 03 A1 A2 A3 A4")
+	m.Reply("3008000000000000", "7E8 21 A5 A6 A7 A8 00 00 00")
+	m.Reply("100A2704AABBCCDD", "7E8 30 00 00 00 00 00 00 00")
+	m.Reply("21EEFF1122000000", "7E8 02 67 04 00 00 00 00 00")
+
+	c := isotp.New(recorder{m}, 0x7E0, 0x7E8)
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range [][]byte{
+		{0x27, 0x01},
+		{0x27, 0x02, 0x13, 0x57, 0x24, 0x68},
+		{0x27, 0x03},
+		{0x27, 0x04, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF, 0x11, 0x22},
+	} {
+		if _, err := c.Request(req); err != nil {
+			t.Fatalf("%X: %s", req, err)
+		}
+	}
+
+	b := crash.Capture("test", nil)
+	all := strings.Replace(strings.Join(b.Transcript, "\n"), " ", "", -1)
+	for _, secret := range []string{"12345678", "13572468", "A1A2A3A4", "A5A6A7A8", "AABBCCDD", "EEFF1122"} {
+		if strings.Contains(all, secret) {
+			t.Errorf("%s left in the transcript:\n%s", secret, strings.Join(b.Transcript, "\n"))
+		}
+	}
+	for _, kept := range []string{"022701", "7E8066701", "7E8100A6703", "100A2704", "7E8026704"} {
+		if !strings.Contains(all, kept) {
+			t.Errorf("%s hidden in the transcript:\n%s", kept, strings.Join(b.Transcript, "\n"))
+		}
+	}
+}
+
+// The K-line's framing, the request bare and the answer after its three header bytes
+func TestRedactKLine(t *testing.T) {
+	crash.Record(">", "2702C0FFEE")
+	crash.Record("<", "84F11067011234")
+	b := crash.Capture("test", nil)
+	n := len(b.Transcript)
+	if n < 2 || !strings.HasSuffix(b.Transcript[n-2], "> 2702******") || !strings.HasSuffix(b.Transcript[n-1], "< 84F1106701****") {
+		t.Errorf("Seed or key left in the transcript:\n%s", strings.Join(b.Transcript, "\n"))
+	}
+}
@@ -0,0 +1,92 @@
+package disasm
+
+import "strings"
+
+// Category is the kind of thing an instruction does, from the opcode tables
+type Category string
+
+const (
+	CategoryBranch     Category = "Branch" // jumps, conditional or not, and DJNZ
+	CategoryCall       Category = "Call"   // and TRAP
+	CategoryReturn     Category = "Return"
+	CategoryLoadStore  Category = "LoadStore" // loads, stores, CLR, XCH, PUSH, POP and block moves
+	CategoryArithmetic Category = "Arithmetic"
+	CategoryLogic      Category = "Logic" // and the shifts
+	CategoryBitTest    Category = "BitTest"
+	CategorySystem     Category = "System" // interrupts, PTS, PSW and power
+)
+
+// Categories is every category, in the order reports list them
+var Categories = []Category{
+	CategoryBranch, CategoryCall, CategoryReturn, CategoryLoadStore,
+	CategoryArithmetic, CategoryLogic, CategoryBitTest, CategorySystem,
+}
+
+// The category of a built in opcode with a mnemonic, for opcodes registered without one
+func categoryOf(mnemonic string) Category {
+	for _, table := range []map[byte]*Opcode{unsignedInstructions, signedInstructions} {
+		for _, op := range table {
+			if op.Mnemonic == mnemonic {
+				return op.Category
+			}
+		}
+	}
+	return ""
+}
+
+// Is reports whether the instruction is in any of the categories
+func (instr *Instruction) Is(categories ...Category) bool {
+	for _, c := range categories {
+		if instr.Opcode != nil && instr.Category == c {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBranch is true for anything that can jump, the bit tests included, but not calls
+func (instr *Instruction) IsBranch() bool {
+	return instr.Is(CategoryBranch, CategoryBitTest)
+}
+
+// IsConditional is true for a branch that can fall through
+func (instr *Instruction) IsConditional() bool {
+	switch strings.TrimPrefix(instr.Mnemonic, "SGN ") {
+	case "JBS", "JBC", "DJNZ", "DJNZW":
+		return true
+	}
+	return instr.IsBranch() && instr.Flags.Tests != 0
+}
+
+// IsIndirect is true for a branch whose target is in a register or table, not the
+// instruction
+func (instr *Instruction) IsIndirect() bool {
+	switch strings.TrimPrefix(instr.Mnemonic, "SGN ") {
+	case "BR", "EBR", "TIJMP":
+		return true
+	}
+	return false
+}
+
+// Filter is the instructions keep is true for, in the same order
+func (inst Instructions) Filter(keep func(*Instruction) bool) Instructions {
+	var kept Instructions
+	for i := range inst {
+		if keep(&inst[i]) {
+			kept = append(kept, inst[i])
+		}
+	}
+	return kept
+}
+
+// InCategory is the instructions in any of the categories
+func (inst Instructions) InCategory(categories ...Category) Instructions {
+	return inst.Filter(func(instr *Instruction) bool {
+		return instr.Is(categories...)
+	})
+}
+
+// IndirectBranches is the branches through a register or jump table
+func (inst Instructions) IndirectBranches() Instructions {
+	return inst.Filter((*Instruction).IsIndirect)
+}
@@ -0,0 +1,214 @@
+package disasm
+
+import "strings"
+
+// State times per addressing mode, from the MCS 96 instruction set summary. They assume
+// operands in the internal register file, anything external adds bus states on top.
+type stateTime struct {
+	Direct       int
+	Immediate    int
+	Indirect     int
+	AutoInc      int
+	ShortIndexed int
+	LongIndexed  int
+	ThreeOp      int // extra for the three operand form
+	Taken        int // extra when a jump is taken
+	PerShift     int // extra per bit shifted
+}
+
+// One timing whatever the addressing mode
+func states(n int) stateTime {
+	return stateTime{n, n, n, n, n, n, 0, 0, 0}
+}
+
+func jumpStates(n, taken int) stateTime {
+	st := states(n)
+	st.Taken = taken
+	return st
+}
+
+func shiftStates(n int) stateTime {
+	st := states(n)
+	st.PerShift = 1
+	return st
+}
+
+var wordArith = stateTime{Direct: 4, Immediate: 5, Indirect: 6, AutoInc: 7, ShortIndexed: 6, LongIndexed: 7, ThreeOp: 1}
+var byteArith = stateTime{Direct: 4, Immediate: 4, Indirect: 6, AutoInc: 7, ShortIndexed: 6, LongIndexed: 7, ThreeOp: 1}
+
+var stateTimes = map[string]stateTime{
+	"ADD":   wordArith,
+	"ADDC":  wordArith,
+	"SUB":   wordArith,
+	"SUBC":  wordArith,
+	"CMP":   wordArith,
+	"AND":   wordArith,
+	"OR":    wordArith,
+	"XOR":   wordArith,
+	"ADDB":  byteArith,
+	"ADDCB": byteArith,
+	"SUBB":  byteArith,
+	"SUBCB": byteArith,
+	"CMPB":  byteArith,
+	"ANDB":  byteArith,
+	"ORB":   byteArith,
+	"XORB":  byteArith,
+
+	"LD":    {Direct: 4, Immediate: 5, Indirect: 5, AutoInc: 6, ShortIndexed: 6, LongIndexed: 7},
+	"LDB":   {Direct: 4, Immediate: 4, Indirect: 5, AutoInc: 6, ShortIndexed: 6, LongIndexed: 7},
+	"LDBZE": {Direct: 4, Immediate: 4, Indirect: 5, AutoInc: 6, ShortIndexed: 6, LongIndexed: 7},
+	"LDBSE": {Direct: 4, Immediate: 4, Indirect: 5, AutoInc: 6, ShortIndexed: 6, LongIndexed: 7},
+	"ST":    {Direct: 4, Indirect: 5, AutoInc: 6, ShortIndexed: 6, LongIndexed: 7},
+	"STB":   {Direct: 4, Indirect: 5, AutoInc: 6, ShortIndexed: 6, LongIndexed: 7},
+	"XCH":   {Direct: 5, ShortIndexed: 8, LongIndexed: 9},
+	"XCHB":  {Direct: 5, ShortIndexed: 8, LongIndexed: 9},
+
+	"MULU":  {Direct: 14, Immediate: 15, Indirect: 16, AutoInc: 17, ShortIndexed: 17, LongIndexed: 18},
+	"MULUB": {Direct: 10, Immediate: 10, Indirect: 12, AutoInc: 13, ShortIndexed: 12, LongIndexed: 13},
+	"MUL":   {Direct: 16, Immediate: 17, Indirect: 18, AutoInc: 19, ShortIndexed: 19, LongIndexed: 20},
+	"MULB":  {Direct: 12, Immediate: 12, Indirect: 14, AutoInc: 15, ShortIndexed: 14, LongIndexed: 15},
+	"DIVU":  {Direct: 24, Immediate: 25, Indirect: 26, AutoInc: 27, ShortIndexed: 27, LongIndexed: 28},
+	"DIVUB": {Direct: 16, Immediate: 16, Indirect: 18, AutoInc: 19, ShortIndexed: 18, LongIndexed: 19},
+	"DIV":   {Direct: 26, Immediate: 27, Indirect: 28, AutoInc: 29, ShortIndexed: 29, LongIndexed: 30},
+	"DIVB":  {Direct: 18, Immediate: 18, Indirect: 20, AutoInc: 21, ShortIndexed: 20, LongIndexed: 21},
+
+	"PUSH": {Direct: 6, Immediate: 6, Indirect: 9, AutoInc: 10, ShortIndexed: 10, LongIndexed: 11},
+	"POP":  {Direct: 8, Indirect: 11, AutoInc: 12, ShortIndexed: 11, LongIndexed: 12},
+
+	"CLR":  states(3),
+	"CLRB": states(3),
+	"NOT":  states(3),
+	"NOTB": states(3),
+	"NEG":  states(3),
+	"NEGB": states(3),
+	"INC":  states(3),
+	"INCB": states(3),
+	"DEC":  states(3),
+	"DECB": states(3),
+	"EXT":  states(4),
+	"EXTB": states(4),
+
+	"SHL":   shiftStates(6),
+	"SHLB":  shiftStates(6),
+	"SHR":   shiftStates(6),
+	"SHRB":  shiftStates(6),
+	"SHRA":  shiftStates(6),
+	"SHRAB": shiftStates(6),
+	"SHLL":  shiftStates(7),
+	"SHRL":  shiftStates(7),
+	"SHRAL": shiftStates(7),
+	"NORML": states(8), // plus one per bit normalized
+
+	"SJMP":  states(7),
+	"LJMP":  states(7),
+	"EJMP":  states(8),
+	"BR":    states(7),
+	"EBR":   states(8),
+	"TIJMP": states(15),
+	"SCALL": states(11),
+	"LCALL": states(11),
+	"ECALL": states(13),
+	"RET":   states(11),
+	"TRAP":  states(16),
+	"RST":   states(16),
+
+	"JC":    jumpStates(4, 4),
+	"JNC":   jumpStates(4, 4),
+	"JE":    jumpStates(4, 4),
+	"JNE":   jumpStates(4, 4),
+	"JGE":   jumpStates(4, 4),
+	"JGT":   jumpStates(4, 4),
+	"JH":    jumpStates(4, 4),
+	"JNH":   jumpStates(4, 4),
+	"JLE":   jumpStates(4, 4),
+	"JLT":   jumpStates(4, 4),
+	"JST":   jumpStates(4, 4),
+	"JNST":  jumpStates(4, 4),
+	"JV":    jumpStates(4, 4),
+	"JNV":   jumpStates(4, 4),
+	"JVT":   jumpStates(4, 4),
+	"JNVT":  jumpStates(4, 4),
+	"JBC":   jumpStates(5, 4),
+	"JBS":   jumpStates(5, 4),
+	"DJNZ":  jumpStates(5, 4),
+	"DJNZW": jumpStates(6, 4),
+
+	"PUSHF": states(6),
+	"POPF":  states(7),
+	"PUSHA": states(12),
+	"POPA":  states(12),
+	"CLRC":  states(2),
+	"SETC":  states(2),
+	"CLRVT": states(2),
+	"DI":    states(2),
+	"EI":    states(2),
+	"DPTS":  states(2),
+	"EPTS":  states(2),
+	"NOP":   states(2),
+	"SKIP":  states(3),
+	"IDLPD": states(8),
+
+	"CMPL": states(7),
+
+	// Block moves take another 8 states per word, the count is in a register
+	"BMOV":   states(6),
+	"BMOVI":  states(7),
+	"EBMOVI": states(8),
+
+	"ELD":  {Indirect: 6, ShortIndexed: 8},
+	"ELDB": {Indirect: 6, ShortIndexed: 8},
+	"EST":  {Indirect: 6, ShortIndexed: 8},
+	"ESTB": {Indirect: 6, ShortIndexed: 8},
+}
+
+func (st stateTime) mode(addressingMode string) int {
+	switch addressingMode {
+	case "immediate":
+		return st.Immediate
+	case "indirect", "extended-indirect":
+		return st.Indirect
+	case "indirect+":
+		return st.AutoInc
+	case "indexed", "short-indexed", "extended-indexed":
+		return st.ShortIndexed
+	case "long-indexed":
+		return st.LongIndexed
+	}
+	return st.Direct
+}
+
+// CycleCount is the state time of the instruction, not taking any jump. Shifts by an
+// immediate count include the count.
+func (instr *Instruction) CycleCount() int {
+	st, ok := stateTimes[strings.TrimPrefix(instr.Mnemonic, "SGN ")]
+	if !ok {
+		return 0
+	}
+
+	n := st.mode(instr.AddressingMode)
+	if len(instr.VarStrings) == 3 {
+		n += st.ThreeOp
+	}
+
+	// Shift count below 0x10 is an immediate count, otherwise it names a register
+	if st.PerShift > 0 && len(instr.RawOps) > 0 && instr.RawOps[0] < 0x10 {
+		n += int(instr.RawOps[0]) * st.PerShift
+	}
+
+	return n
+}
+
+// CycleCountTaken is the state time when the jump is taken
+func (instr *Instruction) CycleCountTaken() int {
+	st := stateTimes[strings.TrimPrefix(instr.Mnemonic, "SGN ")]
+	return instr.CycleCount() + st.Taken
+}
+
+// CycleCount adds up a run of instructions, jumps not taken
+func (inst Instructions) CycleCount() int {
+	n := 0
+	for i := range inst {
+		n += inst[i].CycleCount()
+	}
+	return n
+}
@@ -1,5 +1,10 @@
 package disasm
 
+import (
+	"fmt"
+	"sort"
+)
+
 /*
 	This microcontroller’s flexible interrupt-handling system has three main components:
 	- The programmable interrupt controller
@@ -603,23 +608,43 @@ var interrupts = Interrupts{
 */
 
 func (h *DisAsm) GetInterrupts() error {
+	// An image that stops short of the vectors has nowhere to start a crawl
+	for vec := range interruptVectors {
+		if vec+1 >= len(h.block) {
+			return fmt.Errorf("Image of 0x%X bytes stops before the interrupt vector at 0x%X", len(h.block), vec)
+		}
+	}
 
+	h.intRoutineAdrs = nil
 	h.vectorAdr = make(map[int]string)       // address of interrupt vector locations and name
 	h.intRoutineNames = make(map[int]string) // address of interrupt routine locations and name
 
-	for vec, intr := range interruptVectors {
+	// Map order is random, a routine shared by several vectors is named after the lowest
+	vecs := make([]int, 0, len(interruptVectors))
+	for vec := range interruptVectors {
+		vecs = append(vecs, vec)
+	}
+	sort.Ints(vecs)
+
+	for _, vec := range vecs {
+		intr := interruptVectors[vec]
 
 		rAdr := (int(h.block[vec+1])<<8 | int(h.block[vec]) + 0x170000)
 		h.intRoutineAdrs = append(h.intRoutineAdrs, rAdr) // slice of interrupt routine addresses for start locations
 		h.vectorAdr[vec] = intr.InterruptSource
-		h.intRoutineNames[rAdr] = intr.InterruptSource
+		if h.intRoutineNames[rAdr] == "" {
+			h.intRoutineNames[rAdr] = intr.InterruptSource
+		}
 
 		/*
 			address := addSpaces(fmt.Sprintf("[0x%X]	Interrupt [%s]", rAdr, intr.InterruptSource), 80)
 			shortDesc := fmt.Sprintf("Value: 0x%X ", rAdr)
 
-			log(address+shortDesc, nil)
+			h.log(address+shortDesc, nil)
 		*/
 	}
+
+	// Crawl in address order
+	sort.Ints(h.intRoutineAdrs)
 	return nil
 }
@@ -1,6 +1,27 @@
 package disasm
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// MemKind is what sits at an address
+type MemKind string
+
+const (
+	KindRAM      MemKind = "RAM" // register file and internal RAM
+	KindROM      MemKind = "ROM"
+	KindSFR      MemKind = "SFR"
+	KindExternal MemKind = "EXT" // whatever is on the address/data bus, the flash on this ECU
+	KindReserved MemKind = "RSV"
+	KindNone     MemKind = "" // nothing mapped
+)
+
+var memKinds = map[MemKind]bool{KindRAM: true, KindROM: true, KindSFR: true, KindExternal: true, KindReserved: true}
 
 type MemLocations []MemLocation
 
@@ -10,6 +31,124 @@ type MemLocation struct {
 	Start       int
 	Stop        int
 	Ignore      bool
+	Kind        MemKind
+}
+
+func (m MemLocations) Len() int {
+	return len(m)
+}
+
+func (m MemLocations) Less(i, j int) bool {
+	return m[i].Start < m[j].Start
+}
+
+func (m MemLocations) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+// MemoryMap describes the address space of one chip as wired in one ECU
+type MemoryMap struct {
+	Name      string
+	DataPage  int // page that 16 bit data addresses on the external bus reach
+	CodePage  int // page FFH, where the code runs, is at this page in the image
+	Locations MemLocations
+}
+
+// MemoryMaps are the built in maps, by name
+var MemoryMaps = map[string]*MemoryMap{
+	"196ea": &MemoryMap{Name: "8xC196EA", DataPage: 0x17, CodePage: 0x17, Locations: memMap},
+}
+
+// DefaultMemoryMap is used until a disassembler is given another
+const DefaultMemoryMap = "196ea"
+
+// FindMemoryMap returns a built in map by name, or loads one from a JSON file
+func FindMemoryMap(name string) (*MemoryMap, error) {
+	if m, ok := MemoryMaps[strings.ToLower(name)]; ok {
+		return m, nil
+	}
+	return LoadMemoryMap(name)
+}
+
+// LoadMemoryMap reads a memory map from a JSON file
+func LoadMemoryMap(path string) (*MemoryMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := new(MemoryMap)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("Memory map %s: %s", path, err)
+	}
+	sort.Sort(m.Locations)
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("Memory map %s: %s", path, err)
+	}
+	return m, nil
+}
+
+// Validate checks that the locations are in order, sane and don't overlap. Maps are shared
+// between disassemblers so it only reads.
+func (m *MemoryMap) Validate() error {
+	if len(m.Locations) == 0 {
+		return errors.New("No locations")
+	}
+
+	for i, loc := range m.Locations {
+		if loc.Stop < loc.Start {
+			return fmt.Errorf("%s ends at 0x%X before it starts at 0x%X", loc.Name, loc.Stop, loc.Start)
+		}
+		if !memKinds[loc.Kind] {
+			return fmt.Errorf("%s at 0x%X has unknown kind %q", loc.Name, loc.Start, loc.Kind)
+		}
+		if i > 0 && loc.Start <= m.Locations[i-1].Stop {
+			return fmt.Errorf("%s at 0x%X overlaps or is before %s", loc.Name, loc.Start, m.Locations[i-1].Name)
+		}
+	}
+	return nil
+}
+
+// Locate finds the location holding adr
+func (m *MemoryMap) Locate(adr int) (MemLocation, bool) {
+	i := sort.Search(len(m.Locations), func(i int) bool { return m.Locations[i].Stop >= adr })
+	if i < len(m.Locations) && m.Locations[i].Start <= adr {
+		return m.Locations[i], true
+	}
+	return MemLocation{}, false
+}
+
+// Resolve turns an operand address into an image address. 16 bit addresses that land on
+// the external bus reach the data page, the register file, internal RAM and SFRs are in
+// every page.
+func (m *MemoryMap) Resolve(adr int) int {
+	if adr>>16 == 0xFF && m.CodePage != 0 {
+		return m.CodePage<<16 | adr&0xFFFF
+	}
+	if adr > 0xFFFF || m.DataPage == 0 {
+		return adr
+	}
+	if loc, ok := m.Locate(adr); ok && (loc.Kind == KindExternal || loc.Kind == KindROM) {
+		return m.DataPage<<16 | adr
+	}
+	return adr
+}
+
+// Kind tags a reference to adr
+func (m *MemoryMap) Kind(adr int) MemKind {
+	if loc, ok := m.Locate(m.Resolve(adr)); ok {
+		return loc.Kind
+	}
+	return KindNone
+}
+
+// Executable is true where code can run from
+func (m *MemoryMap) Executable(adr int) bool {
+	switch m.Kind(adr) {
+	case KindROM, KindExternal, KindRAM:
+		return adr >= 0x400 // not out of the register file
+	}
+	return false
 }
 
 var memMap = MemLocations{
@@ -20,6 +159,7 @@ var memMap = MemLocations{
 		Start:       0x000000,
 		Stop:        0x000019,
 		Ignore:      false,
+		Kind:        KindSFR,
 	},
 	MemLocation{
 		Name:        "Lower register file",
@@ -27,6 +167,7 @@ var memMap = MemLocations{
 		Start:       0x00001A,
 		Stop:        0x0000FF,
 		Ignore:      false,
+		Kind:        KindRAM,
 	},
 	MemLocation{
 		Name:        "Upper register file",
@@ -34,6 +175,7 @@ var memMap = MemLocations{
 		Start:       0x000100,
 		Stop:        0x0003FF,
 		Ignore:      false,
+		Kind:        KindRAM,
 	},
 	MemLocation{
 		Name:        "Internal code/data RAM",
@@ -41,6 +183,7 @@ var memMap = MemLocations{
 		Start:       0x000400,
 		Stop:        0x000FFF,
 		Ignore:      false,
+		Kind:        KindRAM,
 	},
 	MemLocation{
 		Name:        "External device",
@@ -48,6 +191,7 @@ var memMap = MemLocations{
 		Start:       0x001000,
 		Stop:        0x001BFF,
 		Ignore:      false,
+		Kind:        KindExternal,
 	},
 	MemLocation{
 		Name:        "Peripheral special-function registers (SFRs)",
@@ -55,6 +199,7 @@ var memMap = MemLocations{
 		Start:       0x001C00,
 		Stop:        0x001FDF,
 		Ignore:      false,
+		Kind:        KindSFR,
 	},
 	MemLocation{
 		Name:        "Memory-mapped special-function registers (SFRs)",
@@ -62,6 +207,7 @@ var memMap = MemLocations{
 		Start:       0x001FE0,
 		Stop:        0x001FFB,
 		Ignore:      false,
+		Kind:        KindSFR,
 	},
 	MemLocation{
 		Name:        "Memory-mapped special-function registers (SFRs)",
@@ -69,6 +215,7 @@ var memMap = MemLocations{
 		Start:       0x001FFC,
 		Stop:        0x001FFF,
 		Ignore:      false,
+		Kind:        KindSFR,
 	},
 	MemLocation{
 		Name:        "External device",
@@ -76,6 +223,7 @@ var memMap = MemLocations{
 		Start:       0x002000,
 		Stop:        0x0023FF,
 		Ignore:      false,
+		Kind:        KindExternal,
 	},
 	MemLocation{
 		Name:        "Internal ROM or External Memory",
@@ -83,6 +231,7 @@ var memMap = MemLocations{
 		Start:       0x002400,
 		Stop:        0x003FFF,
 		Ignore:      false,
+		Kind:        KindROM,
 	},
 	MemLocation{
 		Name:        "External device",
@@ -90,6 +239,7 @@ var memMap = MemLocations{
 		Start:       0x004000,
 		Stop:        0xFFFFF,
 		Ignore:      false,
+		Kind:        KindExternal,
 	},
 
 	MemLocation{ // NOT SURE ABOUT THIS ONE
@@ -98,6 +248,7 @@ var memMap = MemLocations{
 		Start:       0x100000,
 		Stop:        0x16FFFF, // ????
 		Ignore:      false,
+		Kind:        KindExternal, // the flash on this ECU, code runs from here
 	},
 
 	MemLocation{
@@ -106,6 +257,7 @@ var memMap = MemLocations{
 		Start:       0x170000,
 		Stop:        0x1703FF,
 		Ignore:      false,
+		Kind:        KindReserved,
 	},
 	MemLocation{
 		Name:        "Internal code/data RAM ",
@@ -113,6 +265,7 @@ var memMap = MemLocations{
 		Start:       0x170400,
 		Stop:        0x170FFF,
 		Ignore:      true,
+		Kind:        KindRAM,
 	},
 	MemLocation{
 		Name:        "External device",
@@ -120,6 +273,7 @@ var memMap = MemLocations{
 		Start:       0x171000,
 		Stop:        0x171FFF,
 		Ignore:      false,
+		Kind:        KindExternal,
 	},
 	MemLocation{
 		Name:        "Special-purpose memory",
@@ -127,6 +281,7 @@ var memMap = MemLocations{
 		Start:       0x172000,
 		Stop:        0x17207F,
 		Ignore:      false,
+		Kind:        KindROM,
 	},
 	MemLocation{
 		Name:        "Program Start",
@@ -134,6 +289,7 @@ var memMap = MemLocations{
 		Start:       0x172080,
 		Stop:        0x1720BF,
 		Ignore:      false,
+		Kind:        KindROM,
 	},
 	MemLocation{
 		Name:        "Special-purpose memory",
@@ -141,6 +297,7 @@ var memMap = MemLocations{
 		Start:       0x1720C0,
 		Stop:        0x17213F,
 		Ignore:      false,
+		Kind:        KindROM,
 	},
 	MemLocation{
 		Name:        "Program memory",
@@ -148,6 +305,7 @@ var memMap = MemLocations{
 		Start:       0x172140,
 		Stop:        0x1723FF,
 		Ignore:      false,
+		Kind:        KindROM,
 	},
 	MemLocation{
 		Name:        "Program memory",
@@ -155,6 +313,7 @@ var memMap = MemLocations{
 		Start:       0x172400,
 		Stop:        0x173FFF,
 		Ignore:      false,
+		Kind:        KindROM,
 	},
 	MemLocation{
 		Name:        "External device",
@@ -162,16 +321,34 @@ var memMap = MemLocations{
 		Start:       0x174000,
 		Stop:        0x17FFFF,
 		Ignore:      false,
+		Kind:        KindExternal,
 	},
 }
 
+// SetMemoryMap describes the chip and ECU the image is from
+func (h *DisAsm) SetMemoryMap(m *MemoryMap) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	h.memory = m
+	return nil
+}
+
+// MemoryMap is the map in use, the default one unless another was set
+func (h *DisAsm) MemoryMap() *MemoryMap {
+	if h.memory == nil {
+		return MemoryMaps[DefaultMemoryMap]
+	}
+	return h.memory
+}
+
 func (h *DisAsm) GetMemoryMap() error {
 
 	h.memStarts = make(map[int]string) // Starts of memory map Locations
 	h.memStops = make(map[int]string)  // Ends of memory map Locations
 	h.skip = make(map[int]int)         // Start And Stop locations for places to skip
 
-	for _, memLoc := range memMap {
+	for _, memLoc := range h.MemoryMap().Locations {
 		h.memStarts[memLoc.Start] = memLoc.Name
 		h.memStops[memLoc.Stop] = memLoc.Name
 
@@ -189,19 +366,19 @@ func (h *DisAsm) doMemoryMap(adr int) int {
 	//Print the end of a location
 	if h.memStops[adr-1] != "" {
 		location := addSpaces(fmt.Sprintf("\n\n **\n ** [0x%X] END OF %s \n **\n **\n *********************************************\n\n", adr-1, h.memStops[adr-1]), 80)
-		log(location, nil)
+		h.log(location, nil)
 	}
 
 	// Print out our Memory Location Start Block
 	if h.memStarts[adr] != "" {
 		location := addSpaces(fmt.Sprintf("\n\n *********************************************\n **\n ** [0x%X] START OF %s \n **\n **\n\n", adr, h.memStarts[adr]), 80)
-		log(location, nil)
+		h.log(location, nil)
 		if h.skip[adr] != 0 {
 			skip = (h.skip[adr] - adr) + 1
 			location := addSpaces(fmt.Sprintf("** SKIPPING %d BYTES \n **\n", skip), 80)
-			log(location, nil)
+			h.log(location, nil)
 			location = addSpaces(fmt.Sprintf("\n\n **\n ** [0x%X] END OF %s \n **\n **\n *********************************************\n\n", adr+skip-1, h.memStops[adr+skip-1]), 80)
-			log(location, nil)
+			h.log(location, nil)
 		}
 
 	}
@@ -9,28 +9,113 @@ import (
 // Instruction Set
 //////////////////////////////////////
 
+// Returns the table entry for an opcode as an undecoded Instruction, from the signed (0xFE
+// prefixed) table if asked
+func Lookup(op byte, signed bool) (Instruction, bool) {
+	return InstructionSets[DefaultInstructionSet].Lookup(op, signed)
+}
+
+// ErrUnknownOpcode is returned by Parse for a byte that doesn't start any instruction, most
+// likely data. Skipping the byte and trying the next one resyncs.
+type ErrUnknownOpcode struct {
+	Op      byte
+	Signed  bool // followed an 0xFE prefix
+	Address int
+}
+
+func (e ErrUnknownOpcode) Error() string {
+	if e.Signed {
+		return fmt.Sprintf("Unable to find instruction 0xFE 0x%02X at 0x%X", e.Op, e.Address)
+	}
+	return fmt.Sprintf("Unable to find instruction 0x%02X at 0x%X", e.Op, e.Address)
+}
+
+// ErrTruncated is returned by Parse when the buffer ends before the instruction does
+type ErrTruncated struct {
+	Need int
+	Have int
+}
+
+func (e ErrTruncated) Error() string {
+	return fmt.Sprintf("Instruction needs %d bytes, only %d left", e.Need, e.Have)
+}
+
+// ErrMalformed is returned by Parse when the operands can't be decoded the way the table
+// entry says, a registered entry that doesn't fit its row of the opcode map. Like an
+// unknown opcode it is most likely data, skipping the byte resyncs.
+type ErrMalformed struct {
+	Op      byte
+	Signed  bool
+	Address int
+	Reason  string
+}
+
+func (e ErrMalformed) Error() string {
+	if e.Signed {
+		return fmt.Sprintf("Unable to decode instruction 0xFE 0x%02X at 0x%X: %s", e.Op, e.Address, e.Reason)
+	}
+	return fmt.Sprintf("Unable to decode instruction 0x%02X at 0x%X: %s", e.Op, e.Address, e.Reason)
+}
+
 // Returns the first one line instruction in the form of an Instruction "struct" of a byte array that we are given
 func Parse(in []byte, address int) (Instruction, error) {
+	return InstructionSets[DefaultInstructionSet].Parse(in, address)
+}
+
+// Parse decodes the first instruction of in with this set's tables. It is safe to call from
+// several goroutines at once, see InstructionSet.
+func (s *InstructionSet) Parse(in []byte, address int) (Instruction, error) {
+	instr, err := s.parse(in, address, DefaultAddressTranslation)
+	instr.filterXRefs(DefaultDecodeOptions)
+	return instr, err
+}
+
+// Decodes with the jump and call targets wrapped the way t says. The operand decoders
+// read the bytes by the opcode's row of the map, so an entry registered somewhere it
+// doesn't fit can be short of the bytes they read; each checks first and that's an error
+// for the byte, any bytes at all can be handed in.
+func (s *InstructionSet) parse(in []byte, address int, t AddressTranslation) (Instruction, error) {
+	if len(in) == 0 {
+		return Instruction{Opcode: noOpcode, ByteLength: 1}, ErrTruncated{Need: 1, Have: 0}
+	}
 	firstByte := in[0]
 	var signed bool
 
 	// Check if this is a signed operation
-	instructions := unsignedInstructions
 	if firstByte == 0xFE {
+		if len(in) < 2 {
+			return Instruction{Opcode: noOpcode, ByteLength: 1}, ErrTruncated{Need: 2, Have: len(in)}
+		}
 		signed = true
 		firstByte = in[1]
-		instructions = signedInstructions
 	}
 
-	if instruction, ok := instructions[firstByte]; ok {
+	if op, ok := s.opcode(firstByte, signed); ok {
 		// We have it!
-		instruction.Op = firstByte
-		instruction.Signed = signed
-		instruction.Address = address
+		instruction := Instruction{
+			Opcode:         op,
+			Op:             firstByte,
+			Signed:         signed,
+			Address:        address,
+			Mnemonic:       op.Mnemonic,
+			ByteLength:     op.ByteLength,
+			AddressingMode: op.AddressingMode,
+			AutoIncrement:  op.AutoIncrement,
+			Flags:          flagEffects[op.Mnemonic],
+		}
+
+		// The addressing mode is in the low bit of the byte after the opcode, past the prefix
+		mode := 1
+		if signed {
+			mode = 2
+		}
+		if (instruction.AddressingMode == "indexed" || instruction.AddressingMode == "indirect") && len(in) <= mode {
+			return Instruction{Opcode: noOpcode, ByteLength: 1}, ErrTruncated{Need: mode + 1, Have: len(in)}
+		}
 
 		// Check for Indexed Addressing Mode Instruction Type
 		if instruction.AddressingMode == "indexed" && instruction.VariableLength == true {
-			if in[1]&1 == 1 {
+			if in[mode]&1 == 1 {
 				instruction.ByteLength++
 				instruction.AddressingMode = "long-indexed"
 			} else {
@@ -40,7 +125,7 @@ func Parse(in []byte, address int) (Instruction, error) {
 
 		// Check for Indirect Addressing Mode Instruction Type
 		if instruction.AddressingMode == "indirect" {
-			if in[1]&1 == 1 {
+			if in[mode]&1 == 1 {
 				instruction.AddressingMode = "indirect+"
 				instruction.AutoIncrement = true
 			}
@@ -49,6 +134,12 @@ func Parse(in []byte, address int) (Instruction, error) {
 		// Adjust for signed instructions
 		if signed {
 			instruction.ByteLength++
+		}
+		if len(in) < instruction.ByteLength {
+			return Instruction{Opcode: noOpcode, ByteLength: 1}, ErrTruncated{Need: instruction.ByteLength, Have: len(in)}
+		}
+
+		if signed {
 			instruction.Signed = signed
 			instruction.Mnemonic = "SGN " + instruction.Mnemonic
 			instruction.RawOps = in[2:instruction.ByteLength]
@@ -58,77 +149,132 @@ func Parse(in []byte, address int) (Instruction, error) {
 
 		instruction.Raw = in[0:instruction.ByteLength]
 
-		// Build our Vars object from the VarStrings object
+		// Decode the operands named by VarStrings
 		if instruction.VarCount > 0 {
+			var err error
 
 			if (firstByte & 0xf8) == 0x20 {
-				instruction.doSJMP()
-				instruction.doPseudo()
+				err = instruction.doSJMP(t)
 
 			} else if (firstByte & 0xf8) == 0x28 {
-				instruction.doSCALL()
-				instruction.doPseudo()
+				err = instruction.doSCALL(t)
 
 			} else if (firstByte & 0xf8) == 0x30 {
-				instruction.doJBC()
-				instruction.doPseudo()
+				err = instruction.doJBC(t)
 
 			} else if (firstByte & 0xf8) == 0x38 {
-				instruction.doJBS()
-				instruction.doPseudo()
+				err = instruction.doJBS(t)
 
 			} else if (firstByte & 0xf0) == 0xd0 {
-				instruction.doCONDJMP()
-				instruction.doPseudo()
+				err = instruction.doCONDJMP(t)
 
 			} else if (firstByte & 0xf0) == 0xf0 {
-				instruction.doF0()
-				instruction.doPseudo()
+				err = instruction.doF0(t)
 
 			} else if (firstByte & 0xf0) == 0xe0 {
-				instruction.doE0()
-				instruction.doPseudo()
+				err = instruction.doE0(t)
 
 			} else if (firstByte & 0xf0) == 0xc0 {
-				instruction.doC0()
-				instruction.doPseudo()
+				err = instruction.doC0()
 
 			} else if (firstByte & 0xe0) == 0 {
-				instruction.do00()
-				instruction.doPseudo()
+				err = instruction.do00()
 
 			} else {
-				instruction.doMIDDLE()
-				instruction.doPseudo()
+				err = instruction.doMIDDLE()
+			}
+
+			// An entry registered outside its row of the map can decode fewer operands
+			// than it names, or more
+			if err == nil && len(instruction.Ops) != instruction.VarCount {
+				err = fmt.Errorf("decodes %d operands, the entry names %d", len(instruction.Ops), instruction.VarCount)
+			}
+			if err != nil {
+				return Instruction{Opcode: noOpcode, ByteLength: 1}, ErrMalformed{Op: firstByte, Signed: signed, Address: address, Reason: err.Error()}
 			}
+			instruction.doPseudo()
 
 		} else {
+			instruction.doPseudo()
 			instruction.Checked = true
 		}
 
 		return instruction, nil
 
 	} else {
-		return Instruction{ByteLength: 1}, errors.New("Unable to find instruction!")
+		return Instruction{Opcode: noOpcode, ByteLength: 1}, ErrUnknownOpcode{Op: firstByte, Signed: signed, Address: address}
 	}
 
 }
 
-type Instruction struct {
-	Op              byte
-	Address         int
-	XRefs           map[int][]XRef
-	Calls           map[int][]Call
-	Jumps           map[int][]Jump
-	Raw             []byte
-	RawOps          []byte
+// ParseAll decodes in from start to end, one instruction after another. An instruction cut
+// off by the end of in isn't an error, its bytes are handed back as the tail. It stops at the
+// first byte that isn't an instruction and returns what it decoded up to there.
+func ParseAll(in []byte, address int) (instrs Instructions, tail []byte, err error) {
+	return InstructionSets[DefaultInstructionSet].ParseAll(in, address)
+}
+
+// ParseAll decodes in from start to end with this set's tables, see ParseAll
+func (s *InstructionSet) ParseAll(in []byte, address int) (instrs Instructions, tail []byte, err error) {
+	off := 0
+	err = s.Walk(in, address, func(instr Instruction) error {
+		instrs = append(instrs, instr)
+		off += instr.ByteLength
+		return nil
+	})
+	if err != nil {
+		return instrs, nil, err
+	}
+	if off < len(in) {
+		tail = in[off:]
+	}
+	return instrs, tail, nil
+}
+
+// ErrStopWalk is returned by a Walk callback to stop the walk without an error
+var ErrStopWalk = errors.New("Stop walk")
+
+// Walk decodes in from start to end the way ParseAll does, handing each instruction to fn
+// as it goes instead of collecting them. An error from fn stops the walk and is returned,
+// apart from ErrStopWalk which just stops it. For scans that look at every instruction once:
+//
+//	err := disasm.Walk(block[0x172080:0x172200], 0x172080, func(instr disasm.Instruction) error {
+//		if instr.Mnemonic == "LCALL" {
+//			calls = append(calls, instr.Address)
+//		}
+//		return nil
+//	})
+func Walk(in []byte, address int, fn func(Instruction) error) error {
+	return InstructionSets[DefaultInstructionSet].Walk(in, address, fn)
+}
+
+// Walk decodes in with this set's tables, see Walk
+func (s *InstructionSet) Walk(in []byte, address int, fn func(Instruction) error) error {
+	for off := 0; off < len(in); {
+		instr, err := s.Parse(in[off:], address+off)
+		if _, ok := err.(ErrTruncated); ok {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(instr); err == ErrStopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		off += instr.ByteLength
+	}
+	return nil
+}
+
+// Opcode is an entry in the opcode tables. Every instruction decoded from it points at
+// the same one, so it is never changed after the tables are built.
+type Opcode struct {
 	Mnemonic        string
 	ByteLength      int
 	VarCount        int
-	VarStrings      []string            // baop, breg (strings)
-	Vars            map[string]Variable // baop, breg (assembled objects)
-	PseudoCode      string
-	PseudoString    string
+	VarStrings      []string // baop, breg (strings)
 	VarTypes        []string // dest, src, etc
 	AddressingMode  string
 	Description     string
@@ -136,12 +282,42 @@ type Instruction struct {
 	VariableLength  bool
 	AutoIncrement   bool
 	Flags           Flags
-	Signed          bool
+	Category        Category
 	Ignore          bool
+	Signed          bool
 	Reserved        bool
-	Checked         bool
 }
 
+// Instruction is one decoded instruction. The fields an instruction can have different
+// from its Opcode are its own, the rest are read through the Opcode.
+type Instruction struct {
+	*Opcode
+
+	Op             byte
+	Address        int
+	XRefs          map[int][]XRef
+	Calls          map[int][]Call
+	Jumps          map[int][]Jump
+	Raw            []byte
+	RawOps         []byte
+	Mnemonic       string // SGN prefixed after 0xFE
+	ByteLength     int    // one more for long-indexed and after 0xFE
+	AddressingMode string // which indexed or indirect form
+	AutoIncrement  bool
+	Flags          Flags
+	Signed         bool
+	Ops            []Operand // one for each of VarStrings, see Operand
+	PseudoCode     string
+	PseudoString   string
+	Checked        bool
+	Window         int // WSR value the operands were named through, 0 outside a window
+
+	branch condition // what a conditional jump compares, see pairBranches
+}
+
+// The Opcode of what didn't decode
+var noOpcode = &Opcode{}
+
 type Instructions []Instruction
 
 func (inst Instructions) Len() int {
@@ -243,12 +419,9 @@ var VarObjs = map[string]Variable{
 	},
 }
 
-type Flags struct{}
-
+// Variable describes one of the operand fields the opcode tables name (breg, waop...)
 type Variable struct {
 	Description string
-	Type        string
-	Value       string
 	Bits        int
 }
 
@@ -257,6 +430,7 @@ type XRef struct {
 	Mnemonic string
 	XRefFrom int
 	XRefTo   int
+	Kind     MemKind // RAM, ROM, SFR or EXT, from the memory map
 }
 
 type Call struct {
@@ -273,24 +447,20 @@ type Jump struct {
 	JumpTo   int
 }
 
-// XRef
+// XRef files a reference to v, every one of them; which are kept is up to the DecodeOptions
 func (instr *Instruction) XRef(s string, v int) {
-	//if v != 0x00 && instr.Mnemonic != "JBC" {
-	if v > 0x02 {
-
-		existing := instr.XRefs
-		if existing == nil {
-			instr.XRefs = make(map[int][]XRef)
-		} else {
-			for _, ins := range instr.XRefs[v] {
-				if ins.XRefFrom == instr.Address {
-					return
-				}
+	existing := instr.XRefs
+	if existing == nil {
+		instr.XRefs = make(map[int][]XRef)
+	} else {
+		for _, ins := range instr.XRefs[v] {
+			if ins.XRefFrom == instr.Address {
+				return
 			}
 		}
-
-		instr.XRefs[v] = append(existing[v], XRef{String: fmt.Sprintf(s, v), Mnemonic: instr.Mnemonic, XRefFrom: instr.Address, XRefTo: v})
 	}
+
+	instr.XRefs[v] = append(existing[v], XRef{String: fmt.Sprintf(s, v), Mnemonic: instr.Mnemonic, XRefFrom: instr.Address, XRefTo: v})
 }
 
 // Call
@@ -311,135 +481,186 @@ func (instr *Instruction) Jump(s string, v int) {
 	instr.Jumps[v] = append(existing[v], Jump{String: fmt.Sprintf(s, v), Mnemonic: instr.Mnemonic, JumpFrom: instr.Address, JumpTo: v})
 }
 
+// String is the instruction as assembler source, the mnemonic and its operands in the order
+// the assembler takes them (destination first) without the register annotations, so
+// "ADD R_40, R_42, 0x12[R_30]". The asm package reads it back to the same bytes, given
+// EQUs for the SFRs that are shown by name, apart from reserved opcodes and an EJMP or
+// ECALL whose offset has bits the address space drops.
+func (instr Instruction) String() string {
+	if len(instr.Ops) == 0 {
+		return instr.Mnemonic
+	}
+	ops := make([]string, len(instr.Ops))
+	for i, op := range instr.Ops {
+		ops[i] = op.String()
+	}
+	return instr.Mnemonic + " " + strings.Join(ops, ", ")
+}
+
 // Do Pseudo
 func (instr *Instruction) doPseudo() {
-	var v [3]string
-
-Loop:
-	for _, varStr := range instr.VarStrings {
+	instr.PseudoCode = instr.statement(dialects[PseudoC])
+}
 
-		if instr.Mnemonic == "DJNZ" || instr.Mnemonic == "DJNZW" {
-			v[0] = instr.Vars["cadd"].Value
-			v[1] = instr.Vars["breg"].Value
-			break Loop
+// An operand as the pseudo code shows it: "0x04[R_18]" is [R_18+0x04], the zero register is
+// 0, constants are 0x hex and absolute addresses are [0x0CFE]
+func pseudoOperand(op Operand) string {
+	switch op := op.(type) {
+	case RegisterOperand:
+		if op.Reg == 0 {
+			return "0"
 		}
-
-		val := instr.Vars[varStr].Value
-		val = strings.Replace(val, "[R_00 ~(Zero Register)]", "", 1)
-		val = strings.Replace(val, "R_", "$r_", 1)
-		val = strings.Replace(val, "[$r_00]", "", 1)
-		val = strings.Replace(val, "$r_00", "0x00", 1)
-		val = strings.Replace(val, "$r_02", "0x11", 1)
-		val = strings.Replace(val, " ~(", " (", 1)
-		val = strings.Replace(val, " ~", "", 1)
-		val = strings.Replace(val, "$r_02 (Ones Register)", "0x11", 1)
-		val = strings.Replace(val, " (Ones Register)", "", 1)
-		val = strings.Replace(val, "#", "0x", 1)
-
-		val = strings.Replace(val, " ( GP Reg RAM )", "", 1)
-
-		switch instr.Vars[varStr].Type {
-		case "DEST":
-			val = strings.Replace(val, "0x000", "$r_", 1)
-			val = strings.Replace(val, "0x", "$r_", 1)
-			v[0] = val
-		case "ADDR":
-			v[0] = val
-		case "PTRS":
-			v[0] = val
-		case "BYTEREG":
-			v[2] = val
-		default:
-			v[1] = val
+	case ImmediateOperand:
+		return fmt.Sprintf("0x%0*X", op.Size*2, op.Value)
+	case IndexedOperand:
+		offset := fmt.Sprintf("0x%0*X", op.Size*2, op.Offset)
+		if op.Reg == 0 {
+			return "[" + offset + "]"
 		}
+		return "[" + regString(op.Reg, op.Window) + "+" + offset + "]"
 	}
+	return op.String()
+}
 
-	switch instr.Mnemonic {
-
-	case "CLR", "CLRB":
-		instr.PseudoCode = fmt.Sprintf("%s = 0x00", v[0])
-
-	case "EXT":
-		instr.PseudoCode = fmt.Sprintf("SIGN EXTEND INT %s TO LONG INT", v[0])
-
-	case "EXTB":
-		instr.PseudoCode = fmt.Sprintf("SIGN EXTEND SHORT INT %s TO INT", v[0])
-
-	case "JNST", "JNH", "JGT", "JNC", "JNVT", "JNV", "JGE", "JNE", "JST", "JH", "JLE", "JC", "JVT", "JV", "JLT", "JE":
-		instr.PseudoCode = fmt.Sprintf("	JUMP TO: %s", v[0])
-
-	case "JBS":
-		instr.PseudoCode = fmt.Sprintf("if bitno: (%s) of %s is set { JUMP TO: %s }", v[1], v[2], v[0])
-
-	case "JBC":
-		instr.PseudoCode = fmt.Sprintf("if bitno: (%s) of %s is clear { JUMP TO: %s }", v[1], v[2], v[0])
-
-	case "LJMP", "SJMP", "EBR", "EJMP":
-		instr.PseudoCode = fmt.Sprintf("JUMP TO: %s", v[0])
-
-	case "ECALL", "CALL", "SCALL", "LCALL":
-		instr.PseudoCode = fmt.Sprintf("CALL SUB_ %s", v[0])
+// Operands by Var type (DEST, SRC, SRC1, ADDR...)
+func (instr *Instruction) pseudoOperands() map[string]string {
+	ops := make(map[string]string, len(instr.Ops))
+	for i, op := range instr.Ops {
+		ops[strings.ToUpper(instr.VarTypes[i])] = pseudoOperand(op)
+	}
+	return ops
+}
 
-	case "PUSH":
-		instr.PseudoCode = fmt.Sprintf("PUSH %s ONTO THE STACK", v[1])
+var pseudoBinary = map[string]string{
+	"ADD": "+", "ADDB": "+", "ADDC": "+", "ADDCB": "+",
+	"SUB": "-", "SUBB": "-", "SUBC": "-", "SUBCB": "-",
+	"AND": "&", "ANDB": "&", "OR": "|", "ORB": "|", "XOR": "^", "XORB": "^",
+	"MUL": "*", "MULB": "*", "MULU": "*", "MULUB": "*",
+	"DIV": "/", "DIVB": "/", "DIVU": "/", "DIVUB": "/",
+	"SHL": "<<", "SHLB": "<<", "SHLL": "<<",
+	"SHR": ">>", "SHRB": ">>", "SHRL": ">>",
+}
 
-	case "POP":
-		instr.PseudoCode = fmt.Sprintf("POP THE STACK TO %s", v[0])
+// Flag tests of the conditional jumps, for when there is nothing better to say
+var pseudoFlagTests = map[string]string{
+	"JE": "Z", "JNE": "!Z", "JC": "C", "JNC": "!C", "JV": "V", "JNV": "!V",
+	"JVT": "VT", "JNVT": "!VT", "JST": "ST", "JNST": "!ST",
+	"JGE": "!N", "JLT": "N", "JGT": "!N && !Z", "JLE": "N || Z", "JH": "C && !Z", "JNH": "!C || Z",
+}
 
-	case "CMPB", "CMP", "CMPL":
-		instr.PseudoCode = fmt.Sprintf("if (%s == %s) {", v[0], v[1])
+// One line of pseudo code for the instruction on its own, in a dialect. Jumps are gotos,
+// the decompiler turns them into structure.
+func (instr *Instruction) statement(d *dialect) string {
+	m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+	raw := instr.pseudoOperands()
+	ops := make(map[string]string, len(raw))
+	for v, op := range raw {
+		ops[v] = d.operand(op)
+	}
+	dest := ops["DEST"]
 
-	case "ANDB", "AND", "ADDB":
-		instr.PseudoCode = fmt.Sprintf("%s = %s & %s", v[0], v[0], v[1])
+	if op, ok := pseudoBinary[m]; ok {
+		src := ops["SRC"]
+		if src == "" {
+			src = ops["COUNT"]
+		}
 
-	case "ORB", "OR", "XOR", "XORB":
-		instr.PseudoCode = fmt.Sprintf("%s = %s %s %s", v[0], v[0], instr.Mnemonic, v[1])
+		carry := ""
+		switch m {
+		case "ADDC", "ADDCB":
+			carry = " + C"
+		case "SUBC", "SUBCB":
+			carry = d.borrow
+		}
 
-	case "NOT", "NOTB", "NEG", "NEGB":
-		instr.PseudoCode = fmt.Sprintf("%s = %s %s %s", v[0], v[0], instr.Mnemonic, v[0])
+		if a, ok := ops["SRC1"]; ok {
+			return fmt.Sprintf(d.assign, dest, fmt.Sprintf("%s %s %s%s", a, d.op(op), ops["SRC2"], carry))
+		}
+		if carry != "" {
+			return fmt.Sprintf(d.assign, dest, fmt.Sprintf("%s %s %s%s", dest, d.op(op), src, carry))
+		}
+		return d.compound(dest, op, src)
+	}
 
-	case "ADD", "ADDC", "ADDCB":
-		instr.PseudoCode = fmt.Sprintf("%s = %s + %s", v[0], v[0], v[1])
+	if _, ok := pseudoFlagTests[m]; ok {
+		cond := condition{raw: pseudoFlagTests[m]}
+		if instr.branch.op != "" {
+			cond = instr.branch
+		}
+		return fmt.Sprintf(d.ifGoto, d.condition(cond), ops["ADDR"])
+	}
 
+	switch m {
+	case "LD", "LDB", "ELD", "ELDB", "ST", "STB", "EST", "ESTB", "LDBZE":
+		return fmt.Sprintf(d.assign, dest, ops["SRC"])
+	case "LDBSE":
+		return fmt.Sprintf(d.assign, dest, fmt.Sprintf(d.signedByte, ops["SRC"]))
 	case "XCH", "XCHB":
-		instr.PseudoCode = fmt.Sprintf("%s <=%s=> %s", v[0], instr.Mnemonic, v[1])
-
-	case "SUB", "SUBC", "SUBCB", "SUBB":
-		instr.PseudoCode = fmt.Sprintf("%s = %s - %s", v[0], v[0], v[1])
-
-	case "MUL", "MULB", "MULU", "MULUB", "SGN MUL", "SGN MULB":
-		instr.PseudoCode = fmt.Sprintf("%s = %s * %s", v[0], v[0], v[1])
-
-	case "DIV", "DIVU", "DIVUB", "SGN DIVB", "SGN DIV":
-		instr.PseudoCode = fmt.Sprintf("%s = %s / %s", v[0], v[0], v[1])
-
-	case "SHR", "SHRL", "SHRAL", "SHRB":
-		instr.PseudoCode = fmt.Sprintf("%s >> %s", v[0], v[1])
-
-	case "SHL", "SHLL", "SHLB", "SHRA":
-		instr.PseudoCode = fmt.Sprintf("%s << %s", v[0], v[1])
-
-	case "DEC", "DECB":
-		instr.PseudoCode = fmt.Sprintf("%s--", v[0])
-
+		return fmt.Sprintf(d.swap, dest, ops["SRC"])
+	case "CLR", "CLRB":
+		return fmt.Sprintf(d.assign, dest, "0")
+	case "NOT", "NOTB":
+		return fmt.Sprintf(d.complement, dest)
+	case "NEG", "NEGB":
+		return fmt.Sprintf(d.negate, dest)
 	case "INC", "INCB":
-		instr.PseudoCode = fmt.Sprintf("%s++", v[0])
-
-	case "LD", "LDB", "ELD", "ELDB", "STB", "ESTB", "ST", "EST", "LDBZE", "LDBSE":
-		instr.PseudoCode = fmt.Sprintf("%s = %s", v[0], v[1])
-
-	case "NORML": // TODO
-		instr.PseudoCode = fmt.Sprintf("NORMALIZE %s (todo)", v[0])
-
+		return fmt.Sprintf(d.inc, dest)
+	case "DEC", "DECB":
+		return fmt.Sprintf(d.dec, dest)
+	case "EXT", "EXTB":
+		return fmt.Sprintf(d.extend, dest)
+	case "SHRA", "SHRAB", "SHRAL":
+		return fmt.Sprintf(d.assign, dest, fmt.Sprintf(d.signed, dest)+" >> "+ops["COUNT"])
+	case "NORML":
+		return fmt.Sprintf(d.assign, dest, d.function("normalize", ops["SRC"]))
+	case "CMP", "CMPB", "CMPL":
+		return d.function("compare", dest, ops["SRC"])
 	case "BMOV", "BMOVI":
-		instr.PseudoCode = fmt.Sprintf("BMOV %s count(%s) (todo)", v[0], v[1])
+		return d.function("block_move", ops["PTRS"], ops["CNTREG"])
+	case "EBMOVI":
+		// The source pointer is the low long of PTRS, the destination the high one
+		ptrs := instr.Ops[0].(RegisterOperand)
+		dst := RegisterOperand{Reg: ptrs.Reg + 4, Window: ptrs.Window}
+		return fmt.Sprintf(d.copyWords, ops["CNTREG"], pseudoOperand(ptrs), pseudoOperand(dst))
 
-	case "DJNZ", "DJNZW":
-		instr.PseudoCode = fmt.Sprintf("%s--; if ( %s != 0 ) { JUMP TO: %s }", v[1], v[1], v[0])
+	case "PUSH":
+		return d.function("push", ops["SRC"])
+	case "POP":
+		return fmt.Sprintf(d.assign, dest, d.function("pop"))
+	case "PUSHF", "POPF", "PUSHA", "POPA":
+		return d.function(strings.ToLower(m))
+	case "DI", "EI", "DPTS", "EPTS", "IDLPD", "TRAP", "RST":
+		return d.function(strings.ToLower(m))
+	case "CLRC":
+		return fmt.Sprintf(d.assign, "C", "0")
+	case "SETC":
+		return fmt.Sprintf(d.assign, "C", "1")
+	case "CLRVT":
+		return fmt.Sprintf(d.assign, "VT", "0")
+	case "NOP", "SKIP":
+		return ""
 
-	default:
-		instr.PseudoCode = fmt.Sprintf("########### %s = %s", v[0], v[1])
+	case "JBS":
+		return fmt.Sprintf(d.ifGoto, fmt.Sprintf(d.bitSet, ops["BYTEREG"], ops["BITNO"]), ops["ADDR"])
+	case "JBC":
+		return fmt.Sprintf(d.ifGoto, fmt.Sprintf(d.bitClear, ops["BYTEREG"], ops["BITNO"]), ops["ADDR"])
+	case "DJNZ":
+		return fmt.Sprintf(d.djnz, ops["BREG"], ops["ADDR"])
+	case "DJNZW":
+		return fmt.Sprintf(d.djnz, ops["WREG"], ops["ADDR"])
+	case "SJMP", "LJMP", "EJMP":
+		return fmt.Sprintf(d.jump, ops["ADDR"])
+	case "BR", "EBR":
+		return fmt.Sprintf(d.jumpTo, strings.Trim(raw["ADDR"], "[]"))
+	case "TIJMP":
+		return fmt.Sprintf(d.jump, fmt.Sprintf("table[%s & %s]", ops["INDEX"], ops["#MASK"]))
+	case "SCALL", "LCALL", "ECALL":
+		return fmt.Sprintf(d.call, "sub_"+strings.TrimPrefix(ops["ADDR"], "0x"))
+	case "RET":
+		return d.ret
 	}
+
+	return d.function(strings.ToLower(m), instr.VarStrings...)
 }
 
 // Get Offset
@@ -461,37 +682,56 @@ func getOffset(data []byte) int {
 	return offset
 }
 
-// SJMP
-func (instr *Instruction) doSJMP() {
-	vars := map[string]Variable{}
+// Split Offset
+// The reverse of getOffset: returns the three high-order displacement bits (xxx) that
+// belong in the low bits of the SJMP/SCALL opcode, and the low displacement byte.
+func SplitOffset(offset int) (byte, byte) {
+	xxx := byte(offset>>8) & 0x07
+	disp := byte(offset)
+	return xxx, disp
+}
+
+// Checks the instruction carries the n operand bytes its decoder reads
+func (instr *Instruction) need(n int) error {
+	if len(instr.RawOps) < n {
+		return fmt.Errorf("%s in this row of the map takes %d operand bytes, it has %d", instr.Mnemonic, n, len(instr.RawOps))
+	}
+	return nil
+}
+
+// Where a non-extended jump or call offset bytes past the instruction lands
+func (instr *Instruction) relative(t AddressTranslation, offset int) int {
+	next := instr.Address + instr.ByteLength
+	return t.Relative(next, next+offset)
+}
 
+// SJMP
+func (instr *Instruction) doSJMP(t AddressTranslation) error {
+	if err := instr.need(1); err != nil {
+		return err
+	}
 	offset := getOffset([]byte{instr.Op, instr.RawOps[0]})
 
 	str := "0x%X"
-	val := (instr.Address + instr.ByteLength) + offset
+	val := instr.relative(t, offset)
 
 	instr.Jump(str, val)
 	//instr.XRef(str, val)
 
-	cadd := VarObjs["cadd"]
-	cadd.Value = fmt.Sprintf("0x%X", val)
-
-	cadd.Type = instr.VarTypes[0]
-	vars["cadd"] = cadd
-	instr.Vars = vars
+	instr.Ops = []Operand{AddressOperand{Address: val, OpcodeBits: instr.Op & 0x07}}
 	instr.Checked = true
+	return nil
 }
 
 // SCALL
-func (instr *Instruction) doSCALL() {
-	vars := map[string]Variable{}
-
+func (instr *Instruction) doSCALL(t AddressTranslation) error {
+	if err := instr.need(1); err != nil {
+		return err
+	}
 	offset := getOffset([]byte{instr.Op, instr.RawOps[0]})
 
-	cadd := VarObjs["cadd"]
-
 	str := "0x%X"
-	val := (instr.Address + instr.ByteLength) + offset
+	val := instr.relative(t, offset)
 
 	//if val > 0x180000 {
 	//	val = val & 0xFFFFF
@@ -499,110 +739,95 @@ func (instr *Instruction) doSCALL() {
 
 	instr.Call(str, val)
 
-	cadd.Value = fmt.Sprintf(str, val)
-	cadd.Type = instr.VarTypes[0]
-	vars["cadd"] = cadd
-	instr.Vars = vars
+	instr.Ops = []Operand{AddressOperand{Address: val, OpcodeBits: instr.Op & 0x07}}
 	instr.Checked = true
+	return nil
 }
 
 // JBC
-func (instr *Instruction) doJBC() {
-	vars := map[string]Variable{}
-	offset := int(instr.RawOps[1])
-
-	breg := VarObjs["breg"]
+func (instr *Instruction) doJBC(t AddressTranslation) error {
+	if err := instr.need(2); err != nil {
+		return err
+	}
+	offset := int(int8(instr.RawOps[1]))
 
 	val := int(instr.RawOps[0])
 	str := "R_%X"
 	str = regName(str, val)
 	instr.XRef(str, val)
 
-	breg.Value = fmt.Sprintf(str, val)
-	breg.Type = instr.VarTypes[0]
-	vars["breg"] = breg
-
-	bitno := VarObjs["bitno"]
-	bitno.Value = fmt.Sprintf("%d", instr.Op&0x07)
-	bitno.Type = instr.VarTypes[1]
-	vars["bitno"] = bitno
+	breg := RegisterOperand{Reg: val}
+	bitno := BitOperand{Bit: int(instr.Op & 0x07)}
 
-	cadd := VarObjs["cadd"]
-
-	val = int(instr.Address + instr.ByteLength + offset)
+	val = instr.relative(t, offset)
 	str = "0x%X"
 	str = regName(str, val)
 	//instr.XRef(str, val)
 	instr.Jump(str, val)
 
-	cadd.Value = fmt.Sprintf(str, val)
-	cadd.Type = instr.VarTypes[2]
-	vars["cadd"] = cadd
-
-	instr.Vars = vars
+	instr.Ops = []Operand{breg, bitno, AddressOperand{Address: val}}
 	instr.Checked = true
+	return nil
 }
 
 // JBS
-func (instr *Instruction) doJBS() {
-	vars := map[string]Variable{}
-	offset := int(instr.RawOps[1])
-
-	breg := VarObjs["breg"]
+func (instr *Instruction) doJBS(t AddressTranslation) error {
+	if err := instr.need(2); err != nil {
+		return err
+	}
+	offset := int(int8(instr.RawOps[1]))
 
 	val := int(instr.RawOps[0])
 	str := "R_%X"
 	str = regName(str, val)
 	instr.XRef(str, val)
 
-	breg.Value = fmt.Sprintf(str, val)
-	breg.Type = instr.VarTypes[0]
-	vars["breg"] = breg
-
-	bitno := VarObjs["bitno"]
-	bitno.Value = fmt.Sprintf("%d", instr.Op&0x07)
-	bitno.Type = instr.VarTypes[1]
-	vars["bitno"] = bitno
+	breg := RegisterOperand{Reg: val}
+	bitno := BitOperand{Bit: int(instr.Op & 0x07)}
 
-	cadd := VarObjs["cadd"]
-
-	val = int(instr.Address + instr.ByteLength + offset)
+	val = instr.relative(t, offset)
 	str = "0x%X"
 	str = regName(str, val)
 	//instr.XRef(str, val)
 	instr.Jump(str, val)
 
-	cadd.Value = fmt.Sprintf(str, val)
-	cadd.Type = instr.VarTypes[2]
-	vars["cadd"] = cadd
-
-	instr.Vars = vars
+	instr.Ops = []Operand{breg, bitno, AddressOperand{Address: val}}
 	instr.Checked = true
+	return nil
 }
 
 // CONDJMP
-func (instr *Instruction) doCONDJMP() {
-	vars := map[string]Variable{}
-	offset := int(instr.RawOps[0])
+func (instr *Instruction) doCONDJMP(t AddressTranslation) error {
+	if err := instr.need(1); err != nil {
+		return err
+	}
+	offset := int(int8(instr.RawOps[0]))
 
 	str := "0x%X"
-	val := instr.Address + instr.ByteLength + offset
+	val := instr.relative(t, offset)
 	instr.Jump(str, val)
 	//instr.XRef(str, val)
 
-	cadd := VarObjs["cadd"]
-	cadd.Value = fmt.Sprintf(str, val)
-	cadd.Type = instr.VarTypes[0]
-	vars["cadd"] = cadd
-
-	instr.Vars = vars
+	instr.Ops = []Operand{AddressOperand{Address: val}}
 	instr.Checked = true
+	return nil
 }
 
 // Fx OpCodes
-func (instr *Instruction) doF0() {
-	vars := map[string]Variable{}
+func (instr *Instruction) doF0(t AddressTranslation) error {
+	if instr.Op == 0xF6 {
+		// IDLPD #key
+		if err := instr.need(1); err != nil {
+			return err
+		}
+		instr.Ops = []Operand{ImmediateOperand{Value: int(instr.RawOps[0]), Size: 1}}
+		instr.Checked = true
+		return nil
+	}
 
+	if err := instr.need(3); err != nil {
+		return err
+	}
 	b1 := instr.RawOps[0]
 	b2 := instr.RawOps[1]
 	b3 := instr.RawOps[2]
@@ -610,7 +835,7 @@ func (instr *Instruction) doF0() {
 	offset := int(b3)<<16 | int(b2)<<8 | int(b1)
 
 	val := instr.Address + instr.ByteLength + offset
-	val = val & 0x1FFFFF
+	val = t.Wrap(val)
 	str := "0x%X"
 
 	if instr.Mnemonic == "ECALL" {
@@ -619,112 +844,63 @@ func (instr *Instruction) doF0() {
 		instr.XRef(str, val)
 	}
 
-	cadd := VarObjs["cadd"]
-	cadd.Value = fmt.Sprintf(str, val)
-	cadd.Type = instr.VarTypes[0]
-	vars["cadd"] = cadd
-
-	instr.Vars = vars
+	instr.Ops = []Operand{AddressOperand{Address: val}}
 	instr.Checked = true
+	return nil
 }
 
 // Ex OpCodes
-func (instr *Instruction) doE0() {
-	vars := map[string]Variable{}
+func (instr *Instruction) doE0(t AddressTranslation) error {
 	switch instr.Op {
 
 	case 0xE0, 0xE1:
 		// DJNZ, DJNZW
-		offset := int(instr.RawOps[1])
-
-		breg := VarObjs["breg"]
+		if err := instr.need(2); err != nil {
+			return err
+		}
+		offset := int(int8(instr.RawOps[1]))
 
 		val := int(instr.RawOps[0])
 		str := "R_%X"
 		str = regName(str, val)
 		instr.XRef(str, val)
 
-		breg.Value = fmt.Sprintf(str, val)
-		breg.Type = instr.VarTypes[0]
-		vars["breg"] = breg
+		reg := RegisterOperand{Reg: val}
 
-		val = instr.Address + instr.ByteLength + offset
+		val = instr.relative(t, offset)
 		str = "0x%X"
 		instr.Jump(str, val)
 
-		cadd := VarObjs["cadd"]
-		cadd.Value = fmt.Sprintf(str, val)
-		cadd.Type = instr.VarTypes[1]
-		vars["cadd"] = cadd
-
+		instr.Ops = []Operand{reg, AddressOperand{Address: val}}
 		instr.Checked = true
 
 	case 0xEA, 0xEB, 0xE8, 0xE9:
 		// ELD, ELDB
-		switch instr.AddressingMode {
-
-		case "extended-indexed":
+		return instr.doExtended()
 
-			b1 := instr.RawOps[1]
-			b2 := instr.RawOps[2]
-			b3 := instr.RawOps[3]
-
-			offset := int(b3)<<16 | int(b2)<<8 | int(b1)
-
-			offStr := "0x%06X"
-			offStr = regName(offStr, offset)
-			instr.XRef(offStr, offset)
-
-			val := int(instr.RawOps[0])
-			str := "[R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
-
-			treg := VarObjs["treg"]
-			treg.Value = fmt.Sprintf(offStr+str+"]", offset, val)
-			treg.Type = instr.VarTypes[1]
-
-			_reg := VarObjs[instr.VarStrings[0]]
-
-			val = int(instr.RawOps[4])
-			str = "R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
-
-			_reg.Value = fmt.Sprintf(str, val)
-			_reg.Type = instr.VarTypes[0]
-
-			vars["treg"] = treg
-			vars[instr.VarStrings[0]] = _reg
-			instr.Checked = true
-
-		case "extended-indirect":
-
-			val := int(instr.RawOps[0])
-			str := "[R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
-
-			treg := VarObjs["treg"]
-			treg.Value = fmt.Sprintf(str+"]", val)
-			treg.Type = instr.VarTypes[1]
-
-			val = int(instr.RawOps[1])
-			str = "R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
+	case 0xE4:
+		// EBMOVI, the count register then the quad register holding the two 24 bit pointers
+		if err := instr.need(2); err != nil {
+			return err
+		}
+		cnt := int(instr.RawOps[0])
+		str := "R_%02X"
+		str = regName(str, cnt)
+		instr.XRef(str, cnt)
 
-			_reg := VarObjs[instr.VarStrings[0]]
-			_reg.Value = fmt.Sprintf(str, val)
-			_reg.Type = instr.VarTypes[0]
+		ptrs := int(instr.RawOps[1])
+		str = "R_%02X"
+		str = regName(str, ptrs)
+		instr.XRef(str, ptrs)
 
-			vars["treg"] = treg
-			vars[instr.VarStrings[0]] = _reg
-			instr.Checked = true
-		}
+		instr.Ops = []Operand{RegisterOperand{Reg: ptrs}, RegisterOperand{Reg: cnt}}
+		instr.Checked = true
 
 	case 0xE6:
 		// EJMP
+		if err := instr.need(3); err != nil {
+			return err
+		}
 
 		b1 := instr.RawOps[0]
 		b2 := instr.RawOps[1]
@@ -733,58 +909,74 @@ func (instr *Instruction) doE0() {
 		offset := int(b3)<<16 | int(b2)<<8 | int(b1)
 
 		val := instr.Address + instr.ByteLength + offset
-		val = val & 0x1FFFFF
+		val = t.Wrap(val)
 
 		str := "0x%X"
 		str = regName(str, val)
 		instr.Jump(str, val)
 
-		cadd := VarObjs["cadd"]
-		cadd.Value = fmt.Sprintf(str, val)
-		cadd.Type = instr.VarTypes[0]
-		vars["cadd"] = cadd
+		instr.Ops = []Operand{AddressOperand{Address: val}}
+		instr.Checked = true
 
+	case 0xE2:
+		// TIJMP TBASE, [INDEX], #MASK, encoded INDEX, MASK, TBASE
+		if err := instr.need(3); err != nil {
+			return err
+		}
+		index := int(instr.RawOps[0])
+		str := "[R_%02X]"
+		str = regName(str, index)
+		instr.XRef(str, index)
+
+		tbase := int(instr.RawOps[2])
+		str = "R_%02X"
+		str = regName(str, tbase)
+		instr.XRef(str, tbase)
+
+		instr.Ops = []Operand{RegisterOperand{Reg: tbase}, IndirectOperand{Reg: index}, ImmediateOperand{Value: int(instr.RawOps[1]), Size: 1}}
 		instr.Checked = true
 
 	case 0xE3:
 		// BR / EBR
+		if err := instr.need(1); err != nil {
+			return err
+		}
 
 		val := int(instr.RawOps[0])
 
 		if (instr.RawOps[0] & 0x01) == 0x00 {
-			instr.Description = "BRANCH INDIRECT."
+			br := *instr.Opcode // the table entry is shared
+			br.Description = "BRANCH INDIRECT."
+			br.VarStrings = []string{"wreg"}
+			instr.Opcode = &br
 			instr.Mnemonic = "BR"
 			instr.AddressingMode = "indirect"
-			instr.VarStrings = []string{"wreg"}
 
 		} else {
 			val &= 0xFE
 		}
 
-		vo := VarObjs[instr.VarStrings[0]]
 		str := "[R_%02X]"
 		str = regName(str, val)
 		instr.Jump(str, val)
 		instr.XRef(str, val)
 
-		vo.Value = fmt.Sprintf(str, val)
-		vo.Type = instr.VarTypes[0]
-
-		vars[instr.VarStrings[0]] = vo
-
+		instr.Ops = []Operand{IndirectOperand{Reg: val}}
 		instr.Checked = true
 
 	case 0xE7, 0xEF:
 		// LJMP, LCALL
+		if err := instr.need(2); err != nil {
+			return err
+		}
 
 		b1 := instr.RawOps[0]
 		b2 := instr.RawOps[1]
 
-		offset := int(b2)<<8 | int(b1)
+		offset := int(int16(uint16(b2)<<8 | uint16(b1)))
 
-		cadd := VarObjs["cadd"]
 		str := "0x%X"
-		val := int(instr.Address + instr.ByteLength + offset)
+		val := instr.relative(t, offset)
 
 		str = regName(str, val)
 		if instr.Mnemonic == "LCALL" {
@@ -795,35 +987,90 @@ func (instr *Instruction) doE0() {
 
 		//instr.XRef(str, val)
 
-		cadd.Value = fmt.Sprintf(str, val)
-		cadd.Type = instr.VarTypes[0]
-		vars["cadd"] = cadd
+		instr.Ops = []Operand{AddressOperand{Address: val}}
 		instr.Checked = true
 
 	}
-	instr.Vars = vars
 	//instr.Checked = true
+	return nil
 }
 
-//Cx OpCodes
-func (instr *Instruction) doC0() {
-	vars := map[string]Variable{}
+// The 24 bit pointer forms, ELD, ELDB, EST and ESTB
+func (instr *Instruction) doExtended() error {
+	switch instr.AddressingMode {
+
+	case "extended-indexed":
+		if err := instr.need(5); err != nil {
+			return err
+		}
+
+		b1 := instr.RawOps[1]
+		b2 := instr.RawOps[2]
+		b3 := instr.RawOps[3]
+
+		offset := int(b3)<<16 | int(b2)<<8 | int(b1)
+
+		offStr := "0x%06X"
+		offStr = regName(offStr, offset)
+		instr.XRef(offStr, offset)
+
+		val := int(instr.RawOps[0])
+		str := "[R_%02X"
+		str = regName(str, val)
+		instr.XRef(str, val)
+
+		treg := IndexedOperand{Reg: val, Offset: offset, Size: 3}
+
+		val = int(instr.RawOps[4])
+		str = "R_%02X"
+		str = regName(str, val)
+		instr.XRef(str, val)
+
+		instr.Ops = []Operand{RegisterOperand{Reg: val}, treg}
+		instr.Checked = true
+
+	case "extended-indirect":
+		if err := instr.need(2); err != nil {
+			return err
+		}
+
+		val := int(instr.RawOps[0])
+		str := "[R_%02X"
+		str = regName(str, val)
+		instr.XRef(str, val)
+
+		treg := IndirectOperand{Reg: val}
+
+		val = int(instr.RawOps[1])
+		str = "R_%02X"
+		str = regName(str, val)
+		instr.XRef(str, val)
+
+		instr.Ops = []Operand{RegisterOperand{Reg: val}, treg}
+		instr.Checked = true
+	}
+	return nil
+}
+
+// Cx OpCodes
+func (instr *Instruction) doC0() error {
+	ops := make([]Operand, 0, instr.VarCount)
 	instr.Checked = true
 
-	if instr.Op == 0xC1 || instr.Op == 0xC5 || instr.AddressingMode == "direct" {
-		//BMOV / CMPL / all other direct
+	if instr.Op == 0xC1 || instr.Op == 0xC5 || instr.Op == 0xCD || instr.AddressingMode == "direct" {
+		//BMOV / CMPL / BMOVI / all other direct
+		if err := instr.need(instr.VarCount); err != nil {
+			return err
+		}
 		b := len(instr.RawOps) - 1
-		for i, varStr := range instr.VarStrings {
+		for range instr.VarStrings {
 
 			val := int(instr.RawOps[b])
 			str := "R_%02X"
 			str = regName(str, val)
 			instr.XRef(str, val)
 
-			vo := VarObjs[varStr]
-			vo.Value = fmt.Sprintf(str, val)
-			vo.Type = instr.VarTypes[i]
-			vars[varStr] = vo
+			ops = append(ops, RegisterOperand{Reg: val})
 			b--
 			instr.Checked = true
 		}
@@ -833,39 +1080,33 @@ func (instr *Instruction) doC0() {
 		switch instr.AddressingMode {
 
 		case "immediate":
-			for i, varStr := range instr.VarStrings {
-				vo := VarObjs[varStr]
-
+			if err := instr.need(2); err != nil {
+				return err
+			}
+			for range instr.VarStrings {
 				val := int(instr.RawOps[1])<<8 | int(instr.RawOps[0])
 				str := "#%04X"
 				str = regName(str, val)
 				instr.XRef(str, val)
 
-				vo.Value = fmt.Sprintf(str, val)
-				vo.Type = instr.VarTypes[i]
-				vars[varStr] = vo
+				ops = append(ops, ImmediateOperand{Value: val, Size: 2})
 			}
 			instr.Checked = true
 
 		case "indirect", "indirect+":
+			if err := instr.need(instr.VarCount); err != nil {
+				return err
+			}
 			b := len(instr.RawOps) - 1
-			for i, varStr := range instr.VarStrings {
-				str := "R_%02X"
-				val := int(instr.RawOps[b] & 0xFE)
+			for range instr.VarStrings {
+				val := int(instr.RawOps[b])
 				if b == 0 {
-					str = "[R_%02X]"
-					if instr.AutoIncrement == true {
-						str = str + "+"
-						val = val & 0xFE
-					}
+					// The pointer's low bit is the auto-increment
+					val &= 0xFE
+					ops = append(ops, IndirectOperand{Reg: val, AutoIncrement: instr.AutoIncrement})
+				} else {
+					ops = append(ops, RegisterOperand{Reg: val})
 				}
-
-				str = regName(str, val)
-
-				vo := VarObjs[varStr]
-				vo.Value = fmt.Sprintf(str, val)
-				vo.Type = instr.VarTypes[i]
-				vars[varStr] = vo
 				b--
 			}
 			instr.Checked = true
@@ -873,9 +1114,11 @@ func (instr *Instruction) doC0() {
 		case "indexed", "short-indexed":
 
 			// byte offset
+			if err := instr.need(instr.VarCount + 1); err != nil {
+				return err
+			}
 			b := len(instr.RawOps) - 1
-			for i, varStr := range instr.VarStrings {
-				vo := VarObjs[varStr]
+			for i := range instr.VarStrings {
 				val := int(instr.RawOps[b])
 				str := "R_%02X"
 				str = regName(str, val)
@@ -889,17 +1132,10 @@ func (instr *Instruction) doC0() {
 					instr.XRef(offStr, offset)
 
 					val = int(instr.RawOps[b-1] & 0xFE)
-					str = "[R_%02X"
-
-					str = fmt.Sprintf(offStr+str+"]", offset, val)
-					str = regName(str, val)
-					vo.Value = str
+					ops = append(ops, IndexedOperand{Reg: val, Offset: offset, Size: 1})
 				} else {
-					vo.Value = fmt.Sprintf(str, val)
+					ops = append(ops, RegisterOperand{Reg: val})
 				}
-
-				vo.Type = instr.VarTypes[i]
-				vars[varStr] = vo
 				b--
 			}
 			instr.Checked = true
@@ -907,9 +1143,11 @@ func (instr *Instruction) doC0() {
 		case "long-indexed":
 
 			// word offset
+			if err := instr.need(instr.VarCount + 2); err != nil {
+				return err
+			}
 			b := len(instr.RawOps) - 1
-			for i, varStr := range instr.VarStrings {
-				vo := VarObjs[varStr]
+			for i := range instr.VarStrings {
 				val := int(instr.RawOps[b])
 				str := "R_%02X"
 
@@ -925,16 +1163,12 @@ func (instr *Instruction) doC0() {
 					str = regName(str, val)
 					instr.XRef(str, val)
 
-					value := fmt.Sprintf(offStr+str+"]", offset, val)
-					vo.Value = value
+					ops = append(ops, IndexedOperand{Reg: val, Offset: offset, Size: 2})
 				} else {
 					str = regName(str, val)
-					vo.Value = fmt.Sprintf(str, val)
 					instr.XRef(str, val)
+					ops = append(ops, RegisterOperand{Reg: val})
 				}
-
-				vo.Type = instr.VarTypes[i]
-				vars[varStr] = vo
 				b--
 			}
 			instr.Checked = true
@@ -942,166 +1176,115 @@ func (instr *Instruction) doC0() {
 
 	}
 
-	instr.Vars = vars
+	instr.Ops = ops
 	//instr.Checked = true
-
+	return nil
 }
 
 // 0x OpCodes
-func (instr *Instruction) do00() {
-	vars := map[string]Variable{}
-
-	if instr.Op == 0x1F || instr.Op == 0x1D {
-		switch instr.AddressingMode {
-
-		case "extended-indexed":
-			// ETSB
-
-			b1 := byte(instr.RawOps[1])
-			b2 := byte(instr.RawOps[2])
-			b3 := byte(instr.RawOps[3])
-
-			offset := int(b3)<<16 | int(b2)<<8 | int(b1)
-
-			offStr := "0x%06X"
-			offStr = regName(offStr, offset)
-			instr.XRef(offStr, offset)
-
-			val := int(instr.RawOps[0])
-			str := "[R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
-
-			treg := VarObjs["treg"]
-			treg.Value = fmt.Sprintf(offStr+str+"]", offset, val)
-			treg.Type = instr.VarTypes[1]
-
-			val = int(instr.RawOps[4])
-			str = "R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
-
-			_reg := VarObjs[instr.VarStrings[0]]
-			_reg.Value = fmt.Sprintf(str, val)
-			_reg.Type = instr.VarTypes[0]
-
-			vars["treg"] = treg
-			vars[instr.VarStrings[0]] = _reg
-			instr.Vars = vars
-			instr.Checked = true
-
-		case "extended-indirect":
-
-			val := int(instr.RawOps[0])
-			str := "[R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
-
-			treg := VarObjs["treg"]
-			treg.Value = fmt.Sprintf(str+"]", val)
-			treg.Type = instr.VarTypes[1]
-
-			val = int(instr.RawOps[1])
-			str = "R_%02X"
-			str = regName(str, val)
-			instr.XRef(str, val)
-
-			_reg := VarObjs[instr.VarStrings[0]]
-			_reg.Value = fmt.Sprintf(str, val)
-			_reg.Type = instr.VarTypes[0]
-
-			vars["treg"] = treg
-			vars[instr.VarStrings[0]] = _reg
-			instr.Vars = vars
-			instr.Checked = true
+func (instr *Instruction) do00() error {
+	if strings.HasPrefix(instr.AddressingMode, "extended") {
+		// EST, ESTB
+		return instr.doExtended()
+
+	} else if instr.Op == 0x00 && !instr.Signed {
+		// SKIP, the byte it ignores kept so it reads back the same
+		if err := instr.need(1); err != nil {
+			return err
 		}
+		instr.Ops = []Operand{ImmediateOperand{Value: int(instr.RawOps[0]), Size: 1}}
+		instr.Checked = true
+
+	} else if instr.AddressingMode != "direct" {
+		// XCH and XCHB indexed, the signed 0x1C indirect, like the middle of the map
+		return instr.doMIDDLE()
 
 	} else {
 
+		if err := instr.need(instr.VarCount); err != nil {
+			return err
+		}
+		ops := make([]Operand, 0, instr.VarCount)
 		b := len(instr.RawOps) - 1
-		for i, varStr := range instr.VarStrings {
-			vo := VarObjs[varStr]
+		for range instr.VarStrings {
 			val := int(instr.RawOps[b])
 			str := "R_%02X"
 			str = regName(str, val)
 			instr.XRef(str, val)
 
 			if (instr.Op&0x08 == 0x08) && b == 0 && instr.Op != 0x0F && (instr.RawOps[0] < 0x10) {
-				str = "#%02X"
+				ops = append(ops, ImmediateOperand{Value: val, Size: 1}) // shift count
+			} else {
+				ops = append(ops, RegisterOperand{Reg: val})
 			}
-
-			vo.Value = fmt.Sprintf(str, val)
-
-			vo.Type = instr.VarTypes[i]
-			vars[varStr] = vo
 			b--
 		}
 
-		instr.Vars = vars
+		instr.Ops = ops
 		instr.Checked = true
 
 	}
+	return nil
 }
 
 // Middle OpCodes ()
-func (instr *Instruction) doMIDDLE() {
-	vars := map[string]Variable{}
+func (instr *Instruction) doMIDDLE() error {
+	ops := make([]Operand, 0, instr.VarCount)
 
 	switch instr.AddressingMode {
 
 	case "direct":
+		if err := instr.need(instr.VarCount); err != nil {
+			return err
+		}
 		b := len(instr.RawOps) - 1
-		for i, varStr := range instr.VarStrings {
+		for range instr.VarStrings {
 			str := "R_%02X"
 			val := int(instr.RawOps[b])
 			str = regName(str, val)
 			instr.XRef(str, val)
-			vo := VarObjs[varStr]
-			vo.Value = fmt.Sprintf(str, val)
-			vo.Type = instr.VarTypes[i]
-			vars[varStr] = vo
+			ops = append(ops, RegisterOperand{Reg: val})
 			b--
 		}
 		instr.Checked = true
 
 	case "immediate":
-		if instr.Op&0x10 == 0x10 {
+		if instr.VarStrings[len(instr.VarStrings)-1] == "baop" {
 			// byte const
+			if err := instr.need(instr.VarCount); err != nil {
+				return err
+			}
 			b := len(instr.RawOps) - 1
-			for i, varStr := range instr.VarStrings {
+			for range instr.VarStrings {
 				val := int(instr.RawOps[b])
 				str := "R_%02X"
 				str = regName(str, val)
 				if b == 0 {
-					str = "#%02X"
+					ops = append(ops, ImmediateOperand{Value: val, Size: 1})
 				} else {
 					instr.XRef(str, val)
+					ops = append(ops, RegisterOperand{Reg: val})
 				}
-				vo := VarObjs[varStr]
-				vo.Value = fmt.Sprintf(str, val)
-				vo.Type = instr.VarTypes[i]
-				vars[varStr] = vo
 				b--
 			}
 
 		} else {
 			// word constant
+			if err := instr.need(instr.VarCount + 1); err != nil {
+				return err
+			}
 			b := len(instr.RawOps) - 1
-			for i, varStr := range instr.VarStrings {
+			for range instr.VarStrings {
 				val := int(instr.RawOps[b])
 				str := "R_%02X"
 				str = regName(str, val)
 				if b == 1 {
-					str = "#%04X"
 					val = int(instr.RawOps[1])<<8 | int(instr.RawOps[0])
+					ops = append(ops, ImmediateOperand{Value: val, Size: 2})
 				} else {
 					instr.XRef(str, val)
+					ops = append(ops, RegisterOperand{Reg: val})
 				}
-
-				vo := VarObjs[varStr]
-				vo.Value = fmt.Sprintf(str, val)
-				vo.Type = instr.VarTypes[i]
-				vars[varStr] = vo
 				b--
 			}
 
@@ -1109,25 +1292,27 @@ func (instr *Instruction) doMIDDLE() {
 		instr.Checked = true
 
 	case "indirect", "indirect+":
+		if err := instr.need(instr.VarCount); err != nil {
+			return err
+		}
 		b := len(instr.RawOps) - 1
-		for i, varStr := range instr.VarStrings {
+		for range instr.VarStrings {
 			str := "R_%02X"
-			val := int(instr.RawOps[b] & 0xFE)
+			val := int(instr.RawOps[b])
 			str = regName(str, val)
 			if b == 0 {
+				// The pointer's low bit is the auto-increment
+				val &= 0xFE
 				str = "[R_%02X"
 				if instr.AutoIncrement == true {
 					str = str + "+"
-					val = val & 0xFE
 				}
 				str = regName(str, val) + "]"
+				ops = append(ops, IndirectOperand{Reg: val, AutoIncrement: instr.AutoIncrement})
+			} else {
+				ops = append(ops, RegisterOperand{Reg: val})
 			}
 			instr.XRef(str, val)
-
-			vo := VarObjs[varStr]
-			vo.Value = fmt.Sprintf(str, val)
-			vo.Type = instr.VarTypes[i]
-			vars[varStr] = vo
 			b--
 		}
 		instr.Checked = true
@@ -1135,9 +1320,11 @@ func (instr *Instruction) doMIDDLE() {
 	case "indexed", "short-indexed":
 
 		// byte offset
+		if err := instr.need(instr.VarCount + 1); err != nil {
+			return err
+		}
 		b := len(instr.RawOps) - 1
-		for i, varStr := range instr.VarStrings {
-			vo := VarObjs[varStr]
+		for i := range instr.VarStrings {
 			str := "R_%02X"
 			val := int(instr.RawOps[b])
 			str = regName(str, val)
@@ -1155,14 +1342,10 @@ func (instr *Instruction) doMIDDLE() {
 				str = regName(str, val)
 				instr.XRef(str, val)
 
-				value := fmt.Sprintf(offStr+str+"]", offset, val)
-				vo.Value = value
+				ops = append(ops, IndexedOperand{Reg: val, Offset: offset, Size: 1})
 			} else {
-				vo.Value = fmt.Sprintf(str, val)
+				ops = append(ops, RegisterOperand{Reg: val})
 			}
-
-			vo.Type = instr.VarTypes[i]
-			vars[varStr] = vo
 			b--
 		}
 		instr.Checked = true
@@ -1170,9 +1353,11 @@ func (instr *Instruction) doMIDDLE() {
 	case "long-indexed":
 
 		// word offset
+		if err := instr.need(instr.VarCount + 2); err != nil {
+			return err
+		}
 		b := len(instr.RawOps) - 1
-		for i, varStr := range instr.VarStrings {
-			vo := VarObjs[varStr]
+		for i := range instr.VarStrings {
 			val := int(instr.RawOps[b])
 			str := "R_%02X"
 
@@ -1188,45 +1373,41 @@ func (instr *Instruction) doMIDDLE() {
 				str = regName(str, val)
 				instr.XRef(str, val)
 
-				value := fmt.Sprintf(offStr+str+"]", offset, val)
-				vo.Value = value
+				ops = append(ops, IndexedOperand{Reg: val, Offset: offset, Size: 2})
 			} else {
 				str = regName(str, val)
-				vo.Value = fmt.Sprintf(str, val)
 				instr.XRef(str, val)
+				ops = append(ops, RegisterOperand{Reg: val})
 			}
-
-			vo.Type = instr.VarTypes[i]
-			vars[varStr] = vo
 			b--
 		}
 		instr.Checked = true
 
 	}
 
-	instr.Vars = vars
-	//instr.Checked = true
-
+	instr.Ops = ops
+	return nil
 }
 
-var unsignedInstructions = map[byte]Instruction{
-	0x00: Instruction{
+var unsignedInstructions = map[byte]*Opcode{
+	0x00: {
 		Mnemonic:        "SKIP",
 		ByteLength:      2,
-		VarCount:        0,
-		VarTypes:        []string{"ByteReg"},
-		VarStrings:      []string{"breg"},
+		VarCount:        1,
+		VarTypes:        []string{"IGNORED"},
+		VarStrings:      []string{"#byte"},
 		AddressingMode:  "direct",
 		Description:     "TWO BYTE NO-OPERATION.",
 		LongDescription: "Does nothing. Control passes to the next sequentia instruction. This is actually a two-byte NOP i which the second byte can be any value an is simply ignored.",
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          true,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x01: Instruction{
+	0x01: {
 		Mnemonic:        "CLR",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1238,11 +1419,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x02: Instruction{
+	0x02: {
 		Mnemonic:        "NOT",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1254,11 +1436,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x03: Instruction{
+	0x03: {
 		Mnemonic:        "NEG",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1270,11 +1453,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x04: Instruction{
+	0x04: {
 		Mnemonic:        "XCH",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1286,11 +1470,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x05: Instruction{
+	0x05: {
 		Mnemonic:        "DEC",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1302,11 +1487,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x06: Instruction{
+	0x06: {
 		Mnemonic:        "EXT",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1318,11 +1504,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x07: Instruction{
+	0x07: {
 		Mnemonic:        "INC",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1334,11 +1521,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x08: Instruction{
+	0x08: {
 		Mnemonic:        "SHR",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1350,11 +1538,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x09: Instruction{
+	0x09: {
 		Mnemonic:        "SHL",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1366,11 +1555,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x0A: Instruction{
+	0x0A: {
 		Mnemonic:        "SHRA",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1382,11 +1572,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x0B: Instruction{
+	0x0B: {
 		Mnemonic:        "XCH",
 		ByteLength:      4,
 		VarCount:        2,
@@ -1398,11 +1589,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x0C: Instruction{
+	0x0C: {
 		Mnemonic:        "SHRL",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1414,11 +1606,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x0D: Instruction{
+	0x0D: {
 		Mnemonic:        "SHLL",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1430,11 +1623,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x0E: Instruction{
+	0x0E: {
 		Mnemonic:        "SHRAL",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1446,11 +1640,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x0F: Instruction{
+	0x0F: {
 		Mnemonic:        "NORML",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1462,16 +1657,17 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x10: Instruction{
+	0x10: {
 		Mnemonic:   "Reserved",
 		ByteLength: 1,
 		Reserved:   true,
 	},
-	0x11: Instruction{
+	0x11: {
 		Mnemonic:        "CLRB",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1483,11 +1679,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x12: Instruction{
+	0x12: {
 		Mnemonic:        "NOTB",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1499,11 +1696,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x13: Instruction{
+	0x13: {
 		Mnemonic:        "NEGB",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1515,11 +1713,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x14: Instruction{
+	0x14: {
 		Mnemonic:        "XCHB",
 		ByteLength:      3, // Changed? was 2
 		VarCount:        2,
@@ -1531,11 +1730,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x15: Instruction{
+	0x15: {
 		Mnemonic:        "DECB",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1547,11 +1747,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x16: Instruction{
+	0x16: {
 		Mnemonic:        "EXTB",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1563,11 +1764,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x17: Instruction{
+	0x17: {
 		Mnemonic:        "INCB",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1579,11 +1781,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x18: Instruction{
+	0x18: {
 		Mnemonic:        "SHRB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1595,11 +1798,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x19: Instruction{
+	0x19: {
 		Mnemonic:        "SHLB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1611,11 +1815,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x1A: Instruction{
+	0x1A: {
 		Mnemonic:        "SHRAB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1627,27 +1832,29 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x1B: Instruction{
+	0x1B: {
 		Mnemonic:        "XCHB",
 		ByteLength:      4,
 		VarCount:        2,
-		VarTypes:        []string{"DEST", "COUNT"},
-		VarStrings:      []string{"breg", "breg/#count"},
+		VarTypes:        []string{"DEST", "SRC"},
+		VarStrings:      []string{"breg", "baop"},
 		AddressingMode:  "indexed",
-		Description:     "ARITHMETIC RIGHT SHIFT BYTE.",
-		LongDescription: "Shifts the destination byte operand to the right as many times as specified by the count operand. The count may be specified either as an immediate value in the range of 0 to 15 (0FH), inclusive, or as the content of any register (10–0FFH) with a value in the range of 0 to 31 (1FH), inclusive. If the original high order bit value was “0,” zeros are shifted in. If the value was “1,” ones are shifted in. The last bit shifted out is saved in the carry flag.",
+		Description:     "EXCHANGE BYTE.",
+		LongDescription: "Exchanges the value of the source byte operand with that of the destination byte operand.",
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x1C: Instruction{
+	0x1C: {
 		Mnemonic:        "EST",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1659,11 +1866,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x1D: Instruction{
+	0x1D: {
 		Mnemonic:        "EST",
 		ByteLength:      6,
 		VarCount:        2,
@@ -1675,11 +1883,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x1E: Instruction{
+	0x1E: {
 		Mnemonic:        "ESTB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -1691,11 +1900,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x1F: Instruction{
+	0x1F: {
 		Mnemonic:        "ESTB",
 		ByteLength:      6,
 		VarCount:        2,
@@ -1707,11 +1917,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x20: Instruction{
+	0x20: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1723,11 +1934,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x21: Instruction{
+	0x21: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1739,11 +1951,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x22: Instruction{
+	0x22: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1755,11 +1968,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x23: Instruction{
+	0x23: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1771,11 +1985,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x24: Instruction{
+	0x24: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1787,11 +2002,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x25: Instruction{
+	0x25: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1803,11 +2019,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x26: Instruction{
+	0x26: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1819,11 +2036,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x27: Instruction{
+	0x27: {
 		Mnemonic:        "SJMP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1835,11 +2053,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x28: Instruction{
+	0x28: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1851,11 +2070,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x29: Instruction{
+	0x29: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1867,11 +2087,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x2A: Instruction{
+	0x2A: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1883,11 +2104,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x2B: Instruction{
+	0x2B: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1899,11 +2121,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x2C: Instruction{
+	0x2C: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1915,11 +2138,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x2D: Instruction{
+	0x2D: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1931,11 +2155,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x2E: Instruction{
+	0x2E: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1947,11 +2172,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x2F: Instruction{
+	0x2F: {
 		Mnemonic:        "SCALL",
 		ByteLength:      2,
 		VarCount:        1,
@@ -1963,11 +2189,12 @@ var unsignedInstructions = map[byte]Instruction{
 		Description:     "SHORT CALL.",
 		LongDescription: "Pushes the contents of the program counter (the return address) onto the stack, then adds to the program counter the offset between the end of this instruction and the target label, effecting the call. The offset must be in the range of –1024 to +1023.",
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x30: Instruction{
+	0x30: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -1979,11 +2206,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x31: Instruction{
+	0x31: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -1995,11 +2223,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x32: Instruction{
+	0x32: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2011,11 +2240,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x33: Instruction{
+	0x33: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2027,11 +2257,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x34: Instruction{
+	0x34: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2043,11 +2274,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x35: Instruction{
+	0x35: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2059,11 +2291,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x36: Instruction{
+	0x36: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2075,11 +2308,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x37: Instruction{
+	0x37: {
 		Mnemonic:        "JBC",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2091,11 +2325,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x38: Instruction{
+	0x38: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2107,11 +2342,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x39: Instruction{
+	0x39: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2123,11 +2359,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x3A: Instruction{
+	0x3A: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2139,11 +2376,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x3B: Instruction{
+	0x3B: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2155,11 +2393,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x3C: Instruction{
+	0x3C: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2171,11 +2410,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x3D: Instruction{
+	0x3D: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2187,11 +2427,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x3E: Instruction{
+	0x3E: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2203,11 +2444,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x3F: Instruction{
+	0x3F: {
 		Mnemonic:        "JBS",
 		ByteLength:      3,
 		VarCount:        3,
@@ -2219,11 +2461,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBitTest,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x40: Instruction{
+	0x40: {
 		Mnemonic:        "AND",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2235,11 +2478,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x41: Instruction{
+	0x41: {
 		Mnemonic:        "AND",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2251,11 +2495,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x42: Instruction{
+	0x42: {
 		Mnemonic:        "AND",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2267,11 +2512,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x43: Instruction{
+	0x43: {
 		Mnemonic:        "AND",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2283,11 +2529,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x44: Instruction{
+	0x44: {
 		Mnemonic:        "ADD",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2299,11 +2546,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x45: Instruction{
+	0x45: {
 		Mnemonic:        "ADD",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2315,11 +2563,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x46: Instruction{
+	0x46: {
 		Mnemonic:        "ADD",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2331,11 +2580,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x47: Instruction{
+	0x47: {
 		Mnemonic:        "ADD",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2347,11 +2597,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x48: Instruction{
+	0x48: {
 		Mnemonic:        "SUB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2363,11 +2614,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x49: Instruction{
+	0x49: {
 		Mnemonic:        "SUB",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2379,11 +2631,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4A: Instruction{
+	0x4A: {
 		Mnemonic:        "SUB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2395,11 +2648,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4B: Instruction{
+	0x4B: {
 		Mnemonic:        "SUB",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2411,11 +2665,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4C: Instruction{
+	0x4C: {
 		Mnemonic:        "MULU",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2427,11 +2682,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4D: Instruction{
+	0x4D: {
 		Mnemonic:        "MULU",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2443,11 +2699,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4E: Instruction{
+	0x4E: {
 		Mnemonic:        "MULU",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2459,11 +2716,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4F: Instruction{
+	0x4F: {
 		Mnemonic:        "MULU",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2475,11 +2733,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x50: Instruction{
+	0x50: {
 		Mnemonic:        "ANDB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2491,11 +2750,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x51: Instruction{
+	0x51: {
 		Mnemonic:        "ANDB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2507,11 +2767,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x52: Instruction{
+	0x52: {
 		Mnemonic:        "ANDB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2523,11 +2784,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x53: Instruction{
+	0x53: {
 		Mnemonic:        "ANDB",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2539,11 +2801,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x54: Instruction{
+	0x54: {
 		Mnemonic:        "ADDB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2555,11 +2818,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x55: Instruction{
+	0x55: {
 		Mnemonic:        "ADDB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2571,11 +2835,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x56: Instruction{
+	0x56: {
 		Mnemonic:        "ADDB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2587,11 +2852,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x57: Instruction{
+	0x57: {
 		Mnemonic:        "ADDB",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2603,11 +2869,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x58: Instruction{
+	0x58: {
 		Mnemonic:        "SUBB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2619,11 +2886,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x59: Instruction{
+	0x59: {
 		Mnemonic:        "SUBB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2635,11 +2903,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5A: Instruction{
+	0x5A: {
 		Mnemonic:        "SUBB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2651,11 +2920,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5B: Instruction{
+	0x5B: {
 		Mnemonic:        "SUBB",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2667,11 +2937,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5C: Instruction{
+	0x5C: {
 		Mnemonic:        "MULUB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2683,11 +2954,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5D: Instruction{
+	0x5D: {
 		Mnemonic:        "MULUB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2699,11 +2971,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5E: Instruction{
+	0x5E: {
 		Mnemonic:        "MULUB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -2715,11 +2988,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5F: Instruction{
+	0x5F: {
 		Mnemonic:        "MULUB",
 		ByteLength:      5,
 		VarCount:        3,
@@ -2731,11 +3005,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x60: Instruction{
+	0x60: {
 		Mnemonic:        "AND",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2747,11 +3022,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x61: Instruction{
+	0x61: {
 		Mnemonic:        "AND",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2763,11 +3039,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x62: Instruction{
+	0x62: {
 		Mnemonic:        "AND",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2779,11 +3056,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x63: Instruction{
+	0x63: {
 		Mnemonic:        "AND",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2795,11 +3073,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x64: Instruction{
+	0x64: {
 		Mnemonic:        "ADD",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2811,11 +3090,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x65: Instruction{
+	0x65: {
 		Mnemonic:        "ADD",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2827,11 +3107,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x66: Instruction{
+	0x66: {
 		Mnemonic:        "ADD",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2843,11 +3124,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x67: Instruction{
+	0x67: {
 		Mnemonic:        "ADD",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2859,11 +3141,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x68: Instruction{
+	0x68: {
 		Mnemonic:        "SUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2875,11 +3158,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x69: Instruction{
+	0x69: {
 		Mnemonic:        "SUB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2891,11 +3175,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6A: Instruction{
+	0x6A: {
 		Mnemonic:        "SUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2907,11 +3192,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6B: Instruction{
+	0x6B: {
 		Mnemonic:        "SUB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2923,11 +3209,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6C: Instruction{
+	0x6C: {
 		Mnemonic:        "MULU",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2939,11 +3226,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6D: Instruction{
+	0x6D: {
 		Mnemonic:        "MULU",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2955,11 +3243,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6E: Instruction{
+	0x6E: {
 		Mnemonic:        "MULU",
 		ByteLength:      3,
 		VarCount:        2,
@@ -2971,11 +3260,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6F: Instruction{
+	0x6F: {
 		Mnemonic:        "MULU",
 		ByteLength:      4,
 		VarCount:        2,
@@ -2987,11 +3277,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x70: Instruction{
+	0x70: {
 		Mnemonic:        "ANDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3003,11 +3294,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x71: Instruction{
+	0x71: {
 		Mnemonic:        "ANDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3020,11 +3312,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength: false,
 		AutoIncrement:  false,
 		Flags:          Flags{},
+		Category:       CategoryLogic,
 		Ignore:         false,
 		Signed:         false,
 		Reserved:       false,
 	},
-	0x72: Instruction{
+	0x72: {
 		Mnemonic:        "ANDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3037,11 +3330,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength: false,
 		AutoIncrement:  false,
 		Flags:          Flags{},
+		Category:       CategoryLogic,
 		Ignore:         false,
 		Signed:         false,
 		Reserved:       false,
 	},
-	0x73: Instruction{
+	0x73: {
 		Mnemonic:        "ANDB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3054,11 +3348,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength: true,
 		AutoIncrement:  false,
 		Flags:          Flags{},
+		Category:       CategoryLogic,
 		Ignore:         false,
 		Signed:         false,
 		Reserved:       false,
 	},
-	0x74: Instruction{
+	0x74: {
 		Mnemonic:        "ADDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3070,11 +3365,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x75: Instruction{
+	0x75: {
 		Mnemonic:        "ADDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3086,11 +3382,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x76: Instruction{
+	0x76: {
 		Mnemonic:        "ADDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3102,11 +3399,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x77: Instruction{
+	0x77: {
 		Mnemonic:        "ADDB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3118,11 +3416,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x78: Instruction{
+	0x78: {
 		Mnemonic:        "SUBB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3134,11 +3433,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x79: Instruction{
+	0x79: {
 		Mnemonic:        "SUBB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3150,11 +3450,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7A: Instruction{
+	0x7A: {
 		Mnemonic:        "SUBB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3166,11 +3467,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7B: Instruction{
+	0x7B: {
 		Mnemonic:        "SUBB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3182,11 +3484,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7C: Instruction{
+	0x7C: {
 		Mnemonic:        "MULUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3198,11 +3501,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7D: Instruction{
+	0x7D: {
 		Mnemonic:        "MULUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3214,11 +3518,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7E: Instruction{
+	0x7E: {
 		Mnemonic:        "MULUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3230,11 +3535,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7F: Instruction{
+	0x7F: {
 		Mnemonic:        "MULUB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3246,11 +3552,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x80: Instruction{
+	0x80: {
 		Mnemonic:        "OR",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3262,11 +3569,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x81: Instruction{
+	0x81: {
 		Mnemonic:        "OR",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3278,11 +3586,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x82: Instruction{
+	0x82: {
 		Mnemonic:        "OR",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3294,11 +3603,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x83: Instruction{
+	0x83: {
 		Mnemonic:        "OR",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3310,11 +3620,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x84: Instruction{
+	0x84: {
 		Mnemonic:        "XOR",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3326,11 +3637,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x85: Instruction{
+	0x85: {
 		Mnemonic:        "XOR",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3342,11 +3654,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x86: Instruction{
+	0x86: {
 		Mnemonic:        "XOR",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3358,11 +3671,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x87: Instruction{
+	0x87: {
 		Mnemonic:        "XOR",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3374,11 +3688,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x88: Instruction{
+	0x88: {
 		Mnemonic:        "CMP",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3390,11 +3705,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x89: Instruction{
+	0x89: {
 		Mnemonic:        "CMP",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3406,11 +3722,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8A: Instruction{
+	0x8A: {
 		Mnemonic:        "CMP",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3422,11 +3739,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8B: Instruction{
+	0x8B: {
 		Mnemonic:        "CMP",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3438,11 +3756,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8C: Instruction{
+	0x8C: {
 		Mnemonic:        "DIVU",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3454,11 +3773,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8D: Instruction{
+	0x8D: {
 		Mnemonic:        "DIVU",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3470,11 +3790,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8E: Instruction{
+	0x8E: {
 		Mnemonic:        "DIVU",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3486,11 +3807,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8F: Instruction{
+	0x8F: {
 		Mnemonic:        "DIVU",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3502,11 +3824,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x90: Instruction{
+	0x90: {
 		Mnemonic:        "ORB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3518,11 +3841,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x91: Instruction{
+	0x91: {
 		Mnemonic:        "ORB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3534,11 +3858,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x92: Instruction{
+	0x92: {
 		Mnemonic:        "ORB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3550,11 +3875,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x93: Instruction{
+	0x93: {
 		Mnemonic:        "ORB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3566,11 +3892,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x94: Instruction{
+	0x94: {
 		Mnemonic:        "XORB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3582,11 +3909,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x95: Instruction{
+	0x95: {
 		Mnemonic:        "XORB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3598,11 +3926,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x96: Instruction{
+	0x96: {
 		Mnemonic:        "XORB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3614,11 +3943,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x97: Instruction{
+	0x97: {
 		Mnemonic:        "XORB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3630,11 +3960,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLogic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x98: Instruction{
+	0x98: {
 		Mnemonic:        "CMPB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3646,11 +3977,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x99: Instruction{
+	0x99: {
 		Mnemonic:        "CMPB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3662,11 +3994,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9A: Instruction{
+	0x9A: {
 		Mnemonic:        "CMPB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3678,11 +4011,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9B: Instruction{
+	0x9B: {
 		Mnemonic:        "CMPB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3694,11 +4028,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9C: Instruction{
+	0x9C: {
 		Mnemonic:        "DIVUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3710,11 +4045,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9D: Instruction{
+	0x9D: {
 		Mnemonic:        "DIVUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3726,11 +4062,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9E: Instruction{
+	0x9E: {
 		Mnemonic:        "DIVUB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3742,11 +4079,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9F: Instruction{
+	0x9F: {
 		Mnemonic:        "DIVUB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3758,11 +4096,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA0: Instruction{
+	0xA0: {
 		Mnemonic:        "LD",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3774,11 +4113,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA1: Instruction{
+	0xA1: {
 		Mnemonic:        "LD",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3790,11 +4130,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA2: Instruction{
+	0xA2: {
 		Mnemonic:        "LD",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3806,11 +4147,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA3: Instruction{
+	0xA3: {
 		Mnemonic:        "LD",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3822,11 +4164,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA4: Instruction{
+	0xA4: {
 		Mnemonic:        "ADDC",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3838,11 +4181,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA5: Instruction{
+	0xA5: {
 		Mnemonic:        "ADDC",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3854,11 +4198,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA6: Instruction{
+	0xA6: {
 		Mnemonic:        "ADDC",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3870,11 +4215,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA7: Instruction{
+	0xA7: {
 		Mnemonic:        "ADDC",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3886,11 +4232,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA8: Instruction{
+	0xA8: {
 		Mnemonic:        "SUBC",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3902,11 +4249,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xA9: Instruction{
+	0xA9: {
 		Mnemonic:        "SUBC",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3918,11 +4266,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xAA: Instruction{
+	0xAA: {
 		Mnemonic:        "SUBC",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3934,11 +4283,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xAB: Instruction{
+	0xAB: {
 		Mnemonic:        "SUBC",
 		ByteLength:      4,
 		VarCount:        2,
@@ -3950,11 +4300,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xAC: Instruction{
+	0xAC: {
 		Mnemonic:        "LDBZE",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3966,11 +4317,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xAD: Instruction{
+	0xAD: {
 		Mnemonic:        "LDBZE",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3982,11 +4334,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xAE: Instruction{
+	0xAE: {
 		Mnemonic:        "LDBZE",
 		ByteLength:      3,
 		VarCount:        2,
@@ -3998,11 +4351,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xAF: Instruction{
+	0xAF: {
 		Mnemonic:        "LDBZE",
 		ByteLength:      4,
 		VarCount:        2,
@@ -4014,11 +4368,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB0: Instruction{
+	0xB0: {
 		Mnemonic:        "LDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4030,11 +4385,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB1: Instruction{
+	0xB1: {
 		Mnemonic:        "LDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4046,11 +4402,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB2: Instruction{
+	0xB2: {
 		Mnemonic:        "LDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4062,11 +4419,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB3: Instruction{
+	0xB3: {
 		Mnemonic:        "LDB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -4078,11 +4436,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB4: Instruction{
+	0xB4: {
 		Mnemonic:        "ADDCB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4094,11 +4453,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB5: Instruction{
+	0xB5: {
 		Mnemonic:        "ADDCB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4110,11 +4470,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB6: Instruction{
+	0xB6: {
 		Mnemonic:        "ADDCB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4126,11 +4487,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB7: Instruction{
+	0xB7: {
 		Mnemonic:        "ADDCB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -4142,11 +4504,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB8: Instruction{
+	0xB8: {
 		Mnemonic:        "SUBCB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4158,11 +4521,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xB9: Instruction{
+	0xB9: {
 		Mnemonic:        "SUBCB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4174,11 +4538,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xBA: Instruction{
+	0xBA: {
 		Mnemonic:        "SUBCB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4190,11 +4555,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xBB: Instruction{
+	0xBB: {
 		Mnemonic:        "SUBCB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -4206,11 +4572,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xBC: Instruction{
+	0xBC: {
 		Mnemonic:        "LDBSE",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4222,11 +4589,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xBD: Instruction{
+	0xBD: {
 		Mnemonic:        "LDBSE",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4238,11 +4606,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xBE: Instruction{
+	0xBE: {
 		Mnemonic:        "LDBSE",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4254,11 +4623,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xBF: Instruction{
+	0xBF: {
 		Mnemonic:        "LDBSE",
 		ByteLength:      4,
 		VarCount:        2,
@@ -4270,11 +4640,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC0: Instruction{
+	0xC0: {
 		Mnemonic:        "ST",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4286,27 +4657,29 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC1: Instruction{
+	0xC1: {
 		Mnemonic:        "BMOV",
 		ByteLength:      3,
 		VarCount:        2,
 		VarTypes:        []string{"PTRS", "CNTREG"},
 		VarStrings:      []string{"lreg", "wreg"},
-		AddressingMode:  "",
+		AddressingMode:  "direct",
 		Description:     "BLOCK MOVE.",
 		LongDescription: "Moves a block of word data from one location in memory to another. The source and destination addresses are calculated using indirect addressing with autoincrement.\n A long register (PTRS) addresses the source and destination pointers, which are stored in adjacent word registers. The source pointer (SRCPTR) is the low word and the destination pointer (DSTPTR) is the high word of PTRS.\n A word register (CNTREG) specifies thenumber of transfers. CNTREG must reside in the lower register file; it cannot be windowed. The blocks of word data can be located anywhere in page 00H, but should not overlap. Because the source (SRCPTR) and destination (DSTPTR) pointers are 16 bits wide, this instruction uses nonextended data moves. It cannot operate across page boundaries.",
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC2: Instruction{
+	0xC2: {
 		Mnemonic:        "ST",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4318,11 +4691,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC3: Instruction{
+	0xC3: {
 		Mnemonic:        "ST",
 		ByteLength:      4,
 		VarCount:        2,
@@ -4334,11 +4708,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC4: Instruction{
+	0xC4: {
 		Mnemonic:        "STB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4350,11 +4725,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC5: Instruction{
+	0xC5: {
 		Mnemonic:        "CMPL",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4366,11 +4742,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC6: Instruction{
+	0xC6: {
 		Mnemonic:        "STB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4382,11 +4759,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC7: Instruction{
+	0xC7: {
 		Mnemonic:        "STB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -4398,11 +4776,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC8: Instruction{
+	0xC8: {
 		Mnemonic:        "PUSH",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4414,11 +4793,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xC9: Instruction{
+	0xC9: {
 		Mnemonic:        "PUSH",
 		ByteLength:      3,
 		VarCount:        1,
@@ -4430,11 +4810,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xCA: Instruction{
+	0xCA: {
 		Mnemonic:        "PUSH",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4446,11 +4827,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xCB: Instruction{
+	0xCB: {
 		Mnemonic:        "PUSH",
 		ByteLength:      3,
 		VarCount:        1,
@@ -4462,11 +4844,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xCC: Instruction{
+	0xCC: {
 		Mnemonic:        "POP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4478,11 +4861,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xCD: Instruction{
+	0xCD: {
 		Mnemonic:        "BMOVI",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4494,11 +4878,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xCE: Instruction{
+	0xCE: {
 		Mnemonic:        "POP",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4510,11 +4895,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xCF: Instruction{
+	0xCF: {
 		Mnemonic:        "POP",
 		ByteLength:      3,
 		VarCount:        1,
@@ -4526,11 +4912,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD0: Instruction{
+	0xD0: {
 		Mnemonic:        "JNST",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4542,11 +4929,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD1: Instruction{
+	0xD1: {
 		Mnemonic:        "JNH",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4558,11 +4946,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD2: Instruction{
+	0xD2: {
 		Mnemonic:        "JGT",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4574,11 +4963,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD3: Instruction{
+	0xD3: {
 		Mnemonic:        "JNC",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4590,11 +4980,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD4: Instruction{
+	0xD4: {
 		Mnemonic:        "JNVT",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4606,11 +4997,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD5: Instruction{
+	0xD5: {
 		Mnemonic:        "JNV",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4622,11 +5014,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD6: Instruction{
+	0xD6: {
 		Mnemonic:        "JGE",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4638,11 +5031,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD7: Instruction{
+	0xD7: {
 		Mnemonic:        "JNE",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4654,11 +5048,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD8: Instruction{
+	0xD8: {
 		Mnemonic:        "JST",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4670,11 +5065,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xD9: Instruction{
+	0xD9: {
 		Mnemonic:        "JH",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4686,11 +5082,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xDA: Instruction{
+	0xDA: {
 		Mnemonic:        "JLE",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4702,11 +5099,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xDB: Instruction{
+	0xDB: {
 		Mnemonic:        "JC",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4718,11 +5116,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xDC: Instruction{
+	0xDC: {
 		Mnemonic:        "JVT",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4734,11 +5133,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xDD: Instruction{
+	0xDD: {
 		Mnemonic:        "JV",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4750,11 +5150,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xDE: Instruction{
+	0xDE: {
 		Mnemonic:        "JLT",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4766,11 +5167,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xDF: Instruction{
+	0xDF: {
 		Mnemonic:        "JE",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4782,14 +5184,15 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE0: Instruction{
+	0xE0: {
 		Mnemonic:        "DJNZ",
 		ByteLength:      3,
-		VarCount:        1,
+		VarCount:        2,
 		VarTypes:        []string{"BREG", "ADDR"},
 		VarStrings:      []string{"breg", "cadd"},
 		AddressingMode:  "indexed",
@@ -4798,14 +5201,15 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE1: Instruction{
+	0xE1: {
 		Mnemonic:        "DJNZW",
 		ByteLength:      3,
-		VarCount:        1,
+		VarCount:        2,
 		VarTypes:        []string{"WREG", "ADDR"},
 		VarStrings:      []string{"wreg", "cadd"},
 		AddressingMode:  "indexed",
@@ -4814,15 +5218,16 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE2: Instruction{
+	0xE2: {
 		Mnemonic:        "TIJMP",
 		ByteLength:      4,
 		VarCount:        3,
-		VarTypes:        []string{"TBASE", "INDEX", "#MASK"}, // TODO XXX
+		VarTypes:        []string{"TBASE", "INDEX", "#MASK"},
 		VarStrings:      []string{"TBASE", "INDEX", "#MASK"},
 		AddressingMode:  "indexed",
 		Description:     "TABLE INDIRECT JUMP.",
@@ -4830,11 +5235,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE3: Instruction{
+	0xE3: {
 		Mnemonic:        "EBR",
 		ByteLength:      2,
 		VarCount:        1,
@@ -4846,32 +5252,34 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE4: Instruction{
+	0xE4: {
 		Mnemonic:        "EBMOVI",
 		ByteLength:      3,
 		VarCount:        2,
 		VarTypes:        []string{"PTRS", "CNTREG"},
-		VarStrings:      []string{"prt2_reg", "wreg"},
+		VarStrings:      []string{"ptr2_reg", "wreg"},
 		AddressingMode:  "extended-indirect",
 		Description:     "EXTENDED INTERRUPTIBLE BLOCK MOVE.",
 		LongDescription: "Moves a block of word data from one memory location to another. This instruction allows you to move blocks of up to 64K words between any two locations in the 16-Mbyte address space. This instruction is interruptible. The source and destination addresses are calculated using the extended indirect with autoincrement addressing mode. A quadword register (PTRS) addresses the 24-bit pointers, which are stored in adjacent doubleword registers. The source pointer (SRCPTR) is the low double-word and the destination pointer is the high double-word of PTRS. A word register (CNTREG) specifies the number of transfers. This register must reside in the lower register file; it cannot be windowed. The blocks of data can reside anywhere in memory, but should not overlap.",
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE5: Instruction{
+	0xE5: {
 		Mnemonic:   "Reserved",
 		ByteLength: 1,
 		Reserved:   true,
 	},
-	0xE6: Instruction{
+	0xE6: {
 		Mnemonic:        "EJMP",
 		ByteLength:      4,
 		VarCount:        1,
@@ -4883,11 +5291,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE7: Instruction{
+	0xE7: {
 		Mnemonic:        "LJMP",
 		ByteLength:      3,
 		VarCount:        1,
@@ -4899,11 +5308,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryBranch,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE8: Instruction{
+	0xE8: {
 		Mnemonic:        "ELD",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4915,11 +5325,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xE9: Instruction{
+	0xE9: {
 		Mnemonic:        "ELD",
 		ByteLength:      6,
 		VarCount:        2,
@@ -4931,11 +5342,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xEA: Instruction{
+	0xEA: {
 		Mnemonic:        "ELDB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -4947,11 +5359,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xEB: Instruction{
+	0xEB: {
 		Mnemonic:        "ELDB",
 		ByteLength:      6,
 		VarCount:        2,
@@ -4963,11 +5376,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryLoadStore,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xEC: Instruction{
+	0xEC: {
 		Mnemonic:        "DPTS",
 		ByteLength:      1,
 		VarCount:        0,
@@ -4977,11 +5391,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xED: Instruction{
+	0xED: {
 		Mnemonic:        "EPTS",
 		ByteLength:      1,
 		VarCount:        0,
@@ -4991,16 +5406,17 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xEE: Instruction{
+	0xEE: {
 		Mnemonic:   "Reserved",
 		ByteLength: 1,
 		Reserved:   true,
 	},
-	0xEF: Instruction{
+	0xEF: {
 		Mnemonic:        "LCALL",
 		ByteLength:      3,
 		VarCount:        1,
@@ -5012,11 +5428,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF0: Instruction{
+	0xF0: {
 		Mnemonic:        "RET",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5026,11 +5443,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryReturn,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF1: Instruction{
+	0xF1: {
 		Mnemonic:        "ECALL",
 		ByteLength:      4,
 		VarCount:        1,
@@ -5042,11 +5460,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF2: Instruction{
+	0xF2: {
 		Mnemonic:        "PUSHF",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5056,11 +5475,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF3: Instruction{
+	0xF3: {
 		Mnemonic:        "POPF",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5070,11 +5490,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF4: Instruction{
+	0xF4: {
 		Mnemonic:        "PUSHA",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5084,11 +5505,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF5: Instruction{
+	0xF5: {
 		Mnemonic:        "POPA",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5098,25 +5520,29 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF6: Instruction{
+	0xF6: {
 		Mnemonic:        "IDLPD",
-		ByteLength:      1,
-		VarCount:        0,
+		ByteLength:      2,
+		VarCount:        1,
+		VarTypes:        []string{"KEY"},
+		VarStrings:      []string{"#key"},
 		AddressingMode:  "immediate",
 		Description:     "IDLE/POWERDOWN.",
 		LongDescription: "Depending on the 8-bit value of the KEY operand, this instruction causes the device to: \n • enter idle mode, if KEY=1, \n • enter powerdown mode, if KEY=2, \n • execute a reset sequence, \n if KEY > 3. \n The bus controller completes any prefetch cycle in progress before the CPU stops or resets.",
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF7: Instruction{
+	0xF7: {
 		Mnemonic:        "TRAP",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5126,11 +5552,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryCall,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF8: Instruction{
+	0xF8: {
 		Mnemonic:        "CLRC",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5140,11 +5567,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xF9: Instruction{
+	0xF9: {
 		Mnemonic:        "SETC",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5154,11 +5582,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xFA: Instruction{
+	0xFA: {
 		Mnemonic:        "DI",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5168,11 +5597,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xFB: Instruction{
+	0xFB: {
 		Mnemonic:        "EI",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5182,11 +5612,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xFC: Instruction{
+	0xFC: {
 		Mnemonic:        "CLRVT",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5196,11 +5627,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xFD: Instruction{
+	0xFD: {
 		Mnemonic:        "NOP",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5210,11 +5642,12 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0xFE: Instruction{
+	0xFE: {
 		Mnemonic:       "(Note 2) Prefix for signed multiplication and division.",
 		ByteLength:     1,
 		VarCount:       0,
@@ -5225,7 +5658,7 @@ var unsignedInstructions = map[byte]Instruction{
 		Signed:         false,
 		Reserved:       false,
 	},
-	0xFF: Instruction{
+	0xFF: {
 		Mnemonic:        "RST",
 		ByteLength:      1,
 		VarCount:        0,
@@ -5235,14 +5668,15 @@ var unsignedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
 }
 
-var signedInstructions = map[byte]Instruction{
-	0x1C: Instruction{
+var signedInstructions = map[byte]*Opcode{
+	0x1C: {
 		Mnemonic:        "MYSTERY",
 		ByteLength:      4,
 		VarCount:        3,
@@ -5254,11 +5688,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategorySystem,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4C: Instruction{
+	0x4C: {
 		Mnemonic:        "MUL",
 		ByteLength:      4,
 		VarCount:        3,
@@ -5270,11 +5705,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4D: Instruction{
+	0x4D: {
 		Mnemonic:        "MUL",
 		ByteLength:      5,
 		VarCount:        3,
@@ -5286,11 +5722,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4E: Instruction{
+	0x4E: {
 		Mnemonic:        "MUL",
 		ByteLength:      4,
 		VarCount:        3,
@@ -5302,11 +5739,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x4F: Instruction{
+	0x4F: {
 		Mnemonic:        "MUL",
 		ByteLength:      5,
 		VarCount:        3,
@@ -5318,11 +5756,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5C: Instruction{
+	0x5C: {
 		Mnemonic:        "MULB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -5334,11 +5773,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5D: Instruction{
+	0x5D: {
 		Mnemonic:        "MULB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -5350,11 +5790,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5E: Instruction{
+	0x5E: {
 		Mnemonic:        "MULB",
 		ByteLength:      4,
 		VarCount:        3,
@@ -5366,11 +5807,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x5F: Instruction{
+	0x5F: {
 		Mnemonic:        "MULB",
 		ByteLength:      5,
 		VarCount:        3,
@@ -5382,11 +5824,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6C: Instruction{
+	0x6C: {
 		Mnemonic:        "MUL",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5398,11 +5841,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6D: Instruction{
+	0x6D: {
 		Mnemonic:        "MUL",
 		ByteLength:      4,
 		VarCount:        2,
@@ -5414,11 +5858,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6E: Instruction{
+	0x6E: {
 		Mnemonic:        "MUL",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5430,11 +5875,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x6F: Instruction{
+	0x6F: {
 		Mnemonic:        "MUL",
 		ByteLength:      4,
 		VarCount:        2,
@@ -5446,11 +5892,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7C: Instruction{
+	0x7C: {
 		Mnemonic:        "MULB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5462,11 +5909,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7D: Instruction{
+	0x7D: {
 		Mnemonic:        "MULB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5478,11 +5926,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7E: Instruction{
+	0x7E: {
 		Mnemonic:        "MULB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5494,11 +5943,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x7F: Instruction{
+	0x7F: {
 		Mnemonic:        "MULB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -5510,11 +5960,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8C: Instruction{
+	0x8C: {
 		Mnemonic:        "DIV",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5526,11 +5977,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8D: Instruction{
+	0x8D: {
 		Mnemonic:        "DIV",
 		ByteLength:      4,
 		VarCount:        2,
@@ -5542,11 +5994,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8E: Instruction{
+	0x8E: {
 		Mnemonic:        "DIV",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5558,11 +6011,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x8F: Instruction{
+	0x8F: {
 		Mnemonic:        "DIV",
 		ByteLength:      4,
 		VarCount:        2,
@@ -5574,11 +6028,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9C: Instruction{
+	0x9C: {
 		Mnemonic:        "DIVB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5590,11 +6045,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9D: Instruction{
+	0x9D: {
 		Mnemonic:        "DIVB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5606,11 +6062,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9E: Instruction{
+	0x9E: {
 		Mnemonic:        "DIVB",
 		ByteLength:      3,
 		VarCount:        2,
@@ -5622,11 +6079,12 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  false,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
 	},
-	0x9F: Instruction{
+	0x9F: {
 		Mnemonic:        "DIVB",
 		ByteLength:      4,
 		VarCount:        2,
@@ -5638,6 +6096,7 @@ var signedInstructions = map[byte]Instruction{
 		VariableLength:  true,
 		AutoIncrement:   false,
 		Flags:           Flags{},
+		Category:        CategoryArithmetic,
 		Ignore:          false,
 		Signed:          false,
 		Reserved:        false,
@@ -0,0 +1,187 @@
+package disasm
+
+import "strings"
+
+// PSW is a set of program status word flags, using the bit positions of the PSW high byte
+type PSW byte
+
+const (
+	PSW_ST PSW = 0x01 // sticky bit, set when a 1 is shifted through the carry
+	PSW_I  PSW = 0x02 // global interrupt enable
+	PSW_C  PSW = 0x08 // carry
+	PSW_VT PSW = 0x10 // overflow trap, sticky
+	PSW_V  PSW = 0x20 // overflow
+	PSW_N  PSW = 0x40 // negative
+	PSW_Z  PSW = 0x80 // zero
+)
+
+const pswAll = PSW_Z | PSW_N | PSW_V | PSW_VT | PSW_C | PSW_I | PSW_ST
+
+var pswNames = []struct {
+	flag PSW
+	name string
+}{
+	{PSW_Z, "Z"}, {PSW_N, "N"}, {PSW_C, "C"}, {PSW_V, "V"}, {PSW_VT, "VT"}, {PSW_ST, "ST"}, {PSW_I, "I"},
+}
+
+func (p PSW) String() string {
+	var names []string
+	for _, n := range pswNames {
+		if p&n.flag != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// Flags is what an instruction does to the PSW
+type Flags struct {
+	Writes PSW // set or cleared by the result (VT and ST only ever get set this way)
+	Clears PSW // always cleared
+	Sets   PSW // always set
+	Tests  PSW // read by a conditional jump
+}
+
+// Changes is every flag the instruction can change
+func (f Flags) Changes() PSW {
+	return f.Writes | f.Clears | f.Sets
+}
+
+var arithFlags = Flags{Writes: PSW_Z | PSW_N | PSW_C | PSW_V | PSW_VT}
+var logicFlags = Flags{Writes: PSW_Z | PSW_N, Clears: PSW_C | PSW_V}
+
+var flagEffects = map[string]Flags{
+	"ADD":   arithFlags,
+	"ADDB":  arithFlags,
+	"ADDC":  arithFlags, // Z is only ever cleared, so a multi-word result tests as a whole
+	"ADDCB": arithFlags,
+	"SUB":   arithFlags,
+	"SUBB":  arithFlags,
+	"SUBC":  arithFlags,
+	"SUBCB": arithFlags,
+	"CMP":   arithFlags,
+	"CMPB":  arithFlags,
+	"CMPL":  arithFlags,
+	"NEG":   arithFlags,
+	"NEGB":  arithFlags,
+	"INC":   arithFlags,
+	"INCB":  arithFlags,
+	"DEC":   arithFlags,
+	"DECB":  arithFlags,
+
+	"AND":  logicFlags,
+	"ANDB": logicFlags,
+	"OR":   logicFlags,
+	"ORB":  logicFlags,
+	"XOR":  logicFlags,
+	"XORB": logicFlags,
+	"NOT":  logicFlags,
+	"NOTB": logicFlags,
+	"EXT":  logicFlags,
+	"EXTB": logicFlags,
+
+	"CLR":  {Sets: PSW_Z, Clears: PSW_N | PSW_C | PSW_V},
+	"CLRB": {Sets: PSW_Z, Clears: PSW_N | PSW_C | PSW_V},
+
+	"DIV":   {Writes: PSW_V | PSW_VT},
+	"DIVB":  {Writes: PSW_V | PSW_VT},
+	"DIVU":  {Writes: PSW_V | PSW_VT},
+	"DIVUB": {Writes: PSW_V | PSW_VT},
+
+	"SHL":   {Writes: PSW_Z | PSW_N | PSW_C | PSW_V | PSW_VT},
+	"SHLB":  {Writes: PSW_Z | PSW_N | PSW_C | PSW_V | PSW_VT},
+	"SHLL":  {Writes: PSW_Z | PSW_N | PSW_C | PSW_V | PSW_VT},
+	"SHR":   {Writes: PSW_Z | PSW_C | PSW_ST, Clears: PSW_N | PSW_V},
+	"SHRB":  {Writes: PSW_Z | PSW_C | PSW_ST, Clears: PSW_N | PSW_V},
+	"SHRL":  {Writes: PSW_Z | PSW_C | PSW_ST, Clears: PSW_N | PSW_V},
+	"SHRA":  {Writes: PSW_Z | PSW_N | PSW_C | PSW_ST, Clears: PSW_V},
+	"SHRAB": {Writes: PSW_Z | PSW_N | PSW_C | PSW_ST, Clears: PSW_V},
+	"SHRAL": {Writes: PSW_Z | PSW_N | PSW_C | PSW_ST, Clears: PSW_V},
+	"NORML": {Writes: PSW_Z | PSW_N, Clears: PSW_C},
+
+	"SETC":  {Sets: PSW_C},
+	"CLRC":  {Clears: PSW_C},
+	"CLRVT": {Clears: PSW_VT},
+	"DI":    {Clears: PSW_I},
+	"EI":    {Sets: PSW_I},
+
+	// The whole PSW is saved and cleared, or loaded
+	"PUSHF": {Clears: pswAll},
+	"PUSHA": {Clears: pswAll},
+	"POPF":  {Writes: pswAll},
+	"POPA":  {Writes: pswAll},
+	"RST":   {Clears: pswAll},
+
+	"JC":   {Tests: PSW_C},
+	"JNC":  {Tests: PSW_C},
+	"JE":   {Tests: PSW_Z},
+	"JNE":  {Tests: PSW_Z},
+	"JGE":  {Tests: PSW_N},
+	"JLT":  {Tests: PSW_N},
+	"JGT":  {Tests: PSW_Z | PSW_N},
+	"JLE":  {Tests: PSW_Z | PSW_N},
+	"JH":   {Tests: PSW_C | PSW_Z},
+	"JNH":  {Tests: PSW_C | PSW_Z},
+	"JV":   {Tests: PSW_V},
+	"JNV":  {Tests: PSW_V},
+	"JVT":  {Tests: PSW_VT, Clears: PSW_VT},
+	"JNVT": {Tests: PSW_VT, Clears: PSW_VT},
+	"JST":  {Tests: PSW_ST},
+	"JNST": {Tests: PSW_ST},
+}
+
+// FlagSource finds the instruction that set the flags the conditional jump at
+// an.Opcodes[index] tests - the CMP feeding a JGT, say. It only walks back through
+// straight line code and stops at anything that is jumped or called to, since the flags
+// could come from another path. ok is false when there's no single source.
+func (an *Analysis) FlagSource(index int) (int, bool) {
+	if index <= 0 || index >= len(an.Opcodes) {
+		return 0, false
+	}
+
+	tests := an.Opcodes[index].Flags.Tests
+	if tests == 0 {
+		return 0, false
+	}
+
+	for i := index - 1; i >= 0; i-- {
+		prev := an.Opcodes[i]
+		next := an.Opcodes[i+1]
+
+		// A gap, or flow joining from elsewhere
+		if prev.Address+prev.ByteLength != next.Address || an.Jumps[next.Address] != nil || an.Subroutines[next.Address] != nil {
+			return 0, false
+		}
+
+		if prev.Flags.Changes()&tests != 0 {
+			return i, true
+		}
+
+		// Anything that leaves the straight line
+		switch strings.TrimPrefix(prev.Mnemonic, "SGN ") {
+		case "SJMP", "LJMP", "EJMP", "BR", "EBR", "TIJMP", "RET", "SCALL", "LCALL", "ECALL", "TRAP":
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// Rewrites the pseudo code of each conditional jump as the comparison it makes, given the
+// instruction its flags come from, "if (R_40 > 0x5E) goto 0x1234" after a CMP rather than
+// "if (!N && !Z) goto 0x1234". A jump with no single source, or one whose source doesn't
+// say what was compared (a JC after an ADD), keeps its flag test.
+func (an *Analysis) pairBranches() {
+	for i := range an.Opcodes {
+		src, ok := an.FlagSource(i)
+		if !ok {
+			continue
+		}
+		jump := &an.Opcodes[i]
+		cond := flagCondition(an.Opcodes[src], strings.TrimPrefix(jump.Mnemonic, "SGN "))
+		if cond.op == "" {
+			continue
+		}
+		jump.branch = cond
+		jump.PseudoCode = jump.statement(dialects[PseudoC])
+	}
+}
@@ -0,0 +1,225 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Register windows. Writing the Window Selection Register (WSR, 0x14) maps a 32, 64 or
+	128 byte slice of register RAM or the SFRs onto the top of the lower register file, so
+	a direct access to R_E2 might really be AD_RESULT. The WSR value picks the size and
+	the slice:
+
+		0x40-0x7F	32 bytes at 0xE0-0xFF
+		0x20-0x3F	64 bytes at 0xC0-0xFF
+		0x10-0x1F	128 bytes at 0x80-0xFF
+
+	The low bits count slices up from 0x0000, slices past the register RAM (0x400) are the
+	SFRs at 0x1C00-0x1FFF. Only direct register accesses go through the window, indirect
+	and indexed accesses reach the physical register file.
+*/
+
+const wsrReg = 0x14
+
+// WindowAddress is the physical address a direct access to reg reaches with wsr in the
+// Window Selection Register, false if reg isn't in the window
+func WindowAddress(wsr, reg int) (int, bool) {
+	wsr &= 0x7F
+
+	var size int
+	switch {
+	case wsr >= 0x40:
+		size = 0x20
+	case wsr >= 0x20:
+		size = 0x40
+	case wsr >= 0x10:
+		size = 0x80
+	default:
+		return reg, false
+	}
+
+	top := 0x100 - size
+	if reg < top || reg > 0xFF {
+		return reg, false
+	}
+
+	adr := (wsr&(0x800/size-1))*size + reg - top
+	if adr >= 0x400 {
+		adr += 0x1800
+	}
+	return adr, true
+}
+
+// Name for a windowed register, by what it really is
+func windowName(adr int) string {
+	if r, ok := RegObjs[adr]; ok {
+		return strings.TrimSpace(r.Mnemonic)
+	}
+	if adr >= 0x1C00 {
+		return fmt.Sprintf("SFR_%04X", adr)
+	}
+	return fmt.Sprintf("R_%X", adr)
+}
+
+// XRef text for a windowed register, its name and the way it was reached
+func windowXRef(adr, reg, wsr int) string {
+	via := fmt.Sprintf("R_%02X through WSR 0x%02X", reg, wsr)
+	if r, ok := RegObjs[adr]; ok {
+		return fmt.Sprintf("%s ~( %s, %s )", windowName(adr), strings.TrimSpace(r.Description), via)
+	}
+	if adr >= 0x1C00 {
+		return fmt.Sprintf("%s ~( %s )", windowName(adr), via)
+	}
+	return fmt.Sprintf("%s ~( GP Reg RAM, %s )", windowName(adr), via)
+}
+
+// The WSR value after instr runs, given the value before it. -1 is unknown.
+func windowAfter(instr Instruction, wsr int) int {
+	switch strings.TrimPrefix(instr.Mnemonic, "SGN ") {
+	case "POPA":
+		return -1
+	}
+
+	var dest, src Operand
+	for i, op := range instr.Ops {
+		switch strings.ToUpper(instr.VarTypes[i]) {
+		case "DEST":
+			dest = op
+		case "SRC":
+			src = op
+		}
+	}
+	if r, ok := dest.(RegisterOperand); !ok || r.Reg != wsrReg {
+		return wsr
+	}
+
+	switch instr.Mnemonic {
+	case "CLR", "CLRB":
+		return 0
+	case "LD", "LDB":
+		if v, ok := immediate(src); ok {
+			return v & 0xFF
+		}
+	}
+	return -1
+}
+
+// Follows the WSR from the reset and interrupt entries through jumps and calls, and renames
+// the windowed operands of every instruction where only one window can be in effect.
+// Subroutines are taken to hand the WSR back the way they got it.
+func (h *DisAsm) trackWindows(an *Analysis, roots []int) {
+	state := make(map[int]int)
+	var todo []int
+
+	enter := func(adr, wsr int) {
+		if old, ok := state[adr]; ok {
+			if old == wsr || old == -1 {
+				return
+			}
+			wsr = -1
+		}
+		state[adr] = wsr
+		todo = append(todo, adr)
+	}
+
+	for _, adr := range roots {
+		enter(adr, 0)
+	}
+
+	for len(todo) > 0 {
+		adr := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+
+		instr, ok := an.instruction(adr)
+		if !ok {
+			continue
+		}
+		wsr := windowAfter(instr, state[adr])
+
+		targets, falls := flow(instr)
+		if strings.TrimPrefix(instr.Mnemonic, "SGN ") == "TIJMP" {
+			targets = h.switchTargets(an, instr)
+		}
+		for _, t := range targets {
+			enter(t, wsr)
+		}
+		for t := range instr.Calls {
+			enter(t, wsr)
+		}
+		if falls {
+			enter(adr+instr.ByteLength, wsr)
+		}
+	}
+
+	for i := range an.Opcodes {
+		if wsr := state[an.Opcodes[i].Address]; wsr > 0 {
+			an.Opcodes[i].window(wsr, an.XRefs, h.MemoryMap())
+		}
+	}
+}
+
+// Renames the operands reached through the window and moves their XRefs to the physical
+// registers
+func (instr *Instruction) window(wsr int, xrefs map[int][]XRef, memory *MemoryMap) {
+	changed := false
+
+	for i, op := range instr.Ops {
+		switch op := op.(type) {
+		case RegisterOperand:
+			if op.Window == 0 && instr.windowed(op.Reg, wsr, xrefs, memory) {
+				op.Window = wsr
+				instr.Ops[i] = op
+				changed = true
+			}
+		case IndirectOperand:
+			if op.Window == 0 && instr.windowed(op.Reg, wsr, xrefs, memory) {
+				op.Window = wsr
+				instr.Ops[i] = op
+				changed = true
+			}
+		case IndexedOperand:
+			if op.Window == 0 && instr.windowed(op.Reg, wsr, xrefs, memory) {
+				op.Window = wsr
+				instr.Ops[i] = op
+				changed = true
+			}
+		}
+	}
+
+	if changed {
+		instr.Window = wsr
+		instr.doPseudo()
+	}
+}
+
+// Whether reg is in the window, its XRef moves to the register it reaches if so
+func (instr *Instruction) windowed(reg, wsr int, xrefs map[int][]XRef, memory *MemoryMap) bool {
+	adr, ok := WindowAddress(wsr, reg)
+	if !ok {
+		return false
+	}
+	moveXRef(xrefs, instr.Address, reg, XRef{String: windowXRef(adr, reg, wsr), Mnemonic: instr.Mnemonic, XRefFrom: instr.Address, XRefTo: adr, Kind: memory.Kind(adr)})
+	return true
+}
+
+func moveXRef(xrefs map[int][]XRef, from, reg int, to XRef) {
+	var kept []XRef
+	for _, x := range xrefs[reg] {
+		if x.XRefFrom != from {
+			kept = append(kept, x)
+		}
+	}
+	if len(kept) == 0 {
+		delete(xrefs, reg)
+	} else {
+		xrefs[reg] = kept
+	}
+
+	for _, x := range xrefs[to.XRefTo] {
+		if x.XRefFrom == from {
+			return
+		}
+	}
+	xrefs[to.XRefTo] = append(xrefs[to.XRefTo], to)
+}
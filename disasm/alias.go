@@ -0,0 +1,55 @@
+package disasm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+/*
+	Register aliases. Names for what a register holds once it's worked out, R_40 = rpm,
+	shown in place of the register everywhere one is printed: the operands and pseudo code
+	of the listing, the HTML and the export, and the decompiler's output. A register
+	reached through the window is aliased by the register it really is, R_140 and not the
+	R_40 it was encoded as. The aliases are kept in the project with its symbols and
+	comments.
+*/
+
+// RegisterAliases are names for registers, by register address
+type RegisterAliases map[int]string
+
+// A register the way operands print one
+var registerText = regexp.MustCompile(`\bR_([0-9A-F]{2,4})\b`)
+
+var aliasName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// CheckAlias makes sure a name can stand in for a register, an identifier that doesn't
+// look like one
+func CheckAlias(name string) error {
+	if !aliasName.MatchString(name) || registerText.MatchString(name) {
+		return fmt.Errorf("%s isn't a valid register alias", name)
+	}
+	return nil
+}
+
+// Apply writes every register in text that has an alias as its alias
+func (a RegisterAliases) Apply(text string) string {
+	if len(a) == 0 {
+		return text
+	}
+	return registerText.ReplaceAllStringFunc(text, func(reg string) string {
+		adr, err := strconv.ParseInt(reg[2:], 16, 32)
+		if err != nil {
+			return reg
+		}
+		if name, ok := a[int(adr)]; ok {
+			return name
+		}
+		return reg
+	})
+}
+
+// SetRegisterAliases names registers for the listing, HTML, export and decompiler
+func (h *DisAsm) SetRegisterAliases(a RegisterAliases) {
+	h.aliases = a
+}
@@ -0,0 +1,133 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StringRun is a run of printable ASCII in the data, a part number, VIN mask or calibration ID
+type StringRun struct {
+	Address int
+	Text    string
+}
+
+// Strings finds runs of at least minLen text characters (letters, digits, spaces and the
+// punctuation part numbers use) in the data regions. Calibration tables are full of bytes
+// that happen to be printable, so a run has to end the way strings do and runs that look
+// like a table are left out: mostly not letters and digits, a character repeated, rising or
+// falling all the way, or lower case running into upper case mid word.
+func (h *DisAsm) Strings(an *Analysis, minLen int) []StringRun {
+	var found []StringRun
+	for _, r := range h.regions(an) {
+		if r.Class != "data" {
+			continue
+		}
+
+		start := r.Start
+		for adr := r.Start; adr <= r.Stop+1; adr++ {
+			if adr <= r.Stop && textChar(h.block[adr]) {
+				continue
+			}
+			if text := string(h.block[start:adr]); len(text) >= minLen && terminated(h.block, adr) && plausibleText(text) {
+				found = append(found, StringRun{Address: start, Text: text})
+			}
+			start = adr + 1
+		}
+	}
+	return found
+}
+
+// Strings in the image end with a NUL or run into erased (0xFF) fill, table bytes that
+// happen to be text run on into more table
+func terminated(block []byte, adr int) bool {
+	return adr >= len(block) || block[adr] == 0x00 || block[adr] == 0xFF
+}
+
+func textChar(b byte) bool {
+	return isAlnum(b) || strings.IndexByte(" .,-_*/:#()&+'", b) >= 0
+}
+
+func isAlnum(b byte) bool {
+	return b >= '0' && b <= '9' || isUpper(b) || isLower(b)
+}
+
+func isUpper(b byte) bool {
+	return b >= 'A' && b <= 'Z'
+}
+
+func isLower(b byte) bool {
+	return b >= 'a' && b <= 'z'
+}
+
+func plausibleText(text string) bool {
+	alnum, letters, run := 0, 0, 1
+	rising, falling := true, true
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if isAlnum(c) {
+			alnum++
+		}
+		if isUpper(c) || isLower(c) {
+			letters++
+		}
+		if i == 0 {
+			continue
+		}
+
+		prev := text[i-1]
+		if c == prev && c != ' ' {
+			if run++; run > 3 {
+				return false
+			}
+		} else {
+			run = 1
+		}
+		if isLower(prev) && isUpper(c) {
+			return false
+		}
+		rising = rising && c >= prev
+		falling = falling && c <= prev
+	}
+	return letters >= 2 && alnum*3 >= len(text)*2 && !rising && !falling
+}
+
+// SetStrings has the listing show these runs as quoted strings
+func (h *DisAsm) SetStrings(runs []StringRun) {
+	h.strings = make(map[int]string)
+	for _, s := range runs {
+		h.strings[s.Address] = s.Text
+	}
+}
+
+// DCB lines for a string, characters the assembler would split on are left as bytes
+func (h *DisAsm) stringLines(adr int, text string) []string {
+	var lines []string
+	for len(text) > 0 {
+		n := len(text)
+		if n > 48 {
+			n = 48
+		}
+
+		var ops []string
+		quoted := ""
+		for _, c := range text[:n] {
+			if strings.ContainsRune(`,;"`, c) {
+				if quoted != "" {
+					ops = append(ops, `"`+quoted+`"`)
+					quoted = ""
+				}
+				ops = append(ops, fmt.Sprintf("0x%02X", c))
+				continue
+			}
+			quoted += string(c)
+		}
+		if quoted != "" {
+			ops = append(ops, `"`+quoted+`"`)
+		}
+
+		lines = append(lines, addSpaces(fmt.Sprintf("[0x%X]", adr), 20)+addSpaces(string(DataBytes), 6)+strings.Join(ops, ", "))
+		adr += n
+		text = text[n:]
+	}
+	return lines
+}
@@ -0,0 +1,82 @@
+package disasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	Bit names. Most of the decisions the code makes are a JBC or JBS on one bit of a flag
+	register, and it's the bits that mean something, R_11.3 = closed_loop_flag. A bit with
+	a name shows as the name in the pseudo code of the jumps that test it, in the listing,
+	the HTML, the export and the decompiler: if (!closed_loop_flag) goto 0x13A2F0. The
+	names are symbols of kind bit in the project, renamed and checked like any other.
+*/
+
+// RegisterBit is one bit of a byte register, R_11.3
+type RegisterBit struct {
+	Reg int
+	Bit int
+}
+
+func (b RegisterBit) String() string {
+	return fmt.Sprintf("R_%02X.%d", b.Reg, b.Bit)
+}
+
+// ParseRegisterBit reads a bit as a register the way ParseAddress takes one, a dot and
+// the bit number, R_11.3
+func ParseRegisterBit(s string) (RegisterBit, error) {
+	i := strings.LastIndex(s, ".")
+	if i < 0 {
+		return RegisterBit{}, fmt.Errorf("Bad bit, no bit number: %s", s)
+	}
+	reg, err := ParseAddress(s[:i])
+	if err != nil {
+		return RegisterBit{}, err
+	}
+	bit, err := strconv.Atoi(s[i+1:])
+	if err != nil || bit < 0 || bit > 7 {
+		return RegisterBit{}, fmt.Errorf("Bad bit number, 0 to 7: %s", s)
+	}
+	return RegisterBit{Reg: reg, Bit: bit}, nil
+}
+
+// BitNames are names for bits of registers
+type BitNames map[RegisterBit]string
+
+// SetBitNames names register bits for the listing, HTML, export and decompiler
+func (h *DisAsm) SetBitNames(b BitNames) {
+	h.bits = b
+}
+
+// The name of the bit a JBC or JBS tests and whether it jumps when the bit is set
+func (h *DisAsm) testedBit(instr Instruction) (string, bool, bool) {
+	if instr.Mnemonic != "JBC" && instr.Mnemonic != "JBS" || len(instr.Ops) < 2 {
+		return "", false, false
+	}
+	reg, ok := instr.Ops[0].(RegisterOperand)
+	bit, isBit := instr.Ops[1].(BitOperand)
+	if !ok || !isBit {
+		return "", false, false
+	}
+	name, ok := h.bits[RegisterBit{Reg: regAddress(reg.Reg, reg.Window), Bit: bit.Bit}]
+	return name, instr.Mnemonic == "JBS", ok
+}
+
+// The pseudo code of an instruction the way the listings show it, with the names of the
+// bits and registers that have one
+func (h *DisAsm) pseudo(instr Instruction) string {
+	if name, set, ok := h.testedBit(instr); ok {
+		d, known := dialects[h.pseudoStyle]
+		if !known {
+			d = dialects[PseudoC]
+		}
+		cond := fmt.Sprintf(d.flagClear, name)
+		if set {
+			cond = fmt.Sprintf(d.flagSet, name)
+		}
+		return h.aliases.Apply(fmt.Sprintf(d.ifGoto, cond, instr.pseudoOperands()["ADDR"]))
+	}
+	return h.aliases.Apply(instr.Pseudo(h.pseudoStyle))
+}
@@ -0,0 +1,88 @@
+package disasm
+
+import "fmt"
+
+/*
+	Breakpoints, watchpoints and conditional stops. Step returns a *Stop instead of running
+	on when the emulator reaches a breakpoint, when an instruction reads or writes a
+	watched address, or when a StopWhen condition holds after an instruction. A breakpoint
+	stops before its instruction runs, and the next Step runs it. A watchpoint or condition
+	stops after the instruction, with PC at the one that follows. Either way Run carries on
+	from there, so a routine can be run up to the first time it touches a calibration
+	address, looked at and let go.
+
+	Addresses are image addresses, a register or 16 bit data address is taken as the code
+	sees it and mapped to the data page. Fetching instructions doesn't count as a read.
+*/
+
+// Stop is why the emulator stopped, returned by Step, Call and Run
+type Stop struct {
+	PC      int    // the instruction that stopped it
+	Reason  string // breakpoint, watchpoint or condition
+	Address int    // the watched address touched
+	Access  Access // how
+}
+
+func (s *Stop) Error() string {
+	if s.Reason == "watchpoint" {
+		return fmt.Sprintf("Stopped at 0x%X, %s 0x%X (%s)", s.PC, s.Reason, s.Address, s.Access)
+	}
+	return fmt.Sprintf("Stopped at 0x%X, %s", s.PC, s.Reason)
+}
+
+// Break stops the emulator before it runs the instruction at adr
+func (e *Emulator) Break(adr int) {
+	if e.breaks == nil {
+		e.breaks = make(map[int]bool)
+	}
+	e.breaks[adr] = true
+}
+
+// Watch stops the emulator after an instruction that touches any of width bytes from adr
+// the way access says, AccessRead, AccessWrite or both
+func (e *Emulator) Watch(adr, width int, access Access) {
+	if adr <= 0xFFFF {
+		adr = e.Data16(adr)
+	}
+	if e.watches == nil {
+		e.watches = make(map[int]Access)
+	}
+	for i := 0; i < width; i++ {
+		e.watches[adr+i] |= access
+	}
+}
+
+// StopWhen stops the emulator after an instruction that leaves cond true
+func (e *Emulator) StopWhen(cond func(e *Emulator) bool) {
+	e.conds = append(e.conds, cond)
+}
+
+// ClearStops removes every breakpoint, watchpoint and condition
+func (e *Emulator) ClearStops() {
+	e.breaks, e.watches, e.conds = nil, nil, nil
+}
+
+// Notes the first watched access of the instruction running
+func (e *Emulator) watch(addr int, access Access) {
+	if !e.inStep || e.hit != nil {
+		return
+	}
+	if e.watches[addr]&access != 0 {
+		e.hit = &Stop{Reason: "watchpoint", Address: addr, Access: access}
+	}
+}
+
+// Whether the instruction at pc, just run, stops the emulator
+func (e *Emulator) stopAfter(pc int) error {
+	if e.hit != nil {
+		s := e.hit
+		s.PC, e.hit = pc, nil
+		return s
+	}
+	for _, cond := range e.conds {
+		if cond(e) {
+			return &Stop{PC: pc, Reason: "condition"}
+		}
+	}
+	return nil
+}
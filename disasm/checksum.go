@@ -0,0 +1,411 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Checksum routines. A ROM checksum is a loop that reads through a pointer it steps
+	along, adds what it reads into a register with ADD or ADDB (and ADDC or ADDCB into
+	the register above for a long sum), and after the loop compares the sum with the
+	stored checksum. Each loop that jumps back is checked for that: the read is an add
+	straight from [ptr] or [ptr]+, or a load from there into a register the loop then
+	adds. The summed range starts at the pointer's value going into the loop, from the
+	constants in the straight line code before it, and ends at the bound the loop
+	compares the pointer with, or after the count a DJNZ loop runs, and loops that turn
+	out to sum RAM are left out. The stored checksum is what the first compare of the
+	sum after the loop reads, directly or loaded into a register first. Compute and Fix
+	work the sum out again for a patched image.
+*/
+
+// ChecksumRoutine is a loop that sums the image
+type ChecksumRoutine struct {
+	Head        int  // top of the loop
+	Tail        int  // the jump back
+	Pointer     int  // register
+	Sum         int  // register
+	Width       int  // bytes read each time round
+	SumWidth    int  // bytes the sum is kept in
+	Start       int  // summed range, image addresses, -1 when not known
+	Stop        int  // last address summed
+	Compare     int  // where the sum is checked, -1 if it isn't found
+	Stored      int  // the stored checksum, -1 when the sum is compared with a constant or not at all
+	StoredWidth int  // bytes the compare reads
+	Expected    int  // the constant, when it's compared with one
+	extended    bool // a 24 bit pointer
+}
+
+func (c ChecksumRoutine) String() string {
+	s := fmt.Sprintf("loop 0x%X-0x%X adds the %s at %s into a %d byte sum in %s", c.Head, c.Tail, dataUnits[c.Width], regString(c.Pointer, 0), c.SumWidth, regString(c.Sum, 0))
+	if c.Start >= 0 {
+		s += fmt.Sprintf(", range 0x%X-0x%X", c.Start, c.Stop)
+	}
+	switch {
+	case c.Stored >= 0:
+		s += fmt.Sprintf(", checked at 0x%X against 0x%X", c.Compare, c.Stored)
+	case c.Compare >= 0:
+		s += fmt.Sprintf(", checked at 0x%X against #%0*X", c.Compare, c.StoredWidth*2, c.Expected)
+	}
+	return s
+}
+
+var dataUnits = map[int]string{1: "bytes", 2: "words"}
+
+type checksumRoutines []ChecksumRoutine
+
+func (c checksumRoutines) Len() int {
+	return len(c)
+}
+
+func (c checksumRoutines) Less(i, j int) bool {
+	return c[i].Head < c[j].Head
+}
+
+func (c checksumRoutines) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// How far past the loop to look for the compare of the sum
+const checksumCompareReach = 8
+
+// Checksums finds the loops that look like checksum routines, in address order
+func (h *DisAsm) Checksums(an *Analysis) []ChecksumRoutine {
+	memory := h.MemoryMap()
+
+	// The last jump back to each loop head
+	tails := make(map[int]int)
+	for i := range an.Opcodes {
+		for adr := range an.Opcodes[i].Jumps {
+			if adr <= an.Opcodes[i].Address {
+				tails[adr] = i
+			}
+		}
+	}
+
+	counts := make(map[int]Loop)
+	for _, l := range an.Loops() {
+		counts[l.DJNZ] = l
+	}
+
+	var found []ChecksumRoutine
+	for head, tail := range tails {
+		start, ok := an.index(head)
+		if !ok {
+			continue
+		}
+		c, ok := an.checksumLoop(start, tail)
+		if !ok {
+			continue
+		}
+		an.checksumRange(&c, start, tail, counts, memory)
+		if kind := memory.Kind(c.Start); c.Start >= 0 && kind != KindROM && kind != KindExternal {
+			continue // summing RAM
+		}
+		an.checksumCompare(&c, tail, memory)
+		found = append(found, c)
+	}
+
+	sort.Sort(checksumRoutines(found))
+	return found
+}
+
+// The read through a stepped pointer and the add of it in the loop an.Opcodes[start:tail+1]
+func (an *Analysis) checksumLoop(start, tail int) (ChecksumRoutine, bool) {
+	body := an.Opcodes[start : tail+1]
+	for i := 1; i < len(body); i++ {
+		if body[i-1].Address+body[i-1].ByteLength != body[i].Address {
+			return ChecksumRoutine{}, false // not one loop
+		}
+	}
+
+	loaded := make(map[int]IndirectOperand) // registers loaded through a pointer
+	widths := make(map[int]int)
+	modes := make(map[int]string)
+	for i := range body {
+		instr := &body[i]
+		m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+		if len(instr.Ops) < 2 {
+			continue
+		}
+		dest, ok := instr.Ops[0].(RegisterOperand)
+		if !ok || dest.Window != 0 {
+			continue
+		}
+
+		switch m {
+		case "LD", "LDB", "LDBZE", "ELD", "ELDB":
+			if ptr, ok := instr.Ops[1].(IndirectOperand); ok && ptr.Window == 0 {
+				loaded[dest.Reg], widths[dest.Reg], modes[dest.Reg] = ptr, width(m), instr.AddressingMode
+			} else {
+				delete(loaded, dest.Reg)
+			}
+			continue
+		case "ADD", "ADDB":
+		default:
+			continue
+		}
+
+		// ADD sum, [ptr] or ADD sum, loaded, and not the three operand form into another register
+		src := instr.Ops[len(instr.Ops)-1]
+		if len(instr.Ops) == 3 {
+			if r, ok := instr.Ops[1].(RegisterOperand); !ok || r.Reg != dest.Reg {
+				continue
+			}
+		}
+		ptr, ok := src.(IndirectOperand)
+		w, mode := width(m), instr.AddressingMode
+		if r, isReg := src.(RegisterOperand); isReg {
+			ptr, ok = loaded[r.Reg]
+			w, mode = widths[r.Reg], modes[r.Reg]
+		}
+		if !ok || ptr.Window != 0 || ptr.Reg == dest.Reg || !steps(body, ptr) {
+			continue
+		}
+
+		c := ChecksumRoutine{
+			Head: body[0].Address, Tail: body[len(body)-1].Address,
+			Pointer: ptr.Reg, Sum: dest.Reg, Width: w, SumWidth: width(m),
+			Start: -1, Stop: -1, Compare: -1, Stored: -1,
+			extended: strings.HasPrefix(mode, "extended"),
+		}
+		if carriesInto(body, c.Sum+c.SumWidth, c.SumWidth) {
+			c.SumWidth *= 2
+		}
+		return c, true
+	}
+	return ChecksumRoutine{}, false
+}
+
+// Whether the loop moves the pointer on, by auto-increment or an INC or ADD of a constant
+func steps(body Instructions, ptr IndirectOperand) bool {
+	if ptr.AutoIncrement {
+		return true
+	}
+	return pointerStep(body, ptr.Reg) != 0
+}
+
+// What an INC or ADD of a constant in the loop moves the pointer on by, 0 for neither
+func pointerStep(body Instructions, reg int) int {
+	for i := range body {
+		instr := &body[i]
+		if len(instr.Ops) == 0 {
+			continue
+		}
+		if r, ok := instr.Ops[0].(RegisterOperand); !ok || r.Reg != reg || r.Window != 0 {
+			continue
+		}
+		switch instr.Mnemonic {
+		case "INC":
+			return 1
+		case "ADD":
+			if n, ok := immediate(instr.Ops[len(instr.Ops)-1]); ok && len(instr.Ops) == 2 {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// Whether an ADDC or ADDCB of width bytes adds the carry into reg in the loop
+func carriesInto(body Instructions, reg, w int) bool {
+	for i := range body {
+		instr := &body[i]
+		if m := instr.Mnemonic; (m == "ADDC" || m == "ADDCB") && width(m) == w && len(instr.Ops) > 0 {
+			if r, ok := instr.Ops[0].(RegisterOperand); ok && r.Reg == reg && r.Window == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Fills in the range from the pointer's value going into the loop and the loop's bound
+func (an *Analysis) checksumRange(c *ChecksumRoutine, start, tail int, counts map[int]Loop, memory *MemoryMap) {
+	first := start
+	for first > 0 && an.fallsInto(first-1, c.Head) {
+		first--
+	}
+	s := regState{}
+	for _, instr := range an.Opcodes[first:start] {
+		s.step(instr, nil)
+	}
+	ptr, ok := s.word(c.Pointer)
+	if !ok {
+		return
+	}
+	if c.extended {
+		page, ok := s.word(c.Pointer + 2)
+		if !ok {
+			return
+		}
+		ptr |= page << 16
+	}
+
+	step := pointerStep(an.Opcodes[start:tail+1], c.Pointer)
+	if step == 0 {
+		step = c.Width
+	}
+
+	// CMP ptr, #bound in the loop, or the count of a DJNZ loop
+	end := -1
+	for _, instr := range an.Opcodes[start : tail+1] {
+		if instr.Mnemonic != "CMP" || len(instr.Ops) != 2 {
+			continue
+		}
+		if r, ok := instr.Ops[0].(RegisterOperand); ok && r.Reg == c.Pointer && r.Window == 0 {
+			if n, ok := immediate(instr.Ops[1]); ok {
+				end = ptr&^0xFFFF | n
+			}
+		}
+	}
+	if l, ok := counts[an.Opcodes[tail].Address]; ok && end < 0 && l.Count > 0 {
+		end = ptr + l.Count*step
+	}
+	if end <= ptr {
+		return
+	}
+
+	c.Start = memory.Resolve(ptr)
+	c.Stop = c.Start + end - ptr - 1
+}
+
+// Finds the first compare of the sum after the loop and what it's compared with
+func (an *Analysis) checksumCompare(c *ChecksumRoutine, tail int, memory *MemoryMap) {
+	loads := make(map[int]int) // registers loaded from a fixed address, and the address
+	for i := tail + 1; i < len(an.Opcodes) && i <= tail+checksumCompareReach; i++ {
+		instr := &an.Opcodes[i]
+		if i > tail+1 && an.Opcodes[i-1].Address+an.Opcodes[i-1].ByteLength != instr.Address {
+			return
+		}
+		if len(instr.Ops) < 2 {
+			continue
+		}
+		m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+		dest, ok := instr.Ops[0].(RegisterOperand)
+		if !ok || dest.Window != 0 {
+			continue
+		}
+
+		other := instr.Ops[len(instr.Ops)-1]
+		switch m {
+		case "LD", "LDB", "ELD", "ELDB":
+			if x, ok := other.(IndexedOperand); ok && x.Reg == 0 {
+				loads[dest.Reg] = memory.Resolve(x.Offset)
+			}
+			continue
+		case "CMP", "CMPB", "CMPL":
+		default:
+			continue
+		}
+
+		// Either way round
+		if dest.Reg != c.Sum {
+			if r, ok := other.(RegisterOperand); ok && r.Reg == c.Sum && r.Window == 0 {
+				other = dest
+			} else {
+				continue
+			}
+		}
+
+		c.Compare, c.StoredWidth = instr.Address, operandWidth(m, "DEST")
+		switch o := other.(type) {
+		case ImmediateOperand:
+			c.Expected = o.Value
+		case IndexedOperand:
+			if o.Reg == 0 {
+				c.Stored = memory.Resolve(o.Offset)
+			}
+		case RegisterOperand:
+			if adr, ok := loads[o.Reg]; ok {
+				c.Stored = adr
+			}
+		}
+		return
+	}
+}
+
+// Compute works out the sum over the range of an image
+func (c ChecksumRoutine) Compute(image []byte) (int, error) {
+	if c.Start < 0 || c.Stop >= len(image) {
+		return 0, fmt.Errorf("Checksum at 0x%X: the summed range isn't known or is past the end of the image", c.Head)
+	}
+	sum := 0
+	for adr := c.Start; adr+c.Width <= c.Stop+1; adr += c.Width {
+		v := int(image[adr])
+		if c.Width == 2 {
+			v |= int(image[adr+1]) << 8
+		}
+		sum += v
+	}
+	return sum & (1<<uint(8*c.SumWidth) - 1), nil
+}
+
+// Fix stores the sum of a patched image where the routine checks it, as many bytes of it as
+// the compare reads
+func (c ChecksumRoutine) Fix(image []byte) error {
+	if c.Stored < 0 || c.Stored+c.StoredWidth > len(image) {
+		return fmt.Errorf("Checksum at 0x%X: no stored checksum to update", c.Head)
+	}
+	if c.Stored+c.StoredWidth > c.Start && c.Stored <= c.Stop {
+		return fmt.Errorf("Checksum at 0x%X: the stored checksum at 0x%X is inside the summed range", c.Head, c.Stored)
+	}
+	sum, err := c.Compute(image)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < c.StoredWidth; i++ {
+		image[c.Stored+i] = byte(sum >> uint(8*i))
+	}
+	return nil
+}
+
+// FixChecksums fixes every routine's stored checksum that can be fixed, in order, for
+// ClonePolicy.FixChecksum and other patchers. Routines without one are left alone.
+func FixChecksums(routines []ChecksumRoutine) func([]byte) error {
+	return func(image []byte) error {
+		for _, c := range routines {
+			if c.Stored < 0 {
+				continue
+			}
+			if err := c.Fix(image); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// CheckChecksums checks every routine with a stored checksum and a known range against the
+// sum of an image as it was read, for ClonePolicy.CheckChecksum. An image with no routine
+// it can check is an error, its checksums aren't known to be good.
+func CheckChecksums(routines []ChecksumRoutine) func([]byte) error {
+	return func(image []byte) error {
+		checked := 0
+		for _, c := range routines {
+			if c.Stored < 0 || c.Start < 0 {
+				continue
+			}
+			if c.Stored+c.StoredWidth > len(image) {
+				return fmt.Errorf("Checksum at 0x%X: the stored checksum at 0x%X is past the end of the image", c.Head, c.Stored)
+			}
+			sum, err := c.Compute(image)
+			if err != nil {
+				return err
+			}
+			stored := 0
+			for i := 0; i < c.StoredWidth; i++ {
+				stored |= int(image[c.Stored+i]) << uint(8*i)
+			}
+			if mask := 1<<uint(8*c.StoredWidth) - 1; sum&mask != stored {
+				return fmt.Errorf("Checksum at 0x%X: 0x%X-0x%X sums to 0x%0*X, 0x%X holds 0x%0*X", c.Head, c.Start, c.Stop, c.StoredWidth*2, sum&mask, c.Stored, c.StoredWidth*2, stored)
+			}
+			checked++
+		}
+		if checked == 0 {
+			return fmt.Errorf("No checksum routine with a stored checksum to check")
+		}
+		return nil
+	}
+}
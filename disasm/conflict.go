@@ -0,0 +1,99 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Conflict is a path into the middle of an instruction that was already decoded, a jump
+// or call, or an instruction that runs on into it. The bytes decode both ways, either they
+// are data that got crawled as code, or one of the two was decoded wrong. The crawl keeps
+// the instruction it decoded first and doesn't follow the other.
+type Conflict struct {
+	Target       int    // where the path goes
+	From         int    // the branching instruction, or the one that runs on into Target
+	Mnemonic     string // of From
+	FallThrough  bool   // From runs on into Target rather than branching there
+	Instr        int    // the instruction the target lands inside
+	InstrOp      string
+	InstrLength  int
+	TargetOp     string // what the bytes at Target decode as, "" if they don't
+	TargetLength int
+}
+
+func (c Conflict) String() string {
+	s := fmt.Sprintf("0x%X %s -> 0x%X lands inside 0x%X %s (%d bytes)", c.From, c.Mnemonic, c.Target, c.Instr, c.InstrOp, c.InstrLength)
+	if c.FallThrough {
+		s = fmt.Sprintf("0x%X %s runs into 0x%X inside 0x%X %s (%d bytes)", c.From, c.Mnemonic, c.Target, c.Instr, c.InstrOp, c.InstrLength)
+	}
+	if c.TargetOp == "" {
+		return s + ", which doesn't decode"
+	}
+	return s + fmt.Sprintf(", which decodes as %s (%d bytes)", c.TargetOp, c.TargetLength)
+}
+
+type conflicts []Conflict
+
+func (c conflicts) Len() int {
+	return len(c)
+}
+
+func (c conflicts) Less(i, j int) bool {
+	if c[i].Target != c[j].Target {
+		return c[i].Target < c[j].Target
+	}
+	return c[i].From < c[j].From
+}
+
+func (c conflicts) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// Checks every jump and call target, and where every instruction runs on to, against the
+// decoded instructions. The opcodes have to be sorted.
+func (h *DisAsm) findConflicts(an *Analysis) {
+	an.Conflicts = nil
+
+	check := func(target, from int, mnemonic string, fall bool) {
+		i := sort.Search(len(an.Opcodes), func(i int) bool { return an.Opcodes[i].Address >= target })
+		if i == 0 {
+			return
+		}
+		prev := an.Opcodes[i-1]
+		if target >= prev.Address+prev.ByteLength {
+			return
+		}
+		c := Conflict{
+			Target:      target,
+			From:        from,
+			Mnemonic:    mnemonic,
+			FallThrough: fall,
+			Instr:       prev.Address,
+			InstrOp:     prev.Mnemonic,
+			InstrLength: prev.ByteLength,
+		}
+		if other, err := h.parse(target); err == nil {
+			c.TargetOp = other.String()
+			c.TargetLength = other.ByteLength
+		}
+		an.Conflicts = append(an.Conflicts, c)
+	}
+
+	for target, jumps := range an.Jumps {
+		for _, j := range jumps {
+			check(target, j.JumpFrom, j.Mnemonic, false)
+		}
+	}
+	for target, calls := range an.Subroutines {
+		for _, c := range calls {
+			check(target, c.CallFrom, c.Mnemonic, false)
+		}
+	}
+	for _, instr := range an.Opcodes {
+		if fallsOn(instr) {
+			check(instr.Address+instr.ByteLength, instr.Address, instr.Mnemonic, true)
+		}
+	}
+
+	sort.Sort(conflicts(an.Conflicts))
+}
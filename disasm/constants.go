@@ -0,0 +1,250 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Calibration constants. Every address outside the code that the code reads, in ROM or
+	on the external bus, with each instruction that reads it, how wide the read is and
+	whether the value is used as signed. Signedness comes from the reader or, when the
+	reader only loads the value into a register, from the first instruction in the straight
+	line code after it that uses that register: LDBSE, EXT, SHRA and the SGN multiplies and
+	divides are signed, LDBZE, MULU and DIVU unsigned, and a compare is whatever the jump
+	after it tests (JGT, JGE, JLT and JLE are signed, JH, JNH, JC and JNC unsigned). It's a
+	starting point for calibration definitions, not the last word on them.
+*/
+
+// Signedness is how a value is used
+type Signedness int
+
+const (
+	SignUnknown Signedness = iota
+	SignUnsigned
+	SignSigned
+	SignMixed // read both ways
+)
+
+func (s Signedness) String() string {
+	switch s {
+	case SignUnsigned:
+		return "unsigned"
+	case SignSigned:
+		return "signed"
+	case SignMixed:
+		return "mixed"
+	}
+	return "?"
+}
+
+// Combines what two readers say
+func (s Signedness) and(o Signedness) Signedness {
+	switch {
+	case s == SignUnknown:
+		return o
+	case o == SignUnknown || o == s:
+		return s
+	}
+	return SignMixed
+}
+
+// ConstantReader is one instruction reading a constant
+type ConstantReader struct {
+	From     int
+	Mnemonic string
+	Width    int
+	Signed   Signedness
+	Use      int // the instruction the signedness was taken from, -1 if none
+}
+
+func (r ConstantReader) String() string {
+	if r.Use < 0 || r.Use == r.From {
+		return fmt.Sprintf("0x%X %-6s %d %s", r.From, r.Mnemonic, r.Width, r.Signed)
+	}
+	return fmt.Sprintf("0x%X %-6s %d %s (at 0x%X)", r.From, r.Mnemonic, r.Width, r.Signed, r.Use)
+}
+
+type constantReaders []ConstantReader
+
+func (r constantReaders) Len() int {
+	return len(r)
+}
+
+func (r constantReaders) Less(i, j int) bool {
+	return r[i].From < r[j].From
+}
+
+func (r constantReaders) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+// Constant is a data address the code reads
+type Constant struct {
+	Address int
+	Kind    MemKind
+	Width   int // widest read
+	Signed  Signedness
+	Readers []ConstantReader
+}
+
+func (c Constant) String() string {
+	return fmt.Sprintf("0x%06X %s %d bytes, %s, %d readers", c.Address, c.Kind, c.Width, c.Signed, len(c.Readers))
+}
+
+type constants []Constant
+
+func (c constants) Len() int {
+	return len(c)
+}
+
+func (c constants) Less(i, j int) bool {
+	return c[i].Address < c[j].Address
+}
+
+func (c constants) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// How far past a load to look for the instruction that uses the value
+const constantUseReach = 8
+
+// Constants lists the data outside the code that the code reads, in address order
+func (h *DisAsm) Constants(an *Analysis) []Constant {
+	memory := h.MemoryMap()
+
+	found := make(map[int]*Constant)
+	for to, xrefs := range an.XRefs {
+		adr := memory.Resolve(to)
+		kind := memory.Kind(adr)
+		if kind != KindROM && kind != KindExternal || an.Crawled[adr] == 1 {
+			continue
+		}
+
+		for _, x := range xrefs {
+			i, ok := an.index(x.XRefFrom)
+			if !ok {
+				continue
+			}
+			access, w, ok := readAccess(&an.Opcodes[i], x)
+			if !ok || access&AccessRead == 0 {
+				continue
+			}
+
+			c := found[adr]
+			if c == nil {
+				c = &Constant{Address: adr, Kind: kind}
+				found[adr] = c
+			}
+			r := ConstantReader{From: x.XRefFrom, Mnemonic: x.Mnemonic, Width: w}
+			r.Signed, r.Use = an.readSignedness(i)
+			c.Readers = append(c.Readers, r)
+			if w > c.Width {
+				c.Width = w
+			}
+			c.Signed = c.Signed.and(r.Signed)
+		}
+	}
+
+	var out []Constant
+	for _, c := range found {
+		sort.Sort(constantReaders(c.Readers))
+		out = append(out, *c)
+	}
+	sort.Sort(constants(out))
+	return out
+}
+
+// How the instruction an XRef came from uses the address, from its direct operand or from
+// the pointer operand constant propagation resolved
+func readAccess(instr *Instruction, x XRef) (Access, int, bool) {
+	for _, r := range operandRefs(instr) {
+		if r.To == x.XRefTo {
+			return r.Access, r.Width, true
+		}
+	}
+	return pointerAccess(*instr, x)
+}
+
+// The index of the instruction at address in Opcodes
+func (an *Analysis) index(address int) (int, bool) {
+	i := sort.Search(len(an.Opcodes), func(i int) bool { return an.Opcodes[i].Address >= address })
+	return i, i < len(an.Opcodes) && an.Opcodes[i].Address == address
+}
+
+// Whether the value the instruction at an.Opcodes[i] reads is used as signed, and the
+// address of the instruction that says so
+func (an *Analysis) readSignedness(i int) (Signedness, int) {
+	if s := an.signedUse(i); s != SignUnknown {
+		return s, an.Opcodes[i].Address
+	}
+
+	// A load, follow the register it loads
+	m := strings.TrimPrefix(an.Opcodes[i].Mnemonic, "SGN ")
+	if m != "LD" && m != "LDB" && m != "ELD" && m != "ELDB" {
+		return SignUnknown, -1
+	}
+	var dest Ref
+	for _, r := range operandRefs(&an.Opcodes[i]) {
+		if r.Access&AccessWrite != 0 {
+			dest = r
+		}
+	}
+	if dest.Width == 0 {
+		return SignUnknown, -1
+	}
+
+	for j := i + 1; j < len(an.Opcodes) && j <= i+constantUseReach; j++ {
+		prev, next := an.Opcodes[j-1], an.Opcodes[j]
+		if prev.Address+prev.ByteLength != next.Address || an.Jumps[next.Address] != nil || an.Subroutines[next.Address] != nil {
+			break
+		}
+		if prev.Is(CategoryCall, CategoryReturn) || prev.IsBranch() && !prev.IsConditional() {
+			break
+		}
+
+		reads, overwrites := false, false
+		for _, r := range operandRefs(&an.Opcodes[j]) {
+			if r.To < dest.To+dest.Width && dest.To < r.To+r.Width {
+				reads = reads || r.Access&AccessRead != 0
+				overwrites = overwrites || r.Access == AccessWrite
+			}
+		}
+		if reads {
+			if s := an.signedUse(j); s != SignUnknown {
+				return s, next.Address
+			}
+		}
+		if overwrites {
+			break
+		}
+	}
+	return SignUnknown, -1
+}
+
+// Whether an.Opcodes[i] treats its operands as signed, from the instruction itself or the
+// jump testing the flags it sets
+func (an *Analysis) signedUse(i int) Signedness {
+	instr := an.Opcodes[i]
+	switch strings.TrimPrefix(instr.Mnemonic, "SGN ") {
+	case "LDBSE", "EXT", "EXTB", "SHRA", "SHRAB", "SHRAL":
+		return SignSigned
+	case "LDBZE", "MULU", "MULUB", "DIVU", "DIVUB":
+		return SignUnsigned
+	case "MUL", "MULB", "DIV", "DIVB":
+		return SignSigned // only ever SGN
+	case "CMP", "CMPB", "CMPL":
+		if i+1 < len(an.Opcodes) {
+			if src, ok := an.FlagSource(i + 1); ok && src == i {
+				switch an.Opcodes[i+1].Mnemonic {
+				case "JGT", "JGE", "JLT", "JLE":
+					return SignSigned
+				case "JH", "JNH", "JC", "JNC":
+					return SignUnsigned
+				}
+			}
+		}
+	}
+	return SignUnknown
+}
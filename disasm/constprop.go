@@ -0,0 +1,308 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Constant propagation. Registers loaded with an immediate (LD, LDB, CLR, and ADD or SUB
+	of an immediate to one already known) are followed through each function's control
+	flow graph, so "LD R_30, #0x0D24" then "LDB R_1C, 2[R_30]" gets an XRef to 0x0D26 like
+	a direct access would. Values are kept per byte, a register is known where every path
+	into a block agrees on it, and anything that writes a register forgets it. Calls forget
+	everything. SP isn't followed, pushes and pops move it without naming it.
+
+	With a RAM snapshot set, loads from the RAM it holds are known too, see SetRAMSnapshot.
+	A BR or EBR through a register that's known jumps to its value, the target is filed
+	as a jump and Analyze crawls again from the ones it hadn't reached.
+*/
+
+// Known register bytes
+type regState map[int]int
+
+func (s regState) word(reg int) (int, bool) {
+	lo, okLo := s[reg]
+	hi, okHi := s[reg+1]
+	return lo | hi<<8, okLo && okHi
+}
+
+func (s regState) setWord(reg, v int) {
+	s[reg] = v & 0xFF
+	s[reg+1] = v >> 8 & 0xFF
+}
+
+func (s regState) copy() regState {
+	c := make(regState, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+// Keeps what both agree on, true if s lost anything
+func (s regState) meet(o regState) bool {
+	changed := false
+	for k, v := range s {
+		if ov, ok := o[k]; !ok || ov != v {
+			delete(s, k)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// The register and displacement of an operand that points through a register constants can
+// be followed into, an indirect one without auto-increment or an indexed one off a register
+// other than the zero register, neither through the window
+func pointerOperand(op Operand) (reg, disp int, ok bool) {
+	switch op := op.(type) {
+	case IndirectOperand:
+		return op.Reg, 0, !op.AutoIncrement && op.Window == 0
+	case IndexedOperand:
+		return op.Reg, op.Displacement(), op.Reg != 0 && op.Window == 0
+	}
+	return 0, 0, false
+}
+
+// Follows the constants through every function and adds the XRefs and indirect branch
+// targets they resolve. Returns the targets the crawl hasn't reached.
+func (h *DisAsm) propagateConstants(an *Analysis, roots []int) []int {
+	entries := append([]int{}, roots...)
+	for adr := range an.Subroutines {
+		entries = append(entries, adr)
+	}
+	return h.propagate(an, entries)
+}
+
+// Propagates the constants through the functions at entries
+func (h *DisAsm) propagate(an *Analysis, entries []int) []int {
+	memory := h.MemoryMap()
+	ram := h.ramValues(memory)
+	var unreached []int
+
+	sort.Ints(entries)
+
+	done := make(map[int]bool)
+	for _, entry := range entries {
+		if done[entry] {
+			continue
+		}
+		done[entry] = true
+
+		cfg, err := h.CFG(an, entry)
+		if err != nil {
+			continue
+		}
+
+		in := map[int]regState{entry: regState{}}
+		todo := []int{entry}
+		for len(todo) > 0 {
+			b := todo[len(todo)-1]
+			todo = todo[:len(todo)-1]
+
+			state := in[b].copy()
+			for _, instr := range cfg.Blocks[b].Instrs {
+				state.step(instr, ram)
+			}
+			for _, succ := range cfg.Blocks[b].Succs {
+				if cfg.Blocks[succ] == nil {
+					continue // not decoded
+				}
+				if old, ok := in[succ]; !ok {
+					in[succ] = state.copy()
+				} else if !old.meet(state) {
+					continue
+				}
+				todo = append(todo, succ)
+			}
+		}
+
+		for b, blk := range cfg.Blocks {
+			state := in[b].copy()
+			for _, instr := range blk.Instrs {
+				state.resolve(instr, an.XRefs, memory)
+				if adr, ok := state.branch(instr, an.Jumps); ok && an.Crawled[adr] == 0 && memory.Executable(adr) && adr+10 <= len(h.block) {
+					unreached = append(unreached, adr)
+				}
+				state.step(instr, ram)
+			}
+		}
+	}
+	return unreached
+}
+
+// Files the jump of a BR or EBR through a register that's known, returns its target
+func (s regState) branch(instr Instruction, jumps map[int][]Jump) (int, bool) {
+	if instr.Mnemonic != "BR" && instr.Mnemonic != "EBR" || len(instr.Ops) == 0 {
+		return 0, false
+	}
+	ind, ok := instr.Ops[0].(IndirectOperand)
+	if !ok || ind.Reg == 0 {
+		return 0, false
+	}
+	v, ok := s.word(ind.Reg)
+	if !ok {
+		return 0, false
+	}
+	adr := instr.Address&^0xFFFF | v // BR stays in its page
+	if instr.Mnemonic == "EBR" {
+		page, ok := s[ind.Reg+2]
+		if !ok {
+			return 0, false
+		}
+		adr = page<<16 | v
+	}
+
+	for _, j := range jumps[adr] {
+		if j.JumpFrom == instr.Address {
+			return adr, true
+		}
+	}
+	jumps[adr] = append(jumps[adr], Jump{String: fmt.Sprintf("0x%X", adr), Mnemonic: instr.Mnemonic, JumpFrom: instr.Address, JumpTo: adr})
+	return adr, true
+}
+
+// Adds XRefs for the indirect and indexed operands of instr whose base is known
+func (s regState) resolve(instr Instruction, xrefs map[int][]XRef, memory *MemoryMap) {
+	if strings.HasPrefix(instr.AddressingMode, "extended") {
+		return // 24 bit pointers
+	}
+
+	for _, op := range instr.Ops {
+		reg, disp, ok := pointerOperand(op)
+		if !ok {
+			continue
+		}
+		base, ok := s.word(reg)
+		if reg == 0 || !ok {
+			continue
+		}
+		adr := (base + disp) & 0xFFFF
+		operand := pseudoOperand(op)
+
+		dup := false
+		for _, x := range xrefs[adr] {
+			dup = dup || x.XRefFrom == instr.Address
+		}
+		if !dup {
+			xrefs[adr] = append(xrefs[adr], XRef{String: fmt.Sprintf("%s = 0x%X", operand, adr), Mnemonic: instr.Mnemonic, XRefFrom: instr.Address, XRefTo: adr, Kind: memory.Kind(adr)})
+		}
+	}
+}
+
+// Applies what instr does to the known registers, loads from the RAM in ram are known
+func (s regState) step(instr Instruction, ram RAMSnapshot) {
+	if len(instr.Calls) > 0 {
+		for k := range s {
+			delete(s, k)
+		}
+		return
+	}
+
+	dest := -1
+	var writes []Ref
+	for _, r := range operandRefs(&instr) {
+		if r.Access&AccessWrite != 0 {
+			writes = append(writes, r)
+			dest = r.To
+		}
+	}
+	if len(writes) == 0 {
+		return
+	}
+
+	imm, threeOp := -1, false
+	for i, op := range instr.Ops {
+		switch strings.ToUpper(instr.VarTypes[i]) {
+		case "SRC":
+			if n, ok := immediate(op); ok {
+				imm = n
+			}
+		case "SRC1", "SRC2":
+			threeOp = true
+		}
+	}
+
+	// The new value, worked out before the writes forget the old one
+	value, known := 0, false
+	if len(writes) == 1 && dest < 0x400 && dest&^1 != spReg && !threeOp {
+		old, ok := s.word(dest)
+		switch instr.Mnemonic {
+		case "CLR", "CLRB":
+			value, known = 0, true
+		case "LD", "LDB":
+			value, known = imm, imm >= 0
+			if !known {
+				value, known = s.load(instr, ram)
+			}
+		case "ADD":
+			value, known = old+imm, ok && imm >= 0
+		case "SUB":
+			value, known = old-imm, ok && imm >= 0
+		}
+	}
+
+	for _, r := range writes {
+		for adr := r.To; adr < r.To+r.Width; adr++ {
+			delete(s, adr)
+		}
+	}
+
+	if known {
+		if writes[0].Width == 1 {
+			s[dest] = value & 0xFF
+		} else {
+			s.setWord(dest, value&0xFFFF)
+		}
+	}
+}
+
+// The value of an immediate operand
+func immediate(op Operand) (int, bool) {
+	imm, ok := op.(ImmediateOperand)
+	return imm.Value, ok
+}
+
+// The value a load reads from the snapshot, from a fixed address, a register or through a
+// pointer the state knows. A register the state knows is read from the state.
+func (s regState) load(instr Instruction, ram RAMSnapshot) (int, bool) {
+	if len(ram) == 0 || strings.HasPrefix(instr.AddressingMode, "extended") {
+		return 0, false
+	}
+	for i, op := range instr.Ops {
+		if strings.ToUpper(instr.VarTypes[i]) != "SRC" {
+			continue
+		}
+
+		adr := -1
+		switch op := op.(type) {
+		case RegisterOperand:
+			adr = regAddress(op.Reg, op.Window)
+			if v, ok := s.word(adr); ok && instr.Mnemonic == "LD" {
+				return v, true
+			}
+			if v, ok := s[adr]; ok && instr.Mnemonic == "LDB" {
+				return v, true
+			}
+		case IndexedOperand:
+			if op.Reg == 0 {
+				adr = op.Offset & 0xFFFF
+			}
+		}
+		if reg, disp, ok := pointerOperand(op); ok && reg != 0 {
+			if base, ok := s.word(reg); ok {
+				adr = (base + disp) & 0xFFFF
+			}
+		}
+		if adr < 0 {
+			return 0, false
+		}
+		if instr.Mnemonic == "LDB" {
+			return ram.Byte(adr)
+		}
+		return ram.Word(adr)
+	}
+	return 0, false
+}
@@ -0,0 +1,114 @@
+package disasm
+
+import "fmt"
+
+/*
+	Coverage. Every byte of the image in ROM or on the external bus is counted as code the
+	crawl decoded, data, blank (erased flash, as the regions see it) or unknown. Data is
+	what something says is data: the interrupt vectors, the bytes XRefs read (two for a
+	word read, 16 bit addresses resolved to the data page), the strings set with
+	SetStrings and the ranges of the region map. The register file, SFRs and reserved
+	locations are left out, there is nothing in the image to account for there.
+	Confirmed is the part of the code an execution trace ran, see ConfirmCode.
+*/
+
+// Coverage is how many bytes of a memory map location are in each class
+type Coverage struct {
+	Name      string
+	Start     int
+	Stop      int
+	Code      int
+	Data      int
+	Blank     int
+	Unknown   int
+	Confirmed int // of the code
+}
+
+// Total is every byte counted
+func (c Coverage) Total() int {
+	return c.Code + c.Data + c.Blank + c.Unknown
+}
+
+// Percent is n as a percentage of the total
+func (c Coverage) Percent(n int) float64 {
+	if c.Total() == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(c.Total())
+}
+
+func (c Coverage) String() string {
+	str := fmt.Sprintf("code %5.1f%%  data %5.1f%%  blank %5.1f%%  unknown %5.1f%%", c.Percent(c.Code), c.Percent(c.Data), c.Percent(c.Blank), c.Percent(c.Unknown))
+	if c.Confirmed > 0 {
+		str += fmt.Sprintf("  confirmed %5.1f%%", c.Percent(c.Confirmed))
+	}
+	return str
+}
+
+// Coverage breaks the image down by memory map location, and totals it
+func (h *DisAsm) Coverage(an *Analysis) ([]Coverage, Coverage) {
+	h.GetInterrupts()
+	memory := h.MemoryMap()
+
+	data := make(map[int]bool)
+	for vec := range h.vectorAdr {
+		data[vec], data[vec+1] = true, true
+	}
+	for adr, xrefs := range an.XRefs {
+		adr = memory.Resolve(adr)
+		data[adr] = true
+		if wordRead(xrefs) {
+			data[adr+1] = true
+		}
+	}
+	for adr, text := range h.strings {
+		for i := 0; i <= len(text); i++ { // and its NUL
+			data[adr+i] = true
+		}
+	}
+	for _, r := range h.regionMap {
+		for adr := r.Start; adr <= r.Stop; adr++ {
+			data[adr] = true
+		}
+	}
+
+	blank := make(map[int]bool)
+	for _, r := range h.regions(an) {
+		if r.Class == "blank" {
+			for adr := r.Start; adr <= r.Stop; adr++ {
+				blank[adr] = true
+			}
+		}
+	}
+
+	var locations []Coverage
+	total := Coverage{Name: "Total"}
+	for _, loc := range memory.Locations {
+		if loc.Ignore || loc.Kind != KindROM && loc.Kind != KindExternal || loc.Start >= len(h.block) {
+			continue
+		}
+		c := Coverage{Name: loc.Name, Start: loc.Start, Stop: min(loc.Stop, len(h.block)-1)}
+		for adr := c.Start; adr <= c.Stop; adr++ {
+			switch {
+			case an.Crawled[adr] == 1:
+				c.Code++
+				if h.confirmed[adr] {
+					c.Confirmed++
+				}
+			case data[adr]:
+				c.Data++
+			case blank[adr]:
+				c.Blank++
+			default:
+				c.Unknown++
+			}
+		}
+		total.Code += c.Code
+		total.Data += c.Data
+		total.Blank += c.Blank
+		total.Unknown += c.Unknown
+		total.Confirmed += c.Confirmed
+		locations = append(locations, c)
+	}
+	return locations, total
+}
@@ -0,0 +1,171 @@
+package disasm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+/*
+	Data directives. Bytes the crawl didn't reach are listed as DCB, DCW or DCL lines the
+	assembler reads back, 16 bytes, 8 words or 4 longs to a line, with a new line wherever
+	there is something else to say: an XRef, a comment, a vector, code or a memory map
+	location. The RegionMap picks the directive for a range. Outside it a run that word
+	instructions read is listed as words, anything else as bytes.
+*/
+
+// DataFormat is the directive a data region is listed with
+type DataFormat string
+
+const (
+	DataBytes DataFormat = "DCB"
+	DataWords DataFormat = "DCW"
+	DataLongs DataFormat = "DCL"
+)
+
+var (
+	dataSizes   = map[DataFormat]int{DataBytes: 1, DataWords: 2, DataLongs: 4}
+	dataFormats = map[int]DataFormat{1: DataBytes, 2: DataWords, 4: DataLongs}
+)
+
+// DataRegion is a range of the image listed with one directive
+type DataRegion struct {
+	Name   string `json:",omitempty"`
+	Start  int
+	Stop   int
+	Format DataFormat
+}
+
+// RegionMap says how the data in parts of the image is laid out
+type RegionMap []DataRegion
+
+func (m RegionMap) Len() int {
+	return len(m)
+}
+
+func (m RegionMap) Less(i, j int) bool {
+	return m[i].Start < m[j].Start
+}
+
+func (m RegionMap) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+// LoadRegionMap reads a region map from a JSON file
+func LoadRegionMap(path string) (RegionMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var m RegionMap
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("Region map %s: %s", path, err)
+	}
+	sort.Sort(m)
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("Region map %s: %s", path, err)
+	}
+	return m, nil
+}
+
+// Validate checks that the regions are in order, sane and don't overlap
+func (m RegionMap) Validate() error {
+	for i, r := range m {
+		if _, ok := dataSizes[r.Format]; !ok {
+			return fmt.Errorf("Region 0x%X has format %q, not DCB, DCW or DCL", r.Start, r.Format)
+		}
+		if r.Stop < r.Start {
+			return fmt.Errorf("Region 0x%X stops before it starts", r.Start)
+		}
+		if i > 0 && r.Start <= m[i-1].Stop {
+			return fmt.Errorf("Region 0x%X overlaps 0x%X", r.Start, m[i-1].Start)
+		}
+	}
+	return nil
+}
+
+// Locate finds the region holding adr
+func (m RegionMap) Locate(adr int) (DataRegion, bool) {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Stop >= adr })
+	if i < len(m) && m[i].Start <= adr {
+		return m[i], true
+	}
+	return DataRegion{}, false
+}
+
+// SetRegionMap picks the directives data regions are listed with
+func (h *DisAsm) SetRegionMap(m RegionMap) error {
+	sort.Sort(m)
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	h.regionMap = m
+	return nil
+}
+
+// The directive lines for the data run starting at start, and the address after it
+func (h *DisAsm) dataLines(an *Analysis, start int) (lines []string, next int) {
+	if text, ok := h.strings[start]; ok {
+		lines = h.stringLines(start, text)
+		lines[0] += h.comment(start)
+		return lines, start + len(text)
+	}
+
+	format, end := DataBytes, len(h.block)
+	if r, ok := h.dataRegion(start); ok {
+		format, end = r.Format, r.Stop+1
+	} else {
+		end = h.nextDataRegion(start)
+		if start%2 == 0 && wordRead(an.XRefs[start]) {
+			format = DataWords
+		}
+	}
+
+	// Up to the next thing that needs its own line
+	next = start + 1
+	for next < end && h.memStops[next-1] == "" && h.memStarts[next] == "" && an.Crawled[next] != 1 &&
+		an.XRefs[next] == nil && h.vectorAdr[next] == "" && h.comments[next] == "" && h.strings[next] == "" {
+		next++
+	}
+
+	for adr := start; adr < next; {
+		size := dataSizes[format]
+		for adr+size > next {
+			size /= 2 // the end of the run isn't a whole word
+		}
+
+		var values []string
+		for n := 0; n < 16/size && adr+size <= next; n++ {
+			v := 0
+			for i := size - 1; i >= 0; i-- {
+				v = v<<8 | int(h.block[adr+i])
+			}
+			values = append(values, fmt.Sprintf("0x%0*X", size*2, v))
+			adr += size
+		}
+
+		line := addSpaces(fmt.Sprintf("[0x%X]", adr-len(values)*size), 20) + addSpaces(string(dataFormats[size]), 6) + strings.Join(values, ", ")
+		if len(lines) == 0 {
+			line += h.comment(start)
+		}
+		lines = append(lines, line)
+	}
+	if r, ok := h.known.Locate(start); ok && r.Address == start {
+		header := fmt.Sprintf("\n======== REGION_ 0x%X [%s] %d bytes ==================================================================================", start, r.Name, r.Length)
+		lines = append([]string{header}, lines...)
+	}
+	return lines, next
+}
+
+// Whether every XRef to an address is a word access
+func wordRead(xrefs []XRef) bool {
+	for _, x := range xrefs {
+		if width(strings.TrimPrefix(x.Mnemonic, "SGN ")) != 2 {
+			return false
+		}
+	}
+	return len(xrefs) > 0
+}
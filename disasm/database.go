@@ -0,0 +1,217 @@
+package disasm
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+)
+
+// Database is an Analysis in a form that can be saved, so a long session can pick up where
+// the last one left off instead of crawling the image again
+type Database struct {
+	ImageCRC  uint32     // of the image it was made from
+	MemoryMap *MemoryMap // the map the references were tagged with
+	Set       string     // name of the instruction set it was decoded with
+	Code      []int      // instruction addresses
+	Windows   map[int]int
+	Functions []Function
+	Regions   []Region
+	XRefs     []XRef
+	Calls     []Call
+	Jumps     []Jump
+	Invalid   []XRef
+	Conflicts []Conflict
+	Returns   int
+	Errors    int
+}
+
+// Function is a subroutine's extent, from its entry to the end of its last instruction
+type Function struct {
+	Entry   int
+	End     int // one past the last byte
+	Blocks  int
+	Callers []int
+}
+
+// Region is a run of the image classified as code, data or blank (erased flash)
+type Region struct {
+	Start int
+	Stop  int
+	Class string
+	Kind  MemKind
+}
+
+// Database builds the saveable form of an analysis of this image
+func (h *DisAsm) Database(an *Analysis) *Database {
+	db := &Database{
+		ImageCRC:  crc32.ChecksumIEEE(h.block),
+		MemoryMap: h.MemoryMap(),
+		Set:       h.InstructionSet().Name,
+		Windows:   make(map[int]int),
+		Invalid:   an.Invalid,
+		Conflicts: an.Conflicts,
+		Returns:   an.Returns,
+		Errors:    an.Errors,
+	}
+
+	for _, instr := range an.Opcodes {
+		db.Code = append(db.Code, instr.Address)
+		if instr.Window != 0 {
+			db.Windows[instr.Address] = instr.Window
+		}
+	}
+
+	var adrs []int
+	for adr := range an.XRefs {
+		adrs = append(adrs, adr)
+	}
+	sort.Ints(adrs)
+	for _, adr := range adrs {
+		db.XRefs = append(db.XRefs, an.XRefs[adr]...)
+	}
+
+	adrs = nil
+	for adr := range an.Jumps {
+		adrs = append(adrs, adr)
+	}
+	sort.Ints(adrs)
+	for _, adr := range adrs {
+		db.Jumps = append(db.Jumps, an.Jumps[adr]...)
+	}
+
+	adrs = nil
+	for adr := range an.Subroutines {
+		adrs = append(adrs, adr)
+	}
+	sort.Ints(adrs)
+	for _, adr := range adrs {
+		calls := an.Subroutines[adr]
+		db.Calls = append(db.Calls, calls...)
+
+		fn := Function{Entry: adr, End: adr}
+		if cfg, err := h.CFG(an, adr); err == nil {
+			fn.Blocks = len(cfg.Blocks)
+			for _, blk := range cfg.Blocks {
+				last := blk.Instrs[len(blk.Instrs)-1]
+				if end := last.Address + last.ByteLength; end > fn.End {
+					fn.End = end
+				}
+			}
+		}
+		for _, c := range calls {
+			fn.Callers = append(fn.Callers, c.CallFrom)
+		}
+		db.Functions = append(db.Functions, fn)
+	}
+
+	db.Regions = h.regions(an)
+	return db
+}
+
+// Runs of code, data and blank flash, split where the memory map changes
+func (h *DisAsm) regions(an *Analysis) []Region {
+	memory := h.MemoryMap()
+	var regions []Region
+
+	add := func(start, end int, class string) {
+		for adr := start; adr < end; adr++ {
+			kind := memory.Kind(adr)
+			if n := len(regions); n > 0 && regions[n-1].Class == class && regions[n-1].Kind == kind {
+				regions[n-1].Stop = adr
+				continue
+			}
+			regions = append(regions, Region{Start: adr, Stop: adr, Class: class, Kind: kind})
+		}
+	}
+
+	for adr := 0; adr < len(h.block); {
+		end := adr + 1
+		class := "data"
+		if an.Crawled[adr] == 1 {
+			class = "code"
+		} else if h.block[adr] == 0xFF {
+			// Erased flash, 16 or more FF bytes that aren't code
+			for end < len(h.block) && h.block[end] == 0xFF && an.Crawled[end] != 1 {
+				end++
+			}
+			if end-adr >= 16 {
+				class = "blank"
+			}
+		}
+		add(adr, end, class)
+		adr = end
+	}
+	return regions
+}
+
+// Restore rebuilds an analysis from a database made from this same image, parsing the
+// instructions it lists instead of crawling for them
+func (h *DisAsm) Restore(db *Database) (*Analysis, error) {
+	if crc := crc32.ChecksumIEEE(h.block); crc != db.ImageCRC {
+		return nil, fmt.Errorf("Database is for a different image, CRC %08X expected %08X", crc, db.ImageCRC)
+	}
+	if h.memory == nil && db.MemoryMap != nil {
+		if err := h.SetMemoryMap(db.MemoryMap); err != nil {
+			return nil, err
+		}
+	}
+	if h.instructions == nil && db.Set != "" {
+		s, ok := builtinInstructionSet(db.Set)
+		if !ok {
+			return nil, fmt.Errorf("Database was decoded with instruction set %s, set it before restoring", db.Set)
+		}
+		h.SetInstructionSet(s)
+	}
+
+	if err := h.GetInterrupts(); err != nil {
+		return nil, err
+	}
+	h.GetMemoryMap()
+
+	an := &Analysis{
+		Subroutines: make(map[int][]Call),
+		XRefs:       make(map[int][]XRef),
+		Jumps:       make(map[int][]Jump),
+		Crawled:     make(map[int]int),
+		Invalid:     db.Invalid,
+		Conflicts:   db.Conflicts,
+		Returns:     db.Returns,
+		Errors:      db.Errors,
+	}
+
+	for _, adr := range db.Code {
+		if adr < 0 || adr+10 > len(h.block) {
+			return nil, fmt.Errorf("Database lists code at 0x%X, outside the image", adr)
+		}
+		instr, err := h.parse(adr)
+		if err != nil {
+			return nil, fmt.Errorf("Database lists code at 0x%X: %s", adr, err)
+		}
+		for i := 0; i < instr.ByteLength; i++ {
+			an.Crawled[adr+i] = 1
+		}
+		an.Opcodes = append(an.Opcodes, instr)
+	}
+	sort.Sort(an.Opcodes)
+
+	for _, x := range db.XRefs {
+		an.XRefs[x.XRefTo] = append(an.XRefs[x.XRefTo], x)
+	}
+	for _, c := range db.Calls {
+		an.Subroutines[c.CallTo] = append(an.Subroutines[c.CallTo], c)
+	}
+	for _, j := range db.Jumps {
+		an.Jumps[j.JumpTo] = append(an.Jumps[j.JumpTo], j)
+	}
+
+	// The XRefs were moved when the database was made, this only renames the operands
+	memory := h.MemoryMap()
+	for i := range an.Opcodes {
+		if wsr, ok := db.Windows[an.Opcodes[i].Address]; ok {
+			an.Opcodes[i].window(wsr, an.XRefs, memory)
+		}
+	}
+	an.pairBranches()
+
+	return an, nil
+}
@@ -0,0 +1,66 @@
+package disasm
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// A project reopened from its saved analysis lists the same as a fresh crawl, the
+// conditional jumps still naming what they compare
+func TestRestoreListing(t *testing.T) {
+	pre, cal := embedImage()
+	copy(cal[0x2080:], []byte{
+		0x99, 0x55, 0x1C, // CMPB R_1C, #55
+		0xDF, 0x03, // JE 0x172088
+		0xEF, 0x02, 0x00, // LCALL 0x17208A
+		0x27, 0xFE, // SJMP 0x172088
+		0xF0, // RET
+	})
+	image := append(pre, cal...)
+
+	var fresh bytes.Buffer
+	d := NewBlock(image)
+	d.SetOutput(&fresh)
+	an, err := d.Analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(d.Database(an))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Listing(an); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(fresh.Bytes(), []byte("R_1C == 0x55")) {
+		t.Fatalf("The fresh listing doesn't pair the JE with its CMPB:\n%s", fresh.String())
+	}
+
+	db := new(Database)
+	if err := json.Unmarshal(data, db); err != nil {
+		t.Fatal(err)
+	}
+	var restored bytes.Buffer
+	d = NewBlock(image)
+	d.SetOutput(&restored)
+	an, err = d.Restore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Listing(an); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Split(fresh.String(), "\n")
+	got := strings.Split(restored.String(), "\n")
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("Restored listing differs from the fresh one at line %d:\n%q\nwant\n%q", i+1, got[i:], want[i])
+		}
+	}
+	if len(got) > len(want) {
+		t.Errorf("Restored listing has %d lines, the fresh one %d", len(got), len(want))
+	}
+}
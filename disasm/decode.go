@@ -0,0 +1,83 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Decode options. The decoder files an XRef for every register and address an
+	instruction's operands name, and not every analysis wants all of them: the zero
+	register is read for a constant 0 all over the image, and the CPU's SFRs at the bottom
+	of the register file are touched by nearly every subroutine, so their XRef lists are
+	long and say little. DecodeOptions says which of those references an instruction
+	keeps. The default drops everything below 0x03, the zero register and the first SFR,
+	the way the decoder always has.
+
+	XRefPolicies has the usual ones by name, "all" keeps every reference and "memory" only
+	the ones past the SFRs. The options are the disassembler's, for its crawl and listing;
+	Parse, ParseAll and Walk use the default.
+*/
+
+// DecodeOptions picks the XRefs the decoder keeps
+type DecodeOptions struct {
+	IgnoreZeroRegister bool // drop references to the zero register, 0x00 and 0x01
+	IgnoreSFRs         bool // drop references to the CPU's SFRs, 0x02 to 0x17
+	MinAddress         int  // drop references below this address
+}
+
+// DefaultDecodeOptions are used until a disassembler is given others
+var DefaultDecodeOptions = DecodeOptions{IgnoreZeroRegister: true, MinAddress: 0x03}
+
+// XRefPolicies are the built in decode options, by name
+var XRefPolicies = map[string]DecodeOptions{
+	"default": DefaultDecodeOptions,
+	"all":     {},
+	"memory":  {IgnoreZeroRegister: true, IgnoreSFRs: true},
+}
+
+// FindXRefPolicy returns built in decode options by name
+func FindXRefPolicy(name string) (DecodeOptions, error) {
+	if o, ok := XRefPolicies[strings.ToLower(name)]; ok {
+		return o, nil
+	}
+	return DecodeOptions{}, fmt.Errorf("No XRef policy named %s, want default, all or memory", name)
+}
+
+// Keeps is whether a reference to adr is kept
+func (o DecodeOptions) Keeps(adr int) bool {
+	switch {
+	case adr < 0 || adr < o.MinAddress:
+		return false
+	case o.IgnoreZeroRegister && adr <= 0x01:
+		return false
+	case o.IgnoreSFRs && adr >= 0x02 && adr <= 0x17:
+		return false
+	}
+	return true
+}
+
+// SetDecodeOptions sets which XRefs the crawl keeps, before the image is analyzed
+func (h *DisAsm) SetDecodeOptions(o DecodeOptions) {
+	h.decode = &o
+}
+
+// DecodeOptions are the options in use, the default ones unless others were set
+func (h *DisAsm) DecodeOptions() DecodeOptions {
+	if h.decode == nil {
+		return DefaultDecodeOptions
+	}
+	return *h.decode
+}
+
+// Drops the XRefs o doesn't keep
+func (instr *Instruction) filterXRefs(o DecodeOptions) {
+	for adr := range instr.XRefs {
+		if !o.Keeps(adr) {
+			delete(instr.XRefs, adr)
+		}
+	}
+	if len(instr.XRefs) == 0 {
+		instr.XRefs = nil
+	}
+}
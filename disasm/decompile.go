@@ -0,0 +1,918 @@
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Decompiler. A subroutine's instructions are split into basic blocks, dominators and
+	post-dominators are worked out over the graph, and the blocks are printed back as
+	structured pseudo code:
+
+		natural loops			while (cond) { } or while (1) { } with break / continue
+		conditional jumps		if (cond) { } else { }, joining at the post-dominator
+		TIJMP with a known table	switch () { case: }
+		anything left over		goto L_xxxxxx, with the label printed once
+
+	Straight line code is printed a statement per instruction, apart from the idioms
+	idiom.go knows, which are a statement each.
+*/
+
+const noBlock = -1
+
+// BasicBlock is a run of instructions with one way in at the top and one way out at the bottom
+type BasicBlock struct {
+	Start  int
+	Instrs Instructions
+	Succs  []int // a conditional jump's target comes first, then the fall through
+	Preds  []int
+}
+
+// CFG is the control flow graph of one subroutine, calls aren't followed
+type CFG struct {
+	Entry  int
+	Blocks map[int]*BasicBlock
+}
+
+func (an *Analysis) instruction(address int) (Instruction, bool) {
+	i := sort.Search(len(an.Opcodes), func(i int) bool { return an.Opcodes[i].Address >= address })
+	if i < len(an.Opcodes) && an.Opcodes[i].Address == address {
+		return an.Opcodes[i], true
+	}
+	return Instruction{}, false
+}
+
+// Where control can go after an instruction, not counting calls
+func flow(instr Instruction) (targets []int, fallsThrough bool) {
+	m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+
+	var target []int
+	for adr := range instr.Jumps {
+		target = append(target, adr)
+	}
+	sort.Ints(target)
+
+	switch m {
+	case "SJMP", "LJMP", "EJMP":
+		return target, false
+	case "RET", "RST", "BR", "EBR", "TIJMP":
+		return nil, false
+	case "JBC", "JBS", "DJNZ", "DJNZW":
+		return target, true
+	}
+	if instr.Flags.Tests != 0 {
+		return target, true
+	}
+	return nil, true
+}
+
+// CFG builds the graph of the subroutine at entry. TIJMP blocks get their cases as
+// successors when the table base is loaded with an immediate in the same block.
+func (h *DisAsm) CFG(an *Analysis, entry int) (*CFG, error) {
+	if _, ok := an.instruction(entry); !ok {
+		return nil, fmt.Errorf("No instruction at 0x%X", entry)
+	}
+
+	// Find every reachable instruction and the block leaders
+	leaders := map[int]bool{entry: true}
+	seen := make(map[int]bool)
+	todo := []int{entry}
+	for len(todo) > 0 {
+		adr := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if seen[adr] {
+			continue
+		}
+		instr, ok := an.instruction(adr)
+		if !ok {
+			continue
+		}
+		seen[adr] = true
+
+		targets, falls := flow(instr)
+		if instr.Mnemonic == "TIJMP" {
+			targets = h.switchTargets(an, instr)
+		}
+		for _, t := range targets {
+			leaders[t] = true
+			todo = append(todo, t)
+		}
+		if falls {
+			next := adr + instr.ByteLength
+			if len(targets) > 0 {
+				leaders[next] = true
+			}
+			todo = append(todo, next)
+		}
+	}
+
+	cfg := &CFG{Entry: entry, Blocks: make(map[int]*BasicBlock)}
+	for start := range leaders {
+		if !seen[start] {
+			continue
+		}
+		blk := &BasicBlock{Start: start}
+		adr := start
+		for {
+			instr, _ := an.instruction(adr)
+			blk.Instrs = append(blk.Instrs, instr)
+
+			targets, falls := flow(instr)
+			if instr.Mnemonic == "TIJMP" {
+				targets = h.switchTargets(an, instr)
+			}
+			next := adr + instr.ByteLength
+			if len(targets) > 0 || !falls || leaders[next] || !seen[next] {
+				blk.Succs = append(blk.Succs, targets...)
+				if falls && seen[next] {
+					blk.Succs = append(blk.Succs, next)
+				}
+				break
+			}
+			adr = next
+		}
+		cfg.Blocks[start] = blk
+	}
+
+	for _, blk := range cfg.Blocks {
+		for _, s := range blk.Succs {
+			if succ := cfg.Blocks[s]; succ != nil {
+				succ.Preds = append(succ.Preds, blk.Start)
+			}
+		}
+	}
+	for _, blk := range cfg.Blocks {
+		sort.Ints(blk.Preds)
+	}
+
+	return cfg, nil
+}
+
+// The table entries of a TIJMP, when the base is an immediate loaded earlier in the block
+func (h *DisAsm) switchTargets(an *Analysis, tijmp Instruction) []int {
+	base, ok := h.tijmpBase(an, tijmp)
+	if !ok {
+		return nil
+	}
+
+	e := NewEmulator(h.block)
+	mask := int(tijmp.RawOps[1])
+	var targets []int
+	seen := make(map[int]bool)
+	for i := 0; i <= mask; i++ {
+		if i&mask != i {
+			continue
+		}
+		t := tijmp.Address&^0xFFFF | e.Read16(e.Data16(base+2*i))
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+func (h *DisAsm) tijmpBase(an *Analysis, tijmp Instruction) (int, bool) {
+	tbase := tijmp.RawOps[2]
+	adr := tijmp.Address
+	for n := 0; n < 8; n++ {
+		i := sort.Search(len(an.Opcodes), func(i int) bool { return an.Opcodes[i].Address >= adr }) - 1
+		if i < 0 || an.Opcodes[i].Address+an.Opcodes[i].ByteLength != adr {
+			return 0, false
+		}
+		prev := an.Opcodes[i]
+		if prev.Mnemonic == "LD" && prev.AddressingMode == "immediate" && prev.RawOps[2] == tbase {
+			return int(prev.RawOps[0]) | int(prev.RawOps[1])<<8, true
+		}
+		if len(prev.RawOps) > 0 && prev.RawOps[len(prev.RawOps)-1] == tbase {
+			return 0, false // TBASE written some other way
+		}
+		adr = prev.Address
+	}
+	return 0, false
+}
+
+// Reverse post order from the entry
+func postOrder(entry int, succs func(int) []int) []int {
+	var order []int
+	seen := map[int]bool{entry: true}
+	var walk func(int)
+	walk = func(n int) {
+		for _, s := range succs(n) {
+			if !seen[s] {
+				seen[s] = true
+				walk(s)
+			}
+		}
+		order = append(order, n)
+	}
+	walk(entry)
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// Immediate dominators, Cooper, Harvey and Kennedy's iterative way
+func dominators(entry int, succs, preds func(int) []int) map[int]int {
+	order := postOrder(entry, succs)
+	index := make(map[int]int)
+	for i, n := range order {
+		index[n] = i
+	}
+
+	idom := map[int]int{entry: entry}
+	intersect := func(a, b int) int {
+		for a != b {
+			for index[a] > index[b] {
+				a = idom[a]
+			}
+			for index[b] > index[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, n := range order[1:] {
+			newIdom, found := 0, false
+			for _, p := range preds(n) {
+				if _, ok := idom[p]; !ok {
+					continue
+				}
+				if !found {
+					newIdom, found = p, true
+				} else {
+					newIdom = intersect(p, newIdom)
+				}
+			}
+			if found && (idom[n] != newIdom || !hasKey(idom, n)) {
+				idom[n] = newIdom
+				changed = true
+			}
+		}
+	}
+	return idom
+}
+
+func hasKey(m map[int]int, k int) bool {
+	_, ok := m[k]
+	return ok
+}
+
+type loop struct {
+	header int
+	body   map[int]bool
+	exit   int
+	latch  int // the conditional jump back, for do { } while (cond)
+	depth  int
+	cond   string
+}
+
+type pseudoLine struct {
+	depth  int
+	text   string
+	labels []int // blocks that start here
+}
+
+type decompiler struct {
+	cfg     *CFG
+	an      *Analysis
+	h       *DisAsm
+	idom    map[int]int
+	ipdom   map[int]int
+	loops   map[int]*loop
+	emitted map[int]bool
+	gotos   map[int]bool
+	labeled map[int]bool
+	pending []int // labels waiting for the next line
+	lines   []pseudoLine
+
+	interpolates map[int]bool           // subroutines called, whether they're table interpolations
+	vars         map[int]map[int]string // variable names by instruction and register, see variables.go
+	inputs       map[int]map[int]bool   // registers the subroutines called read
+	inlined      map[int]Instructions   // bodies of the subroutines called that are inlined, nil if not
+}
+
+// Decompile prints the subroutine at entry as structured pseudo code
+func (h *DisAsm) Decompile(an *Analysis, entry int) (string, error) {
+	return h.decompile(an, entry, make(map[int]bool))
+}
+
+// Decompiles with what's known about the subroutines called so far
+func (h *DisAsm) decompile(an *Analysis, entry int, interpolates map[int]bool) (string, error) {
+	cfg, err := h.CFG(an, entry)
+	if err != nil {
+		return "", err
+	}
+
+	d := &decompiler{cfg: cfg, an: an, h: h, emitted: make(map[int]bool), gotos: make(map[int]bool), labeled: make(map[int]bool), interpolates: interpolates}
+	d.analyze()
+	d.inlined = make(map[int]Instructions)
+	if h.variables {
+		d.inputs = make(map[int]map[int]bool)
+		d.vars = d.variableNames()
+	}
+
+	name := fmt.Sprintf("sub_%X", entry)
+	if s := h.symbols[entry]; s != "" {
+		name = s
+	}
+	if d.interpolating(entry) {
+		d.line(0, name+"() {  // table interpolation")
+	} else {
+		d.line(0, name+"() {")
+	}
+	d.region(entry, noBlock, nil, 1)
+
+	// Goto targets that nothing structured reached
+	for {
+		var left []int
+		for t := range d.gotos {
+			if !d.emitted[t] && cfg.Blocks[t] != nil {
+				left = append(left, t)
+			}
+		}
+		if len(left) == 0 {
+			break
+		}
+		sort.Ints(left)
+		d.region(left[0], noBlock, nil, 1)
+	}
+	d.line(0, "}")
+
+	return h.aliases.Apply(d.String()), nil
+}
+
+func (d *decompiler) succs(n int) []int {
+	var out []int
+	for _, s := range d.cfg.Blocks[n].Succs {
+		if d.cfg.Blocks[s] != nil {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (d *decompiler) analyze() {
+	d.idom = dominators(d.cfg.Entry, d.succs, func(n int) []int { return d.cfg.Blocks[n].Preds })
+
+	// Post dominators over the reversed graph, from a virtual exit every block without
+	// successors leads to
+	const exit = -2
+	var ends []int
+	for adr := range d.cfg.Blocks {
+		if len(d.succs(adr)) == 0 {
+			ends = append(ends, adr)
+		}
+	}
+	sort.Ints(ends)
+	d.ipdom = dominators(exit,
+		func(n int) []int {
+			if n == exit {
+				return ends
+			}
+			return d.cfg.Blocks[n].Preds
+		},
+		func(n int) []int {
+			if len(d.succs(n)) == 0 {
+				return []int{exit}
+			}
+			return d.succs(n)
+		})
+	for n, p := range d.ipdom {
+		if p == exit {
+			d.ipdom[n] = noBlock
+		}
+	}
+
+	// Natural loops, from back edges to a block that dominates their source
+	d.loops = make(map[int]*loop)
+	var starts []int
+	for adr := range d.cfg.Blocks {
+		starts = append(starts, adr)
+	}
+	sort.Ints(starts)
+	for _, u := range starts {
+		for _, h := range d.succs(u) {
+			if !d.dominates(h, u) {
+				continue
+			}
+			l := d.loops[h]
+			if l == nil {
+				l = &loop{header: h, body: map[int]bool{h: true}, exit: noBlock, latch: noBlock}
+				d.loops[h] = l
+			}
+			todo := []int{u}
+			for len(todo) > 0 {
+				n := todo[len(todo)-1]
+				todo = todo[:len(todo)-1]
+				if l.body[n] {
+					continue
+				}
+				l.body[n] = true
+				todo = append(todo, d.cfg.Blocks[n].Preds...)
+			}
+		}
+	}
+
+	// A loop exits to its header's post dominator if that is outside, otherwise to the
+	// lowest block it can leave to
+	for _, l := range d.loops {
+		if p, ok := d.ipdom[l.header]; ok && p != noBlock && !l.body[p] {
+			l.exit = p
+			continue
+		}
+		for n := range l.body {
+			for _, s := range d.succs(n) {
+				if !l.body[s] && (l.exit == noBlock || s < l.exit) {
+					l.exit = s
+				}
+			}
+		}
+	}
+}
+
+// Where the paths out of b meet again, inside the loop if there is one
+func (d *decompiler) join(b int, lp *loop) int {
+	join, ok := d.ipdom[b]
+	if !ok || (lp != nil && !lp.body[join]) {
+		return noBlock
+	}
+	return join
+}
+
+func (d *decompiler) dominates(a, b int) bool {
+	for {
+		if a == b {
+			return true
+		}
+		p, ok := d.idom[b]
+		if !ok || p == b {
+			return false
+		}
+		b = p
+	}
+}
+
+func (d *decompiler) line(depth int, text string) {
+	d.lines = append(d.lines, pseudoLine{depth: depth, text: text, labels: d.pending})
+	d.pending = nil
+}
+
+// A statement with its semicolon, before the comment an idiom has
+func terminate(stmt string) string {
+	if strings.HasPrefix(stmt, "// ") {
+		return stmt
+	}
+	if i := strings.Index(stmt, "  // "); i >= 0 {
+		return stmt[:i] + ";" + stmt[i:]
+	}
+	return stmt + ";"
+}
+
+// Label puts b's label on the next line printed
+func (d *decompiler) label(b int) {
+	if !d.labeled[b] {
+		d.labeled[b] = true
+		d.pending = append(d.pending, b)
+	}
+}
+
+func (d *decompiler) jump(depth, target int) {
+	d.gotos[target] = true
+	d.line(depth, fmt.Sprintf("goto L_%X;", target))
+}
+
+// Region prints blocks from b until it reaches stop
+func (d *decompiler) region(b, stop int, lp *loop, depth int) {
+	for b != stop && b != noBlock {
+		if d.cfg.Blocks[b] == nil {
+			d.line(depth, fmt.Sprintf("goto 0x%X; // not decoded", b))
+			return
+		}
+		if lp != nil {
+			switch {
+			case b == lp.header:
+				d.line(depth, "continue;")
+				return
+			case b == lp.exit:
+				d.line(depth, "break;")
+				return
+			case !lp.body[b]:
+				d.jump(depth, b)
+				return
+			}
+		}
+		if d.emitted[b] {
+			d.jump(depth, b)
+			return
+		}
+
+		if l := d.loops[b]; l != nil && l != lp {
+			d.loop(l, depth)
+			if lp != nil && l.exit != noBlock && !lp.body[l.exit] && l.exit != lp.exit {
+				d.jump(depth, l.exit)
+				return
+			}
+			b = l.exit
+			continue
+		}
+
+		b = d.block(b, lp, depth)
+	}
+}
+
+func (d *decompiler) loop(l *loop, depth int) {
+	blk := d.cfg.Blocks[l.header]
+	stmts, cond, ok := d.statements(blk)
+
+	// while (cond) when the header is nothing but the test
+	if ok && len(stmts) == 0 && len(blk.Succs) == 2 {
+		taken, next := blk.Succs[0], blk.Succs[1]
+		if taken == l.exit && l.body[next] {
+			taken, next = next, taken
+			cond = cond.not()
+		}
+		if next == l.exit && l.body[taken] {
+			d.emitted[l.header] = true
+			d.label(l.header)
+			d.line(depth, fmt.Sprintf("while (%s) {", cond))
+			d.region(taken, noBlock, l, depth+1)
+			d.trimContinue(depth + 1)
+			d.line(depth, "}")
+			return
+		}
+	}
+
+	// do { } while (cond) when one conditional jump back closes every trip round
+	var latches []int
+	for n := range l.body {
+		for _, s := range d.succs(n) {
+			if s == l.header {
+				latches = append(latches, n)
+			}
+		}
+	}
+	if len(latches) == 1 && d.postDominates(latches[0], l.header) {
+		succs := d.cfg.Blocks[latches[0]].Succs
+		if _, _, ok := d.statements(d.cfg.Blocks[latches[0]]); ok && len(succs) == 2 && (succs[0] == l.exit || succs[1] == l.exit) {
+			l.latch = latches[0]
+			l.depth = depth + 1
+		}
+	}
+
+	d.label(l.header)
+	if l.latch != noBlock {
+		d.line(depth, "do {")
+	} else {
+		d.line(depth, "while (1) {")
+	}
+	next := d.block(l.header, l, depth+1)
+	d.region(next, noBlock, l, depth+1)
+	d.trimContinue(depth + 1)
+
+	switch {
+	case l.latch == noBlock:
+		d.line(depth, "}")
+	case l.cond != "":
+		d.line(depth, fmt.Sprintf("} while (%s);", l.cond))
+	default:
+		// The latch ended up nested, its jumps are already continue and break
+		d.line(depth, "} while (1);")
+	}
+}
+
+func (d *decompiler) postDominates(a, b int) bool {
+	for {
+		if a == b {
+			return true
+		}
+		p, ok := d.ipdom[b]
+		if !ok || p == noBlock || p == b {
+			return false
+		}
+		b = p
+	}
+}
+
+// A continue at the very end of a loop body says nothing
+func (d *decompiler) trimContinue(depth int) {
+	last := len(d.lines) - 1
+	if d.lines[last].depth == depth && d.lines[last].text == "continue;" {
+		d.lines = d.lines[:last]
+	}
+}
+
+// Block prints one block and whatever structure its last instruction starts, returning
+// where to carry on
+func (d *decompiler) block(b int, lp *loop, depth int) int {
+	blk := d.cfg.Blocks[b]
+	d.emitted[b] = true
+	d.label(b)
+
+	stmts, cond, isCond := d.statements(blk)
+	for _, s := range stmts {
+		d.line(depth, terminate(s))
+	}
+
+	last := blk.Instrs[len(blk.Instrs)-1]
+	m := strings.TrimPrefix(last.Mnemonic, "SGN ")
+	next := noBlock
+
+	switch {
+	case isCond && lp != nil && b == lp.latch && depth == lp.depth:
+		if blk.Succs[0] == lp.header {
+			lp.cond = cond.String()
+		} else {
+			lp.cond = cond.not().String()
+		}
+
+	case isCond && lp != nil && d.leavesLoop(blk, lp):
+		// if (cond) { break; } and carry on with the other way, rather than nesting it
+		taken, fall := blk.Succs[0], blk.Succs[1]
+		if taken == lp.exit || taken == lp.header {
+			d.line(depth, fmt.Sprintf("if (%s) {", cond))
+			d.region(taken, noBlock, lp, depth+1)
+			next = fall
+		} else {
+			d.line(depth, fmt.Sprintf("if (%s) {", cond.not()))
+			d.region(fall, noBlock, lp, depth+1)
+			next = taken
+		}
+		d.line(depth, "}")
+
+	case isCond:
+		join := d.join(b, lp)
+		taken, fall := blk.Succs[0], noBlock
+		if len(blk.Succs) > 1 {
+			fall = blk.Succs[1]
+		}
+
+		switch {
+		case taken == join:
+			d.line(depth, fmt.Sprintf("if (%s) {", cond.not()))
+			d.region(fall, join, lp, depth+1)
+		case fall == join:
+			d.line(depth, fmt.Sprintf("if (%s) {", cond))
+			d.region(taken, join, lp, depth+1)
+		default:
+			d.line(depth, fmt.Sprintf("if (%s) {", cond))
+			d.region(taken, join, lp, depth+1)
+			d.line(depth, "} else {")
+			d.region(fall, join, lp, depth+1)
+		}
+		d.line(depth, "}")
+		next = join
+
+	case m == "TIJMP" && len(blk.Succs) > 0:
+		next = d.switchBlock(blk, lp, depth)
+
+	case m == "RET":
+		d.line(depth, "return;")
+
+	case m == "BR" || m == "EBR" || m == "TIJMP" || m == "RST":
+		d.line(depth, d.rename(last.PseudoCode, last)+";")
+
+	default:
+		if len(blk.Succs) > 0 {
+			next = blk.Succs[0]
+		}
+	}
+	return next
+}
+
+// One way out of the block is break or continue and the other stays in the loop
+func (d *decompiler) leavesLoop(blk *BasicBlock, lp *loop) bool {
+	if len(blk.Succs) != 2 {
+		return false
+	}
+	out := func(s int) bool { return s == lp.exit || s == lp.header }
+	a, b := blk.Succs[0], blk.Succs[1]
+	return out(a) != out(b) && (lp.body[a] || lp.body[b])
+}
+
+func (d *decompiler) switchBlock(blk *BasicBlock, lp *loop, depth int) int {
+	tijmp := blk.Instrs[len(blk.Instrs)-1]
+	base, _ := d.h.tijmpBase(d.an, tijmp)
+	mask := int(tijmp.RawOps[1])
+	ops := tijmp.pseudoOperands()
+
+	join := d.join(blk.Start, lp)
+
+	// Cases that share a target share a body
+	e := NewEmulator(d.h.block)
+	cases := make(map[int][]int)
+	for i := 0; i <= mask; i++ {
+		if i&mask != i {
+			continue
+		}
+		t := tijmp.Address&^0xFFFF | e.Read16(e.Data16(base+2*i))
+		cases[t] = append(cases[t], i)
+	}
+
+	d.line(depth, d.rename(fmt.Sprintf("switch (%s & %s) {", ops["INDEX"], ops["#MASK"]), tijmp))
+	for _, t := range blk.Succs {
+		for _, v := range cases[t] {
+			d.line(depth, fmt.Sprintf("case %d:", v))
+		}
+		if t == join {
+			d.line(depth+1, "break;")
+			continue
+		}
+		d.region(t, join, lp, depth+1)
+		d.line(depth+1, "break;")
+	}
+	d.line(depth, "}")
+	return join
+}
+
+// Statements of a block without its closing jump. A compare feeding the jump is folded
+// into the condition rather than printed.
+func (d *decompiler) statements(blk *BasicBlock) ([]string, condition, bool) {
+	instrs := blk.Instrs
+	last := instrs[len(instrs)-1]
+	m := strings.TrimPrefix(last.Mnemonic, "SGN ")
+
+	isCond := false
+	var cond condition
+	skip := -1
+
+	switch {
+	case m == "JBC" || m == "JBS" || m == "DJNZ" || m == "DJNZW":
+		isCond = true
+		ops := last.pseudoOperands()
+		switch m {
+		case "JBS":
+			cond = condition{raw: fmt.Sprintf("%s & (1 << %s)", ops["BYTEREG"], ops["BITNO"])}
+			if name, _, ok := d.h.testedBit(last); ok {
+				cond = condition{raw: name}
+			}
+		case "JBC":
+			cond = condition{raw: fmt.Sprintf("!(%s & (1 << %s))", ops["BYTEREG"], ops["BITNO"])}
+			if name, _, ok := d.h.testedBit(last); ok {
+				cond = condition{raw: "!" + name}
+			}
+		case "DJNZ":
+			cond = condition{left: "--" + ops["BREG"], op: "!=", right: "0"}
+		case "DJNZW":
+			cond = condition{left: "--" + ops["WREG"], op: "!=", right: "0"}
+		}
+		cond = d.renameCondition(cond, last)
+
+	case last.Flags.Tests != 0:
+		isCond = true
+		cond = condition{raw: pseudoFlagTests[m]}
+		for i := len(instrs) - 2; i >= 0; i-- {
+			if instrs[i].Flags.Changes()&last.Flags.Tests == 0 {
+				continue
+			}
+			cond = d.renameCondition(flagCondition(instrs[i], m), instrs[i])
+			if strings.HasPrefix(instrs[i].Mnemonic, "CMP") && cond.op != "" {
+				skip = i
+			}
+			break
+		}
+	}
+
+	var body Instructions
+	for i, instr := range instrs {
+		if i == skip {
+			continue
+		}
+		if i == len(instrs)-1 && (isCond || !fallsOn(instr)) {
+			break
+		}
+		body = append(body, instr)
+	}
+	return d.idiomStatements(body), cond, isCond
+}
+
+// Jumps, returns and the like are handled by the structure, not printed as statements
+func fallsOn(instr Instruction) bool {
+	_, falls := flow(instr)
+	return falls
+}
+
+// The condition a jump tests, given the instruction that set the flags
+func flagCondition(src Instruction, jump string) condition {
+	ops := src.pseudoOperands()
+	m := strings.TrimPrefix(src.Mnemonic, "SGN ")
+
+	relations := map[string]struct {
+		op     string
+		signed bool
+	}{
+		"JE": {"==", false}, "JNE": {"!=", false},
+		"JGT": {">", true}, "JGE": {">=", true}, "JLT": {"<", true}, "JLE": {"<=", true},
+		"JH": {">", false}, "JNH": {"<=", false}, "JC": {">=", false}, "JNC": {"<", false},
+	}
+
+	rel, ok := relations[jump]
+	switch {
+	case !ok:
+	case m == "CMP" || m == "CMPB" || m == "CMPL":
+		c := condition{left: ops["DEST"], op: rel.op, right: ops["SRC"], signed: rel.signed}
+		if c.left == "0" {
+			c = c.swap()
+		}
+		return c
+	case ops["DEST"] != "" && jump != "JC" && jump != "JNC" && jump != "JH" && jump != "JNH":
+		return condition{left: ops["DEST"], op: rel.op, right: "0", signed: rel.signed}
+	}
+	return condition{raw: pseudoFlagTests[jump]}
+}
+
+type condition struct {
+	left, op, right string
+	signed          bool
+	raw             string // when it isn't a comparison
+}
+
+var negated = map[string]string{"==": "!=", "!=": "==", ">": "<=", "<=": ">", "<": ">=", ">=": "<"}
+var mirrored = map[string]string{"==": "==", "!=": "!=", ">": "<", "<": ">", ">=": "<=", "<=": ">="}
+
+func (c condition) not() condition {
+	if c.op != "" {
+		c.op = negated[c.op]
+		return c
+	}
+	if strings.HasPrefix(c.raw, "!") && !strings.ContainsAny(c.raw, " &|") {
+		c.raw = c.raw[1:]
+	} else if strings.HasPrefix(c.raw, "!(") && strings.HasSuffix(c.raw, ")") && strings.Count(c.raw, "(") == strings.Count(c.raw[2:len(c.raw)-1], "(")+1 {
+		c.raw = c.raw[2 : len(c.raw)-1]
+	} else {
+		c.raw = "!(" + c.raw + ")"
+	}
+	return c
+}
+
+func (c condition) swap() condition {
+	c.left, c.right = c.right, c.left
+	c.op = mirrored[c.op]
+	return c
+}
+
+func (c condition) String() string {
+	if c.op == "" {
+		return c.raw
+	}
+	if c.signed {
+		return fmt.Sprintf("(signed)%s %s %s", c.left, c.op, c.right)
+	}
+	return fmt.Sprintf("%s %s %s", c.left, c.op, c.right)
+}
+
+// Text from instrs with the registers that are variables there by name
+func (d *decompiler) rename(text string, instrs ...Instruction) string {
+	return renameVariables(d.vars, text, instrs...)
+}
+
+func (d *decompiler) renameCondition(c condition, instr Instruction) condition {
+	c.left, c.right, c.raw = d.rename(c.left, instr), d.rename(c.right, instr), d.rename(c.raw, instr)
+	return c
+}
+
+// String prints the lines with their labels, only for blocks something jumps to
+func (d *decompiler) String() string {
+	var out []string
+	for _, l := range d.lines {
+		for _, b := range l.labels {
+			if d.gotos[b] {
+				out = append(out, fmt.Sprintf("L_%X:", b))
+			}
+		}
+		out = append(out, strings.Repeat("    ", l.depth)+l.text)
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// DecompileAll decompiles every subroutine the crawl found, in address order
+func (h *DisAsm) DecompileAll(an *Analysis) (string, error) {
+	var subs []int
+	for adr := range an.Subroutines {
+		subs = append(subs, adr)
+	}
+	sort.Ints(subs)
+
+	if len(subs) == 0 {
+		return "", errors.New("No subroutines found")
+	}
+
+	var out []string
+	interpolates := make(map[int]bool)
+	for _, adr := range subs {
+		code, err := h.decompile(an, adr, interpolates)
+		if err != nil {
+			out = append(out, fmt.Sprintf("// sub_%X: %s\n", adr, err))
+			continue
+		}
+		out = append(out, code)
+	}
+	return strings.Join(out, "\n"), nil
+}
@@ -0,0 +1,180 @@
+package disasm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+/*
+	Data detection. A path the crawl takes into a table decodes as something, but not as
+	anything a compiler writes: SKIPs and reserved opcodes, RSTs, the forms real code uses
+	once in the whole image, word registers on odd addresses, one after another. Each
+	instruction is marked unlike code or not, and a window of instructions running on from
+	each other with more of them than one in a million windows of real code would have, at
+	the rate the whole analysis has them, is taken for data.
+
+	SuspectCode lists those runs. With data detection on, Analyze keeps the crawl out of
+	them and re-analyzes the functions that ran into them, the same as for a known region,
+	so the listing shows them as data.
+*/
+
+// SuspectRange is a run of decoded code that scores as data
+type SuspectRange struct {
+	Start  int
+	End    int // one past the last byte
+	Instrs int
+	Unlike int      // instructions unlike compiled code
+	Why    []string // what's unlike it
+}
+
+func (r SuspectRange) String() string {
+	return fmt.Sprintf("0x%X-0x%X %d of %d instructions unlike code (%s)", r.Start, r.End-1, r.Unlike, r.Instrs, strings.Join(r.Why, ", "))
+}
+
+// Instructions scored together
+const suspectWindow = 8
+
+// How unlikely a window has to be in code for it to be taken for data
+const suspectOdds = 1e-6
+
+// SetDataDetection turns reclassifying code that scores as data on in Analyze and Reanalyze
+func (h *DisAsm) SetDataDetection(on bool) {
+	h.detectData = on
+}
+
+// What makes instr unlike compiled code, "" if nothing. forms counts the mnemonics and
+// addressing modes of the analysis.
+func unlikeCode(instr Instruction, forms map[string]int) string {
+	m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+	switch {
+	case strings.HasSuffix(m, "Reserved"):
+		return "reserved"
+	case m == "SKIP" || m == "RST" || m == "TRAP" || m == "IDLPD" || m == "DPTS" || m == "EPTS":
+		return m
+	}
+
+	// Word and long registers only sit on their own boundaries
+	for i, op := range instr.Ops {
+		r, ok := op.(RegisterOperand)
+		if w := operandWidth(m, strings.ToUpper(instr.VarTypes[i])); ok && r.Window == 0 && w > 1 && r.Reg%w != 0 {
+			return "misaligned"
+		}
+	}
+	if forms[instr.Mnemonic+" "+instr.AddressingMode] <= 2 {
+		return "rare"
+	}
+	return ""
+}
+
+// The chance of k or more of n instructions being unlike code, when each is with chance p
+func binomialTail(n, k int, p float64) float64 {
+	tail := 0.0
+	for i := k; i <= n; i++ {
+		c := 1.0
+		for j := 0; j < i; j++ {
+			c = c * float64(n-j) / float64(j+1)
+		}
+		tail += c * math.Pow(p, float64(i)) * math.Pow(1-p, float64(n-i))
+	}
+	return tail
+}
+
+// SuspectCode lists the runs of the analysis's code that are unlike the rest of it, in
+// address order
+func (h *DisAsm) SuspectCode(an *Analysis) []SuspectRange {
+	if len(an.Opcodes) < suspectWindow {
+		return nil
+	}
+
+	forms := make(map[string]int)
+	for _, instr := range an.Opcodes {
+		forms[instr.Mnemonic+" "+instr.AddressingMode]++
+	}
+	why := make([]string, len(an.Opcodes))
+	unlike := 0
+	for i, instr := range an.Opcodes {
+		why[i] = unlikeCode(instr, forms)
+		if why[i] != "" {
+			unlike++
+		}
+	}
+
+	// How many of a window can be unlike code before it's too unlikely, at the rate the
+	// whole analysis has them
+	p := float64(unlike+1) / float64(len(an.Opcodes))
+	limit := 1
+	for limit <= suspectWindow && binomialTail(suspectWindow, limit, p) >= suspectOdds {
+		limit++
+	}
+
+	suspect := make([]bool, len(an.Opcodes))
+	for start := 0; start < len(an.Opcodes); {
+		// A run of instructions that follow on from each other
+		end := start + 1
+		for end < len(an.Opcodes) && an.Opcodes[end].Address == an.Opcodes[end-1].Address+an.Opcodes[end-1].ByteLength {
+			end++
+		}
+		for i := start; i < end; i++ {
+			n := 0
+			for j := i; j < i+suspectWindow && j < end; j++ {
+				if why[j] != "" {
+					n++
+				}
+			}
+			if n < limit {
+				continue
+			}
+			for j := i; j < i+suspectWindow && j < end; j++ {
+				suspect[j] = true
+			}
+		}
+		start = end
+	}
+
+	var found []SuspectRange
+	for i := 0; i < len(suspect); i++ {
+		if !suspect[i] {
+			continue
+		}
+		r := SuspectRange{Start: an.Opcodes[i].Address}
+		seen := make(map[string]bool)
+		for ; i < len(suspect) && suspect[i]; i++ {
+			r.Instrs++
+			r.End = an.Opcodes[i].Address + an.Opcodes[i].ByteLength
+			if w := why[i]; w != "" {
+				r.Unlike++
+				if !seen[w] {
+					seen[w] = true
+					r.Why = append(r.Why, w)
+				}
+			}
+		}
+		sort.Strings(r.Why)
+		found = append(found, r)
+	}
+	return found
+}
+
+// Keeps the crawl out of the code that scores as data and re-crawls the functions that ran
+// into it, until nothing more does
+func (h *DisAsm) reclassify(an *Analysis) (*Analysis, error) {
+	for {
+		found := h.SuspectCode(an)
+		if len(found) == 0 {
+			return an, nil
+		}
+		for _, r := range found {
+			h.detected = append(h.detected, KnownRegion{Address: r.Start, Length: r.End - r.Start, Name: fmt.Sprintf("data_%X", r.Start), Type: DataBytes})
+		}
+		sort.Sort(h.detected)
+
+		var err error
+		for _, r := range found {
+			if an, err = h.recrawl(an, r.Start, r.End); err != nil {
+				return an, err
+			}
+		}
+	}
+}
@@ -2,6 +2,8 @@ package disasm
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
@@ -19,6 +21,29 @@ type DisAsm struct {
 	memStarts       map[int]string // Starts of memory map Locations
 	memStops        map[int]string // Ends of memory map Locations
 	skip            map[int]int
+	memory          *MemoryMap
+	out             io.Writer // listing and errors, stdout unless set
+	comments        map[int]string
+	symbols         map[int]string // names for addresses, from the project
+	aliases         RegisterAliases
+	bits            BitNames
+	variables       bool // name the decompiler's temporaries
+	mergeAliases    bool
+	inlineCalls     int // instructions in a leaf the decompiler inlines, 0 for none
+	workers         int
+	detectData      bool
+	instructions    *InstructionSet
+	regionMap       RegionMap
+	known           KnownRegions   // named data, kept out of the crawl
+	detected        KnownRegions   // code that scored as data, kept out of the crawl too
+	ram             RAMSnapshot    // laid over the image for constant propagation
+	strings         map[int]string // quoted in the listing, by address
+	entries         []int          // entry points added to the vectors
+	confirmed       map[int]bool   // bytes an execution trace ran
+	translation     *AddressTranslation
+	images          []Image        // the main image and the ones added, once one is
+	decode          *DecodeOptions // which XRefs the crawl keeps
+	pseudoStyle     PseudoStyle // for the listing, HTML and export
 }
 
 var calibrations = map[string]string{
@@ -82,25 +107,176 @@ func New(calName string) *DisAsm {
 	return controller
 }
 
-func (h *DisAsm) DisAsm() error {
+// NewBlock wraps an image that is already in memory, addressed the same as the
+// pre-calibration + calibration block New builds
+func NewBlock(block []byte) *DisAsm {
+	controller := new(DisAsm)
+	controller.block = block
+	return controller
+}
 
-	h.GetInterrupts()
-	h.GetMemoryMap()
+// NewReader builds the image from the pre-calibration and calibration, without touching
+// the filesystem or the process, for use as a library. The bytes aren't trusted, an image
+// that stops short of the interrupt vectors is an error from Analyze.
+func NewReader(pre, cal io.Reader) (*DisAsm, error) {
+	preBlock, err := ioutil.ReadAll(pre)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading pre-calibration: %s", err)
+	}
+	calBlock, err := ioutil.ReadAll(cal)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading calibration: %s", err)
+	}
+	return NewBlock(append(preBlock, calBlock...)), nil
+}
 
-	log(fmt.Sprintf("Length: 0x%X", len(h.block)), nil)
+// SetComments attaches user comments to addresses, the listing and exports show them
+func (h *DisAsm) SetComments(comments map[int]string) {
+	h.comments = comments
+}
 
-	var opcodes Instructions
-	subroutines := make(map[int][]Call)
-	xrefs := make(map[int][]XRef)
-	jumps := make(map[int][]Jump)
-	other := make(map[int]bool)
-	crawled := make(map[int]int)
-	returns := 0
-	errors := 0
+// SetSymbols names addresses, the listing and exports use the names for the functions and
+// jump targets there
+func (h *DisAsm) SetSymbols(symbols map[int]string) {
+	h.symbols = symbols
+}
+
+// Comment for the end of a listing line, continued lines are lined up under the first
+func (h *DisAsm) comment(adr int) string {
+	c := h.comments[adr]
+	if c == "" {
+		return ""
+	}
+	return "    ; " + strings.Replace(c, "\n", "\n"+strings.Repeat(" ", 80)+"; ", -1)
+}
+
+// A symbol for a listing header, "[name] "
+func (h *DisAsm) symbolLabel(adr int) string {
+	if name := h.symbols[adr]; name != "" {
+		return "[" + name + "] "
+	}
+	return ""
+}
+
+// Callers is every call to a subroutine, in address order and each call site once
+func (an *Analysis) Callers(entry int) []Call {
+	var callers []Call
+	seen := make(map[int]bool)
+	for _, c := range an.Subroutines[entry] {
+		if !seen[c.CallFrom] {
+			seen[c.CallFrom] = true
+			callers = append(callers, c)
+		}
+	}
+	sort.Sort(calls(callers))
+	return callers
+}
+
+type calls []Call
+
+func (c calls) Len() int {
+	return len(c)
+}
+
+func (c calls) Less(i, j int) bool {
+	return c[i].CallFrom < c[j].CallFrom
+}
+
+func (c calls) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// The function an address is in as "sub_13E540+0x04", taken to be the nearest of the
+// sorted function entries at or below it
+func (h *DisAsm) within(entries []int, adr int) string {
+	i := sort.SearchInts(entries, adr+1) - 1
+	if i < 0 {
+		return fmt.Sprintf("0x%X", adr)
+	}
+	if entries[i] == adr {
+		return h.functionName(adr)
+	}
+	return fmt.Sprintf("%s+0x%X", h.functionName(entries[i]), adr-entries[i])
+}
+
+// SetOutput sends the listing and crawl errors to w, ioutil.Discard to keep quiet
+func (h *DisAsm) SetOutput(w io.Writer) {
+	h.out = w
+}
+
+// Block is the pre-calibration + calibration image
+func (h *DisAsm) Block() []byte {
+	return h.block
+}
+
+// Analysis is everything the crawl found, before any of it is printed
+type Analysis struct {
+	Opcodes     Instructions
+	Subroutines map[int][]Call // call targets and their callers
+	XRefs       map[int][]XRef // referenced addresses and where from
+	Jumps       map[int][]Jump // jump targets and their jumpers
+	Crawled     map[int]int    // 1 crawled, 2 known data reached, 3 failed to parse
+	Invalid     []XRef         // references to nothing in the memory map, and jumps or calls to what can't be code
+	Conflicts   []Conflict     // paths into the middle of decoded instructions
+	Returns     int
+	Errors      int
+}
+
+// Analyze crawls the code from the reset and interrupt vectors, and again from the targets
+// of indirect branches constant propagation resolves. With data detection on, code that
+// scores as data is taken out again, see SuspectCode.
+func (h *DisAsm) Analyze() (*Analysis, error) {
+	for {
+		an, branches, err := h.analyze()
+		if err == nil && len(branches) == 0 && h.detectData {
+			return h.reclassify(an)
+		}
+		if err != nil || len(branches) == 0 {
+			return an, err
+		}
+		h.AddEntryPoints(branches...)
+	}
+}
+
+// One crawl, and the indirect branch targets it didn't reach
+func (h *DisAsm) analyze() (*Analysis, []int, error) {
+	if h.workers > 1 {
+		return h.analyzeParallel()
+	}
+
+	if err := h.GetInterrupts(); err != nil {
+		return nil, nil, err
+	}
+	h.GetMemoryMap()
+	memory := h.MemoryMap()
+
+	an := &Analysis{
+		Subroutines: make(map[int][]Call),
+		XRefs:       make(map[int][]XRef),
+		Jumps:       make(map[int][]Jump),
+		Crawled:     make(map[int]int),
+	}
 
 	// Program Counter - Start Address: 0x172080
-	pcs := []int{0x172080}
-	pcs = append(pcs, h.intRoutineAdrs...)
+	pcs := h.roots()
+	h.crawl(an, pcs, memory)
+
+	sort.Sort(an.Opcodes)
+	h.findConflicts(an)
+	h.trackWindows(an, pcs)
+	branches := h.propagateConstants(an, pcs)
+	an.pairBranches()
+
+	return an, branches, nil
+}
+
+// Crawls from pcs, and from every jump and call target filed in an that hasn't been
+// crawled yet, appending what it decodes to an.Opcodes
+func (h *DisAsm) crawl(an *Analysis, pcs []int, memory *MemoryMap) {
+	subroutines := an.Subroutines
+	jumps := an.Jumps
+	crawled := an.Crawled
+	other := make(map[int]bool)
 
 	loops := 50
 
@@ -160,73 +336,140 @@ func (h *DisAsm) DisAsm() error {
 				continue Loop
 			}
 
+			// Known data, the path ends here
+			if !h.decodable(pc) {
+				crawled[pc] = 2
+				pc = 0xFFFFFF
+				continue Loop
+			}
+
 			// The Parser™
 			b := h.block[pc : pc+10]
-			instr, err := Parse(b, pc)
+			instr, err := h.parse(pc)
 			crawled[pc] = 1
 			for i := 1; i < instr.ByteLength; i++ {
 				crawled[i+pc] = 1
 			}
 
 			if err != nil {
-				errors++
-				log(fmt.Sprintf("ERROR!! Address: 0x%X		Instruction %X", pc, b), err)
+				an.Errors++
+				h.log(fmt.Sprintf("ERROR!! Address: 0x%X		Instruction %X", pc, b), err)
 				crawled[pc] = 3
 				pc = 0xFFFFFF
 				continue Loop
 			}
 
 			// Append our instruction to our opcodes list
-			opcodes = append(opcodes, instr)
+			an.Opcodes = append(an.Opcodes, instr)
 
-			// Append our XRefs to our XRefs list
-			for XRefAdd, XRefVal := range instr.XRefs {
-				xrefs[XRefAdd] = append(xrefs[XRefAdd], XRefVal...)
+			next, ok := an.record(instr, memory)
+			if !ok {
+				pc = 0xFFFFFF
+				continue Loop
 			}
+			pc = next
 
-			// Append our Call addresses to the subroutines list
-			for CallAdd, CallVal := range instr.Calls {
-				subroutines[CallAdd] = append(subroutines[CallAdd], CallVal...)
+		}
+	}
+}
+
+// Files an instruction's XRefs, calls and jumps. Returns where the crawl goes next, the
+// next instruction or an unconditional jump's target, false if the path ends here.
+func (an *Analysis) record(instr Instruction, memory *MemoryMap) (int, bool) {
+
+	// Append our XRefs to our XRefs list, tagged with what they point at
+	for XRefAdd, XRefVal := range instr.XRefs {
+		kind := memory.Kind(XRefAdd)
+		for _, x := range XRefVal {
+			x.Kind = kind
+			if kind == KindNone || kind == KindReserved {
+				an.Invalid = append(an.Invalid, x)
 			}
+			an.XRefs[XRefAdd] = append(an.XRefs[XRefAdd], x)
+		}
+	}
 
-			// Append our Jumps to our Jumps list
-			for JumpAdd, JumpVal := range instr.Jumps {
-				// If this is not a conditional jump, point the program counter at the address
-				switch instr.Mnemonic {
-				case "SJMP", "EJMP", "LJMP", "TIJMP":
-					jumps[JumpAdd] = append(jumps[JumpAdd], JumpVal...)
-					//log(instr.Mnemonic, nil)
-					pc = JumpAdd
-					continue Loop
-				case "EBR", "BR":
-					pc = 0xFFFFFF // TODO!!!!!!!!
-					continue Loop
-				default:
-					jumps[JumpAdd] = append(jumps[JumpAdd], JumpVal...)
-				}
+	// Append our Call addresses to the subroutines list
+	for CallAdd, CallVal := range instr.Calls {
+		if !memory.Executable(CallAdd) {
+			an.Invalid = append(an.Invalid, XRef{String: fmt.Sprintf("0x%X", CallAdd), Mnemonic: instr.Mnemonic, XRefFrom: instr.Address, XRefTo: CallAdd, Kind: memory.Kind(CallAdd)})
+			continue
+		}
+		an.Subroutines[CallAdd] = append(an.Subroutines[CallAdd], CallVal...)
+	}
 
+	// Append our Jumps to our Jumps list
+	for JumpAdd, JumpVal := range instr.Jumps {
+		indirect := instr.Mnemonic == "EBR" || instr.Mnemonic == "BR"
+		if !indirect && !memory.Executable(JumpAdd) {
+			an.Invalid = append(an.Invalid, XRef{String: fmt.Sprintf("0x%X", JumpAdd), Mnemonic: instr.Mnemonic, XRefFrom: instr.Address, XRefTo: JumpAdd, Kind: memory.Kind(JumpAdd)})
+			switch instr.Mnemonic {
+			case "SJMP", "EJMP", "LJMP", "TIJMP":
+				return 0, false
 			}
+			continue
+		}
 
-			// Subroutine Returns and Resets {
-			if instr.Mnemonic == "RET" || instr.Mnemonic == "RST" {
-				returns++
-				pc = 0xFFFFFF
-				continue Loop
-			}
+		// If this is not a conditional jump, point the program counter at the address
+		switch instr.Mnemonic {
+		case "SJMP", "EJMP", "LJMP", "TIJMP":
+			an.Jumps[JumpAdd] = append(an.Jumps[JumpAdd], JumpVal...)
+			return JumpAdd, true
+		case "EBR", "BR":
+			return 0, false // TODO!!!!!!!!
+		default:
+			an.Jumps[JumpAdd] = append(an.Jumps[JumpAdd], JumpVal...)
+		}
 
-			// If we havent unconditionally jumped, move our program counter the length of this op
-			pc += instr.ByteLength
+	}
 
-		}
+	// Subroutine Returns and Resets
+	if instr.Mnemonic == "RET" || instr.Mnemonic == "RST" {
+		an.Returns++
+		return 0, false
 	}
 
-	log(fmt.Sprintf("Found [%d] instructions", len(opcodes)), nil)
-	log(fmt.Sprintf("Found [%d] XRefs", len(xrefs)), nil)
-	log(fmt.Sprintf("Found [%d] Subroutines", len(subroutines)), nil)
-	log(fmt.Sprintf("Found [%d] Returns", returns), nil)
-	log(fmt.Sprintf("Found [%d] Jumps", len(jumps)), nil)
+	// If we havent unconditionally jumped, move on the length of this op
+	return instr.Address + instr.ByteLength, true
+}
+
+func (h *DisAsm) DisAsm() error {
+	an, err := h.Analyze()
+	if err != nil {
+		return err
+	}
+	return h.Listing(an)
+}
+
+// Listing prints the disassembly from an analysis already made, or restored from a database
+func (h *DisAsm) Listing(an *Analysis) error {
+	if len(an.Opcodes) == 0 {
+		return fmt.Errorf("Nothing to list, the analysis found no instructions")
+	}
 
-	sort.Sort(opcodes)
+	h.log(fmt.Sprintf("Length: 0x%X", len(h.block)), nil)
+
+	opcodes := an.Opcodes
+	subroutines := an.Subroutines
+	xrefs := an.XRefs
+	jumps := an.Jumps
+	crawled := an.Crawled
+	returns := an.Returns
+	errors := an.Errors
+
+	h.log(fmt.Sprintf("Found [%d] instructions", len(opcodes)), nil)
+	h.log(fmt.Sprintf("Found [%d] XRefs", len(xrefs)), nil)
+	h.log(fmt.Sprintf("Found [%d] Subroutines", len(subroutines)), nil)
+	h.log(fmt.Sprintf("Found [%d] Returns", returns), nil)
+	h.log(fmt.Sprintf("Found [%d] Jumps", len(jumps)), nil)
+	h.log(fmt.Sprintf("Found [%d] invalid references", len(an.Invalid)), nil)
+	for _, x := range an.Invalid {
+		h.log(fmt.Sprintf("    0x%X %s -> 0x%X [%s]", x.XRefFrom, x.Mnemonic, x.XRefTo, x.Kind), nil)
+	}
+	h.log(fmt.Sprintf("Found [%d] paths into the middle of an instruction", len(an.Conflicts)), nil)
+	for _, c := range an.Conflicts {
+		h.log("    "+c.String(), nil)
+	}
 
 	// Print out the stuff before the Assembly
 	for chkAdr := 0; chkAdr < opcodes[0].Address; chkAdr++ {
@@ -242,56 +485,48 @@ func (h *DisAsm) DisAsm() error {
 				}
 			}
 
-			log(fmt.Sprintf("======== XREF_ 0x%X %s \n%s", chkAdr, regName("", chkAdr), referers), nil)
-
-			address := addSpaces(fmt.Sprintf("[0x%X]   X: ", chkAdr), 20)
-			shortDesc := fmt.Sprintf("%.2X ", h.block[chkAdr])
-
-			log(address+shortDesc, nil)
-
-		} else if crawled[chkAdr] != 1 {
-			address := addSpaces(fmt.Sprintf("[0x%X] %d ?: ", chkAdr, crawled[chkAdr]), 20)
-			shortDesc := fmt.Sprintf("%.2X ", h.block[chkAdr])
+			h.log(fmt.Sprintf("======== XREF_ 0x%X [%s] %s \n%s", chkAdr, xrefs[chkAdr][0].Kind, regName("", chkAdr), referers), nil)
+		}
 
-			for i := 1; i < 32; i++ {
-				if i%8 == 0 {
-					shortDesc += " "
-				}
-				chkAdr++
-				if chkAdr >= len(h.block) {
-					break
-				} else if h.memStarts[chkAdr] != "" || h.memStops[chkAdr] != "" {
-					shortDesc += fmt.Sprintf("%.2X ", h.block[chkAdr])
-					break
-				} else if crawled[chkAdr] != 1 && xrefs[chkAdr] == nil {
-					shortDesc += fmt.Sprintf("%.2X ", h.block[chkAdr])
-				} else {
-					chkAdr--
-					break
-				}
+		if crawled[chkAdr] != 1 {
+			lines, next := h.dataLines(an, chkAdr)
+			for _, line := range lines {
+				h.log(line, nil)
 			}
-			log(address+shortDesc, nil)
+			chkAdr = next - 1
 		}
 
 	}
 
 	// Print out the Assembly
 
-	for index, instr := range opcodes {
+	functions := h.roots()
+	for adr := range subroutines {
+		functions = append(functions, adr)
+	}
+	sort.Ints(functions)
+
+	counted := make(map[int]Loop)
+	for _, l := range an.Loops() {
+		counted[l.DJNZ] = l
+	}
+
+	for _, instr := range opcodes {
 
 		h.doMemoryMap(instr.Address)
 
 		if subroutines[instr.Address] != nil {
 			callers := ""
-			for _, caller := range subroutines[instr.Address] {
-				callers = callers + fmt.Sprintf("  ============================================================= [CALLED FROM 0x%X - %s] \n", caller.CallFrom, caller.Mnemonic)
+			called := an.Callers(instr.Address)
+			for _, caller := range called {
+				callers = callers + fmt.Sprintf("  ============================================================= [CALLED FROM 0x%X - %s - %s] \n", caller.CallFrom, caller.Mnemonic, h.within(functions, caller.CallFrom))
 			}
-			log(fmt.Sprintf("\n======== SUBROUTINE_ 0x%X ==================================================================================\n%s", instr.Address, callers), nil)
+			h.log(fmt.Sprintf("\n======== SUBROUTINE_ 0x%X %s[CALLERS: %d] ==================================================================================\n%s", instr.Address, h.symbolLabel(instr.Address), len(called), callers), nil)
 		}
 
 		if h.intRoutineNames[instr.Address] != "" {
 
-			log(fmt.Sprintf("\n======== INTERRUPT ROUTINE_ %s ==================================================================================", h.intRoutineNames[instr.Address]), nil)
+			h.log(fmt.Sprintf("\n======== INTERRUPT ROUTINE_ %s ==================================================================================", h.intRoutineNames[instr.Address]), nil)
 		}
 
 		if jumps[instr.Address] != nil {
@@ -299,41 +534,11 @@ func (h *DisAsm) DisAsm() error {
 			for _, jumper := range jumps[instr.Address] {
 				jumpers = jumpers + fmt.Sprintf("  ============================================================= [JUMP FROM 0x%X - %s] \n", jumper.JumpFrom, jumper.Mnemonic)
 			}
-			log(fmt.Sprintf("\n======== JUMP_ 0x%X \n%s", instr.Address, jumpers), nil)
+			h.log(fmt.Sprintf("\n======== JUMP_ 0x%X %s\n%s", instr.Address, h.symbolLabel(instr.Address), jumpers), nil)
 		}
 
 		if instr.Ignore == false {
 
-			if instr.Mnemonic == "CMPB" || instr.Mnemonic == "CMP" {
-				switch opcodes[index+1].Mnemonic {
-
-				//case "JNST":
-				case "JNH":
-					instr.PseudoCode = strings.Replace(instr.PseudoCode, "==", "<=", 1)
-				case "JGT":
-					instr.PseudoCode = strings.Replace(instr.PseudoCode, "==", ">", 1)
-				//case "JNC":
-				//case "JNVT":
-				//case "JNV":
-				case "JGE":
-					instr.PseudoCode = strings.Replace(instr.PseudoCode, ">=", "!=", 1)
-				case "JNE":
-					instr.PseudoCode = strings.Replace(instr.PseudoCode, "==", "!=", 1)
-				//case "JST":
-				case "JH":
-					instr.PseudoCode = strings.Replace(instr.PseudoCode, "==", ">", 1)
-				case "JLE":
-					instr.PseudoCode = strings.Replace(instr.PseudoCode, "==", "<=", 1)
-				//case "JC":
-				//case "JVT":
-				//case "JV":
-				case "JLT":
-					instr.PseudoCode = strings.Replace(instr.PseudoCode, "==", "<", 1)
-
-				}
-
-			}
-
 			address := addSpaces(fmt.Sprintf("[0x%X]", instr.Address), 20)
 			//shortDesc := addSpaces(fmt.Sprintf("%s %s", instr.Description, instr.Mnemonic), 45)
 			shortDesc := addSpaces(fmt.Sprintf("%s %s 0x%X 0x%X", instr.Description, instr.Mnemonic, instr.Op, instr.Raw), 45)
@@ -341,17 +546,21 @@ func (h *DisAsm) DisAsm() error {
 			var l1 string
 
 			if !instr.Checked {
-				log("#### ERROR DISASEMBLING OPCODE ####", nil)
+				h.log("#### ERROR DISASEMBLING OPCODE ####", nil)
 			}
 
 			// Pseudo Code
 			l1 = addSpaces(l1, 15)
-			l1 += fmt.Sprintf("%s", instr.PseudoCode)
+			l1 += fmt.Sprintf("%s", h.pseudo(instr))
 
-			log(address+shortDesc+l1, nil)
+			note := h.comment(instr.Address)
+			if l, ok := counted[instr.Address]; ok {
+				note += "    ; " + l.String()
+			}
+			h.log(address+shortDesc+l1+note, nil)
 
 			if instr.Mnemonic == "RET" {
-				log("\n== RETURN FROM SUBROUTINE ===============================================================================\n", nil)
+				h.log("\n== RETURN FROM SUBROUTINE ===============================================================================\n", nil)
 			}
 
 		}
@@ -366,34 +575,18 @@ func (h *DisAsm) DisAsm() error {
 				h.doMemoryMap(instr.Address)
 				break Check
 
-			} else if h.vectorAdr[chkAdr] != "" { // Vector Addresses
-				address := addSpaces(fmt.Sprintf("[0x%X]   V: ", chkAdr), 20)
-				shortDesc := fmt.Sprintf("%.2X		(%s)", h.block[chkAdr:chkAdr+2], h.vectorAdr[chkAdr])
-				log(address+shortDesc, nil)
+			} else if h.vectorAdr[chkAdr] != "" && chkAdr+1 < len(h.block) { // Vector Addresses
+				address := addSpaces(fmt.Sprintf("[0x%X]", chkAdr), 20)
+				shortDesc := fmt.Sprintf("DCW   0x%02X%02X		(%s)", h.block[chkAdr+1], h.block[chkAdr], h.vectorAdr[chkAdr])
+				h.log(address+shortDesc+h.comment(chkAdr), nil)
 				chkAdr++
 
 			} else if crawled[chkAdr] != 1 { // Crawled but not parsed
-				address := addSpaces(fmt.Sprintf("[0x%X] %d ?: ", chkAdr, crawled[chkAdr]), 20)
-				shortDesc := fmt.Sprintf("%.2X ", h.block[chkAdr])
-				for i := 1; i < 32; i++ {
-					if i%8 == 0 {
-						shortDesc += " "
-					}
-					chkAdr++
-					if chkAdr >= len(h.block) {
-						h.doMemoryMap(instr.Address)
-						break
-					} else if h.memStarts[chkAdr] != "" || h.memStops[chkAdr] != "" {
-						shortDesc += fmt.Sprintf("%.2X ", h.block[chkAdr])
-						break
-					} else if crawled[chkAdr] != 1 && xrefs[chkAdr] == nil && h.vectorAdr[chkAdr] == "" {
-						shortDesc += fmt.Sprintf("%.2X ", h.block[chkAdr])
-					} else {
-						chkAdr--
-						break
-					}
+				lines, next := h.dataLines(an, chkAdr)
+				for _, line := range lines {
+					h.log(line, nil)
 				}
-				log(address+shortDesc, nil)
+				chkAdr = next - 1
 			} else { // Bomb out
 				break Check
 			}
@@ -410,10 +603,10 @@ func (h *DisAsm) DisAsm() error {
 		}
 	}
 
-	log(fmt.Sprintf("UNCRAWLED ADDRESSES: %d", count), nil)
-	log(fmt.Sprintf("CRAWLED ADDRESSES: %d", len(crawled)), nil)
-	log(fmt.Sprintf("ERRORS: %d", errors), nil)
-	log(fmt.Sprintf("Found [%d] instructions", len(opcodes)), nil)
+	h.log(fmt.Sprintf("UNCRAWLED ADDRESSES: %d", count), nil)
+	h.log(fmt.Sprintf("CRAWLED ADDRESSES: %d", len(crawled)), nil)
+	h.log(fmt.Sprintf("ERRORS: %d", errors), nil)
+	h.log(fmt.Sprintf("Found [%d] instructions", len(opcodes)), nil)
 
 	return nil
 }
@@ -462,6 +655,18 @@ func dbg(kind string, err error) {
 	}
 }
 
+func (h *DisAsm) log(kind string, err error) {
+	out := h.out
+	if out == nil {
+		out = os.Stdout
+	}
+	if err == nil {
+		fmt.Fprintf(out, " %s\n", kind)
+	} else {
+		fmt.Fprintf(out, "[ERROR - %s]: %s\n", kind, err)
+	}
+}
+
 func log(kind string, err error) {
 	if err == nil {
 		fmt.Printf(" %s\n", kind)
@@ -0,0 +1,143 @@
+package disasm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// A small image, every vector at a routine that calls a subroutine and spins
+func embedImage() (pre, cal []byte) {
+	pre = make([]byte, 0x170000)
+	cal = make([]byte, 0x10000)
+	for vec := range interruptVectors {
+		cal[vec-0x170000] = 0x80
+		cal[vec-0x170000+1] = 0x20
+	}
+	copy(cal[0x2080:], []byte{
+		0xEF, 0x02, 0x00, // LCALL 0x172085
+		0x27, 0xFE, // SJMP 0x172083
+		0xF0, // RET
+	})
+	return pre, cal
+}
+
+// A full analysis from readers, with the listing and exports going to buffers, run in a
+// directory it can't write to with the temp directory and home pointed there too. Nothing
+// is written to the filesystem and nothing printed.
+func TestReadOnlySandbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "disasm-sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("TMPDIR", dir)
+	t.Setenv("HOME", dir)
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	printed := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		printed <- b
+	}()
+
+	pre, cal := embedImage()
+	var listing, export, page bytes.Buffer
+	err = func() error {
+		d, err := NewReader(bytes.NewReader(pre), bytes.NewReader(cal))
+		if err != nil {
+			return err
+		}
+		d.SetOutput(&listing)
+		an, err := d.Analyze()
+		if err != nil {
+			return err
+		}
+		if err := d.Listing(an); err != nil {
+			return err
+		}
+		if err := d.Export(an, &export); err != nil {
+			return err
+		}
+		return d.ExportHTML(an, &page)
+	}()
+
+	os.Stdout = stdout
+	w.Close()
+	if out := <-printed; len(out) > 0 {
+		t.Errorf("Printed to stdout:\n%s", out)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(listing.String(), "SUBROUTINE_ 0x172085") {
+		t.Errorf("The listing has no subroutine at 0x172085:\n%s", listing.String())
+	}
+	if export.Len() == 0 || page.Len() == 0 {
+		t.Errorf("Export wrote %d bytes, ExportHTML %d", export.Len(), page.Len())
+	}
+
+	left, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range left {
+		t.Errorf("%s written to the sandbox", fi.Name())
+	}
+}
+
+// Images too short for the vectors, or cut off in the code, are errors from the library,
+// never panics
+func TestShortImages(t *testing.T) {
+	pre, cal := embedImage()
+	full := append(pre, cal...)
+
+	// Every vector at an LCALL the image stops in the middle of
+	cut := append([]byte{}, full[:0x172142]...)
+	for vec := range interruptVectors {
+		cut[vec], cut[vec+1] = 0x40, 0x21
+	}
+	cut[0x172140], cut[0x172141] = 0xEF, 0x10
+
+	for _, image := range [][]byte{nil, full[:0x100], full[:0x172000], full[:0x17203E], full[:0x1720FF], full[:0x172100], cut} {
+		for _, workers := range []int{1, 4} {
+			d, err := NewReader(bytes.NewReader(image), bytes.NewReader(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.SetOutput(ioutil.Discard)
+			d.SetWorkers(workers)
+			an, err := d.Analyze()
+			if err == nil {
+				err = d.Listing(an)
+			}
+			if err == nil {
+				err = d.ExportHTML(an, ioutil.Discard)
+			}
+			if len(image) < 0x172100 && err == nil {
+				t.Errorf("0x%X bytes, short of the vectors, analyzed and listed", len(image))
+			}
+		}
+	}
+}
@@ -0,0 +1,826 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	A small 80C196 emulator that runs code straight out of the image, decoded with Parse.
+
+	Memory model:
+		0x0000 - 0x03FF		register file, always internal
+		16 bit data addresses	everything else lands in the image at DataPage | address
+		24 bit (extended)	page FF maps to 0x17xxxx like the code, other pages are image offsets
+
+	Writes outside the register file go to an overlay, the image itself is never changed.
+	Calls push a 4 byte return address, the extended addressing mode convention.
+*/
+
+const regFileSize = 0x400
+const spReg = 0x18
+
+// Return address pushed by Call, RET to it ends the run
+const callSentinel = 0xFFFFFE
+
+// Emulator runs 80C196 code from an image addressed the same as the DisAsm block
+type Emulator struct {
+	image    []byte
+	regs     [regFileSize]byte
+	ram      map[int]byte
+	DataPage int // image address 16 bit data accesses are based at
+	Mask     int // address bits, 24 bit jumps and calls wrap within them
+	Page     int // address bits other jumps and calls stay within, 0 to carry past
+	PC       int
+	PSW      PSW
+	States   int                        // state times so far, from CycleCount
+	Steps    int                        // instructions so far
+	MaxSteps int                        // Call gives up after this many instructions
+	Set      *InstructionSet            // decodes with the default set when nil
+	Trace    func(*Emulator, TraceStep) // called after each instruction when set
+	deltas   []Delta                    // what the instruction being traced changed
+	stubs    map[int]*SFRStub           // by register, each byte of it
+	inStep   bool                       // inside Step, stub writes wait for the end of it
+	breaks   map[int]bool
+	watches  map[int]Access
+	conds    []func(e *Emulator) bool
+	hit      *Stop // the watchpoint the instruction running touched
+	resumeAt int   // the breakpoint stopped at, run it on the next Step
+}
+
+// NewEmulator wraps an image, it isn't copied or written to
+func NewEmulator(image []byte) *Emulator {
+	e := &Emulator{image: image, DataPage: 0x170000, Mask: DefaultAddressTranslation.Mask, Page: DefaultAddressTranslation.Page, MaxSteps: 100000}
+	e.Reset()
+	return e
+}
+
+// Reset clears the registers, PSW and everything written to memory
+func (e *Emulator) Reset() {
+	e.regs = [regFileSize]byte{}
+	e.ram = make(map[int]byte)
+	e.PSW = 0
+	e.States = 0
+	e.Steps = 0
+	e.hit, e.resumeAt = nil, -1
+	for _, p := range e.stubs {
+		p.step, p.dirty = -1, false
+	}
+	e.SetReg16(spReg, regFileSize)
+}
+
+// Registers
+
+func (e *Emulator) Reg8(r int) int {
+	r %= regFileSize
+	if adr, ok := WindowAddress(int(e.regs[wsrReg]), r); ok {
+		return e.Read8(adr)
+	}
+	return e.Read8(r)
+}
+
+func (e *Emulator) Reg16(r int) int {
+	r &^= 1
+	return e.Reg8(r) | e.Reg8(r+1)<<8
+}
+
+func (e *Emulator) Reg32(r int) int {
+	r &^= 3
+	return e.Reg16(r) | e.Reg16(r+2)<<16
+}
+
+func (e *Emulator) SetReg8(r, v int) {
+	r %= regFileSize
+	if adr, ok := WindowAddress(int(e.regs[wsrReg]), r); ok {
+		e.Write8(adr, v)
+		return
+	}
+	e.Write8(r, v)
+}
+
+func (e *Emulator) SetReg16(r, v int) {
+	r &^= 1
+	e.SetReg8(r, v)
+	e.SetReg8(r+1, v>>8)
+}
+
+func (e *Emulator) SetReg32(r, v int) {
+	r &^= 3
+	e.SetReg16(r, v)
+	e.SetReg16(r+2, v>>16)
+}
+
+// Memory, by image address
+
+func (e *Emulator) Read8(addr int) int {
+	e.watch(addr, AccessRead)
+	if p, i, ok := e.stub(addr); ok {
+		return e.readStub(p, i)
+	}
+	if addr >= 0 && addr < regFileSize {
+		return int(e.regs[addr])
+	}
+	if v, ok := e.ram[addr]; ok {
+		return int(v)
+	}
+	if addr >= 0 && addr < len(e.image) {
+		return int(e.image[addr])
+	}
+	return 0xFF
+}
+
+func (e *Emulator) Read16(addr int) int {
+	return e.Read8(addr) | e.Read8(addr+1)<<8
+}
+
+func (e *Emulator) Write8(addr, v int) {
+	e.watch(addr, AccessWrite)
+	if p, i, ok := e.stub(addr); ok {
+		e.record(addr, (p.value>>uint(8*i))&0xFF, v)
+		e.writeStub(p, i, v)
+		return
+	}
+	if addr >= 0 && addr < regFileSize {
+		if addr >= 2 { // ZERO_REG
+			e.record(addr, int(e.regs[addr]), v)
+			e.regs[addr] = byte(v)
+		}
+		return
+	}
+	e.record(addr, e.Read8(addr), v)
+	e.ram[addr] = byte(v)
+}
+
+func (e *Emulator) Write16(addr, v int) {
+	e.Write8(addr, v)
+	e.Write8(addr+1, v>>8)
+}
+
+// Data16 maps a 16 bit data address to an image address
+func (e *Emulator) Data16(a int) int {
+	a &= 0xFFFF
+	if a < regFileSize {
+		return a
+	}
+	return e.DataPage | a
+}
+
+// Data24 maps an extended (24 bit) address to an image address
+func (e *Emulator) Data24(a int) int {
+	a &= 0xFFFFFF
+	if a < regFileSize {
+		return a
+	}
+	if a>>16 == 0xFF {
+		return 0x170000 | a&0xFFFF
+	}
+	return a
+}
+
+// Stack
+
+func (e *Emulator) push16(v int) {
+	sp := (e.Reg16(spReg) - 2) & 0xFFFF
+	e.SetReg16(spReg, sp)
+	e.Write16(e.Data16(sp), v)
+}
+
+func (e *Emulator) pop16() int {
+	sp := e.Reg16(spReg)
+	v := e.Read16(e.Data16(sp))
+	e.SetReg16(spReg, sp+2)
+	return v
+}
+
+func (e *Emulator) push32(v int) {
+	e.push16(v >> 16)
+	e.push16(v)
+}
+
+func (e *Emulator) pop32() int {
+	lo := e.pop16()
+	return lo | e.pop16()<<16
+}
+
+// Call runs the subroutine at addr until it returns to the caller
+func (e *Emulator) Call(addr int) error {
+	e.push32(callSentinel)
+	e.PC = addr
+	return e.Run()
+}
+
+// Run carries on from PC until the subroutine Call started returns
+func (e *Emulator) Run() error {
+	start, entry := e.Steps, e.PC
+	for e.PC != callSentinel {
+		if e.Steps-start >= e.MaxSteps {
+			return fmt.Errorf("Subroutine 0x%X didn't return within %d instructions, stopped at 0x%X", entry, e.MaxSteps, e.PC)
+		}
+		if err := e.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The mask and page jumps and calls wrap within
+func (e *Emulator) translation() AddressTranslation {
+	return AddressTranslation{Mask: e.Mask, Page: e.Page}
+}
+
+// Where a non-extended jump or call offset past next lands
+func (e *Emulator) relative(next, offset int) int {
+	return e.translation().Relative(next, next+offset)
+}
+
+// Step runs one instruction
+func (e *Emulator) Step() error {
+	pc := e.PC
+	if e.breaks[pc] && e.resumeAt != pc {
+		e.resumeAt = pc
+		return &Stop{PC: pc, Reason: "breakpoint"}
+	}
+	e.resumeAt = -1
+	b := make([]byte, 10)
+	for i := range b {
+		b[i] = byte(e.Read8(pc + i))
+	}
+
+	set := e.Set
+	if set == nil {
+		set = InstructionSets[DefaultInstructionSet]
+	}
+	instr, err := set.parse(b, pc, e.translation())
+	if err != nil {
+		return fmt.Errorf("Unable to decode 0x%X at 0x%X: %s", b[:4], pc, err)
+	}
+
+	e.PC = pc + instr.ByteLength
+	e.deltas, e.hit = e.deltas[:0], nil
+	e.inStep = true
+	taken, err := e.exec(&instr)
+	e.inStep = false
+	e.flushStubs()
+	if err != nil {
+		return fmt.Errorf("0x%X %s: %s", pc, instr.Mnemonic, err)
+	}
+
+	e.Steps++
+	if taken {
+		e.States += instr.CycleCountTaken()
+	} else {
+		e.States += instr.CycleCount()
+	}
+	if e.Trace != nil {
+		e.Trace(e, e.traceStep(pc, instr, taken))
+	}
+	return e.stopAfter(pc)
+}
+
+// Operand decoding. RawOps holds the aop bytes first, then the registers last to first.
+
+func width(mnemonic string) int {
+	switch mnemonic {
+	case "LDBZE", "LDBSE":
+		return 1
+	case "SUB":
+		return 2
+	}
+	if strings.HasSuffix(mnemonic, "B") {
+		return 1
+	}
+	return 2
+}
+
+// Reg is operand i (0 is the destination) for the registers after the aop
+func reg(instr *Instruction, i int) int {
+	return int(instr.RawOps[len(instr.RawOps)-1-i])
+}
+
+// Aop resolves the last operand to an address, or an immediate value when isImm.
+// Auto-increment happens here, so call it once per instruction.
+func (e *Emulator) aop(instr *Instruction, w int) (addr int, imm int, isImm bool) {
+	ops := instr.RawOps
+	switch instr.AddressingMode {
+	case "immediate":
+		if w == 1 {
+			return 0, int(ops[0]), true
+		}
+		return 0, int(ops[0]) | int(ops[1])<<8, true
+
+	case "indirect", "indirect+":
+		r := int(ops[0] &^ 1)
+		a := e.Reg16(r)
+		if ops[0]&1 == 1 {
+			e.SetReg16(r, a+w)
+		}
+		return e.Data16(a), 0, false
+
+	case "short-indexed", "indexed":
+		r := int(ops[0] &^ 1)
+		if ops[0]&1 == 1 {
+			return e.Data16(e.Reg16(r) + (int(ops[1]) | int(ops[2])<<8)), 0, false
+		}
+		return e.Data16(e.Reg16(r) + int(int8(ops[1]))), 0, false
+
+	case "long-indexed":
+		r := int(ops[0] &^ 1)
+		return e.Data16(e.Reg16(r) + (int(ops[1]) | int(ops[2])<<8)), 0, false
+	}
+
+	// direct, through the register window
+	if adr, ok := WindowAddress(int(e.regs[wsrReg]), int(ops[0])); ok {
+		return adr, 0, false
+	}
+	return int(ops[0]), 0, false
+}
+
+func (e *Emulator) load(addr, w int) int {
+	switch w {
+	case 1:
+		return e.Read8(addr)
+	case 4:
+		return e.Read16(addr) | e.Read16(addr+2)<<16
+	}
+	return e.Read16(addr)
+}
+
+func (e *Emulator) store(addr, w, v int) {
+	switch w {
+	case 1:
+		e.Write8(addr, v)
+	case 4:
+		e.Write16(addr, v)
+		e.Write16(addr+2, v>>16)
+	default:
+		e.Write16(addr, v)
+	}
+}
+
+func (e *Emulator) aopValue(instr *Instruction, w int) int {
+	addr, imm, isImm := e.aop(instr, w)
+	if isImm {
+		return imm
+	}
+	return e.load(addr, w)
+}
+
+// Flags
+
+func (e *Emulator) setFlag(f PSW, on bool) {
+	if on {
+		e.PSW |= f
+	} else {
+		e.PSW &^= f
+	}
+}
+
+func signExtend(v, bits uint) int {
+	shift := 64 - bits
+	return int(int64(uint64(v)<<shift) >> shift)
+}
+
+// Arith sets Z N C V VT for a + b (+ carry in), or a - b when sub. N is the sign of the true
+// result and C is set when there is no borrow, the MCS 96 way.
+func (e *Emulator) arith(a, b, w int, sub, carryIn, zeroOnlyClears bool) int {
+	bits := uint(w * 8)
+	mask := 1<<bits - 1
+	a &= mask
+	b &= mask
+
+	cin := 0
+	if carryIn && e.PSW&PSW_C != 0 {
+		cin = 1
+	}
+
+	var r, signed int
+	var carry bool
+	if sub {
+		if carryIn {
+			cin = 1 - cin // SUBC borrows when C is clear
+		}
+		r = a - b - cin
+		carry = r >= 0
+		signed = signExtend(uint(a), bits) - signExtend(uint(b), bits) - cin
+	} else {
+		r = a + b + cin
+		carry = r > mask
+		signed = signExtend(uint(a), bits) + signExtend(uint(b), bits) + cin
+	}
+	r &= mask
+
+	if zeroOnlyClears {
+		if r != 0 {
+			e.PSW &^= PSW_Z
+		}
+	} else {
+		e.setFlag(PSW_Z, r == 0)
+	}
+	e.setFlag(PSW_N, signed < 0)
+	e.setFlag(PSW_C, carry)
+	overflow := signed != signExtend(uint(r), bits)
+	e.setFlag(PSW_V, overflow)
+	if overflow {
+		e.PSW |= PSW_VT
+	}
+	return r
+}
+
+func (e *Emulator) logic(r, w int) int {
+	r &= 1<<uint(w*8) - 1
+	e.setFlag(PSW_Z, r == 0)
+	e.setFlag(PSW_N, r>>uint(w*8-1)&1 == 1)
+	e.PSW &^= PSW_C | PSW_V
+	return r
+}
+
+func (e *Emulator) condition(mnemonic string) bool {
+	z := e.PSW&PSW_Z != 0
+	n := e.PSW&PSW_N != 0
+	c := e.PSW&PSW_C != 0
+	v := e.PSW&PSW_V != 0
+	vt := e.PSW&PSW_VT != 0
+	st := e.PSW&PSW_ST != 0
+
+	switch mnemonic {
+	case "JC":
+		return c
+	case "JNC":
+		return !c
+	case "JE":
+		return z
+	case "JNE":
+		return !z
+	case "JGE":
+		return !n
+	case "JLT":
+		return n
+	case "JGT":
+		return !n && !z
+	case "JLE":
+		return n || z
+	case "JH":
+		return c && !z
+	case "JNH":
+		return !c || z
+	case "JV":
+		return v
+	case "JNV":
+		return !v
+	case "JVT":
+		e.PSW &^= PSW_VT
+		return vt
+	case "JNVT":
+		e.PSW &^= PSW_VT
+		return !vt
+	case "JST":
+		return st
+	case "JNST":
+		return !st
+	}
+	return false
+}
+
+// Exec runs a decoded instruction, e.PC already points past it. taken is true when a
+// conditional jump went.
+func (e *Emulator) exec(instr *Instruction) (taken bool, err error) {
+	m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+	ops := instr.RawOps
+	next := e.PC
+
+	switch m {
+
+	// Moves
+	case "LD", "LDB":
+		w := width(m)
+		e.store(reg(instr, 0), w, e.aopValue(instr, w))
+	case "LDBZE":
+		e.SetReg16(reg(instr, 0), e.aopValue(instr, 1))
+	case "LDBSE":
+		e.SetReg16(reg(instr, 0), signExtend(uint(e.aopValue(instr, 1)), 8))
+	case "ST", "STB":
+		w := width(m)
+		addr, _, _ := e.aop(instr, w)
+		e.store(addr, w, e.load(reg(instr, 0), w))
+	case "XCH", "XCHB":
+		w := width(m)
+		addr, _, _ := e.aop(instr, w)
+		a, b := e.load(reg(instr, 0), w), e.load(addr, w)
+		e.store(reg(instr, 0), w, b)
+		e.store(addr, w, a)
+	case "CLR", "CLRB":
+		e.store(int(ops[0]), width(m), 0)
+		e.PSW = e.PSW&^(PSW_N|PSW_C|PSW_V) | PSW_Z
+	case "PUSH":
+		e.push16(e.aopValue(instr, 2))
+	case "POP":
+		v := e.pop16()
+		addr, _, _ := e.aop(instr, 2)
+		e.store(addr, 2, v)
+	case "PUSHF":
+		e.push16(int(e.PSW)<<8 | e.Reg8(0x08))
+		e.PSW = 0
+		e.SetReg8(0x08, 0)
+	case "POPF":
+		v := e.pop16()
+		e.PSW = PSW(v >> 8)
+		e.SetReg8(0x08, v)
+	case "PUSHA":
+		e.push16(int(e.PSW)<<8 | e.Reg8(0x08))
+		e.push16(e.Reg8(0x13)<<8 | e.Reg8(0x14))
+		e.PSW = 0
+		e.SetReg8(0x08, 0)
+		e.SetReg8(0x13, 0)
+	case "POPA":
+		v := e.pop16()
+		e.SetReg8(0x13, v>>8)
+		e.SetReg8(0x14, v)
+		v = e.pop16()
+		e.PSW = PSW(v >> 8)
+		e.SetReg8(0x08, v)
+
+	// Arithmetic
+	case "ADD", "ADDB", "SUB", "SUBB", "ADDC", "ADDCB", "SUBC", "SUBCB":
+		w := width(m)
+		b := e.aopValue(instr, w)
+		a := e.load(reg(instr, 0), w)
+		if len(instr.VarStrings) == 3 {
+			a = e.load(reg(instr, 1), w)
+		}
+		sub := strings.HasPrefix(m, "SUB")
+		carry := strings.Contains(m, "C")
+		e.store(reg(instr, 0), w, e.arith(a, b, w, sub, carry, carry))
+	case "CMP", "CMPB":
+		w := width(m)
+		b := e.aopValue(instr, w)
+		e.arith(e.load(reg(instr, 0), w), b, w, true, false, false)
+	case "CMPL":
+		e.arith(e.Reg32(reg(instr, 0)), e.Reg32(reg(instr, 1)), 4, true, false, false)
+	case "NEG", "NEGB":
+		w := width(m)
+		e.store(int(ops[0]), w, e.arith(0, e.load(int(ops[0]), w), w, true, false, false))
+	case "INC", "INCB":
+		w := width(m)
+		e.store(int(ops[0]), w, e.arith(e.load(int(ops[0]), w), 1, w, false, false, false))
+	case "DEC", "DECB":
+		w := width(m)
+		e.store(int(ops[0]), w, e.arith(e.load(int(ops[0]), w), 1, w, true, false, false))
+	case "EXT":
+		v := signExtend(uint(e.Reg16(int(ops[0]))), 16)
+		e.SetReg32(int(ops[0]), e.logic(v, 4))
+	case "EXTB":
+		v := signExtend(uint(e.Reg8(int(ops[0]))), 8)
+		e.SetReg16(int(ops[0]), e.logic(v, 2))
+
+	case "MUL", "MULU", "MULB", "MULUB":
+		w := width(m)
+		b := e.aopValue(instr, w)
+		a := e.load(reg(instr, 0), w)
+		if len(instr.VarStrings) == 3 {
+			a = e.load(reg(instr, 1), w)
+		}
+		var r int
+		if instr.Signed {
+			r = signExtend(uint(a), uint(w*8)) * signExtend(uint(b), uint(w*8))
+		} else {
+			r = a * b
+		}
+		e.store(reg(instr, 0), w*2, r)
+
+	case "DIV", "DIVU", "DIVB", "DIVUB":
+		w := width(m)
+		b := e.aopValue(instr, w)
+		a := e.load(reg(instr, 0), w*2)
+		if b == 0 {
+			// The real part leaves the destination alone and sets V
+			e.PSW |= PSW_V | PSW_VT
+			break
+		}
+		var q, rem int
+		if instr.Signed {
+			sa, sb := signExtend(uint(a), uint(w*16)), signExtend(uint(b), uint(w*8))
+			q, rem = sa/sb, sa%sb
+		} else {
+			q, rem = a/b, a%b
+		}
+		overflow := q>>uint(w*8) != 0 && q>>uint(w*8) != -1
+		e.setFlag(PSW_V, overflow)
+		if overflow {
+			e.PSW |= PSW_VT
+		}
+		mask := 1<<uint(w*8) - 1
+		e.store(reg(instr, 0), w*2, q&mask|(rem&mask)<<uint(w*8))
+
+	// Logic
+	case "AND", "ANDB", "OR", "ORB", "XOR", "XORB":
+		w := width(m)
+		b := e.aopValue(instr, w)
+		a := e.load(reg(instr, 0), w)
+		if len(instr.VarStrings) == 3 {
+			a = e.load(reg(instr, 1), w)
+		}
+		var r int
+		switch strings.TrimSuffix(m, "B") {
+		case "AND":
+			r = a & b
+		case "OR":
+			r = a | b
+		case "XOR":
+			r = a ^ b
+		}
+		e.store(reg(instr, 0), w, e.logic(r, w))
+	case "NOT", "NOTB":
+		w := width(m)
+		e.store(int(ops[0]), w, e.logic(^e.load(int(ops[0]), w), w))
+
+	// Shifts, the count is immediate below 0x10, otherwise a register holding it
+	case "SHL", "SHLB", "SHLL", "SHR", "SHRB", "SHRL", "SHRA", "SHRAB", "SHRAL":
+		e.shift(m, instr)
+	case "NORML":
+		r := reg(instr, 0)
+		v := uint32(e.Reg32(r))
+		n := 0
+		for v != 0 && v&0x80000000 == 0 && n < 31 {
+			v <<= 1
+			n++
+		}
+		e.SetReg32(r, int(v))
+		e.SetReg8(int(ops[0]), n)
+		e.setFlag(PSW_Z, v == 0)
+		e.setFlag(PSW_N, v&0x80000000 != 0)
+		e.PSW &^= PSW_C
+
+	// Flow
+	case "SJMP", "SCALL":
+		target := e.relative(next, signExtend(uint(instr.Op&7)<<8|uint(ops[0]), 11))
+		if m == "SCALL" {
+			e.push32(next)
+		}
+		e.PC = target
+	case "LJMP", "LCALL":
+		target := e.relative(next, int(int16(uint16(ops[0])|uint16(ops[1])<<8)))
+		if m == "LCALL" {
+			e.push32(next)
+		}
+		e.PC = target
+	case "EJMP", "ECALL":
+		target := (next + signExtend(uint(ops[0])|uint(ops[1])<<8|uint(ops[2])<<16, 24)) & e.Mask
+		if m == "ECALL" {
+			e.push32(next)
+		}
+		e.PC = target
+	case "BR", "EBR":
+		if ops[0]&1 == 1 {
+			e.PC = e.Data24(e.Reg32(int(ops[0] &^ 1)))
+		} else {
+			e.PC = next&^0xFFFF | e.Reg16(int(ops[0]))
+		}
+	case "TIJMP":
+		index := e.Reg8(int(ops[0])) & int(ops[1])
+		table := e.Data16(e.Reg16(int(ops[2])) + 2*index)
+		e.PC = next&^0xFFFF | e.Read16(table)
+	case "RET":
+		e.PC = e.pop32()
+
+	case "JBC", "JBS":
+		set := e.Reg8(int(ops[0]))>>(instr.Op&7)&1 == 1
+		if set == (m == "JBS") {
+			e.PC = e.relative(next, int(int8(ops[1])))
+			taken = true
+		}
+	case "DJNZ", "DJNZW":
+		w := 1
+		if m == "DJNZW" {
+			w = 2
+		}
+		v := (e.load(int(ops[0]), w) - 1) & (1<<uint(w*8) - 1)
+		e.store(int(ops[0]), w, v)
+		if v != 0 {
+			e.PC = e.relative(next, int(int8(ops[1])))
+			taken = true
+		}
+
+	// Extended loads and stores
+	case "ELD", "ELDB", "EST", "ESTB":
+		w := width(m)
+		a := e.Reg32(int(ops[0]))
+		if instr.AddressingMode == "extended-indexed" {
+			a += signExtend(uint(ops[1])|uint(ops[2])<<8|uint(ops[3])<<16, 24)
+		}
+		addr := e.Data24(a)
+		r := reg(instr, 0)
+		if strings.HasPrefix(m, "ELD") {
+			e.store(r, w, e.load(addr, w))
+		} else {
+			e.store(addr, w, e.load(r, w))
+		}
+
+	case "BMOV", "BMOVI":
+		ptrs := reg(instr, 0)
+		cnt := int(ops[0])
+		src, dst := e.Reg16(ptrs), e.Reg16(ptrs+2)
+		for n := e.Reg16(cnt); n > 0; n-- {
+			e.Write16(e.Data16(dst), e.Read16(e.Data16(src)))
+			src += 2
+			dst += 2
+		}
+		if m == "BMOVI" {
+			e.SetReg16(ptrs, src)
+			e.SetReg16(ptrs+2, dst)
+			e.SetReg16(cnt, 0)
+		}
+
+	case "EBMOVI":
+		ptrs := reg(instr, 0)
+		cnt := int(ops[0])
+		src, dst := e.Reg32(ptrs)&0xFFFFFF, e.Reg32(ptrs+4)&0xFFFFFF
+		for n := e.Reg16(cnt); n > 0; n-- {
+			e.Write16(e.Data24(dst), e.Read16(e.Data24(src)))
+			src += 2
+			dst += 2
+		}
+		e.SetReg32(ptrs, src)
+		e.SetReg32(ptrs+4, dst)
+		e.SetReg16(cnt, 0)
+
+	case "SETC":
+		e.PSW |= PSW_C
+	case "CLRC":
+		e.PSW &^= PSW_C
+	case "CLRVT":
+		e.PSW &^= PSW_VT
+	case "DI":
+		e.PSW &^= PSW_I
+	case "EI":
+		e.PSW |= PSW_I
+	case "NOP", "SKIP", "DPTS", "EPTS":
+
+	default:
+		if instr.Flags.Tests != 0 && strings.HasPrefix(m, "J") {
+			if e.condition(m) {
+				e.PC = e.relative(next, int(int8(ops[0])))
+				taken = true
+			}
+			break
+		}
+		return false, fmt.Errorf("Not emulated")
+	}
+
+	return taken, nil
+}
+
+func (e *Emulator) shift(m string, instr *Instruction) {
+	ops := instr.RawOps
+	w := width(m)
+	switch m {
+	case "SHLL", "SHRL", "SHRAL":
+		w = 4
+	}
+	bits := uint(w * 8)
+	mask := uint64(1)<<bits - 1
+
+	count := int(ops[0])
+	if count >= 0x10 {
+		count = e.Reg8(count)
+	}
+	count &= 0x1F
+
+	r := reg(instr, 0)
+	v := uint64(e.load(r, w)) & mask
+	sign := v >> (bits - 1)
+
+	var carry, sticky, overflow bool
+	for i := 0; i < count; i++ {
+		sticky = sticky || carry
+		switch {
+		case strings.HasPrefix(m, "SHL"):
+			carry = v>>(bits-1)&1 == 1
+			v = (v << 1) & mask
+			if v>>(bits-1) != sign {
+				overflow = true
+			}
+		case strings.HasPrefix(m, "SHRA"):
+			carry = v&1 == 1
+			v = v>>1 | sign<<(bits-1)
+		default:
+			carry = v&1 == 1
+			v >>= 1
+		}
+	}
+
+	e.store(r, w, int(v))
+	e.setFlag(PSW_Z, v == 0)
+	e.setFlag(PSW_N, v>>(bits-1)&1 == 1)
+	if count > 0 {
+		e.setFlag(PSW_C, carry)
+	}
+	if strings.HasPrefix(m, "SHL") {
+		e.setFlag(PSW_V, overflow)
+		if overflow {
+			e.PSW |= PSW_VT
+		}
+	} else {
+		e.PSW &^= PSW_V
+		e.setFlag(PSW_ST, sticky)
+	}
+}
@@ -0,0 +1,103 @@
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+/*
+	Entry points. The 196EA starts at FF2080h out of reset (0x172080 in the image), and
+	the interrupt vectors below it each hold the 16 bit address of a routine in page FF.
+	The PTS vectors sit alongside them but point at PTS control blocks in the register
+	file rather than code, only a PTS vector that points outside the register file and
+	SFRs is taken as code. Unprogrammed vectors (0000h and FFFFh) are skipped. Dispatch
+	tables in the data are found by crawling from those and following the pointer tables
+	the code refers to, see PointerTables.
+*/
+
+// EntryPoint is an address code starts at and where it was found
+type EntryPoint struct {
+	Address int
+	Source  string // "Reset", the interrupt source, or the pointer table
+	Vector  int    // the vector or table entry the address was read from, 0 for the reset
+}
+
+func (e EntryPoint) String() string {
+	if e.Vector == 0 {
+		return fmt.Sprintf("0x%X  %s", e.Address, e.Source)
+	}
+	return fmt.Sprintf("0x%X  %s (0x%X)", e.Address, e.Source, e.Vector)
+}
+
+// Fewest entries a pointer table needs before its targets are taken as entry points
+const entryTableEntries = 4
+
+const resetAddress = 0x172080
+
+// DiscoverEntryPoints finds where code starts in an image: the reset address, the
+// interrupt and PTS vectors, and the targets of the pointer tables the code refers to.
+// An address found more than one way is listed once, by the first of those.
+func DiscoverEntryPoints(image []byte) ([]EntryPoint, error) {
+	if len(image) < resetAddress+10 {
+		return nil, errors.New("Image is too small to hold the reset and interrupt vectors")
+	}
+
+	h := NewBlock(image)
+	h.SetOutput(ioutil.Discard)
+
+	entries := append([]EntryPoint{{Address: resetAddress, Source: "Reset"}}, h.vectorEntries()...)
+
+	an, err := h.Analyze()
+	if err != nil {
+		return nil, err
+	}
+	_, tables, err := h.FollowPointerTables(an, entryTableEntries)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		for i, adr := range t.Targets {
+			entries = append(entries, EntryPoint{Address: adr, Source: "Pointer table " + t.String(), Vector: t.Start + i*t.Size})
+		}
+	}
+
+	var unique []EntryPoint
+	seen := make(map[int]bool)
+	for _, e := range entries {
+		if !seen[e.Address] {
+			seen[e.Address] = true
+			unique = append(unique, e)
+		}
+	}
+	return unique, nil
+}
+
+// The routines the interrupt and PTS vectors point at, in vector order
+func (h *DisAsm) vectorEntries() []EntryPoint {
+	if len(h.block) < 0x172140 {
+		return nil
+	}
+	memory := h.MemoryMap()
+
+	var vectors []int
+	for vec := range interruptVectors {
+		vectors = append(vectors, vec)
+	}
+	sort.Ints(vectors)
+
+	var entries []EntryPoint
+	for _, vec := range vectors {
+		intr := interruptVectors[vec]
+		v := int(h.block[vec+1])<<8 | int(h.block[vec])
+		if v == 0 || v == 0xFFFF {
+			continue
+		}
+		if kind := memory.Kind(v); intr.Type == "PTS Service" && (kind == KindSFR || kind == KindRAM) {
+			continue // a control block
+		}
+		entries = append(entries, EntryPoint{Address: v + 0x170000, Source: intr.InterruptSource, Vector: vec})
+	}
+	return entries
+}
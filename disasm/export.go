@@ -0,0 +1,67 @@
+package disasm
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+)
+
+// Export is the JSON form of a disassembly, for other tools
+type Export struct {
+	ImageCRC     uint32
+	Instructions []ExportedInstruction
+	Subroutines  []int
+	Comments     map[int]string  `json:",omitempty"` // comments at addresses that aren't instructions
+	Aliases      RegisterAliases `json:",omitempty"` // the register names operands and pseudo code show
+}
+
+// ExportedInstruction is one line of the listing
+type ExportedInstruction struct {
+	Address  int
+	Bytes    string
+	Mnemonic string
+	Operands []string
+	Pseudo   string
+	Window   int    `json:",omitempty"`
+	Comment  string `json:",omitempty"`
+}
+
+// Export writes an analysis as JSON, with the comments set on the disassembler
+func (h *DisAsm) Export(an *Analysis, w io.Writer) error {
+	ex := Export{ImageCRC: crc32.ChecksumIEEE(h.block), Comments: make(map[int]string), Aliases: h.aliases}
+
+	code := make(map[int]bool)
+	for _, instr := range an.Opcodes {
+		code[instr.Address] = true
+
+		e := ExportedInstruction{
+			Address:  instr.Address,
+			Bytes:    fmt.Sprintf("%X", instr.Raw),
+			Mnemonic: instr.Mnemonic,
+			Pseudo:   h.pseudo(instr),
+			Window:   instr.Window,
+			Comment:  h.comments[instr.Address],
+		}
+		for _, op := range instr.Ops {
+			e.Operands = append(e.Operands, h.aliases.Apply(pseudoOperand(op)))
+		}
+		ex.Instructions = append(ex.Instructions, e)
+	}
+
+	for adr := range an.Subroutines {
+		ex.Subroutines = append(ex.Subroutines, adr)
+	}
+	sort.Ints(ex.Subroutines)
+
+	for adr, c := range h.comments {
+		if !code[adr] {
+			ex.Comments[adr] = c
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ex)
+}
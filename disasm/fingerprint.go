@@ -0,0 +1,181 @@
+package disasm
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+/*
+	Function fingerprints. A function is hashed by what its instructions do and how its
+	blocks connect, leaving out every operand: addresses, registers and immediates all move
+	between calibrations, the code around them mostly doesn't. Short and long forms of the
+	same jump or call, and short and long indexing, hash the same since which one the
+	assembler picked depends on how far away things ended up.
+*/
+
+// Fingerprint identifies a function by the shape of its code
+type Fingerprint struct {
+	Entry  int
+	Hash   uint64
+	Instrs int
+	Blocks int
+}
+
+// FunctionMatch pairs a function in one image with the same function in another
+type FunctionMatch struct {
+	From   int
+	To     int
+	Instrs int
+}
+
+// Fingerprints hashes every subroutine in an analysis, by entry
+func (h *DisAsm) Fingerprints(an *Analysis) []Fingerprint {
+	var entries []int
+	for adr := range an.Subroutines {
+		entries = append(entries, adr)
+	}
+	sort.Ints(entries)
+
+	var prints []Fingerprint
+	for _, entry := range entries {
+		if fp, ok := h.fingerprint(an, entry); ok {
+			prints = append(prints, fp)
+		}
+	}
+	return prints
+}
+
+func (h *DisAsm) fingerprint(an *Analysis, entry int) (Fingerprint, bool) {
+	cfg, err := h.CFG(an, entry)
+	if err != nil {
+		return Fingerprint{}, false
+	}
+
+	starts := cfg.starts()
+	index := make(map[int]int)
+	for i, start := range starts {
+		index[start] = i
+	}
+
+	fp := Fingerprint{Entry: entry, Blocks: len(starts)}
+	hash := fnv.New64a()
+	for _, start := range starts {
+		blk := cfg.Blocks[start]
+		for _, instr := range blk.Instrs {
+			fmt.Fprintf(hash, "%s;", NormalForm(instr))
+			fp.Instrs++
+		}
+
+		// Successors by block number, not address
+		fmt.Fprint(hash, "->")
+		for _, s := range blk.Succs {
+			if i, ok := index[s]; ok {
+				fmt.Fprintf(hash, "%d,", i)
+			} else {
+				fmt.Fprint(hash, "x,")
+			}
+		}
+		fmt.Fprint(hash, "\n")
+	}
+	fp.Hash = hash.Sum64()
+	return fp, true
+}
+
+// FunctionInstructions lists a function's instructions in address order, calls aren't
+// followed
+func (h *DisAsm) FunctionInstructions(an *Analysis, entry int) (Instructions, error) {
+	cfg, err := h.CFG(an, entry)
+	if err != nil {
+		return nil, err
+	}
+	var instrs Instructions
+	for _, start := range cfg.starts() {
+		instrs = append(instrs, cfg.Blocks[start].Instrs...)
+	}
+	return instrs, nil
+}
+
+// Block starts in address order
+func (c *CFG) starts() []int {
+	var starts []int
+	for start := range c.Blocks {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+	return starts
+}
+
+// NormalForm is what an instruction does without its operands, the part a fingerprint hashes
+func NormalForm(instr Instruction) string {
+	return normalMnemonic(instr.Mnemonic) + " " + normalMode(instr.AddressingMode)
+}
+
+// Operands are an instruction's operands as the pseudo code shows them, leaving out jump and
+// call targets since those move whenever the code around them does
+func (instr Instruction) Operands() []string {
+	var ops []string
+	for i, op := range instr.Ops {
+		if strings.ToUpper(instr.VarTypes[i]) != "ADDR" {
+			ops = append(ops, pseudoOperand(op))
+		}
+	}
+	return ops
+}
+
+func normalMnemonic(m string) string {
+	switch strings.TrimPrefix(m, "SGN ") {
+	case "SJMP", "LJMP", "EJMP":
+		return "JMP"
+	case "SCALL", "LCALL", "ECALL":
+		return "CALL"
+	}
+	return m
+}
+
+func normalMode(mode string) string {
+	if strings.HasSuffix(mode, "indexed") {
+		return "indexed"
+	}
+	return mode
+}
+
+// MatchFunctions pairs the functions of two images by fingerprint. Only fingerprints found
+// once in each image are paired, and functions shorter than minInstrs are left out since
+// small helpers all look alike.
+func MatchFunctions(from, to []Fingerprint, minInstrs int) []FunctionMatch {
+	count := func(prints []Fingerprint) map[uint64][]Fingerprint {
+		m := make(map[uint64][]Fingerprint)
+		for _, fp := range prints {
+			if fp.Instrs >= minInstrs {
+				m[fp.Hash] = append(m[fp.Hash], fp)
+			}
+		}
+		return m
+	}
+	a, b := count(from), count(to)
+
+	var matches []FunctionMatch
+	for hash, fa := range a {
+		if fb := b[hash]; len(fa) == 1 && len(fb) == 1 {
+			matches = append(matches, FunctionMatch{From: fa[0].Entry, To: fb[0].Entry, Instrs: fa[0].Instrs})
+		}
+	}
+	sort.Sort(functionMatches(matches))
+	return matches
+}
+
+type functionMatches []FunctionMatch
+
+func (f functionMatches) Len() int {
+	return len(f)
+}
+
+func (f functionMatches) Less(i, j int) bool {
+	return f[i].From < f[j].From
+}
+
+func (f functionMatches) Swap(i, j int) {
+	f[i], f[j] = f[j], f[i]
+}
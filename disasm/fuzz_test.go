@@ -0,0 +1,63 @@
+package disasm
+
+import "testing"
+
+/*
+	Fuzzing. Parse gets pointed at corrupt and partial dumps, whatever bytes it's handed it
+	has to come back with an instruction or an error:
+
+		go test ./disasm -run XXX -fuzz FuzzParse
+
+	The first byte of the input picks what's fuzzed, the rest is decoded. Its low bits pick
+	a built in instruction set, with the top bit set the two bytes after it move the entry
+	of one opcode to another byte of a copy of that set first, the way a set loaded for
+	another part would, so the operand decoders see opcodes outside their row of the map.
+*/
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{0x00, 0xA1, 0x24, 0x0D, 0x30})
+	// A routine, every byte of it a start
+	f.Add([]byte{0x00, 0xFA, 0xC9, 0x00, 0x01, 0xA1, 0x00, 0x18, 0x30, 0xB3, 0x31, 0x04, 0x32, 0xDF, 0xF8, 0xEF, 0x00, 0x10, 0xF0})
+	// Cut off in the middle of an operand
+	f.Add([]byte{0x00, 0xE6, 0x00})
+	f.Add([]byte{0x01, 0xEB, 0x30, 0x00})
+	f.Add([]byte{0x03, 0x1C, 0x31})
+	// A long indexed LD moved into the short rows, EST moved into the jumps
+	f.Add([]byte{0x80, 0xA3, 0x05, 0x31, 0x30})
+	f.Add([]byte{0x80, 0x1C, 0xDF, 0x31})
+	f.Add([]byte{0x80, 0xE2, 0x30, 0x30, 0x0F})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) < 1 {
+			return
+		}
+		names := []string{"196ea", "196kr", "196kc", "196kb"}
+		set := InstructionSets[names[int(data[0]&0x7F)%len(names)]]
+
+		in := data[1:]
+		if data[0]&0x80 != 0 {
+			if len(in) < 2 {
+				return
+			}
+			instr, ok := set.Lookup(in[0], false)
+			if !ok {
+				return
+			}
+			set = set.Copy("fuzz")
+			if set.Register(in[1], false, *instr.Opcode) != nil {
+				return
+			}
+			in = in[2:]
+		}
+
+		for off := 0; off < len(in); off++ {
+			instr, err := set.Parse(in[off:], 0x100000+off)
+			if err != nil {
+				continue
+			}
+			_ = instr.String()
+			for _, style := range []PseudoStyle{PseudoC, PseudoPython, PseudoEnglish} {
+				_ = instr.Pseudo(style)
+			}
+		}
+	})
+}
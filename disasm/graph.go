@@ -0,0 +1,98 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RenderCFG draws the basic blocks of the subroutine at entry as a Graphviz digraph, for
+// dot -Tsvg. The edges out of a conditional jump are labeled with the jump and what it
+// tests, the condition on the taken edge and its negation on the fall through, and the
+// edges out of a TIJMP with their cases.
+func (h *DisAsm) RenderCFG(an *Analysis, entry int) (string, error) {
+	cfg, err := h.CFG(an, entry)
+	if err != nil {
+		return "", err
+	}
+	d := &decompiler{cfg: cfg, an: an, h: h}
+
+	var starts []int
+	for start := range cfg.Blocks {
+		starts = append(starts, start)
+	}
+	sort.Ints(starts)
+
+	var out []string
+	out = append(out, fmt.Sprintf("digraph sub_%X {", entry))
+	out = append(out, `	node [shape=box, fontname="monospace", fontsize=10];`)
+	out = append(out, `	edge [fontname="monospace", fontsize=9];`)
+
+	for _, start := range starts {
+		blk := cfg.Blocks[start]
+
+		var label string
+		for _, instr := range blk.Instrs {
+			var ops []string
+			for _, op := range instr.Ops {
+				ops = append(ops, pseudoOperand(op))
+			}
+			label += fmt.Sprintf("0x%X  %s %s\\l", instr.Address, instr.Mnemonic, dotEscape(strings.Join(ops, ", ")))
+		}
+		attrs := ""
+		if start == entry {
+			attrs = ", peripheries=2"
+		}
+		out = append(out, fmt.Sprintf("\tb%X [label=\"%s\"%s];", start, label, attrs))
+
+		for i, succ := range blk.Succs {
+			if cfg.Blocks[succ] == nil {
+				continue // not decoded
+			}
+			edge := fmt.Sprintf("\tb%X -> b%X", start, succ)
+			if l := d.edgeLabel(blk, i); l != "" {
+				edge += fmt.Sprintf(" [label=\"%s\"]", dotEscape(l))
+			}
+			out = append(out, edge+";")
+		}
+	}
+	out = append(out, "}")
+
+	return strings.Join(out, "\n") + "\n", nil
+}
+
+// Label for the edge to a block's i'th successor
+func (d *decompiler) edgeLabel(blk *BasicBlock, i int) string {
+	last := blk.Instrs[len(blk.Instrs)-1]
+	m := strings.TrimPrefix(last.Mnemonic, "SGN ")
+
+	if m == "TIJMP" {
+		base, ok := d.h.tijmpBase(d.an, last)
+		if !ok {
+			return ""
+		}
+		e := NewEmulator(d.h.block)
+		mask := int(last.RawOps[1])
+		var cases []string
+		for v := 0; v <= mask; v++ {
+			if v&mask == v && last.Address&^0xFFFF|e.Read16(e.Data16(base+2*v)) == blk.Succs[i] {
+				cases = append(cases, fmt.Sprint(v))
+			}
+		}
+		return "case " + strings.Join(cases, ", ")
+	}
+
+	_, cond, isCond := d.statements(blk)
+	switch {
+	case !isCond:
+		return ""
+	case i == 0:
+		return m + ": " + cond.String()
+	}
+	return cond.not().String()
+}
+
+// Escapes a string for a double quoted dot label
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,219 @@
+package disasm
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+)
+
+/*
+	HTML export. One page to review an analysis in a browser. Every function is a section
+	that folds away, headed by its callers and its decompiled pseudo code (folded too),
+	then its instructions with the pseudo code of each one alongside. Jump and call targets
+	link to the instruction they go to, and every instruction something jumps to, calls or
+	refers to lists the places that do, as links back. An instruction in more than one
+	function (a shared tail) is listed in the first, code no function holds goes at the end.
+*/
+
+type htmlLink struct {
+	Href string
+	Text string
+}
+
+type htmlOperand struct {
+	Text string
+	Href string // the target, for jumps and calls
+}
+
+type htmlLine struct {
+	Anchor   string
+	Address  int
+	Bytes    string
+	Mnemonic string
+	Operands []htmlOperand
+	Pseudo   string
+	Comment  string
+	Refs     []htmlLink
+}
+
+type htmlFunction struct {
+	Anchor  string
+	Name    string
+	Callers []htmlLink
+	Pseudo  string
+	Lines   []htmlLine
+}
+
+type htmlPage struct {
+	Title     string
+	Functions []htmlFunction
+}
+
+// ExportHTML writes an analysis as a page of linked, collapsible functions
+func (h *DisAsm) ExportHTML(an *Analysis, w io.Writer) error {
+	if err := h.GetInterrupts(); err != nil {
+		return err
+	}
+
+	entries := h.roots()
+	for adr := range an.Subroutines {
+		entries = append(entries, adr)
+	}
+	sort.Ints(entries)
+
+	page := htmlPage{Title: fmt.Sprintf("Disassembly, %d instructions", len(an.Opcodes))}
+	owned := make(map[int]bool)
+	done := make(map[int]bool)
+	for _, entry := range entries {
+		if done[entry] {
+			continue
+		}
+		done[entry] = true
+
+		cfg, err := h.CFG(an, entry)
+		if err != nil {
+			continue
+		}
+		fn := htmlFunction{Anchor: htmlAnchor(entry), Name: h.functionName(entry)}
+		for _, c := range an.Subroutines[entry] {
+			fn.Callers = append(fn.Callers, htmlLink{Href: "#" + htmlAnchor(c.CallFrom), Text: fmt.Sprintf("0x%X", c.CallFrom)})
+		}
+		if code, err := h.Decompile(an, entry); err == nil {
+			fn.Pseudo = code
+		}
+
+		var instrs Instructions
+		for _, blk := range cfg.Blocks {
+			for _, instr := range blk.Instrs {
+				if !owned[instr.Address] {
+					owned[instr.Address] = true
+					instrs = append(instrs, instr)
+				}
+			}
+		}
+		sort.Sort(instrs)
+		for _, instr := range instrs {
+			fn.Lines = append(fn.Lines, h.htmlLine(an, instr))
+		}
+		page.Functions = append(page.Functions, fn)
+	}
+
+	other := htmlFunction{Anchor: "other", Name: "Code outside any function"}
+	for _, instr := range an.Opcodes {
+		if !owned[instr.Address] {
+			other.Lines = append(other.Lines, h.htmlLine(an, instr))
+		}
+	}
+	if len(other.Lines) > 0 {
+		page.Functions = append(page.Functions, other)
+	}
+
+	return htmlTemplate.Execute(w, page)
+}
+
+// Name for a function, its symbol, the interrupt it serves or sub_<address>
+func (h *DisAsm) functionName(entry int) string {
+	if name := h.symbols[entry]; name != "" {
+		return name
+	}
+	if entry == 0x172080 {
+		return "RESET"
+	}
+	if name := h.intRoutineNames[entry]; name != "" {
+		return name
+	}
+	return fmt.Sprintf("sub_%X", entry)
+}
+
+func (h *DisAsm) htmlLine(an *Analysis, instr Instruction) htmlLine {
+	l := htmlLine{
+		Anchor:   htmlAnchor(instr.Address),
+		Address:  instr.Address,
+		Bytes:    fmt.Sprintf("%X", instr.Raw),
+		Mnemonic: instr.Mnemonic,
+		Pseudo:   h.pseudo(instr),
+		Comment:  h.comments[instr.Address],
+	}
+
+	for _, op := range instr.Ops {
+		o := htmlOperand{Text: h.aliases.Apply(pseudoOperand(op))}
+		if a, ok := op.(AddressOperand); ok && (instr.Calls[a.Address] != nil || instr.Jumps[a.Address] != nil) {
+			o.Href = "#" + htmlAnchor(a.Address)
+		}
+		l.Operands = append(l.Operands, o)
+	}
+
+	// Who jumps here, calls here or refers here
+	var from []int
+	seen := make(map[int]bool)
+	add := func(adr int) {
+		if !seen[adr] {
+			seen[adr] = true
+			from = append(from, adr)
+		}
+	}
+	for _, j := range an.Jumps[instr.Address] {
+		add(j.JumpFrom)
+	}
+	for _, c := range an.Subroutines[instr.Address] {
+		add(c.CallFrom)
+	}
+	for _, x := range an.XRefs[instr.Address] {
+		add(x.XRefFrom)
+	}
+	sort.Ints(from)
+	for _, adr := range from {
+		l.Refs = append(l.Refs, htmlLink{Href: "#" + htmlAnchor(adr), Text: fmt.Sprintf("0x%X", adr)})
+	}
+	return l
+}
+
+func htmlAnchor(adr int) string {
+	return fmt.Sprintf("a%X", adr)
+}
+
+var htmlTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: monospace; font-size: 13px; margin: 1em; }
+summary { cursor: pointer; font-weight: bold; padding: 2px 0; }
+details.function { border-top: 1px solid #ccc; }
+details.pseudo { margin: 0 0 0.5em 1em; font-weight: normal; }
+pre { background: #f6f6f6; padding: 0.5em; }
+table { border-collapse: collapse; }
+td { padding: 0 0.75em 0 0; vertical-align: top; white-space: nowrap; }
+td.bytes { color: #888; }
+td.pseudo { color: #060; }
+td.comment { color: #a50; }
+td.refs, .callers { color: #666; }
+tr:target { background: #ffc; }
+a { color: #03c; text-decoration: none; }
+a:hover { text-decoration: underline; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Functions}}
+<details class="function" id="f{{.Anchor}}" open>
+<summary>{{.Name}}</summary>
+{{if .Callers}}<div class="callers">called from {{range $i, $c := .Callers}}{{if $i}}, {{end}}<a href="{{$c.Href}}">{{$c.Text}}</a>{{end}}</div>{{end}}
+{{if .Pseudo}}<details class="pseudo"><summary>pseudo code</summary><pre>{{.Pseudo}}</pre></details>{{end}}
+<table>
+{{range .Lines}}<tr id="{{.Anchor}}">
+<td><a href="#{{.Anchor}}">0x{{printf "%X" .Address}}</a></td>
+<td class="bytes">{{.Bytes}}</td>
+<td>{{.Mnemonic}} {{range $i, $op := .Operands}}{{if $i}}, {{end}}{{if $op.Href}}<a href="{{$op.Href}}">{{$op.Text}}</a>{{else}}{{$op.Text}}{{end}}{{end}}</td>
+<td class="pseudo">{{.Pseudo}}</td>
+<td class="comment">{{if .Comment}}; {{.Comment}}{{end}}</td>
+<td class="refs">{{if .Refs}}xrefs {{range $i, $r := .Refs}}{{if $i}}, {{end}}<a href="{{$r.Href}}">{{$r.Text}}</a>{{end}}{{end}}</td>
+</tr>
+{{end}}</table>
+</details>
+{{end}}
+</body>
+</html>
+`))
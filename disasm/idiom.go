@@ -0,0 +1,244 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Idioms. A few instruction sequences the compiler emits over and over mean more
+	together than one at a time, and the decompiler prints each as a single annotated
+	statement instead:
+
+		scale		MUL(U) L, a, b   SHRL L, #n		L = (a * b) >> n
+				MUL(U) L, a, b   LD/ST w, L+2		w = (a * b) >> 16
+		muldiv		MUL(U) L, a, b   DIV(U) L, c		L = (a * b) / c
+		interpolate	SUB d, y1, y0    a scale of d by f	r = y0 + ((y1 - y0) * f >> n)
+				ADD r, y0, the scaled value
+
+	The two operand forms count too, MUL L, b is a = L and SUB d, y0 is y1 = d. A
+	subroutine with an interpolation in it, across the overflow checks between its steps,
+	is a table interpolation routine, and a call to one says so.
+*/
+
+// Idiom is a run of instructions that reads as one statement
+type Idiom struct {
+	Name   string
+	Start  int // address of the first instruction
+	Instrs int
+	Text   string // C-like, the way PseudoCode is
+}
+
+func (id Idiom) String() string {
+	return fmt.Sprintf("%s  // %s", id.Text, id.Name)
+}
+
+// A scaled product: what it's left in and its text
+type product struct {
+	dest    string
+	a, b    string
+	areg    int // register operands of the factors, -1 for anything else
+	breg    int
+	shift   int
+	divisor string
+	instrs  int
+}
+
+func (p product) String() string {
+	if p.divisor != "" {
+		return fmt.Sprintf("(%s * %s) / %s", p.a, p.b, p.divisor)
+	}
+	return fmt.Sprintf("(%s * %s) >> %d", p.a, p.b, p.shift)
+}
+
+// The register an operand names, -1 if it isn't one
+func idiomReg(op Operand) int {
+	if r, ok := op.(RegisterOperand); ok && r.Reg != 0 {
+		return r.Reg
+	}
+	return -1
+}
+
+// The mnemonic, signed or not
+func idiomMnemonic(instr Instruction) string {
+	return strings.TrimPrefix(instr.Mnemonic, "SGN ")
+}
+
+// A word multiply at instrs[i] and what turns its long result into a scaled word
+func matchProduct(instrs Instructions, i int) (product, bool) {
+	mul := instrs[i]
+	if m := idiomMnemonic(mul); m != "MUL" && m != "MULU" || i+1 >= len(instrs) {
+		return product{}, false
+	}
+	long := idiomReg(mul.Ops[0])
+	if long < 0 {
+		return product{}, false
+	}
+
+	p := product{dest: pseudoOperand(mul.Ops[0]), instrs: 2}
+	if len(mul.Ops) == 3 {
+		p.a, p.areg = pseudoOperand(mul.Ops[1]), idiomReg(mul.Ops[1])
+		p.b, p.breg = pseudoOperand(mul.Ops[2]), idiomReg(mul.Ops[2])
+	} else {
+		p.a, p.areg = p.dest, long
+		p.b, p.breg = pseudoOperand(mul.Ops[1]), idiomReg(mul.Ops[1])
+	}
+
+	next := instrs[i+1]
+	if len(next.Ops) < 2 {
+		return product{}, false
+	}
+	switch idiomMnemonic(next) {
+	case "SHRL":
+		if idiomReg(next.Ops[0]) != long {
+			return product{}, false
+		}
+		if n, ok := next.Ops[1].(ImmediateOperand); ok {
+			p.shift = n.Value
+			return p, true
+		}
+	case "DIV", "DIVU":
+		if idiomReg(next.Ops[0]) == long {
+			p.divisor = pseudoOperand(next.Ops[1])
+			return p, true
+		}
+	case "LD":
+		if idiomReg(next.Ops[1]) == long+2 {
+			p.dest, p.shift = pseudoOperand(next.Ops[0]), 16
+			return p, true
+		}
+	case "ST":
+		if idiomReg(next.Ops[0]) == long+2 {
+			p.dest, p.shift = pseudoOperand(next.Ops[1]), 16
+			return p, true
+		}
+	}
+	return product{}, false
+}
+
+// An interpolation starting with the subtract at instrs[i]
+func matchInterpolation(instrs Instructions, i int) (Idiom, bool) {
+	sub := instrs[i]
+	if idiomMnemonic(sub) != "SUB" || i+1 >= len(instrs) {
+		return Idiom{}, false
+	}
+	diff := idiomReg(sub.Ops[0])
+	if diff < 0 {
+		return Idiom{}, false
+	}
+	y1, y0, y0reg := pseudoOperand(sub.Ops[0]), pseudoOperand(sub.Ops[1]), idiomReg(sub.Ops[1])
+	if len(sub.Ops) == 3 {
+		y1, y0, y0reg = pseudoOperand(sub.Ops[1]), pseudoOperand(sub.Ops[2]), idiomReg(sub.Ops[2])
+	}
+
+	p, ok := matchProduct(instrs, i+1)
+	if !ok || p.divisor != "" || p.areg != diff && p.breg != diff {
+		return Idiom{}, false
+	}
+	f := p.a
+	if p.areg == diff {
+		f = p.b
+	}
+
+	j := i + 1 + p.instrs
+	if j >= len(instrs) || idiomMnemonic(instrs[j]) != "ADD" {
+		return Idiom{}, false
+	}
+	add := instrs[j]
+	var r string
+	switch {
+	case len(add.Ops) == 2 && pseudoOperand(add.Ops[1]) == p.dest && idiomReg(add.Ops[0]) == y0reg:
+		r = y0
+	case len(add.Ops) == 3 && (pseudoOperand(add.Ops[1]) == y0 && pseudoOperand(add.Ops[2]) == p.dest ||
+		pseudoOperand(add.Ops[2]) == y0 && pseudoOperand(add.Ops[1]) == p.dest):
+		r = pseudoOperand(add.Ops[0])
+	default:
+		return Idiom{}, false
+	}
+
+	text := fmt.Sprintf("%s = %s + ((%s - %s) * %s >> %d)", r, y0, y1, y0, f, p.shift)
+	return Idiom{Name: "interpolate", Start: sub.Address, Instrs: j - i + 1, Text: text}, true
+}
+
+// The idiom starting at instrs[i], the longest one that fits
+func matchIdiom(instrs Instructions, i int) (Idiom, bool) {
+	if id, ok := matchInterpolation(instrs, i); ok {
+		return id, true
+	}
+	if p, ok := matchProduct(instrs, i); ok {
+		id := Idiom{Name: "scale", Start: instrs[i].Address, Instrs: p.instrs}
+		if p.divisor != "" {
+			id.Name = "muldiv"
+		}
+		id.Text = fmt.Sprintf("%s = %s", p.dest, p.String())
+		return id, true
+	}
+	return Idiom{}, false
+}
+
+// Statements for a run of straight line instructions, idioms collapsed
+func (d *decompiler) idiomStatements(instrs Instructions) []string {
+	var stmts []string
+	for i := 0; i < len(instrs); i++ {
+		if id, ok := matchIdiom(instrs, i); ok {
+			stmts = append(stmts, d.rename(id.String(), instrs[i:i+id.Instrs]...))
+			i += id.Instrs - 1
+			continue
+		}
+		instr := instrs[i]
+		if instr.PseudoCode == "" {
+			continue
+		}
+		if body, ok := d.inline(instr); ok {
+			stmts = append(stmts, "// "+d.rename(instr.PseudoCode, instr)+" inlined")
+			stmts = append(stmts, d.idiomStatements(body)...)
+			continue
+		}
+		if instr.Is(CategoryCall) && d.callsInterpolation(instr) {
+			stmts = append(stmts, d.rename(instr.PseudoCode, instr)+"  // table interpolation")
+			continue
+		}
+		stmts = append(stmts, d.rename(instr.PseudoCode, instr))
+	}
+	return stmts
+}
+
+func (d *decompiler) callsInterpolation(call Instruction) bool {
+	for adr := range call.Calls {
+		if d.interpolating(adr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the subroutine at adr is a table interpolation, remembered between subroutines
+func (d *decompiler) interpolating(adr int) bool {
+	v, ok := d.interpolates[adr]
+	if !ok {
+		v = d.h.Interpolates(d.an, adr)
+		d.interpolates[adr] = v
+	}
+	return v
+}
+
+// Interpolates says whether the subroutine at entry interpolates, going by its code in
+// address order with the conditional jumps between the steps left out
+func (h *DisAsm) Interpolates(an *Analysis, entry int) bool {
+	all, err := h.FunctionInstructions(an, entry)
+	if err != nil {
+		return false
+	}
+	var instrs Instructions
+	for i := range all {
+		if !all[i].IsConditional() {
+			instrs = append(instrs, all[i])
+		}
+	}
+	for i := range instrs {
+		if _, ok := matchInterpolation(instrs, i); ok {
+			return true
+		}
+	}
+	return false
+}
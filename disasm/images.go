@@ -0,0 +1,173 @@
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+/*
+	Images. An ECU can hold more than one image: the main ROM, and a flash bank on the
+	external bus that only extended instructions (EJMP, ECALL, ELD and the rest) reach. An
+	image added with AddImage is laid into the block at its own CPU address, the bytes
+	between it and the others read as erased flash (0xFF), so the crawl follows calls and
+	jumps into it and files the XRefs to it the same as for the main image, and one analysis
+	covers them all.
+
+	Images lists them, the main image first, and CrossImageRefs the calls, jumps and XRefs
+	from one image to another, which is where the images depend on each other's layout. The
+	address translation is set before any image is added, it only moves the main image.
+*/
+
+// Image is one of the images in the block, by CPU address
+type Image struct {
+	Name   string
+	Base   int
+	Length int
+}
+
+// The name the image the disassembler was made with has in Images
+const mainImage = "main"
+
+// Contains is whether adr is in the image
+func (m Image) Contains(adr int) bool {
+	return adr >= m.Base && adr < m.Base+m.Length
+}
+
+func (m Image) String() string {
+	return fmt.Sprintf("%s 0x%X-0x%X", m.Name, m.Base, m.Base+m.Length-1)
+}
+
+// AddImage lays another image into the block at base, a CPU address, for the crawl to
+// follow references into
+func (h *DisAsm) AddImage(name string, base int, image []byte) error {
+	t := h.AddressTranslation()
+	if len(image) == 0 {
+		return fmt.Errorf("Image %s is empty", name)
+	}
+	if base < 0 || base+len(image) > t.Mask+1 {
+		return fmt.Errorf("Image %s at 0x%X runs past the top of the address space", name, base)
+	}
+
+	images := h.Images()
+	add := Image{Name: name, Base: base, Length: len(image)}
+	for _, m := range images {
+		if m.Name == name {
+			return fmt.Errorf("There's already an image named %s", name)
+		}
+		if add.Base < m.Base+m.Length && m.Base < add.Base+add.Length {
+			return fmt.Errorf("Image %s overlaps %s", add, m)
+		}
+	}
+
+	if end := base + len(image); end > len(h.block) {
+		block := make([]byte, end)
+		copy(block, h.block)
+		for i := len(h.block); i < base; i++ {
+			block[i] = 0xFF
+		}
+		h.block = block
+	}
+	copy(h.block[base:], image)
+	h.images = append(images, add)
+	return nil
+}
+
+// Images are the images in the block, the main one first and the others in the order they
+// were added
+func (h *DisAsm) Images() []Image {
+	if len(h.images) > 0 {
+		return append([]Image{}, h.images...)
+	}
+	base := h.AddressTranslation().Address(0)
+	return []Image{{Name: mainImage, Base: base, Length: len(h.block) - base}}
+}
+
+// ImageOf is the image adr is in
+func (h *DisAsm) ImageOf(adr int) (Image, bool) {
+	for _, m := range h.Images() {
+		if m.Contains(adr) {
+			return m, true
+		}
+	}
+	return Image{}, false
+}
+
+// CrossRef is a call, jump or XRef from one image into another
+type CrossRef struct {
+	From      int
+	To        int
+	FromImage string
+	ToImage   string
+	Kind      string // call, jump or xref
+	Mnemonic  string
+}
+
+func (x CrossRef) String() string {
+	return fmt.Sprintf("%s 0x%X -> %s 0x%X  %s %s", x.FromImage, x.From, x.ToImage, x.To, x.Kind, x.Mnemonic)
+}
+
+type crossRefs []CrossRef
+
+func (c crossRefs) Len() int {
+	return len(c)
+}
+
+func (c crossRefs) Less(i, j int) bool {
+	if c[i].From != c[j].From {
+		return c[i].From < c[j].From
+	}
+	return c[i].To < c[j].To
+}
+
+func (c crossRefs) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// CrossImageRefs lists the analysis's references from one image to another, by where
+// they're from
+func (h *DisAsm) CrossImageRefs(an *Analysis) ([]CrossRef, error) {
+	images := h.Images()
+	if len(images) < 2 {
+		return nil, errors.New("There's only the one image")
+	}
+
+	// Registers and RAM are where they are whichever image is running, only ROM and the
+	// external bus are an image's
+	memory := h.MemoryMap()
+	of := func(adr int) string {
+		if k := memory.Kind(adr); k != KindROM && k != KindExternal {
+			return ""
+		}
+		for _, m := range images {
+			if m.Contains(adr) {
+				return m.Name
+			}
+		}
+		return ""
+	}
+	var refs crossRefs
+	add := func(from, to int, kind, mnemonic string) {
+		f, t := of(from), of(to)
+		if f != "" && t != "" && f != t {
+			refs = append(refs, CrossRef{From: from, To: to, FromImage: f, ToImage: t, Kind: kind, Mnemonic: mnemonic})
+		}
+	}
+	for to, calls := range an.Subroutines {
+		for _, c := range calls {
+			add(c.CallFrom, to, "call", c.Mnemonic)
+		}
+	}
+	for to, jumps := range an.Jumps {
+		for _, j := range jumps {
+			add(j.JumpFrom, to, "jump", j.Mnemonic)
+		}
+	}
+	for to, xrefs := range an.XRefs {
+		for _, x := range xrefs {
+			add(x.XRefFrom, to, "xref", x.Mnemonic)
+		}
+	}
+	sort.Sort(refs)
+	return refs, nil
+}
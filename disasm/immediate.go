@@ -0,0 +1,37 @@
+package disasm
+
+/*
+	Immediate values. A constant the code loads straight from the instruction, a rev
+	limit compared against or a fixed scale factor, isn't in any table and doesn't show
+	up in Constants. FindImmediate lists every instruction with an immediate operand
+	that encodes a value, as a byte or a word or either one. A negative value matches its
+	two's complement, -1 finds #FF and #FFFF.
+*/
+
+// FindImmediate is every instruction in the code with an immediate operand encoding value
+// in width bytes, 1 or 2, or in either one for 0
+func (h *DisAsm) FindImmediate(an *Analysis, value, width int) Instructions {
+	var out Instructions
+	for _, instr := range an.Opcodes {
+		for _, op := range instr.Ops {
+			if imm, ok := op.(ImmediateOperand); ok && immediateMatches(imm, value, width) {
+				out = append(out, instr)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Whether the immediate encodes value, which has to fit its size signed or unsigned
+func immediateMatches(imm ImmediateOperand, value, width int) bool {
+	if width != 0 && imm.Size != width {
+		return false
+	}
+	bits := uint(8 * imm.Size)
+	mask := 1<<bits - 1
+	if value > mask || value < -(1<<(bits-1)) {
+		return false
+	}
+	return value&mask == imm.Value&mask
+}
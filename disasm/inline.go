@@ -0,0 +1,59 @@
+package disasm
+
+/*
+	Inlining. Scaling and lookup code calls a lot of little helpers, a subtract and a
+	shift or a clamp to a limit, and reading a call to one means going to find it. With
+	inlining on, the decompiler prints the body of a short leaf in place of a call to it,
+	after a comment naming what was called:
+
+		// sub_143424() inlined
+		R_30 = (R_32 * R_34) >> 8;  // scale
+		R_36 += R_30;
+
+	Only straight line leaves are inlined, one block ending in RET with no calls in it
+	and at most the given number of instructions before the RET, so the code reads the
+	same in the caller as it does on its own.
+*/
+
+// SetInlineCalls inlines calls to leaf subroutines of up to n instructions in the
+// decompiler, 0 turns it off
+func (h *DisAsm) SetInlineCalls(n int) {
+	h.inlineCalls = n
+}
+
+// The body a call is inlined as, false if it isn't
+func (d *decompiler) inline(call Instruction) (Instructions, bool) {
+	if d.h.inlineCalls <= 0 || !call.Is(CategoryCall) || len(call.Calls) != 1 {
+		return nil, false
+	}
+	var adr int
+	for target := range call.Calls {
+		adr = target
+	}
+
+	body, ok := d.inlined[adr]
+	if !ok {
+		body = d.h.inlineBody(d.an, adr)
+		d.inlined[adr] = body
+	}
+	return body, body != nil
+}
+
+// The instructions of a straight line leaf short enough to inline, without its RET
+func (h *DisAsm) inlineBody(an *Analysis, entry int) Instructions {
+	cfg, err := h.CFG(an, entry)
+	if err != nil || len(cfg.Blocks) != 1 {
+		return nil
+	}
+	instrs := cfg.Blocks[entry].Instrs
+	if len(instrs) < 2 || len(instrs)-1 > h.inlineCalls || instrs[len(instrs)-1].Mnemonic != "RET" {
+		return nil
+	}
+	body := instrs[:len(instrs)-1]
+	for i := range body {
+		if body[i].Is(CategoryCall, CategoryReturn) || body[i].IsBranch() {
+			return nil
+		}
+	}
+	return body
+}
@@ -0,0 +1,286 @@
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/*
+	Instruction sets. The opcode tables are written for the 8xC196EA, the older parts run
+	a subset of it:
+
+		196kr	the EA without the extended (24 bit) instructions, the 0xFE 0x1C MYSTERY
+			opcode found in the EEC images goes with them
+		196kc	the same opcodes as the KR
+		196kb	the KC without BMOVI, TIJMP, DPTS and EPTS
+
+	0xE3 is left in every set, the EA's EBR shares it with BR and an even register names it
+	BR. Opcodes can be added or replaced with Register, on a Copy since the built in sets
+	are shared. The operands of a registered opcode are decoded the same way as the other
+	opcodes in its row of the opcode map. Validate checks that every entry agrees with
+	itself: as many VarTypes and VarStrings as VarCount, a ByteLength that's the opcode and
+	its operands in the addressing mode, and VariableLength on the indexed ops with an aop.
+
+	Decoding is safe from any number of goroutines at once, with the same set or different
+	ones. The table entries are never changed once they are in a set, Parse only reads them
+	and what it decodes belongs to the Instruction it returns (Raw and RawOps are slices of
+	the bytes it was given). Register and Remove lock the set, so a set can be changed while
+	it is decoding, each Instruction is decoded wholly before or after the change.
+*/
+
+// InstructionSet is an opcode table, the plain opcodes and the ones after an 0xFE prefix
+type InstructionSet struct {
+	Name     string
+	mu       sync.RWMutex // guards the tables
+	unsigned map[byte]*Opcode
+	signed   map[byte]*Opcode
+}
+
+// InstructionSets are the built in sets, by name
+var InstructionSets = map[string]*InstructionSet{
+	"196ea": set196EA,
+	"196kr": set196KR,
+	"196kc": set196KC,
+	"196kb": set196KB,
+}
+
+// DefaultInstructionSet is used until a disassembler is given another
+const DefaultInstructionSet = "196ea"
+
+var (
+	set196EA = &InstructionSet{Name: "8xC196EA", unsigned: unsignedInstructions, signed: signedInstructions}
+
+	set196KR = set196EA.without("8xC196KR",
+		[]byte{0x1C, 0x1D, 0x1E, 0x1F, 0xE4, 0xE6, 0xE8, 0xE9, 0xEA, 0xEB, 0xF1}, // EST, ESTB, EBMOVI, EJMP, ELD, ELDB, ECALL
+		[]byte{0x1C}) // MYSTERY
+	set196KC = set196KR.without("8xC196KC", nil, nil)
+	set196KB = set196KC.without("8xC196KB", []byte{0xCD, 0xE2, 0xEC, 0xED}, nil) // BMOVI, TIJMP, DPTS, EPTS
+)
+
+// FindInstructionSet returns a built in set by name
+func FindInstructionSet(name string) (*InstructionSet, error) {
+	if s, ok := InstructionSets[strings.ToLower(name)]; ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("No instruction set named %s", name)
+}
+
+// The built in set with a Name
+func builtinInstructionSet(name string) (*InstructionSet, bool) {
+	for _, s := range InstructionSets {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// Copy makes a set that can be changed without touching this one
+func (s *InstructionSet) Copy(name string) *InstructionSet {
+	return s.without(name, nil, nil)
+}
+
+func (s *InstructionSet) without(name string, unsigned, signed []byte) *InstructionSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c := &InstructionSet{Name: name, unsigned: make(map[byte]*Opcode), signed: make(map[byte]*Opcode)}
+	for b, op := range s.unsigned {
+		c.unsigned[b] = op
+	}
+	for b, op := range s.signed {
+		c.signed[b] = op
+	}
+	for _, op := range unsigned {
+		delete(c.unsigned, op)
+	}
+	for _, op := range signed {
+		delete(c.signed, op)
+	}
+	return c
+}
+
+// Register adds or replaces the table entry for an opcode, in the signed (0xFE prefixed)
+// table if asked. The entry needs at least its Mnemonic, ByteLength, VarCount, VarTypes,
+// VarStrings and AddressingMode. A Category left out is taken from the built in opcode with
+// the same mnemonic, if there is one. The set keeps its own copy.
+func (s *InstructionSet) Register(b byte, signed bool, op Opcode) error {
+	if !signed && b == 0xFE {
+		return errors.New("0xFE is the signed prefix")
+	}
+	if op.Mnemonic == "" || op.ByteLength < 1 {
+		return fmt.Errorf("Opcode 0x%02X needs a mnemonic and a length", b)
+	}
+	if problems := checkOpcode(&op); len(problems) > 0 {
+		return fmt.Errorf("Opcode 0x%02X %s %s", b, op.Mnemonic, strings.Join(problems, ", "))
+	}
+
+	if op.Category == "" {
+		op.Category = categoryOf(op.Mnemonic)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unsigned == nil {
+		s.unsigned, s.signed = make(map[byte]*Opcode), make(map[byte]*Opcode)
+	}
+	if signed {
+		s.signed[b] = &op
+	} else {
+		s.unsigned[b] = &op
+	}
+	return nil
+}
+
+// Validate checks every entry of the set, the error lists each one that's inconsistent
+func (s *InstructionSet) Validate() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []string
+	for _, signed := range []bool{false, true} {
+		table, prefix := s.unsigned, ""
+		if signed {
+			table, prefix = s.signed, "0xFE "
+		}
+		for b := 0; b < 0x100; b++ {
+			op, ok := table[byte(b)]
+			if !ok {
+				continue
+			}
+			if problems := checkOpcode(op); len(problems) > 0 {
+				out = append(out, fmt.Sprintf("%s: opcode %s0x%02X %s %s", s.Name, prefix, b, op.Mnemonic, strings.Join(problems, ", ")))
+			}
+		}
+	}
+	if len(out) > 0 {
+		return errors.New(strings.Join(out, "\n"))
+	}
+	return nil
+}
+
+// Addressing modes a table entry can have, blank for the ones without operands
+var addressingModes = map[string]bool{
+	"":                  true,
+	"direct":            true,
+	"immediate":         true,
+	"indirect":          true,
+	"indexed":           true,
+	"long-indexed":      true,
+	"extended-indirect": true,
+	"extended-indexed":  true,
+}
+
+// What's inconsistent about a table entry
+func checkOpcode(op *Opcode) []string {
+	var problems []string
+	if len(op.VarTypes) != op.VarCount || len(op.VarStrings) != op.VarCount {
+		problems = append(problems, fmt.Sprintf("has %d vars but %d types and %d strings", op.VarCount, len(op.VarTypes), len(op.VarStrings)))
+	}
+	if !addressingModes[op.AddressingMode] || op.AddressingMode == "" && len(op.VarStrings) > 0 {
+		problems = append(problems, fmt.Sprintf("has addressing mode %q", op.AddressingMode))
+	}
+
+	aop := false
+	length := 1
+	for _, v := range op.VarStrings {
+		aop = aop || v == "waop" || v == "baop"
+		length += operandBytes(op, v)
+	}
+	if op.ByteLength != length {
+		problems = append(problems, fmt.Sprintf("is %d bytes, %s operands take %d", op.ByteLength, modeName(op.AddressingMode), length))
+	}
+
+	indexed := op.AddressingMode == "indexed" && aop
+	if op.VariableLength != indexed {
+		problems = append(problems, fmt.Sprintf("has VariableLength %v, want it only on indexed ops with an aop", op.VariableLength))
+	}
+	if op.AutoIncrement && op.AddressingMode != "indirect" {
+		problems = append(problems, "auto-increments without being indirect")
+	}
+	return problems
+}
+
+// Bytes an operand takes in the encoding, a short-indexed aop counts its offset
+func operandBytes(op *Opcode, v string) int {
+	switch v {
+	case "bitno":
+		return 0 // in the opcode
+	case "cadd":
+		switch op.AddressingMode {
+		case "long-indexed":
+			return 2
+		case "extended-indexed":
+			return 3
+		}
+	case "waop", "baop":
+		switch op.AddressingMode {
+		case "immediate":
+			if v == "waop" {
+				return 2
+			}
+		case "indexed":
+			return 2
+		}
+	case "treg":
+		if op.AddressingMode == "extended-indexed" {
+			return 4 // and a 24 bit offset
+		}
+	}
+	return 1
+}
+
+func modeName(mode string) string {
+	if mode == "" {
+		return "its"
+	}
+	return mode
+}
+
+// Remove takes an opcode out of the set, it decodes as unknown after
+func (s *InstructionSet) Remove(b byte, signed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if signed {
+		delete(s.signed, b)
+	} else {
+		delete(s.unsigned, b)
+	}
+}
+
+// Lookup returns the table entry for an opcode as an undecoded Instruction, from the signed
+// table if asked
+func (s *InstructionSet) Lookup(b byte, signed bool) (Instruction, bool) {
+	op, ok := s.opcode(b, signed)
+	if !ok {
+		return Instruction{}, false
+	}
+	return Instruction{Opcode: op, Mnemonic: op.Mnemonic, ByteLength: op.ByteLength, AddressingMode: op.AddressingMode, AutoIncrement: op.AutoIncrement, Flags: op.Flags}, true
+}
+
+// The table entry for an opcode
+func (s *InstructionSet) opcode(b byte, signed bool) (*Opcode, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if signed {
+		op, ok := s.signed[b]
+		return op, ok
+	}
+	op, ok := s.unsigned[b]
+	return op, ok
+}
+
+// SetInstructionSet picks the opcodes the image is decoded with
+func (h *DisAsm) SetInstructionSet(s *InstructionSet) {
+	h.instructions = s
+}
+
+// InstructionSet is the set in use, the default one unless another was set
+func (h *DisAsm) InstructionSet() *InstructionSet {
+	if h.instructions == nil {
+		return InstructionSets[DefaultInstructionSet]
+	}
+	return h.instructions
+}
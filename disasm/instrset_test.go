@@ -0,0 +1,29 @@
+package disasm
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	for _, name := range []string{"196ea", "196kr", "196kc", "196kb"} {
+		s, err := FindInstructionSet(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Validate(); err != nil {
+			t.Errorf("%s:\n%s", name, err)
+		}
+	}
+}
+
+// An entry that disagrees with itself is refused by Register and reported by Validate
+func TestValidateCatches(t *testing.T) {
+	s := InstructionSets[DefaultInstructionSet].Copy("test")
+	bad := Opcode{Mnemonic: "LD", ByteLength: 3, VarCount: 2, VarTypes: []string{"DEST", "SRC"}, VarStrings: []string{"wreg", "waop"}, AddressingMode: "immediate"}
+	if err := s.Register(0x10, false, bad); err == nil {
+		t.Error("Register took a 3 byte word immediate LD")
+	}
+
+	s.unsigned[0x10] = &bad
+	if err := s.Validate(); err == nil {
+		t.Error("Validate passed a 3 byte word immediate LD")
+	}
+}
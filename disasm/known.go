@@ -0,0 +1,141 @@
+package disasm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+/*
+	Known regions. Areas of the image already worked out, like SPARK_TABLE_1 or
+	VIN_STORAGE, from a JSON definition file:
+
+		[{"Address": 1056768, "Length": 17, "Name": "VIN_STORAGE", "Type": "DCB"}]
+
+	The crawl never decodes inside one, a path that runs or jumps into it ends there, so a
+	table can't be taken for code. In the listing a region starts with its name and is
+	listed with the directive its type says (DCB, DCW or DCL, DCB when there's none), and
+	Labels names its first address.
+*/
+
+// KnownRegion is a named area of data
+type KnownRegion struct {
+	Address int
+	Length  int
+	Name    string
+	Type    DataFormat `json:",omitempty"`
+}
+
+// Stop is the last address in the region
+func (r KnownRegion) Stop() int {
+	return r.Address + r.Length - 1
+}
+
+func (r KnownRegion) String() string {
+	return fmt.Sprintf("%s 0x%X-0x%X %s", r.Name, r.Address, r.Stop(), r.Type)
+}
+
+// KnownRegions are the regions of one image, in address order once validated
+type KnownRegions []KnownRegion
+
+func (k KnownRegions) Len() int {
+	return len(k)
+}
+
+func (k KnownRegions) Less(i, j int) bool {
+	return k[i].Address < k[j].Address
+}
+
+func (k KnownRegions) Swap(i, j int) {
+	k[i], k[j] = k[j], k[i]
+}
+
+// LoadKnownRegions reads region definitions from a JSON file
+func LoadKnownRegions(path string) (KnownRegions, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var k KnownRegions
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("Region definitions %s: %s", path, err)
+	}
+	if err := k.Validate(); err != nil {
+		return nil, fmt.Errorf("Region definitions %s: %s", path, err)
+	}
+	return k, nil
+}
+
+// Validate sorts the regions, fills in the default type and checks that they're named,
+// sane and don't overlap
+func (k KnownRegions) Validate() error {
+	sort.Sort(k)
+	for i := range k {
+		r := &k[i]
+		if r.Type == "" {
+			r.Type = DataBytes
+		}
+		if r.Name == "" {
+			return fmt.Errorf("Region 0x%X has no name", r.Address)
+		}
+		if _, ok := dataSizes[r.Type]; !ok {
+			return fmt.Errorf("%s has type %q, not DCB, DCW or DCL", r.Name, r.Type)
+		}
+		if r.Address < 0 || r.Length <= 0 {
+			return fmt.Errorf("%s at 0x%X has length %d", r.Name, r.Address, r.Length)
+		}
+		if i > 0 && r.Address <= k[i-1].Stop() {
+			return fmt.Errorf("%s at 0x%X overlaps %s", r.Name, r.Address, k[i-1].Name)
+		}
+	}
+	return nil
+}
+
+// Locate finds the region holding adr
+func (k KnownRegions) Locate(adr int) (KnownRegion, bool) {
+	i := sort.Search(len(k), func(i int) bool { return k[i].Stop() >= adr })
+	if i < len(k) && k[i].Address <= adr {
+		return k[i], true
+	}
+	return KnownRegion{}, false
+}
+
+// SetKnownRegions names areas of the image and keeps the crawl out of them
+func (h *DisAsm) SetKnownRegions(k KnownRegions) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+	h.known = k
+	return nil
+}
+
+// Whether the crawl can decode at adr
+func (h *DisAsm) decodable(adr int) bool {
+	if _, ok := h.known.Locate(adr); ok {
+		return false
+	}
+	_, ok := h.detected.Locate(adr)
+	return !ok
+}
+
+// The data region holding adr, a known region before one from the region map
+func (h *DisAsm) dataRegion(adr int) (DataRegion, bool) {
+	if r, ok := h.known.Locate(adr); ok {
+		return DataRegion{Name: r.Name, Start: r.Address, Stop: r.Stop(), Format: r.Type}, true
+	}
+	return h.regionMap.Locate(adr)
+}
+
+// Where the next data region after adr starts, the end of the image if there's none
+func (h *DisAsm) nextDataRegion(adr int) int {
+	next := len(h.block)
+	if i := sort.Search(len(h.regionMap), func(i int) bool { return h.regionMap[i].Start > adr }); i < len(h.regionMap) {
+		next = h.regionMap[i].Start
+	}
+	if i := sort.Search(len(h.known), func(i int) bool { return h.known[i].Address > adr }); i < len(h.known) && h.known[i].Address < next {
+		next = h.known[i].Address
+	}
+	return next
+}
@@ -0,0 +1,114 @@
+package disasm
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/*
+	Labels. Every address an analysis can put a name to, for other disassemblers and
+	editors. An address gets one name, the first of: a symbol set with SetSymbols, the
+	name of a known region, RESET or the interrupt it serves (its first vector's, as an
+	identifier), sub_ for a subroutine, L_ for a jump target (the decompiler's labels),
+	str_ for a string set with SetStrings, and data_ for data in ROM or on the external
+	bus that the code refers to. Addresses are image addresses.
+*/
+
+// Label is a name for an address
+type Label struct {
+	Address int
+	Name    string
+	Kind    string // sub, interrupt, label, string or data, or symbol and region for the ones set
+}
+
+type labels []Label
+
+func (l labels) Len() int {
+	return len(l)
+}
+
+func (l labels) Less(i, j int) bool {
+	return l[i].Address < l[j].Address
+}
+
+func (l labels) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+// Labels names every address in an analysis that something refers to, in address order
+func (h *DisAsm) Labels(an *Analysis) []Label {
+	named := make(map[int]bool)
+	var found []Label
+	add := func(adr int, name, kind string) {
+		if !named[adr] {
+			named[adr] = true
+			found = append(found, Label{Address: adr, Name: name, Kind: kind})
+		}
+	}
+
+	for adr, name := range h.symbols {
+		add(adr, name, "symbol")
+	}
+	for _, r := range h.known {
+		add(r.Address, r.Name, "region")
+	}
+
+	add(resetAddress, "RESET", "interrupt")
+	for _, e := range h.vectorEntries() {
+		add(e.Address, identifier(e.Source), "interrupt")
+	}
+
+	for adr := range an.Subroutines {
+		add(adr, fmt.Sprintf("sub_%X", adr), "sub")
+	}
+	for adr := range an.Jumps {
+		add(adr, fmt.Sprintf("L_%X", adr), "label")
+	}
+	for adr := range h.strings {
+		add(adr, fmt.Sprintf("str_%X", adr), "string")
+	}
+
+	memory := h.MemoryMap()
+	for adr := range an.XRefs {
+		adr = memory.Resolve(adr)
+		if kind := memory.Kind(adr); (kind == KindROM || kind == KindExternal) && an.Crawled[adr] != 1 {
+			add(adr, fmt.Sprintf("data_%X", adr), "data")
+		}
+	}
+
+	sort.Sort(labels(found))
+	return found
+}
+
+// Text as a symbol name, runs of anything but letters and digits become one _
+func identifier(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(words, "_")
+}
+
+// ExportSymbols writes the labels as a .sym file, a line of address and name for each, the
+// way linker maps and most symbol loaders read them
+func (h *DisAsm) ExportSymbols(an *Analysis, w io.Writer) error {
+	for _, l := range h.Labels(an) {
+		if _, err := fmt.Fprintf(w, "%06X %s\n", l.Address, l.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportSymbolsCSV writes the labels as CSV, Address, Name and Kind with a header row
+func (h *DisAsm) ExportSymbolsCSV(an *Analysis, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Address", "Name", "Kind"})
+	for _, l := range h.Labels(an) {
+		cw.Write([]string{fmt.Sprintf("0x%06X", l.Address), l.Name, l.Kind})
+	}
+	cw.Flush()
+	return cw.Error()
+}
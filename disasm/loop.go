@@ -0,0 +1,169 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Counted loops. DJNZ and DJNZW decrement a register and jump back while it isn't zero,
+	so a loop entered with the counter at N runs N times (0 runs 256 times, or 65536 for
+	DJNZW). The counter is found by walking back from the top of the loop through the
+	straight line code that falls into it, to the instruction that last wrote it. That
+	has to be a load of a constant or a CLR, the loop can only be entered by falling into
+	it, and nothing in the loop but the DJNZ can write the counter, otherwise the count
+	isn't known. The block copy and checksum loops are all written this way.
+*/
+
+// Loop is a DJNZ or DJNZW loop
+type Loop struct {
+	Head    int // where the DJNZ jumps back to
+	DJNZ    int
+	Counter int // register address
+	Width   int // 1 for DJNZ, 2 for DJNZW
+	Init    int // the instruction that sets the counter, -1 when not found
+	Count   int // iterations, 0 when they aren't known
+}
+
+func (l Loop) String() string {
+	if l.Count == 0 {
+		return fmt.Sprintf("loop 0x%X-0x%X, counter %s", l.Head, l.DJNZ, regString(l.Counter, 0))
+	}
+	return fmt.Sprintf("loop 0x%X-0x%X, %d times, %s set at 0x%X", l.Head, l.DJNZ, l.Count, regString(l.Counter, 0), l.Init)
+}
+
+type loops []Loop
+
+func (l loops) Len() int {
+	return len(l)
+}
+
+func (l loops) Less(i, j int) bool {
+	return l[i].DJNZ < l[j].DJNZ
+}
+
+func (l loops) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+// Loops finds the DJNZ loops that jump back, with their iteration counts where the counter
+// is set to a constant before the loop
+func (an *Analysis) Loops() []Loop {
+	index := make(map[int]int, len(an.Opcodes))
+	for i, instr := range an.Opcodes {
+		index[instr.Address] = i
+	}
+
+	var found []Loop
+	for i := range an.Opcodes {
+		djnz := &an.Opcodes[i]
+		m := strings.TrimPrefix(djnz.Mnemonic, "SGN ")
+		if m != "DJNZ" && m != "DJNZW" {
+			continue
+		}
+
+		var head int
+		for adr := range djnz.Jumps {
+			head = adr
+		}
+		start, ok := index[head]
+		if !ok || head > djnz.Address {
+			continue
+		}
+
+		l := Loop{Head: head, DJNZ: djnz.Address, Width: 1, Init: -1}
+		if m == "DJNZW" {
+			l.Width = 2
+		}
+		for _, r := range operandRefs(djnz) {
+			if r.Access&AccessWrite != 0 {
+				l.Counter = r.To
+			}
+		}
+
+		if an.loopEnteredOnce(l) && !an.writesCounter(l, start, i) {
+			l.Init, l.Count = an.loopCount(l, start)
+		}
+		found = append(found, l)
+	}
+
+	sort.Sort(loops(found))
+	return found
+}
+
+// Nothing jumps to the top of the loop from outside it
+func (an *Analysis) loopEnteredOnce(l Loop) bool {
+	for _, j := range an.Jumps[l.Head] {
+		if j.JumpFrom < l.Head || j.JumpFrom > l.DJNZ {
+			return false
+		}
+	}
+	return an.Subroutines[l.Head] == nil
+}
+
+// Something in the loop other than the DJNZ writes the counter
+func (an *Analysis) writesCounter(l Loop, start, end int) bool {
+	for i := start; i < end; i++ {
+		if len(an.Opcodes[i].Calls) > 0 || writes(&an.Opcodes[i], l.Counter, l.Width) {
+			return true
+		}
+	}
+	return false
+}
+
+// Walks back from the top of the loop to what set the counter, returning its address and
+// the count, -1 and 0 when it isn't a constant
+func (an *Analysis) loopCount(l Loop, start int) (int, int) {
+	for i := start - 1; i >= 0; i-- {
+		if !an.fallsInto(i, l.Head) {
+			return -1, 0
+		}
+		prev := an.Opcodes[i]
+		if !writes(&prev, l.Counter, l.Width) {
+			continue
+		}
+
+		s := regState{}
+		s.step(prev, nil)
+		count, ok := s[l.Counter]
+		if l.Width == 2 {
+			count, ok = s.word(l.Counter)
+		}
+		if !ok {
+			return -1, 0
+		}
+		if count == 0 {
+			count = 1 << uint(8*l.Width)
+		}
+		return prev.Address, count
+	}
+	return -1, 0
+}
+
+// Whether an.Opcodes[i] runs straight on into the instruction after it, with no flow
+// joining there from anywhere else (but head, the top of a loop, can be jumped to)
+func (an *Analysis) fallsInto(i, head int) bool {
+	prev, next := an.Opcodes[i], an.Opcodes[i+1]
+
+	// A gap, or flow joining from elsewhere
+	if prev.Address+prev.ByteLength != next.Address {
+		return false
+	}
+	if next.Address != head && (an.Jumps[next.Address] != nil || an.Subroutines[next.Address] != nil) {
+		return false
+	}
+
+	// Anything that doesn't fall through, or calls what could change the registers
+	return !prev.Is(CategoryCall, CategoryReturn) && !(prev.IsBranch() && !prev.IsConditional())
+}
+
+// The instruction writes any byte of width bytes at adr
+func writes(instr *Instruction, adr, width int) bool {
+	for _, r := range operandRefs(instr) {
+		if r.Access&AccessWrite != 0 && r.To < adr+width && adr < r.To+r.Width {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package disasm
+
+import "fmt"
+
+/*
+	Operands. The decoder fills in an instruction's Ops, one for each of its VarStrings and
+	in the same order, the order the assembler takes them (destination first, the operand
+	the addressing mode applies to last). The role of Ops[i] is VarTypes[i]. Each operand
+	is typed by how it reaches its value, so analyses read register numbers, constants and
+	offsets straight off them, and String writes one the way the assembler reads it.
+
+	A register accessed through the register window (see WindowAddress) keeps the register
+	it was encoded with and the WSR value, and shows as the register it really reaches.
+*/
+
+// Operand is one decoded operand of an instruction
+type Operand interface {
+	String() string
+}
+
+// RegisterOperand is a register accessed directly, R_40
+type RegisterOperand struct {
+	Reg    int
+	Window int // WSR value the access goes through, 0 if it isn't windowed
+}
+
+// ImmediateOperand is a constant held in the instruction, #00FF
+type ImmediateOperand struct {
+	Value int
+	Size  int // bytes
+}
+
+// IndirectOperand is the memory a word register points at, [R_30] or [R_30]+
+type IndirectOperand struct {
+	Reg           int
+	Window        int
+	AutoIncrement bool
+}
+
+// IndexedOperand is the memory at an offset from a word register, 0x12[R_30]. Offset is as
+// encoded, Displacement is what gets added to the register.
+type IndexedOperand struct {
+	Reg    int
+	Window int
+	Offset int
+	Size   int // bytes of offset, 1 short-indexed, 2 long-indexed, 3 extended-indexed
+}
+
+// AddressOperand is a jump or call target, 0x172080
+type AddressOperand struct {
+	Address    int
+	OpcodeBits byte // the high displacement bits SJMP and SCALL carry in the opcode (xxx)
+}
+
+// BitOperand is the bit JBC and JBS test
+type BitOperand struct {
+	Bit int
+}
+
+func (o RegisterOperand) String() string {
+	return regString(o.Reg, o.Window)
+}
+
+func (o ImmediateOperand) String() string {
+	return fmt.Sprintf("#%0*X", o.Size*2, o.Value)
+}
+
+func (o IndirectOperand) String() string {
+	if o.AutoIncrement {
+		return "[" + regString(o.Reg, o.Window) + "]+"
+	}
+	return "[" + regString(o.Reg, o.Window) + "]"
+}
+
+func (o IndexedOperand) String() string {
+	return fmt.Sprintf("0x%0*X[%s]", o.Size*2, o.Offset, regString(o.Reg, o.Window))
+}
+
+func (o AddressOperand) String() string {
+	return fmt.Sprintf("0x%X", o.Address)
+}
+
+func (o BitOperand) String() string {
+	return fmt.Sprintf("%d", o.Bit)
+}
+
+// Displacement is the offset as a signed number, short-indexed offsets are a signed byte
+func (o IndexedOperand) Displacement() int {
+	if o.Size == 1 {
+		return int(int8(o.Offset))
+	}
+	return o.Offset
+}
+
+// The address a register access reaches, through the window if there is one
+func regAddress(reg, wsr int) int {
+	if wsr != 0 {
+		adr, _ := WindowAddress(wsr, reg)
+		return adr
+	}
+	return reg
+}
+
+// A register by name, a windowed one by what it really is
+func regString(reg, wsr int) string {
+	if wsr != 0 {
+		if adr, ok := WindowAddress(wsr, reg); ok {
+			return windowName(adr)
+		}
+	}
+	return fmt.Sprintf("R_%02X", reg)
+}
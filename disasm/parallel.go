@@ -0,0 +1,204 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+	Parallel analysis. The crawl is split by function: each job decodes one subroutine,
+	following its jumps but not its calls, and hands back what it decoded and the
+	subroutines it calls, which become new jobs. Parsing is most of the work, so the
+	instructions are merged into one Analysis afterwards by filing each one once, in
+	address order, the same way the single threaded crawl files them.
+
+	Code shared between functions (tail jumps) is decoded by each of them and kept once.
+	Where two of them decode the same bytes from different starts the one at the lower
+	address is kept, the way the single threaded crawl keeps the one it reached first.
+*/
+
+// crawl is what one job found
+type crawl struct {
+	instrs []Instruction
+	failed []int // addresses that didn't parse
+	calls  []int
+}
+
+// SetWorkers makes Analyze crawl with a pool of n goroutines, 0 or 1 crawls in one
+func (h *DisAsm) SetWorkers(n int) {
+	h.workers = n
+}
+
+func (h *DisAsm) analyzeParallel() (*Analysis, []int, error) {
+	if err := h.GetInterrupts(); err != nil {
+		return nil, nil, err
+	}
+	h.GetMemoryMap()
+	memory := h.MemoryMap()
+
+	roots := h.roots()
+
+	jobs := make(chan int)
+	results := make(chan *crawl)
+	var wg sync.WaitGroup
+	for i := 0; i < h.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				results <- h.crawlFunction(entry, memory)
+			}
+		}()
+	}
+
+	seen := make(map[int]bool)
+	var queue []int
+	for _, adr := range roots {
+		if !seen[adr] {
+			seen[adr] = true
+			queue = append(queue, adr)
+		}
+	}
+
+	decoded := make(map[int]Instruction)
+	failed := make(map[int]bool)
+
+	for pending := 0; len(queue) > 0 || pending > 0; {
+		var send chan int
+		var next int
+		if len(queue) > 0 {
+			send = jobs
+			next = queue[0]
+		}
+
+		select {
+		case send <- next:
+			queue = queue[1:]
+			pending++
+		case c := <-results:
+			pending--
+			for _, instr := range c.instrs {
+				decoded[instr.Address] = instr
+			}
+			for _, adr := range c.failed {
+				failed[adr] = true
+			}
+			for _, adr := range c.calls {
+				if !seen[adr] {
+					seen[adr] = true
+					queue = append(queue, adr)
+				}
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Merge
+	an := &Analysis{
+		Subroutines: make(map[int][]Call),
+		XRefs:       make(map[int][]XRef),
+		Jumps:       make(map[int][]Jump),
+		Crawled:     make(map[int]int),
+	}
+
+	for adr := range decoded {
+		an.Opcodes = append(an.Opcodes, decoded[adr])
+	}
+	sort.Sort(an.Opcodes)
+
+	// Two functions that decode the same bytes from different starts, keep the lower one,
+	// findConflicts reports the path into the other
+	kept := an.Opcodes[:0]
+	for _, instr := range an.Opcodes {
+		if n := len(kept); n > 0 && instr.Address < kept[n-1].Address+kept[n-1].ByteLength {
+			continue
+		}
+		kept = append(kept, instr)
+	}
+	an.Opcodes = kept
+
+	for _, instr := range an.Opcodes {
+		for i := 0; i < instr.ByteLength; i++ {
+			an.Crawled[instr.Address+i] = 1
+		}
+		an.record(instr, memory)
+	}
+	var bad []int
+	for adr := range failed {
+		if an.Crawled[adr] == 0 {
+			bad = append(bad, adr)
+		}
+	}
+	sort.Ints(bad)
+	for _, adr := range bad {
+		_, err := h.parse(adr)
+		h.log(fmt.Sprintf("ERROR!! Address: 0x%X		Instruction %X", adr, h.block[adr:adr+10]), err)
+		an.Crawled[adr] = 3
+		an.Errors++
+	}
+
+	h.findConflicts(an)
+	h.trackWindows(an, roots)
+	branches := h.propagateConstants(an, roots)
+	an.pairBranches()
+
+	return an, branches, nil
+}
+
+// Decodes one function from its entry, following jumps but not calls
+func (h *DisAsm) crawlFunction(entry int, memory *MemoryMap) *crawl {
+	c := new(crawl)
+	local := &Analysis{
+		Subroutines: make(map[int][]Call),
+		XRefs:       make(map[int][]XRef),
+		Jumps:       make(map[int][]Jump),
+	}
+	crawled := make(map[int]bool)
+
+	paths := []int{entry}
+	for len(paths) > 0 {
+		pc := paths[len(paths)-1]
+		paths = paths[:len(paths)-1]
+		if pc+10 > len(h.block) {
+			crawled[pc] = true
+		}
+
+		for pc+10 <= len(h.block) && !crawled[pc] {
+			if !h.decodable(pc) {
+				crawled[pc] = true // known data, the path ends here
+				break
+			}
+			instr, err := h.parse(pc)
+			for i := 0; i < instr.ByteLength; i++ {
+				crawled[pc+i] = true
+			}
+			if err != nil {
+				c.failed = append(c.failed, pc)
+				break
+			}
+			c.instrs = append(c.instrs, instr)
+
+			next, ok := local.record(instr, memory)
+			if !ok {
+				break
+			}
+			pc = next
+		}
+
+		// Conditional jump targets not followed yet
+		if len(paths) == 0 {
+			for adr := range local.Jumps {
+				if !crawled[adr] {
+					paths = append(paths, adr)
+				}
+			}
+		}
+	}
+
+	for adr := range local.Subroutines {
+		c.calls = append(c.calls, adr)
+	}
+	return c
+}
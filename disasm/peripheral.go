@@ -0,0 +1,166 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+	Peripheral access report. Every instruction that touches an SFR is filed under the
+	peripheral the register belongs to, going by the register's name in RegObjs: AD_ is
+	the A/D converter, EPA and OS are the event processor array (the EA's HSI/HSO), SBUF,
+	SP0/SP1 and SSIO are the serial ports, and so on. Direct, windowed and zero-based
+	indexed operands are taken from the operands themselves, pointer accesses from the
+	XRefs constant propagation resolved.
+*/
+
+// PeripheralAccess is one instruction touching a peripheral register
+type PeripheralAccess struct {
+	Ref
+	Register string
+}
+
+func (p PeripheralAccess) String() string {
+	return fmt.Sprintf("0x%X %-6s %-4s %-14s 0x%X/%d", p.From, p.Mnemonic, p.Access, p.Register, p.To, p.Width)
+}
+
+// Peripheral is every access to one peripheral's registers, in address order
+type Peripheral struct {
+	Name     string
+	Accesses []PeripheralAccess
+}
+
+// Peripherals in report order, by register name prefix
+var peripheralPrefixes = []struct {
+	name     string
+	prefixes []string
+}{
+	{"A/D", []string{"AD_"}},
+	{"EPA/HSO", []string{"EPA", "OS"}},
+	{"Serial", []string{"SBUF", "SP0_", "SP1_", "SSIO"}},
+	{"Timers", []string{"TIMER", "T1", "T2", "T3", "T4", "WATCHDOG", "CLKOUT"}},
+	{"PWM", []string{"PWM"}},
+	{"Ports", []string{"P2_", "P3", "P4", "P5", "P7", "P8", "P9", "P10", "P11", "P12", "EP_"}},
+	{"Interrupts", []string{"INT_", "PIH", "PTS"}},
+	{"Bus", []string{"ADDRCOM", "ADDRMSK", "BUSCON", "IRAM_CON"}},
+}
+
+// PeripheralOf names the peripheral an SFR belongs to, false for memory that isn't a
+// peripheral register. SFRs with no name in RegObjs are "Other".
+func PeripheralOf(adr int) (string, bool) {
+	reg, named := RegObjs[adr]
+	if !named && adr&1 == 1 {
+		reg, named = RegObjs[adr-1] // high byte of a word register
+	}
+
+	if named {
+		m := strings.TrimSpace(reg.Mnemonic)
+		for _, p := range peripheralPrefixes {
+			for _, prefix := range p.prefixes {
+				if strings.HasPrefix(m, prefix) {
+					return p.name, true
+				}
+			}
+		}
+	}
+	if adr >= 0x1C00 && adr <= 0x1FFF {
+		return "Other", true
+	}
+	return "", false
+}
+
+// Name for an SFR, from RegObjs where it has one
+func sfrName(adr int) string {
+	if r, ok := RegObjs[adr]; ok {
+		return strings.TrimSpace(r.Mnemonic)
+	}
+	if r, ok := RegObjs[adr-1]; ok && adr&1 == 1 {
+		return strings.TrimSpace(r.Mnemonic) + "+1"
+	}
+	return fmt.Sprintf("SFR_%04X", adr)
+}
+
+// Peripherals groups every instruction that reads or writes a peripheral register by
+// peripheral
+func (h *DisAsm) Peripherals(an *Analysis) []Peripheral {
+	byName := make(map[string][]PeripheralAccess)
+	seen := make(map[[2]int]bool)
+
+	add := func(r Ref) {
+		name, ok := PeripheralOf(r.To)
+		if !ok || seen[[2]int{r.From, r.To}] {
+			return
+		}
+		seen[[2]int{r.From, r.To}] = true
+		byName[name] = append(byName[name], PeripheralAccess{Ref: r, Register: sfrName(r.To)})
+	}
+
+	for i := range an.Opcodes {
+		for _, r := range operandRefs(&an.Opcodes[i]) {
+			add(r)
+		}
+	}
+
+	// Pointer accesses resolved by constant propagation
+	for to, xrefs := range an.XRefs {
+		if _, ok := PeripheralOf(to); !ok {
+			continue
+		}
+		for _, x := range xrefs {
+			instr, ok := an.instruction(x.XRefFrom)
+			if !ok {
+				continue
+			}
+			if a, w, ok := pointerAccess(instr, x); ok {
+				add(Ref{From: x.XRefFrom, To: to, Width: w, Access: a, Mnemonic: instr.Mnemonic})
+			}
+		}
+	}
+
+	var names []string
+	for _, p := range peripheralPrefixes {
+		names = append(names, p.name)
+	}
+
+	var out []Peripheral
+	for _, name := range append(names, "Other") {
+		if accesses := byName[name]; len(accesses) > 0 {
+			sort.Sort(peripheralAccesses(accesses))
+			out = append(out, Peripheral{Name: name, Accesses: accesses})
+		}
+	}
+	return out
+}
+
+// How instr uses the pointer operand an XRef was resolved from and how many bytes, false if
+// the XRef didn't come from a pointer operand
+func pointerAccess(instr Instruction, x XRef) (Access, int, bool) {
+	m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+	threeOp := instr.threeOperand()
+
+	for i, op := range instr.Ops {
+		if _, _, ok := pointerOperand(op); ok && strings.HasPrefix(x.String, pseudoOperand(op)+" = ") {
+			typ := strings.ToUpper(instr.VarTypes[i])
+			return operandAccess(m, typ, threeOp), operandWidth(m, typ), true
+		}
+	}
+	return 0, 0, false
+}
+
+type peripheralAccesses []PeripheralAccess
+
+func (p peripheralAccesses) Len() int {
+	return len(p)
+}
+
+func (p peripheralAccesses) Less(i, j int) bool {
+	if p[i].From != p[j].From {
+		return p[i].From < p[j].From
+	}
+	return p[i].To < p[j].To
+}
+
+func (p peripheralAccesses) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
@@ -0,0 +1,242 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	Pointer tables. Dispatch tables reached through an indirect CALL or BR, or a TIJMP
+	whose base the crawl couldn't work out, leave their targets unreached. The data the
+	crawl didn't reach is searched for aligned runs of words, each the address of
+	plausible code in the table's own 64K page, and for runs of longs holding 24 bit
+	addresses. A target is plausible code if it is in ROM or flash in a page the crawl
+	found code in, and either is a subroutine or jump target the crawl found, or wasn't
+	reached and decodes from there to a RET or a jump the way Unreachable expects code to.
+	Runs of unreached code are full of words that happen to point at code, so a table has
+	to start at an address the code refers to, as an immediate, an XRef or the offset of
+	an indexed operand. The interrupt and PTS vectors are tables of code addresses too,
+	they are already followed and left out.
+*/
+
+// PointerTable is a run of code addresses found in data
+type PointerTable struct {
+	Start   int
+	Size    int   // bytes per entry, 2 or 4
+	Targets []int // one per entry
+}
+
+func (t PointerTable) String() string {
+	return fmt.Sprintf("0x%X %d x %s", t.Start, len(t.Targets), dataFormats[t.Size])
+}
+
+// Most instructions from an unreached target to the end of its run
+const pointerTargetInstrs = 256
+
+// PointerTables finds the tables of at least minEntries code addresses in the data
+func (h *DisAsm) PointerTables(an *Analysis, minEntries int) []PointerTable {
+	h.GetInterrupts()
+
+	pages := make(map[int]bool)
+	for _, instr := range an.Opcodes {
+		pages[instr.Address>>16] = true
+	}
+
+	refs := tableRefs(an)
+
+	var found []PointerTable
+	for _, r := range h.regions(an) {
+		if r.Class != "data" {
+			continue
+		}
+		for _, size := range []int{4, 2} {
+			found = append(found, h.pointerRuns(an, pages, refs, r, size, minEntries)...)
+		}
+	}
+
+	// A table of longs is also a table of words, keep the longs
+	sort.Sort(pointerTables(found))
+	var tables []PointerTable
+	for _, t := range found {
+		if n := len(tables); n > 0 && t.Start < tables[n-1].Start+len(tables[n-1].Targets)*tables[n-1].Size {
+			continue
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
+
+// Runs of entries of one size in a data region
+func (h *DisAsm) pointerRuns(an *Analysis, pages, refs map[int]bool, r Region, size, minEntries int) []PointerTable {
+	var runs []PointerTable
+	run := PointerTable{Size: size}
+	end := func() {
+		// Trim the run to start where the code refers to it
+		for len(run.Targets) >= minEntries && !refs[run.Start] && !refs[run.Start&0xFFFF] {
+			run.Start += size
+			run.Targets = run.Targets[1:]
+		}
+		if len(run.Targets) >= minEntries && !sameTargets(run.Targets) {
+			runs = append(runs, run)
+		}
+	}
+
+	start := r.Start + r.Start%2
+	for adr := start; adr+size <= r.Stop+1; adr += size {
+		target, ok := h.pointerEntry(adr, size)
+		if !ok || h.vectorAdr[adr] != "" || !pages[target>>16] || !h.codeTarget(an, target) {
+			end()
+			run = PointerTable{Start: adr + size, Size: size}
+			continue
+		}
+		if len(run.Targets) == 0 {
+			run.Start = adr
+		}
+		run.Targets = append(run.Targets, target)
+	}
+	end()
+	return runs
+}
+
+// The address an entry holds, words are in the page of the table. A word that addresses
+// the register file or SFRs, which are in every page, can't be code.
+func (h *DisAsm) pointerEntry(adr, size int) (int, bool) {
+	v := int(h.block[adr]) | int(h.block[adr+1])<<8
+	if size == 2 {
+		kind := h.MemoryMap().Kind(v)
+		return adr&^0xFFFF | v, kind != KindSFR && kind != KindRAM
+	}
+	v |= int(h.block[adr+2]) << 16
+	return v, h.block[adr+3] == 0
+}
+
+// Whether an address looks like the start of code
+func (h *DisAsm) codeTarget(an *Analysis, target int) bool {
+	memory := h.MemoryMap()
+	if kind := memory.Kind(target); kind != KindROM && kind != KindExternal || target+10 > len(h.block) {
+		return false
+	}
+	if an.Crawled[target] == 1 {
+		_, sub := an.Subroutines[target]
+		_, jump := an.Jumps[target]
+		return sub || jump
+	}
+	if an.Crawled[target] != 0 {
+		return false
+	}
+
+	adr := target
+	for i := 0; i < pointerTargetInstrs; i++ {
+		if adr+10 > len(h.block) {
+			return false
+		}
+		if an.Crawled[adr] == 1 {
+			_, ok := an.instruction(adr) // ran into code that was reached
+			return ok && adr > target
+		}
+		instr, err := h.parse(adr)
+		if err != nil || !plausible(an, memory, instr) {
+			return false
+		}
+		if exit, ok := exitsTo(an, instr); !ok {
+			return false
+		} else if exit {
+			return true
+		}
+		adr += instr.ByteLength
+	}
+	return false
+}
+
+// The addresses the code refers to, 16 bit ones could be in any page
+func tableRefs(an *Analysis) map[int]bool {
+	refs := make(map[int]bool)
+	for adr := range an.XRefs {
+		refs[adr] = true
+	}
+	for _, instr := range an.Opcodes {
+		for _, op := range instr.Ops {
+			if n, ok := immediate(op); ok {
+				refs[n] = true
+			} else if x, ok := op.(IndexedOperand); ok && x.Reg != 0 && x.Window == 0 {
+				refs[x.Offset] = true
+			}
+		}
+	}
+	return refs
+}
+
+// Whether every entry is the same, fill more than a table
+func sameTargets(targets []int) bool {
+	for _, t := range targets {
+		if t != targets[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// FollowPointerTables crawls again from the targets of the pointer tables in an, over and
+// over while the new code turns up more tables. Returns the last analysis and every table.
+func (h *DisAsm) FollowPointerTables(an *Analysis, minEntries int) (*Analysis, []PointerTable, error) {
+	roots := make(map[int]bool)
+	for _, adr := range h.roots() {
+		roots[adr] = true
+	}
+
+	var tables []PointerTable
+	found := make(map[int]bool)
+	for {
+		var added []int
+		for _, t := range h.PointerTables(an, minEntries) {
+			if !found[t.Start] {
+				found[t.Start] = true
+				tables = append(tables, t)
+			}
+			for _, adr := range t.Targets {
+				if !roots[adr] {
+					roots[adr] = true
+					added = append(added, adr)
+				}
+			}
+		}
+		if len(added) == 0 {
+			return an, tables, nil
+		}
+
+		h.AddEntryPoints(added...)
+		var err error
+		if an, err = h.Analyze(); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// AddEntryPoints has Analyze crawl from these addresses as well as the reset and interrupt
+// vectors
+func (h *DisAsm) AddEntryPoints(adrs ...int) {
+	h.entries = append(h.entries, adrs...)
+}
+
+// The reset vector, the interrupt routines and any entry points added
+func (h *DisAsm) roots() []int {
+	roots := append([]int{0x172080}, h.intRoutineAdrs...)
+	return append(roots, h.entries...)
+}
+
+type pointerTables []PointerTable
+
+func (t pointerTables) Len() int {
+	return len(t)
+}
+
+func (t pointerTables) Less(i, j int) bool {
+	if t[i].Start != t[j].Start {
+		return t[i].Start < t[j].Start
+	}
+	return t[i].Size > t[j].Size
+}
+
+func (t pointerTables) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+}
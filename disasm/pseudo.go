@@ -0,0 +1,239 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	Pseudo code styles. PseudoCode is always C-like, it's what the decompiler builds on.
+	The listing, HTML and JSON export can show another style instead, so the pseudo code
+	can be pasted into a simulation script with little editing:
+
+		C         R_40 += 0x05            if ((signed)R_40 > 0x5E) goto 0x1234
+		Python    R_40 += 0x05            if signed(R_40) > 0x5E: goto(0x1234)
+		English   add 0x05 to R_40        if signed R_40 is above 0x5E, go to 0x1234
+
+	Python reads memory as mem[...] and the flags as C, Z, N, V, VT and ST, gotos and
+	calls are left for the script to define.
+*/
+
+// PseudoStyle is the dialect pseudo code is written in
+type PseudoStyle int
+
+const (
+	PseudoC PseudoStyle = iota
+	PseudoPython
+	PseudoEnglish
+)
+
+var pseudoStyleNames = map[PseudoStyle]string{PseudoC: "c", PseudoPython: "python", PseudoEnglish: "english"}
+
+func (s PseudoStyle) String() string {
+	return pseudoStyleNames[s]
+}
+
+// ParsePseudoStyle is the style named c, python or english
+func ParsePseudoStyle(name string) (PseudoStyle, error) {
+	for s, n := range pseudoStyleNames {
+		if strings.EqualFold(name, n) {
+			return s, nil
+		}
+	}
+	return PseudoC, fmt.Errorf("Unknown pseudo code style %s, use c, python or english", name)
+}
+
+// How a style writes each kind of statement. The formats take their values in the order
+// the comments give.
+type dialect struct {
+	memory     string            // before a [...] memory operand
+	assign     string            // dest, value
+	update     string            // dest, operator, value - a compound assignment
+	updates    map[string]string // dest, operator, value, for operators said another way
+	ops        map[string]string // operators and comparisons spelled another way
+	not        string            // before a negated flag or test
+	and, or    string
+	signed     string // value, read as signed
+	signedByte string // value, a byte sign extended
+	borrow     string // after a subtract with carry
+	swap       string // a, b
+	complement string // dest
+	negate     string // dest
+	inc, dec   string // dest
+	extend     string // dest
+	fn         string // name, arguments
+	copyWords  string // count, from, to
+	ifGoto     string // condition, target
+	jump       string // target
+	jumpTo     string // register holding the target
+	bitSet     string // register, bit number
+	bitClear   string // register, bit number
+	flagSet    string // name of the bit
+	flagClear  string // name of the bit
+	djnz       string // register, target
+	call       string // name
+	ret        string
+}
+
+var dialects = map[PseudoStyle]*dialect{
+	PseudoC: {
+		assign:     "%s = %s",
+		update:     "%s %s= %s",
+		not:        "!",
+		and:        "&&",
+		or:         "||",
+		signed:     "(signed)%s",
+		signedByte: "(signed char)%s",
+		borrow:     " - !C",
+		swap:       "swap(%s, %s)",
+		complement: "%[1]s = ~%[1]s",
+		negate:     "%[1]s = -%[1]s",
+		inc:        "%s++",
+		dec:        "%s--",
+		extend:     "%[1]s = sign_extend(%[1]s)",
+		fn:         "%s(%s)",
+		copyWords:  "copy %s words from [%s] to [%s]",
+		ifGoto:     "if (%s) goto %s",
+		jump:       "goto %s",
+		jumpTo:     "goto *%s",
+		bitSet:     "%s & (1 << %s)",
+		bitClear:   "!(%s & (1 << %s))",
+		flagSet:    "%s",
+		flagClear:  "!%s",
+		djnz:       "if (--%[1]s != 0) goto %[2]s",
+		call:       "%s()",
+		ret:        "return",
+	},
+	PseudoPython: {
+		memory:     "mem",
+		assign:     "%s = %s",
+		update:     "%s %s= %s",
+		ops:        map[string]string{"/": "//"},
+		not:        "not ",
+		and:        "and",
+		or:         "or",
+		signed:     "signed(%s)",
+		signedByte: "sign_extend(%s)",
+		borrow:     " - (1 - C)",
+		swap:       "%[1]s, %[2]s = %[2]s, %[1]s",
+		complement: "%[1]s = ~%[1]s",
+		negate:     "%[1]s = -%[1]s",
+		inc:        "%s += 1",
+		dec:        "%s -= 1",
+		extend:     "%[1]s = sign_extend(%[1]s)",
+		fn:         "%s(%s)",
+		copyWords:  "copy_words(%[2]s, %[3]s, %[1]s)",
+		ifGoto:     "if %s: goto(%s)",
+		jump:       "goto(%s)",
+		jumpTo:     "goto(%s)",
+		bitSet:     "%s & (1 << %s)",
+		bitClear:   "not (%s & (1 << %s))",
+		flagSet:    "%s",
+		flagClear:  "not %s",
+		djnz:       "%[1]s -= 1; if %[1]s != 0: goto(%[2]s)",
+		call:       "%s()",
+		ret:        "return",
+	},
+	PseudoEnglish: {
+		assign: "set %s to %s",
+		update: "set %[1]s to %[1]s %[2]s %[3]s",
+		updates: map[string]string{
+			"+":  "add %[3]s to %[1]s",
+			"-":  "subtract %[3]s from %[1]s",
+			"*":  "multiply %[1]s by %[3]s",
+			"/":  "divide %[1]s by %[3]s",
+			"<<": "shift %[1]s left by %[3]s",
+			">>": "shift %[1]s right by %[3]s",
+		},
+		ops: map[string]string{
+			"==": "is", "!=": "is not", ">": "is above", ">=": "is at least", "<": "is below", "<=": "is at most",
+		},
+		not:        "not ",
+		and:        "and",
+		or:         "or",
+		signed:     "signed %s",
+		signedByte: "%s sign extended",
+		borrow:     " - (1 - C)",
+		swap:       "swap %s and %s",
+		complement: "invert %s",
+		negate:     "negate %s",
+		inc:        "increment %s",
+		dec:        "decrement %s",
+		extend:     "sign extend %s",
+		fn:         "%s %s",
+		copyWords:  "copy %s words from [%s] to [%s]",
+		ifGoto:     "if %s, go to %s",
+		jump:       "go to %s",
+		jumpTo:     "go to the address in %s",
+		bitSet:     "bit %[2]s of %[1]s is set",
+		bitClear:   "bit %[2]s of %[1]s is clear",
+		flagSet:    "%s is set",
+		flagClear:  "%s is clear",
+		djnz:       "decrement %[1]s, if it is not 0 go to %[2]s",
+		call:       "call %s",
+		ret:        "return",
+	},
+}
+
+func (d *dialect) operand(op string) string {
+	if strings.HasPrefix(op, "[") {
+		return d.memory + op
+	}
+	return op
+}
+
+func (d *dialect) op(op string) string {
+	if s, ok := d.ops[op]; ok {
+		return s
+	}
+	return op
+}
+
+func (d *dialect) compound(dest, op, value string) string {
+	if f, ok := d.updates[op]; ok {
+		return fmt.Sprintf(f, dest, op, value)
+	}
+	return fmt.Sprintf(d.update, dest, d.op(op), value)
+}
+
+func (d *dialect) function(name string, args ...string) string {
+	return strings.TrimSpace(fmt.Sprintf(d.fn, name, strings.Join(args, ", ")))
+}
+
+// A condition in the style, the flag tests ("!N && !Z") are C to begin with
+func (d *dialect) condition(c condition) string {
+	if c.op == "" {
+		words := strings.Fields(c.raw)
+		for i, w := range words {
+			switch {
+			case w == "&&":
+				words[i] = d.and
+			case w == "||":
+				words[i] = d.or
+			case strings.HasPrefix(w, "!"):
+				words[i] = d.not + w[1:]
+			}
+		}
+		return strings.Join(words, " ")
+	}
+
+	left := d.operand(c.left)
+	if c.signed {
+		left = fmt.Sprintf(d.signed, left)
+	}
+	return fmt.Sprintf("%s %s %s", left, d.op(c.op), d.operand(c.right))
+}
+
+// Pseudo is the instruction's pseudo code in a style, PseudoCode for C
+func (instr *Instruction) Pseudo(style PseudoStyle) string {
+	d, ok := dialects[style]
+	if style == PseudoC || !ok {
+		return instr.PseudoCode
+	}
+	return instr.statement(d)
+}
+
+// SetPseudoStyle sets the style the listing, HTML and export show pseudo code in
+func (h *DisAsm) SetPseudoStyle(style PseudoStyle) {
+	h.pseudoStyle = style
+}
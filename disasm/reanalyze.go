@@ -0,0 +1,257 @@
+package disasm
+
+import "sort"
+
+/*
+	Re-analysis. A patch changes a few bytes of a big image, and crawling the whole image
+	again for it makes every edit in an interactive session wait. Reanalyze works out which
+	functions the changed bytes are code of, walking back from the changed instructions to
+	the entries that reach them, and throws away what was decoded in those functions along
+	with the XRefs, calls and jumps filed from there. The crawl then starts again from the
+	functions that are still called or jumped to, stopping at code it already knows, and
+	constant propagation runs on the functions crawled again and any it found on the way.
+
+	Subroutines only the thrown away functions called are thrown away as well, and come
+	back if the new code still calls them. Changed bytes that aren't code leave the
+	analysis as it is.
+*/
+
+// Reanalyze updates an, an analysis of this image, after the bytes from start to stop
+// (one past the last) were changed in the image, re-crawling only the functions they're in
+func (h *DisAsm) Reanalyze(an *Analysis, start, stop int) (*Analysis, error) {
+	an, err := h.recrawl(an, start, stop)
+	if err != nil || !h.detectData {
+		return an, err
+	}
+	return h.reclassify(an)
+}
+
+// Re-crawls the functions the bytes from start to stop are in
+func (h *DisAsm) recrawl(an *Analysis, start, stop int) (*Analysis, error) {
+	if err := h.GetInterrupts(); err != nil {
+		return nil, err
+	}
+	memory := h.MemoryMap()
+
+	changed := an.changedCode(start, stop)
+	if len(changed) == 0 {
+		return an, nil
+	}
+	owners := h.owners(an, changed)
+	entries := append([]int{}, owners...)
+
+	// Subroutines nothing calls any more go too, the crawl brings back the ones still
+	// called from the new code
+	for orphans := h.discard(an, owners, changed); len(orphans) > 0; {
+		entries = append(entries, orphans...)
+		orphans = h.discard(an, orphans, orphans)
+	}
+
+	known := make(map[int]bool)
+	for adr := range an.Subroutines {
+		known[adr] = true
+	}
+
+	pcs := h.roots()
+	for {
+		h.crawl(an, pcs, memory)
+		sort.Sort(an.Opcodes)
+		for adr := range an.Subroutines {
+			if !known[adr] {
+				known[adr] = true
+				entries = append(entries, adr)
+			}
+		}
+
+		h.findConflicts(an)
+		h.trackWindows(an, h.roots())
+		branches := h.propagate(an, entries)
+		an.pairBranches()
+		if len(branches) == 0 {
+			return an, nil
+		}
+		h.AddEntryPoints(branches...)
+		pcs = branches
+		entries = append(entries, branches...)
+	}
+}
+
+// The addresses of the instructions with bytes from start to stop, and of the paths that
+// ended there in data or in what didn't decode
+func (an *Analysis) changedCode(start, stop int) []int {
+	var adrs []int
+	for adr := start; adr < stop; adr++ {
+		if c := an.Crawled[adr]; c == 2 || c == 3 {
+			adrs = append(adrs, adr)
+		}
+	}
+	i := sort.Search(len(an.Opcodes), func(i int) bool { return an.Opcodes[i].Address >= start })
+	if i > 0 && an.Opcodes[i-1].Address+an.Opcodes[i-1].ByteLength > start {
+		i--
+	}
+	for ; i < len(an.Opcodes) && an.Opcodes[i].Address < stop; i++ {
+		adrs = append(adrs, an.Opcodes[i].Address)
+	}
+	return adrs
+}
+
+// The entries of the functions that reach the instructions at adrs, found by following
+// jumps and fall throughs backwards from them
+func (h *DisAsm) owners(an *Analysis, adrs []int) []int {
+	entry := make(map[int]bool)
+	for _, adr := range h.roots() {
+		entry[adr] = true
+	}
+
+	// Instructions by where they end, to find what falls through into an address
+	ending := make(map[int][]Instruction)
+	for _, instr := range an.Opcodes {
+		end := instr.Address + instr.ByteLength
+		ending[end] = append(ending[end], instr)
+	}
+
+	var owners []int
+	seen := make(map[int]bool)
+	todo := append([]int{}, adrs...)
+	for len(todo) > 0 {
+		adr := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if seen[adr] {
+			continue
+		}
+		seen[adr] = true
+
+		if entry[adr] || len(an.Subroutines[adr]) > 0 {
+			owners = append(owners, adr)
+		}
+		for _, j := range an.Jumps[adr] {
+			todo = append(todo, j.JumpFrom)
+		}
+		for _, prev := range ending[adr] {
+			if _, falls := flow(prev); falls {
+				todo = append(todo, prev.Address)
+			}
+		}
+	}
+	sort.Ints(owners)
+	return owners
+}
+
+// Takes the instructions of the functions at owners, and those at adrs, out of the analysis
+// with everything filed from them. Returns the subroutines that lost their last call and
+// aren't jumped to or entered any other way.
+func (h *DisAsm) discard(an *Analysis, owners, adrs []int) []int {
+	gone := make(map[int]bool)
+	for _, adr := range adrs {
+		gone[adr] = true
+		an.forgetEnd(adr)
+	}
+	for _, entry := range owners {
+		cfg, err := h.CFG(an, entry)
+		if err != nil {
+			continue
+		}
+		for _, blk := range cfg.Blocks {
+			for _, instr := range blk.Instrs {
+				gone[instr.Address] = true
+			}
+		}
+	}
+
+	kept := an.Opcodes[:0]
+	for _, instr := range an.Opcodes {
+		if !gone[instr.Address] {
+			kept = append(kept, instr)
+			continue
+		}
+		for i := 0; i < instr.ByteLength; i++ {
+			delete(an.Crawled, instr.Address+i)
+		}
+		if instr.Mnemonic == "RET" || instr.Mnemonic == "RST" {
+			an.Returns--
+		}
+
+		// Paths that ended in data or in what didn't decode get another look
+		targets, falls := flow(instr)
+		if falls {
+			targets = append(targets, instr.Address+instr.ByteLength)
+		}
+		for _, t := range targets {
+			an.forgetEnd(t)
+		}
+	}
+	an.Opcodes = kept
+
+	for adr, xrefs := range an.XRefs {
+		k := xrefs[:0]
+		for _, x := range xrefs {
+			if !gone[x.XRefFrom] {
+				k = append(k, x)
+			}
+		}
+		if len(k) == 0 {
+			delete(an.XRefs, adr)
+		} else {
+			an.XRefs[adr] = k
+		}
+	}
+	var orphans []int
+	for adr, calls := range an.Subroutines {
+		k := calls[:0]
+		for _, c := range calls {
+			if !gone[c.CallFrom] {
+				k = append(k, c)
+			}
+		}
+		if len(k) == 0 {
+			delete(an.Subroutines, adr)
+			orphans = append(orphans, adr)
+		} else {
+			an.Subroutines[adr] = k
+		}
+	}
+	for adr, jumps := range an.Jumps {
+		k := jumps[:0]
+		for _, j := range jumps {
+			if !gone[j.JumpFrom] {
+				k = append(k, j)
+			}
+		}
+		if len(k) == 0 {
+			delete(an.Jumps, adr)
+		} else {
+			an.Jumps[adr] = k
+		}
+	}
+	invalid := an.Invalid[:0]
+	for _, x := range an.Invalid {
+		if !gone[x.XRefFrom] {
+			invalid = append(invalid, x)
+		}
+	}
+	an.Invalid = invalid
+
+	roots := make(map[int]bool)
+	for _, adr := range h.roots() {
+		roots[adr] = true
+	}
+	var lost []int
+	for _, adr := range orphans {
+		if !roots[adr] && len(an.Jumps[adr]) == 0 && !gone[adr] {
+			lost = append(lost, adr)
+		}
+	}
+	sort.Ints(lost)
+	return lost
+}
+
+// Forgets that a path ended at adr in data or in what didn't decode
+func (an *Analysis) forgetEnd(adr int) {
+	switch an.Crawled[adr] {
+	case 3:
+		an.Errors--
+		fallthrough
+	case 2:
+		delete(an.Crawled, adr)
+	}
+}
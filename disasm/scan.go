@@ -0,0 +1,77 @@
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a byte signature with a mask, a 0 bit in the mask matches anything
+type Pattern struct {
+	Bytes []byte
+	Mask  []byte
+}
+
+// ParsePattern reads a signature as hex bytes separated by spaces. ?? matches any byte, and
+// a single ? matches any nibble, so "A1 ?? ?? 30 C?" finds a word load of any immediate into
+// R_30 followed by anything from C0 to CF.
+func ParsePattern(s string) (Pattern, error) {
+	var p Pattern
+	for _, f := range strings.Fields(s) {
+		if len(f) != 2 {
+			return p, fmt.Errorf("Bad pattern byte: %s", f)
+		}
+
+		var b, m byte
+		for i, c := range strings.ToUpper(f) {
+			b <<= 4
+			m <<= 4
+			if c == '?' {
+				continue
+			}
+			v, err := strconv.ParseUint(f[i:i+1], 16, 8)
+			if err != nil {
+				return p, fmt.Errorf("Bad pattern byte: %s", f)
+			}
+			b |= byte(v)
+			m |= 0xF
+		}
+		p.Bytes = append(p.Bytes, b)
+		p.Mask = append(p.Mask, m)
+	}
+
+	if len(p.Bytes) == 0 {
+		return p, errors.New("Empty pattern")
+	}
+	return p, nil
+}
+
+// Match checks the pattern against the start of b
+func (p Pattern) Match(b []byte) bool {
+	if len(b) < len(p.Bytes) {
+		return false
+	}
+	for i := range p.Bytes {
+		if b[i]&p.Mask[i] != p.Bytes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Scan finds every address in the image where the pattern matches
+func (h *DisAsm) Scan(pattern string) ([]int, error) {
+	p, err := ParsePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []int
+	for adr := 0; adr+len(p.Bytes) <= len(h.block); adr++ {
+		if p.Match(h.block[adr:]) {
+			found = append(found, adr)
+		}
+	}
+	return found, nil
+}
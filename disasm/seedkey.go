@@ -0,0 +1,183 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	Seed/key extraction. Security access (mode 27) hands the tester a seed and wants a
+	key back, worked out by a routine somewhere in the code. Given that routine's entry,
+	the slice is everything reachable from it: its own instructions and those of every
+	subroutine it calls, however deep. The slice's image holds just those instructions
+	and the ROM data they refer to, everything else erased. Each address referred to
+	brings the data after it up to the next address anything refers to, the way the
+	listing splits its data runs, so a table indexed by the seed comes along whole, and
+	so do the tables of any TIJMP. The
+	emulator runs it with the seed stored wherever the routine reads it and the key read
+	back from wherever it leaves it, for any seed.
+
+	The slice can only be as good as the crawl. Anything in it that reads an SFR (a
+	timer or port mixed into the key), jumps through a register or calls what wasn't
+	decoded is listed in Unresolved, a key worked out across one of those can't be
+	trusted.
+*/
+
+// SeedKey is the code of a seed/key routine, cut out of the image
+type SeedKey struct {
+	Entry      int
+	Functions  []int // the entry and every subroutine it reaches
+	Code       Instructions
+	Data       []Region // the data runs copied into Image
+	Unresolved []string // what the emulator can't be trusted to reproduce
+	Image      []byte   // the code and data, erased flash everywhere else
+	SeedAt     int      // where the routine reads the seed, a register or data address
+	SeedWidth  int
+	KeyAt      int // where it leaves the key
+	KeyWidth   int
+	set        *InstructionSet
+}
+
+// SeedKey slices the code reachable from the seed/key routine at entry out of the image
+func (h *DisAsm) SeedKey(an *Analysis, entry int) (*SeedKey, error) {
+	if _, ok := an.instruction(entry); !ok {
+		return nil, fmt.Errorf("No instruction at 0x%X", entry)
+	}
+	memory := h.MemoryMap()
+
+	sk := &SeedKey{Entry: entry, SeedWidth: 2, KeyWidth: 2, set: h.InstructionSet()}
+	in := make(map[int]bool) // instruction addresses in the slice
+	todo := []int{entry}
+	seen := map[int]bool{entry: true}
+	for len(todo) > 0 {
+		fn := todo[0]
+		todo = todo[1:]
+		cfg, err := h.CFG(an, fn)
+		if err != nil {
+			sk.Unresolved = append(sk.Unresolved, fmt.Sprintf("0x%X isn't decoded", fn))
+			continue
+		}
+		sk.Functions = append(sk.Functions, fn)
+
+		for _, blk := range cfg.Blocks {
+			for _, instr := range blk.Instrs {
+				if in[instr.Address] {
+					continue
+				}
+				in[instr.Address] = true
+				sk.Code = append(sk.Code, instr)
+				for adr := range instr.Calls {
+					if !seen[adr] {
+						seen[adr] = true
+						todo = append(todo, adr)
+					}
+				}
+			}
+		}
+	}
+	sort.Ints(sk.Functions)
+	sort.Sort(sk.Code)
+
+	for i := range sk.Code {
+		instr := &sk.Code[i]
+		if instr.IsIndirect() && instr.Mnemonic != "TIJMP" {
+			sk.Unresolved = append(sk.Unresolved, fmt.Sprintf("0x%X %s jumps through a register", instr.Address, instr.Mnemonic))
+		}
+		for _, r := range operandRefs(instr) {
+			if memory.Kind(r.To) == KindSFR && r.Access&AccessRead != 0 && r.To&^1 != spReg {
+				sk.Unresolved = append(sk.Unresolved, fmt.Sprintf("0x%X %s reads %s", instr.Address, instr.Mnemonic, regName("", r.To)))
+			}
+		}
+	}
+
+	// The data the slice refers to, and the TIJMP tables
+	wanted := make(map[int]bool)
+	for to, xrefs := range an.XRefs {
+		for _, x := range xrefs {
+			if in[x.XRefFrom] {
+				wanted[memory.Resolve(to)] = true
+			}
+		}
+	}
+	e := NewEmulator(h.block)
+	for _, instr := range sk.Code {
+		if instr.Mnemonic != "TIJMP" {
+			continue
+		}
+		if base, ok := h.tijmpBase(an, instr); ok {
+			wanted[e.Data16(base)] = true
+		}
+	}
+	// Each run goes from the address wanted to the next one anything refers to
+	var referenced []int
+	for to := range an.XRefs {
+		referenced = append(referenced, memory.Resolve(to))
+	}
+	sort.Ints(referenced)
+	for _, r := range h.regions(an) {
+		if r.Class == "code" || r.Kind != KindROM && r.Kind != KindExternal {
+			continue
+		}
+		for adr := r.Start; adr <= r.Stop; adr++ {
+			if !wanted[adr] {
+				continue
+			}
+			run := Region{Start: adr, Stop: r.Stop, Class: r.Class, Kind: r.Kind}
+			if i := sort.SearchInts(referenced, adr+1); i < len(referenced) && referenced[i] <= r.Stop {
+				run.Stop = referenced[i] - 1
+			}
+			sk.Data = append(sk.Data, run)
+			adr = run.Stop
+		}
+	}
+
+	sk.Image = make([]byte, len(h.block))
+	for i := range sk.Image {
+		sk.Image[i] = 0xFF
+	}
+	for _, r := range sk.Data {
+		copy(sk.Image[r.Start:r.Stop+1], h.block[r.Start:r.Stop+1])
+	}
+	for _, instr := range sk.Code {
+		copy(sk.Image[instr.Address:instr.Address+instr.ByteLength], h.block[instr.Address:])
+	}
+	return sk, nil
+}
+
+// Key runs the slice on a seed and returns the key it leaves at KeyAt. 16 bit addresses
+// are data addresses, on the data page past the register file.
+func (sk *SeedKey) Key(seed int) (int, error) {
+	e := NewEmulator(sk.Image)
+	e.Set = sk.set
+	seedAt, keyAt := sk.SeedAt, sk.KeyAt
+	if seedAt <= 0xFFFF {
+		seedAt = e.Data16(seedAt)
+	}
+	if keyAt <= 0xFFFF {
+		keyAt = e.Data16(keyAt)
+	}
+
+	for i := 0; i < sk.SeedWidth; i++ {
+		e.Write8(seedAt+i, seed>>uint(8*i))
+	}
+	if err := e.Call(sk.Entry); err != nil {
+		return 0, err
+	}
+	key := 0
+	for i := 0; i < sk.KeyWidth; i++ {
+		key |= e.Read8(keyAt+i) << uint(8*i)
+	}
+	return key, nil
+}
+
+// Bytes is how much of the image the slice keeps, code and data
+func (sk *SeedKey) Bytes() int {
+	n := 0
+	for _, instr := range sk.Code {
+		n += instr.ByteLength
+	}
+	for _, r := range sk.Data {
+		n += r.Stop - r.Start + 1
+	}
+	return n
+}
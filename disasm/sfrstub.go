@@ -0,0 +1,173 @@
+package disasm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+	SFR stubs. Code that polls hardware, waits on a timer or reads the A/D, can't run
+	against the image alone: an SFR reads back whatever was last written, so a loop
+	waiting on AD_DONE never ends and every sample is the same. MapSFR puts a stub behind
+	an SFR, by the address the code uses for it (AD_RESULT is 0x1E72), and the emulator
+	asks the stub for the value on a read and tells it about a write, however the code
+	reaches the register: directly, through the window or by a 16 bit data address.
+
+	A stub is a whole register, one or two bytes. Its Read is called once per instruction
+	that reads the register, so both bytes of a word read come from one value, and its
+	Write once per instruction that writes it, with the value the instruction left.
+	Counter, Samples and StateTimer cover the usual cases, a timer and an A/D channel.
+*/
+
+// SFRStub stands in for the hardware behind an SFR
+type SFRStub struct {
+	Width int                      // bytes, 1 or 2
+	Read  func(e *Emulator) int    // the value read, nil reads back what was written
+	Write func(e *Emulator, v int) // nil just keeps the value for the next read
+	at    int                      // the register
+	value int                      // what was last read or written
+	step  int                      // the instruction value was read in, -1 for none
+	dirty bool                     // written by the instruction running
+}
+
+// Counter is a register that counts up by step on each read, a free running timer
+func Counter(start, step, width int) *SFRStub {
+	v := start - step
+	return &SFRStub{Width: width, Read: func(e *Emulator) int {
+		v += step
+		return v
+	}}
+}
+
+// Samples is a register that reads each of values in turn, over and over, a scripted
+// A/D channel
+func Samples(width int, values ...int) *SFRStub {
+	i := -1
+	return &SFRStub{Width: width, Read: func(e *Emulator) int {
+		if len(values) == 0 {
+			return 0
+		}
+		i = (i + 1) % len(values)
+		return values[i]
+	}}
+}
+
+// StateTimer is a register that counts a tick every div state times the emulator runs
+func StateTimer(div, width int) *SFRStub {
+	if div < 1 {
+		div = 1
+	}
+	return &SFRStub{Width: width, Read: func(e *Emulator) int {
+		return e.States / div
+	}}
+}
+
+// ParseSFRStub reads a stub from the command line, reg=kind:args with the register
+// by name or address and a width of 2:
+//
+//	TIMER1=count:start:step	counts up by step on each read
+//	AD_RESULT=samples:v:v:...	reads the values in turn
+//	TIMER2=states:div	a tick every div state times
+//	PORT3=value:v	always reads v
+//
+// A b after the register (AD_RESULT:b) makes it a byte.
+func ParseSFRStub(s string) (int, *SFRStub, error) {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return 0, nil, fmt.Errorf("Bad SFR stub: %s", s)
+	}
+	reg, width := strings.TrimSpace(kv[0]), 2
+	if strings.HasSuffix(strings.ToLower(reg), ":b") {
+		reg, width = reg[:len(reg)-2], 1
+	}
+	adr, err := ParseAddress(reg)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	f := strings.Split(strings.TrimSpace(kv[1]), ":")
+	var args []int
+	for _, a := range f[1:] {
+		v, err := strconv.ParseInt(a, 0, 32)
+		if err != nil {
+			return 0, nil, fmt.Errorf("Bad SFR stub value %q in %s", a, s)
+		}
+		args = append(args, int(v))
+	}
+
+	switch {
+	case f[0] == "count" && len(args) == 2:
+		return adr, Counter(args[0], args[1], width), nil
+	case f[0] == "samples" && len(args) > 0:
+		return adr, Samples(width, args...), nil
+	case f[0] == "states" && len(args) == 1:
+		return adr, StateTimer(args[0], width), nil
+	case f[0] == "value" && len(args) == 1:
+		v := args[0]
+		return adr, &SFRStub{Width: width, Read: func(e *Emulator) int { return v }}, nil
+	}
+	return 0, nil, fmt.Errorf("Bad SFR stub: %s, want count:start:step, samples:v:..., states:div or value:v", s)
+}
+
+// MapSFR puts p behind the SFR at adr, the address the code uses for it
+func (e *Emulator) MapSFR(adr int, p *SFRStub) {
+	if p.Width < 1 {
+		p.Width = 1
+	}
+	p.at, p.step = adr, -1
+	if e.stubs == nil {
+		e.stubs = make(map[int]*SFRStub)
+	}
+	for i := 0; i < p.Width; i++ {
+		e.stubs[adr+i] = p
+	}
+}
+
+// The stub behind an image address, and which byte of it the address is
+func (e *Emulator) stub(addr int) (*SFRStub, int, bool) {
+	if len(e.stubs) == 0 {
+		return nil, 0, false
+	}
+	// Through the window, or a 16 bit data address on the data page
+	if addr&^0xFFFF == e.DataPage {
+		addr &= 0xFFFF
+	} else if addr >= 0x10000 {
+		return nil, 0, false
+	}
+	p, ok := e.stubs[addr]
+	if !ok {
+		return nil, 0, false
+	}
+	return p, addr - p.at, true
+}
+
+func (e *Emulator) readStub(p *SFRStub, i int) int {
+	if p.step != e.Steps && !p.dirty && p.Read != nil {
+		p.value = p.Read(e)
+		p.step = e.Steps
+	}
+	return (p.value >> uint(8*i)) & 0xFF
+}
+
+func (e *Emulator) writeStub(p *SFRStub, i, v int) {
+	mask := 0xFF << uint(8*i)
+	p.value = p.value&^mask | (v<<uint(8*i))&mask
+	p.dirty = true
+	if !e.inStep {
+		e.flushStubs()
+	}
+}
+
+// Tells the stubs written by the last instruction
+func (e *Emulator) flushStubs() {
+	for adr, p := range e.stubs {
+		if !p.dirty || adr != p.at {
+			continue
+		}
+		p.dirty = false
+		if p.Write != nil {
+			p.Write(e, p.value)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package disasm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/*
+	RAM snapshots. A lot of the code gets at tables and routines through pointers it keeps
+	in RAM, set up once at reset and read back everywhere else, and the image alone can't
+	say where they point. A snapshot of RAM read from a running ECU (over the ELM link with
+	ramdump) is laid over the image for constant propagation: a word or byte load from
+	memory the snapshot has, directly or through a register already known, gives the
+	register the value the snapshot holds, so the pointer's XRefs resolve and a BR through
+	it is followed to its target. Only the upper register file and internal RAM count, the
+	lower register file is scratch and never the same twice.
+
+	A snapshot is a raw dump of the 16 bit data addresses from its base, file@0x400 for
+	one that starts at 0x0400 and file for one from 0.
+*/
+
+// RAMSnapshot is RAM as read from the ECU, by 16 bit data address
+type RAMSnapshot map[int]byte
+
+// LoadRAMSnapshot reads a raw dump of RAM from base
+func LoadRAMSnapshot(path string, base int) (RAMSnapshot, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if base < 0 || base+len(data) > 0x10000 {
+		return nil, fmt.Errorf("RAM snapshot %s runs from 0x%X to 0x%X, past the 16 bit data addresses", path, base, base+len(data)-1)
+	}
+
+	r := make(RAMSnapshot, len(data))
+	for i, b := range data {
+		r[base+i] = b
+	}
+	return r, nil
+}
+
+// ParseRAMSnapshot loads a snapshot from the command line, file or file@base
+func ParseRAMSnapshot(s string) (RAMSnapshot, error) {
+	path, base := s, 0
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		adr, err := ParseAddress(s[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		path, base = s[:i], adr
+	}
+	return LoadRAMSnapshot(path, base)
+}
+
+// Byte is the byte at adr, false if the snapshot doesn't have it
+func (r RAMSnapshot) Byte(adr int) (int, bool) {
+	b, ok := r[adr]
+	return int(b), ok
+}
+
+// Word is the word at adr, false unless the snapshot has both bytes
+func (r RAMSnapshot) Word(adr int) (int, bool) {
+	lo, okLo := r[adr]
+	hi, okHi := r[adr+1]
+	return int(lo) | int(hi)<<8, okLo && okHi
+}
+
+// SetRAMSnapshot lays a snapshot of RAM over the image for the analysis
+func (h *DisAsm) SetRAMSnapshot(r RAMSnapshot) {
+	h.ram = r
+}
+
+// The part of the snapshot the analysis can trust, RAM above the lower register file
+func (h *DisAsm) ramValues(memory *MemoryMap) RAMSnapshot {
+	r := make(RAMSnapshot)
+	for adr, b := range h.ram {
+		if adr >= 0x100 && memory.Kind(adr) == KindRAM {
+			r[adr] = b
+		}
+	}
+	return r
+}
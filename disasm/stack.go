@@ -0,0 +1,210 @@
+package disasm
+
+import (
+	"strings"
+)
+
+/*
+	Static stack depth. Each function is walked over its control flow graph counting the
+	bytes it pushes, with the sizes the emulator uses: calls and PUSHA push 4 bytes, PUSH
+	and PUSHF push 2, and ADD or SUB of an immediate to SP move it by the immediate. A
+	call adds the callee's own deepest point on top of the return address. Any other write
+	to SP can't be followed and is reported, except loading it with the value the reset code
+	starts it at, which empties the stack. Interrupt routines start 4 bytes down, for the
+	return address the interrupt pushed.
+*/
+
+// StackDepth is the deepest the stack gets from one entry point
+type StackDepth struct {
+	Entry      int
+	Name       string
+	Max        int   // bytes below the stack pointer at entry
+	Chain      []int // functions called down to the deepest point, starting at Entry
+	Recursive  bool
+	Unbalanced []int // blocks reached with different depths, the deepest one is used
+	SPWrites   []int // instructions that set SP some other way, not followed
+	Overflow   bool  // deeper than the stack size
+}
+
+type stackWalker struct {
+	h        *DisAsm
+	an       *Analysis
+	done     map[int]*StackDepth
+	visiting map[int]bool
+	top      int // initial SP, -1 if unknown
+}
+
+// StackDepths works out the deepest stack use from the reset entry and each interrupt
+// routine, flagging the ones deeper than size bytes (0 checks nothing)
+func (h *DisAsm) StackDepths(an *Analysis, size int) []StackDepth {
+	h.GetInterrupts()
+	s := &stackWalker{h: h, an: an, done: make(map[int]*StackDepth), visiting: make(map[int]bool), top: -1}
+	if top, ok := h.InitialSP(an); ok {
+		s.top = top
+	}
+
+	var depths []StackDepth
+	add := func(entry int, name string, pushed int) {
+		d := *s.function(entry)
+		d.Name = name
+		d.Max += pushed
+		d.Overflow = size > 0 && d.Max > size
+		depths = append(depths, d)
+	}
+
+	add(0x172080, "RESET", 0)
+	seen := make(map[int]bool)
+	for _, adr := range h.intRoutineAdrs {
+		if !seen[adr] {
+			seen[adr] = true
+			add(adr, h.intRoutineNames[adr], 4)
+		}
+	}
+	return depths
+}
+
+// InitialSP is the value the reset code loads into SP, false if it doesn't load a constant
+func (h *DisAsm) InitialSP(an *Analysis) (int, bool) {
+	cfg, err := h.CFG(an, 0x172080)
+	if err != nil {
+		return 0, false
+	}
+
+	seen := make(map[int]bool)
+	for adr := 0x172080; !seen[adr]; {
+		seen[adr] = true
+		blk, ok := cfg.Blocks[adr]
+		if !ok {
+			break
+		}
+		for _, instr := range blk.Instrs {
+			if imm, ok := spWrite(instr); ok && instr.Mnemonic == "LD" {
+				return imm, imm >= 0
+			}
+		}
+		if len(blk.Succs) != 1 {
+			break
+		}
+		adr = blk.Succs[0]
+	}
+	return 0, false
+}
+
+func (s *stackWalker) function(entry int) *StackDepth {
+	if d, ok := s.done[entry]; ok {
+		return d
+	}
+	d := &StackDepth{Entry: entry, Chain: []int{entry}}
+	if s.visiting[entry] {
+		d.Recursive = true
+		return d
+	}
+	s.visiting[entry] = true
+	defer delete(s.visiting, entry)
+
+	cfg, err := s.h.CFG(s.an, entry)
+	if err != nil {
+		s.done[entry] = d
+		return d
+	}
+
+	at := map[int]int{entry: 0}
+	todo := []int{entry}
+	for len(todo) > 0 {
+		b := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		blk := cfg.Blocks[b]
+
+		depth := at[b]
+		for _, instr := range blk.Instrs {
+			for callee := range instr.Calls {
+				c := s.function(callee)
+				d.Recursive = d.Recursive || c.Recursive
+				if depth+4+c.Max > d.Max {
+					d.Max = depth + 4 + c.Max
+					d.Chain = append([]int{entry}, c.Chain...)
+				}
+			}
+
+			if imm, ok := spWrite(instr); ok && instr.Mnemonic == "LD" && imm == s.top {
+				depth = 0
+			} else if n, ok := stackEffect(instr); ok {
+				depth += n
+			} else {
+				d.SPWrites = append(d.SPWrites, instr.Address)
+			}
+			if depth > d.Max {
+				d.Max = depth
+				d.Chain = []int{entry}
+			}
+		}
+
+		for _, succ := range blk.Succs {
+			if cfg.Blocks[succ] == nil {
+				continue // not decoded
+			}
+			if old, ok := at[succ]; ok {
+				if old != depth {
+					d.Unbalanced = append(d.Unbalanced, succ)
+					if depth > old {
+						at[succ] = depth
+					}
+				}
+				continue
+			}
+			at[succ] = depth
+			todo = append(todo, succ)
+		}
+	}
+
+	s.done[entry] = d
+	return d
+}
+
+// Bytes an instruction pushes, negative for pops, calls aside. False if it writes SP in a
+// way that can't be followed.
+func stackEffect(instr Instruction) (int, bool) {
+	switch strings.TrimPrefix(instr.Mnemonic, "SGN ") {
+	case "PUSH", "PUSHF":
+		return 2, true
+	case "POP", "POPF":
+		return -2, true
+	case "PUSHA":
+		return 4, true
+	case "POPA":
+		return -4, true
+	}
+
+	imm, ok := spWrite(instr)
+	if !ok {
+		return 0, true
+	}
+	switch instr.Mnemonic {
+	case "ADD":
+		if imm >= 0 {
+			return -imm, true
+		}
+	case "SUB":
+		if imm >= 0 {
+			return imm, true
+		}
+	}
+	return 0, false
+}
+
+// Whether instr writes SP, with its immediate source if it has one (-1 if not)
+func spWrite(instr Instruction) (imm int, writes bool) {
+	imm = -1
+	for i, op := range instr.Ops {
+		switch strings.ToUpper(instr.VarTypes[i]) {
+		case "DEST":
+			r, ok := op.(RegisterOperand)
+			writes = ok && r.Reg == spReg && r.Window == 0
+		case "SRC":
+			if n, ok := immediate(op); ok {
+				imm = n
+			}
+		}
+	}
+	return imm, writes
+}
@@ -0,0 +1,128 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	Statistics. How often each mnemonic, addressing mode and category turns up in an
+	analysis, and which immediate values. Compiled code leans on a few idioms, so a
+	mnemonic seen only a handful of times, or odd ones like SKIP, RST and the PTS opcodes
+	in bulk, point at data the crawl took for code. The immediates show the constants
+	the code is built around, masks and scalings.
+*/
+
+// Count is how many times a mnemonic, addressing mode or category was seen
+type Count struct {
+	Name string
+	N    int
+}
+
+// Sorted most seen first, then by name
+type counts []Count
+
+func (c counts) Len() int {
+	return len(c)
+}
+
+func (c counts) Less(i, j int) bool {
+	if c[i].N != c[j].N {
+		return c[i].N > c[j].N
+	}
+	return c[i].Name < c[j].Name
+}
+
+func (c counts) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// ImmediateCount is how many times an immediate value was used, byte and word immediates
+// are counted apart
+type ImmediateCount struct {
+	Value int
+	Size  int // bytes
+	N     int
+}
+
+func (c ImmediateCount) String() string {
+	return fmt.Sprintf("0x%0*X", c.Size*2, c.Value)
+}
+
+type immediateCounts []ImmediateCount
+
+func (c immediateCounts) Len() int {
+	return len(c)
+}
+
+func (c immediateCounts) Less(i, j int) bool {
+	if c[i].N != c[j].N {
+		return c[i].N > c[j].N
+	}
+	if c[i].Size != c[j].Size {
+		return c[i].Size < c[j].Size
+	}
+	return c[i].Value < c[j].Value
+}
+
+func (c immediateCounts) Swap(i, j int) {
+	c[i], c[j] = c[j], c[i]
+}
+
+// Stats is the make up of the code in an analysis
+type Stats struct {
+	Instructions int
+	Bytes        int
+	Mnemonics    []Count // SGN MUL apart from MUL
+	Modes        []Count
+	Categories   []Count
+	Immediates   []ImmediateCount
+}
+
+// Stats counts the instructions of the analysis, the lists are most seen first
+func (an *Analysis) Stats() Stats {
+	mnemonics := make(map[string]int)
+	modes := make(map[string]int)
+	categories := make(map[string]int)
+	immediates := make(map[[2]int]int)
+
+	st := Stats{Instructions: len(an.Opcodes)}
+	for i := range an.Opcodes {
+		instr := &an.Opcodes[i]
+		st.Bytes += instr.ByteLength
+		mnemonics[instr.Mnemonic]++
+		modes[instr.AddressingMode]++
+		categories[string(instr.Category)]++
+		for _, op := range instr.Ops {
+			if imm, ok := op.(ImmediateOperand); ok {
+				immediates[[2]int{imm.Value, imm.Size}]++
+			}
+		}
+	}
+
+	st.Mnemonics = sortedCounts(mnemonics)
+	st.Modes = sortedCounts(modes)
+	st.Categories = sortedCounts(categories)
+	for k, n := range immediates {
+		st.Immediates = append(st.Immediates, ImmediateCount{Value: k[0], Size: k[1], N: n})
+	}
+	sort.Sort(immediateCounts(st.Immediates))
+	return st
+}
+
+func sortedCounts(m map[string]int) []Count {
+	var c []Count
+	for name, n := range m {
+		c = append(c, Count{Name: name, N: n})
+	}
+	sort.Sort(counts(c))
+	return c
+}
+
+// Percent is n as a percentage of the instructions
+func (st Stats) Percent(n int) float64 {
+	if st.Instructions == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(st.Instructions)
+}
@@ -0,0 +1,101 @@
+package disasm
+
+import "io"
+
+// Stream is a linear disassembly that reads its bytes as it goes, so a big ROM, or memory
+// read off the car, never has to be held in one slice
+type Stream struct {
+	r       io.ReaderAt
+	pos     int // address of buf[0]
+	end     int
+	buf     []byte
+	instr   Instruction
+	err     error
+	Skipped int             // bytes that didn't decode
+	Set     *InstructionSet // decodes with the default set when nil, set before the first Scan
+}
+
+const streamChunk = 4096
+
+// Disassemble decodes length bytes of r starting at address base, r is addressed the same
+// way as the image. Bytes that don't decode are skipped one at a time and counted.
+//
+//	s := disasm.Disassemble(r, 0x172080, 0x100)
+//	for s.Scan() {
+//		instr := s.Instruction()
+//	}
+//	err := s.Err()
+func Disassemble(r io.ReaderAt, base, length int) *Stream {
+	return &Stream{r: r, pos: base, end: base + length}
+}
+
+// Scan decodes the next instruction, false at the end or on a read error
+func (s *Stream) Scan() bool {
+	for s.err == nil {
+		if len(s.buf) < 10 && !s.fill() {
+			return false
+		}
+		if len(s.buf) == 0 {
+			return false
+		}
+
+		set := s.Set
+		if set == nil {
+			set = InstructionSets[DefaultInstructionSet]
+		}
+		instr, err := set.Parse(s.buf, s.pos)
+		if _, ok := err.(ErrTruncated); ok {
+			return false // cut off by the end of the range
+		}
+		if err != nil {
+			s.Skipped++
+			s.advance(1)
+			continue
+		}
+
+		s.instr = instr
+		s.advance(instr.ByteLength)
+		return true
+	}
+	return false
+}
+
+// Instruction is the one Scan just decoded
+func (s *Stream) Instruction() Instruction {
+	return s.instr
+}
+
+// Err is the read error that stopped the stream, if any
+func (s *Stream) Err() error {
+	return s.err
+}
+
+func (s *Stream) advance(n int) {
+	s.buf = s.buf[n:]
+	s.pos += n
+}
+
+// Reads the next chunk after what's buffered, false on a read error
+func (s *Stream) fill() bool {
+	at := s.pos + len(s.buf)
+	n := s.end - at
+	if n <= 0 {
+		return true
+	}
+	if n > streamChunk {
+		n = streamChunk
+	}
+
+	chunk := make([]byte, n)
+	got, err := s.r.ReadAt(chunk, int64(at))
+	if got < n {
+		if err == nil || err == io.EOF {
+			s.end = at + got // the reader ran out before the range did
+		} else {
+			s.err = err
+			return false
+		}
+	}
+	s.buf = append(s.buf[:len(s.buf):len(s.buf)], chunk[:got]...)
+	return true
+}
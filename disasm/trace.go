@@ -0,0 +1,145 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+	Execution traces. Set Emulator.Trace and it's called after every instruction with
+	the instruction, where it ran, the PSW after it and each byte it changed, registers
+	apart from memory, old and new. The emulator is passed too, for anything else in the
+	register file. An ExecutionTrace records a run, and ConfirmCode feeds one back into
+	the disassembler: every instruction the emulator ran is code, so the crawl is
+	started again from the ones it didn't reach, and Coverage counts the code a trace
+	confirmed.
+*/
+
+// Delta is one byte an instruction changed
+type Delta struct {
+	Address int // register, or image address
+	Old     int
+	New     int
+}
+
+func (d Delta) String() string {
+	return fmt.Sprintf("0x%X: %02X -> %02X", d.Address, d.Old, d.New)
+}
+
+// TraceStep is one instruction the emulator ran
+type TraceStep struct {
+	PC    int
+	Instr Instruction
+	Taken bool // a jump that was taken
+	PSW   PSW  // after
+	Regs  []Delta
+	Mem   []Delta
+}
+
+func (s TraceStep) String() string {
+	return fmt.Sprintf("0x%X %s %v %v", s.PC, s.Instr.String(), s.Regs, s.Mem)
+}
+
+// Notes a byte written while tracing, once per instruction with the first old value
+func (e *Emulator) record(adr, old, v int) {
+	if e.Trace == nil {
+		return
+	}
+	for i := range e.deltas {
+		if e.deltas[i].Address == adr {
+			e.deltas[i].New = v & 0xFF
+			return
+		}
+	}
+	e.deltas = append(e.deltas, Delta{Address: adr, Old: old, New: v & 0xFF})
+}
+
+func (e *Emulator) traceStep(pc int, instr Instruction, taken bool) TraceStep {
+	s := TraceStep{PC: pc, Instr: instr, Taken: taken, PSW: e.PSW}
+	for _, d := range e.deltas {
+		if d.Old == d.New {
+			continue
+		}
+		if d.Address < regFileSize {
+			s.Regs = append(s.Regs, d)
+		} else {
+			s.Mem = append(s.Mem, d)
+		}
+	}
+	return s
+}
+
+// ExecutionTrace records the instructions an emulator runs, set its Record as the
+// emulator's Trace
+type ExecutionTrace struct {
+	Steps     []TraceStep // kept when KeepSteps is set, a long run makes a lot of them
+	Executed  map[int]int // instruction addresses run, and their lengths
+	KeepSteps bool
+}
+
+// NewExecutionTrace is an empty trace
+func NewExecutionTrace(keepSteps bool) *ExecutionTrace {
+	return &ExecutionTrace{Executed: make(map[int]int), KeepSteps: keepSteps}
+}
+
+// Record is an Emulator.Trace
+func (t *ExecutionTrace) Record(e *Emulator, s TraceStep) {
+	t.Executed[s.PC] = s.Instr.ByteLength
+	if t.KeepSteps {
+		t.Steps = append(t.Steps, s)
+	}
+}
+
+// Addresses is every instruction address run, in order
+func (t *ExecutionTrace) Addresses() []int {
+	var adrs []int
+	for adr := range t.Executed {
+		adrs = append(adrs, adr)
+	}
+	sort.Ints(adrs)
+	return adrs
+}
+
+// ConfirmCode marks what a trace ran as code. Runs of it the analysis didn't decode are
+// crawled from their first instruction, with the analysis made again if there are any.
+// Returns the analysis and the entry points added.
+func (h *DisAsm) ConfirmCode(an *Analysis, t *ExecutionTrace) (*Analysis, []int, error) {
+	if h.confirmed == nil {
+		h.confirmed = make(map[int]bool)
+	}
+
+	var added []int
+	for _, adr := range t.Addresses() {
+		for i := 0; i < t.Executed[adr]; i++ {
+			h.confirmed[adr+i] = true
+		}
+		if an.Crawled[adr] == 1 {
+			continue
+		}
+		// Only the start of a run, the crawl follows on from there
+		if prev, ok := t.previous(adr); ok && an.Crawled[prev] != 1 {
+			continue
+		}
+		added = append(added, adr)
+	}
+	if len(added) == 0 {
+		return an, nil, nil
+	}
+
+	h.AddEntryPoints(added...)
+	an, err := h.Analyze()
+	if err != nil {
+		return nil, nil, err
+	}
+	return an, added, nil
+}
+
+// The instruction run that ends where adr starts
+func (t *ExecutionTrace) previous(adr int) (int, bool) {
+	for n := 1; n <= 8 && n <= adr; n++ {
+		if t.Executed[adr-n] == n {
+			return adr - n, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,132 @@
+package disasm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+	Address translation. An image is analyzed at the addresses the CPU runs it at, the
+	image file is placed in the block at its base address and the block is indexed by CPU
+	address from then on. The mask is the address bits the CPU drives, a 24 bit jump or
+	call wraps within it and a base in a high bank lands at its masked address. The EEC
+	images are pre-calibration and calibration together, already at the addresses they run
+	at, with a 21 bit mask (0x1FFFFF). A dump captured at file offset 0 that runs from
+	0x2000 has a base of 0x2000.
+
+	The page is the address bits a non-extended jump or call (SJMP, LJMP, the conditional
+	jumps, DJNZ, JBC/JBS, SCALL and LCALL) reaches within. On the 1MB and 16MB parts their
+	targets wrap inside the 64KB page they're in and only EJMP, ECALL and EBR leave it, a
+	page of 0xFFFF. With no page a target carries into the next one, the way the EEC images
+	have always been read. A 64KB part has a 16 bit mask and the page doesn't matter.
+	AddressSpaces has the usual ones by name.
+*/
+
+// AddressTranslation maps offsets in an image file to the CPU addresses it runs at
+type AddressTranslation struct {
+	Base int // CPU address of the first byte of the file
+	Mask int // address bits the CPU drives
+	Page int `json:",omitempty"` // address bits a non-extended jump or call stays within, 0 to carry past
+}
+
+// DefaultAddressTranslation is used until a disassembler is given another
+var DefaultAddressTranslation = AddressTranslation{Base: 0, Mask: 0x1FFFFF}
+
+// AddressSpaces are the built in translations, by part
+var AddressSpaces = map[string]AddressTranslation{
+	"eec": DefaultAddressTranslation,
+	"64k": {Mask: 0xFFFF},
+	"1m":  {Mask: 0xFFFFF, Page: 0xFFFF},
+	"16m": {Mask: 0xFFFFFF, Page: 0xFFFF},
+}
+
+// FindAddressSpace returns a built in translation by name
+func FindAddressSpace(name string) (AddressTranslation, error) {
+	if t, ok := AddressSpaces[strings.ToLower(name)]; ok {
+		return t, nil
+	}
+	return AddressTranslation{}, fmt.Errorf("No address space named %s, want eec, 64k, 1m or 16m", name)
+}
+
+// Address is the CPU address of a file offset
+func (t AddressTranslation) Address(offset int) int {
+	return (t.Base + offset) & t.Mask
+}
+
+// Offset is the file offset of a CPU address, it can be outside the file
+func (t AddressTranslation) Offset(adr int) int {
+	return (adr - t.Base) & t.Mask
+}
+
+// Wrap is an address as the CPU drives it
+func (t AddressTranslation) Wrap(adr int) int {
+	return adr & t.Mask
+}
+
+// Relative is where a non-extended jump or call to target lands, next being the address
+// of the instruction after it
+func (t AddressTranslation) Relative(next, target int) int {
+	if t.Page != 0 {
+		target = next&^t.Page | target&t.Page
+	}
+	return target & t.Mask
+}
+
+// Validate checks that the mask and page are runs of low bits
+func (t AddressTranslation) Validate() error {
+	if t.Mask <= 0 || t.Mask&(t.Mask+1) != 0 {
+		return fmt.Errorf("Address mask 0x%X isn't a run of low bits", t.Mask)
+	}
+	if t.Page < 0 || t.Page&(t.Page+1) != 0 || t.Page > t.Mask {
+		return fmt.Errorf("Page mask 0x%X isn't a run of low bits within the address mask", t.Page)
+	}
+	if t.Base < 0 {
+		return fmt.Errorf("Base address 0x%X is negative", t.Base)
+	}
+	return nil
+}
+
+// SetAddressTranslation moves the image to the addresses it runs at, the bytes before it
+// read as erased flash (0xFF). It takes the image as it was loaded, or as the last
+// translation placed it, so it can be changed before the image is analyzed.
+func (h *DisAsm) SetAddressTranslation(t AddressTranslation) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	if len(h.images) > 0 {
+		return errors.New("Set the address translation before adding images")
+	}
+
+	file := h.block[h.AddressTranslation().Address(0):]
+	start := t.Address(0)
+	if start+len(file) > t.Mask+1 {
+		return errors.New("Image runs past the top of the address space")
+	}
+
+	block := make([]byte, start+len(file))
+	for i := 0; i < start; i++ {
+		block[i] = 0xFF
+	}
+	copy(block[start:], file)
+
+	h.block = block
+	h.translation = &t
+	return nil
+}
+
+// AddressTranslation is the translation in use, the default one unless another was set
+func (h *DisAsm) AddressTranslation() AddressTranslation {
+	if h.translation == nil {
+		return DefaultAddressTranslation
+	}
+	return *h.translation
+}
+
+// Decodes the instruction at adr in the image, with the set, translation and decode options
+// in use
+func (h *DisAsm) parse(adr int) (Instruction, error) {
+	instr, err := h.InstructionSet().parse(h.block[adr:min(adr+10, len(h.block))], adr, h.AddressTranslation())
+	instr.filterXRefs(h.DecodeOptions())
+	return instr, err
+}
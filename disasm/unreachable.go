@@ -0,0 +1,100 @@
+package disasm
+
+import "strings"
+
+// CodeRange is a run of bytes the crawl never reached that still decodes cleanly, dead code,
+// a routine only reached through a pointer, or data that happens to look like code
+type CodeRange struct {
+	Start  int
+	End    int // one past the last byte
+	Instrs int
+	Exit   string // the RET or jump it ends on, "" if it runs into crawled code
+}
+
+// Unreachable sweeps the executable memory the crawl didn't reach and reports runs of at least minInstrs
+// instructions that look like code: every call goes to a known subroutine, every jump stays in
+// executable memory, and the run ends on a RET, on a jump into code that was reached, or by
+// running into reached code
+func (h *DisAsm) Unreachable(an *Analysis, minInstrs int) []CodeRange {
+	memory := h.MemoryMap()
+
+	var found []CodeRange
+	for _, r := range h.regions(an) {
+		if r.Class != "data" || !memory.Executable(r.Start) {
+			continue
+		}
+
+		run := CodeRange{Start: r.Start}
+		for adr := r.Start; adr <= r.Stop; {
+			instr, err := h.parse(adr)
+			if err != nil || adr+instr.ByteLength > r.Stop+1 || !plausible(an, memory, instr) {
+				adr++
+				run = CodeRange{Start: adr}
+				continue
+			}
+			run.Instrs++
+			adr += instr.ByteLength
+			run.End = adr
+
+			exit, ok := exitsTo(an, instr)
+			if !ok {
+				run = CodeRange{Start: adr} // leaves for somewhere that isn't code
+				continue
+			}
+			if exit || adr > r.Stop && an.Crawled[adr] == 1 {
+				if exit {
+					run.Exit = instr.Mnemonic
+				}
+				if run.Instrs >= minInstrs {
+					found = append(found, run)
+				}
+				run = CodeRange{Start: adr}
+			}
+		}
+	}
+	return found
+}
+
+// Whether an instruction could be code, as far as where it calls and jumps
+func plausible(an *Analysis, memory *MemoryMap, instr Instruction) bool {
+	if strings.HasSuffix(instr.Mnemonic, "Reserved") {
+		return false
+	}
+	for adr := range instr.Calls {
+		if _, ok := an.Subroutines[adr]; !ok {
+			return false
+		}
+	}
+	for adr := range instr.Jumps {
+		if !memory.Executable(adr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Whether instr ends a run, and false if it ends it in a way code wouldn't: an unconditional
+// jump to somewhere that wasn't reached, or a reset
+func exitsTo(an *Analysis, instr Instruction) (exit bool, ok bool) {
+	switch strings.TrimPrefix(instr.Mnemonic, "SGN ") {
+	case "RET":
+		return true, true
+	case "SJMP", "LJMP", "EJMP":
+		for adr := range instr.Jumps {
+			if an.Crawled[adr] != 1 {
+				return false, false
+			}
+		}
+		return true, true
+	case "BR", "EBR", "TIJMP", "RST":
+		return false, false
+	}
+	return false, true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,393 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Variables. Inside a subroutine the lower register file is scratch, the same R_3C is a
+	loop counter in one place and a pointer a few lines on. With variable names on, the
+	decompiler ties each read of a register to the writes that can reach it (reaching
+	definitions over the subroutine's graph), and the writes that reach a common read are
+	one variable, as is a write with the read of the same instruction (ADD R_3C, #1). Each
+	variable gets a name, var1, var2... in the order of their first write, and every
+	statement that touches it prints the name instead of the register.
+
+	Only variables the subroutine makes itself are renamed. A register read before it's
+	written comes from the caller and one read after a call may be what the call left in
+	it, those keep the register name, as does a register whose bytes are also used at
+	another width (a long and the words in it). With the aliases merged in, a register
+	that has an alias shows as the alias everywhere instead of as variables.
+*/
+
+// SetVariableNames turns naming the temporaries of each subroutine on in the decompiler,
+// merging with the register aliases or not
+func (h *DisAsm) SetVariableNames(on, mergeAliases bool) {
+	h.variables = on
+	h.mergeAliases = mergeAliases
+}
+
+// A write a variable starts with, at an instruction or before the subroutine or after a call
+type regDef struct {
+	reg     int
+	address int
+	outside bool // the caller's value or one a call left
+}
+
+// Sets of the defs that reach a point, by register
+type reachingDefs map[int]map[int]bool
+
+func (r reachingDefs) copy() reachingDefs {
+	c := make(reachingDefs, len(r))
+	for reg, defs := range r {
+		c[reg] = make(map[int]bool, len(defs))
+		for id := range defs {
+			c[reg][id] = true
+		}
+	}
+	return c
+}
+
+// Adds o, true if that added anything
+func (r reachingDefs) merge(o reachingDefs) bool {
+	changed := false
+	for reg, defs := range o {
+		if r[reg] == nil {
+			r[reg] = make(map[int]bool)
+		}
+		for id := range defs {
+			if !r[reg][id] {
+				r[reg][id] = true
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// The registers of the lower register file the subroutine only ever accesses one way,
+// a word as a word and never its bytes
+func variableRegisters(cfg *CFG) map[int]bool {
+	widths := make(map[int]int)
+	bad := make(map[int]bool)
+	for _, blk := range cfg.Blocks {
+		for i := range blk.Instrs {
+			for _, r := range operandRefs(&blk.Instrs[i]) {
+				if r.To < 0x1A || r.To >= 0x100 {
+					continue
+				}
+				if w, ok := widths[r.To]; ok && w != r.Width {
+					bad[r.To] = true
+				}
+				widths[r.To] = r.Width
+			}
+		}
+	}
+	for a, wa := range widths {
+		for b, wb := range widths {
+			if a != b && a < b+wb && b < a+wa {
+				bad[a], bad[b] = true, true
+			}
+		}
+	}
+
+	regs := make(map[int]bool)
+	for reg := range widths {
+		if !bad[reg] {
+			regs[reg] = true
+		}
+	}
+	return regs
+}
+
+// Reaching definitions of a subroutine's registers, the defs that can reach each read and
+// which of them are one variable
+type defUse struct {
+	defs    []regDef
+	parent  []int                   // union-find over defs, the variables
+	entry   map[int]int             // the caller's value, by register
+	written map[[2]int]int          // writes by register and instruction
+	called  map[[2]int]int          // what calls leave, by register and call
+	uses    map[[2]int]map[int]bool // reads by register and instruction
+}
+
+func (du *defUse) def(at map[[2]int]int, reg, address int, outside bool) int {
+	key := [2]int{reg, address}
+	if id, ok := at[key]; ok {
+		return id
+	}
+	at[key] = du.newDef(regDef{reg: reg, address: address, outside: outside})
+	return at[key]
+}
+
+func (du *defUse) newDef(d regDef) int {
+	du.defs = append(du.defs, d)
+	du.parent = append(du.parent, len(du.defs)-1)
+	return len(du.defs) - 1
+}
+
+func (du *defUse) find(id int) int {
+	if du.parent[id] != id {
+		du.parent[id] = du.find(du.parent[id])
+	}
+	return du.parent[id]
+}
+
+func (du *defUse) union(a, b int) {
+	du.parent[du.find(a)] = du.find(b)
+}
+
+func (du *defUse) read(reg, address int, state reachingDefs) {
+	key := [2]int{reg, address}
+	if du.uses[key] == nil {
+		du.uses[key] = make(map[int]bool)
+	}
+	for id := range state[reg] {
+		du.uses[key][id] = true
+	}
+}
+
+// Works out the reaching definitions of regs over the graph. A call reads the registers
+// args says the subroutine called takes and leaves every register with a value from
+// outside; args can be nil for calls that read nothing.
+func reachingDefinitions(cfg *CFG, regs map[int]bool, args func(call Instruction) map[int]bool) *defUse {
+	du := &defUse{entry: make(map[int]int), written: make(map[[2]int]int), called: make(map[[2]int]int), uses: make(map[[2]int]map[int]bool)}
+	entry := make(reachingDefs)
+	for reg := range regs {
+		du.entry[reg] = du.newDef(regDef{reg: reg, outside: true})
+		entry[reg] = map[int]bool{du.entry[reg]: true}
+	}
+
+	run := func(blk *BasicBlock, in reachingDefs) reachingDefs {
+		state := in.copy()
+		for i := range blk.Instrs {
+			instr := &blk.Instrs[i]
+			refs := operandRefs(instr)
+			for _, r := range refs {
+				if regs[r.To] && r.Access&AccessRead != 0 {
+					du.read(r.To, instr.Address, state)
+				}
+			}
+			for _, r := range refs {
+				if regs[r.To] && r.Access&AccessWrite != 0 {
+					state[r.To] = map[int]bool{du.def(du.written, r.To, instr.Address, false): true}
+				}
+			}
+			if !instr.Is(CategoryCall) {
+				continue
+			}
+			if args != nil {
+				for reg := range args(*instr) {
+					if regs[reg] {
+						du.read(reg, instr.Address, state)
+					}
+				}
+			}
+			for reg := range regs {
+				state[reg] = map[int]bool{du.def(du.called, reg, instr.Address, true): true}
+			}
+		}
+		return state
+	}
+
+	var order []int
+	for b := range cfg.Blocks {
+		order = append(order, b)
+	}
+	sort.Ints(order)
+	in := make(map[int]reachingDefs)
+	out := make(map[int]reachingDefs)
+	for _, b := range order {
+		in[b] = make(reachingDefs)
+	}
+	in[cfg.Entry].merge(entry)
+	for changed := true; changed; {
+		changed = false
+		for _, b := range order {
+			for _, p := range cfg.Blocks[b].Preds {
+				if out[p] != nil && in[b].merge(out[p]) {
+					changed = true
+				}
+			}
+			if out[b] == nil {
+				out[b] = make(reachingDefs)
+				changed = true
+			}
+			if out[b].merge(run(cfg.Blocks[b], in[b])) {
+				changed = true
+			}
+		}
+	}
+
+	// Defs that reach the same read are one variable, and a write is the same one as the
+	// read of its register in the same instruction. What a call reads is passed outside,
+	// the same as what it leaves.
+	for key, ids := range du.uses {
+		one := -1
+		for id := range ids {
+			if one < 0 {
+				one = id
+			} else {
+				du.union(one, id)
+			}
+		}
+		if one < 0 {
+			continue
+		}
+		if id, ok := du.written[key]; ok {
+			du.union(one, id)
+		}
+		if id, ok := du.called[key]; ok {
+			du.union(one, id)
+		}
+	}
+	return du
+}
+
+// The registers of the lower register file a subroutine reads before writing them, its
+// arguments
+func (h *DisAsm) subroutineInputs(an *Analysis, entry int) map[int]bool {
+	cfg, err := h.CFG(an, entry)
+	if err != nil {
+		return nil
+	}
+	regs := make(map[int]bool)
+	for _, blk := range cfg.Blocks {
+		for i := range blk.Instrs {
+			for _, r := range operandRefs(&blk.Instrs[i]) {
+				if r.To >= 0x1A && r.To < 0x100 {
+					regs[r.To] = true
+				}
+			}
+		}
+	}
+
+	du := reachingDefinitions(cfg, regs, nil)
+	inputs := make(map[int]bool)
+	for key, ids := range du.uses {
+		if ids[du.entry[key[0]]] {
+			inputs[key[0]] = true
+		}
+	}
+
+	// An extended pointer has its page in the word above
+	for _, blk := range cfg.Blocks {
+		for _, instr := range blk.Instrs {
+			if !strings.HasPrefix(instr.Mnemonic, "E") {
+				continue
+			}
+			for _, op := range instr.Ops {
+				var reg int
+				switch op := op.(type) {
+				case IndirectOperand:
+					reg = regAddress(op.Reg, op.Window)
+				case IndexedOperand:
+					reg = regAddress(op.Reg, op.Window)
+				}
+				if reg != 0 && inputs[reg] {
+					inputs[reg+2] = true
+				}
+			}
+		}
+	}
+	return inputs
+}
+
+// What a call reads, the inputs of what it calls, every register if it isn't known
+func (d *decompiler) callArgs(call Instruction) map[int]bool {
+	if len(call.Calls) == 0 {
+		all := make(map[int]bool)
+		for reg := 0x1A; reg < 0x100; reg++ {
+			all[reg] = true
+		}
+		return all
+	}
+	args := make(map[int]bool)
+	for adr := range call.Calls {
+		inputs, ok := d.inputs[adr]
+		if !ok {
+			inputs = d.h.subroutineInputs(d.an, adr)
+			d.inputs[adr] = inputs
+		}
+		for reg := range inputs {
+			args[reg] = true
+			args[reg-1] = true // the word or long starting below it
+		}
+	}
+	return args
+}
+
+// Names the variables of the subroutine, by instruction address and then register
+func (d *decompiler) variableNames() map[int]map[int]string {
+	du := reachingDefinitions(d.cfg, variableRegisters(d.cfg), d.callArgs)
+
+	// A variable with a value from outside keeps the register name, the others are
+	// numbered in the order of their first write
+	outside := make(map[int]bool)
+	firstWrite := make(map[int]int) // by variable, as address << 8 | register
+	for id, def := range du.defs {
+		root := du.find(id)
+		if def.outside {
+			outside[root] = true
+			continue
+		}
+		if k, ok := firstWrite[root]; !ok || def.address<<8|def.reg < k {
+			firstWrite[root] = def.address<<8 | def.reg
+		}
+	}
+	vars := make(map[int]int)
+	var keys []int
+	for root, k := range firstWrite {
+		if !outside[root] && !(d.h.mergeAliases && d.h.aliases[k&0xFF] != "") {
+			vars[k] = root
+			keys = append(keys, k)
+		}
+	}
+	sort.Ints(keys)
+	names := make(map[int]string)
+	for i, k := range keys {
+		names[vars[k]] = fmt.Sprintf("var%d", i+1)
+	}
+
+	// Every instruction's reads and writes by the name of their variable
+	out := make(map[int]map[int]string)
+	name := func(key [2]int, id int) {
+		if n, ok := names[du.find(id)]; ok {
+			if out[key[1]] == nil {
+				out[key[1]] = make(map[int]string)
+			}
+			out[key[1]][key[0]] = n
+		}
+	}
+	for key, ids := range du.uses {
+		for id := range ids {
+			name(key, id)
+		}
+	}
+	for key, id := range du.written {
+		name(key, id)
+	}
+	return out
+}
+
+// Writes the registers in text that are variables at the instructions as their names
+func renameVariables(vars map[int]map[int]string, text string, instrs ...Instruction) string {
+	if len(vars) == 0 {
+		return text
+	}
+	return registerText.ReplaceAllStringFunc(text, func(reg string) string {
+		adr, err := strconv.ParseInt(reg[2:], 16, 32)
+		if err != nil {
+			return reg
+		}
+		for _, instr := range instrs {
+			if n, ok := vars[instr.Address][int(adr)]; ok {
+				return n
+			}
+		}
+		return reg
+	})
+}
@@ -0,0 +1,276 @@
+package disasm
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+	Cross-reference index. Every operand of every instruction is resolved to the address it
+	touches and whether it is read, written or both, and indexed by each byte it covers, so
+	"who writes R_40?" also finds the word writes to R_40 and the long writes to R_3C.
+	Windowed registers are indexed at the SFR they reach, and the base registers of
+	indirect and indexed operands are indexed as reads (or writes, for auto-increment).
+*/
+
+// Access is how an instruction uses an address
+type Access int
+
+const (
+	AccessRead Access = 1 << iota
+	AccessWrite
+	AccessJump
+	AccessCall
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessRead:
+		return "r"
+	case AccessWrite:
+		return "w"
+	case AccessRead | AccessWrite:
+		return "rw"
+	case AccessJump:
+		return "jump"
+	case AccessCall:
+		return "call"
+	}
+	return "?"
+}
+
+// Ref is one use of an address
+type Ref struct {
+	From     int // instruction address
+	To       int // first byte
+	Width    int
+	Access   Access
+	Mnemonic string
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("0x%X %-6s %-4s 0x%X/%d", r.From, r.Mnemonic, r.Access, r.To, r.Width)
+}
+
+type refs []Ref
+
+func (r refs) Len() int {
+	return len(r)
+}
+
+func (r refs) Less(i, j int) bool {
+	if r[i].From != r[j].From {
+		return r[i].From < r[j].From
+	}
+	return r[i].To < r[j].To
+}
+
+func (r refs) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
+
+// XRefIndex answers reference queries over a whole analysis without rescanning it
+type XRefIndex struct {
+	to   map[int][]Ref // by every byte touched
+	from map[int][]Ref
+}
+
+// NewXRefIndex indexes every operand, jump and call in an analysis
+func NewXRefIndex(an *Analysis) *XRefIndex {
+	x := &XRefIndex{to: make(map[int][]Ref), from: make(map[int][]Ref)}
+
+	for i := range an.Opcodes {
+		for _, r := range operandRefs(&an.Opcodes[i]) {
+			x.add(r)
+		}
+	}
+	for to, calls := range an.Subroutines {
+		for _, c := range calls {
+			x.add(Ref{From: c.CallFrom, To: to, Width: 1, Access: AccessCall, Mnemonic: c.Mnemonic})
+		}
+	}
+	for to, jumps := range an.Jumps {
+		for _, j := range jumps {
+			x.add(Ref{From: j.JumpFrom, To: to, Width: 1, Access: AccessJump, Mnemonic: j.Mnemonic})
+		}
+	}
+
+	for _, r := range x.to {
+		sort.Sort(refs(r))
+	}
+	for _, r := range x.from {
+		sort.Sort(refs(r))
+	}
+	return x
+}
+
+func (x *XRefIndex) add(r Ref) {
+	for adr := r.To; adr < r.To+r.Width; adr++ {
+		x.to[adr] = append(x.to[adr], r)
+	}
+	x.from[r.From] = append(x.from[r.From], r)
+}
+
+// ReferencesTo lists everything that touches adr, including wider accesses that cover it
+func (x *XRefIndex) ReferencesTo(adr int) []Ref {
+	return x.to[adr]
+}
+
+// ReferencesFrom lists everything the instruction at adr touches
+func (x *XRefIndex) ReferencesFrom(adr int) []Ref {
+	return x.from[adr]
+}
+
+// WritersOf lists the instructions that write adr
+func (x *XRefIndex) WritersOf(adr int) []Ref {
+	return x.filter(adr, AccessWrite)
+}
+
+// ReadersOf lists the instructions that read adr
+func (x *XRefIndex) ReadersOf(adr int) []Ref {
+	return x.filter(adr, AccessRead)
+}
+
+func (x *XRefIndex) filter(adr int, a Access) []Ref {
+	var out []Ref
+	for _, r := range x.to[adr] {
+		if r.Access&a != 0 {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Everything one instruction's operands touch
+func operandRefs(instr *Instruction) []Ref {
+	m := strings.TrimPrefix(instr.Mnemonic, "SGN ")
+	var out []Ref
+
+	ref := func(adr, w int, a Access) {
+		if adr < 2 {
+			return // ZERO_REG
+		}
+		out = append(out, Ref{From: instr.Address, To: adr, Width: w, Access: a, Mnemonic: instr.Mnemonic})
+	}
+	pointer := func(reg int) {
+		if instr.AutoIncrement {
+			ref(reg, 2, AccessRead|AccessWrite)
+		} else {
+			ref(reg, 2, AccessRead)
+		}
+	}
+
+	threeOp := instr.threeOperand()
+
+	for i, op := range instr.Ops {
+		typ := strings.ToUpper(instr.VarTypes[i])
+		if typ == "ADDR" || typ == "BITNO" {
+			continue
+		}
+		w, a := operandWidth(m, typ), operandAccess(m, typ, threeOp)
+
+		switch op := op.(type) {
+		case RegisterOperand:
+			ref(regAddress(op.Reg, op.Window), w, a)
+		case IndirectOperand:
+			pointer(regAddress(op.Reg, op.Window))
+		case IndexedOperand:
+			if op.Reg == 0 {
+				ref(op.Offset, w, a)
+			} else {
+				pointer(regAddress(op.Reg, op.Window))
+			}
+		}
+	}
+	return out
+}
+
+// Whether the instruction has two sources and a destination
+func (instr *Instruction) threeOperand() bool {
+	for i := range instr.Ops {
+		if strings.ToUpper(instr.VarTypes[i]) == "SRC1" {
+			return true
+		}
+	}
+	return false
+}
+
+// How an operand of type typ is used, the destination of a three operand instruction is
+// only written
+func operandAccess(m, typ string, threeOp bool) Access {
+	switch typ {
+	case "DEST":
+		switch m {
+		case "CMP", "CMPB", "CMPL":
+			return AccessRead
+		}
+		if threeOp {
+			return AccessWrite
+		}
+		switch m {
+		case "LD", "LDB", "LDBZE", "LDBSE", "ELD", "ELDB", "ST", "STB", "EST", "ESTB", "CLR", "CLRB", "POP":
+			return AccessWrite
+		}
+		return AccessRead | AccessWrite
+	case "SRC":
+		switch m {
+		case "XCH", "XCHB", "NORML":
+			return AccessRead | AccessWrite
+		}
+	case "BREG", "WREG", "PTRS":
+		return AccessRead | AccessWrite // DJNZ counts, BMOV moves its pointers on
+	}
+	return AccessRead
+}
+
+// Bytes an operand of type typ covers
+func operandWidth(m, typ string) int {
+	switch typ {
+	case "BYTEREG", "BREG", "COUNT":
+		return 1
+	case "WREG", "CNTREG", "TBASE", "INDEX":
+		return 2
+	case "PTRS":
+		return 4
+	}
+
+	if typ == "DEST" {
+		switch m {
+		case "MUL", "MULU", "DIV", "DIVU", "SHLL", "SHRL", "SHRAL", "CMPL", "EXT":
+			return 4
+		case "MULB", "MULUB", "DIVB", "DIVUB", "LDBZE", "LDBSE", "EXTB":
+			return 2
+		case "NORML":
+			return 1
+		}
+	}
+	if m == "NORML" || m == "CMPL" {
+		return 4
+	}
+	return width(m)
+}
+
+// ParseAddress reads an address as R_40, 0x0CFE or a register name like AD_RESULT
+func ParseAddress(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	u := strings.ToUpper(s)
+	if strings.HasPrefix(u, "R_") {
+		v, err := strconv.ParseInt(u[2:], 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("Bad register: %s", s)
+		}
+		return int(v), nil
+	}
+	for adr, r := range RegObjs {
+		if strings.TrimSpace(r.Mnemonic) == u {
+			return adr, nil
+		}
+	}
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Bad address: %s", s)
+	}
+	return int(v), nil
+}
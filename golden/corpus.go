@@ -0,0 +1,88 @@
+package golden
+
+import "github.com/murdinc/ELMFlash/disasm"
+
+// Where the opcode sweeps start, in the flash the code runs from
+const sweepBase = 0x100000
+
+// Operand bytes after the opcode, the first one even and then odd
+var sweepOperands = [][]byte{
+	{0x30, 0x32, 0x34, 0x36, 0x38, 0x3A, 0x3C, 0x3E, 0x40},
+	{0x31, 0x32, 0x34, 0x36, 0x38, 0x3A, 0x3C, 0x3E, 0x40},
+}
+
+// Every opcode byte of a set, unsigned and then behind the 0xFE prefix for the ones the
+// EA has a signed form of, so a set that gains or loses one changes the same lines
+func sweep(name string) Case {
+	c := Case{Name: "opcodes-" + name, Set: name, Base: sweepBase, Stride: 16}
+	entry := func(prefix []byte, b byte, operands []byte) {
+		e := make([]byte, c.Stride)
+		copy(e, append(append(append([]byte{}, prefix...), b), operands...))
+		c.Bin = append(c.Bin, e...)
+	}
+
+	for b := 0; b < 0x100; b++ {
+		for _, ops := range sweepOperands {
+			entry(nil, byte(b), ops)
+		}
+	}
+	ea := disasm.InstructionSets[disasm.DefaultInstructionSet]
+	for b := 0; b < 0x100; b++ {
+		if _, ok := ea.Lookup(byte(b), true); !ok {
+			continue
+		}
+		for _, ops := range sweepOperands {
+			entry([]byte{0xFE}, byte(b), ops)
+		}
+	}
+	return c
+}
+
+// Hand assembled routines, decoded straight through
+var routines = []Case{
+	{
+		// Every addressing mode, three operand arithmetic, the jumps and calls of each
+		// length, an indirect branch and a return:
+		//
+		//	start:	LD R_30, #0x0D24
+		//		LDB R_1C, 0x02[R_30]
+		//		LD R_32, 0x1234[R_30]
+		//		LD R_34, [R_30]
+		//		LD R_36, [R_30]+
+		//		ADD R_40, R_42, R_44
+		//		ADDB R_48, #0x12
+		//		SUB R_40, 0x0C22[R_00]
+		//		MULU R_48, R_40, R_42
+		//		DIVU R_48, R_44
+		//		SHRL R_48, #0x04
+		//		CMP R_40, R_42
+		//		JNH skip
+		//		ST R_40, 0x0C22[R_00]
+		//	skip:	STB R_1C, [R_32]
+		//		ELD R_38, 0x123456[R_3C]
+		//		EST R_38, [R_3C]
+		//		DJNZ R_1C, skip
+		//		JBS R_1C, 3, start
+		//		SCALL start
+		//		LCALL start
+		//		ECALL start
+		//		PUSH R_40
+		//		POP R_40
+		//		BR [R_30]
+		//		SJMP start
+		//		LJMP start
+		//		EJMP start
+		//		RET
+		Name: "modes",
+		Set:  "196ea",
+		Base: 0x172080,
+		Bin: []byte{
+			0xA1, 0x24, 0x0D, 0x30, 0xB3, 0x30, 0x02, 0x1C, 0xA3, 0x31, 0x34, 0x12, 0x32, 0xA2, 0x30, 0x34,
+			0xA2, 0x31, 0x36, 0x44, 0x44, 0x42, 0x40, 0x75, 0x12, 0x48, 0x6B, 0x01, 0x22, 0x0C, 0x40, 0x4C,
+			0x42, 0x40, 0x48, 0x8C, 0x44, 0x48, 0x0C, 0x04, 0x48, 0x88, 0x42, 0x40, 0xD1, 0x05, 0xC3, 0x01,
+			0x22, 0x0C, 0x40, 0xC6, 0x32, 0x1C, 0xE9, 0x3C, 0x56, 0x34, 0x12, 0x38, 0x1C, 0x3C, 0x38, 0xE0,
+			0x1C, 0xF1, 0x3B, 0x1C, 0xBB, 0x2F, 0xB9, 0xEF, 0xB6, 0xFF, 0xF1, 0xB2, 0xFF, 0xFF, 0xC8, 0x40,
+			0xCC, 0x40, 0xE3, 0x30, 0x27, 0xAA, 0xE7, 0xA7, 0xFF, 0xE6, 0xA3, 0xFF, 0xFF, 0xF0,
+		},
+	},
+}
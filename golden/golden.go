@@ -0,0 +1,209 @@
+package golden
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+/*
+	Golden listings. The corpus is a set of reference binaries built into the package,
+	every opcode of every instruction set (each with an even and an odd first operand
+	byte, so both forms of the indexed and indirect modes) and a few hand assembled
+	routines. Each is disassembled to a plain listing, one line per instruction with its
+	address, bytes, assembler text and pseudo code, and compared with the listing kept
+	for it in a directory, golden/listings in this repo. Adding an opcode, changing how
+	operands print or how pseudo code reads shows up as lines that changed; Update writes
+	the listings again once the changes are the ones meant. The tables of the built in
+	instruction sets are validated first, a broken entry fails the check on its own.
+
+	From a test:
+
+		func TestListings(t *testing.T) {
+			golden.Verify(t, "../golden/listings")
+		}
+
+	or from the command line with golden, golden --update to write them.
+*/
+
+// Case is one reference binary
+type Case struct {
+	Name   string
+	Set    string // instruction set, by the name FindInstructionSet takes
+	Base   int    // address of the first byte
+	Bin    []byte
+	Stride int // bytes per entry, each decoded on its own, 0 decodes straight through
+}
+
+// Mismatch is a line of a listing that isn't what the golden listing has
+type Mismatch struct {
+	Case string
+	Line int // 1 based
+	Want string
+	Got  string
+}
+
+func (m Mismatch) String() string {
+	return fmt.Sprintf("%s:%d\n  want: %s\n  got:  %s", m.Case, m.Line, m.Want, m.Got)
+}
+
+// T is the part of *testing.T Verify reports through
+type T interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Most bytes one instruction takes, with the prefix
+const maxInstruction = 10
+
+// Listing disassembles the case
+func (c Case) Listing() (string, error) {
+	set, err := disasm.FindInstructionSet(c.Set)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "; %s, %s from 0x%X\n", c.Name, set.Name, c.Base)
+
+	padded := append(append([]byte{}, c.Bin...), make([]byte, maxInstruction)...)
+	if c.Stride > 0 {
+		for off := 0; off < len(c.Bin); off += c.Stride {
+			line(&buf, set, padded[off:off+c.Stride], c.Base+off)
+		}
+		return buf.String(), nil
+	}
+	for off := 0; off < len(c.Bin); {
+		off += line(&buf, set, padded[off:], c.Base+off)
+	}
+	return buf.String(), nil
+}
+
+// Writes the line for the instruction at the start of in, returns its length
+func line(buf *bytes.Buffer, set *disasm.InstructionSet, in []byte, address int) int {
+	instr, err := set.Parse(in, address)
+	if err != nil {
+		fmt.Fprintf(buf, "0x%06X  %-20X  ERROR %s\n", address, in[:1], err)
+		return 1
+	}
+	l := fmt.Sprintf("0x%06X  %-20X  %-32s", address, in[:instr.ByteLength], instr.String())
+	if instr.PseudoCode != "" {
+		l += "  ; " + instr.PseudoCode
+	}
+	buf.WriteString(strings.TrimRight(l, " ") + "\n")
+	return instr.ByteLength
+}
+
+// The listing for a case in dir
+func path(dir string, c Case) string {
+	return filepath.Join(dir, c.Name+".lst")
+}
+
+// Check validates the built in instruction sets and compares every case in the corpus
+// with its listing in dir
+func Check(dir string) ([]Mismatch, error) {
+	for _, name := range setNames() {
+		if err := disasm.InstructionSets[name].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var out []Mismatch
+	for _, c := range Corpus() {
+		got, err := c.Listing()
+		if err != nil {
+			return nil, err
+		}
+		want, err := ioutil.ReadFile(path(dir, c))
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("No golden listing for %s in %s, write them with Update", c.Name, dir)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, compare(c.Name, string(want), got)...)
+	}
+	return out, nil
+}
+
+// Line by line, a line one has and the other doesn't is compared with nothing
+func compare(name, want, got string) []Mismatch {
+	w, g := lines(want), lines(got)
+	var out []Mismatch
+	for i := 0; i < len(w) || i < len(g); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			out = append(out, Mismatch{Case: name, Line: i + 1, Want: wl, Got: gl})
+		}
+	}
+	return out
+}
+
+func lines(s string) []string {
+	var out []string
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		out = append(out, sc.Text())
+	}
+	return out
+}
+
+// Update writes the listing of every case to dir
+func Update(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, c := range Corpus() {
+		l, err := c.Listing()
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path(dir, c), []byte(l), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify fails t for every line of the corpus that doesn't match its listing in dir
+func Verify(t T, dir string) {
+	mismatches, err := Check(dir)
+	if err != nil {
+		t.Errorf("Golden listings: %s", err)
+		return
+	}
+	for _, m := range mismatches {
+		t.Errorf("%s", m)
+	}
+}
+
+// Corpus is every case, the opcode sweeps by instruction set and then the routines
+func Corpus() []Case {
+	var cases []Case
+	for _, name := range setNames() {
+		cases = append(cases, sweep(name))
+	}
+	return append(cases, routines...)
+}
+
+// The built in instruction sets by name, in order
+func setNames() []string {
+	var names []string
+	for name := range disasm.InstructionSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,69 @@
+package golden
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/murdinc/ELMFlash/asm"
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+func TestListings(t *testing.T) {
+	Verify(t, "listings")
+}
+
+// Every instruction of the 196EA cases reads back through the assembler to its own bytes.
+// An EJMP or ECALL offset with bits the address space drops reaches the same target from
+// a different offset, so only the target is compared for those.
+func TestReassemble(t *testing.T) {
+	for _, c := range Corpus() {
+		if c.Set != disasm.DefaultInstructionSet {
+			continue
+		}
+		set, err := disasm.FindInstructionSet(c.Set)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		padded := append(append([]byte{}, c.Bin...), make([]byte, maxInstruction)...)
+		for off := 0; off < len(c.Bin); {
+			end := len(padded)
+			if c.Stride > 0 {
+				end = off + c.Stride
+			}
+			instr, err := set.Parse(padded[off:end], c.Base+off)
+			if err == nil && !instr.Reserved {
+				ops := make([]string, len(instr.Ops))
+				for i, op := range instr.Ops {
+					ops[i] = op.String()
+				}
+				raw := instr.Raw[:instr.ByteLength]
+				out, err := asm.EncodeAt(instr.Address, instr.Mnemonic, ops...)
+				switch {
+				case err != nil:
+					t.Errorf("%s 0x%06X %X %s: %s", c.Name, instr.Address, raw, instr, err)
+				case !bytes.Equal(out, raw) && !sameTarget(instr, out):
+					t.Errorf("%s 0x%06X %X %s assembled to %X", c.Name, instr.Address, raw, instr, out)
+				}
+			}
+
+			switch {
+			case c.Stride > 0:
+				off += c.Stride
+			case err != nil:
+				off++
+			default:
+				off += instr.ByteLength
+			}
+		}
+	}
+}
+
+// Whether out is an EJMP or ECALL to where instr goes
+func sameTarget(instr disasm.Instruction, out []byte) bool {
+	if instr.Mnemonic != "EJMP" && instr.Mnemonic != "ECALL" {
+		return false
+	}
+	again, err := disasm.Parse(out, instr.Address)
+	return err == nil && again.String() == instr.String()
+}
@@ -0,0 +1,161 @@
+package iso9141
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+	Recovery. An ECU whose application was lost to a failed flash still has its boot
+	code, in the boot block a calibration erase never touches, and the boot code can
+	program the flash again; that's how a bricked unit is brought back on the bench. It
+	doesn't talk like the application does. It's started by something done to the ECU as
+	it powers up, a pin held to ground say, and answers at its own baud rate, often
+	without the 5 baud init or to another address, sometimes only once a request has put
+	it in its programming mode. The ECU definition's Boot says how:
+
+		"Boot": {"Prompt": "Ground pin 23 and power the ECU up", "Baud": 9600,
+		         "Init": "none", "Enter": "A5"}
+
+	NewBoot sets the adapter up that way instead of waking the application, and Recover
+	writes a whole image through the boot code, erasing all of the calibration since
+	what's there can't be trusted, and reads it back. The definition's post-flash steps
+	are the application's, an ignition cycle and an idle relearn in the car, so they
+	aren't run on the bench; they're for once the ECU is back in.
+*/
+
+// BootMode is how an ECU's boot code is reached and talks
+type BootMode struct {
+	Prompt   string // what to do to put the ECU in boot mode, they press enter when done
+	Protocol int    // the adapter's protocol, 3 (ISO 9141-2) when not given, 4 or 5 for KWP
+	Baud     int    // the K-line's baud rate, 4800, 9600, 10400, 12500 or 15625, 10400 when not given
+	Init     string // slow (5 baud), fast (KWP's, protocol 5) or none, slow when not given
+	InitAddr int    // where the slow init is sent, 0x33 when not given
+	Enter    string // a request that puts the boot code in programming mode, hex
+	Wait     int    // milliseconds to give it after Enter
+	Security bool   // the boot code wants security access like the application
+}
+
+// The adapter's codes for the K-line baud rates it takes (AT IB)
+var bootBauds = map[int]string{4800: "48", 9600: "96", 10400: "10", 12500: "12", 15625: "15"}
+
+// The adapter commands that set up the protocol, baud rate and init
+func (b BootMode) commands() ([]string, error) {
+	protocol := b.Protocol
+	if protocol == 0 {
+		protocol = 3
+	}
+	if protocol < 3 || protocol > 5 {
+		return nil, fmt.Errorf("Boot protocol %d isn't ISO 9141-2 (3) or KWP (4, 5)", protocol)
+	}
+	baud := b.Baud
+	if baud == 0 {
+		baud = 10400
+	}
+	ib, ok := bootBauds[baud]
+	if !ok {
+		return nil, fmt.Errorf("The adapter can't talk at %d baud on the K-line", baud)
+	}
+	if _, err := hex.DecodeString(b.Enter); err != nil {
+		return nil, fmt.Errorf("Bad boot request %q", b.Enter)
+	}
+
+	cmds := []string{fmt.Sprintf("AT SP %d", protocol), "AT IB " + ib}
+	switch strings.ToLower(b.Init) {
+	case "", "slow":
+		if protocol == 5 {
+			return nil, fmt.Errorf("Protocol 5 does a fast init, not a slow one")
+		}
+	case "fast":
+		if protocol != 5 {
+			return nil, fmt.Errorf("A fast init is protocol 5's")
+		}
+	case "none":
+		cmds = append(cmds, "AT BI")
+	default:
+		return nil, fmt.Errorf("Unknown boot init %q, slow, fast or none", b.Init)
+	}
+	return cmds, nil
+}
+
+// NewBoot connects to the adapter and reaches the ECU's boot code
+func NewBoot(mode BootMode) (*Device, error) {
+	d := new(Device)
+	d.openAdapter()
+	if err := d.EnterBoot(mode); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// EnterBoot sets the adapter up to talk to the ECU's boot code the way mode says, once
+// the ECU is in boot mode
+func (d *Device) EnterBoot(mode BootMode) error {
+	cmds, err := mode.commands()
+	if err != nil {
+		return err
+	}
+
+	if mode.Prompt != "" {
+		fmt.Printf("====> %s (press enter when done)", mode.Prompt)
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+
+	for _, cmd := range cmds {
+		if _, err := d.Cmd(cmd); err != nil {
+			return err
+		}
+	}
+	if how := strings.ToLower(mode.Init); how == "" || how == "slow" {
+		addr := mode.InitAddr
+		if addr == 0 {
+			addr = initAddr
+		}
+		if err := d.slowInit(addr); err != nil {
+			return fmt.Errorf("The boot code didn't answer the slow init: %s", err)
+		}
+	}
+	d.lastHeader = nil
+
+	if mode.Enter != "" {
+		req, _ := hex.DecodeString(mode.Enter)
+		if _, err := d.Msg(req); err != nil {
+			return fmt.Errorf("The boot code didn't go into programming mode: %s", err)
+		}
+		time.Sleep(time.Duration(mode.Wait) * time.Millisecond)
+	}
+
+	// The boot code has nothing to unlock unless the definition says so
+	d.SecurityMode = !mode.Security
+	log("Boot mode", nil)
+	return nil
+}
+
+// Recover writes image through the boot code, erasing the whole calibration, and reads it
+// back. It doesn't run the post-flash steps.
+func (d *Device) Recover(image []byte) error {
+	if err := CheckImage(image); err != nil {
+		return err
+	}
+	chip, err := d.IdentifyFlash()
+	if err != nil {
+		return err
+	}
+	sectors, err := chip.ImageSectors()
+	if err != nil {
+		return err
+	}
+
+	if err := d.writeImage(bytes.NewReader(image), nil, "", nil); err != nil {
+		return fmt.Errorf("Programming: %s", err)
+	}
+	if err := d.verifyImage(image, sectors); err != nil {
+		return fmt.Errorf("Programmed, but the read back failed: %s", err)
+	}
+	return nil
+}
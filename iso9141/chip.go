@@ -0,0 +1,209 @@
+package iso9141
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+/*
+	Flash chips. How a flash is erased and how long it takes depend on the chip the ECU
+	was built with, which changes between hardware revisions: a 28F400 bottom boot has a
+	96K main block and three of 128K above the boot and parameter blocks, an Am29F400
+	seven sectors of 64K, and each erases and programs at its own pace. The chip is
+	identified by its manufacturer code and device ID where the ECU's bootstrap reads
+	them out (the ECU definition's FlashID request), or named in the definition (Flash)
+	where it doesn't, and looked up in the chip database: the chips here, and any more in
+	./definitions/flash.json, a list of the same fields:
+
+		[
+		  {"Name": "M28F400-B", "Manufacturer": 32, "Device": 17553,
+		   "Sectors": [{"Start": 0, "Length": 16384}, ...], "EraseMs": 1000, "ProgramUs": 10}
+		]
+
+	The chip sits at the top of the calibration's address range, so its sectors are where
+	they fall in the image; the ones below it, the boot and parameter blocks under a 28F400,
+	are never erased. Its erase and program times are how long a routine is given before
+	its stop request is polled.
+
+	An ECU whose definition has neither is taken to have the 28F400 bottom boot the
+	Protege has.
+*/
+
+// FlashChip is a flash part in the database
+type FlashChip struct {
+	Name         string
+	Manufacturer byte
+	Device       uint16
+	Sectors      []Sector // by offset into the chip
+	EraseMs      int      // a sector, typical
+	ProgramUs    int      // a byte, typical
+}
+
+// Size is the chip's size in bytes
+func (c *FlashChip) Size() int {
+	n := 0
+	for _, s := range c.Sectors {
+		n += s.Length
+	}
+	return n
+}
+
+func (c *FlashChip) String() string {
+	return fmt.Sprintf("%s (%02X %04X), %d sectors, %dK", c.Name, c.Manufacturer, c.Device, len(c.Sectors), c.Size()/1024)
+}
+
+// Where the chips are set up beyond the built in ones
+const chipDefinitions = "./definitions/flash.json"
+
+// The chip an ECU is taken to have when it can't be identified or isn't named
+const defaultChip = "28F400BX-B"
+
+// Boot block layouts, the small blocks at the bottom or the top
+var (
+	intelBottom = []Sector{{0x00000, 0x4000}, {0x04000, 0x2000}, {0x06000, 0x2000}, {0x08000, 0x18000}, {0x20000, 0x20000}, {0x40000, 0x20000}, {0x60000, 0x20000}}
+	intelTop    = []Sector{{0x00000, 0x20000}, {0x20000, 0x20000}, {0x40000, 0x20000}, {0x60000, 0x18000}, {0x78000, 0x2000}, {0x7A000, 0x2000}, {0x7C000, 0x4000}}
+	amdBottom   = []Sector{{0x00000, 0x4000}, {0x04000, 0x2000}, {0x06000, 0x2000}, {0x08000, 0x8000}, {0x10000, 0x10000}, {0x20000, 0x10000}, {0x30000, 0x10000}, {0x40000, 0x10000}, {0x50000, 0x10000}, {0x60000, 0x10000}, {0x70000, 0x10000}}
+	amdTop      = []Sector{{0x00000, 0x10000}, {0x10000, 0x10000}, {0x20000, 0x10000}, {0x30000, 0x10000}, {0x40000, 0x10000}, {0x50000, 0x10000}, {0x60000, 0x10000}, {0x70000, 0x8000}, {0x78000, 0x2000}, {0x7A000, 0x2000}, {0x7C000, 0x4000}}
+)
+
+// The chip database, typical times from the datasheets
+var flashChips = []*FlashChip{
+	{Name: "28F400BX-B", Manufacturer: 0x89, Device: 0x4471, Sectors: intelBottom, EraseMs: 2400, ProgramUs: 9},
+	{Name: "28F400BX-T", Manufacturer: 0x89, Device: 0x4470, Sectors: intelTop, EraseMs: 2400, ProgramUs: 9},
+	{Name: "Am29F400BB", Manufacturer: 0x01, Device: 0x22AB, Sectors: amdBottom, EraseMs: 1000, ProgramUs: 7},
+	{Name: "Am29F400BT", Manufacturer: 0x01, Device: 0x2223, Sectors: amdTop, EraseMs: 1000, ProgramUs: 7},
+}
+
+// LoadFlashChips adds the chips in ./definitions/flash.json to the database, none if there
+// isn't one
+func LoadFlashChips() error {
+	data, err := ioutil.ReadFile(chipDefinitions)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var chips []*FlashChip
+	if err := json.Unmarshal(data, &chips); err != nil {
+		return fmt.Errorf("%s: %s", chipDefinitions, err)
+	}
+	for _, c := range chips {
+		if c.Name == "" || len(c.Sectors) == 0 {
+			return fmt.Errorf("%s: a chip needs a name and sectors", chipDefinitions)
+		}
+		flashChips = append(flashChips, c)
+	}
+	return nil
+}
+
+// FindFlashChip looks a chip up by name
+func FindFlashChip(name string) (*FlashChip, error) {
+	for _, c := range flashChips {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown flash chip %s", name)
+}
+
+// Looks a chip up by its IDs, the ones added later first
+func chipByID(manufacturer byte, device uint16) (*FlashChip, error) {
+	for i := len(flashChips) - 1; i >= 0; i-- {
+		if c := flashChips[i]; c.Manufacturer == manufacturer && c.Device == device {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("Unknown flash chip %02X %04X, add it to %s", manufacturer, device, chipDefinitions)
+}
+
+// IdentifyFlash works out the ECU's flash chip, by asking the ECU for its IDs when the
+// definition has a request for them, then by the definition's name for it, the default
+// otherwise, and keeps it for the flashes after
+func (d *Device) IdentifyFlash() (*FlashChip, error) {
+	if d.Chip != nil {
+		return d.Chip, nil
+	}
+
+	chip, err := d.identifyFlash()
+	if err != nil {
+		return nil, err
+	}
+	log("Flash chip - "+chip.String(), nil)
+	d.Chip = chip
+	return chip, nil
+}
+
+func (d *Device) identifyFlash() (*FlashChip, error) {
+	if d.ECU != nil && d.ECU.FlashID != "" {
+		req, err := hex.DecodeString(d.ECU.FlashID)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := d.Msg(req)
+		if err != nil {
+			return nil, fmt.Errorf("Reading the flash chip's IDs: %s", err)
+		}
+
+		// The answer ends with the manufacturer code and the device ID, then the checksum
+		m := resp.Message
+		if len(m) < 4 {
+			return nil, fmt.Errorf("Flash chip IDs %X too short", m)
+		}
+		m = m[:len(m)-1]
+		return chipByID(m[len(m)-3], uint16(m[len(m)-2])<<8|uint16(m[len(m)-1]))
+	}
+	if d.ECU != nil && d.ECU.Flash != "" {
+		return FindFlashChip(d.ECU.Flash)
+	}
+	return FindFlashChip(defaultChip)
+}
+
+// ImageSectors is the chip's sectors in the image, by the address the ECU reads them at:
+// the chip's top is the image's, and what's below the image isn't ours to erase
+func (c *FlashChip) ImageSectors() ([]Sector, error) {
+	base := imageStart + imageSize - c.Size()
+	if base > imageStart {
+		return nil, fmt.Errorf("%s is %dK, smaller than the image", c.Name, c.Size()/1024)
+	}
+	var sectors []Sector
+	for _, s := range c.Sectors {
+		start, end := base+s.Start, base+s.Start+s.Length
+		switch {
+		case start >= imageStart:
+			sectors = append(sectors, Sector{Start: start, Length: s.Length})
+		case end > imageStart:
+			return nil, fmt.Errorf("%s's sector at 0x%06X holds the start of the image and what's below it", c.Name, start)
+		}
+	}
+	return sectors, nil
+}
+
+// How long erasing n sectors takes, nothing for no chip
+func (c *FlashChip) eraseTime(n int) time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(n*c.EraseMs) * time.Millisecond
+}
+
+// How long programming n bytes takes, nothing for no chip
+func (c *FlashChip) programTime(n int) time.Duration {
+	if c == nil {
+		return 0
+	}
+	return time.Duration(n*c.ProgramUs) * time.Microsecond
+}
+
+// How many of the chip's sectors the image takes, none for no chip
+func (c *FlashChip) imageSectorCount() int {
+	if c == nil {
+		return 0
+	}
+	sectors, _ := c.ImageSectors()
+	return len(sectors)
+}
@@ -0,0 +1,293 @@
+package iso9141
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Region is a span of the calibration image, as an offset into the downloaded BIN
+type Region struct {
+	Offset int
+	Length int
+}
+
+// ClonePolicy decides what unit specific data survives a clone
+type ClonePolicy struct {
+	Keep          []Region           // regions (VIN, SKIM/immobilizer data) kept from the target ECU
+	CheckChecksum func([]byte) error // checks the donor's checksums with the calibration's own routines
+	FixChecksum   func([]byte) error // recalculates any image checksums after the target data is patched in
+	NoPrompt      bool               // don't wait for the ECU swap, the target is already connected
+	CheckTarget   func([]byte) error // refuses a target, by its ECU ID, before anything is written to it
+}
+
+// CloneStep is one line of the clone report
+type CloneStep struct {
+	Name   string
+	Detail string
+	Err    error
+	Time   time.Time
+}
+
+// CloneReport records every step of a clone, written out next to the images it saved
+type CloneReport struct {
+	Started time.Time
+	Steps   []CloneStep
+}
+
+// Clone reads the calibration from a donor ECU, then programs and verifies it on a target ECU,
+// keeping the target's unit specific data according to policy
+func (d *Device) Clone(outfile string, policy ClonePolicy) (*CloneReport, error) {
+	report := &CloneReport{Started: time.Now()}
+	ts := report.Started.Format(time.RFC3339)
+
+	err := report.run(d, outfile, ts, policy)
+
+	// Always leave the report behind, especially when something failed
+	reportFile := "./" + outfile + ts + ".txt"
+	if werr := report.Write(reportFile); werr != nil {
+		log("Clone - Error writing report", werr)
+	} else {
+		log(fmt.Sprintf("Clone - Report written to %s", reportFile), nil)
+	}
+
+	return report, err
+}
+
+func (r *CloneReport) run(d *Device, outfile, ts string, policy ClonePolicy) error {
+
+	// Read the donor
+	donor, err := d.ReadImage()
+	if err = r.step("Read donor", fmt.Sprintf("0x%X bytes", len(donor)), err); err != nil {
+		return err
+	}
+
+	donorFile := "./" + outfile + "-DONOR" + ts + ".BIN"
+	err = saveImage(donorFile, donor)
+	if err = r.step("Save donor", donorFile, err); err != nil {
+		return err
+	}
+
+	// A donor that fails its own checksums would never run in the target
+	err = CheckImage(donor)
+	checked := "checksums not checked, no checksum routines for this calibration"
+	if err == nil && policy.CheckChecksum != nil {
+		err = policy.CheckChecksum(donor)
+		checked = "checksums good"
+	}
+	if err = r.step("Verify donor", fmt.Sprintf("sum 0x%04X, %s", imageSum(donor), checked), err); err != nil {
+		return err
+	}
+
+	// Swap ECUs, the target needs its own security session
+	if !policy.NoPrompt {
+		fmt.Print("====> Connect the target ECU and press enter...")
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	err = d.Rewake()
+	if err = r.step("Swap ECU", fmt.Sprintf("key bytes %X", d.KeyBytes), err); err != nil {
+		return err
+	}
+
+	// Writes may be locked to one ECU
+	if policy.CheckTarget != nil {
+		id, err := d.ReadEcuId()
+		if err == nil {
+			err = policy.CheckTarget(id)
+		}
+		if err = r.step("Identify target", fmt.Sprintf("ECU %X", id), err); err != nil {
+			return err
+		}
+	}
+
+	// Back up the target before anything is erased
+	target, err := d.ReadImage()
+	if err = r.step("Read target", fmt.Sprintf("0x%X bytes", len(target)), err); err != nil {
+		return err
+	}
+
+	targetFile := "./" + outfile + "-TARGET" + ts + ".BIN"
+	err = saveImage(targetFile, target)
+	if err = r.step("Save target", targetFile, err); err != nil {
+		return err
+	}
+
+	err = CheckImage(target)
+	if err = r.step("Check target", fmt.Sprintf("sum 0x%04X", imageSum(target)), err); err != nil {
+		return err
+	}
+
+	// Carry the unit specific data over from the target
+	image := make([]byte, len(donor))
+	copy(image, donor)
+
+	var kept []string
+	for _, k := range policy.Keep {
+		if k.Offset < 0 || k.Length <= 0 || k.Offset+k.Length > len(image) {
+			err = fmt.Errorf("Region 0x%X:0x%X is outside the image", k.Offset, k.Length)
+			break
+		}
+		copy(image[k.Offset:k.Offset+k.Length], target[k.Offset:k.Offset+k.Length])
+		kept = append(kept, fmt.Sprintf("0x%X:0x%X", k.Offset, k.Length))
+	}
+	if len(kept) == 0 && err == nil {
+		kept = append(kept, "nothing kept, exact copy of the donor")
+	}
+	if err = r.step("Adjust unit data", strings.Join(kept, ", "), err); err != nil {
+		return err
+	}
+
+	// Image checksums
+	if policy.FixChecksum != nil {
+		err = policy.FixChecksum(image)
+		if err = r.step("Fix checksums", fmt.Sprintf("sum 0x%04X", imageSum(image)), err); err != nil {
+			return err
+		}
+	} else {
+		r.step("Fix checksums", "skipped, no checksum fixer for this calibration (block checksums are still sent)", nil)
+	}
+
+	// Program the target
+	err = d.WriteImage(bytes.NewReader(image))
+	if err = r.step("Program target", fmt.Sprintf("0x%X bytes", len(image)), err); err != nil {
+		return err
+	}
+
+	// Read it back
+	readBack, err := d.ReadImage()
+	if err = r.step("Read back", fmt.Sprintf("0x%X bytes", len(readBack)), err); err != nil {
+		return err
+	}
+
+	diffs := 0
+	first := -1
+	for i := range image {
+		if i >= len(readBack) || image[i] != readBack[i] {
+			if first < 0 {
+				first = i
+			}
+			diffs++
+		}
+	}
+	if diffs > 0 {
+		err = fmt.Errorf("%d bytes differ, first at offset 0x%X", diffs, first)
+	}
+	if err = r.step("Verify target", fmt.Sprintf("sum 0x%04X", imageSum(readBack)), err); err != nil {
+		return err
+	}
+
+	// Vehicle specific steps, once the target is known to hold the image
+	if d.ECU == nil || len(d.ECU.PostFlash) == 0 {
+		r.step("Post-flash", "skipped, no post-flash steps for this ECU", nil)
+		return nil
+	}
+	err = d.PostFlash(d.ECU, nil)
+	return r.step("Post-flash", fmt.Sprintf("%d steps", len(d.ECU.PostFlash)), err)
+}
+
+// Step records a step and logs it as it happens
+func (r *CloneReport) step(name, detail string, err error) error {
+	r.Steps = append(r.Steps, CloneStep{Name: name, Detail: detail, Err: err, Time: time.Now()})
+	if err != nil {
+		log("Clone - "+name, err)
+	} else {
+		log(fmt.Sprintf("Clone - %s [OK] %s", name, detail), nil)
+	}
+	return err
+}
+
+// OK is true when every step passed
+func (r *CloneReport) OK() bool {
+	for _, s := range r.Steps {
+		if s.Err != nil {
+			return false
+		}
+	}
+	return len(r.Steps) > 0
+}
+
+func (r *CloneReport) String() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "ELMFlash clone report - %s\n\n", r.Started.Format(time.RFC3339))
+	for _, s := range r.Steps {
+		status := "OK"
+		if s.Err != nil {
+			status = "FAIL - " + s.Err.Error()
+		}
+		fmt.Fprintf(&buf, "%s  %-18s %-8s %s\n", s.Time.Format("15:04:05"), s.Name, status, s.Detail)
+	}
+	if r.OK() {
+		buf.WriteString("\nClone finished.\n")
+	} else {
+		buf.WriteString("\nClone did NOT finish, see the failed step above.\n")
+	}
+	return buf.String()
+}
+
+// Write saves the report as a text file
+func (r *CloneReport) Write(file string) error {
+	return saveImage(file, []byte(r.String()))
+}
+
+// ParseRegions reads a comma separated list of offset:length pairs, like 0x7F00:0x20,0x7F40:16
+func ParseRegions(s string) ([]Region, error) {
+	var regions []Region
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		if len(fields) != 2 {
+			return nil, errors.New("Regions are offset:length - " + part)
+		}
+		offset, err := strconv.ParseInt(fields[0], 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.ParseInt(fields[1], 0, 32)
+		if err != nil {
+			return nil, err
+		}
+		regions = append(regions, Region{Offset: int(offset), Length: int(length)})
+	}
+	return regions, nil
+}
+
+func saveImage(file string, data []byte) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(data)
+	return err
+}
+
+// CheckImage catches reads that came back short or blank before they get written anywhere
+func CheckImage(image []byte) error {
+	if len(image) != imageSize {
+		return fmt.Errorf("Image is 0x%X bytes, expected 0x%X", len(image), imageSize)
+	}
+	for _, b := range image {
+		if b != 0xFF && b != 0x00 {
+			return nil
+		}
+	}
+	return errors.New("Image is blank")
+}
+
+// ImageSum is the same 16 bit byte sum the ECU checks each uploaded block against
+func imageSum(image []byte) uint16 {
+	sum := uint16(0)
+	for _, b := range image {
+		sum += uint16(b)
+	}
+	return sum
+}
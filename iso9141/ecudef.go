@@ -0,0 +1,206 @@
+package iso9141
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/murdinc/ELMFlash/units"
+)
+
+// ECUDef describes one ECU, loaded from ./definitions/<name>.json
+type ECUDef struct {
+	Name      string
+	PostFlash []PostFlashStep // run in order after a successful write
+
+	// The routine that erases one flash sector, the sector's address (3 bytes, as it's
+	// programmed) appended to its start request. When it's set a flash only erases and
+	// programs the sectors that change, otherwise the whole calibration.
+	EraseSector *Routine
+
+	// The flash chip, by its name in the chip database, and the request whose answer ends
+	// with the chip's manufacturer code and 16 bit device ID, where the bootstrap has one
+	Flash   string
+	FlashID string
+
+	// How the boot code is reached to recover the ECU when its application is gone
+	Boot *BootMode
+
+	// A flashing kernel to program through instead of the ECU's routines
+	Kernel *KernelDef
+
+	// Engineering units for named values, like "RPM": {"Units": "rpm", "Scale": 0.25}.
+	// Reports look values up here by the name they were given.
+	Scalings map[string]units.Scaling
+}
+
+// PostFlashStep is one thing an ECU needs after it is written: clearing adaptives,
+// an idle relearn, re-locking security, etc. Requests are hex strings like "31A4".
+type PostFlashStep struct {
+	Name     string
+	Type     string // msg, routine, wait or prompt
+	Request  string // msg: the request, routine: the start request
+	Stop     string // routine: the stop request
+	Success  string // routine: error codes that mean the routine started, like "2223"
+	Wait     int    // wait: milliseconds
+	Prompt   string // prompt: what the user has to do, they press enter when done
+	Optional bool   // keep going if this step fails
+	Ignition bool   // the step cycles the ignition, the ECU is woken again after it
+}
+
+// Routine is an ECU routine by its requests, hex strings like "31A2"
+type Routine struct {
+	Request string // the start request
+	Stop    string // the stop request
+	Success string // error codes that mean the routine started, like "2223"
+}
+
+func (r Routine) requests() ([][]byte, error) {
+	return PostFlashStep{Type: "routine", Request: r.Request, Stop: r.Stop, Success: r.Success}.requests()
+}
+
+// PostFlashProgress is told about every step as it finishes, returning false aborts the
+// steps that are left
+type PostFlashProgress func(i, total int, step PostFlashStep, err error) bool
+
+// LoadECUDef reads an ECU definition by name
+func LoadECUDef(name string) (*ECUDef, error) {
+	data, err := ioutil.ReadFile("./definitions/" + name + ".json")
+	if err != nil {
+		return nil, err
+	}
+
+	def := new(ECUDef)
+	if err := json.Unmarshal(data, def); err != nil {
+		return nil, fmt.Errorf("Definition %s: %s", name, err)
+	}
+
+	// Catch bad steps before anything is written to the ECU
+	for i, step := range def.PostFlash {
+		if _, err := step.requests(); err != nil {
+			return nil, fmt.Errorf("Definition %s, post-flash step %d (%s): %s", name, i+1, step.Name, err)
+		}
+	}
+
+	if _, err := hex.DecodeString(def.FlashID); err != nil {
+		return nil, fmt.Errorf("Definition %s, flash ID request: %s", name, err)
+	}
+	if def.Boot != nil {
+		if _, err := def.Boot.commands(); err != nil {
+			return nil, fmt.Errorf("Definition %s, boot mode: %s", name, err)
+		}
+	}
+	if def.Kernel != nil {
+		if def.Kernel.Source == "" {
+			return nil, fmt.Errorf("Definition %s, kernel: no source", name)
+		}
+		if run, err := hex.DecodeString(def.Kernel.Run); err != nil || len(run) == 0 {
+			return nil, fmt.Errorf("Definition %s, kernel: bad run request %q", name, def.Kernel.Run)
+		}
+	}
+	if def.EraseSector != nil {
+		if _, err := def.EraseSector.requests(); err != nil {
+			return nil, fmt.Errorf("Definition %s, sector erase: %s", name, err)
+		}
+	}
+
+	return def, nil
+}
+
+func (s PostFlashStep) requests() ([][]byte, error) {
+	var out [][]byte
+	switch s.Type {
+	case "msg":
+		out = [][]byte{nil}
+	case "routine":
+		out = [][]byte{nil, nil, nil}
+	case "wait", "prompt":
+		return nil, nil
+	default:
+		return nil, errors.New("Unknown step type: " + s.Type)
+	}
+
+	for i, h := range []string{s.Request, s.Stop, s.Success}[:len(out)] {
+		b, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = b
+	}
+	if len(out[0]) == 0 {
+		return nil, errors.New("Step has no request")
+	}
+	if s.Type == "routine" && len(out[1]) == 0 {
+		return nil, errors.New("Routine has no stop request")
+	}
+	return out, nil
+}
+
+// PostFlash runs the definition's post-flash steps. A failed step stops the run unless it
+// is Optional, and progress (logging only when nil) can abort between steps.
+func (d *Device) PostFlash(def *ECUDef, progress PostFlashProgress) error {
+	if def == nil || len(def.PostFlash) == 0 {
+		return nil
+	}
+
+	if progress == nil {
+		progress = func(i, total int, step PostFlashStep, err error) bool {
+			if err != nil {
+				log(fmt.Sprintf("PostFlash [%d/%d] %s", i+1, total, step.Name), err)
+			} else {
+				log(fmt.Sprintf("PostFlash [%d/%d] %s [OK]", i+1, total, step.Name), nil)
+			}
+			return true
+		}
+	}
+
+	log(fmt.Sprintf("Running %d post-flash steps for %s", len(def.PostFlash), def.Name), nil)
+
+	for i, step := range def.PostFlash {
+		err := d.postFlashStep(step)
+
+		// The ECU restarted, its session and security access with it
+		if step.Ignition {
+			if werr := d.Rewake(); werr != nil && err == nil {
+				err = fmt.Errorf("Waking the ECU after the ignition cycle: %s", werr)
+			}
+		}
+
+		if !progress(i, len(def.PostFlash), step, err) {
+			return fmt.Errorf("Post-flash aborted after step %d (%s)", i+1, step.Name)
+		}
+		if err != nil && !step.Optional {
+			return fmt.Errorf("Post-flash step %d (%s) failed: %s", i+1, step.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *Device) postFlashStep(step PostFlashStep) error {
+	reqs, err := step.requests()
+	if err != nil {
+		return err
+	}
+
+	switch step.Type {
+	case "msg":
+		_, err = d.Msg(reqs[0])
+		return err
+
+	case "routine":
+		return d.RunRoutine(reqs[0], reqs[1], reqs[2])
+
+	case "wait":
+		time.Sleep(time.Duration(step.Wait) * time.Millisecond)
+
+	case "prompt":
+		fmt.Printf("====> %s (press enter when done)", step.Prompt)
+		bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	return nil
+}
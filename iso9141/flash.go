@@ -0,0 +1,342 @@
+package iso9141
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+/*
+	Flashing. Flash takes a calibration image from unlocking the ECU to checking what it
+	reads back, and nothing is erased until everything that can be checked beforehand has
+	been: the image is the right size, its checksums are fixed up (when there's a fixer for
+	the calibration), the ECU's own image is read, or a cached read of it taken, the two
+	compared sector by sector, and what the ECU holds in the sectors to be erased saved as
+	a backup. Then the sectors are erased, the image programmed and read back, and the ECU
+	definition's post-flash steps run once it reads back right. If programming fails, or
+	what reads back isn't the image, the backup goes back on and is read back in turn, so
+	the ECU is left with one image or the other. A read back that doesn't get through
+	leaves the ECU as it is, programmed but not known to be good, and says so.
+
+	With DryRun set it stops after the compare and the plan says what the flash would do:
+	the sectors erased, the bytes programmed, and the sectors that change, with how many
+	bytes and where the first is.
+
+	Sectors are the flash chip's (see IdentifyFlash), by the address the ECU reads them
+	at. The ECU's own erase routine takes the whole calibration,
+	so every sector is erased and programmed even when only one changes, unless the ECU
+	definition has a routine that erases one sector (EraseSector): then a flash is a delta,
+	only the sectors that differ from what the ECU holds are erased, programmed and read
+	back, and a small change to the calibration takes a fraction of the time. A cached
+	read (Current) saves reading the whole ECU first, it only plans the flash: the sectors
+	to be erased are still read from the ECU for the backup, so a stale cache can't put the
+	wrong image back on, and it only has to be right about the sectors it says are the
+	same.
+*/
+
+// Sector is one erase block of the flash, by the address the ECU reads it at
+type Sector struct {
+	Start  int
+	Length int
+}
+
+// SectorChange is what an image changes in one sector
+type SectorChange struct {
+	Sector
+	Bytes int // bytes that differ
+	First int // the address of the first
+}
+
+// FlashOptions are how Flash goes about it
+type FlashOptions struct {
+	DryRun      bool               // plan and report, don't erase or program anything
+	Current     []byte             // a read of the ECU's image to plan with, read from the ECU when nil; never the backup
+	FixChecksum func([]byte) error // recalculates the image's checksums before it's compared and written
+	Backup      string             // where the ECU's image is saved before it's erased, ./BACKUP<time>.BIN when empty
+	Kernel      bool               // program through the ECU definition's flashing kernel
+}
+
+// FlashPlan is what a flash does
+type FlashPlan struct {
+	Chip     string         // the flash chip
+	Erase    []Sector       // sectors erased
+	Program  int            // bytes programmed
+	Changes  []SectorChange // sectors whose contents change, in address order
+	SumFrom  uint16         // the image's sum as given
+	SumTo    uint16         // and once its checksums are fixed
+	Backup   string         // where the ECU's image was saved
+	Restored bool           // the flash failed and the backup went back on
+}
+
+// Bytes is how many bytes change in all
+func (p *FlashPlan) Bytes() int {
+	n := 0
+	for _, c := range p.Changes {
+		n += c.Bytes
+	}
+	return n
+}
+
+func (p *FlashPlan) String() string {
+	var buf bytes.Buffer
+	if len(p.Changes) == 0 {
+		buf.WriteString("The ECU already holds this image, nothing to flash\n")
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "Erase %d sectors of the %s, program 0x%X bytes\n", len(p.Erase), p.Chip, p.Program)
+	if p.SumFrom != p.SumTo {
+		fmt.Fprintf(&buf, "Checksums fixed, image sum 0x%04X to 0x%04X\n", p.SumFrom, p.SumTo)
+	}
+	fmt.Fprintf(&buf, "%d bytes change in %d sectors:\n", p.Bytes(), len(p.Changes))
+	for _, c := range p.Changes {
+		fmt.Fprintf(&buf, "  0x%06X-0x%06X  %6d bytes, first at 0x%06X\n", c.Start, c.Start+c.Length-1, c.Bytes, c.First)
+	}
+	return buf.String()
+}
+
+// Flash writes image to the ECU, checked and verified as a whole, and returns the plan it
+// followed; with opts.DryRun only the plan
+func (d *Device) Flash(image []byte, opts FlashOptions) (*FlashPlan, error) {
+	if err := CheckImage(image); err != nil {
+		return nil, err
+	}
+	img := make([]byte, len(image))
+	copy(img, image)
+
+	plan := &FlashPlan{SumFrom: imageSum(img)}
+	if opts.FixChecksum != nil {
+		if err := opts.FixChecksum(img); err != nil {
+			return plan, fmt.Errorf("Fixing checksums: %s", err)
+		}
+	}
+	plan.SumTo = imageSum(img)
+
+	// Unlock
+	if !d.SecurityMode {
+		if err := d.EnableSecurity(); err != nil {
+			return plan, err
+		}
+	}
+
+	// The chip, for its sectors
+	chip, err := d.IdentifyFlash()
+	if err != nil {
+		return plan, err
+	}
+	sectors, err := chip.ImageSectors()
+	if err != nil {
+		return plan, err
+	}
+	plan.Chip = chip.Name
+
+	// What's there now
+	current := opts.Current
+	if current == nil {
+		if current, err = d.ReadImage(); err != nil {
+			return plan, fmt.Errorf("Reading the ECU's image: %s", err)
+		}
+	}
+	if len(current) != imageSize {
+		return plan, fmt.Errorf("The ECU's image is 0x%X bytes, expected 0x%X", len(current), imageSize)
+	}
+
+	plan.Changes = compareSectors(current, img, sectors)
+	if len(plan.Changes) > 0 {
+		plan.Erase = sectors
+		if opts.Kernel || d.ECU != nil && d.ECU.EraseSector != nil {
+			plan.Erase = nil
+			for _, c := range plan.Changes {
+				plan.Erase = append(plan.Erase, c.Sector)
+			}
+		}
+		for _, s := range plan.Erase {
+			plan.Program += s.Length
+		}
+	}
+	if opts.DryRun || len(plan.Changes) == 0 {
+		return plan, nil
+	}
+
+	// The backup is what's on the ECU, a cached read only planned the flash
+	if opts.Current != nil {
+		if current, err = d.readSectors(current, plan.Erase); err != nil {
+			return plan, fmt.Errorf("Reading the ECU's image for the backup: %s", err)
+		}
+	}
+
+	plan.Backup = opts.Backup
+	if plan.Backup == "" {
+		plan.Backup = "./BACKUP" + time.Now().Format(time.RFC3339) + ".BIN"
+	}
+	if err := saveImage(plan.Backup, current); err != nil {
+		return plan, fmt.Errorf("Saving the backup: %s", err)
+	}
+	log("Flash - ECU image saved to "+plan.Backup, nil)
+
+	if opts.Kernel {
+		return plan, d.kernelFlash(plan, img, current)
+	}
+
+	// Erase, program and check, a delta when only some sectors are erased
+	var only []Sector
+	if len(plan.Erase) < len(sectors) {
+		only = plan.Erase
+	}
+	if err := d.writeImage(bytes.NewReader(img), nil, "", only); err != nil {
+		return plan, d.restore(plan, current, only, fmt.Errorf("Programming: %s", err))
+	}
+	if err := d.verifyImage(img, plan.Erase); err != nil {
+		if _, differs := err.(verifyError); differs {
+			return plan, d.restore(plan, current, only, fmt.Errorf("Programmed, but it reads back wrong: %s", err))
+		}
+		return plan, fmt.Errorf("Programmed, but the read back failed: %s; the ECU may well hold the new image, the old one is in %s", err, plan.Backup)
+	}
+
+	// Vehicle specific steps, only for an image that's known to be on
+	return plan, d.PostFlash(d.ECU, nil)
+}
+
+// A copy of image with sectors read from the ECU over it
+func (d *Device) readSectors(image []byte, sectors []Sector) ([]byte, error) {
+	read := make([]byte, len(image))
+	copy(read, image)
+	for _, s := range sectors {
+		log(fmt.Sprintf("Flash - Reading 0x%06X-0x%06X for the backup", s.Start, s.Start+s.Length-1), nil)
+		data, err := d.ReadMemory(s.Start, s.Length, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) < s.Length {
+			return nil, fmt.Errorf("0x%X bytes read from 0x%06X, expected 0x%X", len(data), s.Start, s.Length)
+		}
+		copy(read[s.Start-imageStart:], data[:s.Length])
+	}
+	return read, nil
+}
+
+// Puts the backup back on after a failed flash and reads it back, without the post-flash
+// steps
+func (d *Device) restore(plan *FlashPlan, current []byte, only []Sector, err error) error {
+	log("Flash - Failed, putting the backup back on", err)
+	rerr := d.writeImage(bytes.NewReader(current), nil, "", only)
+	if rerr == nil {
+		rerr = d.verifyImage(current, plan.Erase)
+	}
+	if rerr != nil {
+		return fmt.Errorf("%s; putting the backup back on failed too: %s, it's in %s", err, rerr, plan.Backup)
+	}
+	plan.Restored = true
+	return fmt.Errorf("%s; the backup is back on", err)
+}
+
+// Flashes the plan's sectors through the kernel, the backup put back through it if that
+// fails, then resets the ECU into whichever image it has and runs the post-flash steps
+func (d *Device) kernelFlash(plan *FlashPlan, image, current []byte) error {
+	k, err := d.LoadKernel()
+	if err != nil {
+		return err
+	}
+
+	err = k.Flash(image, plan.Erase)
+	if err != nil {
+		log("Flash - Failed, putting the backup back on", err)
+		if rerr := k.Flash(current, plan.Erase); rerr != nil {
+			return fmt.Errorf("%s; putting the backup back on failed too: %s, it's in %s", err, rerr, plan.Backup)
+		}
+		plan.Restored = true
+		err = fmt.Errorf("%s; the backup is back on", err)
+	}
+
+	if rerr := k.Reset(); rerr != nil {
+		return fmt.Errorf("Resetting the ECU: %s", rerr)
+	}
+	if rerr := d.Rewake(); rerr != nil {
+		return fmt.Errorf("Waking the ECU after the reset: %s", rerr)
+	}
+	if err != nil {
+		return err
+	}
+	return d.PostFlash(d.ECU, nil)
+}
+
+// A read back that got through and isn't the image, at an address
+type verifyError int
+
+func (e verifyError) Error() string {
+	return fmt.Sprintf("Read back differs at 0x%06X", int(e))
+}
+
+// Reads the sectors written back and checks they hold what the image does
+func (d *Device) verifyImage(image []byte, sectors []Sector) error {
+	for _, s := range sectors {
+		log(fmt.Sprintf("Flash - Reading back 0x%06X-0x%06X", s.Start, s.Start+s.Length-1), nil)
+		readBack, err := d.ReadMemory(s.Start, s.Length, nil)
+		if err != nil {
+			return fmt.Errorf("Reading back: %s", err)
+		}
+		want := image[s.Start-imageStart : s.Start-imageStart+s.Length]
+		for i := range want {
+			if readBack[i] != want[i] {
+				return verifyError(s.Start + i)
+			}
+		}
+	}
+	return nil
+}
+
+// Erases one sector with the ECU definition's routine
+func (d *Device) eraseSector(s Sector) error {
+	if d.ECU == nil || d.ECU.EraseSector == nil {
+		return fmt.Errorf("No sector erase routine for this ECU")
+	}
+	reqs, err := d.ECU.EraseSector.requests()
+	if err != nil {
+		return err
+	}
+	adr := programAddress(s.Start)
+	start := append(append([]byte{}, reqs[0]...), byte(adr>>16), byte(adr>>8), byte(adr))
+	return d.runRoutine(start, reqs[1], reqs[2], d.Chip.eraseTime(1))
+}
+
+// The address a byte the ECU reads at adr is programmed at, the image above its first
+// 0x18000 bytes taking 0x80000 more
+func programAddress(adr int) int {
+	if adr >= imageStart+0x18000 {
+		return adr + 0x80000
+	}
+	return adr
+}
+
+// Whether adr is in one of sectors
+func inSectors(adr int, sectors []Sector) bool {
+	for _, s := range sectors {
+		if adr >= s.Start && adr < s.Start+s.Length {
+			return true
+		}
+	}
+	return false
+}
+
+// The sectors that differ between two images
+func compareSectors(from, to []byte, sectors []Sector) []SectorChange {
+	var changes []SectorChange
+	for _, s := range sectors {
+		c := SectorChange{Sector: s, First: -1}
+		for adr := s.Start; adr < s.Start+s.Length; adr++ {
+			i := adr - imageStart
+			if i >= len(to) || i >= len(from) {
+				break
+			}
+			if from[i] != to[i] {
+				if c.First < 0 {
+					c.First = adr
+				}
+				c.Bytes++
+			}
+		}
+		if c.Bytes > 0 {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
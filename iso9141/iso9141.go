@@ -1,7 +1,6 @@
 package iso9141
 
 import (
-	"bufio"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -13,7 +12,8 @@ import (
 	"time"
 
 	"github.com/cheggaaa/pb"
-	serial "github.com/huin/goserial"
+	"github.com/murdinc/ELMFlash/comm"
+	"github.com/murdinc/ELMFlash/crash"
 )
 
 // App constants
@@ -21,11 +21,18 @@ import (
 const baud = 115200
 const debug = false
 const obdDevice = "STY3M"
-const EOL = 0x3E
 const testerAddr = 0xF5
 const ecuAddr = 0x10
 const errResp = 0x7F
 
+// Adapter is where the adapter is, a serial port, a WiFi adapter's host and port
+// (192.168.0.10:35000 say) or a Bluetooth adapter's address; it's looked for when empty
+var Adapter string
+
+// The calibration image as the ECU serves it, 480 1k blocks
+const imageStart = 0x108000
+const imageSize = 0x78000
+
 var errCodes = []string{
 	0x10: "10 - General Reject",
 	0x11: "11 - Service Not Supported",
@@ -92,33 +99,45 @@ type Packet struct {
 	DataAddr int
 }
 
-// Connection represents an OBD-II serial connection
+// Connection represents an OBD-II connection through the adapter
 type Device struct {
 	Packet       Packet
-	serial       io.ReadWriteCloser
+	link         comm.Transport
 	location     string
 	baud         int
 	lastHeader   []byte
 	SecurityMode bool
+	KeyBytes     []byte // the ECU's key bytes from the slow init
 	Dummy        bool
+	ECU          *ECUDef    // post-flash steps run once a flash is verified, when set
+	Chip         *FlashChip // the flash chip, once it's identified
 }
 
 // Device Functions
 ////////////////..........
 
 func (d *Device) EcuId() error {
-	ecuIdCommand := []byte{0x10} // note: flipped most and least significant bytes
-	idResp, err := d.Msg(ecuIdCommand)
+	id, err := d.ReadEcuId()
 	if err != nil {
 		log("EcuId", err)
 		return err
 	} else {
-		resp := fmt.Sprintf("ECU ID: %X", idResp.Message)
+		resp := fmt.Sprintf("ECU ID: %X", id)
 		log(resp, nil)
 	}
 	return nil
 }
 
+// ReadEcuId returns the raw ECU ID response
+func (d *Device) ReadEcuId() ([]byte, error) {
+	ecuIdCommand := []byte{0x10} // note: flipped most and least significant bytes
+	idResp, err := d.Msg(ecuIdCommand)
+	if err != nil {
+		return nil, err
+	}
+	return idResp.Message, nil
+}
+
 // TEST
 func (d *Device) Test() error {
 	return nil
@@ -147,29 +166,57 @@ func (d *Device) DownloadBIN(outfile string) error {
 		return err
 	}
 
+	image, err := d.ReadImage()
+	if err != nil {
+		return err
+	}
+
+	n, err := f.Write(image)
+	if err != nil {
+		log("DownloadBIN - Error writing to file", err)
+		return err
+	}
+	dbg(fmt.Sprintf("DownloadBIN - wrote %d bytes", n), nil)
+
+	return nil
+}
+
+// ReadImage downloads the whole calibration (0x108000 - 0x17FFFF) into memory
+func (d *Device) ReadImage() ([]byte, error) {
 	log("Starting Download...", nil)
-	bar := pb.StartNew(480)
+	bar := pb.StartNew(imageSize)
 
-	for i := 0x108000; i < 0x017FFFF; i = i + 0x0400 {
-		bar.Increment()
-		addr := i
+	image, err := d.ReadMemory(imageStart, imageSize, func(p Progress) {
+		bar.Set(p.Done)
+		dbg("Download - "+p.String(), nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	bar.FinishPrint("Download Finished!")
+	return image, nil
+}
 
-		block, err := d.DownloadBlock(addr, 1024)
-		if err != nil {
-			return err
-		}
-		dbg(fmt.Sprintf("BLOCK: %X\n\n", block), nil)
+// DumpRAM reads length bytes of RAM from start, in blocks of 0x100, into outfile as a
+// raw snapshot
+func (d *Device) DumpRAM(outfile string, start, length int) error {
+	if length <= 0 || length%0x100 != 0 || start < 0 || start+length > 0x10000 {
+		return fmt.Errorf("Bad RAM range 0x%X, 0x%X bytes", start, length)
+	}
 
-		n, err := f.Write(block)
+	ram := make([]byte, 0, length)
+	for addr := start; addr < start+length; addr += 0x100 {
+		block, err := d.DownloadBlock(addr, 0x100)
 		if err != nil {
-			log("DownloadBIN - Error writing to file", err)
 			return err
 		}
-		dbg(fmt.Sprintf("DownloadBIN - wrote %d bytes", n), nil)
+		ram = append(ram, block...)
+	}
 
-		f.Sync()
+	if err := ioutil.WriteFile(outfile, ram, 0644); err != nil {
+		log("DumpRAM - Error writing to file", err)
+		return err
 	}
-	bar.FinishPrint("Download Finished!")
 	return nil
 }
 
@@ -227,12 +274,46 @@ func (d *Device) UploadBIN(calName string) error {
 	calFile := "./calibrations/" + calibrations[calName]
 	log(fmt.Sprintf("Calibration File: %s", calFile), nil)
 
-	f, err := os.Open(calFile)
+	err := d.ResumeWrite(calFile)
 	if err != nil {
-		log("UploadBIN - Error opening file", err)
+		log("UploadBIN - Stopped, upload again to carry on where it left off", err)
 		return err
 	}
 
+	// Read it back before the vehicle specific steps
+	image, err := ioutil.ReadFile(calFile)
+	if err != nil {
+		return err
+	}
+	if !d.Dummy {
+		chip, err := d.IdentifyFlash()
+		if err != nil {
+			return err
+		}
+		sectors, err := chip.ImageSectors()
+		if err != nil {
+			return err
+		}
+		if err := d.verifyImage(image, sectors); err != nil {
+			log("UploadBIN - Verify failed", err)
+			return err
+		}
+	}
+	return d.PostFlash(d.ECU, nil)
+}
+
+// WriteImage erases and programs a whole calibration image, laid out the same as the
+// files ReadImage and DownloadBIN produce. It doesn't read it back or run the post-flash
+// steps, that's for whoever checks the write.
+func (d *Device) WriteImage(f io.ReadSeeker) error {
+	return d.writeImage(f, nil, "", nil)
+}
+
+// Writes the image, and with a transfer skips the erase and the blocks it has confirmed
+// and confirms each one written, saving it for file. With sectors only those are erased,
+// one at a time, and programmed.
+func (d *Device) writeImage(f io.ReadSeeker, t *Transfer, file string, sectors []Sector) error {
+
 	// Make sure we have Security Access
 	if d.SecurityMode == false {
 		err := d.EnableSecurity()
@@ -243,7 +324,7 @@ func (d *Device) UploadBIN(calName string) error {
 	}
 
 	// Request by PID
-	_, err = d.Msg([]byte{0x22, 0x11, 0x00})
+	_, err := d.Msg([]byte{0x22, 0x11, 0x00})
 	if err != nil {
 		return err
 	}
@@ -254,10 +335,19 @@ func (d *Device) UploadBIN(calName string) error {
 		return err
 	}
 
-	// Delete BIN on ECU
-	err = d.RunRoutine([]byte{0x31, 0xA1}, []byte{0x32, 0xA1, 0x00}, []byte{0x22, 0x23})
-	if err != nil {
-		dbg("UploadBIN - Routine 31 A1", err)
+	// Delete BIN on ECU, or only the sectors being written, unless this picks up a write
+	// that already did
+	if sectors != nil {
+		for _, s := range sectors {
+			if err := d.eraseSector(s); err != nil {
+				return fmt.Errorf("Erasing sector 0x%06X: %s", s.Start, err)
+			}
+		}
+	} else if t == nil || len(t.Blocks) == 0 {
+		err = d.runRoutine([]byte{0x31, 0xA1}, []byte{0x32, 0xA1, 0x00}, []byte{0x22, 0x23}, d.Chip.eraseTime(d.Chip.imageSectorCount()))
+		if err != nil {
+			dbg("WriteImage - Routine 31 A1", err)
+		}
 	}
 
 	// Make a 1024 byte buffer
@@ -284,6 +374,12 @@ func (d *Device) UploadBIN(calName string) error {
 		count++
 		bar.Increment()
 
+		// Already written, or in a sector left alone
+		if t != nil && count <= len(t.Blocks) || sectors != nil && !inSectors(imageStart+i, sectors) {
+			writeOffset -= 0x0400
+			continue
+		}
+
 		dbg(fmt.Sprintf("Requesting count %d", count), nil)
 
 		// Seek to new offset
@@ -293,14 +389,14 @@ func (d *Device) UploadBIN(calName string) error {
 		// Read 1024 bytes
 		n, err := f.Read(block)
 		if err != nil {
-			log("UploadBIN - Error reading calibration", err)
+			log("WriteImage - Error reading calibration", err)
 			return err
 		}
-		dbg(fmt.Sprintf("UploadBIN - reading %d bytes from offset: 0x%X", n, readOffset), nil)
+		dbg(fmt.Sprintf("WriteImage - reading %d bytes from offset: 0x%X", n, readOffset), nil)
 
 		// Get the destination addresses
 		//writeAddr := writeOffset + int(readOffset)
-		dbg(fmt.Sprintf("UploadBIN - writing %d bytes to offset 0x%X", n, writeOffset), nil)
+		dbg(fmt.Sprintf("WriteImage - writing %d bytes to offset 0x%X", n, writeOffset), nil)
 
 		// Append the Checksum
 		crc := uint16(0x0000)
@@ -311,7 +407,7 @@ func (d *Device) UploadBIN(calName string) error {
 		chkh := byte(crc >> 8)
 		chkl := byte(crc)
 
-		dbg(fmt.Sprintf("UploadBIN - appending checksum: 0x%X 0x%X", chkh, chkl), nil)
+		dbg(fmt.Sprintf("WriteImage - appending checksum: 0x%X 0x%X", chkh, chkl), nil)
 		blockChk := append(block, chkh, chkl)
 
 		// Upload the Calibration
@@ -320,6 +416,14 @@ func (d *Device) UploadBIN(calName string) error {
 			return err
 		}
 
+		// Only once the ECU has programmed it
+		if t != nil {
+			t.confirm(int(readOffset), block)
+			if err := t.save(file); err != nil {
+				return err
+			}
+		}
+
 		writeOffset -= 0x0400
 	}
 
@@ -328,7 +432,7 @@ func (d *Device) UploadBIN(calName string) error {
 	// Run Routine A3
 	err = d.RunRoutine([]byte{0x31, 0xA3, 0x1F, 0x3F}, []byte{0x32, 0xA3, 0x00}, []byte{0x22, 0x23})
 	if err != nil {
-		dbg("UploadBIN - Routine A3 [FAIL] [", err)
+		dbg("WriteImage - Routine A3 [FAIL] [", err)
 	}
 
 	return nil
@@ -410,11 +514,25 @@ func (d *Device) LocalIdDump(outfile string) error {
 }
 
 func (d *Device) DownloadBlock(start, length int) ([]byte, error) {
+	resp, err := d.downloadBlock(start, length)
+	if err != nil {
+		return []byte{}, err
+	}
+	if len(resp.Data) < length {
+		return nil, fmt.Errorf("Short block at 0x%06X, 0x%X of 0x%X bytes", start, len(resp.Data), length)
+	}
+
+	// Trim the data to proper size
+	return resp.Data[:length], nil
+}
+
+// Requests the block and returns the response with its frames
+func (d *Device) downloadBlock(start, length int) (Packet, error) {
 	if d.SecurityMode == false {
 		err := d.EnableSecurity()
 		if err != nil {
 			log("DownloadBlock - Unable to enter secutiy mode!", err)
-			return nil, err
+			return Packet{}, err
 		}
 	}
 
@@ -436,7 +554,7 @@ func (d *Device) DownloadBlock(start, length int) ([]byte, error) {
 	resp, err := d.Msg(downloadCommand)
 	if err != nil {
 		log("DownloadBlock [FAIL] [", err)
-		return []byte{}, err
+		return Packet{}, err
 	}
 
 	// Request Download Transfer Exit
@@ -444,14 +562,11 @@ func (d *Device) DownloadBlock(start, length int) ([]byte, error) {
 	_, err = d.Msg(exitCommand)
 	if err != nil {
 		log("DownloadBlock [FAIL] [", err)
-		return []byte{}, err
+		return Packet{}, err
 	}
 
-	// Trim the data to proper size
-	resp.Data = resp.Data[:length]
-
 	resp.DataAddr = start
-	return resp.Data, nil
+	return resp, nil
 }
 
 func (d *Device) UploadBlock(start, length int, block []byte) error {
@@ -463,6 +578,23 @@ func (d *Device) UploadBlock(start, length int, block []byte) error {
 		}
 	}
 
+	if err := d.transferBlock(start, length, block); err != nil {
+		return err
+	}
+
+	// Run Routine A2, the block isn't programmed until the ECU says so
+	err := d.runRoutine([]byte{0x31, 0xA2}, []byte{0x32, 0xA2, 0x00}, []byte{0x23}, d.Chip.programTime(length))
+	if err != nil {
+		dbg("UploadBlock - Routine A2 [FAIL] [", err)
+		return fmt.Errorf("Programming 0x%06X: %s", start, err)
+	}
+
+	return nil
+}
+
+// Sends a block to start with the upload by address request, its checksum after it, short
+// of programming it
+func (d *Device) transferBlock(start, length int, block []byte) error {
 	l1 := byte(length >> 8)
 	l2 := byte(length)
 
@@ -494,7 +626,7 @@ func (d *Device) UploadBlock(start, length int, block []byte) error {
 	}
 
 	// Send the block
-	for i := 0; i < length; i++ {
+	for i := 0; i < length && err == nil; i++ {
 		if i%6 == 0 {
 			end := i + 6
 			if end >= length {
@@ -503,7 +635,10 @@ func (d *Device) UploadBlock(start, length int, block []byte) error {
 
 			uploadBlock := append([]byte{0x36}, block[i:end]...)
 			if d.Dummy != true {
-				_, err = d.Msg(uploadBlock)
+				if _, err = d.Msg(uploadBlock); err != nil {
+					dbg("UploadBlock - Transfer Data - 36 [FAIL] [", err)
+					err = fmt.Errorf("Transferring 0x%06X: %s", start+i, err)
+				}
 			} else {
 				dbg(fmt.Sprintf("%X", uploadBlock), nil)
 			}
@@ -517,24 +652,26 @@ func (d *Device) UploadBlock(start, length int, block []byte) error {
 		dbg(fmt.Sprintf("Timeout default: %X", resp.Message), nil)
 
 	}
+	if err != nil {
+		return err
+	}
 
 	// Request Download/Upload Transfer Exit
 	exitCommand := []byte{0x37, 0x82}
 	_, err = d.Msg(exitCommand)
 	if err != nil {
 		dbg("UploadBlock - Request Transfer Exit - 37 82 [FAIL] [", err)
+		return fmt.Errorf("Transfer exit at 0x%06X: %s", start, err)
 	}
-
-	// Run Routine A2
-	err = d.RunRoutine([]byte{0x31, 0xA2}, []byte{0x32, 0xA2, 0x00}, []byte{0x23})
-	if err != nil {
-		dbg("UploadBlock - Routine A2 [FAIL] [", err)
-	}
-
 	return nil
 }
 
 func (d *Device) RunRoutine(start, stop, success []byte) error {
+	return d.runRoutine(start, stop, success, 0)
+}
+
+// Runs a routine, giving it wait after it starts before its stop request is polled
+func (d *Device) runRoutine(start, stop, success []byte, wait time.Duration) error {
 
 	if d.Dummy == true {
 		dbg(fmt.Sprintf("%X", start), nil)
@@ -567,9 +704,17 @@ func (d *Device) RunRoutine(start, stop, success []byte) error {
 		}
 	}
 
+	time.Sleep(wait)
+
 	// Stop Routine
 	for !done {
-		resp, _ := d.Msg(stop)
+		resp, err := d.Msg(stop)
+		if len(resp.Message) < 2 {
+			if err == nil {
+				err = fmt.Errorf("short answer %X", resp.Message)
+			}
+			return fmt.Errorf("Stop routine %X: %s", stop, err)
+		}
 
 		errCode := resp.Message[(len(resp.Message) - 2)]
 
@@ -646,51 +791,28 @@ func (d *Device) EnableSecurity() error {
 func (d Device) Send(packet Packet) Packet {
 
 	// Check for open connection
-	if d.serial == nil {
-		dbg("No serial connection!", nil)
+	if d.link == nil {
+		dbg("No adapter connection!", nil)
 		return Packet{}
 	}
 
-	var send string
-
-	// Issue command to device
-	if packet.Prepared == true {
-		send = string(packet.Message)
-		dbg("Sending]: ["+send, nil)
-	} else {
-		send = string(packet.Message)
-		dbg("Sending]: ["+send, nil)
-	}
-
-	_, err := d.serial.Write(append(packet.Message, []byte("\r")...))
-	if err != nil {
-		dbg("Error sending packet to serial device!", nil)
-	}
-
-	// Wait for our reply
-	return d.Receive()
-}
-
-func (d Device) Receive() Packet {
-
-	// Read OBD-II response, loop until a response is generated
-	reader := bufio.NewReader(d.serial)
-	reply, err := reader.ReadBytes(EOL)
-	reply = []byte(strings.Trim(string(reply[:]), "\r\n>"))
-	dbg("Received]: ["+string(reply), nil)
+	// Issue command to device, and wait for our reply
+	send := string(packet.Message)
+	dbg("Sending]: ["+send, nil)
+	lines, err := comm.Command(d.link, send)
+	reply := strings.Join(lines, "")
+	dbg("Received]: ["+reply, nil)
 
-	reply = []byte(strings.TrimSuffix(string(reply[:]), "<DATA ERROR"))
-
-	if (err != nil) || (string(reply) == "?") {
-		errMsg := errors.New("Unknown command")
-		return Packet{Error: errMsg}
+	// A frame with a bad checksum still carries the data
+	if comm.IsKind(err, comm.DataError) {
+		err = nil
 	}
-	resp := Packet{Message: reply}
 
-	strResp := string(resp.Message)
-	// Check for ERROR
-	if (strings.Contains(strResp, "?")) || (strings.Contains(strResp, "ERROR")) || (strings.Contains(strResp, "NO DATA")) {
-		resp.Error = errors.New("Response: [" + strResp + "]")
+	resp := Packet{Message: []byte(reply)}
+	if comm.IsKind(err, comm.UnknownCommand) {
+		return Packet{Error: errors.New("Unknown command")}
+	} else if err != nil {
+		resp.Error = errors.New("Response: [" + err.Error() + "]")
 		resp.Message = nil
 		resp.ErrCode = 0xFF
 	}
@@ -711,6 +833,22 @@ func New(test bool) *Device {
 	return device
 }
 
+// Attach returns a device that speaks through t, an adapter already set up or a mock
+func Attach(t comm.Transport) *Device {
+	return &Device{link: t}
+}
+
+// Detect looks for the adapter and connects to it, returning an error instead of
+// carrying on without one
+func Detect() (*Device, error) {
+	device := new(Device)
+	if !device.FindDevice() {
+		return nil, errors.New("No " + obdDevice + " or ELM327 adapter found")
+	}
+	device.ConnectDevice()
+	return device, nil
+}
+
 func (d Device) Cmd(cmd string) (string, error) {
 	command := Packet{Message: []byte(cmd)}
 	resp := d.Send(command)
@@ -838,56 +976,73 @@ func toHex(in []byte) []byte {
 }
 
 func (d *Device) ConnectDevice() {
+	elm := d.openAdapter()
 
+	// Wake the ECU, 5 baud init
+	if err := d.SlowInit(); err != nil {
+		log("Try turning the ignition to position 0 and then position 1 again.", nil)
+		crash.Fatal("ConnectDevice - Slow Init Failure", err)
+	}
+
+	if elm.STN != nil {
+		dbg("Adapter is an "+elm.STN.String(), nil)
+	}
+
+	// Only the ECU's answers to us, the memory reads don't wade through the rest of the bus
+	if err := elm.ReceiveFrom(testerAddr, ecuAddr); err != nil {
+		dbg("Unable to filter the bus", err)
+	}
+
+	// Faster for the big reads and writes, where the adapter can; the network and Bluetooth
+	// have no baud rate
+	if elm.Baud() != 0 {
+		baud, err := elm.SpeedUp()
+		if err != nil {
+			dbg(fmt.Sprintf("Staying at %d baud", baud), err)
+		}
+		d.baud = baud
+	}
+}
+
+// Opens the adapter and sets it up for ISO 9141-2, short of waking the ECU
+func (d *Device) openAdapter() *comm.ELM327 {
 	if len(d.location) < 1 {
 		d.FindDevice()
 	}
 
 	dbg("Setting up connection to device: "+d.location, nil)
-	//fmt.Printf("Creating new device... [%v %v]\n", d.location, d.baud)
-	config := &serial.Config{
-		Name: d.location,
-		Baud: d.baud,
-	}
 
-	var conn io.ReadWriteCloser
-
-	// Attempt to open serial connection
-	dbg("Opening serial connection to device: "+d.location, nil)
-	conn, err := serial.OpenPort(config)
+	// Attempt to open the serial port, or the connection to a WiFi adapter
+	dbg("Opening connection to device: "+d.location, nil)
+	elm, err := comm.Connect(d.location, d.baud)
 	if err != nil {
-		log("ConnectDevice - [FAIL", err)
-		os.Exit(1)
+		crash.Fatal("ConnectDevice - [FAIL", err)
 	}
 
 	// Create OBD-II connection
-	d.serial = conn
-
-	// AT D - Sets All Defaults
-	// AT E0 - Disable device echo
-	// AT L0 - Disable line feed
-	// AT S0 - Disable spaces
-	// AT AT2 - Enable faster responses
-	// AT SP 00 - Automatically select protocol
-	// AT H1 - Turns on headers
-	// AT L1 - Enables line feeds
-	// AT CA F1 - CAN Automatic Formatting on
+	d.link = elm
+
+	// AT SP 3 - ISO 9141-2
 	// AT AL - Allow Long Messages
+	// AT CAF0 - CAN Automatic Formatting off
+	// AT AT1 - Adaptive timing
 
-	// Run set of commands to properly setup our communication with the car
-	commands := []string{"AT D", "AT E0", "AT S0", "AT SP 3", "AT H1", "AT L0", "AT AL", "AT SI", "AT CAF0", "AT AT1"}
-	for _, c := range commands {
-		pkt := Packet{Message: []byte(c)}
-		resp := d.Send(pkt)
-		if resp.Error != nil {
-			dbg("Setup Command Failure: "+c, nil)
-			log("Try turning the ignition to position 0 and then position 1 again.", nil)
-			os.Exit(1)
-		}
+	// Reset the adapter and run set of commands to properly setup our communication with the car
+	if err := elm.Init("AT SP 3", "AT AL", "AT CAF0", "AT AT1"); err != nil {
+		log("Try turning the ignition to position 0 and then position 1 again.", nil)
+		crash.Fatal("ConnectDevice - Setup Command Failure", err)
 	}
+	return elm
 }
 
 func (d *Device) FindDevice() bool {
+	if Adapter != "" {
+		d.location = Adapter
+		d.baud = baud
+		dbg("Using Device: "+d.location, nil)
+		return true
+	}
+
 	contents, _ := ioutil.ReadDir("/dev")
 
 	// Look for what is mostly likely the Arduino device
@@ -899,11 +1054,22 @@ func (d *Device) FindDevice() bool {
 			return true
 		}
 	}
+
+	// Otherwise whatever ELM327 answers on the serial ports
+	if found := comm.Discover(); len(found) > 0 {
+		d.location = found[0].Port
+		d.baud = found[0].Baud
+		dbg("Found Device: "+found[0].String(), nil)
+		return true
+	}
 	return false
 }
 
 func (d *Device) DisconnectDevice() {
-
+	if d.link != nil {
+		d.link.Close()
+		d.link = nil
+	}
 }
 
 func contains(n byte, h []byte) bool {
@@ -0,0 +1,329 @@
+package iso9141
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/murdinc/ELMFlash/asm"
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+/*
+	Flashing kernels. The ECU's own routines program a block at a time behind a request,
+	a transfer exit and a routine started and polled for each one, and erase the whole
+	calibration whatever changed. A kernel is a small program of our own, assembled from
+	source with the asm package, written into the ECU's RAM with the upload by address
+	request (WriteMemory, checked with a ReadMemory of it) and started there, that takes
+	over the K-line and does the erasing and programming itself, a sector at a time and
+	with the data streamed to it unanswered.
+
+	The ECU definition's Kernel says where the source is, where it's assembled for and
+	the request that starts code at an address, the kernel's entry appended. Once it runs
+	the kernel speaks the same ISO 9141 framing, each command answered with the command
+	plus 0x40 or 7F, the command and an error code, like the ECU:
+
+		00              ping, answered 40, the kernel's version and the last erase's status
+		01 aa aa aa     set the address data goes to
+		02 dd ...       up to 6 bytes into the kernel's buffer, not answered
+		03 nn nn ss ss  program the n bytes buffered at the address, ss ss their 16 bit sum
+		04 aa aa aa     erase the sector at a, answered as it starts
+		05 aa aa aa nn nn nn    sum n bytes from a, answered 45 ss ss
+		06              reset the ECU, not answered
+
+	The kernel answers the run request itself, as the ECU would (78 for 38). An erase
+	takes longer than the adapter waits for an answer, so the kernel answers as it starts
+	and nothing until it's done, and is pinged after the chip's erase time until it
+	answers again, the ping saying whether the erase went through (00) or the chip's
+	status bits when it didn't. Addresses are the ones the ECU reads at; the kernel knows
+	its own bus. A flash through it is checked by the kernel summing each sector, not
+	read back, and the ECU is reset into the new image at the end.
+
+	definitions/protege-kernel.a96 is the Protege's, for its 80C196EA and 28F400.
+*/
+
+// KernelDef is where a flashing kernel comes from, in the ECU definition
+type KernelDef struct {
+	Source string // the assembler source, next to the definitions
+	Origin string // where it's assembled for when the source has no ORG
+	Entry  string // the label it starts at, "start" when not given
+	Run    string // the request that runs code at an address, the address (3 bytes) appended, hex
+	Buffer int    // bytes the kernel buffers before programming, 0x100 when not given
+}
+
+// Kernel commands, and the answer to each is the command plus 0x40
+const (
+	kernelPing    = 0x00
+	kernelAddress = 0x01
+	kernelData    = 0x02
+	kernelProgram = 0x03
+	kernelErase   = 0x04
+	kernelSum     = 0x05
+	kernelReset   = 0x06
+)
+
+// Data bytes in a kernel data frame
+const kernelFrame = 6
+
+// How many times a busy kernel is pinged, and the pause between
+const kernelPolls = 20
+const kernelPollDelay = 250 * time.Millisecond
+
+// The end of the ECU's RAM
+const ramEnd = 0x10000
+
+// Kernel is a flashing kernel running in the ECU's RAM
+type Kernel struct {
+	Start   int // where it was loaded
+	Entry   int
+	Version byte
+	buffer  int
+	d       *Device
+}
+
+// WriteMemory writes data to start with the upload by address request, in the blocks it
+// takes, and reads it back. What a last short block covers past the data is read first and
+// written back as it was.
+func (d *Device) WriteMemory(start int, data []byte) error {
+	if start < 0 || len(data) == 0 || start+len(data) > 0x1000000 {
+		return fmt.Errorf("Bad memory range 0x%X, 0x%X bytes", start, len(data))
+	}
+	if !d.SecurityMode {
+		if err := d.EnableSecurity(); err != nil {
+			return err
+		}
+	}
+
+	for adr := start; adr < start+len(data); {
+		size := blockSize(start + len(data) - adr)
+		n := size
+		if left := start + len(data) - adr; left < size {
+			n = left
+		}
+
+		block := data[adr-start : adr-start+n]
+		if n < size {
+			have, err := d.readBlock(adr, size)
+			if err != nil {
+				return err
+			}
+			block = append(append([]byte{}, block...), have[n:]...)
+		}
+
+		sum := imageSum(block)
+		if err := d.transferBlock(adr, size, append(append([]byte{}, block...), byte(sum>>8), byte(sum))); err != nil {
+			return fmt.Errorf("Writing 0x%06X: %s", adr, err)
+		}
+		adr += n
+	}
+
+	back, err := d.ReadMemory(start, len(data), nil)
+	if err != nil {
+		return fmt.Errorf("Reading back: %s", err)
+	}
+	for i := range data {
+		if back[i] != data[i] {
+			return fmt.Errorf("Read back differs at 0x%06X", start+i)
+		}
+	}
+	return nil
+}
+
+// LoadKernel assembles the ECU definition's kernel, writes it into RAM and starts it
+func (d *Device) LoadKernel() (*Kernel, error) {
+	if d.ECU == nil || d.ECU.Kernel == nil {
+		return nil, fmt.Errorf("No flashing kernel for this ECU")
+	}
+	def := d.ECU.Kernel
+
+	src, err := ioutil.ReadFile(filepath.Join("./definitions", def.Source))
+	if err != nil {
+		return nil, err
+	}
+	origin := 0
+	if def.Origin != "" {
+		if origin, err = disasm.ParseAddress(def.Origin); err != nil {
+			return nil, fmt.Errorf("Bad kernel origin %q: %s", def.Origin, err)
+		}
+	}
+	prog, err := asm.Assemble(string(src), origin)
+	if err != nil {
+		return nil, fmt.Errorf("Assembling the kernel: %s", err)
+	}
+	start, code := prog.Bytes(0x00)
+	if len(code) == 0 || start+len(code) > ramEnd {
+		return nil, fmt.Errorf("The kernel, 0x%X bytes at 0x%X, isn't in RAM", len(code), start)
+	}
+	entry := def.Entry
+	if entry == "" {
+		entry = "start"
+	}
+	adr, ok := prog.Labels[entry]
+	if !ok {
+		return nil, fmt.Errorf("The kernel has no %s label", entry)
+	}
+
+	log(fmt.Sprintf("Kernel - Writing 0x%X bytes to 0x%04X", len(code), start), nil)
+	if err := d.WriteMemory(start, code); err != nil {
+		return nil, err
+	}
+
+	run, _ := hex.DecodeString(def.Run)
+	if _, err := d.Msg(append(run, byte(adr>>16), byte(adr>>8), byte(adr))); err != nil {
+		return nil, fmt.Errorf("Starting the kernel at 0x%04X: %s", adr, err)
+	}
+
+	k := &Kernel{Start: start, Entry: adr, buffer: def.Buffer, d: d}
+	if k.buffer <= 0 {
+		k.buffer = 0x100
+	}
+	answer, err := k.command(kernelPing)
+	if err != nil {
+		return nil, fmt.Errorf("The kernel didn't answer: %s", err)
+	}
+	if len(answer) > 0 {
+		k.Version = answer[0]
+	}
+	log(fmt.Sprintf("Kernel - Running at 0x%04X, version %d", adr, k.Version), nil)
+	return k, nil
+}
+
+// Sends a command and returns what the answer carries after the command byte
+func (k *Kernel) command(cmd byte, params ...byte) ([]byte, error) {
+	resp, err := k.d.Msg(append([]byte{cmd}, params...))
+	if err != nil {
+		return nil, err
+	}
+
+	// The answer, then the checksum
+	m := resp.Message
+	if len(m) < 2 || m[0] != cmd+0x40 {
+		return nil, fmt.Errorf("Kernel answered %02X with %X", cmd, m)
+	}
+	return m[1 : len(m)-1], nil
+}
+
+// Sends a command the kernel doesn't answer
+func (k *Kernel) send(cmd byte, params ...byte) error {
+	p := Packet{Message: []byte(toString(append([]byte{cmd}, params...)))}
+	p.prepare()
+	if !bytes.Equal(p.Header, k.d.lastHeader) {
+		if resp := k.d.Send(Packet{Message: append([]byte("AT SH"), p.Header...)}); resp.Error != nil {
+			return resp.Error
+		}
+		k.d.lastHeader = p.Header
+	}
+	return k.d.Send(p).Error
+}
+
+// Erase erases the sector at s, waiting until the kernel is done
+func (k *Kernel) Erase(s Sector) error {
+	if _, err := k.command(kernelErase, byte(s.Start>>16), byte(s.Start>>8), byte(s.Start)); err != nil {
+		return err
+	}
+	time.Sleep(k.d.Chip.eraseTime(1))
+
+	var err error
+	for try := 0; try < kernelPolls; try++ {
+		var answer []byte
+		if answer, err = k.command(kernelPing); err == nil {
+			if len(answer) > 1 && answer[1] != 0 {
+				return fmt.Errorf("The erase failed, status %02X", answer[1])
+			}
+			return nil
+		}
+		time.Sleep(kernelPollDelay)
+	}
+	return fmt.Errorf("The kernel didn't come back from the erase: %s", err)
+}
+
+// Program programs data at adr, a buffer at a time, the data streamed to the kernel with
+// the adapter not waiting for answers
+func (k *Kernel) Program(adr int, data []byte) error {
+	for off := 0; off < len(data); off += k.buffer {
+		end := off + k.buffer
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[off:end]
+		at := adr + off
+
+		if _, err := k.command(kernelAddress, byte(at>>16), byte(at>>8), byte(at)); err != nil {
+			return err
+		}
+		if _, err := k.d.Cmd("AT R0"); err != nil {
+			return err
+		}
+		for i := 0; i < len(chunk); i += kernelFrame {
+			j := i + kernelFrame
+			if j > len(chunk) {
+				j = len(chunk)
+			}
+			if err := k.send(kernelData, chunk[i:j]...); err != nil {
+				k.d.Cmd("AT R1")
+				return err
+			}
+		}
+		if _, err := k.d.Cmd("AT R1"); err != nil {
+			return err
+		}
+
+		sum := imageSum(chunk)
+		if _, err := k.command(kernelProgram, byte(len(chunk)>>8), byte(len(chunk)), byte(sum>>8), byte(sum)); err != nil {
+			return fmt.Errorf("Programming 0x%06X: %s", at, err)
+		}
+	}
+	return nil
+}
+
+// Sum is the 16 bit sum of n bytes from adr, worked out by the kernel
+func (k *Kernel) Sum(adr, n int) (uint16, error) {
+	answer, err := k.command(kernelSum, byte(adr>>16), byte(adr>>8), byte(adr), byte(n>>16), byte(n>>8), byte(n))
+	if err != nil {
+		return 0, err
+	}
+	if len(answer) < 2 {
+		return 0, fmt.Errorf("Short sum %X", answer)
+	}
+	return uint16(answer[0])<<8 | uint16(answer[1]), nil
+}
+
+// Reset restarts the ECU, into whatever the flash holds
+func (k *Kernel) Reset() error {
+	k.d.lastHeader = nil
+	k.d.SecurityMode = false
+	if _, err := k.d.Cmd("AT R0"); err != nil {
+		return err
+	}
+	err := k.send(kernelReset)
+	k.d.Cmd("AT R1")
+	return err
+}
+
+// Flash erases and programs the sectors from image, then has the kernel sum each one
+func (k *Kernel) Flash(image []byte, sectors []Sector) error {
+	for _, s := range sectors {
+		log(fmt.Sprintf("Kernel - Erasing 0x%06X-0x%06X", s.Start, s.Start+s.Length-1), nil)
+		began := time.Now()
+		if err := k.Erase(s); err != nil {
+			return fmt.Errorf("Erasing 0x%06X: %s", s.Start, err)
+		}
+
+		data := image[s.Start-imageStart : s.Start-imageStart+s.Length]
+		if err := k.Program(s.Start, data); err != nil {
+			return err
+		}
+		sum, err := k.Sum(s.Start, s.Length)
+		if err != nil {
+			return err
+		}
+		if want := imageSum(data); sum != want {
+			return fmt.Errorf("Sector 0x%06X sums to 0x%04X, the image to 0x%04X", s.Start, sum, want)
+		}
+		log(fmt.Sprintf("Kernel - 0x%06X done in %s", s.Start, time.Since(began)), nil)
+	}
+	return nil
+}
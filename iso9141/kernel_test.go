@@ -0,0 +1,120 @@
+package iso9141
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/murdinc/ELMFlash/asm"
+	"github.com/murdinc/ELMFlash/comm"
+)
+
+// The Protege's definition names a kernel that assembles into RAM
+func TestProtegeKernel(t *testing.T) {
+	data, err := ioutil.ReadFile("../definitions/protege.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var def ECUDef
+	if err := json.Unmarshal(data, &def); err != nil {
+		t.Fatal(err)
+	}
+	if def.Kernel == nil {
+		t.Fatal("protege.json has no kernel")
+	}
+
+	src, err := ioutil.ReadFile(filepath.Join("../definitions", def.Kernel.Source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := asm.Assemble(string(src), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, code := prog.Bytes(0x00)
+	if len(code) == 0 || start < 0x400 || start+len(code) > 0x1000 {
+		t.Errorf("0x%X bytes at 0x%X, not in the code RAM", len(code), start)
+	}
+	if adr, ok := prog.Labels[def.Kernel.Entry]; !ok || adr != start {
+		t.Errorf("Entry %s at 0x%X, loaded at 0x%X", def.Kernel.Entry, adr, start)
+	}
+}
+
+// Where cmd was sent, -1 when it wasn't
+func sentAt(m *comm.Mock, cmd string) int {
+	for i, s := range m.Sent {
+		if strings.EqualFold(s, cmd) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestKernelExchange(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("04120000", "40F5104400")
+	m.Reply("00", "60F51040010000")
+	m.Reply("01120000", "40F5104100")
+	m.Reply("02010203040506", "OK")
+	m.Reply("0207080910", "OK")
+	m.Reply("03000a003d", "40F5104300")
+	m.Reply("0512000000000a", "60F51045003D0000")
+
+	d := Attach(m)
+	d.SecurityMode = true
+	k := &Kernel{buffer: 0x100, d: d}
+
+	if err := k.Erase(Sector{Start: 0x120000, Length: 10}); err != nil {
+		t.Fatalf("Erase: %s", err)
+	}
+
+	data := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x10}
+	if err := k.Program(0x120000, data); err != nil {
+		t.Fatalf("Program: %s", err)
+	}
+
+	// The data goes out in order with the adapter not waiting for answers, then the
+	// program command with its count and sum
+	order := []string{"01120000", "AT R0", "02010203040506", "0207080910", "AT R1", "03000a003d"}
+	last := -1
+	for _, cmd := range order {
+		i := sentAt(m, cmd)
+		if i <= last {
+			t.Fatalf("%s sent at %d, out of order in %v", cmd, i, m.Sent)
+		}
+		last = i
+	}
+
+	sum, err := k.Sum(0x120000, len(data))
+	if err != nil {
+		t.Fatalf("Sum: %s", err)
+	}
+	if want := imageSum(data); sum != want {
+		t.Errorf("Sum 0x%04X, want 0x%04X", sum, want)
+	}
+}
+
+func TestKernelFailures(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("04120000", "40F5104400")
+	m.Reply("00", "60F51040012800")
+	m.Reply("01120000", "40F5104100")
+	m.Reply("02aabb", "OK")
+	m.Reply("0300020165", "50F5107F037700")
+
+	d := Attach(m)
+	d.SecurityMode = true
+	k := &Kernel{buffer: 0x100, d: d}
+
+	// The ping after the erase carries the chip's error bits
+	if err := k.Erase(Sector{Start: 0x120000, Length: 2}); err == nil || !strings.Contains(err.Error(), "28") {
+		t.Errorf("Erase with status 28: %v", err)
+	}
+
+	// A refused program command is an error, not a programmed buffer
+	if err := k.Program(0x120000, []byte{0xAA, 0xBB}); err == nil {
+		t.Error("Program refused by the kernel returned nil")
+	}
+}
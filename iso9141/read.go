@@ -0,0 +1,145 @@
+package iso9141
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+	Memory reads. ReadMemory reads any span of the ECU's memory with the download by
+	address request (35 82), which only takes blocks of 0x100, 0x200 or 0x400 bytes: the
+	span is cut into the biggest of those that fit, the last one read whole and trimmed.
+	A block that fails is asked for again, a few times with a pause between, before the
+	read gives up. What comes back is checked before it's kept: it can't be short of the
+	length asked for, and the byte ahead of each frame's data has to be the same in every
+	frame or count up by one, so a frame the adapter dropped or repeated is caught and the
+	block read again rather than shifting the rest of the image.
+
+	Progress goes to a callback after every block: the bytes done, the rate since the
+	start and the time left at that rate.
+*/
+
+// Block sizes the download request takes, biggest first
+var blockSizes = []int{0x400, 0x200, 0x100}
+
+// How many times a block is read before the read gives up, and the pause between
+const readTries = 4
+const retryDelay = 500 * time.Millisecond
+
+// Progress is how far a read has got
+type Progress struct {
+	Done    int           // bytes read
+	Total   int           // bytes to read
+	Rate    float64       // bytes a second
+	ETA     time.Duration // time left at Rate
+	Retries int           // blocks read again
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("0x%X of 0x%X bytes, %.0f bytes/s, %s left, %d retries", p.Done, p.Total, p.Rate, p.ETA, p.Retries)
+}
+
+// ReadMemory reads length bytes from start, calling progress after each block if it isn't
+// nil
+func (d *Device) ReadMemory(start, length int, progress func(Progress)) ([]byte, error) {
+	out := make([]byte, 0, length)
+	err := d.readMemory(start, length, func(adr int, block []byte) error {
+		out = append(out, block...)
+		return nil
+	}, progress)
+	return out, err
+}
+
+// Reads length bytes from start a block at a time, handing each to each as it comes
+func (d *Device) readMemory(start, length int, each func(adr int, block []byte) error, progress func(Progress)) error {
+	if start < 0 || length <= 0 || start+length > 0x1000000 {
+		return fmt.Errorf("Bad memory range 0x%X, 0x%X bytes", start, length)
+	}
+
+	p := Progress{Total: length}
+	began := time.Now()
+	for adr := start; adr < start+length; {
+		size := blockSize(start + length - adr)
+
+		var block []byte
+		var err error
+		for try := 0; try < readTries; try++ {
+			if try > 0 {
+				p.Retries++
+				dbg(fmt.Sprintf("ReadMemory - Reading 0x%06X again", adr), err)
+				time.Sleep(retryDelay)
+			}
+			if block, err = d.readBlock(adr, size); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Block at 0x%06X failed %d times: %s", adr, readTries, err)
+		}
+
+		if n := start + length - adr; n < size {
+			block = block[:n]
+		}
+		if err := each(adr, block); err != nil {
+			return err
+		}
+		adr += len(block)
+
+		p.Done = adr - start
+		if secs := time.Since(began).Seconds(); secs > 0 {
+			p.Rate = float64(p.Done) / secs
+			p.ETA = time.Duration(float64(p.Total-p.Done) / p.Rate * float64(time.Second))
+		}
+		if progress != nil {
+			progress(p)
+		}
+	}
+	return nil
+}
+
+// The block to read for what's left, the biggest that fits or the smallest there is
+func blockSize(left int) int {
+	for _, s := range blockSizes {
+		if s <= left {
+			return s
+		}
+	}
+	return blockSizes[len(blockSizes)-1]
+}
+
+// Reads one block and checks it came back whole and in order
+func (d *Device) readBlock(adr, size int) ([]byte, error) {
+	resp, err := d.downloadBlock(adr, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) < size {
+		return nil, fmt.Errorf("Short block at 0x%06X, 0x%X of 0x%X bytes", adr, len(resp.Data), size)
+	}
+	if err := checkSequence(resp.Multi); err != nil {
+		return nil, fmt.Errorf("Block at 0x%06X: %s", adr, err)
+	}
+	return resp.Data[:size], nil
+}
+
+// The byte ahead of each frame's data is the same in every frame, or counts up by one
+func checkSequence(frames []Packet) error {
+	if len(frames) < 2 {
+		return nil
+	}
+	for i, f := range frames {
+		if len(f.Message) == 0 {
+			return fmt.Errorf("Empty frame %d", i)
+		}
+	}
+	first := frames[0].Message[0]
+	same, counting := true, true
+	for i, f := range frames {
+		same = same && f.Message[0] == first
+		counting = counting && f.Message[0] == first+byte(i)
+	}
+	if !same && !counting {
+		return fmt.Errorf("Frames out of sequence")
+	}
+	return nil
+}
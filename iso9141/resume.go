@@ -0,0 +1,180 @@
+package iso9141
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+)
+
+/*
+	Resuming. A full read or write is minutes on the K-line, and a Bluetooth link that
+	drops in the middle of one shouldn't mean starting again. What's been confirmed is
+	kept in a transfer file next to the file being read into or written from
+	(ROM.BIN.transfer), saved after every block: the span, and each block done with the
+	CRC of its data. Run again, a read checks the blocks already in its file against their
+	CRCs and carries on after the last good one; a write checks the image is the one it
+	was writing, by its CRC, and carries on after the last block the ECU took, without
+	erasing again. The transfer file goes once the transfer is through.
+*/
+
+// Transfer is how far a read or write has got
+type Transfer struct {
+	Kind   string // read or write
+	Start  int    // reads: the first address
+	Length int    // bytes in all
+	Image  uint32 // writes: the image's CRC
+	Blocks []TransferBlock
+}
+
+// TransferBlock is a block confirmed, by its offset into the file
+type TransferBlock struct {
+	Offset int
+	Length int
+	CRC    uint32
+}
+
+// The transfer file for file
+func transferPath(file string) string {
+	return file + ".transfer"
+}
+
+// Reads the transfer file for file, nil if there isn't one
+func loadTransfer(file string) (*Transfer, error) {
+	data, err := ioutil.ReadFile(transferPath(file))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := new(Transfer)
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("%s: %s", transferPath(file), err)
+	}
+	return t, nil
+}
+
+// Writes the transfer file for file, whole or not at all
+func (t *Transfer) save(file string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := transferPath(file) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, transferPath(file))
+}
+
+// Records the block at offset as done
+func (t *Transfer) confirm(offset int, data []byte) {
+	t.Blocks = append(t.Blocks, TransferBlock{Offset: offset, Length: len(data), CRC: crc32.ChecksumIEEE(data)})
+}
+
+// Done is the bytes confirmed
+func (t *Transfer) Done() int {
+	n := 0
+	for _, b := range t.Blocks {
+		n += b.Length
+	}
+	return n
+}
+
+// Drops the blocks from the first one data doesn't hold with the same CRC
+func (t *Transfer) verify(data []byte) {
+	for i, b := range t.Blocks {
+		if b.Offset+b.Length > len(data) || crc32.ChecksumIEEE(data[b.Offset:b.Offset+b.Length]) != b.CRC {
+			t.Blocks = t.Blocks[:i]
+			return
+		}
+	}
+}
+
+// ReadMemoryTo reads length bytes from start into file, carrying on from where a read
+// into the same file was interrupted
+func (d *Device) ReadMemoryTo(file string, start, length int, progress func(Progress)) error {
+	t, err := loadTransfer(file)
+	if err != nil {
+		return err
+	}
+	if t != nil && (t.Kind != "read" || t.Start != start || t.Length != length) {
+		log(fmt.Sprintf("ReadMemory - %s is from another transfer, starting again", transferPath(file)), nil)
+		t = nil
+	}
+
+	if t != nil {
+		have, _ := ioutil.ReadFile(file)
+		t.verify(have)
+		log(fmt.Sprintf("ReadMemory - Resuming at 0x%06X, 0x%X of 0x%X bytes already read", start+t.Done(), t.Done(), length), nil)
+	} else {
+		t = &Transfer{Kind: "read", Start: start, Length: length}
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(t.Done())); err != nil {
+		return err
+	}
+
+	err = d.readMemory(start+t.Done(), length-t.Done(), func(adr int, block []byte) error {
+		offset := adr - start
+		if _, err := f.WriteAt(block, int64(offset)); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		t.confirm(offset, block)
+		return t.save(file)
+	}, func(p Progress) {
+		if progress != nil {
+			p.Done += length - p.Total
+			p.Total = length
+			progress(p)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return os.Remove(transferPath(file))
+}
+
+// ResumeWrite writes the image in file like WriteImage, carrying on from where a write of
+// the same image was interrupted
+func (d *Device) ResumeWrite(file string) error {
+	image, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	sum := crc32.ChecksumIEEE(image)
+
+	t, err := loadTransfer(file)
+	if err != nil {
+		return err
+	}
+	if t != nil && (t.Kind != "write" || t.Image != sum) {
+		return fmt.Errorf("%s is from a write of another image, the ECU was erased for that one; remove it to write this one from the start", transferPath(file))
+	}
+	if t != nil {
+		log(fmt.Sprintf("WriteImage - Resuming after %d of 480 blocks", len(t.Blocks)), nil)
+	} else {
+		t = &Transfer{Kind: "write", Length: len(image), Image: sum}
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := d.writeImage(f, t, file, nil); err != nil {
+		return err
+	}
+	return os.Remove(transferPath(file))
+}
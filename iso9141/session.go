@@ -0,0 +1,101 @@
+package iso9141
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+	Sessions. An ISO 9141-2 ECU is woken with an address sent at 5 baud (the slow init),
+	answers with a sync byte and two key bytes that say how it talks, 08 08 or 94 94 for
+	ISO 9141-2, and drops the session again after P3max (5 seconds) without a request. The
+	adapter does the slow init (AT IIA for the address, AT SI to start it) and reports the
+	key bytes (AT KW), which SlowInit reads back and checks; the ones a non-OBD ECU may use
+	are only warned about, the adapter takes any (AT KW0). Between requests the adapter
+	sends a wakeup message on its own when the bus has been quiet (AT SW, AT WM), here the
+	ECU ID request, so a long read or a wait at a prompt doesn't lose the session.
+*/
+
+// The address the slow init is sent to, OBD-II's functional address
+const initAddr = 0x33
+
+// How often the adapter wakes the ECU when the bus is quiet, well inside P3max
+const keepAliveInterval = 2 * time.Second
+
+// The key bytes of an ISO 9141-2 ECU
+var isoKeyBytes = [][2]byte{{0x08, 0x08}, {0x94, 0x94}}
+
+// SlowInit wakes the ECU with the 5 baud init, reads its key bytes into KeyBytes and sets
+// the adapter to keep the session up
+func (d *Device) SlowInit() error {
+	if err := d.slowInit(initAddr); err != nil {
+		return err
+	}
+	return d.KeepAlive(keepAliveInterval)
+}
+
+// Sends the 5 baud init to addr and reads the key bytes into KeyBytes
+func (d *Device) slowInit(addr int) error {
+	if d.link == nil {
+		return errors.New("No adapter connection")
+	}
+	for _, cmd := range []string{fmt.Sprintf("AT IIA %02X", addr), "AT KW0", "AT SI"} {
+		if _, err := d.Cmd(cmd); err != nil {
+			return err
+		}
+	}
+
+	// "1:08 2:08"
+	kw, err := d.Cmd("AT KW")
+	if err != nil {
+		return err
+	}
+	var kb [2]byte
+	if _, err := fmt.Sscanf(kw, "1:%02X 2:%02X", &kb[0], &kb[1]); err != nil {
+		return fmt.Errorf("Unable to read the key bytes from [%s]", kw)
+	}
+	d.KeyBytes = kb[:]
+	dbg(fmt.Sprintf("Key bytes %02X %02X", kb[0], kb[1]), nil)
+
+	iso := false
+	for _, k := range isoKeyBytes {
+		if kb == k {
+			iso = true
+		}
+	}
+	if !iso {
+		log(fmt.Sprintf("SlowInit - Key bytes %02X %02X aren't ISO 9141-2's, carrying on", kb[0], kb[1]), nil)
+	}
+	return nil
+}
+
+// KeepAlive has the adapter send the ECU ID request whenever the bus has been quiet for
+// every, up to about 5 seconds
+func (d *Device) KeepAlive(every time.Duration) error {
+	sw := int(every / (20 * time.Millisecond))
+	if sw < 1 || sw > 0xFF {
+		return fmt.Errorf("Keepalive every %s, the adapter takes 20ms to 5.1s", every)
+	}
+
+	wake := Packet{Message: []byte(toString([]byte{0x10}))}
+	wake.prepare()
+	for _, cmd := range []string{fmt.Sprintf("AT WM %s%s", wake.Header, wake.Message), fmt.Sprintf("AT SW %02X", sw)} {
+		if _, err := d.Cmd(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rewake starts again with an ECU that has restarted, an ignition cycle or another ECU
+// swapped in: the session and the security access went with it, so it's woken again and
+// unlocked when it's next asked for something that needs it
+func (d *Device) Rewake() error {
+	d.SecurityMode = false
+	d.lastHeader = nil
+	if d.link == nil {
+		return nil
+	}
+	return d.SlowInit()
+}
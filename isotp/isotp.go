@@ -0,0 +1,274 @@
+package isotp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/murdinc/ELMFlash/comm"
+)
+
+/*
+	ISO-TP (ISO 15765-2), the transport the diagnostic services take on CAN (ISO 15765-4).
+	A CAN frame carries 8 bytes, so a message of up to 7 goes in a single frame and a longer
+	one, a memory read's answer or a block of a flash transfer, is split into a first frame
+	with its length and consecutive frames numbered 1 to F and round again, the receiver
+	saying how many to send at a time (the block size, 0 for all) and how long to leave
+	between them (STmin) with a flow control frame.
+
+	The adapter's own ISO-TP handling stops at what OBD-II needs, so it's done here with
+	the adapter's formatting off (AT CAF0): every frame goes out as its 8 bytes, with the
+	tester's ID set as the header and only the ECU's ID let through. Sending, the frames
+	with nothing to wait for go out with the adapter's responses off (AT R0), so each is a
+	command and no timeout; the last of a block waits for the ECU's flow control, the last
+	of the message for its answer. Receiving, the first frame's answer is a flow control
+	frame from us, and the adapter hands back the consecutive frames that came after it,
+	up to the block size.
+
+	Only 11-bit IDs are done, 7E0 to the engine and 7E8 back on most cars. The protocol is
+	set up by the caller, AT SP 6 for 500Kbaud.
+*/
+
+// Frame types, the top nibble of the first byte
+const (
+	singleFrame      = 0x0
+	firstFrame       = 0x1
+	consecutiveFrame = 0x2
+	flowControl      = 0x3
+)
+
+// Flow control statuses
+const (
+	continueToSend = 0x0
+	wait           = 0x1
+	overflow       = 0x2
+)
+
+// The longest message a first frame's length takes
+const maxLength = 0xFFF
+
+// Conn is an ISO-TP link to one ECU through an adapter
+type Conn struct {
+	TxID      uint32 // the ECU's request ID, 0x7E0 say
+	RxID      uint32 // its answers, 0x7E8 say
+	BlockSize byte   // how many consecutive frames the ECU sends before waiting for us, 0 for all
+	STmin     byte   // how long the ECU leaves between them, in the flow control encoding
+	Pad       bool   // whether frames are padded to 8 bytes, as most ECUs want
+	PadByte   byte
+	link      comm.Transport
+	quiet     bool // the adapter's responses are off
+}
+
+// New returns a link to the ECU at txID answering on rxID through link, with frames
+// padded and the ECU sending 8 consecutive frames at a time as fast as it can; the
+// adapter still has to be set up with Setup
+func New(link comm.Transport, txID, rxID uint32) *Conn {
+	return &Conn{TxID: txID, RxID: rxID, BlockSize: 8, Pad: true, PadByte: 0x00, link: link}
+}
+
+// Setup turns the adapter's formatting off and points it at the ECU
+func (c *Conn) Setup() error {
+	if c.TxID > 0x7FF || c.RxID > 0x7FF {
+		return fmt.Errorf("Only 11-bit IDs, not %X and %X", c.TxID, c.RxID)
+	}
+	for _, cmd := range []string{"AT CAF0", fmt.Sprintf("AT SH %03X", c.TxID), fmt.Sprintf("AT CRA %03X", c.RxID), "AT R1"} {
+		if _, err := comm.Command(c.link, cmd); err != nil {
+			return err
+		}
+	}
+	c.quiet = false
+	return nil
+}
+
+// Request sends msg to the ECU and returns its answer
+func (c *Conn) Request(msg []byte) ([]byte, error) {
+	lines, err := c.send(msg)
+	if err != nil {
+		return nil, err
+	}
+	return c.receive(lines)
+}
+
+// Sends msg, returning what came back after its last frame
+func (c *Conn) send(msg []byte) ([]string, error) {
+	switch {
+	case len(msg) == 0:
+		return nil, errors.New("Nothing to send")
+	case len(msg) > maxLength:
+		return nil, fmt.Errorf("Message of %d bytes, ISO-TP takes %d", len(msg), maxLength)
+	case len(msg) <= 7:
+		return c.command(append([]byte{singleFrame<<4 | byte(len(msg))}, msg...))
+	}
+
+	lines, err := c.command(append([]byte{firstFrame<<4 | byte(len(msg)>>8), byte(len(msg))}, msg[:6]...))
+	if err != nil {
+		return nil, err
+	}
+	bs, st, err := c.flowControl(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	rest, seq, sent := msg[6:], byte(1), 0
+	for len(rest) > 0 {
+		n := 7
+		if len(rest) < n {
+			n = len(rest)
+		}
+		sent++
+		last := n == len(rest) || (bs != 0 && sent == int(bs))
+
+		// Nothing comes back before the end of a block
+		if err := c.setQuiet(!last); err != nil {
+			return nil, err
+		}
+		time.Sleep(st)
+		lines, err = c.command(append([]byte{consecutiveFrame<<4 | seq}, rest[:n]...))
+		if err != nil {
+			return nil, err
+		}
+		rest, seq = rest[n:], (seq+1)&0x0F
+
+		if last && len(rest) > 0 {
+			if bs, st, err = c.flowControl(lines); err != nil {
+				return nil, err
+			}
+			sent = 0
+		}
+	}
+	return lines, nil
+}
+
+// Reassembles the answer that starts in lines, sending flow control for the rest of it
+func (c *Conn) receive(lines []string) ([]byte, error) {
+	frames := c.frames(lines)
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("No answer from %03X", c.RxID)
+	}
+	f := frames[0]
+	switch f[0] >> 4 {
+	case singleFrame:
+		n := int(f[0] & 0x0F)
+		if n == 0 || n > len(f)-1 {
+			return nil, fmt.Errorf("Bad single frame %X", f)
+		}
+		return f[1 : 1+n], nil
+	case firstFrame:
+	default:
+		return nil, fmt.Errorf("Answer starts with a frame that isn't a first frame, %X", f)
+	}
+	if len(f) < 8 {
+		return nil, fmt.Errorf("Short first frame %X", f)
+	}
+
+	length := int(f[0]&0x0F)<<8 | int(f[1])
+	data := append([]byte{}, f[2:]...)
+	rest, seq, got := frames[1:], byte(1), 0
+	for len(data) < length {
+		if len(rest) == 0 {
+			if got != 0 && (c.BlockSize == 0 || got != int(c.BlockSize)) {
+				return nil, fmt.Errorf("Answer stopped after %d of %d bytes", len(data), length)
+			}
+			if err := c.setQuiet(false); err != nil {
+				return nil, err
+			}
+			fc := []byte{flowControl<<4 | continueToSend, c.BlockSize, c.STmin}
+			lines, err := c.command(fc)
+			if err != nil {
+				return nil, err
+			}
+			if rest = c.frames(lines); len(rest) == 0 {
+				return nil, fmt.Errorf("Answer stopped after %d of %d bytes", len(data), length)
+			}
+			got = 0
+		}
+
+		cf := rest[0]
+		rest = rest[1:]
+		if cf[0]>>4 != consecutiveFrame || cf[0]&0x0F != seq {
+			return nil, fmt.Errorf("Expected consecutive frame %X, got %X", seq, cf)
+		}
+		data = append(data, cf[1:]...)
+		seq, got = (seq+1)&0x0F, got+1
+	}
+	return data[:length], nil
+}
+
+// Finds the ECU's flow control in lines, skipping its waits, returning its block size and
+// STmin
+func (c *Conn) flowControl(lines []string) (byte, time.Duration, error) {
+	for _, f := range c.frames(lines) {
+		if f[0]>>4 != flowControl || len(f) < 3 {
+			continue
+		}
+		switch f[0] & 0x0F {
+		case continueToSend:
+			return f[1], separation(f[2]), nil
+		case wait:
+			continue
+		case overflow:
+			return 0, 0, errors.New("The ECU can't take a message that long")
+		}
+	}
+	return 0, 0, fmt.Errorf("No flow control from %03X", c.RxID)
+}
+
+// Turns the adapter's responses off, or back on
+func (c *Conn) setQuiet(quiet bool) error {
+	if quiet == c.quiet {
+		return nil
+	}
+	cmd := "AT R1"
+	if quiet {
+		cmd = "AT R0"
+	}
+	if _, err := comm.Command(c.link, cmd); err != nil {
+		return err
+	}
+	c.quiet = quiet
+	return nil
+}
+
+// Sends one frame, padded, and returns what came back. No data is no frames.
+func (c *Conn) command(frame []byte) ([]string, error) {
+	for c.Pad && len(frame) < 8 {
+		frame = append(frame, c.PadByte)
+	}
+	lines, err := comm.Command(c.link, strings.ToUpper(hex.EncodeToString(frame)))
+	if comm.IsKind(err, comm.NoData) {
+		err = nil
+	}
+	return lines, err
+}
+
+// The frames from the ECU in lines, an ID and its data bytes a line with headers on
+func (c *Conn) frames(lines []string) [][]byte {
+	id := fmt.Sprintf("%03X", c.RxID)
+	var frames [][]byte
+	for _, line := range lines {
+		line = strings.Replace(line, " ", "", -1)
+		if !strings.HasPrefix(strings.ToUpper(line), id) {
+			continue
+		}
+		f, err := hex.DecodeString(line[len(id):])
+		if err != nil || len(f) == 0 {
+			continue
+		}
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// The time an STmin byte stands for: 0 to 127ms, or 100 to 900us from F1 to F9; the
+// reserved values are taken as the longest
+func separation(st byte) time.Duration {
+	switch {
+	case st <= 0x7F:
+		return time.Duration(st) * time.Millisecond
+	case st >= 0xF1 && st <= 0xF9:
+		return time.Duration(st-0xF0) * 100 * time.Microsecond
+	}
+	return 0x7F * time.Millisecond
+}
@@ -0,0 +1,154 @@
+package isotp
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/murdinc/ELMFlash/comm"
+)
+
+// A link to 7E0 through m, set up, with the setup commands forgotten
+func setup(t *testing.T, m *comm.Mock) *Conn {
+	c := New(m, 0x7E0, 0x7E8)
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	m.Sent = nil
+	return c
+}
+
+func TestSingleFrame(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("023E010000000000", "7E8 02 7E 01 00 00 00 00 00")
+	c := setup(t, m)
+
+	answer, err := c.Request([]byte{0x3E, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(answer, []byte{0x7E, 0x01}) {
+		t.Errorf("Answer %X", answer)
+	}
+}
+
+// A 27 byte request goes as a first frame and three consecutive frames, the ECU taking
+// two at a time 10ms apart and then the rest. Only the last frame of each block waits
+// for an answer.
+func TestSegmentation(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("101B360102030405", "7E8 30 02 0A 00 00 00 00 00")
+	m.Reply("21060708090A0B0C")
+	m.Reply("220D0E0F10111213", "7E8 30 00 00 00 00 00 00 00")
+	m.Reply("231415161718191A", "7E8 01 76 00 00 00 00 00 00")
+	c := setup(t, m)
+
+	msg := []byte{0x36}
+	for b := byte(1); b <= 0x1A; b++ {
+		msg = append(msg, b)
+	}
+	start := time.Now()
+	answer, err := c.Request(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(answer, []byte{0x76}) {
+		t.Errorf("Answer %X", answer)
+	}
+	if took := time.Since(start); took < 20*time.Millisecond {
+		t.Errorf("Frames sent in %s, STmin is 10ms", took)
+	}
+
+	sent := []string{"101B360102030405", "AT R0", "21060708090A0B0C", "AT R1", "220D0E0F10111213", "231415161718191A"}
+	if !reflect.DeepEqual(m.Sent, sent) {
+		t.Errorf("Sent %q, expected %q", m.Sent, sent)
+	}
+}
+
+// The ECU saying wait holds the frames back until it says continue, and overflow ends
+// the request
+func TestFlowControlWait(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("100834010203040A", "7E8 31 00 00 00 00 00 00 00", "7E8 30 00 00 00 00 00 00 00")
+	m.Reply("210B000000000000", "7E8 02 74 20 00 00 00 00 00")
+	c := setup(t, m)
+
+	answer, err := c.Request([]byte{0x34, 0x01, 0x02, 0x03, 0x04, 0x0A, 0x0B, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(answer, []byte{0x74, 0x20}) {
+		t.Errorf("Answer %X", answer)
+	}
+
+	m.Reply("100834010203040C", "7E8 32 00 00 00 00 00 00 00")
+	if _, err := c.Request([]byte{0x34, 0x01, 0x02, 0x03, 0x04, 0x0C, 0x0D, 0x00}); err == nil || !strings.Contains(err.Error(), "can't take") {
+		t.Errorf("Overflow gave %v", err)
+	}
+}
+
+// A 33 byte answer comes as a first frame and four consecutive frames, two after each of
+// our flow controls with our block size and STmin
+func TestReassembly(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("0523172000200000", "7E8 10 21 63 00 01 02 03 04")
+	m.Reply("3002050000000000",
+		"7E8 21 05 06 07 08 09 0A 0B",
+		"7E8 22 0C 0D 0E 0F 10 11 12")
+	m.Reply("3002050000000000",
+		"7E8 23 13 14 15 16 17 18 19",
+		"7E8 24 1A 1B 1C 1D 1E 1F 00")
+	c := setup(t, m)
+	c.BlockSize, c.STmin = 2, 0x05
+
+	answer, err := c.Request([]byte{0x23, 0x17, 0x20, 0x00, 0x20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []byte{0x63}
+	for b := byte(0); b < 0x20; b++ {
+		expect = append(expect, b)
+	}
+	if !bytes.Equal(answer, expect) {
+		t.Errorf("Answer %X, expected %X", answer, expect)
+	}
+
+	sent := []string{"0523172000200000", "3002050000000000", "3002050000000000"}
+	if !reflect.DeepEqual(m.Sent, sent) {
+		t.Errorf("Sent %q, expected %q", m.Sent, sent)
+	}
+}
+
+// Frames out of sequence, or an answer that stops short of its length, are errors
+func TestReassemblyErrors(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("0223010000000000", "7E8 10 10 63 00 01 02 03 04", "7E8 22 05 06 07 08 09 0A 0B")
+	m.Reply("0223020000000000", "7E8 10 10 63 00 01 02 03 04", "7E8 21 05 06 07 08 09 0A 0B")
+	c := setup(t, m)
+	c.BlockSize = 0
+
+	if _, err := c.Request([]byte{0x23, 0x01}); err == nil || !strings.Contains(err.Error(), "Expected consecutive frame 1") {
+		t.Errorf("Out of sequence gave %v", err)
+	}
+	if _, err := c.Request([]byte{0x23, 0x02}); err == nil || !strings.Contains(err.Error(), "stopped after 13 of 16") {
+		t.Errorf("Short answer gave %v", err)
+	}
+}
+
+func TestSeparation(t *testing.T) {
+	for st, d := range map[byte]time.Duration{
+		0x00: 0,
+		0x0A: 10 * time.Millisecond,
+		0x7F: 127 * time.Millisecond,
+		0xF1: 100 * time.Microsecond,
+		0xF9: 900 * time.Microsecond,
+		0x80: 127 * time.Millisecond,
+		0xFA: 127 * time.Millisecond,
+	} {
+		if got := separation(st); got != d {
+			t.Errorf("STmin %02X is %s, expected %s", st, got, d)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package j1850
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/comm"
+)
+
+/*
+	SAE J1850 block transfers, the GM and Ford style memory download of the 80C196 era ECUs:
+	mode 34 asks to download a number of bytes, mode 36 carries them with the address each
+	block goes to (sub mode 00, or 80 to run it once it's in), and mode 37 ends it, each
+	answered with the mode plus 0x40, or 7F, the mode and the request echoed back with a
+	response code last. A frame is a three byte header, priority, target and source, the
+	data and a CRC; PWM (Ford, AT SP 1) and VPW (GM, AT SP 2) frame the same way.
+
+	What the ELM327 gets in the way of: it sends at most 7 data bytes a frame, 8 with long
+	messages on (AT AL), so a mode 36 frame of an ELM327 carries only one byte after its
+	own 7, where an STN adapter sends a frame of up to 4K (STPX) and a block goes in one.
+	On PWM every physically addressed frame needs an in-frame response, which the adapter
+	only gives where the header asks for one unless told to always (AT IFR1) and from its
+	own address (AT IFR S). With headers on it shows the CRC at the end of each frame,
+	which is dropped here.
+*/
+
+// Protocol is a J1850 flavour, by the ELM327's protocol number
+type Protocol int
+
+const (
+	PWM Protocol = 1 // Ford, 41.6Kbaud
+	VPW Protocol = 2 // GM, 10.4Kbaud
+)
+
+// Modes, and the answer to each is the mode plus 0x40
+const (
+	RequestDownloadMode     = 0x34
+	TransferDataMode        = 0x36
+	RequestTransferExitMode = 0x37
+	positiveResponse        = 0x40
+	negativeResponse        = 0x7F
+	responsePending         = 0x78
+)
+
+// Mode 36 sub modes
+const (
+	download        = 0x00
+	downloadExecute = 0x80
+)
+
+// The header's priority byte, physically addressed, for each protocol
+var priorities = map[Protocol]byte{PWM: 0xC4, VPW: 0x6C}
+
+// Data bytes a frame takes: an ELM327 with long messages, an STN with STPX
+const elmMaxData = 8
+const stnMaxData = 4095
+
+// Mode 36's own bytes before the data: mode, sub mode, length and address
+const transferHeader = 7
+
+// NegativeResponse is the ECU turning a request down
+type NegativeResponse struct {
+	Mode byte
+	Code byte
+}
+
+func (n *NegativeResponse) Error() string {
+	return fmt.Sprintf("Mode %02X refused: %02X", n.Mode, n.Code)
+}
+
+// Client does block transfers with one ECU through an adapter
+type Client struct {
+	Protocol Protocol
+	Priority byte // the header's first byte
+	Target   byte // the ECU, 0x10 for the PCM
+	Source   byte // the tester, 0xF1
+	link     comm.Transport
+}
+
+// New returns a client for the ECU at target on protocol through link, with the protocol's
+// physical addressing priority; the adapter still has to be set up with Setup
+func New(link comm.Transport, protocol Protocol, target, source byte) *Client {
+	return &Client{Protocol: protocol, Priority: priorities[protocol], Target: target, Source: source, link: link}
+}
+
+// Setup puts the adapter on the protocol with the header set and only frames to us let
+// through, in-frame responses on for PWM
+func (c *Client) Setup() error {
+	if c.Protocol != PWM && c.Protocol != VPW {
+		return fmt.Errorf("Protocol %d isn't J1850", c.Protocol)
+	}
+	cmds := []string{
+		fmt.Sprintf("AT SP %d", c.Protocol),
+		"ATH1",
+		"AT AL",
+		fmt.Sprintf("AT SH %02X %02X %02X", c.Priority, c.Target, c.Source),
+		fmt.Sprintf("AT SR %02X", c.Source),
+	}
+	if c.Protocol == PWM {
+		cmds = append(cmds, "AT IFR1", "AT IFR S")
+	}
+	for _, cmd := range cmds {
+		if _, err := comm.Command(c.link, cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The adapter, if it's an STN
+func (c *Client) stn() *comm.ELM327 {
+	if e, ok := c.link.(*comm.ELM327); ok && e.STN != nil {
+		return e
+	}
+	return nil
+}
+
+// MaxData is how many data bytes a frame takes through this adapter
+func (c *Client) MaxData() int {
+	if c.stn() != nil {
+		return stnMaxData
+	}
+	return elmMaxData
+}
+
+// Request sends mode with its parameters and returns the parameters of the positive
+// answer, a *NegativeResponse if the ECU turned it down
+func (c *Client) Request(mode byte, params ...byte) ([]byte, error) {
+	data := append([]byte{mode}, params...)
+	if len(data) > c.MaxData() {
+		return nil, fmt.Errorf("Frame of %d data bytes, the adapter sends %d", len(data), c.MaxData())
+	}
+
+	cmd := strings.ToUpper(hex.EncodeToString(data))
+	if len(data) > elmMaxData {
+		// One long frame, one answer
+		cmd = fmt.Sprintf("STPX H:%02X%02X%02X, D:%s, R:1", c.Priority, c.Target, c.Source, cmd)
+	}
+	lines, err := comm.Command(c.link, cmd)
+	if err != nil {
+		return nil, err
+	}
+	return c.answer(mode, lines)
+}
+
+// Picks the ECU's answer to mode out of lines, past any saying it's pending
+func (c *Client) answer(mode byte, lines []string) ([]byte, error) {
+	var answer []byte
+	for _, line := range lines {
+		b, err := hex.DecodeString(strings.Replace(line, " ", "", -1))
+		if err != nil {
+			return nil, fmt.Errorf("Bad line %q", line)
+		}
+
+		// Header, data and the CRC, to us from the ECU
+		if len(b) < 5 || b[1] != c.Source || b[2] != c.Target {
+			continue
+		}
+		data := b[3 : len(b)-1]
+		if len(data) >= 3 && data[0] == negativeResponse && data[1] == mode && data[len(data)-1] == responsePending {
+			continue
+		}
+		answer = data
+	}
+
+	switch {
+	case answer == nil:
+		return nil, fmt.Errorf("No answer to mode %02X", mode)
+	case answer[0] == negativeResponse && len(answer) >= 3:
+		return nil, &NegativeResponse{Mode: answer[1], Code: answer[len(answer)-1]}
+	case answer[0] != mode+positiveResponse:
+		return nil, fmt.Errorf("Answer to mode %02X was %X", mode, answer)
+	}
+	return answer[1:], nil
+}
+
+// RequestDownload asks the ECU to take size bytes
+func (c *Client) RequestDownload(size int) error {
+	if size <= 0 || size > 0xFFFF {
+		return fmt.Errorf("Download of %d bytes, mode 34 takes up to %d", size, 0xFFFF)
+	}
+	_, err := c.Request(RequestDownloadMode, 0x00, byte(size>>8), byte(size))
+	return err
+}
+
+// TransferData sends data to address, as many mode 36 frames as the adapter needs, and
+// has the ECU run it from address once it's in if execute is set
+func (c *Client) TransferData(address int, data []byte, execute bool) error {
+	if address < 0 || address > 0xFFFFFF {
+		return fmt.Errorf("Address %X out of range", address)
+	}
+	if len(data) == 0 {
+		return errors.New("Nothing to transfer")
+	}
+	block := c.MaxData() - transferHeader
+	if block < 1 {
+		return fmt.Errorf("The adapter's frames are too short for mode 36")
+	}
+
+	for start := 0; start < len(data); start += block {
+		end := start + block
+		if end > len(data) {
+			end = len(data)
+		}
+		sub := byte(download)
+		if execute && end == len(data) {
+			sub = downloadExecute
+		}
+		n, adr := end-start, address+start
+		params := append([]byte{sub, byte(n >> 8), byte(n), byte(adr >> 16), byte(adr >> 8), byte(adr)}, data[start:end]...)
+		if _, err := c.Request(TransferDataMode, params...); err != nil {
+			return fmt.Errorf("Block at %X: %s", adr, err)
+		}
+	}
+	return nil
+}
+
+// RequestTransferExit ends the download
+func (c *Client) RequestTransferExit() error {
+	_, err := c.Request(RequestTransferExitMode)
+	return err
+}
@@ -0,0 +1,100 @@
+package j1850
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/murdinc/ELMFlash/comm"
+)
+
+// A line from the PCM to the tester, header, data and a CRC; the adapter has checked the
+// CRC by the time it shows one, so any byte does
+func pcm(priority byte, data ...byte) string {
+	b := append([]byte{priority, 0xF1, 0x10}, data...)
+	return strings.TrimSpace(fmt.Sprintf("% X", append(b, 0x5A)))
+}
+
+// PWM gets in-frame responses from the tester's address, VPW doesn't, and each sets the
+// protocol's physical header
+func TestSetup(t *testing.T) {
+	for _, test := range []struct {
+		protocol Protocol
+		sent     string
+	}{
+		{PWM, "AT SP 1,ATH1,AT AL,AT SH C4 10 F1,AT SR F1,AT IFR1,AT IFR S"},
+		{VPW, "AT SP 2,ATH1,AT AL,AT SH 6C 10 F1,AT SR F1"},
+	} {
+		m := comm.NewMock()
+		if err := New(m, test.protocol, 0x10, 0xF1).Setup(); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(m.Sent, ","); got != test.sent {
+			t.Errorf("Protocol %d sent %s, expected %s", test.protocol, got, test.sent)
+		}
+	}
+
+	if err := New(comm.NewMock(), Protocol(6), 0x10, 0xF1).Setup(); err == nil {
+		t.Errorf("Set up on CAN")
+	}
+}
+
+// A download through an ELM327, which takes one data byte in each mode 36 frame, the
+// last one to run. The PCM is pending on the transfer, and another module's frames and
+// our own in-frame response byte are passed over.
+func TestDownload(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("34000003", pcm(0xC4, 0x74, 0x00))
+	m.Reply("36000001003000AA", pcm(0xC4, 0x7F, 0x36, 0x00, 0x00, 0x01, 0x00, 0x30, 0x00, 0xAA, 0x78), pcm(0xC4, 0x76))
+	m.Reply("36000001003001BB", "C4 F1 18 76 00", pcm(0xC4, 0x76))
+	m.Reply("36800001003002CC", "F1", pcm(0xC4, 0x76))
+	m.Reply("37", pcm(0xC4, 0x77))
+	c := New(m, PWM, 0x10, 0xF1)
+
+	if err := c.RequestDownload(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TransferData(0x3000, []byte{0xAA, 0xBB, 0xCC}, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RequestTransferExit(); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := "34000003,36000001003000AA,36000001003001BB,36800001003002CC,37"
+	if got := strings.Join(m.Sent, ","); got != sent {
+		t.Errorf("Sent %s, expected %s", got, sent)
+	}
+}
+
+// A refusal carries the request echoed with the code last; a frame too long for the
+// adapter or a download mode 34 can't ask for isn't sent
+func TestDownloadErrors(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("34001000", pcm(0x6C, 0x7F, 0x34, 0x00, 0x10, 0x00, 0x22))
+	m.Reply("36000001003000AA", pcm(0x6C, 0x7F, 0x36, 0x00, 0x00, 0x01, 0x00, 0x30, 0x00, 0xAA, 0x78))
+	m.Reply("37", pcm(0x6C, 0x47))
+	c := New(m, VPW, 0x10, 0xF1)
+
+	err := c.RequestDownload(0x1000)
+	if n, ok := err.(*NegativeResponse); !ok || n.Mode != RequestDownloadMode || n.Code != 0x22 {
+		t.Errorf("Refused download gave %v", err)
+	}
+	if err := c.TransferData(0x3000, []byte{0xAA}, false); err == nil || !strings.Contains(err.Error(), "Block at 3000: No answer") {
+		t.Errorf("Pending only gave %v", err)
+	}
+	if err := c.RequestTransferExit(); err == nil || !strings.Contains(err.Error(), "was 47") {
+		t.Errorf("Wrong answer gave %v", err)
+	}
+
+	m.Sent = nil
+	if _, err := c.Request(TransferDataMode, 0, 0, 2, 0, 0x30, 0, 1, 2); err == nil {
+		t.Errorf("9 data bytes sent through an ELM327")
+	}
+	if err := c.RequestDownload(0x10000); err == nil {
+		t.Errorf("Download of 0x10000 bytes asked for")
+	}
+	if len(m.Sent) != 0 {
+		t.Errorf("Sent %q", m.Sent)
+	}
+}
@@ -0,0 +1,368 @@
+package kwp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/murdinc/ELMFlash/comm"
+	"github.com/murdinc/ELMFlash/security"
+)
+
+/*
+	KWP2000 (ISO 14230), the diagnostic services on the K-line: starting communication and
+	a diagnostic session, security access, reading memory, and the download sequence a
+	flash takes (RequestDownload, TransferData blocks, RequestTransferExit), with
+	TesterPresent keeping the session up in between. A request goes out as the service and
+	its parameters with the adapter putting on the header (format, target and source) and
+	checksum; an answer is the service plus 0x40, or 7F with the service and a response
+	code. A 78, response pending, means the ECU is still at it and the real answer follows,
+	which the adapter waits for and hands back in the same response; for the services that
+	erase and program, where that's likely, its timeout is stretched to the longest.
+
+	The timing is ISO 14230-2's: the ECU answers within P2max (50ms, up to 5s once it has
+	said it's pending), the tester leaves at least P3min (55ms) after an answer before its
+	next request, and the session drops after P3max (5s) without one. The adapter is told
+	P2max as its response timeout (AT ST) and sends TesterPresent on its own when the link
+	has been quiet for most of P3max (AT SW, AT WM), so a long wait between blocks doesn't
+	cost the session. The protocol is set up by the caller, AT SP 5 for the fast init.
+*/
+
+// Services, and the answer to each is the service plus 0x40
+const (
+	StartDiagnosticSessionService = 0x10
+	ReadMemoryByAddressService    = 0x23
+	SecurityAccessService         = 0x27
+	RequestDownloadService        = 0x34
+	TransferDataService           = 0x36
+	RequestTransferExitService    = 0x37
+	TesterPresentService          = 0x3E
+	StartCommunicationService     = 0x81
+	positiveResponse              = 0x40
+	negativeResponse              = 0x7F
+)
+
+// Response codes that aren't failures: still at it, and busy so ask again
+const (
+	responsePending = 0x78
+	busyRepeat      = 0x21
+)
+
+// Timing is ISO 14230-2's timing parameters
+type Timing struct {
+	P2Max time.Duration // longest the ECU takes to answer
+	P3Min time.Duration // shortest wait after an answer before the next request
+	P3Max time.Duration // longest the link is quiet before the session drops
+}
+
+// DefaultTiming is the standard timing, what an ECU runs at after StartCommunication
+var DefaultTiming = Timing{P2Max: 50 * time.Millisecond, P3Min: 55 * time.Millisecond, P3Max: 5 * time.Second}
+
+// How many times a busy ECU is asked again
+const busyTries = 3
+
+// The services the ECU is likely to say are pending, erasing and programming, which the
+// adapter waits the longest it can for (AT ST FF, about a second between each pending)
+var slowServices = map[byte]bool{
+	SecurityAccessService:      true,
+	RequestDownloadService:     true,
+	TransferDataService:        true,
+	RequestTransferExitService: true,
+}
+
+// The response codes, by code
+var responseCodes = map[byte]string{
+	0x10: "General Reject",
+	0x11: "Service Not Supported",
+	0x12: "Sub Function Not Supported - Invalid Format",
+	0x21: "Busy - Repeat Request",
+	0x22: "Conditions Not Correct Or Request Sequence Error",
+	0x23: "Routine Not Complete",
+	0x31: "Request Out Of Range",
+	0x33: "Security Access Denied",
+	0x35: "Invalid Key",
+	0x36: "Exceed Number Of Attempts",
+	0x37: "Required Time Delay Not Expired",
+	0x40: "Download Not Accepted",
+	0x41: "Improper Download Type",
+	0x42: "Can Not Download To Specified Address",
+	0x43: "Can Not Download Number Of Bytes Requested",
+	0x71: "Transfer Suspended",
+	0x72: "Transfer Aborted",
+	0x74: "Illegal Address In Block Transfer",
+	0x75: "Illegal Byte Count In Block Transfer",
+	0x76: "Illegal Block Transfer Type",
+	0x77: "Block Transfer Data Checksum Error",
+	0x78: "Request Correctly Received - Response Pending",
+	0x79: "Incorrect Byte Count During Block Transfer",
+	0x80: "Service Not Supported In Active Diagnostic Mode",
+}
+
+// NegativeResponse is the ECU turning a request down
+type NegativeResponse struct {
+	Service byte
+	Code    byte
+}
+
+func (n *NegativeResponse) Error() string {
+	name, ok := responseCodes[n.Code]
+	if !ok {
+		name = "Unknown"
+	}
+	return fmt.Sprintf("Service %02X refused: %02X - %s", n.Service, n.Code, name)
+}
+
+// Client speaks KWP2000 to one ECU through an adapter
+type Client struct {
+	Target byte // the ECU, 0x10 for the engine
+	Source byte // the tester, 0xF1
+	Timing Timing
+	link   comm.Transport
+	last   time.Time // when the last answer came
+	st     byte      // the adapter's response timeout for P2max
+}
+
+// New returns a client for the ECU at target through link, with the standard timing; the
+// adapter still has to be set up with Setup
+func New(link comm.Transport, target, source byte) *Client {
+	return &Client{Target: target, Source: source, Timing: DefaultTiming, link: link}
+}
+
+// Setup points the adapter at the ECU and gives it the timing, the response timeout and
+// the TesterPresent it sends on a quiet link
+func (c *Client) Setup() error {
+	return c.setTiming(c.Timing)
+}
+
+func (c *Client) setTiming(t Timing) error {
+	// AT ST is in 4ms and AT SW in 20ms, to a byte
+	st := int(t.P2Max / (4 * time.Millisecond))
+	sw := int(t.P3Max * 3 / 4 / (20 * time.Millisecond))
+	if st < 1 {
+		st = 1
+	}
+	if st > 0xFF {
+		st = 0xFF
+	}
+	if sw > 0xFF {
+		sw = 0xFF
+	}
+
+	cmds := []string{
+		"ATH1",
+		fmt.Sprintf("AT SH 81 %02X %02X", c.Target, c.Source),
+		fmt.Sprintf("AT ST %02X", st),
+		fmt.Sprintf("AT WM 81 %02X %02X %02X", c.Target, c.Source, TesterPresentService),
+		fmt.Sprintf("AT SW %02X", sw),
+	}
+	for _, cmd := range cmds {
+		if _, err := comm.Command(c.link, cmd); err != nil {
+			return err
+		}
+	}
+	c.Timing, c.st = t, byte(st)
+	return nil
+}
+
+// Sets the adapter's response timeout, in its units of 4ms
+func (c *Client) setTimeout(st byte) error {
+	_, err := comm.Command(c.link, fmt.Sprintf("AT ST %02X", st))
+	return err
+}
+
+// Request sends service with its parameters and returns the parameters of the positive
+// answer, a *NegativeResponse if the ECU turned it down
+func (c *Client) Request(service byte, params ...byte) ([]byte, error) {
+	req := strings.ToUpper(hex.EncodeToString(append([]byte{service}, params...)))
+	if slowServices[service] && c.st != 0 {
+		if err := c.setTimeout(0xFF); err != nil {
+			return nil, err
+		}
+		defer c.setTimeout(c.st)
+	}
+	for try := 0; ; try++ {
+		if wait := c.Timing.P3Min - time.Since(c.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		lines, err := comm.Command(c.link, req)
+		c.last = time.Now()
+		if err != nil {
+			return nil, err
+		}
+
+		answer, err := c.answer(service, lines)
+		if n, ok := err.(*NegativeResponse); ok && n.Code == busyRepeat && try < busyTries {
+			continue
+		}
+		return answer, err
+	}
+}
+
+// Picks the ECU's last answer to service out of lines, past any it sent saying it's
+// pending
+func (c *Client) answer(service byte, lines []string) ([]byte, error) {
+	var answer []byte
+	pending := false
+	for _, line := range lines {
+		msg, err := c.message(line)
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			continue
+		}
+		if len(msg) >= 3 && msg[0] == negativeResponse && msg[1] == service && msg[2] == responsePending {
+			pending = true
+			continue
+		}
+		answer = msg
+	}
+
+	switch {
+	case answer == nil && pending:
+		return nil, fmt.Errorf("Service %02X still pending after %d lines", service, len(lines))
+	case answer == nil:
+		return nil, fmt.Errorf("No answer to service %02X", service)
+	case answer[0] == negativeResponse && len(answer) >= 3:
+		return nil, &NegativeResponse{Service: answer[1], Code: answer[2]}
+	case answer[0] != service+positiveResponse:
+		return nil, fmt.Errorf("Answer to service %02X was %X", service, answer)
+	}
+	return answer[1:], nil
+}
+
+// The service and parameters of a message in a line, nil if it isn't from the ECU to
+// us. The header is the format byte, with the length in its low bits or a length byte
+// after the addresses, target and source, and a checksum ends it.
+func (c *Client) message(line string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.Replace(line, " ", "", -1))
+	if err != nil {
+		return nil, fmt.Errorf("Bad line %q", line)
+	}
+	if len(b) < 5 {
+		return nil, nil
+	}
+	n, start := int(b[0]&0x3F), 3
+	if n == 0 {
+		n, start = int(b[3]), 4
+	}
+	if start+n+1 > len(b) {
+		return nil, fmt.Errorf("Short message %X", b)
+	}
+	if b[1] != c.Source || b[2] != c.Target {
+		return nil, nil
+	}
+
+	var sum byte
+	for _, v := range b[:start+n] {
+		sum += v
+	}
+	if sum != b[start+n] {
+		return nil, fmt.Errorf("Bad checksum on %X", b)
+	}
+	return b[start : start+n], nil
+}
+
+// StartCommunication opens the link (the adapter has done the fast init) and returns the
+// ECU's key bytes, which say what header formats and timing it takes
+func (c *Client) StartCommunication() ([]byte, error) {
+	return c.Request(StartCommunicationService)
+}
+
+// StartDiagnosticSession moves the ECU to session, 0x81 the default and 0x85 programming
+// on most
+func (c *Client) StartDiagnosticSession(session byte) error {
+	_, err := c.Request(StartDiagnosticSessionService, session)
+	return err
+}
+
+// SecurityAccess unlocks level, an odd number: the ECU's seed for it is handed to solver,
+// and the key it works out is sent as level+1
+func (c *Client) SecurityAccess(level byte, solver security.SeedKeySolver) error {
+	if level%2 == 0 {
+		return fmt.Errorf("Security level %02X isn't a seed request", level)
+	}
+	seed, err := c.Request(SecurityAccessService, level)
+	if err != nil {
+		return err
+	}
+	if len(seed) < 1 || seed[0] != level {
+		return fmt.Errorf("Seed for the wrong level, %X", seed)
+	}
+
+	// An all zero seed is an ECU already unlocked
+	unlocked := true
+	for _, b := range seed[1:] {
+		if b != 0 {
+			unlocked = false
+		}
+	}
+	if unlocked {
+		return nil
+	}
+
+	key, err := solver.Key(level, seed[1:])
+	if err != nil {
+		return err
+	}
+	_, err = c.Request(SecurityAccessService, append([]byte{level + 1}, key...)...)
+	return err
+}
+
+// ReadMemoryByAddress reads size bytes at address, a 24 bit address
+func (c *Client) ReadMemoryByAddress(address int, size byte) ([]byte, error) {
+	if address < 0 || address > 0xFFFFFF {
+		return nil, fmt.Errorf("Address %X out of range", address)
+	}
+	data, err := c.Request(ReadMemoryByAddressService, byte(address>>16), byte(address>>8), byte(address), size)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != int(size) {
+		return data, fmt.Errorf("Read %d bytes at %X, asked for %d", len(data), address, size)
+	}
+	return data, nil
+}
+
+// RequestDownload starts a download of size bytes to address, format saying how it's
+// compressed and encrypted (0x00 neither), and returns the longest block TransferData
+// takes, 0 if the ECU doesn't say
+func (c *Client) RequestDownload(address int, format byte, size int) (int, error) {
+	if address < 0 || address > 0xFFFFFF || size < 0 || size > 0xFFFFFF {
+		return 0, fmt.Errorf("Download of %X bytes to %X out of range", size, address)
+	}
+	params := []byte{byte(address >> 16), byte(address >> 8), byte(address), format, byte(size >> 16), byte(size >> 8), byte(size)}
+	answer, err := c.Request(RequestDownloadService, params...)
+	if err != nil {
+		return 0, err
+	}
+	max := 0
+	for _, b := range answer {
+		max = max<<8 | int(b)
+	}
+	return max, nil
+}
+
+// TransferData sends one block of a download
+func (c *Client) TransferData(block []byte) error {
+	if len(block) == 0 {
+		return errors.New("Empty block")
+	}
+	_, err := c.Request(TransferDataService, block...)
+	return err
+}
+
+// RequestTransferExit ends a download
+func (c *Client) RequestTransferExit() error {
+	_, err := c.Request(RequestTransferExitService)
+	return err
+}
+
+// TesterPresent keeps the session up, the adapter sends it on its own when the link is
+// quiet too
+func (c *Client) TesterPresent() error {
+	_, err := c.Request(TesterPresentService, 0x01)
+	return err
+}
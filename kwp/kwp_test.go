@@ -0,0 +1,161 @@
+package kwp
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/murdinc/ELMFlash/comm"
+	"github.com/murdinc/ELMFlash/security"
+)
+
+// A client for the engine through m, set up with no wait between requests, and the setup
+// commands forgotten
+func setup(t *testing.T, m *comm.Mock) *Client {
+	c := New(m, 0x10, 0xF1)
+	if err := c.Setup(); err != nil {
+		t.Fatal(err)
+	}
+	c.Timing.P3Min = 0
+	m.Sent = nil
+	return c
+}
+
+// A line from the engine to the tester carrying data, header and checksum
+func ecu(data ...byte) string {
+	b := append([]byte{0x80 | byte(len(data)), 0xF1, 0x10}, data...)
+	var sum byte
+	for _, v := range b {
+		sum += v
+	}
+	return strings.TrimSpace(fmt.Sprintf("% X", append(b, sum)))
+}
+
+func TestSecurityAccess(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("2701", ecu(0x7F, 0x27, 0x78), ecu(0x67, 0x01, 0x12, 0x34))
+	m.Reply("27025A5A", ecu(0x67, 0x02, 0x34))
+	c := setup(t, m)
+
+	if err := c.SecurityAccess(0x01, security.XOR{Mask: []byte{0x48, 0x6E}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The adapter waits its longest for each, then goes back to P2max
+	sent := "AT ST FF,2701,AT ST 0C,AT ST FF,27025A5A,AT ST 0C"
+	if got := strings.Join(m.Sent, ","); got != sent {
+		t.Errorf("Sent %s, expected %s", got, sent)
+	}
+}
+
+// An ECU already unlocked sends a zero seed and gets no key, one that takes the key
+// badly is a *NegativeResponse
+func TestSecurityAccessRefused(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("2701", ecu(0x67, 0x01, 0x00, 0x00))
+	m.Reply("2703", ecu(0x67, 0x03, 0x12, 0x34))
+	m.Reply("2704123A", ecu(0x7F, 0x27, 0x35))
+	c := setup(t, m)
+
+	xor := security.XOR{Mask: []byte{0x00, 0x0E}}
+	if err := c.SecurityAccess(0x01, xor); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range m.Sent {
+		if strings.HasPrefix(s, "2702") {
+			t.Errorf("Key %s sent for a zero seed", s)
+		}
+	}
+
+	err := c.SecurityAccess(0x03, xor)
+	n, ok := err.(*NegativeResponse)
+	if !ok || n.Service != SecurityAccessService || n.Code != 0x35 {
+		t.Fatalf("Invalid key gave %v", err)
+	}
+	if !strings.Contains(n.Error(), "Invalid Key") {
+		t.Errorf("Negative response reads %q", n.Error())
+	}
+
+	if err := c.SecurityAccess(0x02, xor); err == nil {
+		t.Errorf("Level 02 asked for a seed")
+	}
+}
+
+// A download start to finish, the ECU pending on the request and the exit and busy on a
+// block, which is sent again. Another ECU answering the exit is passed over.
+func TestDownload(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("3400800000000100", ecu(0x7F, 0x34, 0x78), ecu(0x7F, 0x34, 0x78), ecu(0x74, 0x80))
+	m.Reply("36A1A2A3A4", ecu(0x7F, 0x36, 0x21))
+	m.Reply("36A1A2A3A4", ecu(0x76))
+	m.Reply("37", "82 F1 11 77 00 FB", ecu(0x7F, 0x37, 0x78), ecu(0x77))
+	c := setup(t, m)
+
+	max, err := c.RequestDownload(0x008000, 0x00, 0x100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if max != 0x80 {
+		t.Errorf("Longest block %X, expected 80", max)
+	}
+	if err := c.TransferData([]byte{0xA1, 0xA2, 0xA3, 0xA4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RequestTransferExit(); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := 0
+	for _, s := range m.Sent {
+		if s == "36A1A2A3A4" {
+			blocks++
+		}
+	}
+	if blocks != 2 {
+		t.Errorf("Block sent %d times, expected 2", blocks)
+	}
+}
+
+// Pending with no answer after it, a refusal, a busy ECU that stays busy and a bad
+// checksum are all errors
+func TestDownloadErrors(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("3400800000000100", ecu(0x7F, 0x34, 0x78))
+	m.Reply("3400900000000100", ecu(0x7F, 0x34, 0x42))
+	m.Reply("36A1", ecu(0x7F, 0x36, 0x21))
+	m.Reply("37", "81 F1 10 77 00")
+	c := setup(t, m)
+
+	if _, err := c.RequestDownload(0x008000, 0x00, 0x100); err == nil || !strings.Contains(err.Error(), "still pending") {
+		t.Errorf("Pending only gave %v", err)
+	}
+	_, err := c.RequestDownload(0x009000, 0x00, 0x100)
+	if n, ok := err.(*NegativeResponse); !ok || n.Code != 0x42 {
+		t.Errorf("Refused download gave %v", err)
+	}
+	err = c.TransferData([]byte{0xA1})
+	if n, ok := err.(*NegativeResponse); !ok || n.Code != busyRepeat {
+		t.Errorf("Busy block gave %v", err)
+	}
+	if err := c.RequestTransferExit(); err == nil || !strings.Contains(err.Error(), "checksum") {
+		t.Errorf("Bad checksum gave %v", err)
+	}
+	if _, err := c.RequestDownload(0x1000000, 0x00, 0x100); err == nil {
+		t.Errorf("Download past 24 bits accepted")
+	}
+}
+
+func TestReadMemoryByAddress(t *testing.T) {
+	m := comm.NewMock()
+	m.Reply("2317200004", ecu(0x63, 0xDE, 0xAD, 0xBE, 0xEF))
+	c := setup(t, m)
+
+	data, err := c.ReadMemoryByAddress(0x172000, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte{0xDE, 0xAD, 0xBE, 0xEF}) {
+		t.Errorf("Read %X", data)
+	}
+}
@@ -1,21 +1,39 @@
 package main
 
 import (
+	"bufio"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/murdinc/ELMFlash/asm"
 	"github.com/murdinc/ELMFlash/calibrate"
+	"github.com/murdinc/ELMFlash/comm"
 	"github.com/murdinc/ELMFlash/compare"
+	"github.com/murdinc/ELMFlash/crash"
 	"github.com/murdinc/ELMFlash/disasm"
+	"github.com/murdinc/ELMFlash/golden"
 	"github.com/murdinc/ELMFlash/hexstuff"
 	"github.com/murdinc/ELMFlash/iso9141"
 	"github.com/murdinc/ELMFlash/j3"
+	"github.com/murdinc/ELMFlash/project"
+	"github.com/murdinc/ELMFlash/sim"
+	"github.com/murdinc/ELMFlash/wizard"
 	"github.com/murdinc/legacy-cli"
 )
 
 // Main Function
 ////////////////..........
 func main() {
+	defer crash.Recover()
+	crash.Version = "1.0"
+
+	// A serial port or a WiFi adapter's host and port, found on the serial ports when unset
+	iso9141.Adapter = os.Getenv("ELMFLASH_ADAPTER")
 
 	app := cli.NewApp()
 	app.Name = "ELMFlash"
@@ -54,6 +72,73 @@ func main() {
 				obd.DumpBIN("DUMP")
 			},
 		},
+		{
+			Name:        "ramdump",
+			ShortName:   "rd",
+			Example:     "ramdump --out ram.bin",
+			Description: "Read the ECU's RAM into a snapshot for disasm --ram",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "out", Value: "RAM.BIN", Usage: "File to write"},
+				cli.StringFlag{Name: "start", Value: "0x0000", Usage: "First address to read"},
+				cli.StringFlag{Name: "length", Value: "0x1000", Usage: "Bytes to read, a multiple of 0x100"},
+			},
+			Action: func(c *cli.Context) {
+				start, err := strconv.ParseInt(c.String("start"), 0, 32)
+				if err != nil {
+					log("RAM Dump - Bad --start", err)
+					return
+				}
+				length, err := strconv.ParseInt(c.String("length"), 0, 32)
+				if err != nil {
+					log("RAM Dump - Bad --length", err)
+					return
+				}
+				obd := iso9141.New(false)
+				if err := obd.DumpRAM(c.String("out"), int(start), int(length)); err != nil {
+					log("RAM Dump", err)
+					return
+				}
+				log(fmt.Sprintf("RAM Dump - Wrote 0x%X bytes from 0x%04X to %s, use --ram %s@0x%X", length, start, c.String("out"), c.String("out"), start), nil)
+			},
+		},
+		{
+			Name:        "readmem",
+			ShortName:   "rm",
+			Example:     "readmem --start 0x108000 --length 0x78000 --out ROM.BIN",
+			Description: "Read a span of the ECU's memory, retrying failed blocks, with the rate and time left as it goes; an interrupted read carries on where it stopped",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "out", Value: "ROM.BIN", Usage: "File to write"},
+				cli.StringFlag{Name: "start", Value: "0x108000", Usage: "First address to read"},
+				cli.StringFlag{Name: "length", Value: "0x78000", Usage: "Bytes to read"},
+			},
+			Action: func(c *cli.Context) {
+				start, err := strconv.ParseInt(c.String("start"), 0, 32)
+				if err != nil {
+					log("Read Memory - Bad --start", err)
+					return
+				}
+				length, err := strconv.ParseInt(c.String("length"), 0, 32)
+				if err != nil {
+					log("Read Memory - Bad --length", err)
+					return
+				}
+
+				// A line every tenth of the way
+				next := 0
+				obd := iso9141.New(false)
+				err = obd.ReadMemoryTo(c.String("out"), int(start), int(length), func(p iso9141.Progress) {
+					if p.Done*10/p.Total >= next {
+						log("Read Memory - "+p.String(), nil)
+						next = p.Done*10/p.Total + 1
+					}
+				})
+				if err != nil {
+					log("Read Memory - Stopped, run it again to carry on where it left off", err)
+					return
+				}
+				log(fmt.Sprintf("Read Memory - Wrote 0x%X bytes from 0x%06X to %s", length, start, c.String("out")), nil)
+			},
+		},
 		{
 			Name:        "upload",
 			ShortName:   "u",
@@ -64,13 +149,243 @@ func main() {
 			},
 			Flags: []cli.Flag{
 				cli.BoolFlag{Name: "test", Usage: "Test upload"},
+				cli.StringFlag{Name: "ecu", Usage: "ECU definition with the post-flash steps to run"},
+				cli.StringFlag{Name: "project", Usage: "Project with a verified backup of this ECU, writes are locked without one"},
 			},
 			Action: func(c *cli.Context) {
-
 				obd := iso9141.New(c.Bool("test"))
+				if !writesUnlocked(c, "Upload", obd) {
+					return
+				}
+				if c.String("ecu") != "" {
+					def, err := iso9141.LoadECUDef(c.String("ecu"))
+					if err != nil {
+						log("Upload - Unable to load ECU definition", err)
+						return
+					}
+					obd.ECU = def
+				}
 				obd.UploadBIN(c.NamedArg("calibration"))
 			},
 		},
+		{
+			Name:        "flash",
+			ShortName:   "fl",
+			Example:     "flash TUNED.BIN --checksums --dry-run",
+			Description: "Flash an image: unlock, back up, erase, program and verify, putting the backup back on if it fails",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "file", Usage: "flash TUNED.BIN", Description: "The image to flash, laid out like a download", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "dry-run", Usage: "Report what would change without erasing or programming anything"},
+				cli.BoolFlag{Name: "checksums", Usage: "Fix the image's checksums, found in its own code, before flashing"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map for finding the checksums, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "current", Usage: "A read of what the ECU holds to plan with, instead of reading it all first; the sectors erased are still read for the backup"},
+				cli.StringFlag{Name: "backup", Usage: "Where to save the ECU's image before erasing it"},
+				cli.StringFlag{Name: "ecu", Usage: "ECU definition with the post-flash steps to run"},
+				cli.BoolFlag{Name: "kernel", Usage: "Program through the ECU definition's flashing kernel, loaded into RAM"},
+				cli.BoolFlag{Name: "test", Usage: "Test flash"},
+				cli.StringFlag{Name: "project", Usage: "Project with a verified backup of this ECU, writes are locked without one"},
+			},
+			Action: func(c *cli.Context) {
+				image, err := ioutil.ReadFile(c.NamedArg("file"))
+				if err != nil {
+					log("Flash - Unable to read image", err)
+					return
+				}
+				opts := iso9141.FlashOptions{DryRun: c.Bool("dry-run"), Backup: c.String("backup"), Kernel: c.Bool("kernel")}
+				if c.Bool("checksums") {
+					if opts.FixChecksum, err = checksumFixer(image, c.String("memmap")); err != nil {
+						log("Flash - Unable to find the checksums", err)
+						return
+					}
+				}
+				if c.String("current") != "" {
+					if opts.Current, err = ioutil.ReadFile(c.String("current")); err != nil {
+						log("Flash - Unable to read --current", err)
+						return
+					}
+				}
+
+				if err := iso9141.LoadFlashChips(); err != nil {
+					log("Flash - Unable to load the flash chips", err)
+					return
+				}
+				obd := iso9141.New(c.Bool("test"))
+				if !opts.DryRun && !writesUnlocked(c, "Flash", obd) {
+					return
+				}
+				if c.String("ecu") != "" {
+					if obd.ECU, err = iso9141.LoadECUDef(c.String("ecu")); err != nil {
+						log("Flash - Unable to load ECU definition", err)
+						return
+					}
+				}
+				plan, err := obd.Flash(image, opts)
+				if plan != nil {
+					fmt.Print(plan)
+				}
+				if err != nil {
+					log("Flash", err)
+					return
+				}
+				if !opts.DryRun && plan.Bytes() > 0 {
+					log("Flash - Programmed and verified", nil)
+				}
+			},
+		},
+		{
+			Name:        "recover",
+			ShortName:   "rec",
+			Example:     "recover MSP.BIN --ecu protege",
+			Description: "Recover a bricked ECU on the bench: reach its boot code the way its definition says and write a whole image",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "file", Usage: "recover MSP.BIN --ecu protege", Description: "The image to write, laid out like a download", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "ecu", Usage: "ECU definition with the boot mode"},
+				cli.StringFlag{Name: "project", Usage: "Project with a verified backup of this ECU, writes are locked without one"},
+			},
+			Action: func(c *cli.Context) {
+				if !recoverUnlocked(c) {
+					return
+				}
+				image, err := ioutil.ReadFile(c.NamedArg("file"))
+				if err != nil {
+					log("Recover - Unable to read image", err)
+					return
+				}
+				if err := iso9141.LoadFlashChips(); err != nil {
+					log("Recover - Unable to load the flash chips", err)
+					return
+				}
+				def, err := iso9141.LoadECUDef(c.String("ecu"))
+				if err != nil {
+					log("Recover - Unable to load ECU definition", err)
+					return
+				}
+				if def.Boot == nil {
+					log(fmt.Sprintf("Recover - %s has no boot mode", def.Name), nil)
+					return
+				}
+
+				obd, err := iso9141.NewBoot(*def.Boot)
+				if err != nil {
+					log("Recover - Unable to reach the boot code", err)
+					return
+				}
+				obd.ECU = def
+				if err := obd.Recover(image); err != nil {
+					log("Recover", err)
+					return
+				}
+				log("Recover - Written and verified", nil)
+				if len(def.PostFlash) > 0 {
+					log(fmt.Sprintf("Recover - %s's %d post-flash steps weren't run, do them once it's back in the car", def.Name, len(def.PostFlash)), nil)
+				}
+			},
+		},
+		{
+			Name:        "flashchip",
+			ShortName:   "fc",
+			Example:     "flashchip --ecu protege",
+			Description: "Identify the ECU's flash chip and list the sectors of the image it erases",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "ecu", Usage: "ECU definition with the flash ID request or the chip's name"},
+			},
+			Action: func(c *cli.Context) {
+				if err := iso9141.LoadFlashChips(); err != nil {
+					log("Flash Chip - Unable to load the flash chips", err)
+					return
+				}
+				obd := iso9141.New(false)
+				if c.String("ecu") != "" {
+					def, err := iso9141.LoadECUDef(c.String("ecu"))
+					if err != nil {
+						log("Flash Chip - Unable to load ECU definition", err)
+						return
+					}
+					obd.ECU = def
+				}
+				chip, err := obd.IdentifyFlash()
+				if err != nil {
+					log("Flash Chip", err)
+					return
+				}
+				sectors, err := chip.ImageSectors()
+				if err != nil {
+					log("Flash Chip", err)
+					return
+				}
+				for _, s := range sectors {
+					log(fmt.Sprintf("0x%06X-0x%06X  %dK", s.Start, s.Start+s.Length-1, s.Length/1024), nil)
+				}
+			},
+		},
+		{
+			Name:        "clone",
+			ShortName:   "cl",
+			Example:     "clone --keep 0x7F00:0x20",
+			Description: "Clone the calibration from a donor ECU onto a target ECU, with a report of every step",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "keep", Usage: "Image regions (offset:length,...) to keep from the target, like the VIN and immobilizer data"},
+				cli.StringFlag{Name: "ecu", Usage: "ECU definition with the post-flash steps to run"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map for finding the checksums, built in (196ea) or a JSON file"},
+				cli.BoolFlag{Name: "test", Usage: "Test clone"},
+				cli.StringFlag{Name: "project", Usage: "Project with a verified backup of this ECU, writes are locked without one"},
+			},
+			Action: func(c *cli.Context) {
+				keep, err := iso9141.ParseRegions(c.String("keep"))
+				if err != nil {
+					log("Clone - Bad --keep regions", err)
+					return
+				}
+
+				obd := iso9141.New(c.Bool("test"))
+				if c.String("ecu") != "" {
+					obd.ECU, err = iso9141.LoadECUDef(c.String("ecu"))
+					if err != nil {
+						log("Clone - Unable to load ECU definition", err)
+						return
+					}
+				}
+				policy := iso9141.ClonePolicy{
+					Keep:          keep,
+					CheckChecksum: checksumChecker(c.String("memmap")),
+					FixChecksum:   lateChecksumFixer(c.String("memmap")),
+				}
+				// The donor is only read, the lock is on the target it's swapped for
+				if !obd.Dummy {
+					policy.CheckTarget = func(ecuID []byte) error {
+						return wizard.CheckWrites(c.String("project"), ecuID)
+					}
+				}
+				obd.Clone("CLONE", policy)
+			},
+		},
+		{
+			Name:        "wizard",
+			ShortName:   "w",
+			Example:     "wizard",
+			Description: "First run setup: find the adapter, identify the ECU, back it up and create a project",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: "./projects", Usage: "Directory to create the project in"},
+			},
+			Action: func(c *cli.Context) {
+				w := wizard.New(c.String("dir"))
+				reader := bufio.NewReader(os.Stdin)
+				err := w.Run(func(step wizard.Step, prompt string) bool {
+					fmt.Printf("[%s] %s\nPress enter to continue, or q to quit: ", step, prompt)
+					answer, _ := reader.ReadString('\n')
+					return strings.TrimSpace(answer) != "q"
+				})
+				if err != nil {
+					log("Wizard", err)
+					return
+				}
+				log(fmt.Sprintf("Project created in %s, write to the ECU with --project %s", w.Project.Dir, w.Project.Dir), nil)
+			},
+		},
 		{
 			Name:        "common",
 			ShortName:   "c",
@@ -101,6 +416,30 @@ func main() {
 				obd.EcuId()
 			},
 		},
+		{
+			Name:        "adapters",
+			ShortName:   "ad",
+			Example:     "adapters",
+			Description: "Look for ELM327 adapters on the serial ports, probing each at the usual baud rates, and over Bluetooth",
+			Action: func(c *cli.Context) {
+				found := comm.Discover()
+				for _, a := range found {
+					log("Adapters - "+a.String(), nil)
+				}
+
+				// Adapters Discover can't get to until they're paired
+				devices, _ := comm.BluetoothDevices()
+				for _, b := range devices {
+					if b.LooksLikeAdapter() && !b.Paired {
+						log("Adapters - "+b.String()+", pair it first (bluetoothctl pair "+b.Address+")", nil)
+					}
+				}
+
+				if len(found) == 0 {
+					log("Adapters - None found", nil)
+				}
+			},
+		},
 		{
 			Name:        "maptest1",
 			ShortName:   "m1",
@@ -160,6 +499,65 @@ func main() {
 				cmp.Compare()
 			},
 		},
+		{
+			Name:        "diff",
+			ShortName:   "df",
+			Example:     "diff msp mp3 --data",
+			Description: "Compare the disassembly of two Calibration Files, function by function",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration1", Usage: "diff msp mp3", Description: "The name of the first calibration", Optional: false},
+				cli.Argument{Name: "calibration2", Usage: "diff msp mp3", Description: "The name of the second calibration", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "min", Value: "8", Usage: "Fewest instructions a function needs to be matched by fingerprint alone"},
+				cli.BoolFlag{Name: "data", Usage: "List every changed run of data bytes, not just how many"},
+			},
+			Action: func(c *cli.Context) {
+				minInstrs, err := strconv.Atoi(c.String("min"))
+				if err != nil {
+					log("Diff - Bad --min count", err)
+					return
+				}
+
+				a := disasm.New(c.NamedArg("calibration1"))
+				anA, err := a.Analyze()
+				if err != nil {
+					log("Diff", err)
+					return
+				}
+				b := disasm.New(c.NamedArg("calibration2"))
+				anB, err := b.Analyze()
+				if err != nil {
+					log("Diff", err)
+					return
+				}
+
+				report := compare.Diff(a, anA, b, anB, minInstrs)
+				log(fmt.Sprintf("Diff - %d functions unchanged, %d changed, %d removed, %d added", report.Matched, len(report.Functions), len(report.Removed), len(report.Added)), nil)
+
+				for _, f := range report.Functions {
+					log(fmt.Sprintf("Function 0x%X -> 0x%X", f.From, f.To), nil)
+					for _, ch := range f.Changes {
+						log("    "+ch.String(), nil)
+					}
+				}
+				for _, adr := range report.Removed {
+					log(fmt.Sprintf("Removed function 0x%X", adr), nil)
+				}
+				for _, adr := range report.Added {
+					log(fmt.Sprintf("Added function 0x%X", adr), nil)
+				}
+
+				changed := 0
+				for _, d := range report.Data {
+					changed += len(d.Old)
+					if c.Bool("data") {
+						log(fmt.Sprintf("Data 0x%X: % X  =>  % X", d.Start, d.Old, d.New), nil)
+					}
+				}
+				log(fmt.Sprintf("Diff - %d data bytes changed in %d runs", changed, len(report.Data)), nil)
+			},
+		},
 		{
 			Name:        "disasm",
 			ShortName:   "x",
@@ -168,9 +566,1241 @@ func main() {
 			Arguments: []cli.Argument{
 				cli.Argument{Name: "calibration", Usage: "disasm msp", Description: "The name of the calibration to disassemble", Optional: false},
 			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+				cli.BoolFlag{Name: "fresh", Usage: "Analyze from scratch even if the project has a saved analysis"},
+				cli.StringFlag{Name: "workers", Usage: "Crawl with this many goroutines"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+				cli.StringFlag{Name: "regions", Usage: "Region map, a JSON file of data ranges to list as DCB, DCW or DCL"},
+				cli.StringFlag{Name: "pointers", Usage: "Crawl from the entries of tables of at least this many code addresses found in the data"},
+				cli.StringFlag{Name: "pseudo", Usage: "Pseudo code style, c (default), python or english"},
+				cli.StringFlag{Name: "known", Usage: "Region definitions, a JSON file of named data areas to keep out of the crawl"},
+				cli.BoolFlag{Name: "detect-data", Usage: "Take code that scores as data out of the crawl"},
+				cli.StringFlag{Name: "xrefs", Usage: "XRefs to keep, default, all (with the zero register and SFRs) or memory (neither)"},
+				cli.StringFlag{Name: "space", Usage: "Address space the jumps and calls wrap in, eec (21 bit), 64k, 1m or 16m"},
+				cli.StringFlag{Name: "ram", Usage: "RAM snapshot from ramdump, file or file@base, to resolve pointers kept in RAM"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) || !setWorkers(d, c.String("workers")) || !setPseudoStyle(d, c.String("pseudo")) || !setKnownRegions(d, c.String("known")) || !setAddressSpace(d, c.String("space")) || !setRAMSnapshot(d, c.String("ram")) || !setXRefPolicy(d, c.String("xrefs")) {
+					return
+				}
+				d.SetDataDetection(c.Bool("detect-data"))
+				if c.String("regions") != "" {
+					m, err := disasm.LoadRegionMap(c.String("regions"))
+					if err == nil {
+						err = d.SetRegionMap(m)
+					}
+					if err != nil {
+						log("Disassemble - Unable to use region map", err)
+						return
+					}
+				}
+				an, err := analyze(d, c.String("project"), c.Bool("fresh"))
+				if err != nil {
+					log("Disassemble", err)
+					return
+				}
+				if c.String("pointers") != "" {
+					minEntries, err := strconv.Atoi(c.String("pointers"))
+					if err != nil {
+						log("Disassemble - Bad --pointers count", err)
+						return
+					}
+					var tables []disasm.PointerTable
+					if an, tables, err = d.FollowPointerTables(an, minEntries); err != nil {
+						log("Disassemble", err)
+						return
+					}
+					for _, t := range tables {
+						log("Disassemble - Pointer table "+t.String(), nil)
+					}
+				}
+				d.SetStrings(d.Strings(an, 6))
+				d.Listing(an)
+			},
+		},
+		{
+			Name:        "decompile",
+			ShortName:   "dc",
+			Example:     "decompile msp --sub 0x13A16E",
+			Description: "Decompile subroutines in a Calibration File to structured pseudo code",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "decompile msp", Description: "The name of the calibration to decompile", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "sub", Usage: "Address of one subroutine, all of them when left out"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+				cli.BoolFlag{Name: "fresh", Usage: "Analyze from scratch even if the project has a saved analysis"},
+				cli.StringFlag{Name: "workers", Usage: "Crawl with this many goroutines"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+				cli.BoolFlag{Name: "vars", Usage: "Name the temporaries of each subroutine var1, var2... instead of by register"},
+				cli.BoolFlag{Name: "merge-aliases", Usage: "With --vars, registers with an alias in the project keep the alias"},
+				cli.StringFlag{Name: "inline", Usage: "Inline calls to straight line leaf subroutines of up to this many instructions"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) || !setWorkers(d, c.String("workers")) {
+					return
+				}
+				d.SetVariableNames(c.Bool("vars"), c.Bool("merge-aliases"))
+				if c.String("inline") != "" {
+					n, err := strconv.Atoi(c.String("inline"))
+					if err != nil {
+						log("Decompile - Bad --inline", err)
+						return
+					}
+					d.SetInlineCalls(n)
+				}
+				an, err := analyze(d, c.String("project"), c.Bool("fresh"))
+				if err != nil {
+					log("Decompile", err)
+					return
+				}
+
+				var code string
+				if c.String("sub") != "" {
+					adr, perr := strconv.ParseInt(c.String("sub"), 0, 32)
+					if perr != nil {
+						log("Decompile - Bad --sub address", perr)
+						return
+					}
+					code, err = d.Decompile(an, int(adr))
+				} else {
+					code, err = d.DecompileAll(an)
+				}
+				if err != nil {
+					log("Decompile", err)
+					return
+				}
+				fmt.Print(code)
+			},
+		},
+		{
+			Name:        "cfg",
+			ShortName:   "g",
+			Example:     "cfg msp 0x13A16E > sub.dot",
+			Description: "Draw the basic blocks of one subroutine as a Graphviz graph, for dot -Tsvg",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "cfg msp 0x13A16E", Description: "The name of the calibration", Optional: false},
+				cli.Argument{Name: "address", Usage: "cfg msp 0x13A16E", Description: "The address of the subroutine", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+			},
+			Action: func(c *cli.Context) {
+				adr, err := strconv.ParseInt(c.NamedArg("address"), 0, 32)
+				if err != nil {
+					log("CFG - Bad address", err)
+					return
+				}
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("CFG", err)
+					return
+				}
+
+				graph, err := d.RenderCFG(an, int(adr))
+				if err != nil {
+					log("CFG", err)
+					return
+				}
+				fmt.Print(graph)
+			},
+		},
+		{
+			Name:        "xref",
+			ShortName:   "xr",
+			Example:     "xref msp --writers R_40",
+			Description: "Query the cross references of a Calibration File",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "xref msp --writers R_40", Description: "The name of the calibration to query", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "to", Usage: "Everything that touches an address (R_40, 0x0CFE, AD_RESULT)"},
+				cli.StringFlag{Name: "from", Usage: "Everything the instruction at an address touches"},
+				cli.StringFlag{Name: "writers", Usage: "Instructions that write an address"},
+				cli.StringFlag{Name: "readers", Usage: "Instructions that read an address"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+				cli.StringFlag{Name: "workers", Usage: "Crawl with this many goroutines"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) || !setWorkers(d, c.String("workers")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("XRef", err)
+					return
+				}
+				index := disasm.NewXRefIndex(an)
+
+				queries := []struct {
+					flag string
+					fn   func(int) []disasm.Ref
+				}{
+					{"to", index.ReferencesTo},
+					{"from", index.ReferencesFrom},
+					{"writers", index.WritersOf},
+					{"readers", index.ReadersOf},
+				}
+				for _, q := range queries {
+					if c.String(q.flag) == "" {
+						continue
+					}
+					adr, err := disasm.ParseAddress(c.String(q.flag))
+					if err != nil {
+						log("XRef", err)
+						return
+					}
+					found := q.fn(adr)
+					log(fmt.Sprintf("XRef - %s 0x%X: %d", q.flag, adr, len(found)), nil)
+					for _, r := range found {
+						log(r.String(), nil)
+					}
+				}
+			},
+		},
+		{
+			Name:        "peripherals",
+			ShortName:   "io",
+			Example:     "peripherals msp --peripheral A/D",
+			Description: "List every instruction that reads or writes a peripheral register, grouped by peripheral",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "peripherals msp", Description: "The name of the calibration to report on", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "peripheral", Usage: "Only this peripheral (A/D, EPA/HSO, Serial, Timers, PWM, Ports, Interrupts, Bus, Other)"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Peripherals", err)
+					return
+				}
+
+				for _, p := range d.Peripherals(an) {
+					if c.String("peripheral") != "" && !strings.EqualFold(c.String("peripheral"), p.Name) {
+						continue
+					}
+					log(fmt.Sprintf("Peripherals - %s: %d accesses", p.Name, len(p.Accesses)), nil)
+					for _, a := range p.Accesses {
+						log(a.String(), nil)
+					}
+				}
+			},
+		},
+		{
+			Name:        "constants",
+			ShortName:   "k",
+			Example:     "constants msp --project projects/msp",
+			Description: "List the data outside the code that the code reads, with each reader, how wide and whether it is used as signed",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "constants msp", Description: "The name of the calibration to report on", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "readers", Usage: "List every reader, not just the totals"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Constants", err)
+					return
+				}
+
+				constants := d.Constants(an)
+				log(fmt.Sprintf("Constants - %d addresses read", len(constants)), nil)
+				for _, k := range constants {
+					log(k.String(), nil)
+					if c.Bool("readers") {
+						for _, r := range k.Readers {
+							log("    "+r.String(), nil)
+						}
+					}
+				}
+			},
+		},
+		{
+			Name:        "checksums",
+			ShortName:   "sum",
+			Example:     "checksums msp --project projects/msp",
+			Description: "Find the routines that look like ROM checksums, with the range they sum and where the checksum is stored",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "checksums msp", Description: "The name of the calibration to search", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Checksums", err)
+					return
+				}
+
+				routines := d.Checksums(an)
+				log(fmt.Sprintf("Checksums - %d routines found", len(routines)), nil)
+				for _, r := range routines {
+					log(r.String(), nil)
+					if sum, err := r.Compute(d.Block()); err == nil {
+						log(fmt.Sprintf("    sum 0x%0*X", r.SumWidth*2, sum), nil)
+					}
+				}
+			},
+		},
+		{
+			Name:        "seedkey",
+			ShortName:   "sk",
+			Example:     "seedkey msp 0x15C2A0 --seed-at 0x0F00 --key-at 0x0F02 --seeds 0x1234,0xBEEF",
+			Description: "Cut the code reachable from a security access routine out of the image and run it in the emulator to work out keys",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "seedkey msp 0x15C2A0", Description: "The name of the calibration", Optional: false},
+				cli.Argument{Name: "entry", Usage: "seedkey msp 0x15C2A0", Description: "Address of the routine that works out the key", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "seed-at", Usage: "Where the routine reads the seed, a register (R_40) or data address"},
+				cli.StringFlag{Name: "key-at", Usage: "Where the routine leaves the key, a register (R_40) or data address"},
+				cli.StringFlag{Name: "seeds", Usage: "Seeds to work out keys for, comma separated"},
+				cli.BoolFlag{Name: "list", Usage: "List the instructions in the slice"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				entry, err := disasm.ParseAddress(c.NamedArg("entry"))
+				if err != nil {
+					log("Seed/Key - Bad entry address", err)
+					return
+				}
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Seed/Key", err)
+					return
+				}
+				sk, err := d.SeedKey(an, entry)
+				if err != nil {
+					log("Seed/Key", err)
+					return
+				}
+
+				log(fmt.Sprintf("Seed/Key - %d functions, %d instructions, %d bytes of code and data kept", len(sk.Functions), len(sk.Code), sk.Bytes()), nil)
+				for _, u := range sk.Unresolved {
+					log("Seed/Key - Unresolved: "+u, nil)
+				}
+				if c.Bool("list") {
+					for _, instr := range sk.Code {
+						log(fmt.Sprintf("0x%X  %s", instr.Address, instr.String()), nil)
+					}
+				}
+
+				if c.String("seeds") == "" {
+					return
+				}
+				if sk.SeedAt, err = disasm.ParseAddress(c.String("seed-at")); err != nil {
+					log("Seed/Key - Bad --seed-at", err)
+					return
+				}
+				if sk.KeyAt, err = disasm.ParseAddress(c.String("key-at")); err != nil {
+					log("Seed/Key - Bad --key-at", err)
+					return
+				}
+				for _, s := range strings.Split(c.String("seeds"), ",") {
+					seed, err := strconv.ParseInt(strings.TrimSpace(s), 0, 32)
+					if err != nil {
+						log("Seed/Key - Bad seed", err)
+						return
+					}
+					key, err := sk.Key(int(seed))
+					if err != nil {
+						log(fmt.Sprintf("Seed/Key - Seed 0x%04X", seed), err)
+						continue
+					}
+					log(fmt.Sprintf("Seed/Key - Seed 0x%04X key 0x%04X", seed, key), nil)
+				}
+			},
+		},
+		{
+			Name:        "trace",
+			ShortName:   "tr",
+			Example:     "trace msp 0x17A3C0 --setup R_30=0x1F40 --sfr AD_RESULT=samples:0x100:0x200 --watch 0x17C400:2:r",
+			Description: "Run a routine in the emulator, list what each instruction changed and confirm the code it ran in the disassembly",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "trace msp 0x17A3C0", Description: "The name of the calibration", Optional: false},
+				cli.Argument{Name: "routine", Usage: "trace msp 0x17A3C0", Description: "Address of the routine to call", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "setup", Usage: "Word registers or data addresses to set before the call, reg=value,..."},
+				cli.StringFlag{Name: "sfr", Usage: "Stubs for the SFRs the routine polls, reg[:b]=count:start:step|samples:v:...|states:div|value:v,..."},
+				cli.StringFlag{Name: "break", Usage: "Stop before running the instructions at these addresses, comma separated"},
+				cli.StringFlag{Name: "watch", Usage: "Stop after an instruction touches these addresses, adr[:width][:r|w],..."},
+				cli.BoolFlag{Name: "quiet", Usage: "Don't list the instructions run"},
+				cli.BoolFlag{Name: "confirm", Usage: "Crawl from the code the trace ran that the disassembly missed, and show coverage"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				routine, err := disasm.ParseAddress(c.NamedArg("routine"))
+				if err != nil {
+					log("Trace - Bad routine address", err)
+					return
+				}
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+
+				e := disasm.NewEmulator(d.Block())
+				e.Set = d.InstructionSet()
+				if c.String("setup") != "" {
+					for _, s := range strings.Split(c.String("setup"), ",") {
+						kv := strings.SplitN(s, "=", 2)
+						if len(kv) != 2 {
+							log("Trace - Bad --setup entry: "+s, nil)
+							return
+						}
+						adr, err := disasm.ParseAddress(strings.TrimSpace(kv[0]))
+						if err != nil {
+							log("Trace - Bad --setup address", err)
+							return
+						}
+						v, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 0, 32)
+						if err != nil {
+							log("Trace - Bad --setup value: "+s, nil)
+							return
+						}
+						if adr <= 0xFFFF {
+							adr = e.Data16(adr)
+						}
+						e.Write16(adr, int(v))
+					}
+				}
+
+				if c.String("sfr") != "" {
+					for _, s := range strings.Split(c.String("sfr"), ",") {
+						adr, stub, err := disasm.ParseSFRStub(s)
+						if err != nil {
+							log("Trace - Bad --sfr", err)
+							return
+						}
+						e.MapSFR(adr, stub)
+					}
+				}
+
+				if err := setStops(e, c.String("break"), c.String("watch")); err != nil {
+					log("Trace", err)
+					return
+				}
+
+				t := disasm.NewExecutionTrace(false)
+				e.Trace = func(e *disasm.Emulator, s disasm.TraceStep) {
+					t.Record(e, s)
+					if !c.Bool("quiet") {
+						log(s.String(), nil)
+					}
+				}
+				err = e.Call(routine)
+				log(fmt.Sprintf("Trace - %d instructions, %d states, %d addresses", e.Steps, e.States, len(t.Executed)), err)
+
+				if !c.Bool("confirm") {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Trace", err)
+					return
+				}
+				an, added, err := d.ConfirmCode(an, t)
+				if err != nil {
+					log("Trace", err)
+					return
+				}
+				for _, adr := range added {
+					log(fmt.Sprintf("Trace - Crawled from 0x%X", adr), nil)
+				}
+				_, total := d.Coverage(an)
+				log(fmt.Sprintf("Trace - Coverage %s", total.String()), nil)
+			},
+		},
+		{
+			Name:        "golden",
+			ShortName:   "gl",
+			Example:     "golden --update",
+			Description: "Check the disassembly of the reference binaries against their golden listings",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: "./golden/listings", Usage: "Directory the golden listings are kept in"},
+				cli.BoolFlag{Name: "update", Usage: "Write the listings from the disassembly as it is now"},
+			},
+			Action: func(c *cli.Context) {
+				if c.Bool("update") {
+					if err := golden.Update(c.String("dir")); err != nil {
+						log("Golden", err)
+						return
+					}
+					log("Golden - Listings written to "+c.String("dir"), nil)
+					return
+				}
+				mismatches, err := golden.Check(c.String("dir"))
+				if err != nil {
+					log("Golden", err)
+					return
+				}
+				for _, m := range mismatches {
+					log("Golden - "+m.String(), nil)
+				}
+				log(fmt.Sprintf("Golden - %d lines differ", len(mismatches)), nil)
+			},
+		},
+		{
+			Name:        "immediate",
+			ShortName:   "imm",
+			Example:     "immediate msp 0x1900 --width 2 --project projects/msp",
+			Description: "List the instructions in the code that load a constant straight from the instruction",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "immediate msp 0x1900", Description: "The name of the calibration to search", Optional: false},
+				cli.Argument{Name: "value", Usage: "immediate msp 0x1900", Description: "The value, negative ones match their two's complement", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "width", Value: "0", Usage: "Bytes the immediate takes, 1 or 2, 0 for either"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				value, err := strconv.ParseInt(c.NamedArg("value"), 0, 32)
+				if err != nil {
+					log("Immediate - Bad value", err)
+					return
+				}
+				width, err := strconv.Atoi(c.String("width"))
+				if err != nil || width < 0 || width > 2 {
+					log("Immediate - Bad --width, 1 or 2 or 0 for either", err)
+					return
+				}
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Immediate", err)
+					return
+				}
+
+				found := d.FindImmediate(an, int(value), width)
+				for _, instr := range found {
+					log(fmt.Sprintf("0x%X  %s", instr.Address, instr.String()), nil)
+				}
+				log(fmt.Sprintf("Immediate - %d instructions", len(found)), nil)
+			},
+		},
+		{
+			Name:        "strings",
+			ShortName:   "str",
+			Example:     "strings msp --project projects/msp",
+			Description: "Find the ASCII strings in the data, part numbers and calibration IDs",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "strings msp", Description: "The name of the calibration to search", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "min", Value: "6", Usage: "Shortest run of characters that counts as a string"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in, strings are added to its symbols"},
+			},
+			Action: func(c *cli.Context) {
+				minLen, err := strconv.Atoi(c.String("min"))
+				if err != nil {
+					log("Strings - Bad --min length", err)
+					return
+				}
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Strings", err)
+					return
+				}
+
+				runs := d.Strings(an, minLen)
+				log(fmt.Sprintf("Strings - %d found", len(runs)), nil)
+				for _, r := range runs {
+					log(fmt.Sprintf("0x%X %q", r.Address, r.Text), nil)
+				}
+
+				if c.String("project") == "" {
+					return
+				}
+				p, err := project.Open(c.String("project"))
+				if err != nil {
+					log("Strings - Unable to open project", err)
+					return
+				}
+				added, err := p.LabelStrings(runs)
+				if err != nil {
+					log("Strings", err)
+					return
+				}
+				for _, s := range added {
+					log(fmt.Sprintf("Strings - Labeled 0x%X %s", s.Address, s.Name), nil)
+				}
+			},
+		},
+		{
+			Name:        "stack",
+			ShortName:   "sp",
+			Example:     "stack msp --size 0x100",
+			Description: "Work out the deepest stack use from reset and each interrupt routine",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "stack msp", Description: "The name of the calibration to check", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "size", Usage: "Bytes of stack, entry points deeper than this are flagged"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				size := 0
+				if c.String("size") != "" {
+					n, err := strconv.ParseInt(c.String("size"), 0, 32)
+					if err != nil {
+						log("Stack - Bad --size", err)
+						return
+					}
+					size = int(n)
+				}
+
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Stack", err)
+					return
+				}
+
+				if sp, ok := d.InitialSP(an); ok {
+					log(fmt.Sprintf("Stack - Initial SP 0x%X", sp), nil)
+				}
+
+				reset, deepest := 0, 0
+				for _, s := range d.StackDepths(an, size) {
+					var chain []string
+					for _, adr := range s.Chain {
+						chain = append(chain, fmt.Sprintf("0x%X", adr))
+					}
+					line := fmt.Sprintf("0x%X %-35s %4d bytes  %s", s.Entry, s.Name, s.Max, strings.Join(chain, " -> "))
+					if s.Overflow {
+						line += "  OVERFLOW"
+					}
+					if s.Recursive {
+						line += "  recursive"
+					}
+					log(line, nil)
+					for _, adr := range s.SPWrites {
+						log(fmt.Sprintf("    SP written at 0x%X, not followed", adr), nil)
+					}
+					for _, adr := range s.Unbalanced {
+						log(fmt.Sprintf("    Reached 0x%X with different depths", adr), nil)
+					}
+
+					if s.Name == "RESET" {
+						reset = s.Max
+					} else if s.Max > deepest {
+						deepest = s.Max
+					}
+				}
+
+				worst := fmt.Sprintf("Stack - Worst case, reset path plus the deepest interrupt: %d bytes", reset+deepest)
+				if size > 0 && reset+deepest > size {
+					worst += " OVERFLOW"
+				}
+				log(worst, nil)
+			},
+		},
+		{
+			Name:        "unreachable",
+			ShortName:   "dead",
+			Example:     "unreachable msp --min 12",
+			Description: "List code the crawl never reached: dead code, routines only reached through pointers, or data that looks like code",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "unreachable msp", Description: "The name of the calibration to check", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "min", Value: "8", Usage: "Fewest instructions a run needs to be listed"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+			},
+			Action: func(c *cli.Context) {
+				minInstrs, err := strconv.Atoi(c.String("min"))
+				if err != nil {
+					log("Unreachable - Bad --min count", err)
+					return
+				}
+
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Unreachable", err)
+					return
+				}
+
+				found := d.Unreachable(an, minInstrs)
+				log(fmt.Sprintf("Unreachable - %d runs", len(found)), nil)
+				for _, r := range found {
+					exit := r.Exit
+					if exit == "" {
+						exit = "runs into reached code"
+					}
+					log(fmt.Sprintf("0x%X-0x%X  %4d instructions  %s", r.Start, r.End-1, r.Instrs, exit), nil)
+				}
+			},
+		},
+		{
+			Name:        "entries",
+			ShortName:   "ep",
+			Example:     "entries msp",
+			Description: "List where code starts: the reset address, the interrupt and PTS vectors, and the pointer tables the code refers to",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "entries msp", Description: "The name of the calibration to search", Optional: false},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				entries, err := disasm.DiscoverEntryPoints(d.Block())
+				if err != nil {
+					log("Entries", err)
+					return
+				}
+
+				log(fmt.Sprintf("Entries - %d entry points", len(entries)), nil)
+				for _, e := range entries {
+					log(e.String(), nil)
+				}
+			},
+		},
+		{
+			Name:        "coverage",
+			ShortName:   "cov",
+			Example:     "coverage msp --regions regions.json",
+			Description: "Show how much of each memory location the disassembly decoded as code, knows as data, or left unknown",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "coverage msp", Description: "The name of the calibration to check", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+				cli.StringFlag{Name: "regions", Usage: "Region map, a JSON file of data ranges"},
+			},
 			Action: func(c *cli.Context) {
 				d := disasm.New(c.NamedArg("calibration"))
-				d.DisAsm()
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				if c.String("regions") != "" {
+					m, err := disasm.LoadRegionMap(c.String("regions"))
+					if err == nil {
+						err = d.SetRegionMap(m)
+					}
+					if err != nil {
+						log("Coverage - Unable to use region map", err)
+						return
+					}
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Coverage", err)
+					return
+				}
+				d.SetStrings(d.Strings(an, 6))
+
+				locations, total := d.Coverage(an)
+				for _, l := range locations {
+					log(fmt.Sprintf("0x%06X-0x%06X  %s  %s", l.Start, l.Stop, l.String(), l.Name), nil)
+				}
+				log(fmt.Sprintf("Coverage - %d bytes  %s", total.Total(), total.String()), nil)
+			},
+		},
+		{
+			Name:        "stats",
+			ShortName:   "st",
+			Example:     "stats msp --top 30",
+			Description: "Count the mnemonics, addressing modes, categories and immediate values in the disassembly of a Calibration File",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "stats msp", Description: "The name of the calibration to count", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "top", Value: "20", Usage: "How many of the most used immediate values to list"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory to keep the analysis in between runs"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+			},
+			Action: func(c *cli.Context) {
+				top, err := strconv.Atoi(c.String("top"))
+				if err != nil {
+					log("Stats - Bad --top count", err)
+					return
+				}
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Stats", err)
+					return
+				}
+
+				st := an.Stats()
+				log(fmt.Sprintf("Stats - %d instructions, %d bytes", st.Instructions, st.Bytes), nil)
+				for _, list := range []struct {
+					name   string
+					counts []disasm.Count
+				}{{"Categories", st.Categories}, {"Addressing modes", st.Modes}, {"Mnemonics", st.Mnemonics}} {
+					log("Stats - "+list.name, nil)
+					for _, n := range list.counts {
+						log(fmt.Sprintf("%8d  %5.1f%%  %s", n.N, st.Percent(n.N), n.Name), nil)
+					}
+				}
+				log("Stats - Immediate values", nil)
+				for i, n := range st.Immediates {
+					if i == top {
+						break
+					}
+					log(fmt.Sprintf("%8d  %s", n.N, n.String()), nil)
+				}
+				log("Stats - Code that looks like data", nil)
+				for _, r := range d.SuspectCode(an) {
+					log(r.String(), nil)
+				}
+			},
+		},
+		{
+			Name:        "scan",
+			ShortName:   "sc",
+			Example:     "scan msp \"A1 ?? ?? 30 C?\"",
+			Description: "Find a byte signature in a Calibration File, ?? matches any byte and ? any nibble",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "scan msp \"A1 ?? ?? 30\"", Description: "The name of the calibration to scan", Optional: false},
+				cli.Argument{Name: "pattern", Usage: "scan msp \"A1 ?? ?? 30\"", Description: "Hex bytes to look for", Optional: false},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				found, err := d.Scan(c.NamedArg("pattern"))
+				if err != nil {
+					log("Scan", err)
+					return
+				}
+
+				log(fmt.Sprintf("Scan - %d matches", len(found)), nil)
+				block := d.Block()
+				for _, adr := range found {
+					line := fmt.Sprintf("0x%X", adr)
+					if adr+10 <= len(block) {
+						if instr, err := disasm.Parse(block[adr:adr+10], adr); err == nil {
+							line += "  " + instr.Mnemonic + "  " + instr.PseudoCode
+						}
+					}
+					log(line, nil)
+				}
+			},
+		},
+		{
+			Name:        "match",
+			ShortName:   "fp",
+			Example:     "match msp mp3 --from projects/msp --into projects/mp3",
+			Description: "Match functions between two Calibration Files by fingerprint, and carry the subroutine names across",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "from", Usage: "match msp mp3", Description: "The calibration with the known functions", Optional: false},
+				cli.Argument{Name: "to", Usage: "match msp mp3", Description: "The calibration to label", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "from", Usage: "Project of the first calibration, with the names to carry across"},
+				cli.StringFlag{Name: "into", Usage: "Project of the second calibration to add the names to"},
+				cli.StringFlag{Name: "min", Value: "8", Usage: "Fewest instructions a function needs to be matched"},
+			},
+			Action: func(c *cli.Context) {
+				minInstrs, err := strconv.Atoi(c.String("min"))
+				if err != nil {
+					log("Match - Bad --min count", err)
+					return
+				}
+
+				a := disasm.New(c.NamedArg("from"))
+				anA, err := analyze(a, c.String("from"), false)
+				if err != nil {
+					log("Match", err)
+					return
+				}
+				b := disasm.New(c.NamedArg("to"))
+				anB, err := analyze(b, c.String("into"), false)
+				if err != nil {
+					log("Match", err)
+					return
+				}
+
+				matches := disasm.MatchFunctions(a.Fingerprints(anA), b.Fingerprints(anB), minInstrs)
+				log(fmt.Sprintf("Match - %d functions matched", len(matches)), nil)
+				for _, m := range matches {
+					log(fmt.Sprintf("0x%X -> 0x%X (%d instructions)", m.From, m.To, m.Instrs), nil)
+				}
+
+				if c.String("from") == "" || c.String("into") == "" {
+					return
+				}
+				from, err := project.Open(c.String("from"))
+				if err != nil {
+					log("Match - Unable to open project", err)
+					return
+				}
+				into, err := project.Open(c.String("into"))
+				if err != nil {
+					log("Match - Unable to open project", err)
+					return
+				}
+				added, err := into.LabelMatches(from, matches)
+				if err != nil {
+					log("Match", err)
+					return
+				}
+				for _, s := range added {
+					log(fmt.Sprintf("Match - Labeled 0x%X %s", s.Address, s.Name), nil)
+				}
+			},
+		},
+		{
+			Name:        "export",
+			ShortName:   "exp",
+			Example:     "export msp --project projects/mp3 --out msp.json",
+			Description: "Export the disassembly of a Calibration File as JSON, as an HTML page to browse, or its symbols",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "export msp", Description: "The name of the calibration to export", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "out", Usage: "File to write, stdout when left out"},
+				cli.BoolFlag{Name: "html", Usage: "Write an HTML page with linked cross references instead of JSON"},
+				cli.BoolFlag{Name: "sym", Usage: "Write the labels, function names and data symbols as a .sym file instead of JSON"},
+				cli.BoolFlag{Name: "csv", Usage: "Write the labels, function names and data symbols as CSV instead of JSON"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory with the analysis and comments"},
+				cli.StringFlag{Name: "workers", Usage: "Crawl with this many goroutines"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+				cli.StringFlag{Name: "pseudo", Usage: "Pseudo code style, c (default), python or english"},
+				cli.StringFlag{Name: "known", Usage: "Region definitions, a JSON file of named data areas to keep out of the crawl"},
+				cli.BoolFlag{Name: "detect-data", Usage: "Take code that scores as data out of the crawl"},
+				cli.StringFlag{Name: "xrefs", Usage: "XRefs to keep, default, all (with the zero register and SFRs) or memory (neither)"},
+				cli.StringFlag{Name: "space", Usage: "Address space the jumps and calls wrap in, eec (21 bit), 64k, 1m or 16m"},
+				cli.StringFlag{Name: "ram", Usage: "RAM snapshot from ramdump, file or file@base, to resolve pointers kept in RAM"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) || !setWorkers(d, c.String("workers")) || !setPseudoStyle(d, c.String("pseudo")) || !setKnownRegions(d, c.String("known")) || !setAddressSpace(d, c.String("space")) || !setRAMSnapshot(d, c.String("ram")) || !setXRefPolicy(d, c.String("xrefs")) {
+					return
+				}
+				d.SetDataDetection(c.Bool("detect-data"))
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Export", err)
+					return
+				}
+
+				export := d.Export
+				switch {
+				case c.Bool("html"):
+					export = d.ExportHTML
+				case c.Bool("sym"):
+					export = d.ExportSymbols
+				case c.Bool("csv"):
+					export = d.ExportSymbolsCSV
+				}
+				if c.String("out") == "" {
+					export(an, os.Stdout)
+					return
+				}
+				f, err := os.Create(c.String("out"))
+				if err != nil {
+					log("Export", err)
+					return
+				}
+				defer f.Close()
+				if err := export(an, f); err != nil {
+					log("Export", err)
+				}
+			},
+		},
+		{
+			Name:        "source",
+			ShortName:   "src",
+			Example:     "source msp --project projects/mp3 --out msp.asm",
+			Description: "Export a Calibration File as assembler source that assembles back into the same image",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "source msp", Description: "The name of the calibration to export", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "out", Usage: "File to write, stdout when left out"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "project", Usage: "Project directory with the analysis and symbols"},
+				cli.StringFlag{Name: "workers", Usage: "Crawl with this many goroutines"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+				cli.StringFlag{Name: "known", Usage: "Region definitions, a JSON file of named data areas to keep out of the crawl"},
+				cli.BoolFlag{Name: "detect-data", Usage: "Take code that scores as data out of the crawl"},
+				cli.StringFlag{Name: "xrefs", Usage: "XRefs to keep, default, all (with the zero register and SFRs) or memory (neither)"},
+				cli.StringFlag{Name: "space", Usage: "Address space the jumps and calls wrap in, eec (21 bit), 64k, 1m or 16m"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) || !setWorkers(d, c.String("workers")) || !setKnownRegions(d, c.String("known")) || !setAddressSpace(d, c.String("space")) || !setXRefPolicy(d, c.String("xrefs")) {
+					return
+				}
+				d.SetDataDetection(c.Bool("detect-data"))
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Source", err)
+					return
+				}
+
+				if c.String("out") == "" {
+					if err := asm.WriteSource(d, an, os.Stdout); err != nil {
+						log("Source", err)
+					}
+					return
+				}
+				f, err := os.Create(c.String("out"))
+				if err != nil {
+					log("Source", err)
+					return
+				}
+				defer f.Close()
+				if err := asm.WriteSource(d, an, f); err != nil {
+					log("Source", err)
+				}
+			},
+		},
+		{
+			Name:        "import",
+			ShortName:   "sym",
+			Example:     "import factory.map --dir projects/mp3",
+			Description: "Import the symbols in a linker map or symbol file into a project",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "file", Usage: "import factory.map", Description: "The map or symbol file", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Project directory"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map the addresses are resolved through, built in (196ea) or a JSON file"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.NewBlock(nil)
+				if !setMemoryMap(d, c.String("memmap")) {
+					return
+				}
+				p, err := project.Open(c.String("dir"))
+				if err != nil {
+					log("Import - Unable to open project", err)
+					return
+				}
+				added, err := p.ImportSymbols(c.NamedArg("file"), d.MemoryMap())
+				if err != nil {
+					log("Import", err)
+					return
+				}
+				log(fmt.Sprintf("Import - %d symbols added", len(added)), nil)
+				for _, s := range added {
+					log(fmt.Sprintf("0x%X  %s", s.Address, s.Name), nil)
+				}
+			},
+		},
+		{
+			Name:        "add-image",
+			ShortName:   "ai",
+			Example:     "add-image bank.bin --base 0x180000 --name bank --dir projects/mp3",
+			Description: "Add another image to a project, an external flash bank say, analyzed along with the calibration at its own address",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "file", Usage: "add-image bank.bin --base 0x180000", Description: "The image file", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Project directory"},
+				cli.StringFlag{Name: "name", Usage: "Name of the image, the file's name if not given"},
+				cli.StringFlag{Name: "base", Usage: "CPU address of the image's first byte"},
+			},
+			Action: func(c *cli.Context) {
+				base, err := disasm.ParseAddress(c.String("base"))
+				if err != nil {
+					log("Add Image - Bad --base address", err)
+					return
+				}
+				image, err := ioutil.ReadFile(c.NamedArg("file"))
+				if err != nil {
+					log("Add Image - Unable to read image", err)
+					return
+				}
+				name := c.String("name")
+				if name == "" {
+					name = strings.TrimSuffix(filepath.Base(c.NamedArg("file")), filepath.Ext(c.NamedArg("file")))
+				}
+				p, err := project.Open(c.String("dir"))
+				if err != nil {
+					log("Add Image - Unable to open project", err)
+					return
+				}
+				if err := p.AddImage(name, base, image); err != nil {
+					log("Add Image", err)
+					return
+				}
+				log(fmt.Sprintf("Add Image - %s, %d bytes at 0x%X", name, len(image), base), nil)
+			},
+		},
+		{
+			Name:        "images",
+			ShortName:   "im",
+			Example:     "images msp --project projects/mp3",
+			Description: "List the images of a project and the calls, jumps and XRefs from one into another",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "calibration", Usage: "images msp --project projects/mp3", Description: "The name of the calibration the project is for", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "project", Usage: "Project directory holding the images"},
+				cli.StringFlag{Name: "memmap", Usage: "Memory map, built in (196ea) or a JSON file"},
+				cli.StringFlag{Name: "cpu", Usage: "Instruction set, 196ea (default), 196kr, 196kc or 196kb"},
+			},
+			Action: func(c *cli.Context) {
+				d := disasm.New(c.NamedArg("calibration"))
+				if !setMemoryMap(d, c.String("memmap")) || !setInstructionSet(d, c.String("cpu")) {
+					return
+				}
+				an, err := analyze(d, c.String("project"), false)
+				if err != nil {
+					log("Images", err)
+					return
+				}
+				for _, m := range d.Images() {
+					log("Images - "+m.String(), nil)
+				}
+				refs, err := d.CrossImageRefs(an)
+				if err != nil {
+					log("Images", err)
+					return
+				}
+				log(fmt.Sprintf("Images - %d references between images", len(refs)), nil)
+				for _, x := range refs {
+					log(x.String(), nil)
+				}
+			},
+		},
+		{
+			Name:        "comment",
+			ShortName:   "rem",
+			Example:     "comment 0x13A16E \"Fuel table lookup\" --dir projects/mp3",
+			Description: "Attach a comment to an address in a project, an empty comment removes it",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "address", Usage: "comment 0x13A16E \"Fuel table lookup\"", Description: "The address to comment", Optional: false},
+				cli.Argument{Name: "text", Usage: "comment 0x13A16E \"Fuel table lookup\"", Description: "The comment", Optional: true},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Project directory"},
+			},
+			Action: func(c *cli.Context) {
+				adr, err := disasm.ParseAddress(c.NamedArg("address"))
+				if err != nil {
+					log("Comment", err)
+					return
+				}
+				p, err := project.Open(c.String("dir"))
+				if err != nil {
+					log("Comment - Unable to open project", err)
+					return
+				}
+				if err := p.SetComment(adr, c.NamedArg("text")); err != nil {
+					log("Comment", err)
+				}
+			},
+		},
+		{
+			Name:        "alias",
+			ShortName:   "al",
+			Example:     "alias R_40 rpm --dir projects/mp3",
+			Description: "Name a register in a project, the listings, exports and decompiler show the name in its place, an empty name removes it",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "register", Usage: "alias R_40 rpm", Description: "The register to name", Optional: false},
+				cli.Argument{Name: "name", Usage: "alias R_40 rpm", Description: "The name", Optional: true},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Project directory"},
+			},
+			Action: func(c *cli.Context) {
+				reg, err := disasm.ParseAddress(c.NamedArg("register"))
+				if err != nil {
+					log("Alias", err)
+					return
+				}
+				p, err := project.Open(c.String("dir"))
+				if err != nil {
+					log("Alias - Unable to open project", err)
+					return
+				}
+				if err := p.SetAlias(reg, c.NamedArg("name")); err != nil {
+					log("Alias", err)
+				}
+			},
+		},
+		{
+			Name:        "bit",
+			ShortName:   "bit",
+			Example:     "bit R_11.3 closed_loop_flag --dir projects/mp3",
+			Description: "Name a bit of a register in a project, the jumps that test it show the name, an empty name removes it",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "bit", Usage: "bit R_11.3 closed_loop_flag", Description: "The register and bit number", Optional: false},
+				cli.Argument{Name: "name", Usage: "bit R_11.3 closed_loop_flag", Description: "The name", Optional: true},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Project directory"},
+			},
+			Action: func(c *cli.Context) {
+				b, err := disasm.ParseRegisterBit(c.NamedArg("bit"))
+				if err != nil {
+					log("Bit", err)
+					return
+				}
+				p, err := project.Open(c.String("dir"))
+				if err != nil {
+					log("Bit - Unable to open project", err)
+					return
+				}
+				if err := p.NameBit(b, c.NamedArg("name")); err != nil {
+					log("Bit", err)
+				}
 			},
 		},
 		{
@@ -186,6 +1816,103 @@ func main() {
 				d.GetInterrupts()
 			},
 		},
+		{
+			Name:        "simulate",
+			ShortName:   "sim",
+			Example:     "simulate msp mp3 --routine 0x17A3C0 --in rpm=R_30:0:8000:250 --out fuel=R_40",
+			Description: "Run a routine from two calibrations over a sweep of inputs in the emulator and report how the outputs differ",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "stock", Usage: "simulate msp mp3", Description: "The name of the stock calibration", Optional: false},
+				cli.Argument{Name: "patched", Usage: "simulate msp mp3", Description: "The name of the patched calibration", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "routine", Usage: "Address of the routine to call"},
+				cli.StringFlag{Name: "in", Usage: "Inputs to sweep in raw values, [name=]reg:from:to:step[:b],..."},
+				cli.StringFlag{Name: "out", Usage: "Outputs to compare, [name=]reg[:b][:s],..."},
+				cli.StringFlag{Name: "setup", Usage: "Word registers to set before each call, reg=value,..."},
+				cli.StringFlag{Name: "ecu", Usage: "ECU definition with scalings for the named inputs and outputs"},
+			},
+			Action: func(c *cli.Context) {
+				sweep, err := simSweep(c.String("routine"), c.String("in"), c.String("out"), c.String("setup"))
+				if err != nil {
+					log("Simulate", err)
+					return
+				}
+				if c.String("ecu") != "" {
+					def, err := iso9141.LoadECUDef(c.String("ecu"))
+					if err != nil {
+						log("Simulate - Unable to load ECU definition", err)
+						return
+					}
+					sweep.Scale(def.Scalings)
+				}
+
+				stock := disasm.New(c.NamedArg("stock"))
+				patched := disasm.New(c.NamedArg("patched"))
+				diff, err := sim.Compare(stock.Block(), patched.Block(), sweep)
+				if err != nil {
+					log("Simulate", err)
+					return
+				}
+
+				log(fmt.Sprintf("Simulate - %d points", len(diff.Points)), nil)
+				if !diff.Changed() {
+					log("Simulate - No difference in any output", nil)
+				}
+				for _, band := range diff.Bands {
+					log(band.String(), nil)
+				}
+			},
+		},
+		{
+			Name:        "rename",
+			ShortName:   "mv",
+			Example:     "rename fuel_table main_fuel --dir projects/mp3",
+			Description: "Rename a symbol in a project and every reference to it in comments, patch files, reports and exports",
+			Arguments: []cli.Argument{
+				cli.Argument{Name: "old", Usage: "rename fuel_table main_fuel", Description: "The symbol to rename", Optional: false},
+				cli.Argument{Name: "new", Usage: "rename fuel_table main_fuel", Description: "The new name", Optional: false},
+			},
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Project directory"},
+			},
+			Action: func(c *cli.Context) {
+				p, err := project.Open(c.String("dir"))
+				if err != nil {
+					log("Rename - Unable to open project", err)
+					return
+				}
+				report, err := p.Rename(c.NamedArg("old"), c.NamedArg("new"))
+				if report != nil {
+					logRefs("Rename", report)
+				}
+				if err != nil {
+					log("Rename", err)
+				}
+			},
+		},
+		{
+			Name:        "refcheck",
+			ShortName:   "refs",
+			Example:     "refcheck --dir projects/mp3",
+			Description: "List references in a project to symbols that were renamed or removed",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Project directory"},
+			},
+			Action: func(c *cli.Context) {
+				p, err := project.Open(c.String("dir"))
+				if err != nil {
+					log("Reference Check - Unable to open project", err)
+					return
+				}
+				report, err := p.CheckReferences()
+				if err != nil {
+					log("Reference Check", err)
+					return
+				}
+				logRefs("Reference Check", report)
+			},
+		},
 		{
 			Name:        "calibrate",
 			ShortName:   "cal",
@@ -207,6 +1934,342 @@ func main() {
 	app.Run(os.Args)
 }
 
+// Builds a sweep from the simulate flags
+func simSweep(routine, inputs, outputs, setup string) (sim.Sweep, error) {
+	sweep := sim.Sweep{Setup: make(map[int]int)}
+
+	addr, err := strconv.ParseInt(routine, 0, 32)
+	if err != nil {
+		return sweep, fmt.Errorf("Bad --routine address: %s", routine)
+	}
+	sweep.Routine = int(addr)
+
+	for _, s := range strings.Split(inputs, ",") {
+		in, err := sim.ParseInput(s)
+		if err != nil {
+			return sweep, err
+		}
+		sweep.Inputs = append(sweep.Inputs, in)
+	}
+
+	for _, s := range strings.Split(outputs, ",") {
+		out, err := sim.ParseOutput(s)
+		if err != nil {
+			return sweep, err
+		}
+		sweep.Outputs = append(sweep.Outputs, out)
+	}
+
+	if setup == "" {
+		return sweep, nil
+	}
+	for _, s := range strings.Split(setup, ",") {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return sweep, fmt.Errorf("Bad --setup entry: %s", s)
+		}
+		r, err := sim.Register(kv[0])
+		if err != nil {
+			return sweep, err
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 0, 32)
+		if err != nil {
+			return sweep, fmt.Errorf("Bad --setup value: %s", s)
+		}
+		sweep.Setup[r] = int(v)
+	}
+	return sweep, nil
+}
+
+func setStops(e *disasm.Emulator, breaks, watches string) error {
+	if breaks != "" {
+		for _, s := range strings.Split(breaks, ",") {
+			adr, err := disasm.ParseAddress(s)
+			if err != nil {
+				return fmt.Errorf("Bad --break address: %s", s)
+			}
+			e.Break(adr)
+		}
+	}
+	if watches == "" {
+		return nil
+	}
+	for _, s := range strings.Split(watches, ",") {
+		f := strings.Split(strings.TrimSpace(s), ":")
+		adr, err := disasm.ParseAddress(f[0])
+		if err != nil {
+			return fmt.Errorf("Bad --watch address: %s", s)
+		}
+		width, access := 1, disasm.AccessRead|disasm.AccessWrite
+		for _, a := range f[1:] {
+			switch a {
+			case "r":
+				access = disasm.AccessRead
+			case "w":
+				access = disasm.AccessWrite
+			default:
+				w, err := strconv.Atoi(a)
+				if err != nil || w < 1 {
+					return fmt.Errorf("Bad --watch entry: %s", s)
+				}
+				width = w
+			}
+		}
+		e.Watch(adr, width, access)
+	}
+	return nil
+}
+
+func setMemoryMap(d *disasm.DisAsm, name string) bool {
+	if name == "" {
+		return true
+	}
+	m, err := disasm.FindMemoryMap(name)
+	if err == nil {
+		err = d.SetMemoryMap(m)
+	}
+	if err != nil {
+		log("Disassemble - Unable to use memory map", err)
+		return false
+	}
+	return true
+}
+
+func setInstructionSet(d *disasm.DisAsm, name string) bool {
+	if name == "" {
+		return true
+	}
+	s, err := disasm.FindInstructionSet(name)
+	if err != nil {
+		log("Disassemble - Unable to use instruction set", err)
+		return false
+	}
+	d.SetInstructionSet(s)
+	return true
+}
+
+func setWorkers(d *disasm.DisAsm, workers string) bool {
+	if workers == "" {
+		return true
+	}
+	n, err := strconv.Atoi(workers)
+	if err != nil || n < 1 {
+		log("Disassemble - Bad --workers count", fmt.Errorf("%s", workers))
+		return false
+	}
+	d.SetWorkers(n)
+	return true
+}
+
+func setPseudoStyle(d *disasm.DisAsm, name string) bool {
+	if name == "" {
+		return true
+	}
+	s, err := disasm.ParsePseudoStyle(name)
+	if err != nil {
+		log("Disassemble - Unable to use pseudo code style", err)
+		return false
+	}
+	d.SetPseudoStyle(s)
+	return true
+}
+
+func setKnownRegions(d *disasm.DisAsm, path string) bool {
+	if path == "" {
+		return true
+	}
+	k, err := disasm.LoadKnownRegions(path)
+	if err == nil {
+		err = d.SetKnownRegions(k)
+	}
+	if err != nil {
+		log("Disassemble - Unable to use region definitions", err)
+		return false
+	}
+	return true
+}
+
+func setAddressSpace(d *disasm.DisAsm, name string) bool {
+	if name == "" {
+		return true
+	}
+	t, err := disasm.FindAddressSpace(name)
+	if err == nil {
+		err = d.SetAddressTranslation(t)
+	}
+	if err != nil {
+		log("Disassemble - Unable to use address space", err)
+		return false
+	}
+	return true
+}
+
+func setXRefPolicy(d *disasm.DisAsm, name string) bool {
+	if name == "" {
+		return true
+	}
+	o, err := disasm.FindXRefPolicy(name)
+	if err != nil {
+		log("Disassemble - Unable to use XRef policy", err)
+		return false
+	}
+	d.SetDecodeOptions(o)
+	return true
+}
+
+func setRAMSnapshot(d *disasm.DisAsm, snapshot string) bool {
+	if snapshot == "" {
+		return true
+	}
+	r, err := disasm.ParseRAMSnapshot(snapshot)
+	if err != nil {
+		log("Disassemble - Unable to use RAM snapshot", err)
+		return false
+	}
+	d.SetRAMSnapshot(r)
+	return true
+}
+
+// Analyzes the image, through the project in dir when there is one, and picks up the
+// project's comments, symbols, register aliases and bit names
+func analyze(d *disasm.DisAsm, dir string, fresh bool) (*disasm.Analysis, error) {
+	if dir == "" {
+		return d.Analyze()
+	}
+
+	p, err := project.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if fresh {
+		p.Analysis = nil
+	}
+
+	d.SetComments(p.Comments)
+	d.SetSymbols(p.SymbolNames())
+	d.SetRegisterAliases(p.Aliases)
+	d.SetBitNames(p.BitNames())
+
+	an, restored, err := p.Analyze(d)
+	if err != nil {
+		return nil, err
+	}
+	if restored {
+		log("Analysis restored from project "+p.Name, nil)
+	} else {
+		log("Analysis saved to project "+p.Name, nil)
+	}
+	return an, nil
+}
+
+// The commands that write to the ECU are locked until a project holds a verified backup
+// of the ECU that's connected, the wizard's. A test device writes nothing.
+func writesUnlocked(c *cli.Context, kind string, obd *iso9141.Device) bool {
+	if obd.Dummy {
+		return true
+	}
+	ecuID, err := obd.ReadEcuId()
+	if err == nil {
+		err = wizard.CheckWrites(c.String("project"), ecuID)
+	}
+	if err != nil {
+		log(kind+" - Writes are locked, run the wizard on this ECU and give its project with --project", err)
+		return false
+	}
+	log(fmt.Sprintf("%s - ECU %X, backed up in project %s", kind, ecuID, c.String("project")), nil)
+	return true
+}
+
+// An ECU in its boot code can't identify itself, so recovering one takes the ID of the
+// ECU the project backed up typed in, as confirmation it's the one on the bench
+func recoverUnlocked(c *cli.Context) bool {
+	fmt.Print("====> Recover - Type the ID of the ECU on the bench, as the wizard named its project: ")
+	typed, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	ecuID, err := hex.DecodeString(strings.TrimSpace(typed))
+	if err == nil {
+		err = wizard.CheckWrites(c.String("project"), ecuID)
+	}
+	if err != nil {
+		log("Recover - Writes are locked, give the project with the bench ECU's backup with --project and type its ID", err)
+		return false
+	}
+	log(fmt.Sprintf("Recover - Confirmed as ECU %X, backed up in project %s", ecuID, c.String("project")), nil)
+	return true
+}
+
+// Fixes a calibration image's checksums, the routines that check them found in its own code
+// on top of the pre-calibration
+func checksumFixer(image []byte, memmap string) (func([]byte) error, error) {
+	routines, pre, err := imageChecksums(image, memmap)
+	if err != nil {
+		return nil, err
+	}
+
+	fix := disasm.FixChecksums(routines)
+	return func(img []byte) error {
+		full := append(append([]byte{}, pre...), img...)
+		if err := fix(full); err != nil {
+			return err
+		}
+		copy(img, full[len(pre):])
+		return nil
+	}, nil
+}
+
+// Checks an image's checksums with the routines in its own code
+func checksumChecker(memmap string) func([]byte) error {
+	return func(image []byte) error {
+		routines, pre, err := imageChecksums(image, memmap)
+		if err != nil {
+			return err
+		}
+		return disasm.CheckChecksums(routines)(append(append([]byte{}, pre...), image...))
+	}
+}
+
+// Fixes an image's checksums with the routines in its own code, found once it's patched
+func lateChecksumFixer(memmap string) func([]byte) error {
+	return func(image []byte) error {
+		fix, err := checksumFixer(image, memmap)
+		if err != nil {
+			return err
+		}
+		return fix(image)
+	}
+}
+
+// Finds the checksum routines in an image's code, with PRE2.BIN in front of it for the
+// addresses, and returns them and PRE2.BIN
+func imageChecksums(image []byte, memmap string) ([]disasm.ChecksumRoutine, []byte, error) {
+	pre, err := ioutil.ReadFile("./calibrations/PRE2.BIN")
+	if err != nil {
+		return nil, nil, err
+	}
+	d := disasm.NewBlock(append(append([]byte{}, pre...), image...))
+	if !setMemoryMap(d, memmap) {
+		return nil, nil, fmt.Errorf("Bad memory map %s", memmap)
+	}
+	an, err := d.Analyze()
+	if err != nil {
+		return nil, nil, err
+	}
+	routines := d.Checksums(an)
+	log(fmt.Sprintf("Checksums - %d routines found", len(routines)), nil)
+	return routines, pre, nil
+}
+
+func logRefs(kind string, report *project.RefReport) {
+	for _, ref := range report.Updated {
+		log(kind+" - Updated "+ref.String(), nil)
+	}
+	for _, ref := range report.Dangling {
+		log(kind+" - Dangling "+ref.String(), nil)
+	}
+	if len(report.Dangling) == 0 {
+		log(kind+" - No dangling references", nil)
+	}
+}
+
 // Log Function
 ////////////////..........
 func log(kind string, err error) {
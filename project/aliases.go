@@ -0,0 +1,43 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+// SetAlias names a register, the listings show the name in its place. An empty name removes
+// the alias.
+func (p *Project) SetAlias(reg int, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		delete(p.Aliases, reg)
+		return p.Save()
+	}
+	if err := disasm.CheckAlias(name); err != nil {
+		return err
+	}
+	if _, ok := p.Symbol(name); ok {
+		return fmt.Errorf("%s is already a symbol", name)
+	}
+	if r, ok := p.aliasOf(name); ok && r != reg {
+		return fmt.Errorf("%s is already the alias of R_%02X", name, r)
+	}
+
+	if p.Aliases == nil {
+		p.Aliases = make(map[int]string)
+	}
+	p.Aliases[reg] = name
+	return p.Save()
+}
+
+// The register a name is the alias of
+func (p *Project) aliasOf(name string) (int, bool) {
+	for reg, n := range p.Aliases {
+		if n == name {
+			return reg, true
+		}
+	}
+	return 0, false
+}
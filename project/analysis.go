@@ -0,0 +1,42 @@
+package project
+
+import "github.com/murdinc/ELMFlash/disasm"
+
+// Analyze returns the analysis of the image in h, restored from the project when one was
+// saved for the same image, otherwise crawled and saved for next time. The image is moved
+// to the addresses the project's translation says it runs at first, and the project's
+// other images are laid in at theirs.
+func (p *Project) Analyze(h *disasm.DisAsm) (an *disasm.Analysis, restored bool, err error) {
+	if p.Translation != nil {
+		if err := h.SetAddressTranslation(*p.Translation); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := p.addImages(h); err != nil {
+		return nil, false, err
+	}
+
+	if p.Analysis != nil {
+		if an, err := h.Restore(p.Analysis); err == nil {
+			return an, true, nil
+		}
+	}
+
+	an, err = h.Analyze()
+	if err != nil {
+		return nil, false, err
+	}
+	p.Analysis = h.Database(an)
+	return an, false, p.Save()
+}
+
+// Reanalyze updates an after the bytes from start to stop of the image in h were changed,
+// re-crawling only the functions they're in, and saves it as the analysis of the new image
+func (p *Project) Reanalyze(h *disasm.DisAsm, an *disasm.Analysis, start, stop int) (*disasm.Analysis, error) {
+	an, err := h.Reanalyze(an, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	p.Analysis = h.Database(an)
+	return an, p.Save()
+}
@@ -0,0 +1,19 @@
+package project
+
+import "strings"
+
+// SetComment attaches a comment to an image address, an empty comment removes it. Comments
+// are kept by address so they carry over when the same image is disassembled again.
+func (p *Project) SetComment(adr int, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		delete(p.Comments, adr)
+		return p.Save()
+	}
+
+	if p.Comments == nil {
+		p.Comments = make(map[int]string)
+	}
+	p.Comments[adr] = text
+	return p.Save()
+}
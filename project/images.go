@@ -0,0 +1,72 @@
+package project
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+// Image is an image the project analyzes along with the main one, an external flash bank
+// say, kept in the project directory
+type Image struct {
+	Name string
+	File string // relative to Dir
+	Base int    // CPU address of its first byte
+	CRC  uint32
+}
+
+// AddImage stores another image in the project, to be laid in at base whenever the
+// project's image is analyzed. The saved analysis is dropped, it doesn't cover the image.
+func (p *Project) AddImage(name string, base int, image []byte) error {
+	for _, m := range p.Images {
+		if strings.EqualFold(m.Name, name) {
+			return fmt.Errorf("Project already has an image named %s", name)
+		}
+	}
+
+	// Check it fits with the others before keeping it, the main image is checked when
+	// they're laid into it
+	h := disasm.NewBlock(nil)
+	if p.Translation != nil {
+		if err := h.SetAddressTranslation(*p.Translation); err != nil {
+			return err
+		}
+	}
+	if err := p.addImages(h); err != nil {
+		return err
+	}
+	if err := h.AddImage(name, base, image); err != nil {
+		return err
+	}
+
+	file := strings.ToUpper(name) + ".BIN"
+	if file == backupFile {
+		return fmt.Errorf("An image can't be named %s", name)
+	}
+	if err := ioutil.WriteFile(p.Path(file), image, 0444); err != nil {
+		return err
+	}
+	p.Images = append(p.Images, Image{Name: name, File: file, Base: base, CRC: crc32.ChecksumIEEE(image)})
+	p.Analysis = nil
+	return p.Save()
+}
+
+// Lays the project's images into the block of h
+func (p *Project) addImages(h *disasm.DisAsm) error {
+	for _, m := range p.Images {
+		image, err := ioutil.ReadFile(p.Path(m.File))
+		if err != nil {
+			return err
+		}
+		if crc := crc32.ChecksumIEEE(image); crc != m.CRC {
+			return fmt.Errorf("Image %s has changed, CRC %08X expected %08X", m.File, crc, m.CRC)
+		}
+		if err := h.AddImage(m.Name, m.Base, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
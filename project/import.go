@@ -0,0 +1,148 @@
+package project
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+/*
+	Symbol import. Map and symbol files from the factory tools or passed around the
+	community are read a line at a time, any line that isn't a symbol is skipped. The
+	forms read, addresses in hex with an optional 0x prefix or H suffix (0FF2080H):
+
+		00FF2080 reset                  address and name, linker maps and plain lists
+		00FF2080 T reset                nm output, T and t are code
+		reset = 0xFF2080                assignments and ASM96 EQUs
+		reset EQU 0FF2080H
+		DEF reset FF2080                NoICE .sym files
+
+	Addresses are where the CPU sees them, they are resolved through the memory map to
+	image addresses the way operands are (FFxxxxH is the code page, 16 bit ROM addresses
+	the data page).
+*/
+
+// Where a line is only an address and a name, the address has to start with a digit or 0x
+// so "ADD R40" isn't a symbol
+const symAddress = `(0[Xx][0-9A-Fa-f]+|[0-9][0-9A-Fa-f]*[Hh]?)`
+const symHex = `((?:0[Xx])?[0-9A-Fa-f]+[Hh]?)`
+const symName = `([A-Za-z_][A-Za-z0-9_]*)`
+
+var symbolLines = []struct {
+	re      *regexp.Regexp
+	address int // submatch holding the address, the name is the other one
+	kind    int // submatch holding an nm type letter, 0 if none
+}{
+	{regexp.MustCompile(`^` + symAddress + `\s+` + symName + `$`), 1, 0},
+	{regexp.MustCompile(`^` + symAddress + `\s+([A-Za-z])\s+` + symName + `$`), 1, 2},
+	{regexp.MustCompile(`^` + symName + `\s*(?:=|\s[Ee][Qq][Uu]\s)\s*` + symHex + `$`), 2, 0},
+	{regexp.MustCompile(`^[Dd][Ee][Ff]\s+` + symName + `\s+` + symHex + `$`), 2, 0},
+}
+
+// ParseSymbols reads the symbols in a map or symbol file, at the addresses the file gives
+func ParseSymbols(r io.Reader) ([]Symbol, error) {
+	var syms []Symbol
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		for _, f := range symbolLines {
+			m := f.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name := m[len(m)-1]
+			if f.address == len(m)-1 {
+				name = m[1]
+			}
+			hex := strings.TrimRight(m[f.address], "Hh")
+			if strings.HasPrefix(hex, "0x") || strings.HasPrefix(hex, "0X") {
+				hex = hex[2:]
+			}
+			adr, err := strconv.ParseInt(hex, 16, 64)
+			if err != nil {
+				break
+			}
+			s := Symbol{Name: name, Address: int(adr)}
+			if f.kind != 0 && strings.ToUpper(m[f.kind]) == "T" {
+				s.Kind = "sub"
+			}
+			syms = append(syms, s)
+			break
+		}
+	}
+	return syms, scanner.Err()
+}
+
+// ImportSymbols adds the symbols in a map or symbol file, resolved to image addresses
+// through memory. Names already in use and addresses that already have a symbol are left
+// alone. Returns the symbols added.
+func (p *Project) ImportSymbols(path string, memory *disasm.MemoryMap) ([]Symbol, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	syms, err := ParseSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("Symbol file %s: %s", path, err)
+	}
+
+	named := make(map[int]bool)
+	for _, s := range p.Symbols {
+		if s.Kind != "bit" {
+			named[s.Address] = true
+		}
+	}
+
+	var added []Symbol
+	for _, s := range syms {
+		s.Address = memory.Resolve(s.Address)
+		if kind := memory.Kind(s.Address); s.Kind == "" && (kind == disasm.KindRAM || kind == disasm.KindSFR) {
+			s.Kind = "ram"
+		}
+		if named[s.Address] {
+			continue
+		}
+		if _, ok := p.Symbol(s.Name); ok {
+			continue
+		}
+		named[s.Address] = true
+		p.Symbols = append(p.Symbols, s)
+		delete(p.Retired, s.Name)
+		added = append(added, s)
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+	return added, p.Save()
+}
+
+// SymbolNames is the symbols by image address, for the listings, bits aren't addresses
+func (p *Project) SymbolNames() map[int]string {
+	names := make(map[int]string)
+	for _, s := range p.Symbols {
+		if s.Kind != "bit" {
+			names[s.Address] = s.Name
+		}
+	}
+	return names
+}
+
+// BitNames is the bit symbols by register bit, for the listings
+func (p *Project) BitNames() disasm.BitNames {
+	names := make(disasm.BitNames)
+	for _, s := range p.Symbols {
+		if s.Kind == "bit" {
+			names[disasm.RegisterBit{Reg: s.Address, Bit: s.Bit}] = s.Name
+		}
+	}
+	return names
+}
@@ -0,0 +1,117 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+const projectFile = "project.json"
+const backupFile = "BACKUP.BIN"
+
+// Project is a working directory for one ECU, holding its backup and everything
+// worked out about it
+type Project struct {
+	Name      string
+	Dir       string `json:"-"`
+	Created   time.Time
+	EcuID     string
+	Backup    string // backup image, relative to Dir
+	BackupCRC uint32
+	Verified  bool // the backup was read twice from the ECU and both reads matched
+
+	Translation *disasm.AddressTranslation `json:",omitempty"` // where the image runs, when it isn't where the EEC images do
+	Images      []Image                    `json:",omitempty"` // analyzed along with it, at their own addresses
+
+	Symbols  []Symbol
+	Comments map[int]string    // by image address
+	Aliases  map[int]string    // register names, by register address
+	Retired  map[string]string // names no longer in use, and what they became ("" if removed)
+
+	Analysis *disasm.Database `json:",omitempty"` // functions, xrefs and regions from the last run
+}
+
+// New creates a project directory, refusing to reuse one that already holds a project
+func New(dir, name string) (*Project, error) {
+	if _, err := os.Stat(filepath.Join(dir, projectFile)); err == nil {
+		return nil, fmt.Errorf("%s already holds a project", dir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	p := &Project{Name: name, Dir: dir, Created: time.Now()}
+	return p, p.Save()
+}
+
+// Open loads the project in dir
+func Open(dir string) (*Project, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, projectFile))
+	if err != nil {
+		return nil, err
+	}
+
+	p := new(Project)
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	p.Dir = dir
+	return p, nil
+}
+
+// Save writes project.json
+func (p *Project) Save() error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(p.Dir, projectFile), data, 0644)
+}
+
+// Path resolves a file inside the project
+func (p *Project) Path(file string) string {
+	return filepath.Join(p.Dir, file)
+}
+
+// SetBackup stores the backup image in the project and records its CRC
+func (p *Project) SetBackup(image []byte, verified bool) error {
+	if err := ioutil.WriteFile(p.Path(backupFile), image, 0444); err != nil {
+		return err
+	}
+	p.Backup = backupFile
+	p.BackupCRC = crc32.ChecksumIEEE(image)
+	p.Verified = verified
+	return p.Save()
+}
+
+// CheckBackup makes sure a verified backup exists and hasn't changed since it was taken
+func (p *Project) CheckBackup() error {
+	if p.Backup == "" {
+		return errors.New("Project has no backup")
+	}
+	if !p.Verified {
+		return errors.New("Project backup was never verified")
+	}
+
+	image, err := ioutil.ReadFile(p.Path(p.Backup))
+	if err != nil {
+		return err
+	}
+	if crc := crc32.ChecksumIEEE(image); crc != p.BackupCRC {
+		return fmt.Errorf("Backup %s has changed, CRC %08X expected %08X", p.Backup, crc, p.BackupCRC)
+	}
+	return nil
+}
+
+// WritesUnlocked is true once the project holds a verified backup
+func (p *Project) WritesUnlocked() bool {
+	return p.CheckBackup() == nil
+}
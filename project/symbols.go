@@ -0,0 +1,375 @@
+package project
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+// Symbol names an address in the image
+type Symbol struct {
+	Name    string
+	Address int
+	Kind    string // sub, table, ram, string, bit...
+	Size    int    // bytes, for tables and strings
+	Bit     int    // bit number, for bits, Address is the register
+}
+
+// Reference is one place a name or address is used
+type Reference struct {
+	File string // relative to the project, or "comment 0x..." for a project comment
+	Line int
+	Name string
+	Text string
+}
+
+func (r Reference) String() string {
+	if r.Line == 0 {
+		return fmt.Sprintf("%s: %s", r.File, r.Text)
+	}
+	return fmt.Sprintf("%s:%d: %s", r.File, r.Line, r.Text)
+}
+
+// RefReport lists what a refactor changed and what still needs a look
+type RefReport struct {
+	Updated  []Reference
+	Dangling []Reference // names that no longer exist, or addresses a table moved away from
+}
+
+// Files that can hold references: patch sources, reports and exports
+var referenceFiles = map[string]bool{".asm": true, ".txt": true, ".csv": true, ".json": true, ".md": true}
+
+var symbolName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Symbol finds a symbol by name
+func (p *Project) Symbol(name string) (*Symbol, bool) {
+	for i := range p.Symbols {
+		if p.Symbols[i].Name == name {
+			return &p.Symbols[i], true
+		}
+	}
+	return nil, false
+}
+
+// AddSymbol names an address
+func (p *Project) AddSymbol(s Symbol) error {
+	if !symbolName.MatchString(s.Name) {
+		return fmt.Errorf("%s isn't a valid symbol name", s.Name)
+	}
+	if _, ok := p.Symbol(s.Name); ok {
+		return fmt.Errorf("Symbol %s already exists", s.Name)
+	}
+	if reg, ok := p.aliasOf(s.Name); ok {
+		return fmt.Errorf("%s is already the alias of R_%02X", s.Name, reg)
+	}
+	p.Symbols = append(p.Symbols, s)
+	delete(p.Retired, s.Name)
+	return p.Save()
+}
+
+// Rename renames a symbol and every reference to it in the project's comments and files,
+// then checks the whole project for anything still dangling
+func (p *Project) Rename(oldName, newName string) (*RefReport, error) {
+	sym, ok := p.Symbol(oldName)
+	if !ok {
+		return nil, fmt.Errorf("No symbol %s", oldName)
+	}
+	if !symbolName.MatchString(newName) {
+		return nil, fmt.Errorf("%s isn't a valid symbol name", newName)
+	}
+	if _, ok := p.Symbol(newName); ok {
+		return nil, fmt.Errorf("Symbol %s already exists", newName)
+	}
+	if reg, ok := p.aliasOf(newName); ok {
+		return nil, fmt.Errorf("%s is already the alias of R_%02X", newName, reg)
+	}
+
+	report := new(RefReport)
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(oldName) + `\b`)
+
+	for adr, comment := range p.Comments {
+		if word.MatchString(comment) {
+			p.Comments[adr] = word.ReplaceAllString(comment, newName)
+			report.Updated = append(report.Updated, Reference{File: fmt.Sprintf("comment 0x%X", adr), Name: oldName, Text: p.Comments[adr]})
+		}
+	}
+
+	err := p.eachFile(func(rel string, data []byte) error {
+		if !word.Match(data) {
+			return nil
+		}
+		report.Updated = append(report.Updated, findReferences(rel, data, word, newName)...)
+		return ioutil.WriteFile(p.Path(rel), word.ReplaceAll(data, []byte(newName)), 0644)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	sym.Name = newName
+	if p.Retired == nil {
+		p.Retired = make(map[string]string)
+	}
+	for name, to := range p.Retired {
+		if to == oldName {
+			p.Retired[name] = newName
+		}
+	}
+	p.Retired[oldName] = newName
+	delete(p.Retired, newName)
+
+	if err := p.Save(); err != nil {
+		return report, err
+	}
+
+	check, err := p.CheckReferences()
+	if err != nil {
+		return report, err
+	}
+	report.Dangling = check.Dangling
+	return report, nil
+}
+
+// Redefine moves or resizes a table. References by name follow it, references by its old
+// address are reported for review since the same number can mean something else.
+func (p *Project) Redefine(name string, address, size int) (*RefReport, error) {
+	sym, ok := p.Symbol(name)
+	if !ok {
+		return nil, fmt.Errorf("No symbol %s", name)
+	}
+
+	report := new(RefReport)
+	if sym.Address != address {
+		old := regexp.MustCompile(fmt.Sprintf(`(?i)\b0x0*%X\b`, sym.Address))
+
+		for adr, comment := range p.Comments {
+			if old.MatchString(comment) {
+				report.Dangling = append(report.Dangling, Reference{File: fmt.Sprintf("comment 0x%X", adr), Name: name, Text: comment})
+			}
+		}
+		err := p.eachFile(func(rel string, data []byte) error {
+			report.Dangling = append(report.Dangling, findReferences(rel, data, old, "")...)
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+
+		// Comments live at addresses, they move with the table
+		if c, ok := p.Comments[sym.Address]; ok {
+			delete(p.Comments, sym.Address)
+			p.Comments[address] = c
+		}
+	}
+
+	sym.Address = address
+	sym.Size = size
+	return report, p.Save()
+}
+
+// RemoveSymbol forgets a symbol, anything still using the name shows up as dangling
+func (p *Project) RemoveSymbol(name string) error {
+	for i := range p.Symbols {
+		if p.Symbols[i].Name == name {
+			p.Symbols = append(p.Symbols[:i], p.Symbols[i+1:]...)
+			if p.Retired == nil {
+				p.Retired = make(map[string]string)
+			}
+			p.Retired[name] = ""
+			return p.Save()
+		}
+	}
+	return fmt.Errorf("No symbol %s", name)
+}
+
+// NameBit names a bit of a register, a symbol of kind bit. An empty name removes the one it
+// has, a bit that has one already keeps it until it's renamed.
+func (p *Project) NameBit(b disasm.RegisterBit, name string) error {
+	var named *Symbol
+	for i := range p.Symbols {
+		if s := &p.Symbols[i]; s.Kind == "bit" && s.Address == b.Reg && s.Bit == b.Bit {
+			named = s
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "" && named == nil:
+		return fmt.Errorf("%s has no name", b)
+	case name == "":
+		return p.RemoveSymbol(named.Name)
+	case named != nil:
+		return fmt.Errorf("%s is already %s, rename it instead", b, named.Name)
+	}
+	return p.AddSymbol(Symbol{Name: name, Address: b.Reg, Kind: "bit", Bit: b.Bit})
+}
+
+// LabelMatches copies the subroutine symbols of another project onto the functions matched
+// to them in this one. Names already in use here are left alone. Returns the symbols added.
+func (p *Project) LabelMatches(from *Project, matches []disasm.FunctionMatch) ([]Symbol, error) {
+	to := make(map[int]int)
+	for _, m := range matches {
+		to[m.From] = m.To
+	}
+
+	var added []Symbol
+	for _, s := range from.Symbols {
+		adr, ok := to[s.Address]
+		if !ok || s.Kind != "sub" {
+			continue
+		}
+		if _, ok := p.Symbol(s.Name); ok {
+			continue
+		}
+		s.Address = adr
+		p.Symbols = append(p.Symbols, s)
+		delete(p.Retired, s.Name)
+		added = append(added, s)
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+	return added, p.Save()
+}
+
+// LabelStrings names the strings found in the image str_<address>, leaving addresses that
+// already have a symbol alone
+func (p *Project) LabelStrings(runs []disasm.StringRun) ([]Symbol, error) {
+	named := make(map[int]bool)
+	for _, s := range p.Symbols {
+		if s.Kind != "bit" {
+			named[s.Address] = true
+		}
+	}
+
+	var added []Symbol
+	for _, r := range runs {
+		if named[r.Address] {
+			continue
+		}
+		s := Symbol{Name: fmt.Sprintf("str_%X", r.Address), Address: r.Address, Kind: "string", Size: len(r.Text)}
+		if _, ok := p.Symbol(s.Name); ok {
+			continue
+		}
+		p.Symbols = append(p.Symbols, s)
+		delete(p.Retired, s.Name)
+		added = append(added, s)
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+	return added, p.Save()
+}
+
+// CheckReferences finds every use of a retired name in the project
+func (p *Project) CheckReferences() (*RefReport, error) {
+	report := new(RefReport)
+	if len(p.Retired) == 0 {
+		return report, nil
+	}
+
+	var names []string
+	for name := range p.Retired {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	sort.Strings(names)
+	retired := regexp.MustCompile(`\b(` + strings.Join(names, "|") + `)\b`)
+
+	for adr, comment := range p.Comments {
+		for _, name := range retired.FindAllString(comment, -1) {
+			report.Dangling = append(report.Dangling, Reference{File: fmt.Sprintf("comment 0x%X", adr), Name: name, Text: p.retiredHint(name)})
+		}
+	}
+
+	err := p.eachFile(func(rel string, data []byte) error {
+		for _, ref := range findReferences(rel, data, retired, "") {
+			ref.Text = p.retiredHint(ref.Name) + ": " + ref.Text
+			report.Dangling = append(report.Dangling, ref)
+		}
+		return nil
+	})
+
+	sort.Sort(references(report.Dangling))
+	return report, err
+}
+
+func (p *Project) retiredHint(name string) string {
+	if to := p.Retired[name]; to != "" {
+		return fmt.Sprintf("%s was renamed to %s", name, to)
+	}
+	return fmt.Sprintf("%s was removed", name)
+}
+
+// Calls fn with every reference file in the project, by path relative to the project
+func (p *Project) eachFile(fn func(rel string, data []byte) error) error {
+	if p.Dir == "" {
+		return errors.New("Project has no directory")
+	}
+
+	return filepath.Walk(p.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !referenceFiles[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		rel, err := filepath.Rel(p.Dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == projectFile {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return fn(rel, data)
+	})
+}
+
+// Lines in data matching re, with the text after replacing matches with repl (kept as is
+// when repl is empty)
+func findReferences(rel string, data []byte, re *regexp.Regexp, repl string) []Reference {
+	var refs []Reference
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		for _, name := range re.FindAllString(line, -1) {
+			text := line
+			if repl != "" {
+				text = re.ReplaceAllString(line, repl)
+			}
+			refs = append(refs, Reference{File: rel, Line: n, Name: name, Text: strings.TrimSpace(text)})
+		}
+	}
+	return refs
+}
+
+type references []Reference
+
+func (r references) Len() int {
+	return len(r)
+}
+
+func (r references) Less(i, j int) bool {
+	if r[i].File != r[j].File {
+		return r[i].File < r[j].File
+	}
+	return r[i].Line < r[j].Line
+}
+
+func (r references) Swap(i, j int) {
+	r[i], r[j] = r[j], r[i]
+}
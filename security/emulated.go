@@ -0,0 +1,142 @@
+package security
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/murdinc/ELMFlash/disasm"
+)
+
+/*
+	Emulated keys. Where nobody has worked out an ECU's algorithm its own code can still
+	work the key out: given the ROM and the address of the routine that calculates it,
+	the routine and everything it calls are sliced out of the image (disasm's SeedKey) and
+	run on the emulator with the live seed stored where the routine reads it, the key read
+	back from where it leaves it. Analyzing the image takes a while, so it's done once,
+	on the first seed, and the slice kept for the rest of the session; each key after that
+	is a run of a few hundred instructions.
+
+	A wrong key costs an attempt, and enough of them lock the ECU out for a while, so a
+	slice that reads an SFR, jumps through a register or calls what wasn't decoded isn't
+	trusted to give one unless AllowUnresolved is set.
+*/
+
+// Emulated works keys out by running the ECU's routine on the emulator. The seed and key
+// are big endian here and little endian in the ECU's memory.
+type Emulated struct {
+	ROM             []byte // the whole image, addressed as disasm.NewBlock takes it
+	Entry           int    // the routine's address
+	SeedAt          int    // where it reads the seed, a register or data address
+	KeyAt           int    // where it leaves the key
+	SeedWidth       int
+	KeyWidth        int
+	MemoryMap       *disasm.MemoryMap // nil for the default
+	AllowUnresolved bool              // use a slice that reads what the emulator can't reproduce
+	Routine         *disasm.SeedKey   // the slice, cut on the first key when nil
+	mu              sync.Mutex
+}
+
+// NewEmulated returns a solver running the routine at entry in rom, with a 2 byte seed
+// at seedAt and a 2 byte key at keyAt
+func NewEmulated(rom []byte, entry, seedAt, keyAt int) *Emulated {
+	return &Emulated{ROM: rom, Entry: entry, SeedAt: seedAt, KeyAt: keyAt, SeedWidth: 2, KeyWidth: 2}
+}
+
+// Key runs the routine on seed
+func (e *Emulated) Key(level byte, seed []byte) ([]byte, error) {
+	sk, err := e.slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != sk.SeedWidth {
+		return nil, fmt.Errorf("Seed of %d bytes, the routine takes %d", len(seed), sk.SeedWidth)
+	}
+
+	s := 0
+	for _, b := range seed {
+		s = s<<8 | int(b)
+	}
+	k, err := sk.Key(s)
+	if err != nil {
+		return nil, fmt.Errorf("Emulating the seed/key routine at 0x%X: %s", e.Entry, err)
+	}
+	key := make([]byte, sk.KeyWidth)
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i] = byte(k)
+		k >>= 8
+	}
+	return key, nil
+}
+
+// Cuts the routine out of the image, once
+func (e *Emulated) slice() (*disasm.SeedKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.Routine != nil {
+		return e.Routine, nil
+	}
+	if len(e.ROM) == 0 {
+		return nil, fmt.Errorf("No ROM for the seed/key routine")
+	}
+
+	d := disasm.NewBlock(e.ROM)
+	if e.MemoryMap != nil {
+		if err := d.SetMemoryMap(e.MemoryMap); err != nil {
+			return nil, err
+		}
+	}
+	an, err := d.Analyze()
+	if err != nil {
+		return nil, err
+	}
+	sk, err := d.SeedKey(an, e.Entry)
+	if err != nil {
+		return nil, err
+	}
+	if len(sk.Unresolved) > 0 && !e.AllowUnresolved {
+		return nil, fmt.Errorf("The seed/key routine at 0x%X can't be trusted in the emulator: %s", e.Entry, strings.Join(sk.Unresolved, ", "))
+	}
+	sk.SeedAt, sk.KeyAt = e.SeedAt, e.KeyAt
+	if e.SeedWidth > 0 {
+		sk.SeedWidth = e.SeedWidth
+	}
+	if e.KeyWidth > 0 {
+		sk.KeyWidth = e.KeyWidth
+	}
+	e.Routine = sk
+	return sk, nil
+}
+
+// The emulated solver a definition describes, its image looked for in dir
+func (s Spec) emulated(dir string) (SeedKeySolver, error) {
+	if s.Image == "" {
+		return nil, fmt.Errorf("No image")
+	}
+	rom, err := ioutil.ReadFile(filepath.Join(dir, s.Image))
+	if err != nil {
+		return nil, err
+	}
+
+	var adr [3]int
+	for i, a := range []string{s.Entry, s.SeedAt, s.KeyAt} {
+		if adr[i], err = disasm.ParseAddress(a); err != nil {
+			return nil, fmt.Errorf("Bad address %q: %s", a, err)
+		}
+	}
+	e := NewEmulated(rom, adr[0], adr[1], adr[2])
+	if s.SeedWidth > 0 {
+		e.SeedWidth = s.SeedWidth
+	}
+	if s.KeyWidth > 0 {
+		e.KeyWidth = s.KeyWidth
+	}
+	if s.MemoryMap != "" {
+		if e.MemoryMap, err = disasm.FindMemoryMap(s.MemoryMap); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,229 @@
+package security
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/*
+	Seed/key solvers. Security access hands the tester a seed and wants back the key an
+	ECU's own routine works out from it, and every family of ECU has its own routine. A
+	SeedKeySolver is one of them, and the registry holds the solver for each ECU, by its
+	ID in hex as the ECU reports it, so the KWP and UDS flows look one up by what they're
+	talking to instead of having it written in.
+
+	The built in solvers are the simple ones a lot of ECUs use, a mask XORed in (XOR) or a
+	constant added (Add), a table of seeds and their keys sniffed or read out of an ECU
+	(Table), and the ECU's own routine run on the emulator (Emulated). Solvers are set up
+	without code changes from ./definitions/security.json, an ECU ID to a solver each:
+
+		{
+		  "4C01": {"Algorithm": "xor", "Mask": "A5C3"},
+		  "6702": {"Algorithm": "table", "Table": "mp3-keys.txt"},
+		  "6703": {"Algorithm": "emulated", "Image": "MP3-FULL.BIN", "Entry": "0x15C2A0",
+		           "SeedAt": "0x0F00", "KeyAt": "0x0F02"}
+		}
+
+	A table file is a seed and its key in hex a line, '#' starting a comment, and is looked
+	for next to the definitions.
+*/
+
+// SeedKeySolver works out the key to a seed for a security level
+type SeedKeySolver interface {
+	Key(level byte, seed []byte) ([]byte, error)
+}
+
+// Where the solvers are set up
+const definitions = "./definitions/security.json"
+
+var (
+	mu      sync.Mutex
+	solvers = make(map[string]SeedKeySolver)
+)
+
+// Register sets the solver for the ECU with id, hex as it reports it
+func Register(id string, s SeedKeySolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	solvers[normalize(id)] = s
+}
+
+// Solver returns the solver for the ECU with id
+func Solver(id string) (SeedKeySolver, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := solvers[normalize(id)]
+	if !ok {
+		return nil, fmt.Errorf("No seed/key solver for ECU %s", normalize(id))
+	}
+	return s, nil
+}
+
+// SolverFor returns the solver for the ECU whose ID came back as id
+func SolverFor(id []byte) (SeedKeySolver, error) {
+	return Solver(hex.EncodeToString(id))
+}
+
+func normalize(id string) string {
+	return strings.ToUpper(strings.Replace(id, " ", "", -1))
+}
+
+// XOR XORs the seed with Mask, repeated over a longer seed
+type XOR struct {
+	Mask []byte
+}
+
+func (x XOR) Key(level byte, seed []byte) ([]byte, error) {
+	if len(x.Mask) == 0 {
+		return nil, fmt.Errorf("XOR with no mask")
+	}
+	key := make([]byte, len(seed))
+	for i := range seed {
+		key[i] = seed[i] ^ x.Mask[i%len(x.Mask)]
+	}
+	return key, nil
+}
+
+// Add adds Constant to the seed, both big endian and the key as wide as the seed
+type Add struct {
+	Constant []byte
+}
+
+func (a Add) Key(level byte, seed []byte) ([]byte, error) {
+	if len(a.Constant) > len(seed) {
+		return nil, fmt.Errorf("Constant of %d bytes for a seed of %d", len(a.Constant), len(seed))
+	}
+	key := make([]byte, len(seed))
+	carry := 0
+	for i := len(seed) - 1; i >= 0; i-- {
+		c := 0
+		if j := i - (len(seed) - len(a.Constant)); j >= 0 {
+			c = int(a.Constant[j])
+		}
+		sum := int(seed[i]) + c + carry
+		key[i], carry = byte(sum), sum>>8
+	}
+	return key, nil
+}
+
+// Table looks the seed up, by its hex
+type Table map[string][]byte
+
+func (t Table) Key(level byte, seed []byte) ([]byte, error) {
+	key, ok := t[normalize(hex.EncodeToString(seed))]
+	if !ok {
+		return nil, fmt.Errorf("Seed %X isn't in the table", seed)
+	}
+	return key, nil
+}
+
+// LoadTable reads a table file, a seed and its key in hex a line
+func LoadTable(path string) (Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t := make(Table)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s line %d: want a seed and a key", path, n)
+		}
+		seed, err := hex.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: bad seed %s", path, n, fields[0])
+		}
+		key, err := hex.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: bad key %s", path, n, fields[1])
+		}
+		t[normalize(hex.EncodeToString(seed))] = key
+	}
+	return t, scanner.Err()
+}
+
+// Spec is one solver in the definitions
+type Spec struct {
+	Algorithm string // xor, add, table or emulated
+	Mask      string // xor: the mask in hex
+	Constant  string // add: the constant in hex
+	Table     string // table: the file, next to the definitions
+	Image     string // emulated: the ROM image, next to the definitions
+	Entry     string // emulated: the routine's address
+	SeedAt    string // emulated: where it reads the seed, a register (R_40) or data address
+	KeyAt     string // emulated: where it leaves the key
+	SeedWidth int    // emulated: bytes, 2 when not given
+	KeyWidth  int
+	MemoryMap string // emulated: built in (196ea) or a JSON file, the default when not given
+}
+
+// Solver makes the solver spec describes, its files looked for in dir
+func (s Spec) Solver(dir string) (SeedKeySolver, error) {
+	switch strings.ToLower(s.Algorithm) {
+	case "xor":
+		mask, err := hex.DecodeString(s.Mask)
+		if err != nil || len(mask) == 0 {
+			return nil, fmt.Errorf("Bad mask %q", s.Mask)
+		}
+		return XOR{Mask: mask}, nil
+	case "add":
+		c, err := hex.DecodeString(s.Constant)
+		if err != nil || len(c) == 0 {
+			return nil, fmt.Errorf("Bad constant %q", s.Constant)
+		}
+		return Add{Constant: c}, nil
+	case "table":
+		if s.Table == "" {
+			return nil, fmt.Errorf("No table file")
+		}
+		return LoadTable(filepath.Join(dir, s.Table))
+	case "emulated":
+		return s.emulated(dir)
+	}
+	return nil, fmt.Errorf("Unknown algorithm %q, xor, add, table or emulated", s.Algorithm)
+}
+
+// Load registers the solvers in ./definitions/security.json, none if there isn't one
+func Load() error {
+	if _, err := os.Stat(definitions); os.IsNotExist(err) {
+		return nil
+	}
+	return LoadFile(definitions)
+}
+
+// LoadFile registers the solvers in the definitions file at path
+func LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	specs := make(map[string]Spec)
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	for id, spec := range specs {
+		s, err := spec.Solver(filepath.Dir(path))
+		if err != nil {
+			return fmt.Errorf("%s, ECU %s: %s", path, id, err)
+		}
+		Register(id, s)
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/murdinc/ELMFlash/disasm"
+	"github.com/murdinc/ELMFlash/units"
+)
+
+/*
+	Behavioral diffs of calibration edits. A routine (the table lookup, say) is run in the
+	emulator over a sweep of inputs against the stock and the patched image, and the outputs
+	are compared: "R_40 +8.0% for RPM 2000-3000". With scalings from the calibration
+	definition the same report reads "fuel +8.0% (+0.21 ms) for RPM 2000-3000 rpm".
+*/
+
+// Input is a register the routine reads, swept From to To by Step
+type Input struct {
+	Name  string
+	Reg   int
+	Width int // 1 or 2 bytes
+	From  int
+	To    int
+	Step  int
+
+	Scaling *units.Scaling // nil reports raw values
+}
+
+// Output is a register the routine leaves a result in
+type Output struct {
+	Name   string
+	Reg    int
+	Width  int
+	Signed bool
+
+	Scaling *units.Scaling
+}
+
+// Sweep is what to run and what to watch
+type Sweep struct {
+	Routine int
+	Inputs  []Input
+	Outputs []Output
+	Setup   map[int]int // word registers set before every call, pointers and the like
+}
+
+// Point is one set of inputs and the outputs from each image
+type Point struct {
+	Inputs  []int
+	Stock   []int
+	Patched []int
+}
+
+// Band is a run of points along the first input where one output moved the same way
+type Band struct {
+	Output  string
+	Input   string
+	From    int
+	To      int
+	At      string // the other inputs, when there are more than one
+	Percent float64
+	Delta   int // mean raw change, the only change shown for outputs that are zero in the stock image
+	Points  int
+
+	in, out *units.Scaling
+}
+
+func (b Band) String() string {
+	var change string
+	switch {
+	case b.Percent == 0:
+		change = b.out.FormatDelta(b.Delta)
+	case b.out != nil:
+		change = fmt.Sprintf("%+.1f%% (%s)", b.Percent, b.out.FormatDelta(b.Delta))
+	default:
+		change = fmt.Sprintf("%+.1f%%", b.Percent)
+	}
+
+	s := fmt.Sprintf("%s %s for %s %s", b.Output, change, b.Input, b.in.FormatRange(b.From, b.To))
+	if b.At != "" {
+		s += " at " + b.At
+	}
+	return s
+}
+
+// Diff is the result of a sweep over both images
+type Diff struct {
+	Sweep  Sweep
+	Points []Point
+	Bands  []Band
+}
+
+// Changed is true if any output differed anywhere in the sweep
+func (d *Diff) Changed() bool {
+	return len(d.Bands) > 0
+}
+
+// Compare runs the sweep against both images
+func Compare(stock, patched []byte, sweep Sweep) (*Diff, error) {
+	if len(sweep.Inputs) == 0 || len(sweep.Outputs) == 0 {
+		return nil, errors.New("A sweep needs at least one input and one output")
+	}
+	for _, in := range sweep.Inputs {
+		if in.Step <= 0 || in.To < in.From {
+			return nil, fmt.Errorf("Input %s has a bad range %d:%d:%d", in.Name, in.From, in.To, in.Step)
+		}
+	}
+
+	emus := []*disasm.Emulator{disasm.NewEmulator(stock), disasm.NewEmulator(patched)}
+	diff := &Diff{Sweep: sweep}
+
+	var err error
+	sweep.each(func(inputs []int) bool {
+		point := Point{Inputs: inputs}
+		for i, e := range emus {
+			var out []int
+			out, err = sweep.run(e, inputs)
+			if err != nil {
+				err = fmt.Errorf("%s image at %s: %s", []string{"Stock", "Patched"}[i], sweep.describe(inputs, 0), err)
+				return false
+			}
+			if i == 0 {
+				point.Stock = out
+			} else {
+				point.Patched = out
+			}
+		}
+		diff.Points = append(diff.Points, point)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	diff.Bands = diff.bands()
+	return diff, nil
+}
+
+// Each calls fn for every combination of inputs, the first input changing fastest so runs
+// along it are next to each other
+func (s Sweep) each(fn func([]int) bool) {
+	inputs := make([]int, len(s.Inputs))
+	for i, in := range s.Inputs {
+		inputs[i] = in.From
+	}
+
+	for {
+		point := make([]int, len(inputs))
+		copy(point, inputs)
+		if !fn(point) {
+			return
+		}
+
+		i := 0
+		for ; i < len(inputs); i++ {
+			inputs[i] += s.Inputs[i].Step
+			if inputs[i] <= s.Inputs[i].To {
+				break
+			}
+			inputs[i] = s.Inputs[i].From
+		}
+		if i == len(inputs) {
+			return
+		}
+	}
+}
+
+func (s Sweep) run(e *disasm.Emulator, inputs []int) ([]int, error) {
+	e.Reset()
+	for r, v := range s.Setup {
+		e.SetReg16(r, v)
+	}
+	for i, in := range s.Inputs {
+		if in.Width == 1 {
+			e.SetReg8(in.Reg, inputs[i])
+		} else {
+			e.SetReg16(in.Reg, inputs[i])
+		}
+	}
+
+	if err := e.Call(s.Routine); err != nil {
+		return nil, err
+	}
+
+	out := make([]int, len(s.Outputs))
+	for i, o := range s.Outputs {
+		if o.Width == 1 {
+			out[i] = e.Reg8(o.Reg)
+			if o.Signed {
+				out[i] = int(int8(out[i]))
+			}
+		} else {
+			out[i] = e.Reg16(o.Reg)
+			if o.Signed {
+				out[i] = int(int16(out[i]))
+			}
+		}
+	}
+	return out, nil
+}
+
+// Describe names the inputs from skip on, "RPM 2000 LOAD 40"
+func (s Sweep) describe(inputs []int, skip int) string {
+	var parts []string
+	for i := skip; i < len(inputs); i++ {
+		parts = append(parts, fmt.Sprintf("%s %s", s.Inputs[i].Name, s.Inputs[i].Scaling.Format(inputs[i])))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (d *Diff) bands() []Band {
+	var bands []Band
+
+	for o, out := range d.Sweep.Outputs {
+		var cur *Band
+		var sign, sumDelta int
+		var sumPercent float64
+		var percents int
+		var key string
+
+		flush := func() {
+			if cur == nil {
+				return
+			}
+			cur.Delta = sumDelta / cur.Points
+			if percents == cur.Points {
+				cur.Percent = sumPercent / float64(percents)
+			}
+			bands = append(bands, *cur)
+			cur = nil
+		}
+
+		for _, p := range d.Points {
+			delta := p.Patched[o] - p.Stock[o]
+			s := 0
+			if delta > 0 {
+				s = 1
+			} else if delta < 0 {
+				s = -1
+			}
+			at := d.Sweep.describe(p.Inputs, 1)
+
+			if cur != nil && (s != sign || at != key) {
+				flush()
+			}
+			if s == 0 {
+				continue
+			}
+
+			if cur == nil {
+				cur = &Band{Output: out.Name, Input: d.Sweep.Inputs[0].Name, From: p.Inputs[0], At: at, in: d.Sweep.Inputs[0].Scaling, out: out.Scaling}
+				sign, key = s, at
+				sumDelta, sumPercent, percents = 0, 0, 0
+			}
+			cur.To = p.Inputs[0]
+			cur.Points++
+			sumDelta += delta
+			if p.Stock[o] != 0 {
+				sumPercent += float64(delta) * 100 / float64(p.Stock[o])
+				percents++
+			}
+		}
+		flush()
+	}
+	return bands
+}
+
+// Scale looks up a scaling for every input and output by name, as the calibration
+// definition lists them
+func (s *Sweep) Scale(scalings map[string]units.Scaling) {
+	for i := range s.Inputs {
+		if sc, ok := scalings[s.Inputs[i].Name]; ok {
+			s.Inputs[i].Scaling = &sc
+		}
+	}
+	for i := range s.Outputs {
+		if sc, ok := scalings[s.Outputs[i].Name]; ok {
+			s.Outputs[i].Scaling = &sc
+		}
+	}
+}
+
+// Register parses R_30, 0x30 or 48
+func Register(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToUpper(s), "R_") {
+		s = "0x" + s[2:]
+	}
+	v, err := strconv.ParseInt(s, 0, 32)
+	if err != nil || v < 0 || v >= 0x400 {
+		return 0, fmt.Errorf("Bad register: %s", s)
+	}
+	return int(v), nil
+}
+
+// ParseInput reads [name=]reg:from:to:step[:b], b for a byte register
+func ParseInput(s string) (Input, error) {
+	in := Input{Width: 2}
+	name, spec := splitName(s)
+
+	fields := strings.Split(spec, ":")
+	if len(fields) == 5 && fields[4] == "b" {
+		in.Width = 1
+		fields = fields[:4]
+	}
+	if len(fields) != 4 {
+		return in, fmt.Errorf("Input %s should be [name=]reg:from:to:step[:b]", s)
+	}
+
+	var err error
+	if in.Reg, err = Register(fields[0]); err != nil {
+		return in, err
+	}
+	for i, p := range []*int{&in.From, &in.To, &in.Step} {
+		v, err := strconv.ParseInt(fields[i+1], 0, 32)
+		if err != nil {
+			return in, fmt.Errorf("Input %s: %s", s, err)
+		}
+		*p = int(v)
+	}
+
+	in.Name = name
+	if in.Name == "" {
+		in.Name = fmt.Sprintf("R_%02X", in.Reg)
+	}
+	return in, nil
+}
+
+// ParseOutput reads [name=]reg[:b][:s], b for a byte register and s for signed
+func ParseOutput(s string) (Output, error) {
+	out := Output{Width: 2}
+	name, spec := splitName(s)
+
+	fields := strings.Split(spec, ":")
+	var err error
+	if out.Reg, err = Register(fields[0]); err != nil {
+		return out, err
+	}
+	for _, f := range fields[1:] {
+		switch f {
+		case "b":
+			out.Width = 1
+		case "s":
+			out.Signed = true
+		default:
+			return out, fmt.Errorf("Output %s should be [name=]reg[:b][:s]", s)
+		}
+	}
+
+	out.Name = name
+	if out.Name == "" {
+		out.Name = fmt.Sprintf("R_%02X", out.Reg)
+	}
+	return out, nil
+}
+
+func splitName(s string) (string, string) {
+	if i := strings.Index(s, "="); i >= 0 {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	return "", strings.TrimSpace(s)
+}
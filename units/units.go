@@ -0,0 +1,69 @@
+package units
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scaling turns a raw register or table value into engineering units, Raw*Scale + Offset.
+// A nil Scaling leaves values raw, and a zero Scale (left out of a definition file) is 1.
+type Scaling struct {
+	Units  string  // "ms", "deg", "rpm"
+	Scale  float64 // units per count
+	Offset float64
+	Digits int // decimal places to show
+}
+
+func (s *Scaling) scale() float64 {
+	if s.Scale == 0 {
+		return 1
+	}
+	return s.Scale
+}
+
+// Value converts a raw value
+func (s *Scaling) Value(raw int) float64 {
+	if s == nil {
+		return float64(raw)
+	}
+	return float64(raw)*s.scale() + s.Offset
+}
+
+// Raw converts a value in units back to the nearest raw value
+func (s *Scaling) Raw(value float64) int {
+	if s == nil {
+		return int(math.Floor(value + 0.5))
+	}
+	return int(math.Floor((value-s.Offset)/s.scale() + 0.5))
+}
+
+// Format shows a raw value in units, "2.35 ms"
+func (s *Scaling) Format(raw int) string {
+	if s == nil {
+		return fmt.Sprintf("%d", raw)
+	}
+	return s.withUnits(fmt.Sprintf("%.*f", s.Digits, s.Value(raw)))
+}
+
+// FormatRange shows a raw range with the units once, "2000-3000 rpm"
+func (s *Scaling) FormatRange(from, to int) string {
+	if s == nil {
+		return fmt.Sprintf("%d-%d", from, to)
+	}
+	return s.withUnits(fmt.Sprintf("%.*f-%.*f", s.Digits, s.Value(from), s.Digits, s.Value(to)))
+}
+
+// FormatDelta shows a raw difference in units with its sign, the offset doesn't apply
+func (s *Scaling) FormatDelta(delta int) string {
+	if s == nil {
+		return fmt.Sprintf("%+d", delta)
+	}
+	return s.withUnits(fmt.Sprintf("%+.*f", s.Digits, float64(delta)*s.scale()))
+}
+
+func (s *Scaling) withUnits(v string) string {
+	if s.Units == "" {
+		return v
+	}
+	return v + " " + s.Units
+}
@@ -0,0 +1,201 @@
+package wizard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/murdinc/ELMFlash/iso9141"
+	"github.com/murdinc/ELMFlash/project"
+)
+
+// Step is where the wizard is in the first-run flow
+type Step int
+
+const (
+	StepDetect Step = iota
+	StepIdentify
+	StepBackup
+	StepProject
+	StepDone
+)
+
+var stepNames = []string{
+	StepDetect:   "Detect adapter",
+	StepIdentify: "Identify vehicle",
+	StepBackup:   "Backup",
+	StepProject:  "Create project",
+	StepDone:     "Done",
+}
+
+var stepPrompts = []string{
+	StepDetect:   "Plug the OBD adapter into the car and this computer.",
+	StepIdentify: "Turn the ignition to position 1 (engine off).",
+	StepBackup:   "Keep the ignition on, the full backup is read twice and takes a while.",
+	StepProject:  "The backup matched, ready to create the project.",
+	StepDone:     "Setup finished, write features are unlocked for this project.",
+}
+
+func (s Step) String() string {
+	if int(s) < len(stepNames) {
+		return stepNames[s]
+	}
+	return fmt.Sprintf("Step %d", int(s))
+}
+
+// Wizard walks a new user from a bare adapter to a project with a verified backup.
+// It only holds state, a CLI or UI drives it with Prompt and Next.
+type Wizard struct {
+	Step    Step
+	Root    string // projects are created under here, one directory per ECU
+	Device  *iso9141.Device
+	EcuID   []byte
+	Project *project.Project
+	Err     error // the last step's error, the step can be retried with Next
+
+	backup   []byte
+	verified bool
+}
+
+// New starts a wizard that creates its project under root
+func New(root string) *Wizard {
+	return &Wizard{Root: root}
+}
+
+// Prompt is what to tell the user before the current step runs
+func (w *Wizard) Prompt() string {
+	return stepPrompts[w.Step]
+}
+
+// Next runs the current step and moves on if it worked
+func (w *Wizard) Next() error {
+	var err error
+
+	switch w.Step {
+	case StepDetect:
+		w.Device, err = iso9141.Detect()
+
+	case StepIdentify:
+		w.EcuID, err = w.Device.ReadEcuId()
+		if err == nil && len(w.EcuID) == 0 {
+			err = errors.New("ECU didn't identify itself")
+		}
+
+	case StepBackup:
+		err = w.doBackup()
+
+	case StepProject:
+		err = w.doProject()
+
+	case StepDone:
+		return nil
+	}
+
+	w.Err = err
+	if err == nil {
+		w.Step++
+	}
+	return err
+}
+
+// Back up the whole calibration twice and only accept it when both reads match
+func (w *Wizard) doBackup() error {
+	w.backup = nil
+	w.verified = false
+
+	first, err := w.Device.ReadImage()
+	if err != nil {
+		return err
+	}
+	if err := iso9141.CheckImage(first); err != nil {
+		return err
+	}
+
+	second, err := w.Device.ReadImage()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(first, second) {
+		return errors.New("Backup reads don't match, check the connection and try again")
+	}
+
+	w.backup = first
+	w.verified = true
+	return nil
+}
+
+func (w *Wizard) doProject() error {
+	if !w.verified {
+		return errors.New("Can't create a project without a verified backup")
+	}
+
+	name := fmt.Sprintf("%X", w.EcuID)
+	p, err := project.New(filepath.Join(w.Root, name), name)
+	if err != nil {
+		return err
+	}
+	p.EcuID = name
+
+	if err := p.SetBackup(w.backup, w.verified); err != nil {
+		return err
+	}
+
+	w.Project = p
+	return nil
+}
+
+// WritesUnlocked is true once the wizard has finished with a verified backup on disk
+func (w *Wizard) WritesUnlocked() bool {
+	return w.Step == StepDone && w.Project != nil && w.Project.WritesUnlocked()
+}
+
+// CheckWrites is why writing to the ECU that identified itself as ecuID is locked for the
+// project in dir, nil once the project is that ECU's and holds a verified backup that
+// hasn't changed since
+func CheckWrites(dir string, ecuID []byte) error {
+	if dir == "" {
+		return errors.New("Writes are locked until the wizard has made a project with a verified backup")
+	}
+	p, err := project.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := p.CheckBackup(); err != nil {
+		return err
+	}
+
+	// A backup of another car's ECU is no backup of this one
+	if len(ecuID) == 0 {
+		return errors.New("ECU didn't identify itself, can't tell whose backup the project holds")
+	}
+	if id := fmt.Sprintf("%X", ecuID); id != p.EcuID {
+		return fmt.Errorf("Project %s holds the backup of ECU %s, not of ECU %s", p.Name, p.EcuID, id)
+	}
+	return nil
+}
+
+// Run drives the wizard to the end, asking confirm before each step. confirm returning
+// false stops the wizard, it can be picked up again with Run.
+func (w *Wizard) Run(confirm func(step Step, prompt string) bool) error {
+	for w.Step != StepDone {
+		if !confirm(w.Step, w.Prompt()) {
+			return errors.New("Wizard stopped at: " + w.Step.String())
+		}
+		if err := w.Next(); err != nil {
+			log("Wizard - "+w.Step.String(), err)
+			continue
+		}
+		log(fmt.Sprintf("Wizard - %s [OK]", w.Step-1), nil)
+	}
+	return nil
+}
+
+// Log Function
+func log(kind string, err error) {
+	if err == nil {
+		fmt.Printf("====> %s\n", kind)
+	} else {
+		fmt.Printf("[ERROR - %s]: %s\n", kind, err)
+	}
+}
@@ -0,0 +1,42 @@
+package wizard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/murdinc/ELMFlash/project"
+)
+
+// Writes are only unlocked for the ECU the project's backup was read from
+func TestCheckWrites(t *testing.T) {
+	root, err := ioutil.TempDir("", "wizard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	dir := filepath.Join(root, "0102")
+	p, err := project.New(dir, "0102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.EcuID = "0102"
+	if err := p.SetBackup([]byte{0xAA, 0xBB}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckWrites(dir, []byte{0x01, 0x02}); err != nil {
+		t.Errorf("Locked for the project's own ECU: %s", err)
+	}
+	if err := CheckWrites(dir, []byte{0x01, 0x03}); err == nil {
+		t.Error("Unlocked for another ECU")
+	}
+	if err := CheckWrites(dir, nil); err == nil {
+		t.Error("Unlocked for an ECU that didn't identify itself")
+	}
+	if err := CheckWrites("", []byte{0x01, 0x02}); err == nil {
+		t.Error("Unlocked without a project")
+	}
+}